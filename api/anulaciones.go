@@ -0,0 +1,142 @@
+// Package api expone el flujo de anulación de facturas autorizadas
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go-facturacion-sri/database"
+)
+
+// handleAnulacionFactura maneja /api/facturas/db/{id}/anulacion[/confirmar|/rechazar]
+func (s *Server) handleAnulacionFactura(w http.ResponseWriter, r *http.Request) {
+	partes := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/facturas/db/"), "/"), "/")
+	id, err := strconv.Atoi(partes[0])
+	if err != nil || len(partes) < 2 || partes[1] != "anulacion" || len(partes) > 3 {
+		http.Error(w, "Ruta de anulación inválida", http.StatusNotFound)
+		return
+	}
+	accion := ""
+	if len(partes) == 3 {
+		accion = partes[2]
+	}
+
+	if _, err := s.facturas.ObtenerFacturaPorID(r.Context(), id); err != nil {
+		http.Error(w, fmt.Sprintf("Factura no encontrada: %v", err), http.StatusNotFound)
+		return
+	}
+
+	switch {
+	case accion == "" && r.Method == http.MethodGet:
+		s.ListarAnulacionesDB(w, r, id)
+	case accion == "" && r.Method == http.MethodPost:
+		s.SolicitarAnulacionDB(w, r, id)
+	case (accion == "confirmar" || accion == "rechazar") && r.Method == http.MethodPost:
+		s.ResolverAnulacionDB(w, r, id, accion == "confirmar")
+	case accion == "" || accion == "confirmar" || accion == "rechazar":
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Método no permitido")
+	default:
+		http.Error(w, "Ruta de anulación inválida", http.StatusNotFound)
+	}
+}
+
+// escribirErrorAnulacion traduce los errores del flujo de anulación a códigos HTTP
+func escribirErrorAnulacion(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, database.ErrAnulacionPendiente), errors.Is(err, database.ErrSinAnulacionPendiente),
+		errors.Is(err, database.ErrTransicionInvalida):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, fmt.Sprintf("Error en la anulación: %v", err), http.StatusBadRequest)
+	}
+}
+
+// SolicitarAnulacionDB registra la solicitud de anulación de una factura autorizada
+func (s *Server) SolicitarAnulacionDB(w http.ResponseWriter, r *http.Request, facturaID int) {
+	var input struct {
+		Motivo  string `json:"motivo"`
+		Usuario string `json:"usuario"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, fmt.Sprintf("Error parseando JSON: %v", err), http.StatusBadRequest)
+		return
+	}
+	input.Usuario = usuarioSolicitud(r, input.Usuario)
+
+	anulacion, err := s.anulaciones.SolicitarAnulacion(r.Context(), facturaID, input.Motivo, input.Usuario)
+	if err != nil {
+		escribirErrorAnulacion(w, err)
+		return
+	}
+
+	response := map[string]interface{}{
+		"success": true,
+		"message": "Solicitud de anulación registrada; confírmela con la referencia del portal del SRI",
+		"data":    anulacion,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+// ResolverAnulacionDB confirma (con la referencia del SRI) o rechaza la anulación pendiente
+func (s *Server) ResolverAnulacionDB(w http.ResponseWriter, r *http.Request, facturaID int, confirmar bool) {
+	var input struct {
+		ReferenciaSRI string `json:"referenciaSRI"`
+		Observaciones string `json:"observaciones"`
+		Usuario       string `json:"usuario"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, fmt.Sprintf("Error parseando JSON: %v", err), http.StatusBadRequest)
+		return
+	}
+	input.Usuario = usuarioSolicitud(r, input.Usuario)
+
+	var anulacion *database.AnulacionFacturaDB
+	var err error
+	mensaje := "Factura anulada"
+	if confirmar {
+		anulacion, err = s.anulaciones.ConfirmarAnulacion(r.Context(), facturaID, input.ReferenciaSRI, input.Usuario)
+	} else {
+		anulacion, err = s.anulaciones.RechazarAnulacion(r.Context(), facturaID, input.Observaciones, input.Usuario)
+		mensaje = "Anulación rechazada; la factura sigue autorizada"
+	}
+	if err != nil {
+		escribirErrorAnulacion(w, err)
+		return
+	}
+
+	response := map[string]interface{}{
+		"success": true,
+		"message": mensaje,
+		"data":    anulacion,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// ListarAnulacionesDB retorna las solicitudes de anulación de una factura
+func (s *Server) ListarAnulacionesDB(w http.ResponseWriter, r *http.Request, facturaID int) {
+	anulaciones, err := s.anulaciones.ListarAnulaciones(r.Context(), facturaID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error consultando anulaciones: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"success": true,
+		"data": map[string]interface{}{
+			"anulaciones": anulaciones,
+			"count":       len(anulaciones),
+		},
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,195 @@
+// Package api expone el archivo de envelopes SOAP intercambiados con el SRI
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-facturacion-sri/config"
+	"go-facturacion-sri/database"
+	"go-facturacion-sri/sri"
+)
+
+// archivoSOAPDB archiva los intercambios de los clientes SRI del servidor en la base de datos
+type archivoSOAPDB struct {
+	db *database.Database
+}
+
+// ArchivarIntercambio guarda el intercambio según config.Config.SRI.ArchivoSOAP
+func (a archivoSOAPDB) ArchivarIntercambio(intercambio sri.IntercambioSOAP) error {
+	_, err := a.db.GuardarIntercambioSOAP(intercambio, config.Config.SRI.ArchivoSOAP.Comprimir)
+	return err
+}
+
+// purgarArchivoSOAP aplica la política de retención del archivo SOAP
+func (s *Server) purgarArchivoSOAP(retencionDias int) (int64, error) {
+	return s.db.PurgarIntercambiosSOAP(time.Now().AddDate(0, 0, -retencionDias))
+}
+
+// iniciarPurgaArchivoSOAP purga el archivo SOAP al iniciar y luego una vez al día
+func (s *Server) iniciarPurgaArchivoSOAP() {
+	archivo := config.Config.SRI.ArchivoSOAP
+	if !archivo.Habilitado || archivo.RetencionDias <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(24 * time.Hour)
+		defer ticker.Stop()
+
+		for {
+			if eliminados, err := s.purgarArchivoSOAP(archivo.RetencionDias); err != nil {
+				log.Printf("[ARCHIVO_SOAP] Error purgando archivo: %v", err)
+			} else if eliminados > 0 {
+				log.Printf("[ARCHIVO_SOAP] %d intercambios con más de %d días eliminados", eliminados, archivo.RetencionDias)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// parsearFechaFiltro acepta fechas RFC3339 o AAAA-MM-DD; con finDeDia una fecha sin hora
+// se extiende hasta el inicio del día siguiente para que el rango sea inclusivo
+func parsearFechaFiltro(valor string, finDeDia bool) (time.Time, error) {
+	if valor == "" {
+		return time.Time{}, nil
+	}
+	if fecha, err := time.Parse(time.RFC3339, valor); err == nil {
+		return fecha, nil
+	}
+
+	fecha, err := time.ParseInLocation("2006-01-02", valor, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("fecha inválida %q: use AAAA-MM-DD o RFC3339", valor)
+	}
+	if finDeDia {
+		fecha = fecha.AddDate(0, 0, 1)
+	}
+	return fecha, nil
+}
+
+// handleIntercambiosSOAP maneja las rutas dinámicas del archivo SOAP
+func (s *Server) handleIntercambiosSOAP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/purgar") {
+		s.PurgarIntercambiosSOAPDB(w, r)
+	} else if r.Method == http.MethodGet {
+		s.ObtenerIntercambioSOAPDB(w, r)
+	} else {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Método no permitido")
+	}
+}
+
+// BuscarIntercambiosSOAPDB lista los intercambios archivados por clave de acceso, operación o rango de fechas
+func (s *Server) BuscarIntercambiosSOAPDB(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	filtro := database.FiltroIntercambiosSOAP{
+		ClaveAcceso: strings.TrimSpace(query.Get("clave")),
+		Operacion:   strings.ToUpper(strings.TrimSpace(query.Get("operacion"))),
+		Limite:      50,
+	}
+
+	var err error
+	if filtro.Desde, err = parsearFechaFiltro(query.Get("desde"), false); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if filtro.Hasta, err = parsearFechaFiltro(query.Get("hasta"), true); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if l, err := strconv.Atoi(query.Get("limit")); err == nil && l > 0 {
+		filtro.Limite = l
+	}
+	if o, err := strconv.Atoi(query.Get("offset")); err == nil && o >= 0 {
+		filtro.Offset = o
+	}
+
+	intercambios, err := s.db.BuscarIntercambiosSOAP(filtro)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error buscando intercambios: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"success": true,
+		"data": map[string]interface{}{
+			"intercambios": intercambios,
+			"count":        len(intercambios),
+			"limit":        filtro.Limite,
+			"offset":       filtro.Offset,
+			"habilitado":   config.Config.SRI.ArchivoSOAP.Habilitado,
+		},
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// ObtenerIntercambioSOAPDB retorna un intercambio con los envelopes de petición y respuesta
+func (s *Server) ObtenerIntercambioSOAPDB(w http.ResponseWriter, r *http.Request) {
+	idStr := strings.TrimPrefix(r.URL.Path, "/api/sri/intercambios/")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "ID de intercambio inválido", http.StatusBadRequest)
+		return
+	}
+
+	intercambio, err := s.db.ObtenerIntercambioSOAP(id)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Intercambio no encontrado: %v", err), http.StatusNotFound)
+		return
+	}
+
+	response := map[string]interface{}{
+		"success": true,
+		"data":    intercambio,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// PurgarIntercambiosSOAPDB elimina los intercambios más antiguos que ?dias= (por defecto la retención configurada)
+func (s *Server) PurgarIntercambiosSOAPDB(w http.ResponseWriter, r *http.Request) {
+	dias := config.Config.SRI.ArchivoSOAP.RetencionDias
+	if valor := r.URL.Query().Get("dias"); valor != "" {
+		d, err := strconv.Atoi(valor)
+		if err != nil || d <= 0 {
+			http.Error(w, "dias debe ser un entero positivo", http.StatusBadRequest)
+			return
+		}
+		dias = d
+	}
+	if dias <= 0 {
+		http.Error(w, "No hay política de retención configurada", http.StatusBadRequest)
+		return
+	}
+
+	eliminados, err := s.purgarArchivoSOAP(dias)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error purgando intercambios: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"success": true,
+		"message": fmt.Sprintf("%d intercambios con más de %d días eliminados", eliminados, dias),
+		"data": map[string]interface{}{
+			"eliminados":     eliminados,
+			"retencion_dias": dias,
+		},
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
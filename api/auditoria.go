@@ -0,0 +1,117 @@
+// Package api expone la verificación y los checkpoints de la cadena de auditoría
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"go-facturacion-sri/config"
+	"go-facturacion-sri/database"
+)
+
+// claveFirmaAuditoria resuelve la clave HMAC de los checkpoints; nil si no está configurada
+func (s *Server) claveFirmaAuditoria() ([]byte, error) {
+	referencia := config.Config.Auditoria.ClaveFirma
+	if referencia == "" {
+		return nil, nil
+	}
+
+	clave, err := s.secretos.Resolver(referencia)
+	if err != nil {
+		return nil, fmt.Errorf("error resolviendo auditoria.claveFirma: %v", err)
+	}
+	return clave, nil
+}
+
+// iniciarCheckpointsAuditoria exporta un checkpoint firmado al iniciar y luego en cada intervalo
+func (s *Server) iniciarCheckpointsAuditoria() {
+	auditoria := config.Config.Auditoria
+	if auditoria.DirectorioCheckpoints == "" || auditoria.IntervaloCheckpointMinutos <= 0 {
+		return
+	}
+	clave, err := s.claveFirmaAuditoria()
+	if err != nil || len(clave) == 0 {
+		log.Printf("[AUDITORIA] Checkpoints deshabilitados: configure auditoria.claveFirma (%v)", err)
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(time.Duration(auditoria.IntervaloCheckpointMinutos) * time.Minute)
+		defer ticker.Stop()
+
+		for {
+			if ruta, err := s.db.ExportarCheckpointAuditoria(auditoria.DirectorioCheckpoints, clave); err != nil {
+				log.Printf("[AUDITORIA] Error exportando checkpoint: %v", err)
+			} else {
+				log.Printf("[AUDITORIA] Checkpoint exportado en %s", ruta)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// VerificarAuditoriaDB recorre la cadena de hashes de audit_log y reporta el primer quiebre.
+// Si hay checkpoints configurados también comprueba el más reciente.
+func (s *Server) VerificarAuditoriaDB(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Método no permitido")
+		return
+	}
+
+	verificacion, err := s.db.VerificarCadenaAuditoria()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error verificando auditoría: %v", err), http.StatusInternalServerError)
+		return
+	}
+	data := map[string]interface{}{
+		"cadena": verificacion,
+	}
+	valida := verificacion.Valida
+
+	if directorio := config.Config.Auditoria.DirectorioCheckpoints; directorio != "" {
+		estado := map[string]interface{}{"directorio": directorio}
+		clave, err := s.claveFirmaAuditoria()
+		if err == nil && len(clave) == 0 {
+			err = fmt.Errorf("auditoria.claveFirma no está configurada")
+		}
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Error verificando checkpoint: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		checkpoint, ruta, err := database.LeerUltimoCheckpointAuditoria(directorio)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Error leyendo checkpoint: %v", err), http.StatusInternalServerError)
+			return
+		}
+		if checkpoint != nil {
+			estado["archivo"] = ruta
+			estado["checkpoint"] = checkpoint
+			if err := s.db.VerificarCheckpointAuditoria(checkpoint, clave); err != nil {
+				estado["valido"] = false
+				estado["error"] = err.Error()
+				valida = false
+			} else {
+				estado["valido"] = true
+			}
+		}
+		data["checkpoint"] = estado
+	}
+	data["valida"] = valida
+
+	mensaje := "La cadena de auditoría está íntegra"
+	if !valida {
+		mensaje = "Se detectaron alteraciones en el log de auditoría"
+	}
+	response := map[string]interface{}{
+		"success": true,
+		"message": mensaje,
+		"data":    data,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
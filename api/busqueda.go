@@ -0,0 +1,53 @@
+// Package api expone la búsqueda unificada de clientes y productos facturados
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go-facturacion-sri/database"
+)
+
+// BuscarDB busca el texto de q en clientes y líneas de factura, ordenando por relevancia
+func (s *Server) BuscarDB(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Método no permitido")
+		return
+	}
+
+	texto := strings.TrimSpace(r.URL.Query().Get("q"))
+	if texto == "" {
+		http.Error(w, "Parámetro q requerido", http.StatusBadRequest)
+		return
+	}
+	limit := 20
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l <= 100 {
+		limit = l
+	}
+
+	resultados, err := s.busqueda.Buscar(r.Context(), texto, limit)
+	if errors.Is(err, database.ErrBusquedaVacia) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error en la búsqueda: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"success": true,
+		"data": map[string]interface{}{
+			"q":          texto,
+			"resultados": resultados,
+			"count":      len(resultados),
+		},
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
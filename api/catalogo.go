@@ -0,0 +1,186 @@
+// Package api expone el catálogo maestro de productos y servicios
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go-facturacion-sri/database"
+)
+
+// handleCatalogoProductos maneja /api/catalogo/productos (listar y crear)
+func (s *Server) handleCatalogoProductos(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		s.ListarCatalogoDB(w, r)
+	} else if r.Method == http.MethodPost {
+		s.CrearProductoCatalogoDB(w, r)
+	} else {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Método no permitido")
+	}
+}
+
+// handleProductoCatalogo maneja las rutas dinámicas /api/catalogo/productos/{id}
+func (s *Server) handleProductoCatalogo(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		s.ObtenerProductoCatalogoDB(w, r)
+	} else if r.Method == http.MethodPut {
+		s.ActualizarProductoCatalogoDB(w, r)
+	} else if r.Method == http.MethodDelete {
+		s.DesactivarProductoCatalogoDB(w, r)
+	} else {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Método no permitido")
+	}
+}
+
+// idProductoCatalogo extrae el ID de /api/catalogo/productos/{id}
+func idProductoCatalogo(path string) (int, error) {
+	return strconv.Atoi(strings.Trim(strings.TrimPrefix(path, "/api/catalogo/productos/"), "/"))
+}
+
+// ListarCatalogoDB lista el catálogo, con búsqueda por código o descripción
+func (s *Server) ListarCatalogoDB(w http.ResponseWriter, r *http.Request) {
+	busqueda := r.URL.Query().Get("q")
+	incluirInactivos := r.URL.Query().Get("incluirInactivos") == "true"
+	limit := 50
+	offset := 0
+
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 {
+		limit = l
+	}
+	if o, err := strconv.Atoi(r.URL.Query().Get("offset")); err == nil && o >= 0 {
+		offset = o
+	}
+
+	productos, err := s.catalogo.ListarProductosCatalogo(r.Context(), busqueda, incluirInactivos, limit, offset)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error listando catálogo: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"success": true,
+		"data": map[string]interface{}{
+			"productos": productos,
+			"count":     len(productos),
+			"limit":     limit,
+			"offset":    offset,
+		},
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// CrearProductoCatalogoDB agrega un producto al catálogo
+func (s *Server) CrearProductoCatalogoDB(w http.ResponseWriter, r *http.Request) {
+	var input database.ProductoCatalogoDB
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, fmt.Sprintf("Error parseando JSON: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	producto, err := s.catalogo.GuardarProductoCatalogo(r.Context(), &input)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error guardando producto: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	response := map[string]interface{}{
+		"success": true,
+		"message": "Producto agregado al catálogo",
+		"data":    producto,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+// ObtenerProductoCatalogoDB retorna un producto del catálogo
+func (s *Server) ObtenerProductoCatalogoDB(w http.ResponseWriter, r *http.Request) {
+	id, err := idProductoCatalogo(r.URL.Path)
+	if err != nil {
+		http.Error(w, "ID de producto inválido", http.StatusBadRequest)
+		return
+	}
+
+	producto, err := s.catalogo.ObtenerProductoCatalogo(r.Context(), id)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Producto no encontrado: %v", err), http.StatusNotFound)
+		return
+	}
+
+	response := map[string]interface{}{
+		"success": true,
+		"data":    producto,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// ActualizarProductoCatalogoDB actualiza un producto del catálogo
+func (s *Server) ActualizarProductoCatalogoDB(w http.ResponseWriter, r *http.Request) {
+	id, err := idProductoCatalogo(r.URL.Path)
+	if err != nil {
+		http.Error(w, "ID de producto inválido", http.StatusBadRequest)
+		return
+	}
+
+	var input database.ProductoCatalogoDB
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, fmt.Sprintf("Error parseando JSON: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if _, err := s.catalogo.ObtenerProductoCatalogo(r.Context(), id); err != nil {
+		http.Error(w, fmt.Sprintf("Producto no encontrado: %v", err), http.StatusNotFound)
+		return
+	}
+
+	input.ID = id
+	producto, err := s.catalogo.ActualizarProductoCatalogo(r.Context(), &input)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error actualizando producto: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	response := map[string]interface{}{
+		"success": true,
+		"message": "Producto actualizado exitosamente",
+		"data":    producto,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// DesactivarProductoCatalogoDB retira un producto del catálogo (soft delete)
+func (s *Server) DesactivarProductoCatalogoDB(w http.ResponseWriter, r *http.Request) {
+	id, err := idProductoCatalogo(r.URL.Path)
+	if err != nil {
+		http.Error(w, "ID de producto inválido", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := s.catalogo.ObtenerProductoCatalogo(r.Context(), id); err != nil {
+		http.Error(w, fmt.Sprintf("Producto no encontrado: %v", err), http.StatusNotFound)
+		return
+	}
+
+	if err := s.catalogo.DesactivarProductoCatalogo(r.Context(), id); err != nil {
+		http.Error(w, fmt.Sprintf("Error desactivando producto: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"success": true,
+		"message": "Producto desactivado del catálogo",
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
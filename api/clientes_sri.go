@@ -0,0 +1,141 @@
+// Package api administra los clientes SOAP del SRI compartidos por los handlers
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"go-facturacion-sri/config"
+	"go-facturacion-sri/sri"
+)
+
+// ClienteSRI retorna el cliente SOAP del ambiente, creándolo la primera vez.
+// Un único cliente por ambiente permite que el circuit breaker acumule estado entre peticiones.
+func (s *Server) ClienteSRI(ambiente sri.Ambiente) *sri.SOAPClient {
+	s.mutexClientes.Lock()
+	defer s.mutexClientes.Unlock()
+
+	if s.clientesSRI == nil {
+		s.clientesSRI = make(map[sri.Ambiente]*sri.SOAPClient)
+	}
+	cliente, ok := s.clientesSRI[ambiente]
+	if !ok {
+		cliente = sri.NewSOAPClient(ambiente)
+		if s.db != nil {
+			if config.Config.SRI.ArchivoSOAP.Habilitado {
+				cliente.ConfigurarArchivo(archivoSOAPDB{db: s.db})
+			}
+			cliente.ConfigurarColaFallidos(s.db)
+		}
+		s.clientesSRI[ambiente] = cliente
+	}
+	return cliente
+}
+
+// ConfigurarClienteSRI reemplaza el cliente SOAP de un ambiente (p.ej. por uno del simulador)
+func (s *Server) ConfigurarClienteSRI(ambiente sri.Ambiente, cliente *sri.SOAPClient) {
+	s.mutexClientes.Lock()
+	defer s.mutexClientes.Unlock()
+
+	if s.clientesSRI == nil {
+		s.clientesSRI = make(map[sri.Ambiente]*sri.SOAPClient)
+	}
+	s.clientesSRI[ambiente] = cliente
+}
+
+// ambienteSolicitado lee ?ambiente= (1, 2, pruebas o produccion); sin valor usa el configurado
+func ambienteSolicitado(r *http.Request) (sri.Ambiente, error) {
+	switch strings.ToLower(strings.TrimSpace(r.URL.Query().Get("ambiente"))) {
+	case "":
+		return sri.AmbienteConfigurado(), nil
+	case "1", "pruebas":
+		return sri.Pruebas, nil
+	case "2", "produccion", "producción":
+		return sri.Produccion, nil
+	default:
+		return 0, fmt.Errorf("ambiente inválido: use 1 (pruebas) o 2 (produccion)")
+	}
+}
+
+// nombreAmbiente nombre legible del ambiente para las respuestas JSON
+func nombreAmbiente(ambiente sri.Ambiente) string {
+	if ambiente == sri.Produccion {
+		return "PRODUCCION"
+	}
+	return "PRUEBAS"
+}
+
+// estadoClienteSRI resume el circuit breaker y los endpoints de un cliente
+func estadoClienteSRI(ambiente sri.Ambiente, cliente *sri.SOAPClient) map[string]interface{} {
+	recepcion, autorizacion := cliente.Endpoints()
+	return map[string]interface{}{
+		"ambiente":              nombreAmbiente(ambiente),
+		"codigo_ambiente":       ambiente.String(),
+		"estado":                cliente.ObtenerEstadoCircuitBreaker().String(),
+		"operacional":           cliente.EsSRIOperacional(),
+		"estadisticas":          cliente.ObtenerEstadisticasCircuitBreaker(),
+		"limitador":             cliente.ObtenerMetricasLimitador(),
+		"endpoint_recepcion":    recepcion,
+		"endpoint_autorizacion": autorizacion,
+	}
+}
+
+// EstadoCircuitBreakerSRI muestra el circuit breaker de los clientes SRI (todos o ?ambiente=)
+func (s *Server) EstadoCircuitBreakerSRI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ambientes := []sri.Ambiente{sri.Pruebas, sri.Produccion}
+	if r.URL.Query().Get("ambiente") != "" {
+		ambiente, err := ambienteSolicitado(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		ambientes = []sri.Ambiente{ambiente}
+	}
+
+	clientes := make([]map[string]interface{}, 0, len(ambientes))
+	for _, ambiente := range ambientes {
+		clientes = append(clientes, estadoClienteSRI(ambiente, s.ClienteSRI(ambiente)))
+	}
+
+	response := map[string]interface{}{
+		"success":              true,
+		"ambiente_configurado": nombreAmbiente(sri.AmbienteConfigurado()),
+		"data":                 clientes,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// ReiniciarCircuitBreakerSRI cierra el circuit breaker del ambiente indicado
+func (s *Server) ReiniciarCircuitBreakerSRI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ambiente, err := ambienteSolicitado(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	cliente := s.ClienteSRI(ambiente)
+	cliente.ReiniciarCircuitBreaker()
+
+	response := map[string]interface{}{
+		"success": true,
+		"message": fmt.Sprintf("Circuit breaker de %s reiniciado", nombreAmbiente(ambiente)),
+		"data":    estadoClienteSRI(ambiente, cliente),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
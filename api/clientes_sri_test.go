@@ -0,0 +1,108 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-facturacion-sri/sri"
+)
+
+// TestClienteSRICompartido verifica que las peticiones reutilicen el mismo cliente por ambiente
+func TestClienteSRICompartido(t *testing.T) {
+	setUp()
+	server := NewServer("8080", nil, nil)
+
+	if server.ClienteSRI(sri.Pruebas) != server.ClienteSRI(sri.Pruebas) {
+		t.Error("ClienteSRI() debería retornar el mismo cliente para el mismo ambiente")
+	}
+	if server.ClienteSRI(sri.Pruebas) == server.ClienteSRI(sri.Produccion) {
+		t.Error("Cada ambiente debería tener su propio cliente")
+	}
+}
+
+// TestEstadoCircuitBreakerSRI verifica que las estadísticas se acumulen entre peticiones
+func TestEstadoCircuitBreakerSRI(t *testing.T) {
+	setUp()
+	server := NewServer("8080", nil, nil)
+
+	simulador := sri.NuevoSimuladorSRI(sri.Pruebas)
+	if err := simulador.Iniciar(); err != nil {
+		t.Fatalf("Error iniciando simulador: %v", err)
+	}
+	defer simulador.Detener()
+	server.ConfigurarClienteSRI(sri.Pruebas, simulador.NuevoCliente())
+
+	clave := "2501202401179214673900110010010000000011234567893"
+	for i := 0; i < 2; i++ {
+		if _, err := server.ClienteSRI(sri.Pruebas).ConsultarAutorizacion(clave); err != nil {
+			t.Fatalf("ConsultarAutorizacion() error: %v", err)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/sri/circuit-breaker?ambiente=pruebas", nil)
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Status = %d, esperado 200: %s", w.Code, w.Body.String())
+	}
+
+	var response struct {
+		Success bool `json:"success"`
+		Data    []struct {
+			Ambiente          string                         `json:"ambiente"`
+			Estado            string                         `json:"estado"`
+			EndpointRecepcion string                         `json:"endpoint_recepcion"`
+			Estadisticas      sri.EstadisticasCircuitBreaker `json:"estadisticas"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Error decodificando respuesta: %v", err)
+	}
+	if !response.Success || len(response.Data) != 1 {
+		t.Fatalf("Respuesta inesperada: %+v", response)
+	}
+
+	estado := response.Data[0]
+	if estado.Ambiente != "PRUEBAS" || estado.EndpointRecepcion != simulador.EndpointRecepcion() {
+		t.Errorf("Cliente inesperado: %+v", estado)
+	}
+	if estado.Estadisticas.TotalPeticiones != 2 {
+		t.Errorf("TotalPeticiones = %d, esperado 2", estado.Estadisticas.TotalPeticiones)
+	}
+}
+
+// TestReiniciarCircuitBreakerSRI verifica el reinicio y la validación del ambiente
+func TestReiniciarCircuitBreakerSRI(t *testing.T) {
+	setUp()
+	server := NewServer("8080", nil, nil)
+
+	tests := []struct {
+		name   string
+		method string
+		url    string
+		status int
+	}{
+		{"reiniciar pruebas", http.MethodPost, "/api/admin/sri/circuit-breaker/reiniciar?ambiente=1", http.StatusOK},
+		{"ambiente inválido", http.MethodPost, "/api/admin/sri/circuit-breaker/reiniciar?ambiente=3", http.StatusBadRequest},
+		{"método no permitido", http.MethodGet, "/api/admin/sri/circuit-breaker/reiniciar", http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.url, nil)
+			w := httptest.NewRecorder()
+			server.router.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Errorf("Status = %d, esperado %d: %s", w.Code, tt.status, w.Body.String())
+			}
+		})
+	}
+
+	if estado := server.ClienteSRI(sri.Pruebas).ObtenerEstadoCircuitBreaker(); estado != sri.EstadoCerrado {
+		t.Errorf("Estado tras reiniciar = %v, esperado CERRADO", estado)
+	}
+}
@@ -0,0 +1,167 @@
+// Package api expone la cola de fallidos (dead-letter queue) de envíos al SRI
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go-facturacion-sri/pipeline"
+)
+
+// handleFallidos maneja las rutas dinámicas de la cola de fallidos
+func (s *Server) handleFallidos(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/reencolar") {
+		s.ReencolarFallidoDB(w, r)
+	} else if r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/descartar") {
+		s.DescartarFallidoDB(w, r)
+	} else if r.Method == http.MethodGet {
+		s.ObtenerFallidoDB(w, r)
+	} else {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Método no permitido")
+	}
+}
+
+// idFallido extrae el ID de /api/pipeline/fallidos/{id}[/accion]
+func idFallido(path string) (int, error) {
+	partes := strings.Split(strings.TrimPrefix(path, "/api/pipeline/fallidos/"), "/")
+	return strconv.Atoi(partes[0])
+}
+
+// ListarFallidosDB lista la cola de fallidos, opcionalmente filtrada por estado
+func (s *Server) ListarFallidosDB(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Parámetros de filtro y paginación
+	estado := strings.ToUpper(r.URL.Query().Get("estado"))
+	limit := 50
+	offset := 0
+
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 {
+		limit = l
+	}
+	if o, err := strconv.Atoi(r.URL.Query().Get("offset")); err == nil && o >= 0 {
+		offset = o
+	}
+
+	fallidos, err := s.db.ListarFallidos(estado, limit, offset)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error listando fallidos: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"success": true,
+		"data": map[string]interface{}{
+			"fallidos": fallidos,
+			"count":    len(fallidos),
+			"limit":    limit,
+			"offset":   offset,
+		},
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// ObtenerFallidoDB retorna un fallido con el error clasificado de cada intento y el XML del comprobante
+func (s *Server) ObtenerFallidoDB(w http.ResponseWriter, r *http.Request) {
+	id, err := idFallido(r.URL.Path)
+	if err != nil {
+		http.Error(w, "ID de fallido inválido", http.StatusBadRequest)
+		return
+	}
+
+	fallido, err := s.db.ObtenerFallido(id)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Fallido no encontrado: %v", err), http.StatusNotFound)
+		return
+	}
+
+	response := map[string]interface{}{
+		"success": true,
+		"data": map[string]interface{}{
+			"fallido":         fallido,
+			"xml_comprobante": fallido.XMLComprobante,
+		},
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// ReencolarFallidoDB devuelve un fallido a la cola de autorización
+func (s *Server) ReencolarFallidoDB(w http.ResponseWriter, r *http.Request) {
+	if s.pipeline == nil {
+		http.Error(w, "Pipeline de autorización no habilitado", http.StatusServiceUnavailable)
+		return
+	}
+
+	id, err := idFallido(r.URL.Path)
+	if err != nil {
+		http.Error(w, "ID de fallido inválido", http.StatusBadRequest)
+		return
+	}
+
+	trabajo, err := s.pipeline.ReencolarFallido(id)
+	if errors.Is(err, pipeline.ErrFallidoNoReencolable) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error reencolando fallido: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"success": true,
+		"message": "Fallido reencolado para autorización",
+		"data":    trabajo,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(response)
+}
+
+// DescartarFallidoDB marca un fallido como descartado; acepta {"motivo": "..."} opcional
+func (s *Server) DescartarFallidoDB(w http.ResponseWriter, r *http.Request) {
+	id, err := idFallido(r.URL.Path)
+	if err != nil {
+		http.Error(w, "ID de fallido inválido", http.StatusBadRequest)
+		return
+	}
+
+	var request struct {
+		Motivo string `json:"motivo"`
+	}
+	if r.ContentLength > 0 {
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, "JSON inválido", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if _, err := s.db.ObtenerFallido(id); err != nil {
+		http.Error(w, fmt.Sprintf("Fallido no encontrado: %v", err), http.StatusNotFound)
+		return
+	}
+	if err := s.db.DescartarFallido(id, request.Motivo); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	response := map[string]interface{}{
+		"success": true,
+		"message": fmt.Sprintf("Fallido %d descartado", id),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -2,10 +2,14 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
 	"go-facturacion-sri/database"
@@ -29,45 +33,58 @@ func (s *Server) CrearFacturaConDB(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Crear factura
-	factura, err := factory.CrearFactura(input)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Error creando factura: %v", err), http.StatusBadRequest)
-		return
+	// Completar productos referenciados por código con precio e impuestos del catálogo
+	if s.catalogo != nil {
+		productos, err := s.catalogo.CompletarDesdeCatalogo(r.Context(), input.Productos)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Error resolviendo productos del catálogo: %v", err), http.StatusBadRequest)
+			return
+		}
+		input.Productos = productos
 	}
 
-	// Generar clave de acceso
-	claveConfig := sri.ClaveAccesoConfig{
-		FechaEmision:     time.Now(),
-		TipoComprobante:  sri.Factura,
-		RUCEmisor:        "1792146739001", // TODO: Obtener de configuración
-		Ambiente:         sri.Pruebas,
-		Serie:            "001001",
-		NumeroSecuencial: "000000001", // TODO: Generar secuencial automático
-		TipoEmision:      sri.EmisionNormal,
+	// Con una credencial de tenant la factura se emite con su RUC, establecimiento y secuencial;
+	// sin ella, con los del emisor de la configuración
+	var emisor factory.Emisor
+	var err error
+	if tenantID, _ := database.TenantDe(r.Context()); tenantID != database.TenantPredeterminado && s.tenants != nil {
+		emisor, err = s.emisorTenant(r, tenantID)
+	} else {
+		emisor, err = s.emisorConfig(r.Context())
+	}
+	if errors.Is(err, database.ErrEstablecimientoNoEncontrado) {
+		http.Error(w, fmt.Sprintf("Error reservando secuencial: %v", err), http.StatusBadRequest)
+		return
 	}
-
-	claveAcceso, err := sri.GenerarClaveAcceso(claveConfig)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error generando clave de acceso: %v", err), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("Error preparando emisor: %v", err), http.StatusInternalServerError)
 		return
 	}
 
-	// Conectar a base de datos
-	db, err := database.New("database/facturacion.db")
+	// Crear factura con la clave de acceso del secuencial reservado
+	factura, err := factory.CrearFacturaParaEmisor(input, emisor)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error conectando a base de datos: %v", err), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("Error creando factura: %v", err), http.StatusBadRequest)
 		return
 	}
-	defer db.Close()
 
 	// Guardar en base de datos
-	facturaDB, err := db.GuardarFactura(factura, claveAcceso, input.Productos)
+	facturaDB, err := s.facturas.GuardarFactura(r.Context(), factura, emisor.ClaveAcceso, input.Productos)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error guardando factura: %v", err), http.StatusInternalServerError)
 		return
 	}
 
+	// Encolar para firma y autorización si el pipeline está activo
+	var trabajo *database.TrabajoAutorizacionDB
+	if s.pipeline != nil {
+		trabajo, err = s.pipeline.Encolar(facturaDB.ID)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Error encolando factura para autorización: %v", err), http.StatusInternalServerError)
+			return
+		}
+	}
+
 	// Respuesta
 	response := map[string]interface{}{
 		"success": true,
@@ -88,6 +105,9 @@ func (s *Server) CrearFacturaConDB(w http.ResponseWriter, r *http.Request) {
 	if includeXML {
 		response["data"].(map[string]interface{})["xml"] = facturaDB.XMLOriginal
 	}
+	if trabajo != nil {
+		response["data"].(map[string]interface{})["trabajo_autorizacion"] = trabajo
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
@@ -100,35 +120,18 @@ func (s *Server) ListarFacturasDB(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Parámetros de paginación
-	limitStr := r.URL.Query().Get("limit")
-	offsetStr := r.URL.Query().Get("offset")
-
-	limit := 10 // Por defecto
-	offset := 0 // Por defecto
-
-	if limitStr != "" {
-		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
-			limit = l
-		}
-	}
-
-	if offsetStr != "" {
-		if o, err := strconv.Atoi(offsetStr); err == nil && o >= 0 {
-			offset = o
-		}
-	}
-
-	// Conectar a base de datos
-	db, err := database.New("database/facturacion.db")
+	filtro, err := filtroFacturasDesdeQuery(r.URL.Query())
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error conectando a base de datos: %v", err), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	defer db.Close()
 
 	// Obtener facturas
-	facturas, err := db.ListarFacturas(limit, offset)
+	facturas, total, err := s.facturas.BuscarFacturas(r.Context(), filtro)
+	if errors.Is(err, database.ErrFiltroFacturasInvalido) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error listando facturas: %v", err), http.StatusInternalServerError)
 		return
@@ -140,8 +143,9 @@ func (s *Server) ListarFacturasDB(w http.ResponseWriter, r *http.Request) {
 		"data": map[string]interface{}{
 			"facturas": facturas,
 			"count":    len(facturas),
-			"limit":    limit,
-			"offset":   offset,
+			"total":    total,
+			"limit":    filtro.Limite,
+			"offset":   filtro.Offset,
 		},
 	}
 
@@ -149,6 +153,63 @@ func (s *Server) ListarFacturasDB(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// filtroFacturasDesdeQuery arma el filtro de /api/facturas/db/list a partir de los parámetros:
+// desde, hasta, estado (lista separada por comas), ambiente, totalMin, totalMax, establecimiento,
+// numero y clave (prefijos), cedula, ordenarPor, orden (asc|desc), limit y offset
+func filtroFacturasDesdeQuery(query url.Values) (database.FiltroFacturas, error) {
+	filtro := database.FiltroFacturas{
+		Ambiente:        strings.TrimSpace(query.Get("ambiente")),
+		Establecimiento: strings.TrimSpace(query.Get("establecimiento")),
+		PrefijoNumero:   strings.TrimSpace(query.Get("numero")),
+		PrefijoClave:    strings.TrimSpace(query.Get("clave")),
+		ClienteCedula:   strings.TrimSpace(query.Get("cedula")),
+		OrdenarPor:      strings.TrimSpace(query.Get("ordenarPor")),
+		Limite:          10, // Por defecto
+	}
+
+	var err error
+	if filtro.Desde, err = parsearFechaFiltro(query.Get("desde"), false); err != nil {
+		return filtro, err
+	}
+	if filtro.Hasta, err = parsearFechaFiltro(query.Get("hasta"), true); err != nil {
+		return filtro, err
+	}
+
+	if estados := strings.TrimSpace(query.Get("estado")); estados != "" {
+		for _, estado := range strings.Split(estados, ",") {
+			filtro.Estados = append(filtro.Estados, strings.ToUpper(strings.TrimSpace(estado)))
+		}
+	}
+
+	for parametro, destino := range map[string]**float64{"totalMin": &filtro.TotalMinimo, "totalMax": &filtro.TotalMaximo} {
+		valor := query.Get(parametro)
+		if valor == "" {
+			continue
+		}
+		total, err := strconv.ParseFloat(valor, 64)
+		if err != nil {
+			return filtro, fmt.Errorf("%s inválido: %q", parametro, valor)
+		}
+		*destino = &total
+	}
+
+	switch strings.ToLower(query.Get("orden")) {
+	case "", "desc":
+	case "asc":
+		filtro.Ascendente = true
+	default:
+		return filtro, fmt.Errorf("orden inválido: %q (use asc o desc)", query.Get("orden"))
+	}
+
+	if l, err := strconv.Atoi(query.Get("limit")); err == nil && l > 0 {
+		filtro.Limite = l
+	}
+	if o, err := strconv.Atoi(query.Get("offset")); err == nil && o >= 0 {
+		filtro.Offset = o
+	}
+	return filtro, nil
+}
+
 // ObtenerFacturaDB obtiene una factura específica por ID
 func (s *Server) ObtenerFacturaDB(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -169,23 +230,15 @@ func (s *Server) ObtenerFacturaDB(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Conectar a base de datos
-	db, err := database.New("database/facturacion.db")
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Error conectando a base de datos: %v", err), http.StatusInternalServerError)
-		return
-	}
-	defer db.Close()
-
 	// Obtener factura
-	factura, err := db.ObtenerFacturaPorID(id)
+	factura, err := s.facturas.ObtenerFacturaPorID(r.Context(), id)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error obteniendo factura: %v", err), http.StatusNotFound)
 		return
 	}
 
 	// Obtener productos asociados
-	productos, err := db.ObtenerProductosPorFactura(id)
+	productos, err := s.facturas.ObtenerProductosPorFactura(r.Context(), id)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error obteniendo productos: %v", err), http.StatusInternalServerError)
 		return
@@ -214,7 +267,51 @@ func (s *Server) ObtenerFacturaDB(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
-// ActualizarEstadoFacturaDB actualiza el estado de una factura
+// ObtenerHistorialEstadosFacturaDB lista las transiciones de estado de una factura
+func (s *Server) ObtenerHistorialEstadosFacturaDB(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Obtener ID de la URL
+	idStr := r.URL.Path[len("/api/facturas/db/"):]
+	idStr = idStr[:len(idStr)-len("/historial")] // Remover "/historial" del final
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "ID de factura inválido", http.StatusBadRequest)
+		return
+	}
+
+	factura, err := s.facturas.ObtenerFacturaPorID(r.Context(), id)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Factura no encontrada: %v", err), http.StatusNotFound)
+		return
+	}
+
+	historial, err := s.facturas.ObtenerHistorialEstados(r.Context(), id)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error obteniendo historial: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Respuesta
+	response := map[string]interface{}{
+		"success": true,
+		"data": map[string]interface{}{
+			"estado":                  factura.Estado,
+			"transiciones_permitidas": database.TransicionesDesde(factura.Estado),
+			"historial":               historial,
+		},
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// ActualizarEstadoFacturaDB aplica un cambio de estado manual (reabrir en BORRADOR o anular).
+// Los estados del SRI y el número de autorización solo los asigna el pipeline.
 func (s *Server) ActualizarEstadoFacturaDB(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPut {
 		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
@@ -237,30 +334,43 @@ func (s *Server) ActualizarEstadoFacturaDB(w http.ResponseWriter, r *http.Reques
 		NumeroAutorizacion string `json:"numero_autorizacion"`
 		XMLAutorizado      string `json:"xml_autorizado"`
 		ObservacionesSRI   string `json:"observaciones_sri"`
+		Actor              string `json:"actor"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
 		http.Error(w, fmt.Sprintf("Error parseando JSON: %v", err), http.StatusBadRequest)
 		return
 	}
-
-	// Conectar a base de datos
-	db, err := database.New("database/facturacion.db")
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Error conectando a base de datos: %v", err), http.StatusInternalServerError)
+	if input.NumeroAutorizacion != "" || input.XMLAutorizado != "" {
+		http.Error(w, "numero_autorizacion y xml_autorizado los asigna el SRI a través del pipeline", http.StatusBadRequest)
+		return
+	}
+	if !database.EsEstadoManual(input.Estado) {
+		http.Error(w, fmt.Sprintf("Error actualizando estado: %v: %s solo lo asigna el pipeline de autorización",
+			database.ErrTransicionInvalida, input.Estado), http.StatusConflict)
 		return
 	}
-	defer db.Close()
+	if input.Actor == "" {
+		input.Actor = "api"
+	}
 
-	// Actualizar estado
-	err = db.ActualizarEstadoFactura(id, input.Estado, input.NumeroAutorizacion, input.XMLAutorizado, input.ObservacionesSRI)
+	// Actualizar estado validando la transición
+	err = s.facturas.TransicionarEstadoFactura(r.Context(), id, database.CambioEstadoFactura{
+		Estado:        input.Estado,
+		Observaciones: input.ObservacionesSRI,
+		Actor:         input.Actor,
+	})
+	if errors.Is(err, database.ErrTransicionInvalida) {
+		http.Error(w, fmt.Sprintf("Error actualizando estado: %v", err), http.StatusConflict)
+		return
+	}
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error actualizando estado: %v", err), http.StatusInternalServerError)
 		return
 	}
 
 	// Obtener factura actualizada
-	factura, err := db.ObtenerFacturaPorID(id)
+	factura, err := s.facturas.ObtenerFacturaPorID(r.Context(), id)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error obteniendo factura actualizada: %v", err), http.StatusInternalServerError)
 		return
@@ -284,16 +394,8 @@ func (s *Server) EstadisticasDB(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Conectar a base de datos
-	db, err := database.New("database/facturacion.db")
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Error conectando a base de datos: %v", err), http.StatusInternalServerError)
-		return
-	}
-	defer db.Close()
-
 	// Obtener estadísticas
-	estadisticas, err := db.EstadisticasFacturas()
+	estadisticas, err := s.facturas.EstadisticasFacturas(r.Context())
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error obteniendo estadísticas: %v", err), http.StatusInternalServerError)
 		return
@@ -323,16 +425,8 @@ func (s *Server) GuardarClienteDB(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Conectar a base de datos
-	db, err := database.New("database/facturacion.db")
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Error conectando a base de datos: %v", err), http.StatusInternalServerError)
-		return
-	}
-	defer db.Close()
-
 	// Guardar cliente
-	cliente, err := db.GuardarCliente(&input)
+	cliente, err := s.clientes.GuardarCliente(r.Context(), &input)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error guardando cliente: %v", err), http.StatusInternalServerError)
 		return
@@ -363,16 +457,8 @@ func (s *Server) BuscarClienteDB(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Conectar a base de datos
-	db, err := database.New("database/facturacion.db")
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Error conectando a base de datos: %v", err), http.StatusInternalServerError)
-		return
-	}
-	defer db.Close()
-
 	// Buscar cliente
-	cliente, err := db.ObtenerClientePorCedula(cedula)
+	cliente, err := s.clientes.ObtenerClientePorCedula(r.Context(), cedula)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Cliente no encontrado: %v", err), http.StatusNotFound)
 		return
@@ -416,16 +502,8 @@ func (s *Server) ListarClientesDB(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Conectar a base de datos
-	db, err := database.New("database/facturacion.db")
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Error conectando a base de datos: %v", err), http.StatusInternalServerError)
-		return
-	}
-	defer db.Close()
-
 	// Listar clientes
-	clientes, err := db.ListarClientes(nombre, tipoCliente, limit, offset)
+	clientes, err := s.clientes.ListarClientes(r.Context(), nombre, tipoCliente, limit, offset)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error listando clientes: %v", err), http.StatusInternalServerError)
 		return
@@ -459,11 +537,17 @@ func (s *Server) ConsultarEstadoSRI(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Crear cliente SRI
-	sriClient := sri.NewSOAPClient(sri.Pruebas)
+	ambiente, err := ambienteSolicitado(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	// Consultar autorización
-	respuesta, err := sriClient.ConsultarAutorizacion(claveAcceso)
+	// Cliente SRI compartido del ambiente
+	sriClient := s.ClienteSRI(ambiente)
+
+	// Consultar autorización (se cancela si el cliente HTTP abandona la petición)
+	respuesta, err := sriClient.ConsultarAutorizacionContexto(r.Context(), claveAcceso)
 	if err != nil {
 		// Respuesta con error, pero no falla el endpoint
 		response := map[string]interface{}{
@@ -492,6 +576,7 @@ func (s *Server) ConsultarEstadoSRI(w http.ResponseWriter, r *http.Request) {
 		
 		if len(auth.Mensajes) > 0 {
 			response["mensajes"] = auth.Mensajes
+			response["errores"] = auth.ErroresSRI()
 		}
 	}
 
@@ -506,8 +591,14 @@ func (s *Server) EstadoGeneralSRI(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Crear cliente SRI
-	sriClient := sri.NewSOAPClient(sri.Pruebas)
+	ambiente, err := ambienteSolicitado(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Cliente SRI compartido del ambiente
+	sriClient := s.ClienteSRI(ambiente)
 
 	// Intentar una consulta simple para verificar conectividad
 	// Usamos una clave de acceso de prueba conocida
@@ -516,33 +607,54 @@ func (s *Server) EstadoGeneralSRI(w http.ResponseWriter, r *http.Request) {
 	response := map[string]interface{}{
 		"disponible": false,
 		"mensaje":    "Verificando estado...",
-		"ambiente":   "PRUEBAS",
+		"ambiente":   nombreAmbiente(ambiente),
 		"timestamp":  time.Now().Format(time.RFC3339),
 	}
 
-	// Intentar consulta con timeout
-	done := make(chan bool, 1)
-	var sriError error
-
-	go func() {
-		_, err := sriClient.ConsultarAutorizacion(claveTestPruebas)
-		sriError = err
-		done <- true
-	}()
-
-	// Timeout de 5 segundos
-	select {
-	case <-done:
-		if sriError != nil {
-			response["disponible"] = false
-			response["mensaje"] = fmt.Sprintf("Servicio no disponible: %v", sriError)
-		} else {
-			response["disponible"] = true
-			response["mensaje"] = "Servicio SRI operativo"
-		}
-	case <-time.After(5 * time.Second):
+	// Intentar consulta con timeout de 5 segundos ligado a la petición
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	_, sriError := sriClient.ConsultarAutorizacionContexto(ctx, claveTestPruebas)
+	switch {
+	case errors.Is(sriError, context.DeadlineExceeded):
 		response["disponible"] = false
 		response["mensaje"] = "Timeout en la consulta al SRI"
+	case sriError != nil:
+		response["disponible"] = false
+		response["mensaje"] = fmt.Sprintf("Servicio no disponible: %v", sriError)
+	default:
+		response["disponible"] = true
+		response["mensaje"] = "Servicio SRI operativo"
+	}
+	response["circuit_breaker"] = sriClient.ObtenerEstadoCircuitBreaker().String()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// CatalogoErroresSRIHandler lista los identificadores de error del SRI o retorna uno con ?codigo=XX
+func (s *Server) CatalogoErroresSRIHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var data interface{}
+	if codigo := r.URL.Query().Get("codigo"); codigo != "" {
+		errorSRI, ok := sri.BuscarErrorSRI(codigo)
+		if !ok {
+			http.Error(w, fmt.Sprintf("Código de error SRI desconocido: %s", codigo), http.StatusNotFound)
+			return
+		}
+		data = errorSRI
+	} else {
+		data = sri.CatalogoErroresSRI()
+	}
+
+	response := map[string]interface{}{
+		"success": true,
+		"data":    data,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -577,15 +689,8 @@ func (s *Server) ObtenerAuditoriaDB(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Conectar a base de datos
-	db, err := database.New("database/facturacion.db")
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Error conectando a base de datos: %v", err), http.StatusInternalServerError)
-		return
-	}
-	defer db.Close()
-
 	var registros []*database.AuditLogDB
+	var err error
 
 	// Determinar tipo de consulta
 	if registroIDStr != "" && tabla != "" {
@@ -596,14 +701,14 @@ func (s *Server) ObtenerAuditoriaDB(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		
-		registros, err = db.ObtenerAuditoriaPorRegistro(tabla, registroID)
+		registros, err = s.db.ObtenerAuditoriaPorRegistro(r.Context(), tabla, registroID)
 		if err != nil {
 			http.Error(w, fmt.Sprintf("Error obteniendo auditoría: %v", err), http.StatusInternalServerError)
 			return
 		}
 	} else if tabla != "" {
 		// Consulta por tabla
-		registros, err = db.ObtenerAuditoriaPorTabla(tabla, limit, offset)
+		registros, err = s.db.ObtenerAuditoriaPorTabla(r.Context(), tabla, limit, offset)
 		if err != nil {
 			http.Error(w, fmt.Sprintf("Error obteniendo auditoría: %v", err), http.StatusInternalServerError)
 			return
@@ -652,19 +757,11 @@ func (s *Server) CrearRespaldoDB(w http.ResponseWriter, r *http.Request) {
 		input.Sufijo = "api_request"
 	}
 
-	// Conectar a base de datos
-	db, err := database.New("database/facturacion.db")
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Error conectando a base de datos: %v", err), http.StatusInternalServerError)
-		return
-	}
-	defer db.Close()
-
 	// Crear gestor de respaldos
-	backupManager := database.NewBackupManagerDefault(db)
+	backupManager := database.NewBackupManagerDefault(s.db)
 
 	// Crear respaldo manual
-	err = backupManager.CrearRespaldoManual(input.Sufijo)
+	err := backupManager.CrearRespaldoManual(input.Sufijo)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error creando respaldo: %v", err), http.StatusInternalServerError)
 		return
@@ -688,16 +785,8 @@ func (s *Server) ListarRespaldosDB(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Conectar a base de datos
-	db, err := database.New("database/facturacion.db")
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Error conectando a base de datos: %v", err), http.StatusInternalServerError)
-		return
-	}
-	defer db.Close()
-
 	// Crear gestor de respaldos
-	backupManager := database.NewBackupManagerDefault(db)
+	backupManager := database.NewBackupManagerDefault(s.db)
 
 	// Listar respaldos
 	respaldos, err := backupManager.ListarRespaldos()
@@ -739,16 +828,8 @@ func (s *Server) ObtenerClienteDB(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Conectar a base de datos
-	db, err := database.New("database/facturacion.db")
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Error conectando a base de datos: %v", err), http.StatusInternalServerError)
-		return
-	}
-	defer db.Close()
-
 	// Obtener cliente
-	cliente, err := db.ObtenerClientePorID(id)
+	cliente, err := s.clientes.ObtenerClientePorID(r.Context(), id)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Cliente no encontrado: %v", err), http.StatusNotFound)
 		return
@@ -791,16 +872,8 @@ func (s *Server) ActualizarClienteDB(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Conectar a base de datos
-	db, err := database.New("database/facturacion.db")
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Error conectando a base de datos: %v", err), http.StatusInternalServerError)
-		return
-	}
-	defer db.Close()
-
 	// Verificar que el cliente existe
-	_, err = db.ObtenerClientePorID(id)
+	_, err = s.clientes.ObtenerClientePorID(r.Context(), id)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Cliente no encontrado: %v", err), http.StatusNotFound)
 		return
@@ -808,7 +881,7 @@ func (s *Server) ActualizarClienteDB(w http.ResponseWriter, r *http.Request) {
 
 	// Actualizar cliente (establecer ID para update)
 	input.ID = id
-	cliente, err := db.ActualizarCliente(&input)
+	cliente, err := s.clientes.ActualizarCliente(r.Context(), &input)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error actualizando cliente: %v", err), http.StatusInternalServerError)
 		return
@@ -845,27 +918,19 @@ func (s *Server) EliminarClienteDB(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Conectar a base de datos
-	db, err := database.New("database/facturacion.db")
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Error conectando a base de datos: %v", err), http.StatusInternalServerError)
-		return
-	}
-	defer db.Close()
-
 	// Verificar que el cliente existe
-	cliente, err := db.ObtenerClientePorID(id)
+	cliente, err := s.clientes.ObtenerClientePorID(r.Context(), id)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Cliente no encontrado: %v", err), http.StatusNotFound)
 		return
 	}
 
 	// Verificar si el cliente tiene facturas asociadas
-	facturas, err := db.ListarFacturasPorCliente(cliente.Cedula, 1, 0)
+	facturas, err := s.facturas.ListarFacturasPorCliente(r.Context(), cliente.Cedula, 1, 0)
 	if err == nil && len(facturas) > 0 {
 		// Cliente tiene facturas, no se puede eliminar completamente
 		// En su lugar, marcamos como inactivo
-		err = db.DesactivarCliente(id)
+		err = s.clientes.DesactivarCliente(r.Context(), id)
 		if err != nil {
 			http.Error(w, fmt.Sprintf("Error desactivando cliente: %v", err), http.StatusInternalServerError)
 			return
@@ -883,7 +948,7 @@ func (s *Server) EliminarClienteDB(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// El cliente no tiene facturas, se puede eliminar
-	err = db.EliminarCliente(id)
+	err = s.clientes.EliminarCliente(r.Context(), id)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error eliminando cliente: %v", err), http.StatusInternalServerError)
 		return
@@ -933,16 +998,8 @@ func (s *Server) ActualizarFacturaDB(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Conectar a base de datos
-	db, err := database.New("database/facturacion.db")
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Error conectando a base de datos: %v", err), http.StatusInternalServerError)
-		return
-	}
-	defer db.Close()
-
 	// Verificar que la factura existe y está en estado BORRADOR
-	factura, err := db.ObtenerFacturaPorID(id)
+	factura, err := s.facturas.ObtenerFacturaPorID(r.Context(), id)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Factura no encontrada: %v", err), http.StatusNotFound)
 		return
@@ -954,7 +1011,7 @@ func (s *Server) ActualizarFacturaDB(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Actualizar factura
-	facturaActualizada, err := db.ActualizarFactura(id, input.ClienteCedula, input.ClienteNombre, input.Productos, input.Observaciones)
+	facturaActualizada, err := s.facturas.ActualizarFactura(r.Context(), id, input.ClienteCedula, input.ClienteNombre, input.Productos, input.Observaciones)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error actualizando factura: %v", err), http.StatusInternalServerError)
 		return
@@ -991,16 +1048,8 @@ func (s *Server) EliminarFacturaDB(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Conectar a base de datos
-	db, err := database.New("database/facturacion.db")
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Error conectando a base de datos: %v", err), http.StatusInternalServerError)
-		return
-	}
-	defer db.Close()
-
 	// Obtener factura
-	factura, err := db.ObtenerFacturaPorID(id)
+	factura, err := s.facturas.ObtenerFacturaPorID(r.Context(), id)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Factura no encontrada: %v", err), http.StatusNotFound)
 		return
@@ -1011,29 +1060,13 @@ func (s *Server) EliminarFacturaDB(w http.ResponseWriter, r *http.Request) {
 
 	// Determinar acción según el estado
 	switch factura.Estado {
-	case "BORRADOR":
-		// Se puede eliminar completamente
-		err = db.EliminarFactura(id)
-		if err != nil {
-			http.Error(w, fmt.Sprintf("Error eliminando factura: %v", err), http.StatusInternalServerError)
-			return
-		}
-		mensaje = "Factura eliminada exitosamente"
-		action = "eliminada"
-
-	case "ENVIADA", "AUTORIZADA":
-		// No se puede eliminar, solo anular
-		err = db.ActualizarEstadoFactura(id, "ANULADA", "", "", "Anulada por solicitud del usuario")
-		if err != nil {
-			http.Error(w, fmt.Sprintf("Error anulando factura: %v", err), http.StatusInternalServerError)
+	case database.EstadoBorrador:
+		// Solo los borradores se eliminan físicamente
+		err = s.facturas.EliminarFactura(r.Context(), id)
+		if errors.Is(err, database.ErrFacturaNoEliminable) {
+			http.Error(w, err.Error(), http.StatusConflict)
 			return
 		}
-		mensaje = "Factura anulada exitosamente"
-		action = "anulada"
-
-	case "RECHAZADA":
-		// Se puede eliminar
-		err = db.EliminarFactura(id)
 		if err != nil {
 			http.Error(w, fmt.Sprintf("Error eliminando factura: %v", err), http.StatusInternalServerError)
 			return
@@ -1041,6 +1074,24 @@ func (s *Server) EliminarFacturaDB(w http.ResponseWriter, r *http.Request) {
 		mensaje = "Factura eliminada exitosamente"
 		action = "eliminada"
 
+	case database.EstadoAutorizada:
+		// Los comprobantes autorizados se conservan y se anulan en el portal del SRI
+		http.Error(w, fmt.Sprintf("La factura está AUTORIZADA y no puede eliminarse; solicite su anulación en POST /api/facturas/db/%d/anulacion", id), http.StatusConflict)
+		return
+
+	case database.EstadoFirmada, database.EstadoDevuelta, database.EstadoNoAutorizada, database.EstadoRechazada:
+		http.Error(w, fmt.Sprintf("La factura está %s; regrésela a BORRADOR para eliminarla o cambie su estado a ANULADA", factura.Estado), http.StatusConflict)
+		return
+
+	case database.EstadoAnulada:
+		http.Error(w, "La factura ya está anulada y debe conservarse", http.StatusConflict)
+		return
+
+	case database.EstadoEnviada, database.EstadoRecibida, database.EstadoEnProceso:
+		// En trámite con el SRI: esperar la respuesta antes de eliminar o anular
+		http.Error(w, fmt.Sprintf("La factura está %s en el SRI y no puede eliminarse", factura.Estado), http.StatusConflict)
+		return
+
 	default:
 		http.Error(w, "Estado de factura no válido para eliminación", http.StatusBadRequest)
 		return
@@ -1074,19 +1125,11 @@ func (s *Server) GenerarPDFFacturaDB(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Conectar a base de datos
-	db, err := database.New("database/facturacion.db")
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Error conectando a base de datos: %v", err), http.StatusInternalServerError)
-		return
-	}
-	defer db.Close()
-
 	// Crear generador de PDF
-	pdfGenerator := pdf.NewFacturaPDFGenerator(db)
+	pdfGenerator := pdf.NewFacturaPDFGenerator(s.db)
 
 	// Validar que la factura puede generar PDF
-	err = pdfGenerator.ValidarFacturaParaPDF(id)
+	err = pdfGenerator.ValidarFacturaParaPDF(r.Context(), id)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error validando factura para PDF: %v", err), http.StatusBadRequest)
 		return
@@ -1097,9 +1140,9 @@ func (s *Server) GenerarPDFFacturaDB(w http.ResponseWriter, r *http.Request) {
 
 	var pdfBytes []byte
 	if simple {
-		pdfBytes, err = pdfGenerator.GenerarFacturaSimplePDF(id)
+		pdfBytes, err = pdfGenerator.GenerarFacturaSimplePDF(r.Context(), id)
 	} else {
-		pdfBytes, err = pdfGenerator.GenerarFacturaPDF(id)
+		pdfBytes, err = pdfGenerator.GenerarFacturaPDF(r.Context(), id)
 	}
 
 	if err != nil {
@@ -1108,7 +1151,7 @@ func (s *Server) GenerarPDFFacturaDB(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Obtener información de la factura para el nombre del archivo
-	factura, err := db.ObtenerFacturaPorID(id)
+	factura, err := s.facturas.ObtenerFacturaPorID(r.Context(), id)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error obteniendo factura: %v", err), http.StatusInternalServerError)
 		return
@@ -1123,3 +1166,131 @@ func (s *Server) GenerarPDFFacturaDB(w http.ResponseWriter, r *http.Request) {
 	// Escribir PDF
 	w.Write(pdfBytes)
 }
+
+// DescargarXMLAutorizadoDB descarga el comprobante autorizado (<autorizacion>) de una factura
+func (s *Server) DescargarXMLAutorizadoDB(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Obtener ID de la URL
+	idStr := r.URL.Path[len("/api/facturas/db/"):]
+	idStr = idStr[:len(idStr)-len("/xml")] // Remover "/xml" del final
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "ID de factura inválido", http.StatusBadRequest)
+		return
+	}
+
+	factura, err := s.facturas.ObtenerFacturaPorID(r.Context(), id)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Factura no encontrada: %v", err), http.StatusNotFound)
+		return
+	}
+
+	// Solo existe XML autorizado si el SRI autorizó la factura (se conserva al anularla)
+	if factura.XMLAutorizado == "" {
+		http.Error(w, fmt.Sprintf("La factura está %s y no tiene XML autorizado", factura.Estado), http.StatusConflict)
+		return
+	}
+
+	// Configurar headers para descarga del XML, nombrado con la clave de acceso como el SRI
+	filename := fmt.Sprintf("%s.xml", factura.ClaveAcceso)
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
+	w.Header().Set("Content-Length", strconv.Itoa(len(factura.XMLAutorizado)))
+
+	w.Write([]byte(factura.XMLAutorizado))
+}
+
+// EnviarFacturaSRIDB encola una factura existente en el pipeline de autorización
+func (s *Server) EnviarFacturaSRIDB(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if s.pipeline == nil {
+		http.Error(w, "Pipeline de autorización no habilitado", http.StatusServiceUnavailable)
+		return
+	}
+
+	// Obtener ID de la URL
+	idStr := r.URL.Path[len("/api/facturas/db/"):]
+	idStr = idStr[:len(idStr)-len("/enviar")] // Remover "/enviar" del final
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "ID de factura inválido", http.StatusBadRequest)
+		return
+	}
+
+	factura, err := s.facturas.ObtenerFacturaPorID(r.Context(), id)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Factura no encontrada: %v", err), http.StatusNotFound)
+		return
+	}
+
+	if factura.Estado == database.EstadoAutorizada || factura.Estado == database.EstadoAnulada {
+		http.Error(w, fmt.Sprintf("La factura está %s y no puede enviarse al SRI", factura.Estado), http.StatusConflict)
+		return
+	}
+
+	trabajo, err := s.pipeline.Encolar(id)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error encolando factura: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"success": true,
+		"message": "Factura encolada para autorización",
+		"data":    trabajo,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(response)
+}
+
+// ListarTrabajosAutorizacionDB lista los trabajos del pipeline de autorización
+func (s *Server) ListarTrabajosAutorizacionDB(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Parámetros de filtro y paginación
+	estado := r.URL.Query().Get("estado")
+	limit := 50
+	offset := 0
+
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 {
+		limit = l
+	}
+	if o, err := strconv.Atoi(r.URL.Query().Get("offset")); err == nil && o >= 0 {
+		offset = o
+	}
+
+	trabajos, err := s.db.ListarTrabajosAutorizacion(estado, limit, offset)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error listando trabajos: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"success": true,
+		"data": map[string]interface{}{
+			"trabajos":   trabajos,
+			"count":      len(trabajos),
+			"limit":      limit,
+			"offset":     offset,
+			"habilitado": s.pipeline != nil,
+		},
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -193,16 +193,44 @@ func (s *Server) handleRoot(w http.ResponseWriter, r *http.Request) {
 			"POST /api/facturas": "Crear nueva factura (memoria)",
 			"GET /api/facturas/{id}": "Obtener factura por ID (memoria)",
 			"POST /api/facturas/db": "Crear nueva factura (base de datos)",
-			"GET /api/facturas/db/list": "Listar facturas (base de datos)",
+			"GET /api/facturas/db/list": "Listar facturas (desde, hasta, estado, ambiente, totalMin, totalMax, establecimiento, numero, clave, cedula, ordenarPor, orden)",
 			"GET /api/facturas/db/{id}": "Obtener factura por ID (base de datos)",
-			"PUT /api/facturas/db/{id}/estado": "Actualizar estado de factura",
+			"PUT /api/facturas/db/{id}/estado": "Reabrir o anular factura (BORRADOR, ANULADA)",
+			"GET /api/facturas/db/{id}/xml": "Descargar XML autorizado por el SRI",
+			"DELETE /api/facturas/db/{id}": "Eliminar factura en BORRADOR",
+			"POST /api/facturas/db/{id}/anulacion": "Solicitar anulación de factura autorizada (motivo)",
+			"GET /api/facturas/db/{id}/anulacion": "Solicitudes de anulación de la factura",
+			"POST /api/facturas/db/{id}/anulacion/confirmar": "Confirmar anulación con la referencia del portal del SRI",
+			"POST /api/facturas/db/{id}/anulacion/rechazar": "Registrar anulación rechazada por el SRI o el receptor",
 			"GET /api/estadisticas": "Obtener estadísticas de facturas",
 			"POST /api/clientes": "Guardar cliente",
 			"GET /api/clientes/buscar?cedula=XXX": "Buscar cliente por cédula",
+			"GET /api/catalogo/productos?q=XXX": "Listar catálogo de productos (incluirInactivos=true para ver todos)",
+			"POST /api/catalogo/productos": "Agregar producto al catálogo",
+			"GET /api/catalogo/productos/{id}": "Obtener producto del catálogo",
+			"PUT /api/catalogo/productos/{id}": "Actualizar producto del catálogo",
+			"DELETE /api/catalogo/productos/{id}": "Desactivar producto del catálogo",
+			"GET /api/inventario/stock?establecimiento=001&bajo=true": "Existencias por establecimiento (bajo=true: en o bajo el mínimo)",
+			"PUT /api/inventario/stock": "Configurar stock mínimo de un producto",
+			"GET /api/inventario/movimientos?productoId=N": "Kardex de movimientos de inventario",
+			"POST /api/inventario/ajustes": "Ajuste manual de inventario (requiere motivo)",
+			"POST /api/inventario/notas-credito": "Reingresar devoluciones de una nota de crédito autorizada",
+			"GET /api/buscar?q=XXX": "Búsqueda de texto completo en clientes y productos facturados",
 			"GET /api/sri/estado?clave=XXX": "Consultar estado en SRI",
+			"GET /api/sri/errores?codigo=XX": "Catálogo de errores del SRI",
+			"GET /api/sri/intercambios?clave=X&desde=AAAA-MM-DD&hasta=AAAA-MM-DD": "Buscar envelopes SOAP archivados",
+			"GET /api/sri/intercambios/{id}": "Petición y respuesta SOAP archivadas",
+			"POST /api/sri/intercambios/purgar?dias=N": "Aplicar retención del archivo SOAP",
+			"GET /api/admin/sri/circuit-breaker": "Estado y estadísticas del circuit breaker SRI",
+			"POST /api/admin/sri/circuit-breaker/reiniciar?ambiente=1": "Reiniciar circuit breaker SRI",
 			"GET /api/auditoria?tabla=XXX": "Obtener registros de auditoría",
+			"GET /api/auditoria/verificar": "Verificar la cadena de hashes de auditoría y el último checkpoint firmado",
 			"POST /api/respaldos": "Crear respaldo manual de la base de datos",
 			"GET /api/respaldos/listar": "Listar todos los respaldos disponibles",
+			"GET /api/pipeline/fallidos?estado=PENDIENTE": "Cola de envíos que agotaron sus reintentos",
+			"GET /api/pipeline/fallidos/{id}": "Detalle de un fallido con el error de cada intento",
+			"POST /api/pipeline/fallidos/{id}/reencolar": "Reencolar un fallido en el pipeline de autorización",
+			"POST /api/pipeline/fallidos/{id}/descartar": "Descartar un fallido con motivo",
 		},
 		"example_request": map[string]interface{}{
 			"url": "/api/facturas",
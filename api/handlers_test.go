@@ -22,7 +22,7 @@ func setUp() {
 
 // TestNewServer verifica la creación del servidor
 func TestNewServer(t *testing.T) {
-	server := NewServer("8080")
+	server := NewServer("8080", nil, nil)
 	
 	if server == nil {
 		t.Fatal("NewServer() retornó nil")
@@ -38,7 +38,7 @@ func TestNewServer(t *testing.T) {
 // TestHandleHealth verifica el endpoint de health check
 func TestHandleHealth(t *testing.T) {
 	setUp()
-	server := NewServer("8080")
+	server := NewServer("8080", nil, nil)
 
 	tests := []struct {
 		name           string
@@ -101,7 +101,7 @@ func TestHandleHealth(t *testing.T) {
 // TestHandleRoot verifica el endpoint de documentación
 func TestHandleRoot(t *testing.T) {
 	setUp()
-	server := NewServer("8080")
+	server := NewServer("8080", nil, nil)
 
 	tests := []struct {
 		name           string
@@ -152,7 +152,7 @@ func TestHandleRoot(t *testing.T) {
 // TestHandleCreateFactura verifica la creación de facturas
 func TestHandleCreateFactura(t *testing.T) {
 	setUp()
-	server := NewServer("8080")
+	server := NewServer("8080", nil, nil)
 
 	tests := []struct {
 		name           string
@@ -311,7 +311,7 @@ func TestHandleCreateFactura(t *testing.T) {
 // TestHandleListFacturas verifica el listado de facturas
 func TestHandleListFacturas(t *testing.T) {
 	setUp()
-	server := NewServer("8080")
+	server := NewServer("8080", nil, nil)
 
 	// Agregar algunas facturas al storage
 	storage.Store("FAC-000001", FacturaResponse{
@@ -357,7 +357,7 @@ func TestHandleListFacturas(t *testing.T) {
 // TestHandleFacturaByID verifica obtener factura por ID
 func TestHandleFacturaByID(t *testing.T) {
 	setUp()
-	server := NewServer("8080")
+	server := NewServer("8080", nil, nil)
 
 	// Agregar factura al storage
 	testFactura := FacturaResponse{
@@ -492,7 +492,7 @@ func TestHandleFacturaByID(t *testing.T) {
 // TestHandleFacturas verifica el router principal de facturas
 func TestHandleFacturas(t *testing.T) {
 	setUp()
-	server := NewServer("8080")
+	server := NewServer("8080", nil, nil)
 
 	tests := []struct {
 		name           string
@@ -648,7 +648,7 @@ func TestFacturaResponse_StructureValidation(t *testing.T) {
 // Benchmark para crear facturas
 func BenchmarkHandleCreateFactura(b *testing.B) {
 	setUp()
-	server := NewServer("8080")
+	server := NewServer("8080", nil, nil)
 
 	request := CreateFacturaRequest{
 		FacturaInput: models.FacturaInput{
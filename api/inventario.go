@@ -0,0 +1,197 @@
+// Package api expone el inventario por establecimiento y su kardex
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"go-facturacion-sri/database"
+)
+
+// handleStockInventario maneja /api/inventario/stock (consultar y fijar stock mínimo)
+func (s *Server) handleStockInventario(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		s.ListarStockDB(w, r)
+	} else if r.Method == http.MethodPut {
+		s.ConfigurarStockMinimoDB(w, r)
+	} else {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Método no permitido")
+	}
+}
+
+// ListarStockDB lista existencias; con bajo=true solo los productos en o bajo su stock mínimo
+func (s *Server) ListarStockDB(w http.ResponseWriter, r *http.Request) {
+	establecimiento := r.URL.Query().Get("establecimiento")
+	limit := 50
+	offset := 0
+
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 {
+		limit = l
+	}
+	if o, err := strconv.Atoi(r.URL.Query().Get("offset")); err == nil && o >= 0 {
+		offset = o
+	}
+
+	var stock []*database.StockDB
+	var err error
+	if r.URL.Query().Get("bajo") == "true" {
+		stock, err = s.inventario.ListarStockBajo(r.Context(), establecimiento)
+	} else {
+		stock, err = s.inventario.ListarStock(r.Context(), establecimiento, limit, offset)
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error consultando stock: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"success": true,
+		"data": map[string]interface{}{
+			"stock":  stock,
+			"count":  len(stock),
+			"limit":  limit,
+			"offset": offset,
+		},
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// ConfigurarStockMinimoDB fija el stock mínimo de un producto en un establecimiento
+func (s *Server) ConfigurarStockMinimoDB(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		ProductoID      int     `json:"productoId"`
+		Establecimiento string  `json:"establecimiento"`
+		StockMinimo     float64 `json:"stockMinimo"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, fmt.Sprintf("Error parseando JSON: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	stock, err := s.inventario.ConfigurarStockMinimo(r.Context(), input.ProductoID, input.Establecimiento, input.StockMinimo)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error configurando stock mínimo: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	response := map[string]interface{}{
+		"success": true,
+		"message": "Stock mínimo actualizado",
+		"data":    stock,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// ListarMovimientosInventarioDB lista el kardex, opcionalmente de un producto y establecimiento
+func (s *Server) ListarMovimientosInventarioDB(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Método no permitido")
+		return
+	}
+
+	productoID, _ := strconv.Atoi(r.URL.Query().Get("productoId"))
+	establecimiento := r.URL.Query().Get("establecimiento")
+	limit := 50
+	offset := 0
+
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 {
+		limit = l
+	}
+	if o, err := strconv.Atoi(r.URL.Query().Get("offset")); err == nil && o >= 0 {
+		offset = o
+	}
+
+	movimientos, err := s.inventario.ListarMovimientosInventario(r.Context(), productoID, establecimiento, limit, offset)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error consultando movimientos: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"success": true,
+		"data": map[string]interface{}{
+			"movimientos": movimientos,
+			"count":       len(movimientos),
+			"limit":       limit,
+			"offset":      offset,
+		},
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// AjustarStockDB registra un ajuste manual de inventario
+func (s *Server) AjustarStockDB(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Método no permitido")
+		return
+	}
+
+	var ajuste database.AjusteInventario
+	if err := json.NewDecoder(r.Body).Decode(&ajuste); err != nil {
+		http.Error(w, fmt.Sprintf("Error parseando JSON: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	ajuste.Usuario = usuarioSolicitud(r, ajuste.Usuario)
+
+	movimiento, err := s.inventario.AjustarStock(r.Context(), ajuste)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error ajustando inventario: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	response := map[string]interface{}{
+		"success": true,
+		"message": "Ajuste de inventario registrado",
+		"data":    movimiento,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+// RegistrarNotaCreditoDB reingresa al inventario la mercadería de una nota de crédito autorizada
+func (s *Server) RegistrarNotaCreditoDB(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Método no permitido")
+		return
+	}
+
+	var nota database.NotaCreditoDevolucion
+	if err := json.NewDecoder(r.Body).Decode(&nota); err != nil {
+		http.Error(w, fmt.Sprintf("Error parseando JSON: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	nota.Usuario = usuarioSolicitud(r, nota.Usuario)
+
+	movimientos, err := s.inventario.RegistrarNotaCreditoDevolucion(r.Context(), nota)
+	if errors.Is(err, database.ErrNotaCreditoRegistrada) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error registrando devolución: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	response := map[string]interface{}{
+		"success": true,
+		"message": "Devolución registrada en el inventario",
+		"data":    movimientos,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
@@ -1,11 +1,50 @@
 package api
 
 import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
 	"log"
+	"net"
 	"net/http"
+	"regexp"
+	"strings"
 	"time"
+
+	"go-facturacion-sri/config"
+	"go-facturacion-sri/database"
 )
 
+// usuarioAnonimoAPI actor de las peticiones sin usuario autenticado
+const usuarioAnonimoAPI = "api"
+
+// usuarioOperadorAPI actor de las peticiones con la credencial de operador
+const usuarioOperadorAPI = "operador"
+
+// patronRequestID request IDs aceptados desde un proxy confiable
+var patronRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// rutasOperador endpoints que exponen datos de todos los emisores (respaldos, cola del pipeline,
+// intercambios SOAP, cadena de auditoría completa); una credencial de tenant no tiene acceso
+var rutasOperador = []string{
+	"/api/auditoria/verificar",
+	"/api/respaldos",
+	"/api/pipeline/",
+	"/api/sri/intercambios",
+	"/api/admin/",
+}
+
+// esRutaOperador indica si la ruta está reservada al operador del despliegue
+func esRutaOperador(ruta string) bool {
+	for _, prefijo := range rutasOperador {
+		if strings.HasPrefix(ruta, prefijo) {
+			return true
+		}
+	}
+	return false
+}
+
 // loggingMiddleware - Middleware para logging de requests
 func (s *Server) loggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -19,13 +58,15 @@ func (s *Server) loggingMiddleware(next http.Handler) http.Handler {
 		
 		// Log de la request
 		duration := time.Since(start)
+		actor := database.ActorAuditoriaDe(r.Context())
 		log.Printf(
-			"%s %s %d %v %s",
+			"%s %s %d %v %s %s",
 			r.Method,
 			r.URL.Path,
 			wrapped.statusCode,
 			duration,
 			r.RemoteAddr,
+			actor.RequestID,
 		)
 	})
 }
@@ -36,7 +77,7 @@ func (s *Server) corsMiddleware(next http.Handler) http.Handler {
 		// Configurar headers CORS
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-API-Key")
 		
 		// Manejar preflight OPTIONS request
 		if r.Method == http.MethodOptions {
@@ -59,4 +100,201 @@ type responseWriter struct {
 func (rw *responseWriter) WriteHeader(code int) {
 	rw.statusCode = code
 	rw.ResponseWriter.WriteHeader(code)
-}
\ No newline at end of file
+}
+
+// actorMiddleware identifica quién origina la petición (usuario, IP, user agent y request ID) y
+// lo agrega al contexto para que las escrituras auditadas lo registren
+func (s *Server) actorMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actor := actorDePeticion(r, config.Config.Servidor)
+		w.Header().Set("X-Request-ID", actor.RequestID)
+		next.ServeHTTP(w, r.WithContext(database.ConActorAuditoria(r.Context(), actor)))
+	})
+}
+
+// tenantMiddleware acota la petición al tenant de su credencial de API. Sin credencial la
+// petición opera como el emisor de config.Config (tenant 0), salvo que la configuración exija
+// credenciales; una credencial inválida o revocada siempre se rechaza. Las rutas de operador
+// requieren la credencial de operador, que también opera como tenant 0.
+func (s *Server) tenantMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.tenants == nil || r.URL.Path == "/health" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		clave := credencialDePeticion(r)
+		if clave != "" && s.esClaveOperador(clave) {
+			ctx := database.ConTenant(r.Context(), database.TenantPredeterminado)
+			if actor := database.ActorAuditoriaDe(ctx); !actor.Autenticado {
+				actor.Usuario = usuarioOperadorAPI
+				actor.Autenticado = true
+				ctx = database.ConActorAuditoria(ctx, actor)
+			}
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+
+		if clave == "" {
+			requerida := config.Config.Servidor.RequerirCredenciales
+			if esRutaOperador(r.URL.Path) {
+				libre, err := s.operadorSinCredencial()
+				if err != nil {
+					http.Error(w, "Error verificando credencial de API", http.StatusInternalServerError)
+					return
+				}
+				requerida = !libre
+			}
+			if requerida {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(w, "Credencial de API requerida", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(database.ConTenant(r.Context(), database.TenantPredeterminado)))
+			return
+		}
+
+		credencial, tenant, err := s.tenants.ResolverCredencialTenant(clave)
+		if errors.Is(err, database.ErrCredencialInvalida) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "Credencial de API inválida", http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Error verificando credencial de API", http.StatusInternalServerError)
+			return
+		}
+		if esRutaOperador(r.URL.Path) {
+			http.Error(w, "Ruta no disponible para credenciales de tenant", http.StatusForbidden)
+			return
+		}
+
+		ctx := database.ConTenant(r.Context(), tenant.ID)
+		// El usuario autenticado por un proxy confiable tiene prioridad sobre el nombre de la credencial
+		if actor := database.ActorAuditoriaDe(ctx); !actor.Autenticado {
+			actor.Usuario = credencial.Nombre
+			actor.Autenticado = true
+			ctx = database.ConActorAuditoria(ctx, actor)
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// esClaveOperador compara en tiempo constante con la credencial de operador configurada
+func (s *Server) esClaveOperador(clave string) bool {
+	return len(s.claveOperador) > 0 && subtle.ConstantTimeCompare([]byte(clave), s.claveOperador) == 1
+}
+
+// operadorSinCredencial indica si las rutas de operador aceptan peticiones sin credencial: solo
+// en un despliegue de un único emisor, sin tenants, sin clave de operador y sin credenciales exigidas
+func (s *Server) operadorSinCredencial() (bool, error) {
+	if config.Config.Servidor.ClaveOperador != "" || config.Config.Servidor.RequerirCredenciales {
+		return false, nil
+	}
+	existen, err := s.tenants.ExistenTenants()
+	return !existen, err
+}
+
+// credencialDePeticion retorna la clave de API de Authorization: Bearer o, si no viene, de X-API-Key
+func credencialDePeticion(r *http.Request) string {
+	autorizacion := strings.TrimSpace(r.Header.Get("Authorization"))
+	if len(autorizacion) > len("Bearer ") && strings.EqualFold(autorizacion[:len("Bearer ")], "Bearer ") {
+		return strings.TrimSpace(autorizacion[len("Bearer "):])
+	}
+	return strings.TrimSpace(r.Header.Get("X-API-Key"))
+}
+
+// actorDePeticion arma el actor de la petición. X-Forwarded-For, X-Request-ID y el encabezado de
+// usuario solo se aceptan cuando la conexión viene de un proxy confiable.
+func actorDePeticion(r *http.Request, cfg config.ServidorConfig) database.ActorAuditoria {
+	remota := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(remota); err == nil {
+		remota = host
+	}
+
+	actor := database.ActorAuditoria{
+		Usuario:   usuarioAnonimoAPI,
+		IPAddress: remota,
+		UserAgent: r.UserAgent(),
+	}
+
+	if esProxyConfiable(remota, cfg.ProxiesConfiables) {
+		actor.IPAddress = ipCliente(r.Header.Values("X-Forwarded-For"), remota, cfg.ProxiesConfiables)
+
+		encabezado := cfg.EncabezadoUsuario
+		if encabezado == "" {
+			encabezado = "X-Forwarded-User"
+		}
+		if usuario := strings.TrimSpace(r.Header.Get(encabezado)); usuario != "" {
+			actor.Usuario = usuario
+			actor.Autenticado = true
+		}
+		if id := r.Header.Get("X-Request-ID"); patronRequestID.MatchString(id) {
+			actor.RequestID = id
+		}
+	}
+
+	if actor.RequestID == "" {
+		actor.RequestID = nuevoRequestID()
+	}
+	return actor
+}
+
+// ipCliente recorre X-Forwarded-For de derecha a izquierda y retorna la primera IP que no es un
+// proxy confiable; las entradas de la izquierda las escribe el cliente y no son confiables
+func ipCliente(forwardedFor []string, remota string, proxies []string) string {
+	var saltos []string
+	for _, valor := range forwardedFor {
+		for _, salto := range strings.Split(valor, ",") {
+			if salto = strings.TrimSpace(salto); net.ParseIP(salto) != nil {
+				saltos = append(saltos, salto)
+			}
+		}
+	}
+
+	cliente := remota
+	for i := len(saltos) - 1; i >= 0; i-- {
+		cliente = saltos[i]
+		if !esProxyConfiable(cliente, proxies) {
+			break
+		}
+	}
+	return cliente
+}
+
+// esProxyConfiable indica si la IP está en la lista de proxies (IPs individuales o rangos CIDR)
+func esProxyConfiable(ip string, proxies []string) bool {
+	direccion := net.ParseIP(ip)
+	if direccion == nil {
+		return false
+	}
+	for _, proxy := range proxies {
+		if _, red, err := net.ParseCIDR(proxy); err == nil {
+			if red.Contains(direccion) {
+				return true
+			}
+		} else if confiable := net.ParseIP(proxy); confiable != nil && confiable.Equal(direccion) {
+			return true
+		}
+	}
+	return false
+}
+
+// nuevoRequestID genera un identificador aleatorio para correlacionar logs y auditoría
+func nuevoRequestID() string {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		return time.Now().UTC().Format("20060102150405.000000000")
+	}
+	return hex.EncodeToString(bytes)
+}
+
+// usuarioSolicitud usuario de una operación: el autenticado por el proxy prevalece sobre el
+// declarado en el cuerpo de la petición
+func usuarioSolicitud(r *http.Request, declarado string) string {
+	actor := database.ActorAuditoriaDe(r.Context())
+	if actor.Autenticado || declarado == "" {
+		return actor.Usuario
+	}
+	return declarado
+}
@@ -5,11 +5,13 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+
+	"go-facturacion-sri/config"
 )
 
 // TestCorsMiddleware verifica el middleware CORS
 func TestCorsMiddleware(t *testing.T) {
-	server := NewServer("8080")
+	server := NewServer("8080", nil, nil)
 
 	// Handler simple para testing
 	testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -31,7 +33,7 @@ func TestCorsMiddleware(t *testing.T) {
 				expectedHeaders := map[string]string{
 					"Access-Control-Allow-Origin":  "*",
 					"Access-Control-Allow-Methods": "GET, POST, PUT, DELETE, OPTIONS",
-					"Access-Control-Allow-Headers": "Content-Type, Authorization",
+					"Access-Control-Allow-Headers": "Content-Type, Authorization, X-API-Key",
 				}
 
 				for header, expectedValue := range expectedHeaders {
@@ -98,7 +100,7 @@ func TestCorsMiddleware(t *testing.T) {
 
 // TestLoggingMiddleware verifica el middleware de logging
 func TestLoggingMiddleware(t *testing.T) {
-	server := NewServer("8080")
+	server := NewServer("8080", nil, nil)
 
 	// Handler simple para testing
 	testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -205,7 +207,7 @@ func TestResponseWriter(t *testing.T) {
 
 // TestMiddlewareChain verifica que los middlewares se apliquen en orden correcto
 func TestMiddlewareChain(t *testing.T) {
-	server := NewServer("8080")
+	server := NewServer("8080", nil, nil)
 
 	// Handler que retorna diferentes status codes para testing
 	testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -426,7 +428,7 @@ func TestWriteErrorResponse(t *testing.T) {
 
 // Benchmark para middleware chain completo
 func BenchmarkMiddlewareChain(b *testing.B) {
-	server := NewServer("8080")
+	server := NewServer("8080", nil, nil)
 
 	testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -450,7 +452,7 @@ func BenchmarkMiddlewareChain(b *testing.B) {
 
 // Benchmark para CORS middleware solo
 func BenchmarkCorsMiddleware(b *testing.B) {
-	server := NewServer("8080")
+	server := NewServer("8080", nil, nil)
 
 	testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -468,4 +470,63 @@ func BenchmarkCorsMiddleware(b *testing.B) {
 			b.Fatalf("expected status 200, got %d", w.Code)
 		}
 	}
-}
\ No newline at end of file
+}
+func TestActorDePeticion(t *testing.T) {
+	cfg := config.ServidorConfig{ProxiesConfiables: []string{"10.0.0.0/8", "192.168.1.10"}}
+
+	tests := []struct {
+		name        string
+		remota      string
+		encabezados map[string]string
+		ip          string
+		usuario     string
+		autenticado bool
+		requestID   string
+	}{
+		{
+			name:        "cliente directo no puede falsificar encabezados",
+			remota:      "203.0.113.7:5000",
+			encabezados: map[string]string{"X-Forwarded-For": "1.1.1.1", "X-Forwarded-User": "admin", "X-Request-ID": "abc"},
+			ip:          "203.0.113.7",
+			usuario:     usuarioAnonimoAPI,
+		},
+		{
+			name:        "proxy confiable",
+			remota:      "10.0.0.2:443",
+			encabezados: map[string]string{"X-Forwarded-For": "1.1.1.1, 198.51.100.4, 192.168.1.10", "X-Forwarded-User": "contador", "X-Request-ID": "req-123"},
+			ip:          "198.51.100.4",
+			usuario:     "contador",
+			autenticado: true,
+			requestID:   "req-123",
+		},
+		{
+			name:        "request ID inválido se reemplaza",
+			remota:      "192.168.1.10:443",
+			encabezados: map[string]string{"X-Request-ID": "id con espacios\n"},
+			ip:          "192.168.1.10",
+			usuario:     usuarioAnonimoAPI,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/clientes/list", nil)
+			req.RemoteAddr = tt.remota
+			req.Header.Set("User-Agent", "pruebas/1.0")
+			for clave, valor := range tt.encabezados {
+				req.Header.Set(clave, valor)
+			}
+
+			actor := actorDePeticion(req, cfg)
+			if actor.IPAddress != tt.ip || actor.Usuario != tt.usuario || actor.Autenticado != tt.autenticado || actor.UserAgent != "pruebas/1.0" {
+				t.Errorf("actorDePeticion() = %+v", actor)
+			}
+			if tt.requestID != "" && actor.RequestID != tt.requestID {
+				t.Errorf("RequestID = %q, esperado %q", actor.RequestID, tt.requestID)
+			}
+			if tt.requestID == "" && (actor.RequestID == "" || actor.RequestID == tt.encabezados["X-Request-ID"]) {
+				t.Errorf("Se esperaba un request ID generado, se obtuvo %q", actor.RequestID)
+			}
+		})
+	}
+}
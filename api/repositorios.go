@@ -0,0 +1,120 @@
+// Package api define los repositorios de los que dependen los handlers de facturas y clientes
+package api
+
+import (
+	"context"
+
+	"go-facturacion-sri/database"
+	"go-facturacion-sri/models"
+)
+
+// FacturaRepository operaciones de facturas que usan los handlers de la API
+type FacturaRepository interface {
+	GuardarFactura(ctx context.Context, factura models.Factura, claveAcceso string, productos []models.ProductoInput) (*database.FacturaDB, error)
+	ObtenerFacturaPorID(ctx context.Context, id int) (*database.FacturaDB, error)
+	ListarFacturas(ctx context.Context, limite, offset int) ([]*database.FacturaDB, error)
+	BuscarFacturas(ctx context.Context, filtro database.FiltroFacturas) ([]*database.FacturaDB, int, error)
+	ListarFacturasPorCliente(ctx context.Context, cedula string, limite, offset int) ([]*database.FacturaDB, error)
+	ObtenerProductosPorFactura(ctx context.Context, facturaID int) ([]*database.ProductoDB, error)
+	ActualizarFactura(ctx context.Context, id int, clienteCedula, clienteNombre string, productos []database.ProductoDB, observaciones string) (*database.FacturaDB, error)
+	EliminarFactura(ctx context.Context, id int) error
+	TransicionarEstadoFactura(ctx context.Context, id int, cambio database.CambioEstadoFactura) error
+	ObtenerHistorialEstados(ctx context.Context, facturaID int) ([]*database.HistorialEstadoDB, error)
+	EstadisticasFacturas(ctx context.Context) (map[string]interface{}, error)
+	ReservarSecuencialEmisor(ctx context.Context, ruc, establecimiento, puntoEmision string) (int, error)
+}
+
+// ClienteRepository operaciones de clientes que usan los handlers de la API
+type ClienteRepository interface {
+	GuardarCliente(ctx context.Context, cliente *database.ClienteDB) (*database.ClienteDB, error)
+	ObtenerClientePorID(ctx context.Context, id int) (*database.ClienteDB, error)
+	ObtenerClientePorCedula(ctx context.Context, cedula string) (*database.ClienteDB, error)
+	ListarClientes(ctx context.Context, nombre, tipoCliente string, limite, offset int) ([]*database.ClienteDB, error)
+	ActualizarCliente(ctx context.Context, cliente *database.ClienteDB) (*database.ClienteDB, error)
+	DesactivarCliente(ctx context.Context, id int) error
+	EliminarCliente(ctx context.Context, id int) error
+}
+
+// CatalogoRepository operaciones del catálogo maestro de productos que usan los handlers de la API
+type CatalogoRepository interface {
+	GuardarProductoCatalogo(ctx context.Context, producto *database.ProductoCatalogoDB) (*database.ProductoCatalogoDB, error)
+	ObtenerProductoCatalogo(ctx context.Context, id int) (*database.ProductoCatalogoDB, error)
+	ListarProductosCatalogo(ctx context.Context, busqueda string, incluirInactivos bool, limite, offset int) ([]*database.ProductoCatalogoDB, error)
+	ActualizarProductoCatalogo(ctx context.Context, producto *database.ProductoCatalogoDB) (*database.ProductoCatalogoDB, error)
+	DesactivarProductoCatalogo(ctx context.Context, id int) error
+	CompletarDesdeCatalogo(ctx context.Context, productos []models.ProductoInput) ([]models.ProductoInput, error)
+}
+
+// InventarioRepository operaciones de inventario que usan los handlers de la API
+type InventarioRepository interface {
+	ObtenerStock(ctx context.Context, productoID int, establecimiento string) (*database.StockDB, error)
+	ListarStock(ctx context.Context, establecimiento string, limite, offset int) ([]*database.StockDB, error)
+	ListarStockBajo(ctx context.Context, establecimiento string) ([]*database.StockDB, error)
+	ConfigurarStockMinimo(ctx context.Context, productoID int, establecimiento string, minimo float64) (*database.StockDB, error)
+	AjustarStock(ctx context.Context, ajuste database.AjusteInventario) (*database.MovimientoInventarioDB, error)
+	RegistrarNotaCreditoDevolucion(ctx context.Context, nota database.NotaCreditoDevolucion) ([]*database.MovimientoInventarioDB, error)
+	ListarMovimientosInventario(ctx context.Context, productoID int, establecimiento string, limite, offset int) ([]*database.MovimientoInventarioDB, error)
+}
+
+// BusquedaRepository búsqueda de texto completo en clientes y líneas de factura
+type BusquedaRepository interface {
+	Buscar(ctx context.Context, texto string, limite int) ([]*database.ResultadoBusqueda, error)
+}
+
+// AnulacionRepository flujo de anulación de facturas autorizadas
+type AnulacionRepository interface {
+	SolicitarAnulacion(ctx context.Context, facturaID int, motivo, usuario string) (*database.AnulacionFacturaDB, error)
+	ConfirmarAnulacion(ctx context.Context, facturaID int, referenciaSRI, usuario string) (*database.AnulacionFacturaDB, error)
+	RechazarAnulacion(ctx context.Context, facturaID int, observaciones, usuario string) (*database.AnulacionFacturaDB, error)
+	ListarAnulaciones(ctx context.Context, facturaID int) ([]*database.AnulacionFacturaDB, error)
+}
+
+// TenantRepository emisores y credenciales de API con los que se resuelve el tenant de cada petición
+type TenantRepository interface {
+	ResolverCredencialTenant(clave string) (*database.CredencialTenantDB, *database.TenantDB, error)
+	ObtenerTenant(id int) (*database.TenantDB, error)
+	ReservarSecuencialTenant(ctx context.Context, codigo, puntoEmision string) (*database.EstablecimientoTenantDB, error)
+	ExistenTenants() (bool, error)
+}
+
+// database.Database (SQLite o PostgreSQL) satisface todos los repositorios
+var (
+	_ FacturaRepository    = (*database.Database)(nil)
+	_ ClienteRepository    = (*database.Database)(nil)
+	_ CatalogoRepository   = (*database.Database)(nil)
+	_ InventarioRepository = (*database.Database)(nil)
+	_ BusquedaRepository   = (*database.Database)(nil)
+	_ AnulacionRepository  = (*database.Database)(nil)
+	_ TenantRepository     = (*database.Database)(nil)
+)
+
+// ConfigurarRepositorios reemplaza los repositorios de facturas y clientes (por ejemplo, con fakes en tests)
+func (s *Server) ConfigurarRepositorios(facturas FacturaRepository, clientes ClienteRepository) {
+	s.facturas = facturas
+	s.clientes = clientes
+}
+
+// ConfigurarCatalogo reemplaza el repositorio del catálogo de productos
+func (s *Server) ConfigurarCatalogo(catalogo CatalogoRepository) {
+	s.catalogo = catalogo
+}
+
+// ConfigurarInventario reemplaza el repositorio de inventario
+func (s *Server) ConfigurarInventario(inventario InventarioRepository) {
+	s.inventario = inventario
+}
+
+// ConfigurarBusqueda reemplaza el repositorio de búsqueda
+func (s *Server) ConfigurarBusqueda(busqueda BusquedaRepository) {
+	s.busqueda = busqueda
+}
+
+// ConfigurarAnulaciones reemplaza el repositorio del flujo de anulación
+func (s *Server) ConfigurarAnulaciones(anulaciones AnulacionRepository) {
+	s.anulaciones = anulaciones
+}
+
+// ConfigurarTenants reemplaza el repositorio de tenants y credenciales de API
+func (s *Server) ConfigurarTenants(tenants TenantRepository) {
+	s.tenants = tenants
+}
@@ -0,0 +1,410 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go-facturacion-sri/config"
+	"go-facturacion-sri/database"
+	"go-facturacion-sri/models"
+)
+
+// facturasFake repositorio de facturas en memoria
+type facturasFake struct {
+	facturas     map[int]*database.FacturaDB
+	secuenciales map[string]int
+}
+
+func (f *facturasFake) GuardarFactura(ctx context.Context, factura models.Factura, claveAcceso string, productos []models.ProductoInput) (*database.FacturaDB, error) {
+	nueva := &database.FacturaDB{ID: len(f.facturas) + 1, ClaveAcceso: claveAcceso, Estado: database.EstadoBorrador}
+	f.facturas[nueva.ID] = nueva
+	return nueva, nil
+}
+
+func (f *facturasFake) ObtenerFacturaPorID(ctx context.Context, id int) (*database.FacturaDB, error) {
+	if factura, ok := f.facturas[id]; ok {
+		return factura, nil
+	}
+	return nil, fmt.Errorf("factura con ID %d no encontrada", id)
+}
+
+func (f *facturasFake) ListarFacturas(ctx context.Context, limite, offset int) ([]*database.FacturaDB, error) {
+	var facturas []*database.FacturaDB
+	for _, factura := range f.facturas {
+		facturas = append(facturas, factura)
+	}
+	return facturas, nil
+}
+
+func (f *facturasFake) BuscarFacturas(ctx context.Context, filtro database.FiltroFacturas) ([]*database.FacturaDB, int, error) {
+	facturas, _ := f.ListarFacturas(ctx, filtro.Limite, filtro.Offset)
+	return facturas, len(facturas), nil
+}
+
+func (f *facturasFake) ListarFacturasPorCliente(ctx context.Context, cedula string, limite, offset int) ([]*database.FacturaDB, error) {
+	var facturas []*database.FacturaDB
+	for _, factura := range f.facturas {
+		if factura.ClienteCedula == cedula {
+			facturas = append(facturas, factura)
+		}
+	}
+	return facturas, nil
+}
+
+func (f *facturasFake) ObtenerProductosPorFactura(ctx context.Context, facturaID int) ([]*database.ProductoDB, error) {
+	return nil, nil
+}
+
+func (f *facturasFake) ActualizarFactura(ctx context.Context, id int, clienteCedula, clienteNombre string, productos []database.ProductoDB, observaciones string) (*database.FacturaDB, error) {
+	return f.ObtenerFacturaPorID(ctx, id)
+}
+
+func (f *facturasFake) EliminarFactura(ctx context.Context, id int) error {
+	delete(f.facturas, id)
+	return nil
+}
+
+func (f *facturasFake) TransicionarEstadoFactura(ctx context.Context, id int, cambio database.CambioEstadoFactura) error {
+	factura, err := f.ObtenerFacturaPorID(ctx, id)
+	if err != nil {
+		return err
+	}
+	factura.Estado = cambio.Estado
+	return nil
+}
+
+func (f *facturasFake) ObtenerHistorialEstados(ctx context.Context, facturaID int) ([]*database.HistorialEstadoDB, error) {
+	return nil, nil
+}
+
+func (f *facturasFake) EstadisticasFacturas(ctx context.Context) (map[string]interface{}, error) {
+	return map[string]interface{}{"total_facturas": len(f.facturas)}, nil
+}
+
+func (f *facturasFake) ReservarSecuencialEmisor(ctx context.Context, ruc, establecimiento, puntoEmision string) (int, error) {
+	clave := ruc + establecimiento + puntoEmision
+	f.secuenciales[clave]++
+	return f.secuenciales[clave], nil
+}
+
+// clientesFake repositorio de clientes en memoria
+type clientesFake struct {
+	clientes     map[int]*database.ClienteDB
+	desactivados []int
+	eliminados   []int
+}
+
+func (c *clientesFake) GuardarCliente(ctx context.Context, cliente *database.ClienteDB) (*database.ClienteDB, error) {
+	cliente.ID = len(c.clientes) + 1
+	c.clientes[cliente.ID] = cliente
+	return cliente, nil
+}
+
+func (c *clientesFake) ObtenerClientePorID(ctx context.Context, id int) (*database.ClienteDB, error) {
+	if cliente, ok := c.clientes[id]; ok {
+		return cliente, nil
+	}
+	return nil, fmt.Errorf("cliente con ID %d no encontrado", id)
+}
+
+func (c *clientesFake) ObtenerClientePorCedula(ctx context.Context, cedula string) (*database.ClienteDB, error) {
+	for _, cliente := range c.clientes {
+		if cliente.Cedula == cedula {
+			return cliente, nil
+		}
+	}
+	return nil, fmt.Errorf("cliente con cédula %s no encontrado", cedula)
+}
+
+func (c *clientesFake) ListarClientes(ctx context.Context, nombre, tipoCliente string, limite, offset int) ([]*database.ClienteDB, error) {
+	var clientes []*database.ClienteDB
+	for _, cliente := range c.clientes {
+		clientes = append(clientes, cliente)
+	}
+	return clientes, nil
+}
+
+func (c *clientesFake) ActualizarCliente(ctx context.Context, cliente *database.ClienteDB) (*database.ClienteDB, error) {
+	c.clientes[cliente.ID] = cliente
+	return cliente, nil
+}
+
+func (c *clientesFake) DesactivarCliente(ctx context.Context, id int) error {
+	c.desactivados = append(c.desactivados, id)
+	return nil
+}
+
+func (c *clientesFake) EliminarCliente(ctx context.Context, id int) error {
+	c.eliminados = append(c.eliminados, id)
+	return nil
+}
+
+func nuevoServidorConFakes() (*Server, *facturasFake, *clientesFake) {
+	facturas := &facturasFake{facturas: map[int]*database.FacturaDB{}, secuenciales: map[string]int{}}
+	clientes := &clientesFake{clientes: map[int]*database.ClienteDB{}}
+
+	server := NewServer("8080", nil, nil)
+	server.ConfigurarRepositorios(facturas, clientes)
+	return server, facturas, clientes
+}
+
+func TestObtenerFacturaDBConRepositorio(t *testing.T) {
+	server, facturas, _ := nuevoServidorConFakes()
+	facturas.facturas[1] = &database.FacturaDB{ID: 1, NumeroFactura: "001-001-000000001", Estado: database.EstadoBorrador}
+
+	rr := httptest.NewRecorder()
+	server.Router().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/facturas/db/1", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Status = %d, esperado 200: %s", rr.Code, rr.Body.String())
+	}
+
+	var respuesta struct {
+		Data struct {
+			Factura database.FacturaDB `json:"factura"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&respuesta); err != nil {
+		t.Fatalf("Respuesta no es JSON: %v", err)
+	}
+	if respuesta.Data.Factura.NumeroFactura != "001-001-000000001" {
+		t.Errorf("Factura inesperada: %+v", respuesta.Data.Factura)
+	}
+
+	rr = httptest.NewRecorder()
+	server.Router().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/facturas/db/99", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Status factura inexistente = %d, esperado 404", rr.Code)
+	}
+}
+
+func TestEliminarClienteDBConFacturasLoDesactiva(t *testing.T) {
+	server, facturas, clientes := nuevoServidorConFakes()
+	clientes.clientes[1] = &database.ClienteDB{ID: 1, Cedula: "1713175071", Nombre: "CLIENTE CON FACTURAS"}
+	clientes.clientes[2] = &database.ClienteDB{ID: 2, Cedula: "0926687856", Nombre: "CLIENTE SIN FACTURAS"}
+	facturas.facturas[1] = &database.FacturaDB{ID: 1, ClienteCedula: "1713175071"}
+
+	rr := httptest.NewRecorder()
+	server.Router().ServeHTTP(rr, httptest.NewRequest(http.MethodDelete, "/api/clientes/1", nil))
+	if rr.Code != http.StatusOK || len(clientes.desactivados) != 1 || len(clientes.eliminados) != 0 {
+		t.Errorf("Cliente con facturas: status=%d desactivados=%v eliminados=%v", rr.Code, clientes.desactivados, clientes.eliminados)
+	}
+
+	rr = httptest.NewRecorder()
+	server.Router().ServeHTTP(rr, httptest.NewRequest(http.MethodDelete, "/api/clientes/2", nil))
+	if rr.Code != http.StatusOK || len(clientes.eliminados) != 1 || clientes.eliminados[0] != 2 {
+		t.Errorf("Cliente sin facturas: status=%d eliminados=%v", rr.Code, clientes.eliminados)
+	}
+}
+
+func TestActualizarEstadoFacturaDBSoloCambiosManuales(t *testing.T) {
+	server, facturas, _ := nuevoServidorConFakes()
+	facturas.facturas[1] = &database.FacturaDB{ID: 1, Estado: database.EstadoRecibida}
+	facturas.facturas[2] = &database.FacturaDB{ID: 2, Estado: database.EstadoBorrador}
+
+	put := func(id int, cuerpo string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		ruta := fmt.Sprintf("/api/facturas/db/%d/estado", id)
+		server.Router().ServeHTTP(rr, httptest.NewRequest(http.MethodPut, ruta, strings.NewReader(cuerpo)))
+		return rr
+	}
+
+	// Los estados del SRI solo los asigna el pipeline
+	for _, estado := range []string{database.EstadoEnviada, database.EstadoAutorizada, database.EstadoRechazada} {
+		if rr := put(1, fmt.Sprintf(`{"estado":%q}`, estado)); rr.Code != http.StatusConflict {
+			t.Errorf("PUT %s status = %d, esperado 409", estado, rr.Code)
+		}
+	}
+	if rr := put(1, `{"estado":"ANULADA","numero_autorizacion":"1234567890"}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("PUT con numero_autorizacion status = %d, esperado 400", rr.Code)
+	}
+	if facturas.facturas[1].Estado != database.EstadoRecibida {
+		t.Errorf("Estado tras peticiones rechazadas = %s, esperado RECIBIDA", facturas.facturas[1].Estado)
+	}
+
+	if rr := put(2, `{"estado":"ANULADA","observaciones_sri":"Emitida por error"}`); rr.Code != http.StatusOK {
+		t.Errorf("PUT ANULADA status = %d: %s", rr.Code, rr.Body.String())
+	}
+	if facturas.facturas[2].Estado != database.EstadoAnulada {
+		t.Errorf("Estado tras anular = %s, esperado ANULADA", facturas.facturas[2].Estado)
+	}
+}
+
+func TestNewServerUsaBaseDeDatosCompartida(t *testing.T) {
+	db, err := database.New(filepath.Join(t.TempDir(), "api.db"))
+	if err != nil {
+		t.Fatalf("Error creando base de datos: %v", err)
+	}
+	defer db.Close()
+
+	if _, err := db.GuardarCliente(context.Background(), &database.ClienteDB{Cedula: "1713175071", Nombre: "CLIENTE API", TipoCliente: "PERSONA_NATURAL"}); err != nil {
+		t.Fatalf("GuardarCliente() error: %v", err)
+	}
+
+	server := NewServer("8080", db, nil)
+	rr := httptest.NewRecorder()
+	server.Router().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/clientes/buscar?cedula=1713175071", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Status = %d, esperado 200: %s", rr.Code, rr.Body.String())
+	}
+}
+
+// La clave de acceso usa el RUC de la configuración y un secuencial persistido que sobrevive a un reinicio
+func TestCrearFacturaDBReservaSecuencialDelEmisor(t *testing.T) {
+	config.CargarConfiguracionPorDefecto()
+	ruta := filepath.Join(t.TempDir(), "secuencial.db")
+	factura := `{"ClienteNombre":"CLIENTE SECUENCIAL","ClienteCedula":"1713175071","Productos":[{"Codigo":"S1","Descripcion":"Servicio","Cantidad":1,"PrecioUnitario":10}]}`
+
+	crear := func(esperado string) {
+		t.Helper()
+		db, err := database.New(ruta)
+		if err != nil {
+			t.Fatalf("Error abriendo base de datos: %v", err)
+		}
+		defer db.Close()
+
+		rr := httptest.NewRecorder()
+		NewServer("8080", db, nil).Router().ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/facturas/db?includeXML=true", strings.NewReader(factura)))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Status crear factura = %d: %s", rr.Code, rr.Body.String())
+		}
+		var respuesta struct {
+			Data struct {
+				ClaveAcceso string `json:"clave_acceso"`
+				XML         string `json:"xml"`
+			} `json:"data"`
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&respuesta); err != nil {
+			t.Fatalf("Respuesta no es JSON: %v", err)
+		}
+
+		clave := respuesta.Data.ClaveAcceso
+		if len(clave) != 49 || clave[10:23] != config.Config.Empresa.RUC || clave[24:30] != "001001" || clave[30:39] != esperado {
+			t.Errorf("Clave de acceso %q: esperado RUC %s, serie 001001 y secuencial %s", clave, config.Config.Empresa.RUC, esperado)
+		}
+		if !strings.Contains(respuesta.Data.XML, "<claveAcceso>"+clave+"</claveAcceso>") ||
+			!strings.Contains(respuesta.Data.XML, "<secuencial>"+esperado+"</secuencial>") {
+			t.Errorf("El XML no usa la clave y el secuencial reservados: %s", respuesta.Data.XML)
+		}
+	}
+
+	crear("000000001")
+	crear("000000002")
+	crear("000000003")
+}
+
+func TestCrearFacturaDBDesdeCatalogo(t *testing.T) {
+	config.CargarConfiguracionPorDefecto()
+	db, err := database.New(filepath.Join(t.TempDir(), "catalogo.db"))
+	if err != nil {
+		t.Fatalf("Error creando base de datos: %v", err)
+	}
+	defer db.Close()
+	server := NewServer("8080", db, nil)
+
+	producto := `{"codigoPrincipal":"SERV001","descripcion":"Soporte técnico mensual","precioUnitario":80}`
+	rr := httptest.NewRecorder()
+	server.Router().ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/catalogo/productos", strings.NewReader(producto)))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Status crear producto = %d: %s", rr.Code, rr.Body.String())
+	}
+
+	factura := `{"ClienteNombre":"CLIENTE CATALOGO","ClienteCedula":"1713175071","Productos":[{"Codigo":"SERV001","Cantidad":2}]}`
+	rr = httptest.NewRecorder()
+	server.Router().ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/facturas/db", strings.NewReader(factura)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Status crear factura = %d: %s", rr.Code, rr.Body.String())
+	}
+	var respuesta struct {
+		Data struct {
+			Total float64 `json:"total"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&respuesta); err != nil {
+		t.Fatalf("Respuesta no es JSON: %v", err)
+	}
+	if math.Abs(respuesta.Data.Total-184) > 1e-9 {
+		t.Errorf("Total = %v, esperado 184 (2 x 80 + IVA 15%%)", respuesta.Data.Total)
+	}
+
+	factura = `{"ClienteNombre":"CLIENTE CATALOGO","ClienteCedula":"1713175071","Productos":[{"Codigo":"NOEXISTE","Cantidad":1}]}`
+	rr = httptest.NewRecorder()
+	server.Router().ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/facturas/db", strings.NewReader(factura)))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Status con código fuera del catálogo = %d, esperado 400", rr.Code)
+	}
+}
+
+func TestFiltroFacturasDesdeQuery(t *testing.T) {
+	query, _ := url.ParseQuery("desde=2026-01-01&hasta=2026-01-31&estado=autorizada,%20anulada&totalMin=10.5&establecimiento=002&numero=FAC-00&ordenarPor=total&orden=asc&limit=25")
+	filtro, err := filtroFacturasDesdeQuery(query)
+	if err != nil {
+		t.Fatalf("filtroFacturasDesdeQuery() error: %v", err)
+	}
+
+	if len(filtro.Estados) != 2 || filtro.Estados[0] != database.EstadoAutorizada || filtro.Estados[1] != database.EstadoAnulada {
+		t.Errorf("Estados = %v", filtro.Estados)
+	}
+	if filtro.TotalMinimo == nil || *filtro.TotalMinimo != 10.5 || filtro.TotalMaximo != nil {
+		t.Errorf("Rango de total inesperado: %v - %v", filtro.TotalMinimo, filtro.TotalMaximo)
+	}
+	// hasta con fecha sin hora incluye todo el día
+	if filtro.Hasta.Format("2006-01-02") != "2026-02-01" || filtro.Desde.Format("2006-01-02") != "2026-01-01" {
+		t.Errorf("Rango de fechas inesperado: %v - %v", filtro.Desde, filtro.Hasta)
+	}
+	if !filtro.Ascendente || filtro.OrdenarPor != "total" || filtro.Limite != 25 || filtro.Establecimiento != "002" || filtro.PrefijoNumero != "FAC-00" {
+		t.Errorf("Filtro inesperado: %+v", filtro)
+	}
+
+	for _, invalido := range []string{"desde=ayer", "totalMax=mucho", "orden=arriba"} {
+		query, _ := url.ParseQuery(invalido)
+		if _, err := filtroFacturasDesdeQuery(query); err == nil {
+			t.Errorf("filtroFacturasDesdeQuery(%s) debió fallar", invalido)
+		}
+	}
+}
+
+func TestAuditoriaRegistraActorDeLaPeticion(t *testing.T) {
+	config.CargarConfiguracionPorDefecto()
+	config.Config.Servidor.ProxiesConfiables = []string{"10.0.0.1"}
+	defer func() { config.Config.Servidor.ProxiesConfiables = nil }()
+
+	db, err := database.New(filepath.Join(t.TempDir(), "actor.db"))
+	if err != nil {
+		t.Fatalf("Error creando base de datos: %v", err)
+	}
+	defer db.Close()
+	server := NewServer("8080", db, nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/clientes", strings.NewReader(`{"cedula":"1713175071","nombre":"CLIENTE ACTOR","tipoCliente":"PERSONA_NATURAL"}`))
+	req.RemoteAddr = "10.0.0.1:443"
+	req.Header.Set("X-Forwarded-For", "198.51.100.4")
+	req.Header.Set("X-Forwarded-User", "contador@empresa.ec")
+	req.Header.Set("X-Request-ID", "req-cliente-1")
+	req.Header.Set("User-Agent", "erp/2.1")
+	rr := httptest.NewRecorder()
+	server.Router().ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Status crear cliente = %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr.Header().Get("X-Request-ID") != "req-cliente-1" {
+		t.Errorf("X-Request-ID de respuesta = %q", rr.Header().Get("X-Request-ID"))
+	}
+
+	registros, err := db.ObtenerAuditoriaPorTabla(context.Background(), "clientes", 10, 0)
+	if err != nil || len(registros) != 1 {
+		t.Fatalf("Auditoría de clientes = %d registros, %v", len(registros), err)
+	}
+	audit := registros[0]
+	if audit.Operacion != "CREATE" || audit.Usuario != "contador@empresa.ec" || audit.IPAddress != "198.51.100.4" ||
+		audit.UserAgent != "erp/2.1" || audit.RequestID != "req-cliente-1" {
+		t.Errorf("Entrada de auditoría = %+v", audit)
+	}
+}
@@ -8,20 +8,62 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
+
+	"go-facturacion-sri/database"
+	"go-facturacion-sri/pipeline"
+	"go-facturacion-sri/secrets"
+	"go-facturacion-sri/sri"
 )
 
 // Server - Estructura principal del servidor HTTP
 type Server struct {
-	port   string
-	router *http.ServeMux
+	port     string
+	router   *http.ServeMux
+	pipeline *pipeline.Pipeline // Pipeline de autorización asíncrona (opcional)
+
+	db          *database.Database // Conexión compartida por todos los handlers
+	facturas    FacturaRepository
+	clientes    ClienteRepository
+	catalogo    CatalogoRepository
+	inventario  InventarioRepository
+	busqueda    BusquedaRepository
+	anulaciones AnulacionRepository
+	tenants     TenantRepository
+
+	secretos      *secrets.Resolvedor // Resolvedor de secretos del proceso
+	claveOperador []byte              // Credencial de las rutas de operador; nil si no está configurada o no se resolvió
+
+	clientesSRI   map[sri.Ambiente]*sri.SOAPClient // Un cliente SOAP por ambiente, compartido entre peticiones
+	mutexClientes sync.Mutex
 }
 
-// NewServer - Crea una nueva instancia del servidor
-func NewServer(port string) *Server {
+// NewServer - Crea una nueva instancia del servidor con la base de datos compartida y el
+// resolvedor de secretos del proceso (nil: solo referencias env: y file:)
+func NewServer(port string, db *database.Database, secretos *secrets.Resolvedor) *Server {
+	if secretos == nil {
+		secretos = secrets.NuevoResolvedor()
+	}
 	server := &Server{
-		port:   port,
-		router: http.NewServeMux(),
+		port:     port,
+		router:   http.NewServeMux(),
+		db:       db,
+		secretos: secretos,
+	}
+	if db != nil {
+		server.facturas = db
+		server.clientes = db
+		server.catalogo = db
+		server.inventario = db
+		server.busqueda = db
+		server.anulaciones = db
+		server.tenants = db
+	}
+	if clave, err := server.claveOperadorAPI(); err != nil {
+		log.Printf("⚠️  Rutas de operador sin credencial válida: %v", err)
+	} else {
+		server.claveOperador = clave
 	}
 	
 	// Configurar rutas
@@ -30,6 +72,11 @@ func NewServer(port string) *Server {
 	return server
 }
 
+// ConfigurarPipeline habilita el envío automático de facturas al pipeline de autorización
+func (s *Server) ConfigurarPipeline(p *pipeline.Pipeline) {
+	s.pipeline = p
+}
+
 // setupRoutes - Configura todas las rutas de la API
 func (s *Server) setupRoutes() {
 	// Health check endpoint
@@ -48,11 +95,27 @@ func (s *Server) setupRoutes() {
 	s.router.HandleFunc("/api/clientes/buscar", s.BuscarClienteDB)
 	s.router.HandleFunc("/api/clientes/list", s.ListarClientesDB)
 	s.router.HandleFunc("/api/clientes/", s.handleClienteDB)
+	s.router.HandleFunc("/api/catalogo/productos", s.handleCatalogoProductos)
+	s.router.HandleFunc("/api/catalogo/productos/", s.handleProductoCatalogo)
+	s.router.HandleFunc("/api/inventario/stock", s.handleStockInventario)
+	s.router.HandleFunc("/api/inventario/movimientos", s.ListarMovimientosInventarioDB)
+	s.router.HandleFunc("/api/inventario/ajustes", s.AjustarStockDB)
+	s.router.HandleFunc("/api/inventario/notas-credito", s.RegistrarNotaCreditoDB)
+	s.router.HandleFunc("/api/buscar", s.BuscarDB)
 	s.router.HandleFunc("/api/sri/estado", s.ConsultarEstadoSRI)
 	s.router.HandleFunc("/api/sri/status", s.EstadoGeneralSRI)
+	s.router.HandleFunc("/api/sri/errores", s.CatalogoErroresSRIHandler)
+	s.router.HandleFunc("/api/sri/intercambios", s.BuscarIntercambiosSOAPDB)
+	s.router.HandleFunc("/api/sri/intercambios/", s.handleIntercambiosSOAP)
+	s.router.HandleFunc("/api/admin/sri/circuit-breaker", s.EstadoCircuitBreakerSRI)
+	s.router.HandleFunc("/api/admin/sri/circuit-breaker/reiniciar", s.ReiniciarCircuitBreakerSRI)
 	s.router.HandleFunc("/api/auditoria", s.ObtenerAuditoriaDB)
+	s.router.HandleFunc("/api/auditoria/verificar", s.VerificarAuditoriaDB)
 	s.router.HandleFunc("/api/respaldos", s.CrearRespaldoDB)
 	s.router.HandleFunc("/api/respaldos/listar", s.ListarRespaldosDB)
+	s.router.HandleFunc("/api/pipeline/trabajos", s.ListarTrabajosAutorizacionDB)
+	s.router.HandleFunc("/api/pipeline/fallidos", s.ListarFallidosDB)
+	s.router.HandleFunc("/api/pipeline/fallidos/", s.handleFallidos)
 	
 	// Servir archivos estáticos del frontend (Astro build)
 	s.setupStaticFiles()
@@ -63,10 +126,18 @@ func (s *Server) setupRoutes() {
 
 // handleFacturaDB maneja rutas dinámicas de facturas en DB
 func (s *Server) handleFacturaDB(w http.ResponseWriter, r *http.Request) {
-	if r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/pdf") {
+	if strings.Contains(r.URL.Path, "/anulacion") {
+		s.handleAnulacionFactura(w, r)
+	} else if r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/pdf") {
 		s.GenerarPDFFacturaDB(w, r)
+	} else if r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/xml") {
+		s.DescargarXMLAutorizadoDB(w, r)
+	} else if r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/historial") {
+		s.ObtenerHistorialEstadosFacturaDB(w, r)
 	} else if r.Method == http.MethodGet {
 		s.ObtenerFacturaDB(w, r)
+	} else if r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/enviar") {
+		s.EnviarFacturaSRIDB(w, r)
 	} else if r.Method == http.MethodPut && strings.HasSuffix(r.URL.Path, "/estado") {
 		s.ActualizarEstadoFacturaDB(w, r)
 	} else if r.Method == http.MethodPut {
@@ -102,6 +173,12 @@ func (s *Server) Start() error {
 		IdleTimeout:  60 * time.Second,
 	}
 	
+	// Política de retención del archivo SOAP
+	s.iniciarPurgaArchivoSOAP()
+
+	// Checkpoints firmados de la cadena de auditoría
+	s.iniciarCheckpointsAuditoria()
+
 	log.Printf("🚀 Servidor iniciado en http://localhost:%s", s.port)
 	log.Printf("📋 Health check: http://localhost:%s/health", s.port)
 	log.Printf("🌐 Frontend: http://localhost:%s/ (requiere build)", s.port)
@@ -118,7 +195,7 @@ func (s *Server) Router() http.Handler {
 
 // middlewareChain - Aplica middleware a todas las requests
 func (s *Server) middlewareChain(next http.Handler) http.Handler {
-	return s.corsMiddleware(s.loggingMiddleware(next))
+	return s.corsMiddleware(s.actorMiddleware(s.loggingMiddleware(s.tenantMiddleware(next))))
 }
 
 // ResponseWriter helper functions
@@ -0,0 +1,123 @@
+// Package api arma el emisor de las facturas (de la configuración o del tenant) y resuelve la credencial de operador
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go-facturacion-sri/config"
+	"go-facturacion-sri/database"
+	"go-facturacion-sri/factory"
+	"go-facturacion-sri/sri"
+)
+
+// leyendasRimpe leyenda contribuyenteRimpe que el SRI exige según el régimen del emisor
+var leyendasRimpe = map[string]string{
+	database.RegimenRimpeEmprendedor:    "CONTRIBUYENTE RÉGIMEN RIMPE",
+	database.RegimenRimpeNegocioPopular: "CONTRIBUYENTE NEGOCIO POPULAR - RÉGIMEN RIMPE",
+}
+
+// claveOperadorAPI resuelve servidor.claveOperador; nil si no está configurada
+func (s *Server) claveOperadorAPI() ([]byte, error) {
+	referencia := config.Config.Servidor.ClaveOperador
+	if referencia == "" {
+		return nil, nil
+	}
+
+	clave, err := s.secretos.Resolver(referencia)
+	if err != nil {
+		return nil, fmt.Errorf("error resolviendo servidor.claveOperador: %v", err)
+	}
+	return clave, nil
+}
+
+// emisorTenant reserva el siguiente secuencial de un establecimiento del tenant y arma el emisor
+// con su clave de acceso. El establecimiento se elige con los parámetros establecimiento y
+// puntoEmision; sin ellos se usa el primero activo del tenant.
+func (s *Server) emisorTenant(r *http.Request, tenantID int) (factory.Emisor, error) {
+	tenant, err := s.tenants.ObtenerTenant(tenantID)
+	if err != nil {
+		return factory.Emisor{}, err
+	}
+
+	codigo := r.URL.Query().Get("establecimiento")
+	puntoEmision := r.URL.Query().Get("puntoEmision")
+	if codigo == "" && puntoEmision == "" {
+		for _, establecimiento := range tenant.Establecimientos {
+			if establecimiento.Activo {
+				codigo, puntoEmision = establecimiento.Codigo, establecimiento.PuntoEmision
+				break
+			}
+		}
+	}
+
+	establecimiento, err := s.tenants.ReservarSecuencialTenant(r.Context(), codigo, puntoEmision)
+	if err != nil {
+		return factory.Emisor{}, err
+	}
+
+	emisor := factory.Emisor{
+		RUC:                   tenant.RUC,
+		RazonSocial:           tenant.RazonSocial,
+		NombreComercial:       tenant.NombreComercial,
+		DirMatriz:             tenant.DireccionMatriz,
+		DirEstablecimiento:    establecimiento.Direccion,
+		Establecimiento:       establecimiento.Codigo,
+		PuntoEmision:          establecimiento.PuntoEmision,
+		Ambiente:              tenant.Ambiente,
+		TipoEmision:           tenant.TipoEmision,
+		ContribuyenteEspecial: tenant.ContribuyenteEspecial,
+		ObligadoContabilidad:  "NO",
+		ContribuyenteRimpe:    leyendasRimpe[tenant.Regimen],
+		Secuencial:            fmt.Sprintf("%09d", establecimiento.Secuencial),
+	}
+	if tenant.ObligadoContabilidad {
+		emisor.ObligadoContabilidad = "SI"
+	}
+	if establecimiento.Direccion == "" {
+		emisor.DirEstablecimiento = tenant.DireccionMatriz
+	}
+
+	emisor.ClaveAcceso, err = claveAccesoEmisor(emisor)
+	if err != nil {
+		return factory.Emisor{}, err
+	}
+	return emisor, nil
+}
+
+// emisorConfig reserva el siguiente secuencial persistido del emisor de la configuración para
+// su establecimiento y punto de emisión, y arma el emisor con su clave de acceso
+func (s *Server) emisorConfig(ctx context.Context) (factory.Emisor, error) {
+	emisor := factory.EmisorDesdeConfig()
+	secuencial, err := s.facturas.ReservarSecuencialEmisor(ctx, emisor.RUC, emisor.Establecimiento, emisor.PuntoEmision)
+	if err != nil {
+		return factory.Emisor{}, err
+	}
+
+	emisor.Secuencial = fmt.Sprintf("%09d", secuencial)
+	emisor.ClaveAcceso, err = claveAccesoEmisor(emisor)
+	if err != nil {
+		return factory.Emisor{}, err
+	}
+	return emisor, nil
+}
+
+// claveAccesoEmisor genera la clave de acceso de una factura con el RUC, ambiente, serie y
+// secuencial del emisor
+func claveAccesoEmisor(emisor factory.Emisor) (string, error) {
+	clave, err := sri.GenerarClaveAcceso(sri.ClaveAccesoConfig{
+		FechaEmision:     time.Now(),
+		TipoComprobante:  sri.Factura,
+		RUCEmisor:        emisor.RUC,
+		Ambiente:         sri.AmbienteDesdeCodigo(emisor.Ambiente),
+		Serie:            emisor.Establecimiento + emisor.PuntoEmision,
+		NumeroSecuencial: emisor.Secuencial,
+		TipoEmision:      sri.EmisionNormal,
+	})
+	if err != nil {
+		return "", fmt.Errorf("error generando clave de acceso: %v", err)
+	}
+	return clave, nil
+}
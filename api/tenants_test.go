@@ -0,0 +1,261 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go-facturacion-sri/config"
+	"go-facturacion-sri/database"
+	"go-facturacion-sri/sri"
+)
+
+// servidorMultiTenant servidor sobre una base con dos tenants y una credencial de API para cada uno
+func servidorMultiTenant(t *testing.T) (*Server, *database.Database, [2]*database.TenantDB, [2]string) {
+	t.Helper()
+	config.CargarConfiguracionPorDefecto()
+
+	db, err := database.New(filepath.Join(t.TempDir(), "tenants.db"))
+	if err != nil {
+		t.Fatalf("Error creando base de datos: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	var tenants [2]*database.TenantDB
+	var claves [2]string
+	for i, ruc := range []string{"1792146739001", "0990000000001"} {
+		tenant, err := db.CrearTenant(context.Background(), &database.TenantDB{
+			RUC:             ruc,
+			RazonSocial:     fmt.Sprintf("EMPRESA %d S.A.", i+1),
+			DireccionMatriz: "Av. 9 de Octubre 100, Guayaquil",
+			Regimen:         database.RegimenRimpeEmprendedor,
+			Establecimientos: []*database.EstablecimientoTenantDB{
+				{Codigo: "002", PuntoEmision: "001", Direccion: "Local 2", Secuencial: 41},
+			},
+		})
+		if err != nil {
+			t.Fatalf("CrearTenant() error: %v", err)
+		}
+		clave, _, err := db.EmitirCredencialTenant(context.Background(), tenant.ID, fmt.Sprintf("erp-%d", i+1))
+		if err != nil {
+			t.Fatalf("EmitirCredencialTenant() error: %v", err)
+		}
+		tenants[i], claves[i] = tenant, clave
+	}
+	return NewServer("8080", db, nil), db, tenants, claves
+}
+
+// peticionTenant ejecuta la petición con la credencial indicada en Authorization: Bearer
+func peticionTenant(server *Server, metodo, ruta, clave, cuerpo string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(metodo, ruta, strings.NewReader(cuerpo))
+	if clave != "" {
+		req.Header.Set("Authorization", "Bearer "+clave)
+	}
+	rr := httptest.NewRecorder()
+	server.Router().ServeHTTP(rr, req)
+	return rr
+}
+
+func TestTenantMiddlewareCredenciales(t *testing.T) {
+	server, _, _, claves := servidorMultiTenant(t)
+	defer func() { config.Config.Servidor.RequerirCredenciales = false }()
+
+	// Sin credencial se opera como el emisor de la configuración
+	if rr := peticionTenant(server, http.MethodGet, "/api/facturas/db/list", "", ""); rr.Code != http.StatusOK {
+		t.Errorf("Status sin credencial = %d, esperado 200", rr.Code)
+	}
+	if rr := peticionTenant(server, http.MethodGet, "/api/facturas/db/list", "fsri_invalida", ""); rr.Code != http.StatusUnauthorized {
+		t.Errorf("Status con credencial inválida = %d, esperado 401", rr.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/facturas/db/list", nil)
+	req.Header.Set("X-API-Key", claves[0])
+	rr := httptest.NewRecorder()
+	server.Router().ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Errorf("Status con X-API-Key = %d, esperado 200", rr.Code)
+	}
+
+	// Las rutas del operador exponen datos de todos los emisores
+	for _, ruta := range []string{"/api/respaldos/listar", "/api/pipeline/trabajos", "/api/auditoria/verificar", "/api/sri/intercambios"} {
+		if rr := peticionTenant(server, http.MethodGet, ruta, claves[0], ""); rr.Code != http.StatusForbidden {
+			t.Errorf("Status de %s con credencial de tenant = %d, esperado 403", ruta, rr.Code)
+		}
+	}
+
+	// Con tenants registrados las rutas de operador no aceptan peticiones anónimas
+	for _, ruta := range []string{"/api/respaldos/listar", "/api/pipeline/trabajos", "/api/sri/intercambios"} {
+		if rr := peticionTenant(server, http.MethodGet, ruta, "", ""); rr.Code != http.StatusUnauthorized {
+			t.Errorf("Status de %s sin credencial = %d, esperado 401", ruta, rr.Code)
+		}
+	}
+
+	config.Config.Servidor.RequerirCredenciales = true
+	if rr := peticionTenant(server, http.MethodGet, "/api/facturas/db/list", "", ""); rr.Code != http.StatusUnauthorized {
+		t.Errorf("Status sin credencial requerida = %d, esperado 401", rr.Code)
+	}
+	if rr := peticionTenant(server, http.MethodGet, "/health", "", ""); rr.Code != http.StatusOK {
+		t.Errorf("Status de /health sin credencial = %d, esperado 200", rr.Code)
+	}
+}
+
+func TestCrearFacturaConCredencialDeTenant(t *testing.T) {
+	server, db, tenants, claves := servidorMultiTenant(t)
+
+	cuerpo := `{"ClienteNombre":"CLIENTE TENANT","ClienteCedula":"1713175071","Productos":[{"Codigo":"P1","Descripcion":"Servicio","Cantidad":1,"PrecioUnitario":50}]}`
+	rr := peticionTenant(server, http.MethodPost, "/api/facturas/db?includeXML=true", claves[0], cuerpo)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Status crear factura = %d: %s", rr.Code, rr.Body.String())
+	}
+	var respuesta struct {
+		Data struct {
+			ID            int    `json:"id"`
+			NumeroFactura string `json:"numero_factura"`
+			ClaveAcceso   string `json:"clave_acceso"`
+			XML           string `json:"xml"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&respuesta); err != nil {
+		t.Fatalf("Respuesta no es JSON: %v", err)
+	}
+
+	// La clave de acceso y el XML llevan el RUC, la serie y el secuencial del tenant
+	clave, err := sri.ParsearClaveAcceso(respuesta.Data.ClaveAcceso)
+	if err != nil {
+		t.Fatalf("Clave de acceso inválida %s: %v", respuesta.Data.ClaveAcceso, err)
+	}
+	if clave.RUCEmisor != tenants[0].RUC || clave.Serie != "002001" || clave.NumeroSecuencial != "000000042" {
+		t.Errorf("Clave de acceso inesperada: %+v", clave)
+	}
+	for _, fragmento := range []string{
+		"<ruc>" + tenants[0].RUC + "</ruc>",
+		"<claveAcceso>" + respuesta.Data.ClaveAcceso + "</claveAcceso>",
+		"<secuencial>000000042</secuencial>",
+		"<contribuyenteRimpe>CONTRIBUYENTE RÉGIMEN RIMPE</contribuyenteRimpe>",
+		"<dirEstablecimiento>Local 2</dirEstablecimiento>",
+	} {
+		if !strings.Contains(respuesta.Data.XML, fragmento) {
+			t.Errorf("El XML no contiene %s", fragmento)
+		}
+	}
+	if respuesta.Data.NumeroFactura != "FAC-000001" {
+		t.Errorf("Número de factura = %s, esperado FAC-000001", respuesta.Data.NumeroFactura)
+	}
+
+	// El otro tenant no ve la factura
+	ruta := fmt.Sprintf("/api/facturas/db/%d", respuesta.Data.ID)
+	for _, r := range []string{ruta, ruta + "/historial"} {
+		if rr := peticionTenant(server, http.MethodGet, r, claves[1], ""); rr.Code != http.StatusNotFound {
+			t.Errorf("Status de %s desde otro tenant = %d, esperado 404", r, rr.Code)
+		}
+	}
+	if rr := peticionTenant(server, http.MethodDelete, ruta, claves[1], ""); rr.Code == http.StatusOK {
+		t.Errorf("El otro tenant eliminó la factura: %s", rr.Body.String())
+	}
+	rr = peticionTenant(server, http.MethodGet, "/api/facturas/db/list", claves[1], "")
+	var lista struct {
+		Data struct {
+			Total int `json:"total"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&lista); err != nil || lista.Data.Total != 0 {
+		t.Errorf("Listado del otro tenant = %d facturas, %v", lista.Data.Total, err)
+	}
+	if rr := peticionTenant(server, http.MethodGet, ruta, claves[0], ""); rr.Code != http.StatusOK {
+		t.Errorf("Status de la factura propia = %d, esperado 200", rr.Code)
+	}
+
+	// La auditoría registra el tenant y el nombre de la credencial
+	registros, err := db.ObtenerAuditoriaPorRegistro(database.ConTenant(context.Background(), tenants[0].ID), "facturas", respuesta.Data.ID)
+	if err != nil || len(registros) == 0 {
+		t.Fatalf("Auditoría de la factura = %d registros, %v", len(registros), err)
+	}
+	if registros[0].TenantID != tenants[0].ID || registros[0].Usuario != "erp-1" {
+		t.Errorf("Entrada de auditoría = %+v", registros[0])
+	}
+}
+
+func TestCatalogoEInventarioPorTenant(t *testing.T) {
+	server, _, _, claves := servidorMultiTenant(t)
+
+	// Ambos tenants registran el mismo código en su propio catálogo
+	cuerpo := `{"codigoPrincipal":"INV001","descripcion":"Producto con stock","precioUnitario":12.5,"controlaInventario":true}`
+	var ids [2]int
+	for i, clave := range claves {
+		rr := peticionTenant(server, http.MethodPost, "/api/catalogo/productos", clave, cuerpo)
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("Status crear producto del tenant %d = %d: %s", i+1, rr.Code, rr.Body.String())
+		}
+		var respuesta struct {
+			Data database.ProductoCatalogoDB `json:"data"`
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&respuesta); err != nil {
+			t.Fatalf("Respuesta no es JSON: %v", err)
+		}
+		ids[i] = respuesta.Data.ID
+	}
+
+	ruta := fmt.Sprintf("/api/catalogo/productos/%d", ids[0])
+	if rr := peticionTenant(server, http.MethodGet, ruta, claves[1], ""); rr.Code != http.StatusNotFound {
+		t.Errorf("Status del producto de otro tenant = %d, esperado 404", rr.Code)
+	}
+	if rr := peticionTenant(server, http.MethodGet, ruta, claves[0], ""); rr.Code != http.StatusOK {
+		t.Errorf("Status del producto propio = %d, esperado 200", rr.Code)
+	}
+
+	ajuste := fmt.Sprintf(`{"productoId":%d,"establecimiento":"002","cantidad":7,"motivo":"Inventario inicial"}`, ids[0])
+	if rr := peticionTenant(server, http.MethodPost, "/api/inventario/ajustes", claves[1], ajuste); rr.Code == http.StatusCreated {
+		t.Errorf("El otro tenant ajustó el stock: %s", rr.Body.String())
+	}
+	if rr := peticionTenant(server, http.MethodPost, "/api/inventario/ajustes", claves[0], ajuste); rr.Code != http.StatusCreated {
+		t.Fatalf("Status ajuste propio = %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// Stock y kardex solo muestran los registros del tenant de la credencial
+	for i, propio := range []bool{true, false} {
+		for _, r := range []string{"/api/inventario/stock", "/api/inventario/movimientos"} {
+			rr := peticionTenant(server, http.MethodGet, r, claves[i], "")
+			if rr.Code != http.StatusOK {
+				t.Fatalf("Status de %s del tenant %d = %d", r, i+1, rr.Code)
+			}
+			contiene := strings.Contains(rr.Body.String(), fmt.Sprintf(`"productoId":%d`, ids[0]))
+			if contiene != propio {
+				t.Errorf("%s del tenant %d: %s", r, i+1, rr.Body.String())
+			}
+		}
+	}
+}
+
+func TestRutasOperadorConClaveOperador(t *testing.T) {
+	config.CargarConfiguracionPorDefecto()
+	config.Config.Servidor.ClaveOperador = "clave-operador-prueba"
+	defer func() { config.Config.Servidor.ClaveOperador = "" }()
+
+	db, err := database.New(filepath.Join(t.TempDir(), "operador.db"))
+	if err != nil {
+		t.Fatalf("Error creando base de datos: %v", err)
+	}
+	defer db.Close()
+	server := NewServer("8080", db, nil)
+
+	// Con clave de operador configurada no hay acceso anónimo aunque no existan tenants
+	if rr := peticionTenant(server, http.MethodGet, "/api/respaldos/listar", "", ""); rr.Code != http.StatusUnauthorized {
+		t.Errorf("Status sin credencial = %d, esperado 401", rr.Code)
+	}
+	if rr := peticionTenant(server, http.MethodGet, "/api/respaldos/listar", "otra-clave", ""); rr.Code != http.StatusUnauthorized {
+		t.Errorf("Status con clave incorrecta = %d, esperado 401", rr.Code)
+	}
+	if rr := peticionTenant(server, http.MethodGet, "/api/respaldos/listar", "clave-operador-prueba", ""); rr.Code == http.StatusUnauthorized || rr.Code == http.StatusForbidden {
+		t.Errorf("Status con clave de operador = %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// Las demás rutas siguen operando como el emisor de la configuración
+	if rr := peticionTenant(server, http.MethodGet, "/api/facturas/db/list", "", ""); rr.Code != http.StatusOK {
+		t.Errorf("Status de facturas sin credencial = %d, esperado 200", rr.Code)
+	}
+}
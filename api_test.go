@@ -14,7 +14,7 @@ import (
 // setupAPITest - Configura el servidor para tests
 func setupAPITest() *api.Server {
 	config.CargarConfiguracionPorDefecto()
-	return api.NewServer("8080")
+	return api.NewServer("8080", nil, nil)
 }
 
 // TestHealthEndpoint - Prueba el endpoint de health check
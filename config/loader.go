@@ -1,8 +1,11 @@
 package config
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"fmt"
+	"net/url"
 	"os"
 	"strconv"
 	"sync/atomic"
@@ -63,6 +66,50 @@ func validarConfiguracion() error {
 		return fmt.Errorf("código de ambiente debe ser '1' (pruebas) o '2' (producción)")
 	}
 	
+	// Validar red hacia el SRI
+	if Config.SRI.Red.ProxyURL != "" {
+		if proxy, err := url.Parse(Config.SRI.Red.ProxyURL); err != nil || proxy.Host == "" {
+			return fmt.Errorf("sri.red.proxyURL inválida: %s", Config.SRI.Red.ProxyURL)
+		}
+	}
+	
+	if (Config.SRI.Red.CertificadoCliente == "") != (Config.SRI.Red.LlaveCliente == "") {
+		return fmt.Errorf("sri.red.certificadoCliente y sri.red.llaveCliente deben configurarse juntos")
+	}
+
+	// Las CA y el certificado de cliente se cargan al arrancar para no caer en silencio
+	// a un transporte sin ellos
+	for _, ruta := range Config.SRI.Red.CAsAdicionales {
+		pem, err := os.ReadFile(ruta)
+		if err != nil {
+			return fmt.Errorf("sri.red.casAdicionales: error leyendo %s: %v", ruta, err)
+		}
+		if !x509.NewCertPool().AppendCertsFromPEM(pem) {
+			return fmt.Errorf("sri.red.casAdicionales: %s no contiene certificados PEM válidos", ruta)
+		}
+	}
+
+	if Config.SRI.Red.CertificadoCliente != "" {
+		if _, err := tls.LoadX509KeyPair(Config.SRI.Red.CertificadoCliente, Config.SRI.Red.LlaveCliente); err != nil {
+			return fmt.Errorf("sri.red.certificadoCliente: error cargando certificado y llave: %v", err)
+		}
+	}
+	
+	if Config.SRI.Red.OmitirVerificacionTLS && Config.Ambiente.Codigo == "2" {
+		return fmt.Errorf("sri.red.omitirVerificacionTLS no está permitido en producción")
+	}
+	
+	// Validar base de datos
+	switch Config.Database.Driver {
+	case "", "sqlite3", "postgres":
+	default:
+		return fmt.Errorf("database.driver debe ser 'sqlite3' o 'postgres', no '%s'", Config.Database.Driver)
+	}
+	
+	if Config.Database.Driver == "postgres" && Config.Database.DSN == "" {
+		return fmt.Errorf("database.dsn es requerido con el driver postgres")
+	}
+	
 	// Aplicar valores por defecto para campos opcionales
 	aplicarValoresPorDefecto()
 	
@@ -81,14 +128,66 @@ func aplicarValoresPorDefecto() {
 	if Config.SRI.PolicyID == "" {
 		Config.SRI.PolicyID = "https://www.sri.gob.ec/politica-de-firma"
 	}
+	if Config.SRI.ArchivoSOAP.RetencionDias == 0 {
+		Config.SRI.ArchivoSOAP.RetencionDias = 180
+	}
+	aplicarLimitePorDefecto(&Config.SRI.Limites.Pruebas, LimiteSRIConfig{PeticionesPorSegundo: 5, Rafaga: 10, MaxConcurrentes: 4})
+	aplicarLimitePorDefecto(&Config.SRI.Limites.Produccion, LimiteSRIConfig{PeticionesPorSegundo: 10, Rafaga: 20, MaxConcurrentes: 8})
+	if Config.SRI.Red.MaxConexionesInactivas == 0 {
+		Config.SRI.Red.MaxConexionesInactivas = 20
+	}
+	if Config.SRI.Red.MaxConexionesPorHost == 0 {
+		Config.SRI.Red.MaxConexionesPorHost = 10
+	}
+	if Config.SRI.Red.KeepAliveSegundos == 0 {
+		Config.SRI.Red.KeepAliveSegundos = 30
+	}
+	if Config.SRI.Red.InactividadSegundos == 0 {
+		Config.SRI.Red.InactividadSegundos = 90
+	}
+	if Config.SRI.Red.TimeoutHandshakeSegundos == 0 {
+		Config.SRI.Red.TimeoutHandshakeSegundos = 10
+	}
 	
 	// Database defaults
+	if Config.Database.Driver == "" {
+		Config.Database.Driver = "sqlite3"
+	}
 	if Config.Database.Ruta == "" {
-		Config.Database.Ruta = "./facturacion.db"
+		Config.Database.Ruta = "database/facturacion.db"
 	}
 	if Config.Database.MaxConexiones == 0 {
 		Config.Database.MaxConexiones = 10
 	}
+
+	// Pipeline de autorización defaults
+	if Config.Pipeline.Workers == 0 {
+		Config.Pipeline.Workers = 2
+	}
+	if Config.Pipeline.IntervaloSondeoSegundos == 0 {
+		Config.Pipeline.IntervaloSondeoSegundos = 2
+	}
+	if Config.Pipeline.MaxIntentos == 0 {
+		Config.Pipeline.MaxIntentos = 8
+	}
+	if Config.Pipeline.EsperaAutorizacionSegundos == 0 {
+		Config.Pipeline.EsperaAutorizacionSegundos = 3
+	}
+	if Config.Pipeline.MaxReencolados == nil {
+		maxReencolados := 3
+		Config.Pipeline.MaxReencolados = &maxReencolados
+	}
+	if Config.Pipeline.EsperaReencoladoMinutos == 0 {
+		Config.Pipeline.EsperaReencoladoMinutos = 15
+	}
+	
+	// Checkpoints de auditoría defaults
+	if Config.Auditoria.IntervaloCheckpointMinutos == 0 {
+		Config.Auditoria.IntervaloCheckpointMinutos = 60
+	}
+	if Config.Servidor.EncabezadoUsuario == "" {
+		Config.Servidor.EncabezadoUsuario = "X-Forwarded-User"
+	}
 	
 	// Endpoints según ambiente
 	if Config.Ambiente.Codigo == "1" {
@@ -110,6 +209,19 @@ func aplicarValoresPorDefecto() {
 	}
 }
 
+// aplicarLimitePorDefecto completa los campos en cero de un límite SRI
+func aplicarLimitePorDefecto(limite *LimiteSRIConfig, defecto LimiteSRIConfig) {
+	if limite.PeticionesPorSegundo == 0 {
+		limite.PeticionesPorSegundo = defecto.PeticionesPorSegundo
+	}
+	if limite.Rafaga == 0 {
+		limite.Rafaga = defecto.Rafaga
+	}
+	if limite.MaxConcurrentes == 0 {
+		limite.MaxConcurrentes = defecto.MaxConcurrentes
+	}
+}
+
 // CargarConfiguracionPorDefecto - Carga configuración de desarrollo si no existe archivo
 func CargarConfiguracionPorDefecto() {
 	Config = FacturacionConfig{
@@ -129,9 +241,14 @@ func CargarConfiguracionPorDefecto() {
 			TimeoutSegundos: 30,
 			MaxReintentos:   3,
 			PolicyID:        "https://www.sri.gob.ec/politica-de-firma",
+			ArchivoSOAP: ArchivoSOAPConfig{
+				Habilitado: true,
+				Comprimir:  true,
+			},
 		},
 		Database: DatabaseConfig{
-			Ruta:          "./facturacion.db",
+			Driver:        "sqlite3",
+			Ruta:          "database/facturacion.db",
 			MaxConexiones: 10,
 		},
 	}
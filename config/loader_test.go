@@ -93,6 +93,80 @@ func TestCargarConfiguracion_ArchivoValido(t *testing.T) {
 	Config = originalConfig
 }
 
+// TestCargarConfiguracion_MaxReencolados verifica que 0 desactive el reencolado y que sin valor se use 3
+func TestCargarConfiguracion_MaxReencolados(t *testing.T) {
+	originalConfig := Config
+	defer func() { Config = originalConfig }()
+
+	base := `"empresa": {"razonSocial": "EMPRESA TEST S.A.", "ruc": "9876543210001", "establecimiento": "001", "puntoEmision": "001"},
+		"ambiente": {"codigo": "1", "tipoEmision": "1"}`
+	tests := []struct {
+		nombre   string
+		json     string
+		esperado int
+	}{
+		{"sin valor", "{" + base + "}", 3},
+		{"desactivado", "{" + base + `, "pipeline": {"maxReencolados": 0}}`, 0},
+		{"explícito", "{" + base + `, "pipeline": {"maxReencolados": 5}}`, 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.nombre, func(t *testing.T) {
+			configFile := filepath.Join(t.TempDir(), "config.json")
+			if err := os.WriteFile(configFile, []byte(tt.json), 0644); err != nil {
+				t.Fatalf("Error creando archivo de configuración de prueba: %v", err)
+			}
+
+			Config = FacturacionConfig{}
+			if err := CargarConfiguracion(configFile); err != nil {
+				t.Fatalf("Error cargando configuración: %v", err)
+			}
+			if Config.Pipeline.MaxReencolados == nil || *Config.Pipeline.MaxReencolados != tt.esperado {
+				t.Errorf("MaxReencolados = %v, quería %d", Config.Pipeline.MaxReencolados, tt.esperado)
+			}
+		})
+	}
+}
+
+// TestCargarConfiguracion_RedSRIArchivosInvalidos verifica que una CA o certificado de cliente
+// ilegible detenga el arranque
+func TestCargarConfiguracion_RedSRIArchivosInvalidos(t *testing.T) {
+	originalConfig := Config
+	defer func() { Config = originalConfig }()
+
+	dir := t.TempDir()
+	noPEM := filepath.Join(dir, "no-pem.pem")
+	if err := os.WriteFile(noPEM, []byte("no es un certificado"), 0600); err != nil {
+		t.Fatalf("Error escribiendo archivo de prueba: %v", err)
+	}
+
+	base := `"empresa": {"razonSocial": "EMPRESA TEST S.A.", "ruc": "9876543210001", "establecimiento": "001", "puntoEmision": "001"},
+		"ambiente": {"codigo": "1", "tipoEmision": "1"}`
+	tests := []struct {
+		nombre string
+		red    string
+	}{
+		{"CA inexistente", `{"casAdicionales": ["` + filepath.Join(dir, "no-existe.pem") + `"]}`},
+		{"CA sin certificados", `{"casAdicionales": ["` + noPEM + `"]}`},
+		{"certificado de cliente inválido", `{"certificadoCliente": "` + noPEM + `", "llaveCliente": "` + noPEM + `"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.nombre, func(t *testing.T) {
+			configFile := filepath.Join(t.TempDir(), "config.json")
+			contenido := "{" + base + `, "sri": {"red": ` + tt.red + "}}"
+			if err := os.WriteFile(configFile, []byte(contenido), 0644); err != nil {
+				t.Fatalf("Error creando archivo de configuración de prueba: %v", err)
+			}
+
+			Config = FacturacionConfig{}
+			if err := CargarConfiguracion(configFile); err == nil {
+				t.Error("CargarConfiguracion() debería fallar")
+			}
+		})
+	}
+}
+
 // TestCargarConfiguracion_ArchivoInexistente verifica error con archivo inexistente
 func TestCargarConfiguracion_ArchivoInexistente(t *testing.T) {
 	err := CargarConfiguracion("/archivo/que/no/existe.json")
@@ -0,0 +1,42 @@
+// Package database transporta en el contexto quién origina cada escritura auditada
+package database
+
+import "context"
+
+// ActorAuditoria identifica el origen de una petición: se copia en cada entrada de audit_log
+type ActorAuditoria struct {
+	Usuario     string `json:"usuario"`
+	Autenticado bool   `json:"autenticado"` // Usuario verificado (no declarado por el cliente)
+	IPAddress   string `json:"ipAddress"`
+	UserAgent   string `json:"userAgent"`
+	RequestID   string `json:"requestId"`
+}
+
+// claveActorAuditoria clave privada del actor en context.Context
+type claveActorAuditoria struct{}
+
+// ConActorAuditoria retorna un contexto que lleva el actor de la petición
+func ConActorAuditoria(ctx context.Context, actor ActorAuditoria) context.Context {
+	return context.WithValue(ctx, claveActorAuditoria{}, actor)
+}
+
+// ActorAuditoriaDe obtiene el actor del contexto; vacío en procesos internos sin petición
+func ActorAuditoriaDe(ctx context.Context) ActorAuditoria {
+	if ctx == nil {
+		return ActorAuditoria{}
+	}
+	actor, _ := ctx.Value(claveActorAuditoria{}).(ActorAuditoria)
+	return actor
+}
+
+// usuarioAuditoria resuelve el usuario de una operación: el indicado explícitamente, el del
+// contexto o ActorSistema
+func usuarioAuditoria(ctx context.Context, usuario string) string {
+	if usuario != "" {
+		return usuario
+	}
+	if actor := ActorAuditoriaDe(ctx); actor.Usuario != "" {
+		return actor.Usuario
+	}
+	return ActorSistema
+}
@@ -2,6 +2,7 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
@@ -9,9 +10,9 @@ import (
 )
 
 // ActualizarCliente actualiza un cliente existente
-func (d *Database) ActualizarCliente(cliente *ClienteDB) (*ClienteDB, error) {
+func (d *Database) ActualizarCliente(ctx context.Context, cliente *ClienteDB) (*ClienteDB, error) {
 	// Registrar auditoría - obtener datos antes
-	clienteAntes, err := d.ObtenerClientePorID(cliente.ID)
+	clienteAntes, err := d.ObtenerClientePorID(ctx, cliente.ID)
 	if err != nil {
 		return nil, fmt.Errorf("cliente no encontrado: %v", err)
 	}
@@ -19,7 +20,7 @@ func (d *Database) ActualizarCliente(cliente *ClienteDB) (*ClienteDB, error) {
 	query := `
 		UPDATE clientes 
 		SET cedula = ?, nombre = ?, direccion = ?, telefono = ?, email = ?, tipo_cliente = ?
-		WHERE id = ? AND activo = 1`
+		WHERE id = ? AND activo = TRUE`
 
 	_, err = d.db.Exec(query, cliente.Cedula, cliente.Nombre, cliente.Direccion, 
 		cliente.Telefono, cliente.Email, cliente.TipoCliente, cliente.ID)
@@ -28,7 +29,7 @@ func (d *Database) ActualizarCliente(cliente *ClienteDB) (*ClienteDB, error) {
 	}
 
 	// Obtener cliente actualizado
-	clienteActualizado, err := d.ObtenerClientePorID(cliente.ID)
+	clienteActualizado, err := d.ObtenerClientePorID(ctx, cliente.ID)
 	if err != nil {
 		return nil, fmt.Errorf("error obteniendo cliente actualizado: %v", err)
 	}
@@ -40,24 +41,23 @@ func (d *Database) ActualizarCliente(cliente *ClienteDB) (*ClienteDB, error) {
 		Tabla:        "clientes",
 		RegistroID:   cliente.ID,
 		Operacion:    "UPDATE",
-		Usuario:      "system", // TODO: obtener usuario real
 		DatosAntes:   string(datosAntes),
 		DatosDespues: string(datosDespues),
 	}
-	d.RegistrarAuditoria(audit)
+	d.RegistrarAuditoria(ctx, audit)
 
 	return clienteActualizado, nil
 }
 
 // DesactivarCliente marca un cliente como inactivo (soft delete)
-func (d *Database) DesactivarCliente(id int) error {
+func (d *Database) DesactivarCliente(ctx context.Context, id int) error {
 	// Verificar que el cliente existe
-	cliente, err := d.ObtenerClientePorID(id)
+	cliente, err := d.ObtenerClientePorID(ctx, id)
 	if err != nil {
 		return fmt.Errorf("cliente no encontrado: %v", err)
 	}
 
-	query := `UPDATE clientes SET activo = 0 WHERE id = ?`
+	query := `UPDATE clientes SET activo = FALSE WHERE id = ?`
 	_, err = d.db.Exec(query, id)
 	if err != nil {
 		return fmt.Errorf("error desactivando cliente: %v", err)
@@ -73,19 +73,18 @@ func (d *Database) DesactivarCliente(id int) error {
 		Tabla:        "clientes",
 		RegistroID:   id,
 		Operacion:    "DEACTIVATE",
-		Usuario:      "system", // TODO: obtener usuario real
 		DatosAntes:   string(datosAntes),
 		DatosDespues: string(datosDespues),
 	}
-	d.RegistrarAuditoria(audit)
+	d.RegistrarAuditoria(ctx, audit)
 
 	return nil
 }
 
 // EliminarCliente elimina completamente un cliente (hard delete)
-func (d *Database) EliminarCliente(id int) error {
+func (d *Database) EliminarCliente(ctx context.Context, id int) error {
 	// Obtener cliente para auditoría
-	cliente, err := d.ObtenerClientePorID(id)
+	cliente, err := d.ObtenerClientePorID(ctx, id)
 	if err != nil {
 		return fmt.Errorf("cliente no encontrado: %v", err)
 	}
@@ -102,26 +101,27 @@ func (d *Database) EliminarCliente(id int) error {
 		Tabla:      "clientes",
 		RegistroID: id,
 		Operacion:  "DELETE",
-		Usuario:    "system", // TODO: obtener usuario real
 		DatosAntes: string(datosAntes),
 	}
-	d.RegistrarAuditoria(audit)
+	d.RegistrarAuditoria(ctx, audit)
 
 	return nil
 }
 
-// ListarFacturasPorCliente obtiene facturas de un cliente específico
-func (d *Database) ListarFacturasPorCliente(cedula string, limite, offset int) ([]*FacturaDB, error) {
+// ListarFacturasPorCliente obtiene facturas de un cliente específico del tenant del contexto
+func (d *Database) ListarFacturasPorCliente(ctx context.Context, cedula string, limite, offset int) ([]*FacturaDB, error) {
+	filtro, args := filtroTenant(ctx, "tenant_id")
 	query := `
 		SELECT id, numero_factura, clave_acceso, cliente_cedula, cliente_nombre,
 			   subtotal, iva, total, estado, fecha_emision, fecha_creacion,
-			   numero_autorizacion, fecha_autorizacion, observaciones_sri, xml_original, xml_autorizado
+			   numero_autorizacion, fecha_autorizacion, observaciones_sri, xml_original, xml_autorizado, tenant_id
 		FROM facturas 
-		WHERE cliente_cedula = ?
+		WHERE cliente_cedula = ?` + filtro + `
 		ORDER BY fecha_creacion DESC 
 		LIMIT ? OFFSET ?`
 
-	rows, err := d.db.Query(query, cedula, limite, offset)
+	args = append([]interface{}{cedula}, append(args, limite, offset)...)
+	rows, err := d.db.Query(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("error listando facturas por cliente: %v", err)
 	}
@@ -139,7 +139,7 @@ func (d *Database) ListarFacturasPorCliente(cedula string, limite, offset int) (
 			&factura.Subtotal, &factura.IVA, &factura.Total,
 			&factura.Estado, &factura.FechaEmision, &factura.FechaCreacion,
 			&numeroAutorizacion, &fechaAutorizacion, &observacionesSRI,
-			&xmlOriginal, &xmlAutorizado,
+			&xmlOriginal, &xmlAutorizado, &factura.TenantID,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("error escaneando factura: %v", err)
@@ -172,9 +172,9 @@ func (d *Database) ListarFacturasPorCliente(cedula string, limite, offset int) (
 }
 
 // ActualizarFactura actualiza una factura completa (solo en estado BORRADOR)
-func (d *Database) ActualizarFactura(id int, clienteCedula, clienteNombre string, productos []ProductoDB, observaciones string) (*FacturaDB, error) {
+func (d *Database) ActualizarFactura(ctx context.Context, id int, clienteCedula, clienteNombre string, productos []ProductoDB, observaciones string) (*FacturaDB, error) {
 	// Verificar que la factura existe y está en estado BORRADOR
-	facturaAntes, err := d.ObtenerFacturaPorID(id)
+	facturaAntes, err := d.ObtenerFacturaPorID(ctx, id)
 	if err != nil {
 		return nil, fmt.Errorf("factura no encontrada: %v", err)
 	}
@@ -215,15 +215,16 @@ func (d *Database) ActualizarFactura(id int, clienteCedula, clienteNombre string
 		return nil, fmt.Errorf("error eliminando productos existentes: %v", err)
 	}
 
-	// Insertar nuevos productos
+	// Insertar nuevos productos; el subtotal de cada línea va en precio_total_sin_iva
 	queryProducto := `
-		INSERT INTO productos (factura_id, codigo, descripcion, cantidad, precio_unitario, descuento, subtotal)
-		VALUES (?, ?, ?, ?, ?, ?, ?)`
+		INSERT INTO productos (factura_id, codigo, descripcion, cantidad, precio_unitario, descuento,
+		                       precio_total_sin_iva, precio_total, iva)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, 0)`
 
 	for _, producto := range productos {
 		subtotalProducto := producto.Cantidad * producto.PrecioUnitario
 		_, err = tx.Exec(queryProducto, id, producto.Codigo, producto.Descripcion,
-			producto.Cantidad, producto.PrecioUnitario, producto.Descuento, subtotalProducto)
+			producto.Cantidad, producto.PrecioUnitario, producto.Descuento, subtotalProducto, subtotalProducto)
 		if err != nil {
 			return nil, fmt.Errorf("error insertando producto: %v", err)
 		}
@@ -235,7 +236,7 @@ func (d *Database) ActualizarFactura(id int, clienteCedula, clienteNombre string
 	}
 
 	// Obtener factura actualizada
-	facturaActualizada, err := d.ObtenerFacturaPorID(id)
+	facturaActualizada, err := d.ObtenerFacturaPorID(ctx, id)
 	if err != nil {
 		return nil, fmt.Errorf("error obteniendo factura actualizada: %v", err)
 	}
@@ -247,22 +248,25 @@ func (d *Database) ActualizarFactura(id int, clienteCedula, clienteNombre string
 		Tabla:        "facturas",
 		RegistroID:   id,
 		Operacion:    "UPDATE",
-		Usuario:      "system", // TODO: obtener usuario real
 		DatosAntes:   string(datosAntes),
 		DatosDespues: string(datosDespues),
 	}
-	d.RegistrarAuditoria(audit)
+	d.RegistrarAuditoria(ctx, audit)
 
 	return facturaActualizada, nil
 }
 
-// EliminarFactura elimina completamente una factura y sus productos
-func (d *Database) EliminarFactura(id int) error {
+// EliminarFactura elimina completamente una factura en BORRADOR y sus productos. Las facturas
+// emitidas se conservan: las autorizadas se anulan con SolicitarAnulacion.
+func (d *Database) EliminarFactura(ctx context.Context, id int) error {
 	// Obtener factura para auditoría
-	factura, err := d.ObtenerFacturaPorID(id)
+	factura, err := d.ObtenerFacturaPorID(ctx, id)
 	if err != nil {
 		return fmt.Errorf("factura no encontrada: %v", err)
 	}
+	if factura.Estado != EstadoBorrador {
+		return fmt.Errorf("%w (estado actual: %s)", ErrFacturaNoEliminable, factura.Estado)
+	}
 
 	// Iniciar transacción
 	tx, err := d.db.Begin()
@@ -277,11 +281,14 @@ func (d *Database) EliminarFactura(id int) error {
 		return fmt.Errorf("error eliminando productos: %v", err)
 	}
 
-	// Eliminar factura
-	_, err = tx.Exec("DELETE FROM facturas WHERE id = ?", id)
+	// Eliminar factura, solo si sigue en BORRADOR
+	resultado, err := tx.Exec("DELETE FROM facturas WHERE id = ? AND estado = ?", id, EstadoBorrador)
 	if err != nil {
 		return fmt.Errorf("error eliminando factura: %v", err)
 	}
+	if eliminadas, err := resultado.RowsAffected(); err == nil && eliminadas == 0 {
+		return ErrFacturaNoEliminable
+	}
 
 	// Confirmar transacción
 	if err = tx.Commit(); err != nil {
@@ -294,10 +301,9 @@ func (d *Database) EliminarFactura(id int) error {
 		Tabla:      "facturas",
 		RegistroID: id,
 		Operacion:  "DELETE",
-		Usuario:    "system", // TODO: obtener usuario real
 		DatosAntes: string(datosAntes),
 	}
-	d.RegistrarAuditoria(audit)
+	d.RegistrarAuditoria(ctx, audit)
 
 	return nil
 }
\ No newline at end of file
@@ -0,0 +1,275 @@
+// Package database implementa el flujo de anulación de facturas autorizadas
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Estados de una solicitud de anulación
+const (
+	AnulacionSolicitada = "SOLICITADA" // Pendiente de anular en el portal del SRI
+	AnulacionConfirmada = "CONFIRMADA" // Anulada en el SRI; la factura pasa a ANULADA
+	AnulacionRechazada  = "RECHAZADA"  // El SRI o el receptor no aceptaron la anulación
+)
+
+var (
+	// ErrAnulacionPendiente se retorna al solicitar una anulación que ya está en trámite
+	ErrAnulacionPendiente = errors.New("la factura ya tiene una solicitud de anulación pendiente")
+	// ErrSinAnulacionPendiente se retorna al resolver una anulación que no fue solicitada
+	ErrSinAnulacionPendiente = errors.New("la factura no tiene una solicitud de anulación pendiente")
+	// ErrFacturaNoEliminable se retorna al eliminar una factura que no está en BORRADOR
+	ErrFacturaNoEliminable = errors.New("solo se pueden eliminar facturas en BORRADOR")
+)
+
+// AnulacionFacturaDB solicitud de anulación de una factura autorizada
+type AnulacionFacturaDB struct {
+	ID              int        `json:"id"`
+	FacturaID       int        `json:"facturaId"`
+	Motivo          string     `json:"motivo"`
+	Estado          string     `json:"estado"`
+	ReferenciaSRI   string     `json:"referenciaSRI,omitempty"` // Comprobante de anulación del portal del SRI
+	Observaciones   string     `json:"observaciones,omitempty"`
+	SolicitadoPor   string     `json:"solicitadoPor"`
+	FechaSolicitud  time.Time  `json:"fechaSolicitud"`
+	ResueltoPor     string     `json:"resueltoPor,omitempty"`
+	FechaResolucion *time.Time `json:"fechaResolucion,omitempty"`
+}
+
+const selectAnulacion = `
+	SELECT id, factura_id, motivo, estado, referencia_sri, observaciones,
+	       solicitado_por, fecha_solicitud, resuelto_por, fecha_resolucion
+	FROM anulaciones_factura`
+
+// escanearAnulacion convierte una fila en AnulacionFacturaDB
+func escanearAnulacion(fila filaEscaneable) (*AnulacionFacturaDB, error) {
+	anulacion := &AnulacionFacturaDB{}
+	var referencia, observaciones, resueltoPor sql.NullString
+	var fechaResolucion sql.NullTime
+
+	err := fila.Scan(&anulacion.ID, &anulacion.FacturaID, &anulacion.Motivo, &anulacion.Estado,
+		&referencia, &observaciones, &anulacion.SolicitadoPor, &anulacion.FechaSolicitud,
+		&resueltoPor, &fechaResolucion)
+	if err != nil {
+		return nil, err
+	}
+
+	anulacion.ReferenciaSRI = referencia.String
+	anulacion.Observaciones = observaciones.String
+	anulacion.ResueltoPor = resueltoPor.String
+	if fechaResolucion.Valid {
+		anulacion.FechaResolucion = &fechaResolucion.Time
+	}
+	return anulacion, nil
+}
+
+// SolicitarAnulacion registra la intención de anular una factura autorizada. La factura sigue
+// AUTORIZADA hasta que la anulación se confirme con la referencia del portal del SRI.
+func (d *Database) SolicitarAnulacion(ctx context.Context, facturaID int, motivo, usuario string) (*AnulacionFacturaDB, error) {
+	motivo = strings.TrimSpace(motivo)
+	if motivo == "" || len(motivo) > 300 {
+		return nil, fmt.Errorf("el motivo de anulación es requerido y no puede exceder 300 caracteres")
+	}
+	usuario = usuarioAuditoria(ctx, usuario)
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error iniciando transacción: %v", err)
+	}
+	defer tx.Rollback()
+
+	var estado string
+	filtro, args := filtroTenant(ctx, "tenant_id")
+	err = tx.QueryRow("SELECT estado FROM facturas WHERE id = ?"+filtro+tx.paraActualizar(),
+		append([]interface{}{facturaID}, args...)...).Scan(&estado)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("factura con ID %d no encontrada", facturaID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error obteniendo estado de factura: %v", err)
+	}
+	if estado != EstadoAutorizada {
+		return nil, fmt.Errorf("%w: solo se anulan facturas autorizadas (estado actual: %s)", ErrTransicionInvalida, estado)
+	}
+
+	var pendientes int
+	err = tx.QueryRow("SELECT COUNT(*) FROM anulaciones_factura WHERE factura_id = ? AND estado = ?",
+		facturaID, AnulacionSolicitada).Scan(&pendientes)
+	if err != nil {
+		return nil, fmt.Errorf("error verificando anulaciones pendientes: %v", err)
+	}
+	if pendientes > 0 {
+		return nil, ErrAnulacionPendiente
+	}
+
+	var id int
+	err = tx.QueryRow(`
+		INSERT INTO anulaciones_factura (factura_id, motivo, estado, solicitado_por, fecha_solicitud)
+		VALUES (?, ?, ?, ?, ?)
+		RETURNING id`,
+		facturaID, motivo, AnulacionSolicitada, usuario, time.Now().UTC()).Scan(&id)
+	if err != nil {
+		return nil, fmt.Errorf("error registrando solicitud de anulación: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error confirmando transacción: %v", err)
+	}
+
+	anulacion, err := d.obtenerAnulacion(id)
+	if err != nil {
+		return nil, err
+	}
+	d.auditar(ctx, "anulaciones_factura", id, "CREATE", usuario, nil, anulacion)
+	return anulacion, nil
+}
+
+// ConfirmarAnulacion registra la referencia de la anulación hecha en el portal del SRI, pasa la
+// factura a ANULADA y reingresa al inventario lo que la venta había descontado
+func (d *Database) ConfirmarAnulacion(ctx context.Context, facturaID int, referenciaSRI, usuario string) (*AnulacionFacturaDB, error) {
+	referenciaSRI = strings.TrimSpace(referenciaSRI)
+	if referenciaSRI == "" {
+		return nil, fmt.Errorf("la referencia de anulación del SRI es requerida")
+	}
+	usuario = usuarioAuditoria(ctx, usuario)
+
+	facturaAntes, err := d.ObtenerFacturaPorID(ctx, facturaID)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error iniciando transacción: %v", err)
+	}
+	defer tx.Rollback()
+
+	antes, err := pendienteAnulacion(ctx, tx, facturaID)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE anulaciones_factura
+		SET estado = ?, referencia_sri = ?, resuelto_por = ?, fecha_resolucion = ?
+		WHERE id = ?`,
+		AnulacionConfirmada, referenciaSRI, usuario, time.Now().UTC(), antes.ID)
+	if err != nil {
+		return nil, fmt.Errorf("error confirmando anulación: %v", err)
+	}
+
+	err = transicionarEstadoFactura(ctx, tx, facturaID, CambioEstadoFactura{
+		Estado:        EstadoAnulada,
+		Observaciones: fmt.Sprintf("Anulada en el SRI (referencia %s): %s", referenciaSRI, antes.Motivo),
+		Actor:         usuario,
+	}, true)
+	if err != nil {
+		return nil, err
+	}
+	if err := reingresarInventarioAnulacion(tx, facturaID, usuario); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error confirmando transacción: %v", err)
+	}
+
+	anulacion, err := d.obtenerAnulacion(antes.ID)
+	if err != nil {
+		return nil, err
+	}
+	d.auditar(ctx, "anulaciones_factura", antes.ID, "UPDATE", usuario, antes, anulacion)
+	if facturaDespues, err := d.ObtenerFacturaPorID(ctx, facturaID); err == nil {
+		d.auditar(ctx, "facturas", facturaID, "UPDATE", usuario, facturaAntes, facturaDespues)
+	}
+	return anulacion, nil
+}
+
+// RechazarAnulacion cierra una solicitud que el SRI o el receptor no aceptaron; la factura
+// sigue AUTORIZADA y puede solicitarse de nuevo
+func (d *Database) RechazarAnulacion(ctx context.Context, facturaID int, observaciones, usuario string) (*AnulacionFacturaDB, error) {
+	observaciones = strings.TrimSpace(observaciones)
+	if observaciones == "" {
+		return nil, fmt.Errorf("las observaciones del rechazo son requeridas")
+	}
+	usuario = usuarioAuditoria(ctx, usuario)
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error iniciando transacción: %v", err)
+	}
+	defer tx.Rollback()
+
+	antes, err := pendienteAnulacion(ctx, tx, facturaID)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE anulaciones_factura
+		SET estado = ?, observaciones = ?, resuelto_por = ?, fecha_resolucion = ?
+		WHERE id = ?`,
+		AnulacionRechazada, observaciones, usuario, time.Now().UTC(), antes.ID)
+	if err != nil {
+		return nil, fmt.Errorf("error rechazando anulación: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error confirmando transacción: %v", err)
+	}
+
+	anulacion, err := d.obtenerAnulacion(antes.ID)
+	if err != nil {
+		return nil, err
+	}
+	d.auditar(ctx, "anulaciones_factura", antes.ID, "UPDATE", usuario, antes, anulacion)
+	return anulacion, nil
+}
+
+// ListarAnulaciones retorna las solicitudes de anulación de una factura en orden cronológico
+func (d *Database) ListarAnulaciones(ctx context.Context, facturaID int) ([]*AnulacionFacturaDB, error) {
+	filtro, args := filtroFacturaTenant(ctx, "factura_id")
+	rows, err := d.db.Query(selectAnulacion+" WHERE factura_id = ?"+filtro+" ORDER BY id",
+		append([]interface{}{facturaID}, args...)...)
+	if err != nil {
+		return nil, fmt.Errorf("error consultando anulaciones: %v", err)
+	}
+	defer rows.Close()
+
+	var anulaciones []*AnulacionFacturaDB
+	for rows.Next() {
+		anulacion, err := escanearAnulacion(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error escaneando anulación: %v", err)
+		}
+		anulaciones = append(anulaciones, anulacion)
+	}
+	return anulaciones, rows.Err()
+}
+
+// obtenerAnulacion obtiene una solicitud de anulación por su ID
+func (d *Database) obtenerAnulacion(id int) (*AnulacionFacturaDB, error) {
+	anulacion, err := escanearAnulacion(d.db.QueryRow(selectAnulacion+" WHERE id = ?", id))
+	if err != nil {
+		return nil, fmt.Errorf("error obteniendo anulación: %v", err)
+	}
+	return anulacion, nil
+}
+
+// pendienteAnulacion obtiene y bloquea la solicitud pendiente de una factura del tenant del contexto
+func pendienteAnulacion(ctx context.Context, tx *transaccion, facturaID int) (*AnulacionFacturaDB, error) {
+	filtro, args := filtroFacturaTenant(ctx, "factura_id")
+	anulacion, err := escanearAnulacion(tx.QueryRow(selectAnulacion+" WHERE factura_id = ? AND estado = ?"+filtro+tx.paraActualizar(),
+		append([]interface{}{facturaID, AnulacionSolicitada}, args...)...))
+	if err == sql.ErrNoRows {
+		return nil, ErrSinAnulacionPendiente
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error obteniendo anulación pendiente: %v", err)
+	}
+	return anulacion, nil
+}
@@ -0,0 +1,102 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go-facturacion-sri/models"
+)
+
+func TestAnulacionFacturaAutorizada(t *testing.T) {
+	db := nuevaDBTrabajosPrueba(t)
+	producto := productoInventarioPrueba(t, db, "ANU001")
+	if _, err := db.AjustarStock(context.Background(), AjusteInventario{ProductoID: producto.ID, Establecimiento: "001", Cantidad: 5, Motivo: "Inventario inicial"}); err != nil {
+		t.Fatalf("AjustarStock() error: %v", err)
+	}
+	facturaID := autorizarFacturaInventario(t, db, clavePrueba("01", "001", "000000001"),
+		[]models.ProductoInput{{Codigo: "ANU001", Cantidad: 2}})
+
+	// Una factura autorizada no se elimina ni se anula directamente
+	if err := db.EliminarFactura(context.Background(), facturaID); !errors.Is(err, ErrFacturaNoEliminable) {
+		t.Errorf("EliminarFactura() = %v, esperado ErrFacturaNoEliminable", err)
+	}
+	if err := db.TransicionarEstadoFactura(context.Background(), facturaID, CambioEstadoFactura{Estado: EstadoAnulada}); !errors.Is(err, ErrTransicionInvalida) {
+		t.Errorf("Transición directa a ANULADA = %v, esperado ErrTransicionInvalida", err)
+	}
+	if _, err := db.ConfirmarAnulacion(context.Background(), facturaID, "REF-1", "contador"); !errors.Is(err, ErrSinAnulacionPendiente) {
+		t.Errorf("ConfirmarAnulacion() sin solicitud = %v, esperado ErrSinAnulacionPendiente", err)
+	}
+
+	// Una solicitud rechazada deja la factura autorizada
+	if _, err := db.SolicitarAnulacion(context.Background(), facturaID, "Cliente desistió", "vendedor"); err != nil {
+		t.Fatalf("SolicitarAnulacion() error: %v", err)
+	}
+	if _, err := db.SolicitarAnulacion(context.Background(), facturaID, "Otra vez", "vendedor"); !errors.Is(err, ErrAnulacionPendiente) {
+		t.Errorf("Solicitud duplicada = %v, esperado ErrAnulacionPendiente", err)
+	}
+	rechazada, err := db.RechazarAnulacion(context.Background(), facturaID, "El receptor no aceptó", "contador")
+	if err != nil || rechazada.Estado != AnulacionRechazada || rechazada.FechaResolucion == nil {
+		t.Fatalf("RechazarAnulacion() = %+v, %v", rechazada, err)
+	}
+	if factura, _ := db.ObtenerFacturaPorID(context.Background(), facturaID); factura.Estado != EstadoAutorizada {
+		t.Errorf("Estado tras rechazo = %s, esperado AUTORIZADA", factura.Estado)
+	}
+
+	// Confirmar anula la factura, guarda la referencia y reingresa el inventario
+	if _, err := db.SolicitarAnulacion(context.Background(), facturaID, "Error en datos del comprador", "vendedor"); err != nil {
+		t.Fatalf("SolicitarAnulacion() error: %v", err)
+	}
+	confirmada, err := db.ConfirmarAnulacion(context.Background(), facturaID, "ANU-2026-0001", "contador")
+	if err != nil {
+		t.Fatalf("ConfirmarAnulacion() error: %v", err)
+	}
+	if confirmada.Estado != AnulacionConfirmada || confirmada.ReferenciaSRI != "ANU-2026-0001" || confirmada.ResueltoPor != "contador" {
+		t.Errorf("Anulación confirmada = %+v", confirmada)
+	}
+
+	factura, err := db.ObtenerFacturaPorID(context.Background(), facturaID)
+	if err != nil || factura.Estado != EstadoAnulada {
+		t.Fatalf("Factura tras confirmar = %+v, %v", factura, err)
+	}
+	if stock, err := db.ObtenerStock(context.Background(), producto.ID, "001"); err != nil || stock.Cantidad != 5 {
+		t.Errorf("Stock tras anular = %+v, %v; esperado 5", stock, err)
+	}
+
+	estadisticas, err := db.EstadisticasFacturas(context.Background())
+	if err != nil {
+		t.Fatalf("EstadisticasFacturas() error: %v", err)
+	}
+	if total, _ := estadisticas["total_facturado"].(float64); total != 0 {
+		t.Errorf("total_facturado = %v, una factura anulada no debe sumar", estadisticas["total_facturado"])
+	}
+
+	anulaciones, err := db.ListarAnulaciones(context.Background(), facturaID)
+	if err != nil || len(anulaciones) != 2 {
+		t.Fatalf("ListarAnulaciones() = %d, %v; esperadas 2", len(anulaciones), err)
+	}
+	auditoria, err := db.ObtenerAuditoriaPorRegistro(context.Background(), "anulaciones_factura", confirmada.ID)
+	if err != nil || len(auditoria) != 2 {
+		t.Errorf("Auditoría de la anulación = %d registros, %v; esperados 2", len(auditoria), err)
+	}
+}
+
+func TestSolicitarAnulacionRequiereAutorizada(t *testing.T) {
+	db := nuevaDBTrabajosPrueba(t)
+	id := facturaEstadosPrueba(t, db, clavePrueba("01", "001", "000000002"))
+
+	if _, err := db.SolicitarAnulacion(context.Background(), id, "Error", "vendedor"); !errors.Is(err, ErrTransicionInvalida) {
+		t.Errorf("SolicitarAnulacion(BORRADOR) = %v, esperado ErrTransicionInvalida", err)
+	}
+	if _, err := db.SolicitarAnulacion(context.Background(), id, "  ", "vendedor"); err == nil {
+		t.Error("Se esperaba error por motivo vacío")
+	}
+
+	// Los borradores sí se eliminan
+	if err := db.EliminarFactura(context.Background(), id); err != nil {
+		t.Fatalf("EliminarFactura(BORRADOR) error: %v", err)
+	}
+	if _, err := db.ObtenerFacturaPorID(context.Background(), id); err == nil {
+		t.Error("La factura en BORRADOR debería haberse eliminado")
+	}
+}
@@ -0,0 +1,364 @@
+// Package database encadena el log de auditoría con hashes para detectar alteraciones
+package database
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+var (
+	// ErrCadenaAuditoriaRota se retorna cuando una entrada de audit_log fue modificada o eliminada
+	ErrCadenaAuditoriaRota = errors.New("la cadena de auditoría fue alterada")
+	// ErrCheckpointInvalido se retorna cuando la firma de un checkpoint no corresponde a su contenido
+	ErrCheckpointInvalido = errors.New("checkpoint de auditoría con firma inválida")
+)
+
+// prefijoCheckpointAuditoria nombre base de los archivos de checkpoint exportados
+const prefijoCheckpointAuditoria = "checkpoint_auditoria_"
+
+const selectAuditoria = `
+	SELECT id, tabla, registro_id, operacion, usuario, datos_antes, datos_despues,
+	       ip_address, user_agent, timestamp, hash, hash_anterior, request_id, tenant_id
+	FROM audit_log`
+
+// QuiebreAuditoria primera entrada cuya cadena de hashes no coincide
+type QuiebreAuditoria struct {
+	ID             int    `json:"id"`
+	Motivo         string `json:"motivo"`
+	HashEsperado   string `json:"hashEsperado"`
+	HashRegistrado string `json:"hashRegistrado"`
+}
+
+// VerificacionAuditoria resultado de recorrer la cadena de audit_log
+type VerificacionAuditoria struct {
+	Valida     bool              `json:"valida"`
+	Registros  int               `json:"registros"` // Entradas verificadas antes del quiebre
+	UltimoID   int               `json:"ultimoId"`  // Última entrada válida
+	UltimoHash string            `json:"ultimoHash"`
+	Quiebre    *QuiebreAuditoria `json:"quiebre,omitempty"`
+}
+
+// CheckpointAuditoria punto de control firmado con HMAC-SHA256. Guardado fuera de la base de
+// datos permite detectar que se eliminaron las últimas entradas o se recalculó toda la cadena.
+type CheckpointAuditoria struct {
+	UltimoID  int       `json:"ultimoId"`
+	Hash      string    `json:"hash"`
+	Registros int       `json:"registros"`
+	Fecha     time.Time `json:"fecha"`
+	Firma     string    `json:"firma"`
+}
+
+// escanearAuditoria convierte una fila en AuditLogDB
+func escanearAuditoria(fila filaEscaneable) (*AuditLogDB, error) {
+	audit := &AuditLogDB{}
+	var datosAntes, datosDespues, ipAddress, userAgent, hash, hashAnterior, requestID sql.NullString
+
+	err := fila.Scan(&audit.ID, &audit.Tabla, &audit.RegistroID, &audit.Operacion, &audit.Usuario,
+		&datosAntes, &datosDespues, &ipAddress, &userAgent, &audit.Timestamp, &hash, &hashAnterior, &requestID, &audit.TenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	audit.DatosAntes = datosAntes.String
+	audit.DatosDespues = datosDespues.String
+	audit.IPAddress = ipAddress.String
+	audit.UserAgent = userAgent.String
+	audit.Hash = hash.String
+	audit.HashAnterior = hashAnterior.String
+	audit.RequestID = requestID.String
+	return audit, nil
+}
+
+// hashAuditoria calcula el SHA-256 del contenido de una entrada encadenado al hash anterior
+func hashAuditoria(audit *AuditLogDB, hashAnterior string) string {
+	campos := []interface{}{
+		hashAnterior, audit.Tabla, audit.RegistroID, audit.Operacion, audit.Usuario,
+		audit.DatosAntes, audit.DatosDespues, audit.IPAddress, audit.UserAgent,
+		audit.Timestamp.UTC().Format(time.RFC3339Nano),
+	}
+	// Solo se agrega si existe para que las entradas previas a request_id conserven su hash
+	if audit.RequestID != "" {
+		campos = append(campos, audit.RequestID)
+	}
+	// Igual con el tenant: las entradas del emisor predeterminado conservan el hash anterior a v12
+	if audit.TenantID != TenantPredeterminado {
+		campos = append(campos, audit.TenantID)
+	}
+	contenido, _ := json.Marshal(campos)
+	suma := sha256.Sum256(contenido)
+	return hex.EncodeToString(suma[:])
+}
+
+// insertarAuditoria agrega la entrada al final de la cadena. La transacción debe excluir a
+// otros escritores de audit_log para que dos entradas no compartan el mismo hash anterior.
+func insertarAuditoria(tx *transaccion, audit *AuditLogDB) error {
+	if err := tx.bloquear(bloqueoAuditoria); err != nil {
+		return err
+	}
+
+	var anterior sql.NullString
+	err := tx.QueryRow("SELECT hash FROM audit_log ORDER BY id DESC LIMIT 1").Scan(&anterior)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("error obteniendo la última entrada de auditoría: %v", err)
+	}
+
+	// PostgreSQL guarda microsegundos: truncar para que el hash se pueda recalcular al leer
+	audit.Timestamp = time.Now().UTC().Truncate(time.Microsecond)
+	audit.HashAnterior = anterior.String
+	audit.Hash = hashAuditoria(audit, audit.HashAnterior)
+
+	err = tx.QueryRow(`
+		INSERT INTO audit_log (tabla, registro_id, operacion, usuario, datos_antes, datos_despues,
+		                       ip_address, user_agent, timestamp, hash, hash_anterior, request_id, tenant_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id`,
+		audit.Tabla, audit.RegistroID, audit.Operacion, audit.Usuario, audit.DatosAntes, audit.DatosDespues,
+		audit.IPAddress, audit.UserAgent, audit.Timestamp, audit.Hash, audit.HashAnterior, audit.RequestID, audit.TenantID).Scan(&audit.ID)
+	if err != nil {
+		return fmt.Errorf("error registrando auditoría: %v", err)
+	}
+	return nil
+}
+
+// auditar registra en audit_log un cambio serializando los datos antes y después
+func (d *Database) auditar(ctx context.Context, tabla string, id int, operacion, usuario string, antes, despues interface{}) {
+	audit := &AuditLogDB{
+		Tabla:      tabla,
+		RegistroID: id,
+		Operacion:  operacion,
+		Usuario:    usuario,
+	}
+	if antes != nil {
+		datos, _ := json.Marshal(antes)
+		audit.DatosAntes = string(datos)
+	}
+	if despues != nil {
+		datos, _ := json.Marshal(despues)
+		audit.DatosDespues = string(datos)
+	}
+	d.RegistrarAuditoria(ctx, audit)
+}
+
+// auditarEnTransaccion registra el cambio dentro de tx, así se confirma o revierte junto con él
+func auditarEnTransaccion(ctx context.Context, tx *transaccion, tabla string, id int, operacion, usuario string, antes, despues interface{}) error {
+	audit := &AuditLogDB{
+		Tabla:      tabla,
+		RegistroID: id,
+		Operacion:  operacion,
+		Usuario:    usuario,
+	}
+	if antes != nil {
+		datos, _ := json.Marshal(antes)
+		audit.DatosAntes = string(datos)
+	}
+	if despues != nil {
+		datos, _ := json.Marshal(despues)
+		audit.DatosDespues = string(datos)
+	}
+	completarAuditoria(ctx, audit)
+	return insertarAuditoria(tx, audit)
+}
+
+// sellarAuditoriaExistente encadena las entradas registradas antes de que audit_log tuviera hashes
+func sellarAuditoriaExistente(tx *transaccion) error {
+	// Se aplica en la versión 10, cuando audit_log aún no tiene request_id ni tenant_id
+	rows, err := tx.Query(`
+		SELECT id, tabla, registro_id, operacion, usuario, datos_antes, datos_despues,
+		       ip_address, user_agent, timestamp, hash, hash_anterior, NULL, 0
+		FROM audit_log
+		ORDER BY id`)
+	if err != nil {
+		return fmt.Errorf("error leyendo auditoría existente: %v", err)
+	}
+	var registros []*AuditLogDB
+	for rows.Next() {
+		audit, err := escanearAuditoria(rows)
+		if err != nil {
+			rows.Close()
+			return fmt.Errorf("error escaneando auditoría: %v", err)
+		}
+		registros = append(registros, audit)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	anterior := ""
+	for _, audit := range registros {
+		hash := hashAuditoria(audit, anterior)
+		if _, err := tx.Exec("UPDATE audit_log SET hash = ?, hash_anterior = ? WHERE id = ?", hash, anterior, audit.ID); err != nil {
+			return fmt.Errorf("error sellando auditoría %d: %v", audit.ID, err)
+		}
+		anterior = hash
+	}
+	return nil
+}
+
+// VerificarCadenaAuditoria recorre audit_log en orden y reporta la primera entrada cuyo hash
+// anterior no coincide con la entrada previa (eliminada o reordenada) o cuyo contenido no
+// coincide con su hash (modificada)
+func (d *Database) VerificarCadenaAuditoria() (*VerificacionAuditoria, error) {
+	rows, err := d.db.Query(selectAuditoria + " ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("error leyendo auditoría: %v", err)
+	}
+	defer rows.Close()
+
+	verificacion := &VerificacionAuditoria{Valida: true}
+	for rows.Next() {
+		audit, err := escanearAuditoria(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error escaneando auditoría: %v", err)
+		}
+
+		if audit.HashAnterior != verificacion.UltimoHash {
+			verificacion.Quiebre = &QuiebreAuditoria{
+				ID:             audit.ID,
+				Motivo:         "el hash anterior no coincide con la entrada previa (entrada eliminada o reordenada)",
+				HashEsperado:   verificacion.UltimoHash,
+				HashRegistrado: audit.HashAnterior,
+			}
+		} else if esperado := hashAuditoria(audit, audit.HashAnterior); esperado != audit.Hash {
+			verificacion.Quiebre = &QuiebreAuditoria{
+				ID:             audit.ID,
+				Motivo:         "el contenido no coincide con su hash (entrada modificada)",
+				HashEsperado:   esperado,
+				HashRegistrado: audit.Hash,
+			}
+		}
+		if verificacion.Quiebre != nil {
+			verificacion.Valida = false
+			return verificacion, nil
+		}
+
+		verificacion.Registros++
+		verificacion.UltimoID = audit.ID
+		verificacion.UltimoHash = audit.Hash
+	}
+	return verificacion, rows.Err()
+}
+
+// contenidoFirmado texto sobre el que se calcula la firma del checkpoint
+func (c *CheckpointAuditoria) contenidoFirmado() []byte {
+	return []byte(fmt.Sprintf("%d|%s|%d|%s", c.UltimoID, c.Hash, c.Registros, c.Fecha.UTC().Format(time.RFC3339Nano)))
+}
+
+// firmarCheckpoint calcula el HMAC-SHA256 del checkpoint en hexadecimal
+func firmarCheckpoint(checkpoint *CheckpointAuditoria, clave []byte) string {
+	mac := hmac.New(sha256.New, clave)
+	mac.Write(checkpoint.contenidoFirmado())
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// GenerarCheckpointAuditoria verifica la cadena y firma su estado actual con la clave dada
+func (d *Database) GenerarCheckpointAuditoria(clave []byte) (*CheckpointAuditoria, error) {
+	if len(clave) == 0 {
+		return nil, fmt.Errorf("se requiere una clave para firmar el checkpoint de auditoría")
+	}
+
+	verificacion, err := d.VerificarCadenaAuditoria()
+	if err != nil {
+		return nil, err
+	}
+	if !verificacion.Valida {
+		return nil, fmt.Errorf("%w en la entrada %d: %s", ErrCadenaAuditoriaRota, verificacion.Quiebre.ID, verificacion.Quiebre.Motivo)
+	}
+
+	checkpoint := &CheckpointAuditoria{
+		UltimoID:  verificacion.UltimoID,
+		Hash:      verificacion.UltimoHash,
+		Registros: verificacion.Registros,
+		Fecha:     time.Now().UTC(),
+	}
+	checkpoint.Firma = firmarCheckpoint(checkpoint, clave)
+	return checkpoint, nil
+}
+
+// ExportarCheckpointAuditoria genera un checkpoint y lo guarda en el directorio; retorna la ruta del archivo
+func (d *Database) ExportarCheckpointAuditoria(directorio string, clave []byte) (string, error) {
+	checkpoint, err := d.GenerarCheckpointAuditoria(clave)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(directorio, 0700); err != nil {
+		return "", fmt.Errorf("error creando directorio de checkpoints: %v", err)
+	}
+	contenido, err := json.MarshalIndent(checkpoint, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("error serializando checkpoint: %v", err)
+	}
+
+	ruta := filepath.Join(directorio, prefijoCheckpointAuditoria+checkpoint.Fecha.Format("20060102T150405.000000000Z")+".json")
+	if err := os.WriteFile(ruta, contenido, 0600); err != nil {
+		return "", fmt.Errorf("error guardando checkpoint: %v", err)
+	}
+	return ruta, nil
+}
+
+// LeerUltimoCheckpointAuditoria carga el checkpoint más reciente del directorio; nil si no hay ninguno
+func LeerUltimoCheckpointAuditoria(directorio string) (*CheckpointAuditoria, string, error) {
+	archivos, err := filepath.Glob(filepath.Join(directorio, prefijoCheckpointAuditoria+"*.json"))
+	if err != nil {
+		return nil, "", fmt.Errorf("error listando checkpoints: %v", err)
+	}
+	if len(archivos) == 0 {
+		return nil, "", nil
+	}
+	sort.Strings(archivos)
+	ruta := archivos[len(archivos)-1]
+
+	contenido, err := os.ReadFile(ruta)
+	if err != nil {
+		return nil, "", fmt.Errorf("error leyendo checkpoint: %v", err)
+	}
+	checkpoint := &CheckpointAuditoria{}
+	if err := json.Unmarshal(contenido, checkpoint); err != nil {
+		return nil, "", fmt.Errorf("error parseando checkpoint %s: %v", ruta, err)
+	}
+	return checkpoint, ruta, nil
+}
+
+// VerificarCheckpointAuditoria comprueba la firma del checkpoint y que la entrada que selló siga
+// en audit_log con el mismo hash y la misma cantidad de entradas hasta ella
+func (d *Database) VerificarCheckpointAuditoria(checkpoint *CheckpointAuditoria, clave []byte) error {
+	if !hmac.Equal([]byte(firmarCheckpoint(checkpoint, clave)), []byte(checkpoint.Firma)) {
+		return ErrCheckpointInvalido
+	}
+	if checkpoint.UltimoID == 0 {
+		return nil
+	}
+
+	var hash sql.NullString
+	err := d.db.QueryRow("SELECT hash FROM audit_log WHERE id = ?", checkpoint.UltimoID).Scan(&hash)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("%w: la entrada %d del checkpoint ya no existe", ErrCadenaAuditoriaRota, checkpoint.UltimoID)
+	}
+	if err != nil {
+		return fmt.Errorf("error obteniendo entrada de auditoría: %v", err)
+	}
+	if hash.String != checkpoint.Hash {
+		return fmt.Errorf("%w: el hash de la entrada %d difiere del checkpoint", ErrCadenaAuditoriaRota, checkpoint.UltimoID)
+	}
+
+	var registros int
+	if err := d.db.QueryRow("SELECT COUNT(*) FROM audit_log WHERE id <= ?", checkpoint.UltimoID).Scan(&registros); err != nil {
+		return fmt.Errorf("error contando entradas de auditoría: %v", err)
+	}
+	if registros != checkpoint.Registros {
+		return fmt.Errorf("%w: hay %d entradas hasta la %d y el checkpoint registró %d",
+			ErrCadenaAuditoriaRota, registros, checkpoint.UltimoID, checkpoint.Registros)
+	}
+	return nil
+}
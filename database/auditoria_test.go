@@ -0,0 +1,187 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// registrarAuditoriaPrueba agrega n entradas al log de auditoría
+func registrarAuditoriaPrueba(t *testing.T, db *Database, n int) {
+	t.Helper()
+	for i := 1; i <= n; i++ {
+		err := db.RegistrarAuditoria(context.Background(), &AuditLogDB{
+			Tabla: "facturas", RegistroID: i, Operacion: "UPDATE", Usuario: "contador",
+			DatosDespues: `{"estado":"AUTORIZADA"}`,
+		})
+		if err != nil {
+			t.Fatalf("RegistrarAuditoria() error: %v", err)
+		}
+	}
+}
+
+func TestCadenaAuditoria(t *testing.T) {
+	db := nuevaDBTrabajosPrueba(t)
+	registrarAuditoriaPrueba(t, db, 4)
+
+	verificacion, err := db.VerificarCadenaAuditoria()
+	if err != nil {
+		t.Fatalf("VerificarCadenaAuditoria() error: %v", err)
+	}
+	if !verificacion.Valida || verificacion.Registros != 4 || verificacion.Quiebre != nil {
+		t.Fatalf("Verificación de cadena íntegra = %+v", verificacion)
+	}
+
+	registros, err := db.ObtenerAuditoriaPorTabla(context.Background(), "facturas", 10, 0)
+	if err != nil || len(registros) != 4 {
+		t.Fatalf("ObtenerAuditoriaPorTabla() = %d, %v", len(registros), err)
+	}
+	if registros[3].HashAnterior != "" || registros[2].HashAnterior != registros[3].Hash {
+		t.Errorf("Las entradas no están encadenadas: %+v", registros)
+	}
+
+	// Modificar el contenido de una entrada rompe su hash
+	segunda := registros[2]
+	if _, err := db.db.Exec("UPDATE audit_log SET usuario = 'intruso' WHERE id = ?", segunda.ID); err != nil {
+		t.Fatalf("Error alterando auditoría: %v", err)
+	}
+	verificacion, err = db.VerificarCadenaAuditoria()
+	if err != nil {
+		t.Fatalf("VerificarCadenaAuditoria() error: %v", err)
+	}
+	if verificacion.Valida || verificacion.Quiebre == nil || verificacion.Quiebre.ID != segunda.ID || verificacion.Registros != 1 {
+		t.Errorf("Verificación tras modificar = %+v, quiebre %+v", verificacion, verificacion.Quiebre)
+	}
+	if _, err := db.db.Exec("UPDATE audit_log SET usuario = 'contador' WHERE id = ?", segunda.ID); err != nil {
+		t.Fatalf("Error restaurando auditoría: %v", err)
+	}
+
+	// Eliminar una entrada intermedia rompe el enlace de la siguiente
+	if _, err := db.db.Exec("DELETE FROM audit_log WHERE id = ?", segunda.ID); err != nil {
+		t.Fatalf("Error eliminando auditoría: %v", err)
+	}
+	verificacion, _ = db.VerificarCadenaAuditoria()
+	if verificacion.Valida || verificacion.Quiebre.ID != registros[1].ID {
+		t.Errorf("Verificación tras eliminar = %+v, quiebre %+v", verificacion, verificacion.Quiebre)
+	}
+}
+
+func TestCheckpointAuditoria(t *testing.T) {
+	db := nuevaDBTrabajosPrueba(t)
+	registrarAuditoriaPrueba(t, db, 3)
+	clave := []byte("clave-checkpoints")
+	directorio := t.TempDir()
+
+	if _, err := db.ExportarCheckpointAuditoria(directorio, nil); err == nil {
+		t.Error("Se esperaba error sin clave de firma")
+	}
+	ruta, err := db.ExportarCheckpointAuditoria(directorio, clave)
+	if err != nil {
+		t.Fatalf("ExportarCheckpointAuditoria() error: %v", err)
+	}
+
+	checkpoint, rutaLeida, err := LeerUltimoCheckpointAuditoria(directorio)
+	if err != nil || checkpoint == nil || rutaLeida != ruta || checkpoint.Registros != 3 {
+		t.Fatalf("LeerUltimoCheckpointAuditoria() = %+v, %s, %v", checkpoint, rutaLeida, err)
+	}
+	if err := db.VerificarCheckpointAuditoria(checkpoint, clave); err != nil {
+		t.Errorf("VerificarCheckpointAuditoria() error: %v", err)
+	}
+
+	// Nuevas entradas no invalidan un checkpoint anterior
+	registrarAuditoriaPrueba(t, db, 1)
+	if err := db.VerificarCheckpointAuditoria(checkpoint, clave); err != nil {
+		t.Errorf("Checkpoint tras nuevas entradas: %v", err)
+	}
+
+	if err := db.VerificarCheckpointAuditoria(checkpoint, []byte("otra clave")); !errors.Is(err, ErrCheckpointInvalido) {
+		t.Errorf("Clave incorrecta = %v, esperado ErrCheckpointInvalido", err)
+	}
+
+	// Truncar el final del log no se detecta en la cadena pero sí con el checkpoint
+	if _, err := db.db.Exec("DELETE FROM audit_log WHERE id >= ?", checkpoint.UltimoID); err != nil {
+		t.Fatalf("Error truncando auditoría: %v", err)
+	}
+	if verificacion, _ := db.VerificarCadenaAuditoria(); !verificacion.Valida {
+		t.Fatalf("La cadena truncada sigue siendo consistente: %+v", verificacion)
+	}
+	if err := db.VerificarCheckpointAuditoria(checkpoint, clave); !errors.Is(err, ErrCadenaAuditoriaRota) {
+		t.Errorf("Checkpoint tras truncar = %v, esperado ErrCadenaAuditoriaRota", err)
+	}
+}
+
+func TestSellarAuditoriaExistente(t *testing.T) {
+	db := nuevaDBTrabajosPrueba(t)
+
+	// Entradas previas a la migración, sin hash
+	for i := 1; i <= 3; i++ {
+		_, err := db.db.Exec("INSERT INTO audit_log (tabla, registro_id, operacion, usuario) VALUES ('clientes', ?, 'CREATE', 'sistema')", i)
+		if err != nil {
+			t.Fatalf("Error insertando auditoría heredada: %v", err)
+		}
+	}
+	if verificacion, _ := db.VerificarCadenaAuditoria(); verificacion.Valida {
+		t.Fatal("Las entradas sin hash no deberían verificar")
+	}
+
+	tx, err := db.db.Begin()
+	if err != nil {
+		t.Fatalf("Begin() error: %v", err)
+	}
+	if err := sellarAuditoriaExistente(tx); err != nil {
+		tx.Rollback()
+		t.Fatalf("sellarAuditoriaExistente() error: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit() error: %v", err)
+	}
+
+	registrarAuditoriaPrueba(t, db, 1)
+	verificacion, err := db.VerificarCadenaAuditoria()
+	if err != nil || !verificacion.Valida || verificacion.Registros != 4 {
+		t.Errorf("Verificación tras sellar = %+v, %v", verificacion, err)
+	}
+}
+
+func TestAuditoriaUsaActorDelContexto(t *testing.T) {
+	db := nuevaDBTrabajosPrueba(t)
+	ctx := ConActorAuditoria(context.Background(), ActorAuditoria{
+		Usuario: "contador", Autenticado: true, IPAddress: "198.51.100.4", UserAgent: "erp/2.1", RequestID: "req-1",
+	})
+
+	cliente, err := db.GuardarCliente(ctx, &ClienteDB{Cedula: "1713175071", Nombre: "Juan Pérez", TipoCliente: "PERSONA_NATURAL"})
+	if err != nil {
+		t.Fatalf("GuardarCliente() error: %v", err)
+	}
+	// Guardar una cédula existente la actualiza y se audita como UPDATE
+	if _, err := db.GuardarCliente(context.Background(), &ClienteDB{Cedula: "1713175071", Nombre: "Juan P. Pérez", TipoCliente: "PERSONA_NATURAL"}); err != nil {
+		t.Fatalf("GuardarCliente() error: %v", err)
+	}
+
+	registros, err := db.ObtenerAuditoriaPorRegistro(context.Background(), "clientes", cliente.ID)
+	if err != nil || len(registros) != 2 {
+		t.Fatalf("Auditoría del cliente = %d registros, %v", len(registros), err)
+	}
+	actualizacion, creacion := registros[0], registros[1]
+	if creacion.Operacion != "CREATE" || creacion.Usuario != "contador" || creacion.IPAddress != "198.51.100.4" ||
+		creacion.UserAgent != "erp/2.1" || creacion.RequestID != "req-1" {
+		t.Errorf("Entrada de creación = %+v", creacion)
+	}
+	if actualizacion.Operacion != "UPDATE" || actualizacion.Usuario != ActorSistema || actualizacion.DatosAntes == "" {
+		t.Errorf("Entrada de actualización sin contexto = %+v", actualizacion)
+	}
+
+	factura := guardarFacturaFiltro(t, db, clavePrueba("01", "001", "000000001"), 10)
+	if err := db.EliminarFactura(ctx, factura.ID); err != nil {
+		t.Fatalf("EliminarFactura() error: %v", err)
+	}
+	registros, err = db.ObtenerAuditoriaPorRegistro(context.Background(), "facturas", factura.ID)
+	if err != nil || len(registros) != 2 || registros[0].Operacion != "DELETE" || registros[0].Usuario != "contador" ||
+		registros[1].Operacion != "CREATE" {
+		t.Errorf("Auditoría de la factura = %+v, %v", registros, err)
+	}
+
+	if verificacion, err := db.VerificarCadenaAuditoria(); err != nil || !verificacion.Valida {
+		t.Errorf("La cadena con request ID debería verificar: %+v, %v", verificacion, err)
+	}
+}
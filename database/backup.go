@@ -2,6 +2,7 @@
 package database
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
@@ -130,8 +131,17 @@ func (bm *BackupManager) CrearRespaldo() error {
 
 // obtenerRutaBaseDatos obtiene la ruta del archivo de base de datos actual
 func (bm *BackupManager) obtenerRutaBaseDatos() (string, error) {
-	// En una implementación más avanzada, esto podría obtenerse del objeto Database
-	// Por ahora, intentamos varias rutas posibles
+	// Los respaldos copian el archivo SQLite; PostgreSQL se respalda con pg_dump
+	if bm.database != nil && bm.database.dialecto == DialectoPostgres {
+		return "", fmt.Errorf("los respaldos por archivo solo aplican a SQLite; use pg_dump para PostgreSQL")
+	}
+
+	// Ruta con la que se abrió la base de datos
+	if bm.database != nil && bm.database.ruta != "" {
+		return bm.database.ruta, nil
+	}
+
+	// Sin base de datos asociada, intentamos varias rutas posibles
 	rutasPosibles := []string{
 		"database/facturacion.db",
 		"test_respaldos.db",
@@ -181,7 +191,7 @@ func (bm *BackupManager) verificarIntegridadRespaldo(rutaRespaldo string) error
 	defer respaldoDB.Close()
 
 	// Hacer una consulta simple para verificar integridad
-	stats, err := respaldoDB.EstadisticasFacturas()
+	stats, err := respaldoDB.EstadisticasFacturas(context.Background())
 	if err != nil {
 		return fmt.Errorf("respaldo corrupto, error en consulta: %v", err)
 	}
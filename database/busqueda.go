@@ -0,0 +1,327 @@
+// Package database implementa la búsqueda de texto completo en clientes y líneas de factura
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Tipos de resultado de la búsqueda unificada
+const (
+	ResultadoCliente  = "CLIENTE"
+	ResultadoProducto = "PRODUCTO"
+)
+
+// ErrBusquedaVacia se retorna cuando el texto buscado no tiene letras ni dígitos
+var ErrBusquedaVacia = errors.New("la búsqueda requiere al menos una palabra")
+
+// maxTerminosBusqueda limita las palabras consideradas de una búsqueda
+const maxTerminosBusqueda = 10
+
+// columnasBusquedaClientes y columnasBusquedaProductos columnas indexadas para texto completo
+var (
+	columnasBusquedaClientes  = []string{"nombre", "cedula", "email", "direccion"}
+	columnasBusquedaProductos = []string{"descripcion", "codigo"}
+)
+
+// ResultadoBusqueda coincidencia de la búsqueda unificada; para productos se incluye la factura
+type ResultadoBusqueda struct {
+	Tipo          string  `json:"tipo"` // CLIENTE o PRODUCTO
+	ID            int     `json:"id"`
+	Titulo        string  `json:"titulo"`  // Nombre del cliente o descripción del producto
+	Detalle       string  `json:"detalle"` // Cédula del cliente o código del producto
+	FacturaID     int     `json:"facturaId,omitempty"`
+	NumeroFactura string  `json:"numeroFactura,omitempty"`
+	Puntaje       float64 `json:"puntaje"` // Mayor es más relevante
+}
+
+// sentenciasTextoCompleto tablas FTS5 de contenido externo sincronizadas por triggers
+var sentenciasTextoCompleto = []string{
+	`CREATE VIRTUAL TABLE IF NOT EXISTS clientes_fts USING fts5(
+		nombre, cedula, email, direccion,
+		content='clientes', content_rowid='id', tokenize='unicode61 remove_diacritics 2'
+	)`,
+	`CREATE TRIGGER IF NOT EXISTS clientes_fts_insertar AFTER INSERT ON clientes BEGIN
+		INSERT INTO clientes_fts (rowid, nombre, cedula, email, direccion)
+		VALUES (new.id, new.nombre, new.cedula, new.email, new.direccion);
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS clientes_fts_eliminar AFTER DELETE ON clientes BEGIN
+		INSERT INTO clientes_fts (clientes_fts, rowid, nombre, cedula, email, direccion)
+		VALUES ('delete', old.id, old.nombre, old.cedula, old.email, old.direccion);
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS clientes_fts_actualizar AFTER UPDATE ON clientes BEGIN
+		INSERT INTO clientes_fts (clientes_fts, rowid, nombre, cedula, email, direccion)
+		VALUES ('delete', old.id, old.nombre, old.cedula, old.email, old.direccion);
+		INSERT INTO clientes_fts (rowid, nombre, cedula, email, direccion)
+		VALUES (new.id, new.nombre, new.cedula, new.email, new.direccion);
+	END`,
+	`CREATE VIRTUAL TABLE IF NOT EXISTS productos_fts USING fts5(
+		descripcion, codigo,
+		content='productos', content_rowid='id', tokenize='unicode61 remove_diacritics 2'
+	)`,
+	`CREATE TRIGGER IF NOT EXISTS productos_fts_insertar AFTER INSERT ON productos BEGIN
+		INSERT INTO productos_fts (rowid, descripcion, codigo) VALUES (new.id, new.descripcion, new.codigo);
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS productos_fts_eliminar AFTER DELETE ON productos BEGIN
+		INSERT INTO productos_fts (productos_fts, rowid, descripcion, codigo)
+		VALUES ('delete', old.id, old.descripcion, old.codigo);
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS productos_fts_actualizar AFTER UPDATE ON productos BEGIN
+		INSERT INTO productos_fts (productos_fts, rowid, descripcion, codigo)
+		VALUES ('delete', old.id, old.descripcion, old.codigo);
+		INSERT INTO productos_fts (rowid, descripcion, codigo) VALUES (new.id, new.descripcion, new.codigo);
+	END`,
+	"INSERT INTO clientes_fts (clientes_fts) VALUES ('rebuild')",
+	"INSERT INTO productos_fts (productos_fts) VALUES ('rebuild')",
+}
+
+// fts5Disponible indica si el SQLite enlazado incluye FTS5 (go-sqlite3 lo habilita con -tags sqlite_fts5)
+func fts5Disponible(tx *transaccion) (bool, error) {
+	var habilitado bool
+	if err := tx.QueryRow("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&habilitado); err != nil {
+		return false, fmt.Errorf("error verificando soporte FTS5: %v", err)
+	}
+	return habilitado, nil
+}
+
+// crearIndicesTextoCompleto crea las tablas FTS5 e indexa los datos existentes. Sin FTS5
+// no hace nada: la búsqueda usa LIKE y los índices se crean al abrir con un binario que lo soporte.
+func crearIndicesTextoCompleto(tx *transaccion) error {
+	disponible, err := fts5Disponible(tx)
+	if err != nil || !disponible {
+		return err
+	}
+	return sentencias(sentenciasTextoCompleto...)(tx)
+}
+
+// eliminarIndicesTextoCompleto elimina las tablas FTS5 y sus triggers
+func eliminarIndicesTextoCompleto(tx *transaccion) error {
+	return sentencias(
+		"DROP TRIGGER IF EXISTS clientes_fts_insertar",
+		"DROP TRIGGER IF EXISTS clientes_fts_eliminar",
+		"DROP TRIGGER IF EXISTS clientes_fts_actualizar",
+		"DROP TRIGGER IF EXISTS productos_fts_insertar",
+		"DROP TRIGGER IF EXISTS productos_fts_eliminar",
+		"DROP TRIGGER IF EXISTS productos_fts_actualizar",
+		"DROP TABLE IF EXISTS clientes_fts",
+		"DROP TABLE IF EXISTS productos_fts",
+	)(tx)
+}
+
+// prepararBusqueda determina cómo se ejecuta la búsqueda de texto completo en SQLite. Si el
+// binario soporta FTS5 y faltan los índices (la migración corrió sin FTS5) se crean ahora; si
+// la base ya tiene índices FTS5 pero el binario no los soporta, las escrituras de clientes y
+// productos fallarían en los triggers, así que la apertura se rechaza.
+func (d *Database) prepararBusqueda() error {
+	if d.dialecto != DialectoSQLite {
+		return nil
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error iniciando transacción: %v", err)
+	}
+	defer tx.Rollback()
+
+	disponible, err := fts5Disponible(tx)
+	if err != nil {
+		return err
+	}
+	var indices int
+	err = tx.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'clientes_fts'").Scan(&indices)
+	if err != nil {
+		return fmt.Errorf("error verificando índices de búsqueda: %v", err)
+	}
+
+	switch {
+	case indices > 0 && !disponible:
+		return fmt.Errorf("la base de datos tiene índices FTS5 pero este binario no soporta FTS5; compile con -tags sqlite_fts5")
+	case indices == 0 && disponible:
+		if err := crearIndicesTextoCompleto(tx); err != nil {
+			return fmt.Errorf("error creando índices de búsqueda: %v", err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("error confirmando transacción: %v", err)
+		}
+	}
+
+	d.textoCompleto = disponible
+	return nil
+}
+
+// ModoBusqueda describe cómo se ejecuta la búsqueda de texto completo en esta base, para
+// registrarlo una vez al arrancar
+func (d *Database) ModoBusqueda() string {
+	switch {
+	case d.dialecto == DialectoPostgres:
+		return "PostgreSQL tsvector (índices GIN)"
+	case d.textoCompleto:
+		return "SQLite FTS5"
+	default:
+		return "SQLite sin FTS5 (compile con -tags sqlite_fts5): LIKE ordenado por posición de la coincidencia"
+	}
+}
+
+// terminosBusqueda separa el texto en palabras (letras y dígitos) en minúsculas
+func terminosBusqueda(texto string) []string {
+	terminos := strings.FieldsFunc(strings.ToLower(texto), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(terminos) > maxTerminosBusqueda {
+		terminos = terminos[:maxTerminosBusqueda]
+	}
+	return terminos
+}
+
+// coincidencia arma la parte de la consulta que busca los términos en una tabla: un JOIN
+// adicional, la condición del WHERE, la expresión de puntaje y los argumentos de ambos.
+// Todos los términos deben aparecer: como prefijo de una palabra con índices, o en cualquier
+// parte del texto con LIKE, que puntúa según dónde aparece cada término.
+func (d *Database) coincidencia(tabla, alias string, columnas, terminos []string) (join, condicion, puntaje string, args []interface{}) {
+	switch {
+	case d.dialecto == DialectoPostgres:
+		valores := make([]string, len(columnas))
+		for i, columna := range columnas {
+			valores[i] = "COALESCE(" + alias + "." + columna + ", '')"
+		}
+		// La expresión coincide con la de los índices GIN de la migración 8
+		vector := "to_tsvector('simple', " + strings.Join(valores, " || ' ' || ") + ")"
+		prefijos := make([]string, len(terminos))
+		for i, termino := range terminos {
+			prefijos[i] = termino + ":*"
+		}
+		join = " CROSS JOIN to_tsquery('simple', ?) AS consulta"
+		return join, vector + " @@ consulta", "ts_rank(" + vector + ", consulta)",
+			[]interface{}{strings.Join(prefijos, " & ")}
+
+	case d.textoCompleto:
+		prefijos := make([]string, len(terminos))
+		for i, termino := range terminos {
+			prefijos[i] = `"` + termino + `"*`
+		}
+		fts := tabla + "_fts"
+		join = " JOIN " + fts + " ON " + fts + ".rowid = " + alias + ".id"
+		// bm25 es menor cuanto más relevante
+		return join, fts + " MATCH ?", "-bm25(" + fts + ")", []interface{}{strings.Join(prefijos, " ")}
+
+	default:
+		// El puntaje va en el SELECT, antes del WHERE: sus argumentos van primero
+		var condiciones, pesos []string
+		var argsCondicion []interface{}
+		for _, termino := range terminos {
+			alternativas := make([]string, len(columnas))
+			for i, columna := range columnas {
+				valor := "LOWER(COALESCE(" + alias + "." + columna + ", ''))"
+				alternativas[i] = valor + " LIKE ?"
+				argsCondicion = append(argsCondicion, "%"+termino+"%")
+				pesos = append(pesos, pesoCoincidenciaLike(valor))
+				args = append(args, termino, termino+"%", "% "+termino+"%", "%"+termino+"%")
+			}
+			condiciones = append(condiciones, "("+strings.Join(alternativas, " OR ")+")")
+		}
+		return "", strings.Join(condiciones, " AND "), "(" + strings.Join(pesos, " + ") + ")", append(args, argsCondicion...)
+	}
+}
+
+// pesoCoincidenciaLike puntúa un término en una columna cuando no hay índices de texto completo:
+// valor exacto, inicio del valor, inicio de una palabra y en medio de una palabra, en ese orden.
+// Recibe como argumentos el término exacto y los patrones "t%", "% t%" y "%t%".
+func pesoCoincidenciaLike(valor string) string {
+	return "CASE WHEN " + valor + " = ? THEN 8 WHEN " + valor + " LIKE ? THEN 4 WHEN " + valor +
+		" LIKE ? THEN 2 WHEN " + valor + " LIKE ? THEN 1 ELSE 0 END"
+}
+
+// Buscar busca el texto en clientes activos y en las líneas de factura del tenant del contexto,
+// y retorna los resultados de ambos ordenados por relevancia
+func (d *Database) Buscar(ctx context.Context, texto string, limite int) ([]*ResultadoBusqueda, error) {
+	terminos := terminosBusqueda(texto)
+	if len(terminos) == 0 {
+		return nil, ErrBusquedaVacia
+	}
+	if limite <= 0 {
+		limite = 20
+	}
+
+	clientes, err := d.buscarClientes(ctx, terminos, limite)
+	if err != nil {
+		return nil, err
+	}
+	productos, err := d.buscarProductos(ctx, terminos, limite)
+	if err != nil {
+		return nil, err
+	}
+
+	resultados := append(clientes, productos...)
+	sort.SliceStable(resultados, func(i, j int) bool {
+		return resultados[i].Puntaje > resultados[j].Puntaje
+	})
+	if len(resultados) > limite {
+		resultados = resultados[:limite]
+	}
+	return resultados, nil
+}
+
+// buscarClientes busca en nombre, cédula, email y dirección de los clientes activos
+func (d *Database) buscarClientes(ctx context.Context, terminos []string, limite int) ([]*ResultadoBusqueda, error) {
+	join, condicion, puntaje, args := d.coincidencia("clientes", "c", columnasBusquedaClientes, terminos)
+	filtro, argsTenant := filtroTenant(ctx, "c.tenant_id")
+	query := `
+		SELECT c.id, c.nombre, c.cedula, ` + puntaje + ` AS puntaje
+		FROM clientes c` + join + `
+		WHERE ` + condicion + ` AND c.activo = TRUE` + filtro + `
+		ORDER BY puntaje DESC, c.nombre
+		LIMIT ?`
+
+	args = append(args, argsTenant...)
+	rows, err := d.db.Query(query, append(args, limite)...)
+	if err != nil {
+		return nil, fmt.Errorf("error buscando clientes: %v", err)
+	}
+	defer rows.Close()
+
+	var resultados []*ResultadoBusqueda
+	for rows.Next() {
+		resultado := &ResultadoBusqueda{Tipo: ResultadoCliente}
+		if err := rows.Scan(&resultado.ID, &resultado.Titulo, &resultado.Detalle, &resultado.Puntaje); err != nil {
+			return nil, fmt.Errorf("error escaneando cliente: %v", err)
+		}
+		resultados = append(resultados, resultado)
+	}
+	return resultados, rows.Err()
+}
+
+// buscarProductos busca en descripción y código de las líneas de factura
+func (d *Database) buscarProductos(ctx context.Context, terminos []string, limite int) ([]*ResultadoBusqueda, error) {
+	join, condicion, puntaje, args := d.coincidencia("productos", "p", columnasBusquedaProductos, terminos)
+	filtro, argsTenant := filtroTenant(ctx, "f.tenant_id")
+	query := `
+		SELECT p.id, p.descripcion, p.codigo, p.factura_id, f.numero_factura, ` + puntaje + ` AS puntaje
+		FROM productos p
+		JOIN facturas f ON f.id = p.factura_id` + join + `
+		WHERE ` + condicion + filtro + `
+		ORDER BY puntaje DESC, p.id DESC
+		LIMIT ?`
+
+	args = append(args, argsTenant...)
+	rows, err := d.db.Query(query, append(args, limite)...)
+	if err != nil {
+		return nil, fmt.Errorf("error buscando productos: %v", err)
+	}
+	defer rows.Close()
+
+	var resultados []*ResultadoBusqueda
+	for rows.Next() {
+		resultado := &ResultadoBusqueda{Tipo: ResultadoProducto}
+		err := rows.Scan(&resultado.ID, &resultado.Titulo, &resultado.Detalle, &resultado.FacturaID,
+			&resultado.NumeroFactura, &resultado.Puntaje)
+		if err != nil {
+			return nil, fmt.Errorf("error escaneando producto: %v", err)
+		}
+		resultados = append(resultados, resultado)
+	}
+	return resultados, rows.Err()
+}
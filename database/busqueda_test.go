@@ -0,0 +1,123 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestTerminosBusqueda(t *testing.T) {
+	terminos := terminosBusqueda(`  Pérez "Distribuidora" S.A.  ventas@ejemplo.ec* `)
+	esperados := []string{"pérez", "distribuidora", "s", "a", "ventas", "ejemplo", "ec"}
+	if !reflect.DeepEqual(terminos, esperados) {
+		t.Errorf("terminosBusqueda() = %q, esperado %q", terminos, esperados)
+	}
+}
+
+// Funciona con o sin FTS5 (go test -tags sqlite_fts5 ./database ejercita los índices)
+func TestBuscarClientesYProductos(t *testing.T) {
+	db := nuevaDBTrabajosPrueba(t)
+
+	distribuidora, err := db.GuardarCliente(context.Background(), &ClienteDB{
+		Cedula: "1790012345001", Nombre: "Distribuidora Andina S.A.", Email: "ventas@andina.ec",
+		Direccion: "Av. Amazonas N34", TipoCliente: "EMPRESA",
+	})
+	if err != nil {
+		t.Fatalf("GuardarCliente() error: %v", err)
+	}
+	if _, err := db.GuardarCliente(context.Background(), &ClienteDB{Cedula: "1713175071", Nombre: "Juan Pérez", TipoCliente: "PERSONA_NATURAL"}); err != nil {
+		t.Fatalf("GuardarCliente() error: %v", err)
+	}
+	factura := guardarFacturaFiltro(t, db, clavePrueba("01", "001", "000000001"), 25)
+
+	// Coincidencia por prefijo de nombre, combinando términos
+	resultados, err := db.Buscar(context.Background(), "andina distrib", 10)
+	if err != nil {
+		t.Fatalf("Buscar() error: %v", err)
+	}
+	if len(resultados) != 1 || resultados[0].Tipo != ResultadoCliente || resultados[0].ID != distribuidora.ID {
+		t.Errorf("Buscar(andina distrib) = %+v", resultados)
+	}
+
+	// Email y dirección también se indexan
+	if resultados, _ := db.Buscar(context.Background(), "amazonas", 10); len(resultados) != 1 || resultados[0].ID != distribuidora.ID {
+		t.Errorf("Buscar(amazonas) = %+v", resultados)
+	}
+
+	// Las líneas de factura retornan la factura a la que pertenecen
+	resultados, err = db.Buscar(context.Background(), "filtros fil001", 10)
+	if err != nil {
+		t.Fatalf("Buscar() error: %v", err)
+	}
+	if len(resultados) != 1 || resultados[0].Tipo != ResultadoProducto ||
+		resultados[0].FacturaID != factura.ID || resultados[0].NumeroFactura != factura.NumeroFactura {
+		t.Errorf("Buscar(filtros fil001) = %+v", resultados)
+	}
+
+	// Los cambios del cliente se reflejan en la búsqueda y los inactivos se excluyen
+	distribuidora.Nombre = "Comercial Sierra"
+	if _, err := db.ActualizarCliente(context.Background(), distribuidora); err != nil {
+		t.Fatalf("ActualizarCliente() error: %v", err)
+	}
+	if resultados, _ := db.Buscar(context.Background(), "andina distrib", 10); len(resultados) != 0 {
+		t.Errorf("El nombre anterior no debería encontrarse: %+v", resultados)
+	}
+	if resultados, _ := db.Buscar(context.Background(), "sierra", 10); len(resultados) != 1 {
+		t.Errorf("Buscar(sierra) = %+v", resultados)
+	}
+	if err := db.DesactivarCliente(context.Background(), distribuidora.ID); err != nil {
+		t.Fatalf("DesactivarCliente() error: %v", err)
+	}
+	if resultados, _ := db.Buscar(context.Background(), "sierra", 10); len(resultados) != 0 {
+		t.Errorf("Un cliente inactivo no debería encontrarse: %+v", resultados)
+	}
+
+	if _, err := db.Buscar(context.Background(), " *** ", 10); !errors.Is(err, ErrBusquedaVacia) {
+		t.Errorf("Buscar(***) = %v, esperado ErrBusquedaVacia", err)
+	}
+}
+
+// El modo informado al arrancar corresponde a la búsqueda que se ejecuta
+func TestModoBusqueda(t *testing.T) {
+	db := nuevaDBTrabajosPrueba(t)
+	modo := db.ModoBusqueda()
+	if db.textoCompleto != (modo == "SQLite FTS5") {
+		t.Errorf("ModoBusqueda() = %q con textoCompleto = %v", modo, db.textoCompleto)
+	}
+}
+
+// Sin FTS5 el orden lo da la posición de la coincidencia; con FTS5 lo define bm25
+func TestBuscarLikeOrdenaPorRelevancia(t *testing.T) {
+	db := nuevaDBTrabajosPrueba(t)
+	if db.textoCompleto {
+		t.Skip("con FTS5 el orden lo define bm25")
+	}
+
+	for i, nombre := range []string{"Comercial Losandes", "Comercial Los Andes", "Andes Import", "Andes"} {
+		cliente := &ClienteDB{Cedula: fmt.Sprintf("179001234%d001", i), Nombre: nombre, TipoCliente: "EMPRESA"}
+		if _, err := db.GuardarCliente(context.Background(), cliente); err != nil {
+			t.Fatalf("GuardarCliente() error: %v", err)
+		}
+	}
+
+	resultados, err := db.Buscar(context.Background(), "andes", 10)
+	if err != nil {
+		t.Fatalf("Buscar() error: %v", err)
+	}
+	var nombres []string
+	for _, resultado := range resultados {
+		nombres = append(nombres, resultado.Titulo)
+	}
+	esperados := []string{"Andes", "Andes Import", "Comercial Los Andes", "Comercial Losandes"}
+	if !reflect.DeepEqual(nombres, esperados) {
+		t.Errorf("Buscar(andes) = %q, esperado %q", nombres, esperados)
+	}
+	for i := 1; i < len(resultados); i++ {
+		if resultados[i].Puntaje >= resultados[i-1].Puntaje {
+			t.Errorf("Puntajes sin orden estricto: %+v", resultados)
+			break
+		}
+	}
+}
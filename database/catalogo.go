@@ -0,0 +1,318 @@
+// Package database implementa el catálogo maestro de productos y servicios
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"go-facturacion-sri/models"
+)
+
+// ProductoCatalogoDB producto o servicio del catálogo maestro
+type ProductoCatalogoDB struct {
+	ID                 int       `json:"id"`
+	CodigoPrincipal    string    `json:"codigoPrincipal"`
+	CodigoAuxiliar     string    `json:"codigoAuxiliar"`
+	Descripcion        string    `json:"descripcion"`
+	UnidadMedida       string    `json:"unidadMedida"`
+	PrecioUnitario     float64   `json:"precioUnitario"`
+	CodigoIVA          string    `json:"codigoIva"`          // Código de porcentaje de IVA del SRI
+	CodigoICE          string    `json:"codigoIce"`          // Código de ICE del SRI (vacío si no grava ICE)
+	TarifaICE          float64   `json:"tarifaIce"`          // Porcentaje ad valorem de ICE
+	ControlaInventario bool      `json:"controlaInventario"` // Falso para servicios: no lleva stock
+	Activo             bool      `json:"activo"`
+	FechaCreacion      time.Time `json:"fechaCreacion"`
+	FechaActualizacion time.Time `json:"fechaActualizacion"`
+	TenantID           int       `json:"tenantId"` // Emisor dueño del producto; 0 = config.Config
+}
+
+// validarProductoCatalogo normaliza y valida los datos de un producto del catálogo
+func validarProductoCatalogo(producto *ProductoCatalogoDB) error {
+	producto.CodigoPrincipal = strings.TrimSpace(producto.CodigoPrincipal)
+	producto.CodigoAuxiliar = strings.TrimSpace(producto.CodigoAuxiliar)
+	producto.Descripcion = strings.TrimSpace(producto.Descripcion)
+
+	if producto.CodigoPrincipal == "" || len(producto.CodigoPrincipal) > 25 {
+		return fmt.Errorf("el código principal es requerido y no puede exceder 25 caracteres")
+	}
+	if len(producto.CodigoAuxiliar) > 25 {
+		return fmt.Errorf("el código auxiliar no puede exceder 25 caracteres")
+	}
+	if producto.Descripcion == "" || len(producto.Descripcion) > 300 {
+		return fmt.Errorf("la descripción es requerida y no puede exceder 300 caracteres")
+	}
+	if producto.PrecioUnitario <= 0 {
+		return fmt.Errorf("el precio unitario debe ser mayor a cero")
+	}
+	if producto.UnidadMedida == "" {
+		producto.UnidadMedida = "UNI"
+	}
+	if producto.CodigoIVA == "" {
+		producto.CodigoIVA = models.CodigoIVAGeneral
+	}
+	if _, err := models.TarifaIVA(producto.CodigoIVA); err != nil {
+		return err
+	}
+	if err := models.ValidarCodigoICE(producto.CodigoICE); err != nil {
+		return err
+	}
+	if producto.TarifaICE < 0 || (producto.TarifaICE > 0 && producto.CodigoICE == "") {
+		return fmt.Errorf("la tarifa de ICE requiere un código de ICE y no puede ser negativa")
+	}
+	return nil
+}
+
+// GuardarProductoCatalogo agrega un producto activo al catálogo del tenant del contexto
+func (d *Database) GuardarProductoCatalogo(ctx context.Context, producto *ProductoCatalogoDB) (*ProductoCatalogoDB, error) {
+	if err := validarProductoCatalogo(producto); err != nil {
+		return nil, err
+	}
+	producto.TenantID = tenantEscritura(ctx)
+
+	var existentes int
+	err := d.db.QueryRow("SELECT COUNT(*) FROM catalogo_productos WHERE codigo_principal = ? AND tenant_id = ?",
+		producto.CodigoPrincipal, producto.TenantID).Scan(&existentes)
+	if err != nil {
+		return nil, fmt.Errorf("error verificando código de producto: %v", err)
+	}
+	if existentes > 0 {
+		return nil, fmt.Errorf("ya existe un producto con código %s en el catálogo", producto.CodigoPrincipal)
+	}
+
+	ahora := time.Now().UTC()
+	var id int
+	err = d.db.QueryRow(`
+		INSERT INTO catalogo_productos (
+			codigo_principal, codigo_auxiliar, descripcion, unidad_medida, precio_unitario,
+			codigo_iva, codigo_ice, tarifa_ice, controla_inventario, activo, fecha_creacion, fecha_actualizacion, tenant_id
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, TRUE, ?, ?, ?)
+		RETURNING id`,
+		producto.CodigoPrincipal, producto.CodigoAuxiliar, producto.Descripcion, producto.UnidadMedida,
+		producto.PrecioUnitario, producto.CodigoIVA, producto.CodigoICE, producto.TarifaICE,
+		producto.ControlaInventario, ahora, ahora, producto.TenantID).Scan(&id)
+	if err != nil {
+		return nil, fmt.Errorf("error guardando producto del catálogo: %v", err)
+	}
+
+	creado, err := d.ObtenerProductoCatalogo(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	d.auditarCatalogo(ctx, id, "CREATE", nil, creado)
+	return creado, nil
+}
+
+// ObtenerProductoCatalogo obtiene un producto del catálogo por su ID, activo o no
+func (d *Database) ObtenerProductoCatalogo(ctx context.Context, id int) (*ProductoCatalogoDB, error) {
+	filtro, args := filtroTenant(ctx, "tenant_id")
+	producto, err := escanearProductoCatalogo(d.db.QueryRow(selectProductoCatalogo+" WHERE id = ?"+filtro,
+		append([]interface{}{id}, args...)...))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("producto del catálogo con ID %d no encontrado", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error obteniendo producto del catálogo: %v", err)
+	}
+	return producto, nil
+}
+
+// ObtenerProductoCatalogoPorCodigo busca un producto activo por código principal o auxiliar;
+// si ambos coinciden con productos distintos prevalece el código principal
+func (d *Database) ObtenerProductoCatalogoPorCodigo(ctx context.Context, codigo string) (*ProductoCatalogoDB, error) {
+	codigo = strings.TrimSpace(codigo)
+	filtro, args := filtroTenant(ctx, "tenant_id")
+	producto, err := escanearProductoCatalogo(d.db.QueryRow(selectProductoCatalogo+`
+		WHERE (codigo_principal = ? OR codigo_auxiliar = ?) AND activo = TRUE`+filtro+`
+		ORDER BY CASE WHEN codigo_principal = ? THEN 0 ELSE 1 END
+		LIMIT 1`, append(append([]interface{}{codigo, codigo}, args...), codigo)...))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("producto con código %s no encontrado en el catálogo", codigo)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error obteniendo producto del catálogo: %v", err)
+	}
+	return producto, nil
+}
+
+// ListarProductosCatalogo lista el catálogo filtrando por código o descripción
+func (d *Database) ListarProductosCatalogo(ctx context.Context, busqueda string, incluirInactivos bool, limite, offset int) ([]*ProductoCatalogoDB, error) {
+	filtro, args := filtroTenant(ctx, "tenant_id")
+	query := selectProductoCatalogo + " WHERE 1 = 1" + filtro
+	if !incluirInactivos {
+		query += " AND activo = TRUE"
+	}
+	if busqueda != "" {
+		query += " AND (LOWER(codigo_principal) LIKE LOWER(?) OR LOWER(codigo_auxiliar) LIKE LOWER(?) OR LOWER(descripcion) LIKE LOWER(?))"
+		patron := "%" + busqueda + "%"
+		args = append(args, patron, patron, patron)
+	}
+	query += " ORDER BY codigo_principal LIMIT ? OFFSET ?"
+	args = append(args, limite, offset)
+
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error consultando catálogo: %v", err)
+	}
+	defer rows.Close()
+
+	var productos []*ProductoCatalogoDB
+	for rows.Next() {
+		producto, err := escanearProductoCatalogo(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error escaneando producto del catálogo: %v", err)
+		}
+		productos = append(productos, producto)
+	}
+	return productos, rows.Err()
+}
+
+// ActualizarProductoCatalogo reemplaza los datos de un producto activo del catálogo
+func (d *Database) ActualizarProductoCatalogo(ctx context.Context, producto *ProductoCatalogoDB) (*ProductoCatalogoDB, error) {
+	antes, err := d.ObtenerProductoCatalogo(ctx, producto.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !antes.Activo {
+		return nil, fmt.Errorf("el producto %s está inactivo", antes.CodigoPrincipal)
+	}
+	if err := validarProductoCatalogo(producto); err != nil {
+		return nil, err
+	}
+
+	var duplicados int
+	err = d.db.QueryRow("SELECT COUNT(*) FROM catalogo_productos WHERE codigo_principal = ? AND id <> ? AND tenant_id = ?",
+		producto.CodigoPrincipal, producto.ID, antes.TenantID).Scan(&duplicados)
+	if err != nil {
+		return nil, fmt.Errorf("error verificando código de producto: %v", err)
+	}
+	if duplicados > 0 {
+		return nil, fmt.Errorf("ya existe un producto con código %s en el catálogo", producto.CodigoPrincipal)
+	}
+
+	_, err = d.db.Exec(`
+		UPDATE catalogo_productos
+		SET codigo_principal = ?, codigo_auxiliar = ?, descripcion = ?, unidad_medida = ?, precio_unitario = ?,
+		    codigo_iva = ?, codigo_ice = ?, tarifa_ice = ?, controla_inventario = ?, fecha_actualizacion = ?
+		WHERE id = ?`,
+		producto.CodigoPrincipal, producto.CodigoAuxiliar, producto.Descripcion, producto.UnidadMedida,
+		producto.PrecioUnitario, producto.CodigoIVA, producto.CodigoICE, producto.TarifaICE,
+		producto.ControlaInventario, time.Now().UTC(), producto.ID)
+	if err != nil {
+		return nil, fmt.Errorf("error actualizando producto del catálogo: %v", err)
+	}
+
+	despues, err := d.ObtenerProductoCatalogo(ctx, producto.ID)
+	if err != nil {
+		return nil, err
+	}
+	d.auditarCatalogo(ctx, producto.ID, "UPDATE", antes, despues)
+	return despues, nil
+}
+
+// DesactivarProductoCatalogo retira un producto del catálogo sin borrarlo; las facturas
+// emitidas conservan sus propios datos de línea
+func (d *Database) DesactivarProductoCatalogo(ctx context.Context, id int) error {
+	antes, err := d.ObtenerProductoCatalogo(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	_, err = d.db.Exec("UPDATE catalogo_productos SET activo = FALSE, fecha_actualizacion = ? WHERE id = ?",
+		time.Now().UTC(), id)
+	if err != nil {
+		return fmt.Errorf("error desactivando producto del catálogo: %v", err)
+	}
+
+	despues := *antes
+	despues.Activo = false
+	d.auditarCatalogo(ctx, id, "DEACTIVATE", antes, &despues)
+	return nil
+}
+
+// CompletarDesdeCatalogo completa las líneas de factura con los datos del catálogo. Una línea
+// sin descripción ni precio es una referencia al catálogo y su código debe existir; en las
+// demás líneas el catálogo solo aporta los datos que falten (p.ej. los códigos de impuesto).
+// Se usa el catálogo del tenant del contexto.
+func (d *Database) CompletarDesdeCatalogo(ctx context.Context, productos []models.ProductoInput) ([]models.ProductoInput, error) {
+	completos := make([]models.ProductoInput, len(productos))
+	for i, producto := range productos {
+		referencia := producto.Descripcion == "" && producto.PrecioUnitario == 0
+
+		catalogo, err := d.ObtenerProductoCatalogoPorCodigo(ctx, producto.Codigo)
+		if err != nil {
+			if referencia {
+				return nil, fmt.Errorf("producto %d: %v", i+1, err)
+			}
+			completos[i] = producto
+			continue
+		}
+
+		producto.Codigo = catalogo.CodigoPrincipal
+		if producto.Descripcion == "" {
+			producto.Descripcion = catalogo.Descripcion
+		}
+		if producto.PrecioUnitario == 0 {
+			producto.PrecioUnitario = catalogo.PrecioUnitario
+		}
+		if producto.CodigoIVA == "" {
+			producto.CodigoIVA = catalogo.CodigoIVA
+		}
+		if producto.CodigoICE == "" {
+			producto.CodigoICE = catalogo.CodigoICE
+			producto.TarifaICE = catalogo.TarifaICE
+		}
+		completos[i] = producto
+	}
+	return completos, nil
+}
+
+// auditarCatalogo registra en audit_log un cambio del catálogo
+func (d *Database) auditarCatalogo(ctx context.Context, id int, operacion string, antes, despues *ProductoCatalogoDB) {
+	audit := &AuditLogDB{
+		Tabla:      "catalogo_productos",
+		RegistroID: id,
+		Operacion:  operacion,
+	}
+	if despues != nil {
+		audit.TenantID = despues.TenantID
+	}
+	if antes != nil {
+		datos, _ := json.Marshal(antes)
+		audit.DatosAntes = string(datos)
+	}
+	if despues != nil {
+		datos, _ := json.Marshal(despues)
+		audit.DatosDespues = string(datos)
+	}
+	d.RegistrarAuditoria(ctx, audit)
+}
+
+const selectProductoCatalogo = `
+	SELECT id, codigo_principal, codigo_auxiliar, descripcion, unidad_medida, precio_unitario,
+	       codigo_iva, codigo_ice, tarifa_ice, controla_inventario, activo, fecha_creacion, fecha_actualizacion,
+	       tenant_id
+	FROM catalogo_productos`
+
+// escanearProductoCatalogo convierte una fila en ProductoCatalogoDB
+func escanearProductoCatalogo(fila filaEscaneable) (*ProductoCatalogoDB, error) {
+	producto := &ProductoCatalogoDB{}
+	var codigoAuxiliar, codigoICE sql.NullString
+
+	err := fila.Scan(
+		&producto.ID, &producto.CodigoPrincipal, &codigoAuxiliar, &producto.Descripcion,
+		&producto.UnidadMedida, &producto.PrecioUnitario, &producto.CodigoIVA, &codigoICE,
+		&producto.TarifaICE, &producto.ControlaInventario, &producto.Activo, &producto.FechaCreacion,
+		&producto.FechaActualizacion, &producto.TenantID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	producto.CodigoAuxiliar = codigoAuxiliar.String
+	producto.CodigoICE = codigoICE.String
+	return producto, nil
+}
@@ -0,0 +1,100 @@
+package database
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go-facturacion-sri/models"
+)
+
+func TestCatalogoCRUD(t *testing.T) {
+	db := nuevaDBTrabajosPrueba(t)
+
+	producto, err := db.GuardarProductoCatalogo(context.Background(), &ProductoCatalogoDB{
+		CodigoPrincipal: "LAPTOP001",
+		CodigoAuxiliar:  "7861234567890",
+		Descripcion:     "Laptop Dell Inspiron 15",
+		PrecioUnitario:  450,
+	})
+	if err != nil {
+		t.Fatalf("GuardarProductoCatalogo() error: %v", err)
+	}
+	if !producto.Activo || producto.UnidadMedida != "UNI" || producto.CodigoIVA != models.CodigoIVAGeneral {
+		t.Errorf("Valores por defecto inesperados: %+v", producto)
+	}
+
+	if _, err := db.GuardarProductoCatalogo(context.Background(), &ProductoCatalogoDB{CodigoPrincipal: "LAPTOP001", Descripcion: "Duplicado", PrecioUnitario: 1}); err == nil {
+		t.Error("GuardarProductoCatalogo() con código duplicado debió fallar")
+	}
+	if _, err := db.GuardarProductoCatalogo(context.Background(), &ProductoCatalogoDB{CodigoPrincipal: "X1", Descripcion: "IVA inválido", PrecioUnitario: 1, CodigoIVA: "99"}); err == nil {
+		t.Error("GuardarProductoCatalogo() con código de IVA inválido debió fallar")
+	}
+
+	// Búsqueda por código auxiliar y por descripción
+	encontrado, err := db.ObtenerProductoCatalogoPorCodigo(context.Background(), "7861234567890")
+	if err != nil || encontrado.ID != producto.ID {
+		t.Errorf("ObtenerProductoCatalogoPorCodigo(auxiliar) = %+v, %v", encontrado, err)
+	}
+	productos, err := db.ListarProductosCatalogo(context.Background(), "inspiron", false, 10, 0)
+	if err != nil || len(productos) != 1 {
+		t.Errorf("ListarProductosCatalogo() = %d productos, %v", len(productos), err)
+	}
+
+	producto.PrecioUnitario = 475
+	actualizado, err := db.ActualizarProductoCatalogo(context.Background(), producto)
+	if err != nil || actualizado.PrecioUnitario != 475 {
+		t.Fatalf("ActualizarProductoCatalogo() = %+v, %v", actualizado, err)
+	}
+
+	if err := db.DesactivarProductoCatalogo(context.Background(), producto.ID); err != nil {
+		t.Fatalf("DesactivarProductoCatalogo() error: %v", err)
+	}
+	if _, err := db.ObtenerProductoCatalogoPorCodigo(context.Background(), "LAPTOP001"); err == nil {
+		t.Error("Un producto inactivo no debe resolverse por código")
+	}
+	if productos, _ := db.ListarProductosCatalogo(context.Background(), "", true, 10, 0); len(productos) != 1 || productos[0].Activo {
+		t.Errorf("ListarProductosCatalogo(incluirInactivos) = %+v", productos)
+	}
+}
+
+func TestCompletarDesdeCatalogo(t *testing.T) {
+	db := nuevaDBTrabajosPrueba(t)
+
+	_, err := db.GuardarProductoCatalogo(context.Background(), &ProductoCatalogoDB{
+		CodigoPrincipal: "LICOR001",
+		Descripcion:     "Licor 750ml",
+		PrecioUnitario:  20,
+		CodigoICE:       "3031",
+		TarifaICE:       75,
+	})
+	if err != nil {
+		t.Fatalf("GuardarProductoCatalogo() error: %v", err)
+	}
+
+	productos, err := db.CompletarDesdeCatalogo(context.Background(), []models.ProductoInput{
+		{Codigo: "LICOR001", Cantidad: 2},
+		{Codigo: "LICOR001", Cantidad: 1, Descripcion: "Licor en promoción", PrecioUnitario: 15},
+		{Codigo: "LIBRE001", Cantidad: 1, Descripcion: "Servicio sin catálogo", PrecioUnitario: 10},
+	})
+	if err != nil {
+		t.Fatalf("CompletarDesdeCatalogo() error: %v", err)
+	}
+
+	referencia := productos[0]
+	if referencia.Descripcion != "Licor 750ml" || referencia.PrecioUnitario != 20 ||
+		referencia.CodigoIVA != models.CodigoIVAGeneral || referencia.CodigoICE != "3031" || referencia.TarifaICE != 75 {
+		t.Errorf("Referencia al catálogo mal completada: %+v", referencia)
+	}
+	if productos[1].PrecioUnitario != 15 || productos[1].CodigoICE != "3031" {
+		t.Errorf("Los datos enviados deben conservarse y completarse los impuestos: %+v", productos[1])
+	}
+	if productos[2].CodigoIVA != "" {
+		t.Errorf("Una línea fuera del catálogo no debe modificarse: %+v", productos[2])
+	}
+
+	_, err = db.CompletarDesdeCatalogo(context.Background(), []models.ProductoInput{{Codigo: "NOEXISTE", Cantidad: 1}})
+	if err == nil || !strings.Contains(err.Error(), "NOEXISTE") {
+		t.Errorf("Una referencia a un código inexistente debió fallar, obtuvo %v", err)
+	}
+}
@@ -0,0 +1,234 @@
+// Package database implementa la cola de fallidos (dead-letter queue) de envíos al SRI
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"go-facturacion-sri/sri"
+)
+
+// Estados de un registro de la cola de fallidos
+const (
+	FallidoPendiente  = "PENDIENTE"
+	FallidoReencolado = "REENCOLADO"
+	FallidoDescartado = "DESCARTADO"
+)
+
+// Origen del registro en la cola de fallidos
+const (
+	OrigenPipeline   = "PIPELINE"
+	OrigenClienteSRI = "CLIENTE_SRI"
+)
+
+// FallidoDB comprobante que agotó sus reintentos con el SRI
+type FallidoDB struct {
+	ID                 int                  `json:"id"`
+	FacturaID          int                  `json:"facturaId,omitempty"` // 0 si la clave no corresponde a una factura
+	TrabajoID          int                  `json:"trabajoId,omitempty"` // Trabajo que falló o, tras reencolar, el nuevo
+	ClaveAcceso        string               `json:"claveAcceso"`
+	Origen             string               `json:"origen"` // PIPELINE o CLIENTE_SRI
+	Etapa              string               `json:"etapa"`  // Etapa u operación que agotó los intentos
+	XMLComprobante     string               `json:"-"`
+	Intentos           []sri.IntentoFallido `json:"intentos"`
+	UltimoError        string               `json:"ultimoError"`
+	Recuperable        bool                 `json:"recuperable"`
+	Estado             string               `json:"estado"`      // PENDIENTE, REENCOLADO, DESCARTADO
+	Reencolados        int                  `json:"reencolados"` // Fallos previos de la misma factura
+	MotivoDescarte     string               `json:"motivoDescarte,omitempty"`
+	FechaCreacion      time.Time            `json:"fechaCreacion"`
+	FechaActualizacion time.Time            `json:"fechaActualizacion"`
+}
+
+// GuardarFallido agrega un registro PENDIENTE a la cola de fallidos. Reencolados se calcula
+// con los registros anteriores de la misma factura para limitar los reencolados automáticos.
+func (d *Database) GuardarFallido(fallido *FallidoDB) (*FallidoDB, error) {
+	intentos, err := json.Marshal(fallido.Intentos)
+	if err != nil {
+		return nil, fmt.Errorf("error serializando intentos fallidos: %v", err)
+	}
+
+	if fallido.FacturaID > 0 {
+		err := d.db.QueryRow("SELECT COUNT(*) FROM cola_fallidos WHERE factura_id = ?", fallido.FacturaID).Scan(&fallido.Reencolados)
+		if err != nil {
+			return nil, fmt.Errorf("error contando fallidos de la factura: %v", err)
+		}
+	}
+
+	ahora := time.Now().UTC()
+	var id int
+	err = d.db.QueryRow(`
+		INSERT INTO cola_fallidos (
+			factura_id, trabajo_id, clave_acceso, origen, etapa, xml_comprobante, intentos,
+			ultimo_error, recuperable, estado, reencolados, fecha_creacion, fecha_actualizacion
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id`,
+		nuloSiCero(fallido.FacturaID), nuloSiCero(fallido.TrabajoID), fallido.ClaveAcceso, fallido.Origen,
+		fallido.Etapa, fallido.XMLComprobante, string(intentos), fallido.UltimoError, fallido.Recuperable,
+		FallidoPendiente, fallido.Reencolados, ahora, ahora).Scan(&id)
+	if err != nil {
+		return nil, fmt.Errorf("error registrando fallido: %v", err)
+	}
+	return d.ObtenerFallido(id)
+}
+
+// RegistrarFallido implementa sri.ColaFallidos para los clientes SOAP; la factura se
+// identifica por la clave de acceso cuando existe
+func (d *Database) RegistrarFallido(fallido sri.FallidoSRI) error {
+	registro := &FallidoDB{
+		ClaveAcceso:    fallido.ClaveAcceso,
+		Origen:         OrigenClienteSRI,
+		Etapa:          fallido.Operacion,
+		XMLComprobante: string(fallido.XMLComprobante),
+		Intentos:       fallido.Intentos,
+		Recuperable:    fallido.Recuperable,
+	}
+	if n := len(fallido.Intentos); n > 0 {
+		registro.UltimoError = fallido.Intentos[n-1].Error
+	}
+
+	if fallido.ClaveAcceso != "" {
+		err := d.db.QueryRow("SELECT id FROM facturas WHERE clave_acceso = ?", fallido.ClaveAcceso).Scan(&registro.FacturaID)
+		if err != nil && err != sql.ErrNoRows {
+			return fmt.Errorf("error buscando factura del fallido: %v", err)
+		}
+	}
+
+	_, err := d.GuardarFallido(registro)
+	return err
+}
+
+// ObtenerFallido obtiene un registro de la cola de fallidos con el XML del comprobante
+func (d *Database) ObtenerFallido(id int) (*FallidoDB, error) {
+	fallido, err := escanearFallido(d.db.QueryRow(selectFallido+" WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("fallido con ID %d no encontrado", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error obteniendo fallido: %v", err)
+	}
+	return fallido, nil
+}
+
+// ListarFallidos lista la cola de fallidos, opcionalmente filtrada por estado
+func (d *Database) ListarFallidos(estado string, limite, offset int) ([]*FallidoDB, error) {
+	query := selectFallido
+	args := []interface{}{}
+	if estado != "" {
+		query += " WHERE estado = ?"
+		args = append(args, estado)
+	}
+	query += " ORDER BY id DESC LIMIT ? OFFSET ?"
+	args = append(args, limite, offset)
+
+	return d.consultarFallidos(query, args...)
+}
+
+// FallidosReencolables retorna los fallidos PENDIENTES recuperables de facturas conocidas
+// que aún no superan el máximo de reencolados
+func (d *Database) FallidosReencolables(maxReencolados int) ([]*FallidoDB, error) {
+	return d.consultarFallidos(selectFallido+`
+		WHERE estado = ? AND recuperable = TRUE AND factura_id IS NOT NULL AND reencolados < ?
+		ORDER BY id`, FallidoPendiente, maxReencolados)
+}
+
+// MarcarFallidoReencolado registra que el fallido volvió a la cola de autorización con el trabajo indicado
+func (d *Database) MarcarFallidoReencolado(id, trabajoID int) error {
+	return d.cerrarFallido(id, FallidoReencolado, trabajoID, "")
+}
+
+// DescartarFallido marca el fallido como descartado; no volverá a reencolarse
+func (d *Database) DescartarFallido(id int, motivo string) error {
+	return d.cerrarFallido(id, FallidoDescartado, 0, motivo)
+}
+
+// cerrarFallido saca un fallido PENDIENTE de la cola
+func (d *Database) cerrarFallido(id int, estado string, trabajoID int, motivo string) error {
+	result, err := d.db.Exec(`
+		UPDATE cola_fallidos
+		SET estado = ?, trabajo_id = COALESCE(?, trabajo_id), motivo_descarte = ?, fecha_actualizacion = ?
+		WHERE id = ? AND estado = ?`,
+		estado, nuloSiCero(trabajoID), motivo, time.Now().UTC(), id, FallidoPendiente)
+	if err != nil {
+		return fmt.Errorf("error actualizando fallido: %v", err)
+	}
+
+	filas, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error actualizando fallido: %v", err)
+	}
+	if filas == 0 {
+		fallido, err := d.ObtenerFallido(id)
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("el fallido %d ya está %s", id, fallido.Estado)
+	}
+	return nil
+}
+
+const selectFallido = `
+	SELECT id, factura_id, trabajo_id, clave_acceso, origen, etapa, xml_comprobante, intentos,
+	       ultimo_error, recuperable, estado, reencolados, motivo_descarte, fecha_creacion, fecha_actualizacion
+	FROM cola_fallidos`
+
+// consultarFallidos ejecuta una consulta sobre selectFallido
+func (d *Database) consultarFallidos(query string, args ...interface{}) ([]*FallidoDB, error) {
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error consultando cola de fallidos: %v", err)
+	}
+	defer rows.Close()
+
+	var fallidos []*FallidoDB
+	for rows.Next() {
+		fallido, err := escanearFallido(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error escaneando fallido: %v", err)
+		}
+		fallidos = append(fallidos, fallido)
+	}
+
+	return fallidos, rows.Err()
+}
+
+// escanearFallido convierte una fila en FallidoDB
+func escanearFallido(fila filaEscaneable) (*FallidoDB, error) {
+	fallido := &FallidoDB{}
+	var facturaID, trabajoID sql.NullInt64
+	var claveAcceso, xmlComprobante, intentos, ultimoError, motivo sql.NullString
+
+	err := fila.Scan(
+		&fallido.ID, &facturaID, &trabajoID, &claveAcceso, &fallido.Origen, &fallido.Etapa,
+		&xmlComprobante, &intentos, &ultimoError, &fallido.Recuperable, &fallido.Estado,
+		&fallido.Reencolados, &motivo, &fallido.FechaCreacion, &fallido.FechaActualizacion,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	fallido.FacturaID = int(facturaID.Int64)
+	fallido.TrabajoID = int(trabajoID.Int64)
+	fallido.ClaveAcceso = claveAcceso.String
+	fallido.XMLComprobante = xmlComprobante.String
+	fallido.UltimoError = ultimoError.String
+	fallido.MotivoDescarte = motivo.String
+
+	if intentos.String != "" {
+		if err := json.Unmarshal([]byte(intentos.String), &fallido.Intentos); err != nil {
+			return nil, fmt.Errorf("error leyendo intentos fallidos: %v", err)
+		}
+	}
+
+	return fallido, nil
+}
+
+// nuloSiCero guarda NULL en lugar de un ID 0
+func nuloSiCero(id int) interface{} {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
@@ -0,0 +1,108 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"go-facturacion-sri/sri"
+)
+
+func TestGuardarYListarFallidos(t *testing.T) {
+	db := nuevaDBTrabajosPrueba(t)
+
+	intentos := []sri.IntentoFallido{
+		sri.NuevoIntentoFallido(1, errors.New("timeout de conexión")),
+		sri.NuevoIntentoFallido(2, errors.New("timeout de conexión")),
+	}
+	fallido, err := db.GuardarFallido(&FallidoDB{
+		FacturaID:      3,
+		TrabajoID:      9,
+		ClaveAcceso:    "CLAVE-FALLIDA",
+		Origen:         OrigenPipeline,
+		Etapa:          EtapaEnviar,
+		XMLComprobante: "<factura/>",
+		Intentos:       intentos,
+		UltimoError:    "timeout de conexión",
+		Recuperable:    true,
+	})
+	if err != nil {
+		t.Fatalf("GuardarFallido() error: %v", err)
+	}
+	if fallido.Estado != FallidoPendiente || fallido.Reencolados != 0 {
+		t.Errorf("Fallido inicial inesperado: %+v", fallido)
+	}
+	if fallido.XMLComprobante != "<factura/>" || len(fallido.Intentos) != 2 || fallido.Intentos[1].Numero != 2 {
+		t.Errorf("Intentos o XML no persistidos: %+v", fallido)
+	}
+
+	// Un segundo fallo de la misma factura cuenta el anterior
+	segundo, err := db.GuardarFallido(&FallidoDB{FacturaID: 3, ClaveAcceso: "CLAVE-FALLIDA", Origen: OrigenPipeline, Etapa: EtapaEnviar})
+	if err != nil {
+		t.Fatalf("GuardarFallido() segundo error: %v", err)
+	}
+	if segundo.Reencolados != 1 {
+		t.Errorf("Reencolados = %d, esperado 1", segundo.Reencolados)
+	}
+
+	pendientes, err := db.ListarFallidos(FallidoPendiente, 10, 0)
+	if err != nil {
+		t.Fatalf("ListarFallidos() error: %v", err)
+	}
+	if len(pendientes) != 2 {
+		t.Errorf("Pendientes = %d, esperados 2", len(pendientes))
+	}
+}
+
+func TestFallidosReencolablesYCierre(t *testing.T) {
+	db := nuevaDBTrabajosPrueba(t)
+
+	recuperable, _ := db.GuardarFallido(&FallidoDB{FacturaID: 1, ClaveAcceso: "A", Origen: OrigenPipeline, Recuperable: true})
+	db.GuardarFallido(&FallidoDB{FacturaID: 2, ClaveAcceso: "B", Origen: OrigenPipeline, Recuperable: false})
+	db.GuardarFallido(&FallidoDB{ClaveAcceso: "C", Origen: OrigenClienteSRI, Recuperable: true})
+
+	reencolables, err := db.FallidosReencolables(3)
+	if err != nil {
+		t.Fatalf("FallidosReencolables() error: %v", err)
+	}
+	if len(reencolables) != 1 || reencolables[0].ID != recuperable.ID {
+		t.Fatalf("Reencolables inesperados: %+v", reencolables)
+	}
+
+	if err := db.MarcarFallidoReencolado(recuperable.ID, 42); err != nil {
+		t.Fatalf("MarcarFallidoReencolado() error: %v", err)
+	}
+	reencolado, _ := db.ObtenerFallido(recuperable.ID)
+	if reencolado.Estado != FallidoReencolado || reencolado.TrabajoID != 42 {
+		t.Errorf("Fallido reencolado inesperado: %+v", reencolado)
+	}
+
+	// Solo los fallidos pendientes se pueden cerrar
+	if err := db.DescartarFallido(recuperable.ID, "duplicado"); err == nil {
+		t.Error("Se esperaba error al descartar un fallido ya reencolado")
+	}
+}
+
+func TestRegistrarFallidoDesdeClienteSRI(t *testing.T) {
+	db := nuevaDBTrabajosPrueba(t)
+	facturaID := facturaEstadosPrueba(t, db, "CLAVE-CLIENTE")
+
+	err := db.RegistrarFallido(sri.FallidoSRI{
+		Operacion:      sri.OperacionRecepcion,
+		ClaveAcceso:    "CLAVE-CLIENTE",
+		XMLComprobante: []byte("<factura/>"),
+		Intentos:       []sri.IntentoFallido{{Numero: 1, Error: "servicio no disponible", Fecha: time.Now()}},
+		Recuperable:    true,
+	})
+	if err != nil {
+		t.Fatalf("RegistrarFallido() error: %v", err)
+	}
+
+	fallidos, _ := db.ListarFallidos("", 10, 0)
+	if len(fallidos) != 1 {
+		t.Fatalf("Fallidos = %d, esperado 1", len(fallidos))
+	}
+	if fallidos[0].FacturaID != facturaID || fallidos[0].Origen != OrigenClienteSRI || fallidos[0].UltimoError != "servicio no disponible" {
+		t.Errorf("Fallido registrado inesperado: %+v", fallidos[0])
+	}
+}
@@ -2,19 +2,27 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3" // Driver SQLite
+	"go-facturacion-sri/config"
 	"go-facturacion-sri/models"
 )
 
 // Database estructura para manejar la base de datos
 type Database struct {
-	db *sql.DB
+	db       *conexion
+	dialecto Dialecto
+	ruta     string // Archivo SQLite, usado por los respaldos (vacío en PostgreSQL)
+
+	textoCompleto bool // SQLite con índices FTS5; sin ellos la búsqueda usa LIKE
 }
 
 // FacturaDB estructura de factura para base de datos
@@ -41,6 +49,7 @@ type FacturaDB struct {
 	TipoEmision          string    `json:"tipoEmision"`
 	FechaCreacion        time.Time `json:"fechaCreacion"`
 	FechaActualizacion   time.Time `json:"fechaActualizacion"`
+	TenantID             int       `json:"tenantId"` // Emisor; 0 = config.Config
 }
 
 // ProductoDB estructura de producto para base de datos
@@ -71,6 +80,7 @@ type ClienteDB struct {
 	TipoCliente   string    `json:"tipoCliente"` // PERSONA_NATURAL, EMPRESA
 	FechaCreacion time.Time `json:"fechaCreacion"`
 	Activo        bool      `json:"activo"`
+	TenantID      int       `json:"tenantId"`
 }
 
 // ConfigDB estructura de configuración para base de datos
@@ -94,167 +104,140 @@ type AuditLogDB struct {
 	IPAddress   string    `json:"ipAddress"`   // IP del usuario
 	UserAgent   string    `json:"userAgent"`   // User agent del cliente
 	Timestamp   time.Time `json:"timestamp"`   // Momento de la operación
+	Hash        string    `json:"hash"`        // SHA-256 del contenido encadenado al hash anterior
+	HashAnterior string   `json:"hashAnterior"` // Hash de la entrada previa (vacío en la primera)
+	RequestID   string    `json:"requestId"`   // Petición HTTP que originó la operación
+	TenantID    int       `json:"tenantId"`    // Emisor dueño del registro afectado
 }
 
-// New crea una nueva instancia de base de datos
+// New crea una nueva instancia de base de datos SQLite y aplica las migraciones pendientes
 func New(dbPath string) (*Database, error) {
-	// Crear directorio si no existe
-	if err := os.MkdirAll("database", 0755); err != nil {
-		return nil, fmt.Errorf("error creando directorio database: %v", err)
+	return Abrir(DialectoSQLite, dbPath)
+}
+
+// NuevaDesdeConfig abre la base de datos con el driver configurado: la ruta del archivo
+// para SQLite o el DSN para PostgreSQL
+func NuevaDesdeConfig(cfg config.DatabaseConfig) (*Database, error) {
+	dialecto, fuente, err := fuenteDesdeConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	database, err := Abrir(dialecto, fuente)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.MaxConexiones > 0 {
+		database.db.SetMaxOpenConns(cfg.MaxConexiones)
+	}
+	return database, nil
+}
+
+// fuenteDesdeConfig obtiene el dialecto y la cadena de conexión de la configuración
+func fuenteDesdeConfig(cfg config.DatabaseConfig) (Dialecto, string, error) {
+	dialecto, err := ParsearDialecto(cfg.Driver)
+	if err != nil {
+		return "", "", err
+	}
+	if dialecto == DialectoPostgres {
+		if cfg.DSN == "" {
+			return "", "", fmt.Errorf("el driver postgres requiere database.dsn")
+		}
+		return dialecto, cfg.DSN, nil
+	}
+	return dialecto, cfg.Ruta, nil
+}
+
+// Abrir conecta al motor indicado y aplica las migraciones pendientes
+func Abrir(dialecto Dialecto, fuente string) (*Database, error) {
+	database, err := abrir(dialecto, fuente)
+	if err != nil {
+		return nil, err
+	}
+
+	// Verificar versión del esquema y migrar si hay cambios pendientes
+	if err := database.verificarEsquema(); err != nil {
+		database.Close()
+		return nil, err
+	}
+	if _, err := database.Migrar(); err != nil {
+		database.Close()
+		return nil, fmt.Errorf("error migrando esquema: %v", err)
+	}
+	if err := database.prepararBusqueda(); err != nil {
+		database.Close()
+		return nil, err
+	}
+
+	log.Printf("✅ Base de datos inicializada (%s): %s", dialecto, database.descripcion())
+	return database, nil
+}
+
+// abrir conecta a la base de datos sin aplicar migraciones
+func abrir(dialecto Dialecto, fuente string) (*Database, error) {
+	var ruta, dsn string
+	switch dialecto {
+	case DialectoSQLite:
+		// Crear directorio si no existe
+		ruta = strings.SplitN(fuente, "?", 2)[0]
+		if err := os.MkdirAll(filepath.Dir(ruta), 0755); err != nil {
+			return nil, fmt.Errorf("error creando directorio de base de datos: %v", err)
+		}
+
+		// busy_timeout evita errores "database is locked" cuando la API y los workers de
+		// autorización escriben a la vez, y txlock=immediate evita el bloqueo mutuo de
+		// transacciones que leen antes de escribir
+		dsn = fuente
+		if !strings.Contains(dsn, "?") {
+			dsn += "?_busy_timeout=5000&_txlock=immediate"
+		}
+	case DialectoPostgres:
+		dsn = fuente
+	default:
+		return nil, fmt.Errorf("driver de base de datos no soportado: %s", dialecto)
 	}
 
-	// Abrir conexión a SQLite
-	db, err := sql.Open("sqlite3", dbPath)
+	db, err := sql.Open(string(dialecto), dsn)
 	if err != nil {
 		return nil, fmt.Errorf("error abriendo base de datos: %v", err)
 	}
 
 	// Verificar conexión
 	if err := db.Ping(); err != nil {
+		db.Close()
 		return nil, fmt.Errorf("error conectando a la base de datos: %v", err)
 	}
 
-	database := &Database{db: db}
-
-	// Crear tablas si no existen
-	if err := database.createTables(); err != nil {
-		return nil, fmt.Errorf("error creando tablas: %v", err)
+	database := &Database{db: &conexion{DB: db, dialecto: dialecto}, dialecto: dialecto, ruta: ruta}
+	if err := database.asegurarTablaMigraciones(); err != nil {
+		db.Close()
+		return nil, err
 	}
-
-	log.Printf("✅ Base de datos inicializada: %s", dbPath)
 	return database, nil
 }
 
-// Close cierra la conexión a la base de datos
-func (d *Database) Close() error {
-	if d.db != nil {
-		return d.db.Close()
-	}
-	return nil
+// Dialecto motor SQL de la base de datos abierta
+func (d *Database) Dialecto() Dialecto {
+	return d.dialecto
 }
 
-// createTables crea las tablas necesarias
-func (d *Database) createTables() error {
-	// Tabla de facturas
-	facturaSQL := `
-	CREATE TABLE IF NOT EXISTS facturas (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		numero_factura TEXT NOT NULL UNIQUE,
-		clave_acceso TEXT NOT NULL UNIQUE,
-		fecha_emision DATETIME NOT NULL,
-		cliente_nombre TEXT NOT NULL,
-		cliente_cedula TEXT NOT NULL,
-		cliente_direccion TEXT,
-		cliente_telefono TEXT,
-		cliente_email TEXT,
-		subtotal REAL NOT NULL,
-		iva REAL NOT NULL,
-		total REAL NOT NULL,
-		estado TEXT NOT NULL DEFAULT 'BORRADOR',
-		numero_autorizacion TEXT,
-		fecha_autorizacion DATETIME,
-		xml_original TEXT,
-		xml_autorizado TEXT,
-		observaciones_sri TEXT,
-		ambiente TEXT NOT NULL DEFAULT 'PRUEBAS',
-		tipo_emision TEXT NOT NULL DEFAULT 'NORMAL',
-		fecha_creacion DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
-		fecha_actualizacion DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
-	);`
-
-	// Tabla de productos
-	productoSQL := `
-	CREATE TABLE IF NOT EXISTS productos (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		factura_id INTEGER NOT NULL,
-		codigo TEXT NOT NULL,
-		codigo_principal TEXT,
-		codigo_auxiliar TEXT,
-		descripcion TEXT NOT NULL,
-		unidad_medida TEXT DEFAULT 'UNI',
-		cantidad REAL NOT NULL,
-		precio_unitario REAL NOT NULL,
-		descuento REAL DEFAULT 0,
-		precio_total_sin_iva REAL NOT NULL,
-		precio_total REAL NOT NULL,
-		iva REAL NOT NULL,
-		FOREIGN KEY (factura_id) REFERENCES facturas (id) ON DELETE CASCADE
-	);`
-
-	// Tabla de clientes
-	clienteSQL := `
-	CREATE TABLE IF NOT EXISTS clientes (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		cedula TEXT NOT NULL UNIQUE,
-		nombre TEXT NOT NULL,
-		direccion TEXT,
-		telefono TEXT,
-		email TEXT,
-		tipo_cliente TEXT NOT NULL DEFAULT 'PERSONA_NATURAL',
-		fecha_creacion DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
-		activo BOOLEAN NOT NULL DEFAULT 1
-	);`
-
-	// Tabla de configuración
-	configSQL := `
-	CREATE TABLE IF NOT EXISTS configuracion (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		clave TEXT NOT NULL UNIQUE,
-		valor TEXT NOT NULL,
-		tipo TEXT NOT NULL DEFAULT 'STRING',
-		activo BOOLEAN NOT NULL DEFAULT 1
-	);`
-
-	// Tabla de auditoría
-	auditSQL := `
-	CREATE TABLE IF NOT EXISTS audit_log (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		tabla TEXT NOT NULL,
-		registro_id INTEGER NOT NULL,
-		operacion TEXT NOT NULL,
-		usuario TEXT NOT NULL DEFAULT 'sistema',
-		datos_antes TEXT,
-		datos_despues TEXT,
-		ip_address TEXT,
-		user_agent TEXT,
-		timestamp DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
-	);`
-
-	// Índices para mejorar performance
-	indicesSQL := []string{
-		"CREATE INDEX IF NOT EXISTS idx_facturas_numero ON facturas(numero_factura);",
-		"CREATE INDEX IF NOT EXISTS idx_facturas_clave ON facturas(clave_acceso);",
-		"CREATE INDEX IF NOT EXISTS idx_facturas_cliente ON facturas(cliente_cedula);",
-		"CREATE INDEX IF NOT EXISTS idx_facturas_fecha ON facturas(fecha_emision);",
-		"CREATE INDEX IF NOT EXISTS idx_facturas_estado ON facturas(estado);",
-		"CREATE INDEX IF NOT EXISTS idx_productos_factura ON productos(factura_id);",
-		"CREATE INDEX IF NOT EXISTS idx_clientes_cedula ON clientes(cedula);",
-		"CREATE INDEX IF NOT EXISTS idx_audit_tabla ON audit_log(tabla);",
-		"CREATE INDEX IF NOT EXISTS idx_audit_registro ON audit_log(registro_id);",
-		"CREATE INDEX IF NOT EXISTS idx_audit_timestamp ON audit_log(timestamp);",
-		"CREATE INDEX IF NOT EXISTS idx_audit_usuario ON audit_log(usuario);",
-	}
-
-	// Ejecutar creación de tablas
-	tables := []string{facturaSQL, productoSQL, clienteSQL, configSQL, auditSQL}
-	for _, table := range tables {
-		if _, err := d.db.Exec(table); err != nil {
-			return fmt.Errorf("error creando tabla: %v", err)
-		}
+// descripcion identifica la base de datos en los logs sin exponer credenciales del DSN
+func (d *Database) descripcion() string {
+	if d.ruta != "" {
+		return d.ruta
 	}
+	return string(d.dialecto)
+}
 
-	// Ejecutar creación de índices
-	for _, index := range indicesSQL {
-		if _, err := d.db.Exec(index); err != nil {
-			return fmt.Errorf("error creando índice: %v", err)
-		}
+// Close cierra la conexión a la base de datos
+func (d *Database) Close() error {
+	if d.db != nil {
+		return d.db.Close()
 	}
-
 	return nil
 }
 
 // GuardarFactura guarda una factura completa en la base de datos
-func (d *Database) GuardarFactura(factura models.Factura, claveAcceso string, productos []models.ProductoInput) (*FacturaDB, error) {
+func (d *Database) GuardarFactura(ctx context.Context, factura models.Factura, claveAcceso string, productos []models.ProductoInput) (*FacturaDB, error) {
 	// Iniciar transacción
 	tx, err := d.db.Begin()
 	if err != nil {
@@ -262,8 +245,9 @@ func (d *Database) GuardarFactura(factura models.Factura, claveAcceso string, pr
 	}
 	defer tx.Rollback()
 
-	// Generar número de factura
-	numeroFactura, err := d.generarNumeroFactura(tx)
+	// Generar número de factura, correlativo por tenant
+	tenantID := tenantEscritura(ctx)
+	numeroFactura, err := d.generarNumeroFactura(tx, tenantID)
 	if err != nil {
 		return nil, fmt.Errorf("error generando número de factura: %v", err)
 	}
@@ -279,13 +263,19 @@ func (d *Database) GuardarFactura(factura models.Factura, claveAcceso string, pr
 		INSERT INTO facturas (
 			numero_factura, clave_acceso, fecha_emision, cliente_nombre, cliente_cedula,
 			cliente_direccion, cliente_telefono, cliente_email, subtotal, iva, total,
-			estado, xml_original, ambiente, tipo_emision
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+			estado, xml_original, ambiente, tipo_emision, tenant_id
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 
-	result, err := tx.Exec(facturaSQL,
+	ambiente := "PRUEBAS"
+	if factura.InfoTributaria.Ambiente == "2" {
+		ambiente = "PRODUCCION"
+	}
+
+	var facturaID int64
+	err = tx.QueryRow(facturaSQL+" RETURNING id",
 		numeroFactura,
 		claveAcceso,
-		time.Now(),
+		time.Now().UTC(), // En UTC para que los filtros por fecha comparen igual en ambos dialectos
 		factura.InfoFactura.RazonSocialComprador,
 		factura.InfoFactura.IdentificacionComprador,
 		factura.InfoFactura.DirEstablecimiento, // Usamos dirección del establecimiento como placeholder
@@ -296,18 +286,14 @@ func (d *Database) GuardarFactura(factura models.Factura, claveAcceso string, pr
 		factura.InfoFactura.ImporteTotal,
 		"BORRADOR",
 		string(xmlOriginal),
-		"PRUEBAS",
+		ambiente,
 		"NORMAL",
-	)
+		tenantID,
+	).Scan(&facturaID)
 	if err != nil {
 		return nil, fmt.Errorf("error insertando factura: %v", err)
 	}
 
-	facturaID, err := result.LastInsertId()
-	if err != nil {
-		return nil, fmt.Errorf("error obteniendo ID de factura: %v", err)
-	}
-
 	// Insertar productos
 	productoSQL := `
 		INSERT INTO productos (
@@ -340,13 +326,24 @@ func (d *Database) GuardarFactura(factura models.Factura, claveAcceso string, pr
 	}
 
 	// Retornar factura creada
-	return d.ObtenerFacturaPorID(int(facturaID))
+	facturaDB, err := d.ObtenerFacturaPorID(ctx, int(facturaID))
+	if err != nil {
+		return nil, err
+	}
+	d.auditar(ctx, "facturas", facturaDB.ID, "CREATE", "", nil, facturaDB)
+
+	return facturaDB, nil
 }
 
-// generarNumeroFactura genera un número de factura secuencial
-func (d *Database) generarNumeroFactura(tx *sql.Tx) (string, error) {
+// generarNumeroFactura genera el siguiente número de factura del tenant. En PostgreSQL el
+// bloqueo evita que dos transacciones concurrentes lean el mismo máximo.
+func (d *Database) generarNumeroFactura(tx *transaccion, tenantID int) (string, error) {
+	if err := tx.bloquear(bloqueoNumeracionFacturas); err != nil {
+		return "", err
+	}
+
 	var ultimoNumero int
-	err := tx.QueryRow("SELECT COALESCE(MAX(CAST(SUBSTR(numero_factura, 5) AS INTEGER)), 0) FROM facturas WHERE numero_factura LIKE 'FAC-%'").Scan(&ultimoNumero)
+	err := tx.QueryRow("SELECT COALESCE(MAX(CAST(SUBSTR(numero_factura, 5) AS INTEGER)), 0) FROM facturas WHERE tenant_id = ? AND numero_factura LIKE 'FAC-%'", tenantID).Scan(&ultimoNumero)
 	if err != nil && err != sql.ErrNoRows {
 		return "", err
 	}
@@ -354,16 +351,17 @@ func (d *Database) generarNumeroFactura(tx *sql.Tx) (string, error) {
 	return fmt.Sprintf("FAC-%06d", ultimoNumero+1), nil
 }
 
-// ObtenerFacturaPorID obtiene una factura por su ID
-func (d *Database) ObtenerFacturaPorID(id int) (*FacturaDB, error) {
+// ObtenerFacturaPorID obtiene una factura por su ID; las de otro tenant no se encuentran
+func (d *Database) ObtenerFacturaPorID(ctx context.Context, id int) (*FacturaDB, error) {
 	query := `
 		SELECT id, numero_factura, clave_acceso, fecha_emision, cliente_nombre, cliente_cedula,
 			   cliente_direccion, cliente_telefono, cliente_email, subtotal, iva, total,
 			   estado, numero_autorizacion, fecha_autorizacion, xml_original, xml_autorizado,
-			   observaciones_sri, ambiente, tipo_emision, fecha_creacion, fecha_actualizacion
+			   observaciones_sri, ambiente, tipo_emision, fecha_creacion, fecha_actualizacion, tenant_id
 		FROM facturas WHERE id = ?`
+	filtro, args := filtroTenant(ctx, "tenant_id")
 
-	row := d.db.QueryRow(query, id)
+	row := d.db.QueryRow(query+filtro, append([]interface{}{id}, args...)...)
 	
 	factura := &FacturaDB{}
 	var fechaAutorizacion sql.NullTime
@@ -377,6 +375,7 @@ func (d *Database) ObtenerFacturaPorID(id int) (*FacturaDB, error) {
 		&factura.Total, &factura.Estado, &numeroAutorizacion, &fechaAutorizacion,
 		&factura.XMLOriginal, &xmlAutorizado, &observacionesSRI,
 		&factura.Ambiente, &factura.TipoEmision, &factura.FechaCreacion, &factura.FechaActualizacion,
+		&factura.TenantID,
 	)
 	
 	if err != nil {
@@ -412,16 +411,17 @@ func (d *Database) ObtenerFacturaPorID(id int) (*FacturaDB, error) {
 	return factura, nil
 }
 
-// ObtenerFacturaPorNumero obtiene una factura por su número
-func (d *Database) ObtenerFacturaPorNumero(numero string) (*FacturaDB, error) {
+// ObtenerFacturaPorNumero obtiene una factura del tenant del contexto por su número
+func (d *Database) ObtenerFacturaPorNumero(ctx context.Context, numero string) (*FacturaDB, error) {
 	query := `
 		SELECT id, numero_factura, clave_acceso, fecha_emision, cliente_nombre, cliente_cedula,
 			   cliente_direccion, cliente_telefono, cliente_email, subtotal, iva, total,
 			   estado, numero_autorizacion, fecha_autorizacion, xml_original, xml_autorizado,
-			   observaciones_sri, ambiente, tipo_emision, fecha_creacion, fecha_actualizacion
+			   observaciones_sri, ambiente, tipo_emision, fecha_creacion, fecha_actualizacion, tenant_id
 		FROM facturas WHERE numero_factura = ?`
+	filtro, args := filtroTenant(ctx, "tenant_id")
 
-	row := d.db.QueryRow(query, numero)
+	row := d.db.QueryRow(query+filtro, append([]interface{}{numero}, args...)...)
 	
 	factura := &FacturaDB{}
 	var fechaAutorizacion sql.NullTime
@@ -435,6 +435,7 @@ func (d *Database) ObtenerFacturaPorNumero(numero string) (*FacturaDB, error) {
 		&factura.Total, &factura.Estado, &numeroAutorizacion, &fechaAutorizacion,
 		&factura.XMLOriginal, &xmlAutorizado, &observacionesSRI,
 		&factura.Ambiente, &factura.TipoEmision, &factura.FechaCreacion, &factura.FechaActualizacion,
+		&factura.TenantID,
 	)
 	
 	if err != nil {
@@ -470,77 +471,33 @@ func (d *Database) ObtenerFacturaPorNumero(numero string) (*FacturaDB, error) {
 	return factura, nil
 }
 
-// ListarFacturas obtiene una lista paginada de facturas
-func (d *Database) ListarFacturas(limite, offset int) ([]*FacturaDB, error) {
-	query := `
-		SELECT id, numero_factura, clave_acceso, fecha_emision, cliente_nombre, cliente_cedula,
-			   subtotal, iva, total, estado, numero_autorizacion, ambiente
-		FROM facturas 
-		ORDER BY fecha_creacion DESC 
-		LIMIT ? OFFSET ?`
-
-	rows, err := d.db.Query(query, limite, offset)
-	if err != nil {
-		return nil, fmt.Errorf("error listando facturas: %v", err)
-	}
-	defer rows.Close()
-
-	var facturas []*FacturaDB
-	
-	for rows.Next() {
-		factura := &FacturaDB{}
-		var numeroAutorizacion sql.NullString
-		
-		err := rows.Scan(
-			&factura.ID, &factura.NumeroFactura, &factura.ClaveAcceso, &factura.FechaEmision,
-			&factura.ClienteNombre, &factura.ClienteCedula, &factura.Subtotal, &factura.IVA,
-			&factura.Total, &factura.Estado, &numeroAutorizacion, &factura.Ambiente,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("error escaneando factura: %v", err)
-		}
-		
-		if numeroAutorizacion.Valid {
-			factura.NumeroAutorizacion = numeroAutorizacion.String
-		}
-		
-		facturas = append(facturas, factura)
-	}
-	
-	return facturas, nil
+// ListarFacturas obtiene una lista paginada de facturas, las más recientes primero
+func (d *Database) ListarFacturas(ctx context.Context, limite, offset int) ([]*FacturaDB, error) {
+	facturas, _, err := d.BuscarFacturas(ctx, FiltroFacturas{Limite: limite, Offset: offset})
+	return facturas, err
 }
 
-// ActualizarEstadoFactura actualiza el estado de una factura
-func (d *Database) ActualizarEstadoFactura(id int, estado string, numeroAutorizacion string, xmlAutorizado string, observaciones string) error {
-	query := `
-		UPDATE facturas 
-		SET estado = ?, numero_autorizacion = ?, fecha_autorizacion = ?, 
-		    xml_autorizado = ?, observaciones_sri = ?, fecha_actualizacion = CURRENT_TIMESTAMP
-		WHERE id = ?`
-
-	var fechaAutorizacion *time.Time
-	if estado == "AUTORIZADA" {
-		now := time.Now()
-		fechaAutorizacion = &now
-	}
-
-	_, err := d.db.Exec(query, estado, numeroAutorizacion, fechaAutorizacion, xmlAutorizado, observaciones, id)
-	if err != nil {
-		return fmt.Errorf("error actualizando estado de factura: %v", err)
-	}
-
-	return nil
+// ActualizarEstadoFactura actualiza el estado de una factura validando la transición.
+// El cambio queda registrado en el historial con el actor "sistema".
+func (d *Database) ActualizarEstadoFactura(ctx context.Context, id int, estado string, numeroAutorizacion string, xmlAutorizado string, observaciones string) error {
+	return d.TransicionarEstadoFactura(ctx, id, CambioEstadoFactura{
+		Estado:             estado,
+		NumeroAutorizacion: numeroAutorizacion,
+		XMLAutorizado:      xmlAutorizado,
+		Observaciones:      observaciones,
+	})
 }
 
 // ObtenerProductosPorFactura obtiene los productos de una factura
-func (d *Database) ObtenerProductosPorFactura(facturaID int) ([]*ProductoDB, error) {
+func (d *Database) ObtenerProductosPorFactura(ctx context.Context, facturaID int) ([]*ProductoDB, error) {
+	filtro, args := filtroFacturaTenant(ctx, "factura_id")
 	query := `
 		SELECT id, factura_id, codigo, codigo_principal, codigo_auxiliar, descripcion,
 			   unidad_medida, cantidad, precio_unitario, descuento, precio_total_sin_iva,
 			   precio_total, iva
-		FROM productos WHERE factura_id = ? ORDER BY id`
+		FROM productos WHERE factura_id = ?` + filtro + ` ORDER BY id`
 
-	rows, err := d.db.Query(query, facturaID)
+	rows, err := d.db.Query(query, append([]interface{}{facturaID}, args...)...)
 	if err != nil {
 		return nil, fmt.Errorf("error obteniendo productos: %v", err)
 	}
@@ -575,39 +532,59 @@ func (d *Database) ObtenerProductosPorFactura(facturaID int) ([]*ProductoDB, err
 	return productos, nil
 }
 
-// GuardarCliente guarda un cliente en la base de datos
-func (d *Database) GuardarCliente(cliente *ClienteDB) (*ClienteDB, error) {
+// GuardarCliente guarda un cliente del tenant del contexto; si la cédula ya existe en el tenant
+// lo actualiza
+func (d *Database) GuardarCliente(ctx context.Context, cliente *ClienteDB) (*ClienteDB, error) {
+	tenantID := tenantEscritura(ctx)
 	query := `
-		INSERT OR REPLACE INTO clientes (cedula, nombre, direccion, telefono, email, tipo_cliente)
-		VALUES (?, ?, ?, ?, ?, ?)`
-
-	result, err := d.db.Exec(query, cliente.Cedula, cliente.Nombre, cliente.Direccion, 
-		cliente.Telefono, cliente.Email, cliente.TipoCliente)
+		INSERT INTO clientes (cedula, nombre, direccion, telefono, email, tipo_cliente, tenant_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (tenant_id, cedula) DO UPDATE SET
+			nombre = excluded.nombre, direccion = excluded.direccion, telefono = excluded.telefono,
+			email = excluded.email, tipo_cliente = excluded.tipo_cliente, activo = TRUE
+		RETURNING id`
+
+	// Datos previos para auditoría cuando la cédula ya estaba registrada
+	var antes *ClienteDB
+	var idExistente int
+	if err := d.db.QueryRow("SELECT id FROM clientes WHERE tenant_id = ? AND cedula = ?", tenantID, cliente.Cedula).Scan(&idExistente); err == nil {
+		antes, _ = d.ObtenerClientePorID(ctx, idExistente)
+	}
+
+	var id int
+	err := d.db.QueryRow(query, cliente.Cedula, cliente.Nombre, cliente.Direccion, 
+		cliente.Telefono, cliente.Email, cliente.TipoCliente, tenantID).Scan(&id)
 	if err != nil {
 		return nil, fmt.Errorf("error guardando cliente: %v", err)
 	}
 
-	id, err := result.LastInsertId()
+	guardado, err := d.ObtenerClientePorID(ctx, id)
 	if err != nil {
-		return nil, fmt.Errorf("error obteniendo ID de cliente: %v", err)
+		return nil, err
+	}
+	if antes == nil {
+		d.auditar(ctx, "clientes", id, "CREATE", "", nil, guardado)
+	} else {
+		d.auditar(ctx, "clientes", id, "UPDATE", "", antes, guardado)
 	}
 
-	return d.ObtenerClientePorID(int(id))
+	return guardado, nil
 }
 
-// ObtenerClientePorID obtiene un cliente por su ID
-func (d *Database) ObtenerClientePorID(id int) (*ClienteDB, error) {
+// ObtenerClientePorID obtiene un cliente por su ID; los de otro tenant no se encuentran
+func (d *Database) ObtenerClientePorID(ctx context.Context, id int) (*ClienteDB, error) {
 	query := `
-		SELECT id, cedula, nombre, direccion, telefono, email, tipo_cliente, fecha_creacion, activo
+		SELECT id, cedula, nombre, direccion, telefono, email, tipo_cliente, fecha_creacion, activo, tenant_id
 		FROM clientes WHERE id = ?`
+	filtro, args := filtroTenant(ctx, "tenant_id")
 
-	row := d.db.QueryRow(query, id)
+	row := d.db.QueryRow(query+filtro, append([]interface{}{id}, args...)...)
 	
 	cliente := &ClienteDB{}
 	var direccion, telefono, email sql.NullString
 	
 	err := row.Scan(&cliente.ID, &cliente.Cedula, &cliente.Nombre, &direccion,
-		&telefono, &email, &cliente.TipoCliente, &cliente.FechaCreacion, &cliente.Activo)
+		&telefono, &email, &cliente.TipoCliente, &cliente.FechaCreacion, &cliente.Activo, &cliente.TenantID)
 	
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -629,19 +606,20 @@ func (d *Database) ObtenerClientePorID(id int) (*ClienteDB, error) {
 	return cliente, nil
 }
 
-// ObtenerClientePorCedula obtiene un cliente por su cédula
-func (d *Database) ObtenerClientePorCedula(cedula string) (*ClienteDB, error) {
+// ObtenerClientePorCedula obtiene un cliente del tenant del contexto por su cédula
+func (d *Database) ObtenerClientePorCedula(ctx context.Context, cedula string) (*ClienteDB, error) {
 	query := `
-		SELECT id, cedula, nombre, direccion, telefono, email, tipo_cliente, fecha_creacion, activo
-		FROM clientes WHERE cedula = ? AND activo = 1`
+		SELECT id, cedula, nombre, direccion, telefono, email, tipo_cliente, fecha_creacion, activo, tenant_id
+		FROM clientes WHERE cedula = ? AND activo = TRUE`
+	filtro, args := filtroTenant(ctx, "tenant_id")
 
-	row := d.db.QueryRow(query, cedula)
+	row := d.db.QueryRow(query+filtro, append([]interface{}{cedula}, args...)...)
 	
 	cliente := &ClienteDB{}
 	var direccion, telefono, email sql.NullString
 	
 	err := row.Scan(&cliente.ID, &cliente.Cedula, &cliente.Nombre, &direccion,
-		&telefono, &email, &cliente.TipoCliente, &cliente.FechaCreacion, &cliente.Activo)
+		&telefono, &email, &cliente.TipoCliente, &cliente.FechaCreacion, &cliente.Activo, &cliente.TenantID)
 	
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -663,12 +641,12 @@ func (d *Database) ObtenerClientePorCedula(cedula string) (*ClienteDB, error) {
 	return cliente, nil
 }
 
-// ListarClientes obtiene todos los clientes con filtros opcionales
-func (d *Database) ListarClientes(nombre, tipoCliente string, limite, offset int) ([]*ClienteDB, error) {
-	var args []interface{}
+// ListarClientes obtiene los clientes del tenant del contexto con filtros opcionales
+func (d *Database) ListarClientes(ctx context.Context, nombre, tipoCliente string, limite, offset int) ([]*ClienteDB, error) {
+	filtro, args := filtroTenant(ctx, "tenant_id")
 	query := `
-		SELECT id, cedula, nombre, direccion, telefono, email, tipo_cliente, fecha_creacion, activo
-		FROM clientes WHERE activo = 1`
+		SELECT id, cedula, nombre, direccion, telefono, email, tipo_cliente, fecha_creacion, activo, tenant_id
+		FROM clientes WHERE activo = TRUE` + filtro
 
 	// Agregar filtros si se proporcionan
 	if nombre != "" {
@@ -701,7 +679,7 @@ func (d *Database) ListarClientes(nombre, tipoCliente string, limite, offset int
 		var direccion, telefono, email sql.NullString
 
 		err := rows.Scan(&cliente.ID, &cliente.Cedula, &cliente.Nombre, &direccion,
-			&telefono, &email, &cliente.TipoCliente, &cliente.FechaCreacion, &cliente.Activo)
+			&telefono, &email, &cliente.TipoCliente, &cliente.FechaCreacion, &cliente.Activo, &cliente.TenantID)
 		if err != nil {
 			return nil, fmt.Errorf("error escaneando cliente: %v", err)
 		}
@@ -726,20 +704,21 @@ func (d *Database) ListarClientes(nombre, tipoCliente string, limite, offset int
 	return clientes, nil
 }
 
-// EstadisticasFacturas obtiene estadísticas básicas de facturas
-func (d *Database) EstadisticasFacturas() (map[string]interface{}, error) {
+// EstadisticasFacturas obtiene estadísticas básicas de las facturas del tenant del contexto
+func (d *Database) EstadisticasFacturas(ctx context.Context) (map[string]interface{}, error) {
 	stats := make(map[string]interface{})
+	filtro, args := filtroTenant(ctx, "tenant_id")
 	
 	// Total de facturas
 	var total int
-	err := d.db.QueryRow("SELECT COUNT(*) FROM facturas").Scan(&total)
+	err := d.db.QueryRow("SELECT COUNT(*) FROM facturas WHERE 1 = 1"+filtro, args...).Scan(&total)
 	if err != nil {
 		return nil, fmt.Errorf("error obteniendo total de facturas: %v", err)
 	}
 	stats["total_facturas"] = total
 	
 	// Facturas por estado
-	rows, err := d.db.Query("SELECT estado, COUNT(*) FROM facturas GROUP BY estado")
+	rows, err := d.db.Query("SELECT estado, COUNT(*) FROM facturas WHERE 1 = 1"+filtro+" GROUP BY estado", args...)
 	if err != nil {
 		return nil, fmt.Errorf("error obteniendo estadísticas por estado: %v", err)
 	}
@@ -758,7 +737,7 @@ func (d *Database) EstadisticasFacturas() (map[string]interface{}, error) {
 	
 	// Total facturado
 	var totalFacturado sql.NullFloat64
-	err = d.db.QueryRow("SELECT SUM(total) FROM facturas WHERE estado = 'AUTORIZADA'").Scan(&totalFacturado)
+	err = d.db.QueryRow("SELECT SUM(total) FROM facturas WHERE estado = 'AUTORIZADA'"+filtro, args...).Scan(&totalFacturado)
 	if err != nil {
 		return nil, fmt.Errorf("error obteniendo total facturado: %v", err)
 	}
@@ -772,32 +751,54 @@ func (d *Database) EstadisticasFacturas() (map[string]interface{}, error) {
 	return stats, nil
 }
 
-// RegistrarAuditoria registra una operación en el log de auditoría
-func (d *Database) RegistrarAuditoria(audit *AuditLogDB) error {
-	query := `
-		INSERT INTO audit_log (tabla, registro_id, operacion, usuario, datos_antes, datos_despues, ip_address, user_agent)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+// RegistrarAuditoria agrega una operación al log de auditoría encadenándola a la entrada anterior.
+// Usuario, IP, user agent y request ID vacíos se completan con el actor del contexto, y la
+// entrada pertenece al tenant del contexto.
+func (d *Database) RegistrarAuditoria(ctx context.Context, audit *AuditLogDB) error {
+	completarAuditoria(ctx, audit)
 
-	_, err := d.db.Exec(query, audit.Tabla, audit.RegistroID, audit.Operacion,
-		audit.Usuario, audit.DatosAntes, audit.DatosDespues, audit.IPAddress, audit.UserAgent)
+	tx, err := d.db.Begin()
 	if err != nil {
+		return fmt.Errorf("error iniciando transacción: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := insertarAuditoria(tx, audit); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
 		return fmt.Errorf("error registrando auditoría: %v", err)
 	}
 
 	return nil
 }
 
-// ObtenerAuditoriaPorTabla obtiene registros de auditoría para una tabla específica
-func (d *Database) ObtenerAuditoriaPorTabla(tabla string, limite, offset int) ([]*AuditLogDB, error) {
-	query := `
-		SELECT id, tabla, registro_id, operacion, usuario, datos_antes, datos_despues,
-			   ip_address, user_agent, timestamp
-		FROM audit_log 
-		WHERE tabla = ?
-		ORDER BY timestamp DESC 
-		LIMIT ? OFFSET ?`
-
-	rows, err := d.db.Query(query, tabla, limite, offset)
+// completarAuditoria toma del contexto el tenant y el actor que la entrada no trae
+func completarAuditoria(ctx context.Context, audit *AuditLogDB) {
+	actor := ActorAuditoriaDe(ctx)
+	if audit.TenantID == TenantPredeterminado {
+		audit.TenantID = tenantEscritura(ctx)
+	}
+	audit.Usuario = usuarioAuditoria(ctx, audit.Usuario)
+	if audit.IPAddress == "" {
+		audit.IPAddress = actor.IPAddress
+	}
+	if audit.UserAgent == "" {
+		audit.UserAgent = actor.UserAgent
+	}
+	if audit.RequestID == "" {
+		audit.RequestID = actor.RequestID
+	}
+}
+
+// ObtenerAuditoriaPorTabla obtiene registros de auditoría del tenant del contexto para una tabla
+func (d *Database) ObtenerAuditoriaPorTabla(ctx context.Context, tabla string, limite, offset int) ([]*AuditLogDB, error) {
+	filtro, args := filtroTenant(ctx, "tenant_id")
+	args = append([]interface{}{tabla}, append(args, limite, offset)...)
+	rows, err := d.db.Query(selectAuditoria+`
+		WHERE tabla = ?`+filtro+`
+		ORDER BY timestamp DESC, id DESC
+		LIMIT ? OFFSET ?`, args...)
 	if err != nil {
 		return nil, fmt.Errorf("error obteniendo auditoría: %v", err)
 	}
@@ -806,47 +807,22 @@ func (d *Database) ObtenerAuditoriaPorTabla(tabla string, limite, offset int) ([
 	var registros []*AuditLogDB
 	
 	for rows.Next() {
-		audit := &AuditLogDB{}
-		var datosAntes, datosDespues, ipAddress, userAgent sql.NullString
-		
-		err := rows.Scan(
-			&audit.ID, &audit.Tabla, &audit.RegistroID, &audit.Operacion,
-			&audit.Usuario, &datosAntes, &datosDespues, &ipAddress,
-			&userAgent, &audit.Timestamp,
-		)
+		audit, err := escanearAuditoria(rows)
 		if err != nil {
 			return nil, fmt.Errorf("error escaneando auditoría: %v", err)
 		}
-		
-		if datosAntes.Valid {
-			audit.DatosAntes = datosAntes.String
-		}
-		if datosDespues.Valid {
-			audit.DatosDespues = datosDespues.String
-		}
-		if ipAddress.Valid {
-			audit.IPAddress = ipAddress.String
-		}
-		if userAgent.Valid {
-			audit.UserAgent = userAgent.String
-		}
-		
 		registros = append(registros, audit)
 	}
 	
 	return registros, nil
 }
 
-// ObtenerAuditoriaPorRegistro obtiene auditoría para un registro específico
-func (d *Database) ObtenerAuditoriaPorRegistro(tabla string, registroID int) ([]*AuditLogDB, error) {
-	query := `
-		SELECT id, tabla, registro_id, operacion, usuario, datos_antes, datos_despues,
-			   ip_address, user_agent, timestamp
-		FROM audit_log 
-		WHERE tabla = ? AND registro_id = ?
-		ORDER BY timestamp DESC`
-
-	rows, err := d.db.Query(query, tabla, registroID)
+// ObtenerAuditoriaPorRegistro obtiene auditoría del tenant del contexto para un registro específico
+func (d *Database) ObtenerAuditoriaPorRegistro(ctx context.Context, tabla string, registroID int) ([]*AuditLogDB, error) {
+	filtro, args := filtroTenant(ctx, "tenant_id")
+	rows, err := d.db.Query(selectAuditoria+`
+		WHERE tabla = ? AND registro_id = ?`+filtro+`
+		ORDER BY timestamp DESC, id DESC`, append([]interface{}{tabla, registroID}, args...)...)
 	if err != nil {
 		return nil, fmt.Errorf("error obteniendo auditoría del registro: %v", err)
 	}
@@ -855,33 +831,12 @@ func (d *Database) ObtenerAuditoriaPorRegistro(tabla string, registroID int) ([]
 	var registros []*AuditLogDB
 	
 	for rows.Next() {
-		audit := &AuditLogDB{}
-		var datosAntes, datosDespues, ipAddress, userAgent sql.NullString
-		
-		err := rows.Scan(
-			&audit.ID, &audit.Tabla, &audit.RegistroID, &audit.Operacion,
-			&audit.Usuario, &datosAntes, &datosDespues, &ipAddress,
-			&userAgent, &audit.Timestamp,
-		)
+		audit, err := escanearAuditoria(rows)
 		if err != nil {
 			return nil, fmt.Errorf("error escaneando auditoría: %v", err)
 		}
-		
-		if datosAntes.Valid {
-			audit.DatosAntes = datosAntes.String
-		}
-		if datosDespues.Valid {
-			audit.DatosDespues = datosDespues.String
-		}
-		if ipAddress.Valid {
-			audit.IPAddress = ipAddress.String
-		}
-		if userAgent.Valid {
-			audit.UserAgent = userAgent.String
-		}
-		
 		registros = append(registros, audit)
 	}
 	
 	return registros, nil
-}
\ No newline at end of file
+}
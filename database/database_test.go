@@ -1,6 +1,7 @@
 package database
 
 import (
+	"context"
 	"go-facturacion-sri/config"
 	"go-facturacion-sri/factory"
 	"go-facturacion-sri/models"
@@ -20,7 +21,7 @@ func TestNew(t *testing.T) {
 	dbPath := "test_facturacion.db"
 	defer os.Remove(dbPath)
 
-	db, err := New(dbPath)
+	db, err := abrirDBPrueba(t, dbPath)
 	if err != nil {
 		t.Fatalf("Error creando base de datos: %v", err)
 	}
@@ -39,7 +40,7 @@ func TestGuardarYObtenerFactura(t *testing.T) {
 	dbPath := "test_factura_operaciones.db"
 	defer os.Remove(dbPath)
 
-	db, err := New(dbPath)
+	db, err := abrirDBPrueba(t, dbPath)
 	if err != nil {
 		t.Fatalf("Error creando base de datos: %v", err)
 	}
@@ -81,7 +82,7 @@ func TestGuardarYObtenerFactura(t *testing.T) {
 	}
 
 	// Guardar factura en base de datos
-	facturaDB, err := db.GuardarFactura(factura, claveAcceso, facturaData.Productos)
+	facturaDB, err := db.GuardarFactura(context.Background(), factura, claveAcceso, facturaData.Productos)
 	if err != nil {
 		t.Fatalf("Error guardando factura: %v", err)
 	}
@@ -99,7 +100,7 @@ func TestGuardarYObtenerFactura(t *testing.T) {
 	}
 
 	// Obtener factura por ID
-	facturaObtenida, err := db.ObtenerFacturaPorID(facturaDB.ID)
+	facturaObtenida, err := db.ObtenerFacturaPorID(context.Background(), facturaDB.ID)
 	if err != nil {
 		t.Fatalf("Error obteniendo factura por ID: %v", err)
 	}
@@ -110,7 +111,7 @@ func TestGuardarYObtenerFactura(t *testing.T) {
 	}
 
 	// Obtener factura por número
-	facturaPorNumero, err := db.ObtenerFacturaPorNumero(facturaDB.NumeroFactura)
+	facturaPorNumero, err := db.ObtenerFacturaPorNumero(context.Background(), facturaDB.NumeroFactura)
 	if err != nil {
 		t.Fatalf("Error obteniendo factura por número: %v", err)
 	}
@@ -129,7 +130,7 @@ func TestListarFacturas(t *testing.T) {
 	dbPath := "test_listar_facturas.db"
 	defer os.Remove(dbPath)
 
-	db, err := New(dbPath)
+	db, err := abrirDBPrueba(t, dbPath)
 	if err != nil {
 		t.Fatalf("Error creando base de datos: %v", err)
 	}
@@ -170,14 +171,14 @@ func TestListarFacturas(t *testing.T) {
 			t.Fatalf("Error generando clave de acceso %d: %v", i, err)
 		}
 
-		_, err = db.GuardarFactura(factura, claveAcceso, facturaData.Productos)
+		_, err = db.GuardarFactura(context.Background(), factura, claveAcceso, facturaData.Productos)
 		if err != nil {
 			t.Fatalf("Error guardando factura %d: %v", i, err)
 		}
 	}
 
 	// Listar facturas
-	facturas, err := db.ListarFacturas(10, 0)
+	facturas, err := db.ListarFacturas(context.Background(), 10, 0)
 	if err != nil {
 		t.Fatalf("Error listando facturas: %v", err)
 	}
@@ -200,7 +201,7 @@ func TestActualizarEstadoFactura(t *testing.T) {
 	dbPath := "test_actualizar_estado.db"
 	defer os.Remove(dbPath)
 
-	db, err := New(dbPath)
+	db, err := abrirDBPrueba(t, dbPath)
 	if err != nil {
 		t.Fatalf("Error creando base de datos: %v", err)
 	}
@@ -240,7 +241,7 @@ func TestActualizarEstadoFactura(t *testing.T) {
 		t.Fatalf("Error generando clave de acceso: %v", err)
 	}
 
-	facturaDB, err := db.GuardarFactura(factura, claveAcceso, facturaData.Productos)
+	facturaDB, err := db.GuardarFactura(context.Background(), factura, claveAcceso, facturaData.Productos)
 	if err != nil {
 		t.Fatalf("Error guardando factura: %v", err)
 	}
@@ -255,13 +256,14 @@ func TestActualizarEstadoFactura(t *testing.T) {
 	xmlAutorizado := "<factura>XML autorizado</factura>"
 	observaciones := "Factura autorizada correctamente"
 
-	err = db.ActualizarEstadoFactura(facturaDB.ID, "AUTORIZADA", numeroAutorizacion, xmlAutorizado, observaciones)
+	recorrerHastaRecibida(t, db, facturaDB.ID)
+	err = db.ActualizarEstadoFactura(context.Background(), facturaDB.ID, "AUTORIZADA", numeroAutorizacion, xmlAutorizado, observaciones)
 	if err != nil {
 		t.Fatalf("Error actualizando estado: %v", err)
 	}
 
 	// Verificar actualización
-	facturaActualizada, err := db.ObtenerFacturaPorID(facturaDB.ID)
+	facturaActualizada, err := db.ObtenerFacturaPorID(context.Background(), facturaDB.ID)
 	if err != nil {
 		t.Fatalf("Error obteniendo factura actualizada: %v", err)
 	}
@@ -292,7 +294,7 @@ func TestObtenerProductosPorFactura(t *testing.T) {
 	dbPath := "test_productos_factura.db"
 	defer os.Remove(dbPath)
 
-	db, err := New(dbPath)
+	db, err := abrirDBPrueba(t, dbPath)
 	if err != nil {
 		t.Fatalf("Error creando base de datos: %v", err)
 	}
@@ -338,13 +340,13 @@ func TestObtenerProductosPorFactura(t *testing.T) {
 		t.Fatalf("Error generando clave de acceso: %v", err)
 	}
 
-	facturaDB, err := db.GuardarFactura(factura, claveAcceso, facturaData.Productos)
+	facturaDB, err := db.GuardarFactura(context.Background(), factura, claveAcceso, facturaData.Productos)
 	if err != nil {
 		t.Fatalf("Error guardando factura: %v", err)
 	}
 
 	// Obtener productos de la factura
-	productos, err := db.ObtenerProductosPorFactura(facturaDB.ID)
+	productos, err := db.ObtenerProductosPorFactura(context.Background(), facturaDB.ID)
 	if err != nil {
 		t.Fatalf("Error obteniendo productos: %v", err)
 	}
@@ -373,7 +375,7 @@ func TestGuardarYObtenerCliente(t *testing.T) {
 	dbPath := "test_clientes.db"
 	defer os.Remove(dbPath)
 
-	db, err := New(dbPath)
+	db, err := abrirDBPrueba(t, dbPath)
 	if err != nil {
 		t.Fatalf("Error creando base de datos: %v", err)
 	}
@@ -390,7 +392,7 @@ func TestGuardarYObtenerCliente(t *testing.T) {
 	}
 
 	// Guardar cliente
-	clienteGuardado, err := db.GuardarCliente(cliente)
+	clienteGuardado, err := db.GuardarCliente(context.Background(), cliente)
 	if err != nil {
 		t.Fatalf("Error guardando cliente: %v", err)
 	}
@@ -400,7 +402,7 @@ func TestGuardarYObtenerCliente(t *testing.T) {
 	}
 
 	// Obtener cliente por ID
-	clientePorID, err := db.ObtenerClientePorID(clienteGuardado.ID)
+	clientePorID, err := db.ObtenerClientePorID(context.Background(), clienteGuardado.ID)
 	if err != nil {
 		t.Fatalf("Error obteniendo cliente por ID: %v", err)
 	}
@@ -411,7 +413,7 @@ func TestGuardarYObtenerCliente(t *testing.T) {
 	}
 
 	// Obtener cliente por cédula
-	clientePorCedula, err := db.ObtenerClientePorCedula(cliente.Cedula)
+	clientePorCedula, err := db.ObtenerClientePorCedula(context.Background(), cliente.Cedula)
 	if err != nil {
 		t.Fatalf("Error obteniendo cliente por cédula: %v", err)
 	}
@@ -430,7 +432,7 @@ func TestEstadisticasFacturas(t *testing.T) {
 	dbPath := "test_estadisticas.db"
 	defer os.Remove(dbPath)
 
-	db, err := New(dbPath)
+	db, err := abrirDBPrueba(t, dbPath)
 	if err != nil {
 		t.Fatalf("Error creando base de datos: %v", err)
 	}
@@ -473,14 +475,15 @@ func TestEstadisticasFacturas(t *testing.T) {
 			t.Fatalf("Error generando clave de acceso %d: %v", i, err)
 		}
 
-		facturaDB, err := db.GuardarFactura(factura, claveAcceso, facturaData.Productos)
+		facturaDB, err := db.GuardarFactura(context.Background(), factura, claveAcceso, facturaData.Productos)
 		if err != nil {
 			t.Fatalf("Error guardando factura %d: %v", i, err)
 		}
 
 		// Actualizar estado si no es BORRADOR
 		if estado != "BORRADOR" {
-			err = db.ActualizarEstadoFactura(facturaDB.ID, estado, "AUTH"+string(rune(i+49)), "", "")
+			recorrerHastaRecibida(t, db, facturaDB.ID)
+			err = db.ActualizarEstadoFactura(context.Background(), facturaDB.ID, estado, "AUTH"+string(rune(i+49)), "", "")
 			if err != nil {
 				t.Fatalf("Error actualizando estado factura %d: %v", i, err)
 			}
@@ -488,7 +491,7 @@ func TestEstadisticasFacturas(t *testing.T) {
 	}
 
 	// Obtener estadísticas
-	stats, err := db.EstadisticasFacturas()
+	stats, err := db.EstadisticasFacturas(context.Background())
 	if err != nil {
 		t.Fatalf("Error obteniendo estadísticas: %v", err)
 	}
@@ -523,7 +526,7 @@ func BenchmarkGuardarFactura(b *testing.B) {
 	dbPath := "benchmark_facturacion.db"
 	defer os.Remove(dbPath)
 
-	db, err := New(dbPath)
+	db, err := abrirDBPrueba(b, dbPath)
 	if err != nil {
 		b.Fatalf("Error creando base de datos: %v", err)
 	}
@@ -566,7 +569,7 @@ func BenchmarkGuardarFactura(b *testing.B) {
 			b.Fatalf("Error generando clave de acceso: %v", err)
 		}
 
-		_, err = db.GuardarFactura(factura, claveAcceso, facturaData.Productos)
+		_, err = db.GuardarFactura(context.Background(), factura, claveAcceso, facturaData.Productos)
 		if err != nil {
 			b.Fatalf("Error guardando factura: %v", err)
 		}
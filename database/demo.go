@@ -2,6 +2,7 @@
 package database
 
 import (
+	"context"
 	"fmt"
 	"go-facturacion-sri/factory"
 	"go-facturacion-sri/models"
@@ -116,7 +117,7 @@ func DemoDatabase() {
 		}
 
 		// Guardar en base de datos
-		facturaDB, err := db.GuardarFactura(factura, claveAcceso, facturaData.Productos)
+		facturaDB, err := db.GuardarFactura(context.Background(), factura, claveAcceso, facturaData.Productos)
 		if err != nil {
 			fmt.Printf("❌ Error guardando factura %d: %v\n", i+1, err)
 			continue
@@ -133,7 +134,7 @@ func DemoDatabase() {
 	fmt.Println("\n3️⃣ LISTADO DE FACTURAS")
 	fmt.Println(strings.Repeat("-", 40))
 
-	todasFacturas, err := db.ListarFacturas(10, 0)
+	todasFacturas, err := db.ListarFacturas(context.Background(), 10, 0)
 	if err != nil {
 		fmt.Printf("❌ Error listando facturas: %v\n", err)
 	} else {
@@ -163,13 +164,22 @@ func DemoDatabase() {
 			autorizacion := sri.SimularAutorizacionSRI(factura.ClaveAcceso, sri.Pruebas)
 			xmlAutorizado := fmt.Sprintf("<facturaAutorizada>XML autorizado para %s</facturaAutorizada>", factura.NumeroFactura)
 
-			err := db.ActualizarEstadoFactura(
-				factura.ID,
-				"AUTORIZADA",
-				autorizacion.NumeroAutorizacion,
-				xmlAutorizado,
-				"Factura autorizada automáticamente por el SRI",
-			)
+			// Recorrer el ciclo de envío antes de registrar la autorización
+			var err error
+			for _, estado := range []string{EstadoFirmada, EstadoEnviada, EstadoRecibida} {
+				if err = db.ActualizarEstadoFactura(context.Background(), factura.ID, estado, "", "", ""); err != nil {
+					break
+				}
+			}
+			if err == nil {
+				err = db.ActualizarEstadoFactura(context.Background(),
+					factura.ID,
+					EstadoAutorizada,
+					autorizacion.NumeroAutorizacion,
+					xmlAutorizado,
+					"Factura autorizada automáticamente por el SRI",
+				)
+			}
 
 			if err != nil {
 				fmt.Printf("❌ Error actualizando estado: %v\n", err)
@@ -187,7 +197,7 @@ func DemoDatabase() {
 	if len(facturasGuardadas) > 0 {
 		facturaID := facturasGuardadas[0].ID
 
-		facturaDetalle, err := db.ObtenerFacturaPorID(facturaID)
+		facturaDetalle, err := db.ObtenerFacturaPorID(context.Background(), facturaID)
 		if err != nil {
 			fmt.Printf("❌ Error obteniendo factura: %v\n", err)
 		} else {
@@ -203,7 +213,7 @@ func DemoDatabase() {
 			}
 
 			// Obtener productos
-			productos, err := db.ObtenerProductosPorFactura(facturaID)
+			productos, err := db.ObtenerProductosPorFactura(context.Background(), facturaID)
 			if err != nil {
 				fmt.Printf("❌ Error obteniendo productos: %v\n", err)
 			} else {
@@ -243,7 +253,7 @@ func DemoDatabase() {
 	for i, clienteData := range clientesDemo {
 		fmt.Printf("\n👤 Guardando cliente %d: %s\n", i+1, clienteData.Nombre)
 
-		cliente, err := db.GuardarCliente(clienteData)
+		cliente, err := db.GuardarCliente(context.Background(), clienteData)
 		if err != nil {
 			fmt.Printf("❌ Error guardando cliente: %v\n", err)
 		} else {
@@ -257,7 +267,7 @@ func DemoDatabase() {
 	fmt.Println("\n7️⃣ ESTADÍSTICAS DEL SISTEMA")
 	fmt.Println(strings.Repeat("-", 40))
 
-	estadisticas, err := db.EstadisticasFacturas()
+	estadisticas, err := db.EstadisticasFacturas(context.Background())
 	if err != nil {
 		fmt.Printf("❌ Error obteniendo estadísticas: %v\n", err)
 	} else {
@@ -302,7 +312,7 @@ func DemoAPIDatabase() {
 		{"POST", "/api/facturas/db", "Crear factura en base de datos"},
 		{"GET", "/api/facturas/db/list", "Listar facturas paginadas"},
 		{"GET", "/api/facturas/db/{id}", "Obtener factura por ID"},
-		{"PUT", "/api/facturas/db/{id}/estado", "Actualizar estado de factura"},
+		{"PUT", "/api/facturas/db/{id}/estado", "Reabrir o anular factura (BORRADOR, ANULADA)"},
 		{"GET", "/api/estadisticas", "Obtener estadísticas"},
 		{"POST", "/api/clientes", "Crear/actualizar cliente"},
 		{"GET", "/api/clientes/buscar?cedula={cedula}", "Buscar cliente por cédula"},
@@ -337,13 +347,12 @@ func DemoAPIDatabase() {
 	fmt.Println("\n3. Obtener factura específica:")
 	fmt.Println(`curl "http://localhost:8080/api/facturas/db/1?includeXML=true"`)
 
-	fmt.Println("\n4. Actualizar estado de factura:")
+	fmt.Println("\n4. Anular una factura no enviada:")
 	fmt.Println(`curl -X PUT http://localhost:8080/api/facturas/db/1/estado \
   -H "Content-Type: application/json" \
   -d '{
-    "estado": "AUTORIZADA",
-    "numero_autorizacion": "1234567890123456789",
-    "observaciones_sri": "Autorizada correctamente"
+    "estado": "ANULADA",
+    "observaciones_sri": "Emitida por error, nunca enviada al SRI"
   }'`)
 
 	fmt.Println("\n5. Obtener estadísticas:")
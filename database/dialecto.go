@@ -0,0 +1,139 @@
+// Package database adapta las consultas al motor SQL configurado (SQLite o PostgreSQL)
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+
+	_ "github.com/lib/pq" // Driver PostgreSQL
+)
+
+// Dialecto motor SQL de la base de datos; su valor es el nombre del driver database/sql
+type Dialecto string
+
+const (
+	DialectoSQLite   Dialecto = "sqlite3"
+	DialectoPostgres Dialecto = "postgres"
+)
+
+// ParsearDialecto interpreta el driver configurado; vacío equivale a SQLite
+func ParsearDialecto(driver string) (Dialecto, error) {
+	switch driver {
+	case "", string(DialectoSQLite):
+		return DialectoSQLite, nil
+	case string(DialectoPostgres):
+		return DialectoPostgres, nil
+	default:
+		return "", fmt.Errorf("driver de base de datos no soportado: %s", driver)
+	}
+}
+
+// Claves de los bloqueos de aplicación que serializan escrituras concurrentes en PostgreSQL
+const (
+	bloqueoNumeracionFacturas int64 = 4210001
+	bloqueoMigraciones        int64 = 4210002
+	bloqueoAuditoria          int64 = 4210003
+)
+
+// conexion envuelve *sql.DB para que las consultas, escritas con placeholders ?, funcionen
+// en cualquier dialecto
+type conexion struct {
+	*sql.DB
+	dialecto Dialecto
+}
+
+// Exec ejecuta la sentencia adaptada al dialecto
+func (c *conexion) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return c.DB.Exec(adaptarConsulta(c.dialecto, query), args...)
+}
+
+// Query ejecuta la consulta adaptada al dialecto
+func (c *conexion) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return c.DB.Query(adaptarConsulta(c.dialecto, query), args...)
+}
+
+// QueryRow ejecuta la consulta adaptada al dialecto
+func (c *conexion) QueryRow(query string, args ...interface{}) *sql.Row {
+	return c.DB.QueryRow(adaptarConsulta(c.dialecto, query), args...)
+}
+
+// Begin inicia una transacción. En SQLite es inmediata (_txlock=immediate); en PostgreSQL
+// las secciones que leen antes de escribir toman bloqueos explícitos con bloquear o paraActualizar.
+func (c *conexion) Begin() (*transaccion, error) {
+	tx, err := c.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &transaccion{Tx: tx, dialecto: c.dialecto}, nil
+}
+
+// transaccion envuelve *sql.Tx con la misma adaptación de consultas que conexion
+type transaccion struct {
+	*sql.Tx
+	dialecto Dialecto
+}
+
+// Exec ejecuta la sentencia adaptada al dialecto
+func (t *transaccion) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return t.Tx.Exec(adaptarConsulta(t.dialecto, query), args...)
+}
+
+// Query ejecuta la consulta adaptada al dialecto
+func (t *transaccion) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return t.Tx.Query(adaptarConsulta(t.dialecto, query), args...)
+}
+
+// QueryRow ejecuta la consulta adaptada al dialecto
+func (t *transaccion) QueryRow(query string, args ...interface{}) *sql.Row {
+	return t.Tx.QueryRow(adaptarConsulta(t.dialecto, query), args...)
+}
+
+// bloquear serializa la transacción con otras que usan la misma clave hasta el commit.
+// En SQLite la transacción inmediata ya excluye a otros escritores.
+func (t *transaccion) bloquear(clave int64) error {
+	if t.dialecto != DialectoPostgres {
+		return nil
+	}
+	if _, err := t.Exec("SELECT pg_advisory_xact_lock(?)", clave); err != nil {
+		return fmt.Errorf("error tomando bloqueo %d: %v", clave, err)
+	}
+	return nil
+}
+
+// paraActualizar sufijo que bloquea las filas leídas hasta el commit (sin efecto en SQLite)
+func (t *transaccion) paraActualizar() string {
+	if t.dialecto == DialectoPostgres {
+		return " FOR UPDATE"
+	}
+	return ""
+}
+
+// adaptarConsulta reescribe los placeholders ? como $1, $2... para PostgreSQL,
+// ignorando los que aparecen dentro de literales entre comillas simples
+func adaptarConsulta(dialecto Dialecto, query string) string {
+	if dialecto != DialectoPostgres || !strings.Contains(query, "?") {
+		return query
+	}
+
+	var builder strings.Builder
+	builder.Grow(len(query) + 16)
+	enLiteral := false
+	n := 0
+	for i := 0; i < len(query); i++ {
+		caracter := query[i]
+		switch {
+		case caracter == '\'':
+			enLiteral = !enLiteral
+			builder.WriteByte(caracter)
+		case caracter == '?' && !enLiteral:
+			n++
+			builder.WriteByte('$')
+			builder.WriteString(strconv.Itoa(n))
+		default:
+			builder.WriteByte(caracter)
+		}
+	}
+	return builder.String()
+}
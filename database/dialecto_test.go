@@ -0,0 +1,120 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// variablePostgresPrueba DSN de un servidor PostgreSQL de pruebas. Si está definida,
+// los tests del paquete se ejecutan contra PostgreSQL en vez de SQLite.
+const variablePostgresPrueba = "FACTURACION_TEST_POSTGRES_DSN"
+
+// abrirDBPrueba abre la base de datos de un test: el archivo SQLite dbPath o, con
+// FACTURACION_TEST_POSTGRES_DSN definida, un schema PostgreSQL propio que se elimina al terminar
+func abrirDBPrueba(t testing.TB, dbPath string) (*Database, error) {
+	t.Helper()
+
+	dsn := os.Getenv(variablePostgresPrueba)
+	if dsn == "" {
+		return New(dbPath)
+	}
+
+	admin, err := sql.Open(string(DialectoPostgres), dsn)
+	if err != nil {
+		return nil, err
+	}
+	schema := fmt.Sprintf("prueba_%d", time.Now().UnixNano())
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		admin.Close()
+		return nil, fmt.Errorf("error creando schema de prueba: %v", err)
+	}
+	t.Cleanup(func() {
+		admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+		admin.Close()
+	})
+
+	return Abrir(DialectoPostgres, conSearchPath(dsn, schema))
+}
+
+// conSearchPath agrega search_path al DSN (formato URL o clave=valor); lib/pq lo envía como parámetro de sesión
+func conSearchPath(dsn, schema string) string {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		separador := "?"
+		if strings.Contains(dsn, "?") {
+			separador = "&"
+		}
+		return dsn + separador + "search_path=" + url.QueryEscape(schema)
+	}
+	return dsn + " search_path=" + schema
+}
+
+func TestAdaptarConsulta(t *testing.T) {
+	casos := []struct {
+		consulta string
+		postgres string
+	}{
+		{"SELECT 1", "SELECT 1"},
+		{"SELECT * FROM facturas WHERE id = ?", "SELECT * FROM facturas WHERE id = $1"},
+		{"UPDATE t SET a = ?, b = ? WHERE c IN (?, ?)", "UPDATE t SET a = $1, b = $2 WHERE c IN ($3, $4)"},
+		{"SELECT * FROM t WHERE a = '¿?' AND b = ?", "SELECT * FROM t WHERE a = '¿?' AND b = $1"},
+		{"SELECT * FROM t WHERE a = 'it''s?' AND b = ?", "SELECT * FROM t WHERE a = 'it''s?' AND b = $1"},
+		{"INSERT INTO t VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+			"INSERT INTO t VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)"},
+		{"SELECT a FROM t WHERE b LIKE '%?%' OR c = ? FOR UPDATE", "SELECT a FROM t WHERE b LIKE '%?%' OR c = $1 FOR UPDATE"},
+	}
+
+	for _, caso := range casos {
+		if obtenida := adaptarConsulta(DialectoSQLite, caso.consulta); obtenida != caso.consulta {
+			t.Errorf("SQLite no debe modificar %q, obtuvo %q", caso.consulta, obtenida)
+		}
+		if obtenida := adaptarConsulta(DialectoPostgres, caso.consulta); obtenida != caso.postgres {
+			t.Errorf("adaptarConsulta(postgres, %q) = %q, esperado %q", caso.consulta, obtenida, caso.postgres)
+		}
+	}
+}
+
+func TestConSearchPath(t *testing.T) {
+	casos := []struct {
+		dsn      string
+		esperado string
+	}{
+		{"postgres://u:p@localhost:5432/db", "postgres://u:p@localhost:5432/db?search_path=prueba_1"},
+		{"postgresql://localhost/db?sslmode=disable", "postgresql://localhost/db?sslmode=disable&search_path=prueba_1"},
+		{"host=localhost dbname=db sslmode=disable", "host=localhost dbname=db sslmode=disable search_path=prueba_1"},
+	}
+	for _, caso := range casos {
+		if obtenido := conSearchPath(caso.dsn, "prueba_1"); obtenido != caso.esperado {
+			t.Errorf("conSearchPath(%q) = %q, esperado %q", caso.dsn, obtenido, caso.esperado)
+		}
+	}
+}
+
+func TestParsearDialecto(t *testing.T) {
+	casos := map[string]Dialecto{"": DialectoSQLite, "sqlite3": DialectoSQLite, "postgres": DialectoPostgres}
+	for driver, esperado := range casos {
+		if dialecto, err := ParsearDialecto(driver); err != nil || dialecto != esperado {
+			t.Errorf("ParsearDialecto(%q) = %q, %v; esperado %q", driver, dialecto, err, esperado)
+		}
+	}
+	if _, err := ParsearDialecto("mysql"); err == nil {
+		t.Error("ParsearDialecto(mysql) debió fallar")
+	}
+}
+
+func TestMigracionesPostgresEquivalentes(t *testing.T) {
+	if len(migracionesPostgres) != len(migraciones) {
+		t.Fatalf("PostgreSQL tiene %d migraciones, SQLite %d", len(migracionesPostgres), len(migraciones))
+	}
+	for i, migracion := range migraciones {
+		postgres := migracionesPostgres[i]
+		if postgres.Version != migracion.Version || postgres.Descripcion != migracion.Descripcion {
+			t.Errorf("Migración %d difiere entre dialectos: %d %q / %d %q", i,
+				migracion.Version, migracion.Descripcion, postgres.Version, postgres.Descripcion)
+		}
+	}
+}
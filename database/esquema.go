@@ -0,0 +1,676 @@
+// Package database define las migraciones versionadas del esquema SQLite
+package database
+
+import (
+	"fmt"
+)
+
+// migraciones historial del esquema en orden de versión. Nunca se modifica una migración
+// publicada: los cambios de esquema se agregan como una versión nueva al final.
+var migraciones = []Migracion{
+	{
+		Version:     1,
+		Descripcion: "esquema inicial: facturas, productos, clientes, configuración y auditoría",
+		Subir: sentencias(
+			`CREATE TABLE IF NOT EXISTS facturas (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				numero_factura TEXT NOT NULL UNIQUE,
+				clave_acceso TEXT NOT NULL UNIQUE,
+				fecha_emision DATETIME NOT NULL,
+				cliente_nombre TEXT NOT NULL,
+				cliente_cedula TEXT NOT NULL,
+				cliente_direccion TEXT,
+				cliente_telefono TEXT,
+				cliente_email TEXT,
+				subtotal REAL NOT NULL,
+				iva REAL NOT NULL,
+				total REAL NOT NULL,
+				estado TEXT NOT NULL DEFAULT 'BORRADOR',
+				numero_autorizacion TEXT,
+				fecha_autorizacion DATETIME,
+				xml_original TEXT,
+				xml_autorizado TEXT,
+				observaciones_sri TEXT,
+				ambiente TEXT NOT NULL DEFAULT 'PRUEBAS',
+				tipo_emision TEXT NOT NULL DEFAULT 'NORMAL',
+				fecha_creacion DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+				fecha_actualizacion DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+			)`,
+			`CREATE TABLE IF NOT EXISTS productos (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				factura_id INTEGER NOT NULL,
+				codigo TEXT NOT NULL,
+				codigo_principal TEXT,
+				codigo_auxiliar TEXT,
+				descripcion TEXT NOT NULL,
+				unidad_medida TEXT DEFAULT 'UNI',
+				cantidad REAL NOT NULL,
+				precio_unitario REAL NOT NULL,
+				descuento REAL DEFAULT 0,
+				precio_total_sin_iva REAL NOT NULL,
+				precio_total REAL NOT NULL,
+				iva REAL NOT NULL,
+				FOREIGN KEY (factura_id) REFERENCES facturas (id) ON DELETE CASCADE
+			)`,
+			`CREATE TABLE IF NOT EXISTS clientes (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				cedula TEXT NOT NULL UNIQUE,
+				nombre TEXT NOT NULL,
+				direccion TEXT,
+				telefono TEXT,
+				email TEXT,
+				tipo_cliente TEXT NOT NULL DEFAULT 'PERSONA_NATURAL',
+				fecha_creacion DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+				activo BOOLEAN NOT NULL DEFAULT 1
+			)`,
+			`CREATE TABLE IF NOT EXISTS configuracion (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				clave TEXT NOT NULL UNIQUE,
+				valor TEXT NOT NULL,
+				tipo TEXT NOT NULL DEFAULT 'STRING',
+				activo BOOLEAN NOT NULL DEFAULT 1
+			)`,
+			`CREATE TABLE IF NOT EXISTS audit_log (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				tabla TEXT NOT NULL,
+				registro_id INTEGER NOT NULL,
+				operacion TEXT NOT NULL,
+				usuario TEXT NOT NULL DEFAULT 'sistema',
+				datos_antes TEXT,
+				datos_despues TEXT,
+				ip_address TEXT,
+				user_agent TEXT,
+				timestamp DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+			)`,
+			"CREATE INDEX IF NOT EXISTS idx_facturas_numero ON facturas(numero_factura)",
+			"CREATE INDEX IF NOT EXISTS idx_facturas_clave ON facturas(clave_acceso)",
+			"CREATE INDEX IF NOT EXISTS idx_facturas_cliente ON facturas(cliente_cedula)",
+			"CREATE INDEX IF NOT EXISTS idx_facturas_fecha ON facturas(fecha_emision)",
+			"CREATE INDEX IF NOT EXISTS idx_facturas_estado ON facturas(estado)",
+			"CREATE INDEX IF NOT EXISTS idx_productos_factura ON productos(factura_id)",
+			"CREATE INDEX IF NOT EXISTS idx_clientes_cedula ON clientes(cedula)",
+			"CREATE INDEX IF NOT EXISTS idx_audit_tabla ON audit_log(tabla)",
+			"CREATE INDEX IF NOT EXISTS idx_audit_registro ON audit_log(registro_id)",
+			"CREATE INDEX IF NOT EXISTS idx_audit_timestamp ON audit_log(timestamp)",
+			"CREATE INDEX IF NOT EXISTS idx_audit_usuario ON audit_log(usuario)",
+		),
+		Bajar: sentencias(
+			"DROP TABLE IF EXISTS audit_log",
+			"DROP TABLE IF EXISTS configuracion",
+			"DROP TABLE IF EXISTS clientes",
+			"DROP TABLE IF EXISTS productos",
+			"DROP TABLE IF EXISTS facturas",
+		),
+	},
+	{
+		Version:     2,
+		Descripcion: "cola persistente de trabajos de autorización SRI",
+		Subir: sentencias(
+			`CREATE TABLE IF NOT EXISTS trabajos_autorizacion (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				factura_id INTEGER NOT NULL,
+				clave_acceso TEXT,
+				etapa TEXT NOT NULL DEFAULT 'FIRMAR',
+				estado TEXT NOT NULL DEFAULT 'PENDIENTE',
+				intentos INTEGER NOT NULL DEFAULT 0,
+				proximo_intento DATETIME NOT NULL,
+				xml_firmado TEXT,
+				ultimo_error TEXT,
+				worker TEXT,
+				fecha_creacion DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+				fecha_actualizacion DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (factura_id) REFERENCES facturas (id) ON DELETE CASCADE
+			)`,
+			"CREATE INDEX IF NOT EXISTS idx_trabajos_estado ON trabajos_autorizacion(estado, proximo_intento)",
+			"CREATE INDEX IF NOT EXISTS idx_trabajos_factura ON trabajos_autorizacion(factura_id)",
+		),
+		Bajar: sentencias("DROP TABLE IF EXISTS trabajos_autorizacion"),
+	},
+	{
+		Version:     3,
+		Descripcion: "historial de transiciones de estado de facturas",
+		Subir: sentencias(
+			`CREATE TABLE IF NOT EXISTS historial_estados_factura (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				factura_id INTEGER NOT NULL,
+				estado_anterior TEXT NOT NULL,
+				estado_nuevo TEXT NOT NULL,
+				actor TEXT NOT NULL,
+				mensajes_sri TEXT,
+				observaciones TEXT,
+				fecha DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (factura_id) REFERENCES facturas (id) ON DELETE CASCADE
+			)`,
+			"CREATE INDEX IF NOT EXISTS idx_historial_estados_factura ON historial_estados_factura(factura_id)",
+		),
+		Bajar: sentencias("DROP TABLE IF EXISTS historial_estados_factura"),
+	},
+	{
+		Version:     4,
+		Descripcion: "archivo de envelopes SOAP intercambiados con el SRI",
+		Subir: sentencias(
+			`CREATE TABLE IF NOT EXISTS intercambios_soap (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				operacion TEXT NOT NULL,
+				clave_acceso TEXT,
+				ambiente TEXT,
+				endpoint TEXT,
+				codigo_http INTEGER,
+				duracion_ms INTEGER,
+				error TEXT,
+				comprimido BOOLEAN DEFAULT 0,
+				solicitud BLOB,
+				respuesta BLOB,
+				fecha DATETIME NOT NULL
+			)`,
+			"CREATE INDEX IF NOT EXISTS idx_intercambios_soap_clave ON intercambios_soap(clave_acceso)",
+			"CREATE INDEX IF NOT EXISTS idx_intercambios_soap_fecha ON intercambios_soap(fecha)",
+		),
+		Bajar: sentencias("DROP TABLE IF EXISTS intercambios_soap"),
+	},
+	{
+		Version:     5,
+		Descripcion: "cola de fallidos y errores clasificados por intento",
+		Subir: func(tx *transaccion) error {
+			// Instalaciones previas al sistema de migraciones pueden tener ya la columna
+			if err := asegurarColumna(tx, "trabajos_autorizacion", "errores_intentos", "TEXT"); err != nil {
+				return err
+			}
+			return sentencias(
+				`CREATE TABLE IF NOT EXISTS cola_fallidos (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					factura_id INTEGER,
+					trabajo_id INTEGER,
+					clave_acceso TEXT,
+					origen TEXT NOT NULL,
+					etapa TEXT NOT NULL,
+					xml_comprobante TEXT,
+					intentos TEXT,
+					ultimo_error TEXT,
+					recuperable BOOLEAN NOT NULL DEFAULT 0,
+					estado TEXT NOT NULL DEFAULT 'PENDIENTE',
+					reencolados INTEGER NOT NULL DEFAULT 0,
+					motivo_descarte TEXT,
+					fecha_creacion DATETIME NOT NULL,
+					fecha_actualizacion DATETIME NOT NULL
+				)`,
+				"CREATE INDEX IF NOT EXISTS idx_cola_fallidos_estado ON cola_fallidos(estado)",
+				"CREATE INDEX IF NOT EXISTS idx_cola_fallidos_factura ON cola_fallidos(factura_id)",
+			)(tx)
+		},
+		Bajar: sentencias(
+			"DROP TABLE IF EXISTS cola_fallidos",
+			"ALTER TABLE trabajos_autorizacion DROP COLUMN errores_intentos",
+		),
+	},
+	{
+		Version:     6,
+		Descripcion: "catálogo maestro de productos y servicios",
+		Subir: sentencias(
+			`CREATE TABLE IF NOT EXISTS catalogo_productos (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				codigo_principal TEXT NOT NULL UNIQUE,
+				codigo_auxiliar TEXT,
+				descripcion TEXT NOT NULL,
+				unidad_medida TEXT NOT NULL DEFAULT 'UNI',
+				precio_unitario REAL NOT NULL,
+				codigo_iva TEXT NOT NULL DEFAULT '4',
+				codigo_ice TEXT,
+				tarifa_ice REAL NOT NULL DEFAULT 0,
+				activo BOOLEAN NOT NULL DEFAULT 1,
+				fecha_creacion DATETIME NOT NULL,
+				fecha_actualizacion DATETIME NOT NULL
+			)`,
+			"CREATE INDEX IF NOT EXISTS idx_catalogo_productos_auxiliar ON catalogo_productos(codigo_auxiliar)",
+		),
+		Bajar: sentencias("DROP TABLE IF EXISTS catalogo_productos"),
+	},
+	{
+		Version:     7,
+		Descripcion: "inventario por establecimiento y kardex de movimientos",
+		Subir: sentencias(
+			"ALTER TABLE catalogo_productos ADD COLUMN controla_inventario BOOLEAN NOT NULL DEFAULT 0",
+			`CREATE TABLE IF NOT EXISTS stock_productos (
+				producto_id INTEGER NOT NULL,
+				establecimiento TEXT NOT NULL,
+				cantidad REAL NOT NULL DEFAULT 0,
+				stock_minimo REAL NOT NULL DEFAULT 0,
+				fecha_actualizacion DATETIME NOT NULL,
+				PRIMARY KEY (producto_id, establecimiento),
+				FOREIGN KEY (producto_id) REFERENCES catalogo_productos (id)
+			)`,
+			`CREATE TABLE IF NOT EXISTS movimientos_inventario (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				producto_id INTEGER NOT NULL,
+				establecimiento TEXT NOT NULL,
+				tipo TEXT NOT NULL,
+				cantidad REAL NOT NULL,
+				saldo REAL NOT NULL,
+				factura_id INTEGER,
+				documento TEXT,
+				motivo TEXT,
+				usuario TEXT NOT NULL,
+				fecha DATETIME NOT NULL,
+				FOREIGN KEY (producto_id) REFERENCES catalogo_productos (id)
+			)`,
+			"CREATE INDEX IF NOT EXISTS idx_movimientos_inventario_producto ON movimientos_inventario(producto_id, establecimiento)",
+			"CREATE INDEX IF NOT EXISTS idx_movimientos_inventario_factura ON movimientos_inventario(factura_id)",
+			"CREATE INDEX IF NOT EXISTS idx_movimientos_inventario_documento ON movimientos_inventario(documento)",
+		),
+		Bajar: sentencias(
+			"DROP TABLE IF EXISTS movimientos_inventario",
+			"DROP TABLE IF EXISTS stock_productos",
+			"ALTER TABLE catalogo_productos DROP COLUMN controla_inventario",
+		),
+	},
+	{
+		Version:     8,
+		Descripcion: "índices de texto completo de clientes y productos",
+		Subir:       crearIndicesTextoCompleto,
+		Bajar:       eliminarIndicesTextoCompleto,
+	},
+	{
+		Version:     9,
+		Descripcion: "solicitudes de anulación de facturas autorizadas",
+		Subir: sentencias(
+			`CREATE TABLE IF NOT EXISTS anulaciones_factura (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				factura_id INTEGER NOT NULL,
+				motivo TEXT NOT NULL,
+				estado TEXT NOT NULL,
+				referencia_sri TEXT,
+				observaciones TEXT,
+				solicitado_por TEXT NOT NULL,
+				fecha_solicitud DATETIME NOT NULL,
+				resuelto_por TEXT,
+				fecha_resolucion DATETIME,
+				FOREIGN KEY (factura_id) REFERENCES facturas (id)
+			)`,
+			"CREATE INDEX IF NOT EXISTS idx_anulaciones_factura ON anulaciones_factura(factura_id)",
+			// Una sola solicitud pendiente por factura
+			"CREATE UNIQUE INDEX IF NOT EXISTS idx_anulaciones_pendiente ON anulaciones_factura(factura_id) WHERE estado = 'SOLICITADA'",
+		),
+		Bajar: sentencias("DROP TABLE IF EXISTS anulaciones_factura"),
+	},
+	{
+		Version:     10,
+		Descripcion: "cadena de hashes del log de auditoría",
+		Subir: func(tx *transaccion) error {
+			err := sentencias(
+				"ALTER TABLE audit_log ADD COLUMN hash TEXT",
+				"ALTER TABLE audit_log ADD COLUMN hash_anterior TEXT",
+			)(tx)
+			if err != nil {
+				return err
+			}
+			return sellarAuditoriaExistente(tx)
+		},
+		Bajar: sentencias(
+			"ALTER TABLE audit_log DROP COLUMN hash_anterior",
+			"ALTER TABLE audit_log DROP COLUMN hash",
+		),
+	},
+	{
+		Version:     11,
+		Descripcion: "request ID de la petición que originó cada entrada de auditoría",
+		Subir: sentencias(
+			"ALTER TABLE audit_log ADD COLUMN request_id TEXT",
+			"CREATE INDEX IF NOT EXISTS idx_audit_request ON audit_log(request_id)",
+		),
+		Bajar: sentencias(
+			"DROP INDEX IF EXISTS idx_audit_request",
+			"ALTER TABLE audit_log DROP COLUMN request_id",
+		),
+	},
+	{
+		Version:     12,
+		Descripcion: "tenants: emisores con sus establecimientos y credenciales; datos aislados por tenant_id",
+		Subir: func(tx *transaccion) error {
+			err := sentencias(
+				`CREATE TABLE IF NOT EXISTS tenants (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					ruc TEXT NOT NULL UNIQUE,
+					razon_social TEXT NOT NULL,
+					nombre_comercial TEXT,
+					direccion_matriz TEXT NOT NULL,
+					obligado_contabilidad BOOLEAN NOT NULL DEFAULT 0,
+					contribuyente_especial TEXT,
+					regimen TEXT NOT NULL DEFAULT 'GENERAL',
+					ambiente TEXT NOT NULL DEFAULT '1',
+					tipo_emision TEXT NOT NULL DEFAULT '1',
+					certificado_ruta TEXT,
+					certificado_password TEXT,
+					activo BOOLEAN NOT NULL DEFAULT 1,
+					fecha_creacion DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+				)`,
+				`CREATE TABLE IF NOT EXISTS establecimientos_tenant (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					tenant_id INTEGER NOT NULL,
+					codigo TEXT NOT NULL,
+					punto_emision TEXT NOT NULL,
+					direccion TEXT NOT NULL,
+					secuencial INTEGER NOT NULL DEFAULT 0,
+					activo BOOLEAN NOT NULL DEFAULT 1,
+					FOREIGN KEY (tenant_id) REFERENCES tenants (id),
+					UNIQUE (tenant_id, codigo, punto_emision)
+				)`,
+				`CREATE TABLE IF NOT EXISTS credenciales_tenant (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					tenant_id INTEGER NOT NULL,
+					nombre TEXT NOT NULL,
+					prefijo TEXT NOT NULL,
+					hash_clave TEXT NOT NULL UNIQUE,
+					activa BOOLEAN NOT NULL DEFAULT 1,
+					fecha_creacion DATETIME NOT NULL,
+					fecha_revocacion DATETIME,
+					FOREIGN KEY (tenant_id) REFERENCES tenants (id)
+				)`,
+				"CREATE INDEX IF NOT EXISTS idx_credenciales_tenant ON credenciales_tenant(tenant_id)",
+				// tenant_id 0 es el emisor de config.Config, sin fila en tenants
+				"ALTER TABLE audit_log ADD COLUMN tenant_id INTEGER NOT NULL DEFAULT 0",
+				"CREATE INDEX IF NOT EXISTS idx_audit_tenant ON audit_log(tenant_id)",
+			)(tx)
+			if err != nil {
+				return err
+			}
+			// Número de factura y cédula pasan a ser únicos por tenant: SQLite no puede quitar
+			// la restricción UNIQUE original sin reconstruir las tablas
+			err = reconstruirTabla(tx, "facturas", tablaFacturasTenant, columnasFacturas+", tenant_id", columnasFacturas+", 0")
+			if err != nil {
+				return err
+			}
+			err = reconstruirTabla(tx, "clientes", tablaClientesTenant, columnasClientes+", tenant_id", columnasClientes+", 0")
+			if err != nil {
+				return err
+			}
+			err = sentencias(append(indicesFacturasClientes,
+				"CREATE UNIQUE INDEX IF NOT EXISTS idx_facturas_tenant_numero ON facturas(tenant_id, numero_factura)",
+				"CREATE UNIQUE INDEX IF NOT EXISTS idx_clientes_tenant_cedula ON clientes(tenant_id, cedula)",
+			)...)(tx)
+			if err != nil {
+				return err
+			}
+			return crearIndicesTextoCompleto(tx)
+		},
+		Bajar: func(tx *transaccion) error {
+			// Falla si dos tenants comparten un número de factura o una cédula
+			err := reconstruirTabla(tx, "facturas", tablaFacturasGlobal, columnasFacturas, columnasFacturas)
+			if err != nil {
+				return err
+			}
+			err = reconstruirTabla(tx, "clientes", tablaClientesGlobal, columnasClientes, columnasClientes)
+			if err != nil {
+				return err
+			}
+			err = sentencias(append(indicesFacturasClientes,
+				"DROP INDEX IF EXISTS idx_audit_tenant",
+				"ALTER TABLE audit_log DROP COLUMN tenant_id",
+				"DROP TABLE IF EXISTS credenciales_tenant",
+				"DROP TABLE IF EXISTS establecimientos_tenant",
+				"DROP TABLE IF EXISTS tenants",
+			)...)(tx)
+			if err != nil {
+				return err
+			}
+			return crearIndicesTextoCompleto(tx)
+		},
+	},
+	{
+		Version:     13,
+		Descripcion: "catálogo e inventario aislados por tenant_id",
+		Subir: func(tx *transaccion) error {
+			// Código principal y existencias pasan a ser únicos por tenant
+			err := reconstruirTabla(tx, "catalogo_productos", tablaCatalogoTenant, columnasCatalogo+", tenant_id", columnasCatalogo+", 0")
+			if err != nil {
+				return err
+			}
+			err = reconstruirTabla(tx, "stock_productos", tablaStockTenant, columnasStock+", tenant_id", columnasStock+", 0")
+			if err != nil {
+				return err
+			}
+			return sentencias(
+				"CREATE INDEX IF NOT EXISTS idx_catalogo_productos_auxiliar ON catalogo_productos(codigo_auxiliar)",
+				"CREATE UNIQUE INDEX IF NOT EXISTS idx_catalogo_tenant_codigo ON catalogo_productos(tenant_id, codigo_principal)",
+				"ALTER TABLE movimientos_inventario ADD COLUMN tenant_id INTEGER NOT NULL DEFAULT 0",
+				"CREATE INDEX IF NOT EXISTS idx_movimientos_inventario_tenant ON movimientos_inventario(tenant_id, producto_id)",
+			)(tx)
+		},
+		Bajar: func(tx *transaccion) error {
+			// Falla si dos tenants comparten un código principal
+			err := reconstruirTabla(tx, "catalogo_productos", tablaCatalogoGlobal, columnasCatalogo, columnasCatalogo)
+			if err != nil {
+				return err
+			}
+			err = reconstruirTabla(tx, "stock_productos", tablaStockGlobal, columnasStock, columnasStock)
+			if err != nil {
+				return err
+			}
+			return sentencias(
+				"CREATE INDEX IF NOT EXISTS idx_catalogo_productos_auxiliar ON catalogo_productos(codigo_auxiliar)",
+				"DROP INDEX IF EXISTS idx_movimientos_inventario_tenant",
+				"ALTER TABLE movimientos_inventario DROP COLUMN tenant_id",
+			)(tx)
+		},
+	},
+	{
+		Version:     14,
+		Descripcion: "secuenciales del emisor de la configuración por establecimiento y punto de emisión",
+		Subir: sentencias(
+			`CREATE TABLE IF NOT EXISTS secuenciales_emisor (
+				ruc TEXT NOT NULL,
+				establecimiento TEXT NOT NULL,
+				punto_emision TEXT NOT NULL,
+				secuencial INTEGER NOT NULL,
+				PRIMARY KEY (ruc, establecimiento, punto_emision)
+			)`,
+		),
+		Bajar: sentencias("DROP TABLE IF EXISTS secuenciales_emisor"),
+	},
+	{
+		Version:     15,
+		Descripcion: "recepción devuelta por clave registrada o en procesamiento (43, 70)",
+		Subir:       sentencias("ALTER TABLE trabajos_autorizacion ADD COLUMN recepcion_duplicada BOOLEAN NOT NULL DEFAULT 0"),
+		Bajar:       sentencias("ALTER TABLE trabajos_autorizacion DROP COLUMN recepcion_duplicada"),
+	},
+}
+
+// Columnas de facturas y clientes previas a tenant_id, copiadas al reconstruir las tablas
+const (
+	columnasFacturas = `id, numero_factura, clave_acceso, fecha_emision, cliente_nombre, cliente_cedula,
+		cliente_direccion, cliente_telefono, cliente_email, subtotal, iva, total, estado,
+		numero_autorizacion, fecha_autorizacion, xml_original, xml_autorizado, observaciones_sri,
+		ambiente, tipo_emision, fecha_creacion, fecha_actualizacion`
+	columnasClientes = "id, cedula, nombre, direccion, telefono, email, tipo_cliente, fecha_creacion, activo"
+)
+
+// Definiciones de facturas y clientes para reconstruirTabla; %s es el nombre de la tabla.
+// Las versiones "Global" corresponden a la migración 1.
+const (
+	tablaFacturasGlobal = `CREATE TABLE %s (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		numero_factura TEXT NOT NULL UNIQUE,
+		clave_acceso TEXT NOT NULL UNIQUE,
+		fecha_emision DATETIME NOT NULL,
+		cliente_nombre TEXT NOT NULL,
+		cliente_cedula TEXT NOT NULL,
+		cliente_direccion TEXT,
+		cliente_telefono TEXT,
+		cliente_email TEXT,
+		subtotal REAL NOT NULL,
+		iva REAL NOT NULL,
+		total REAL NOT NULL,
+		estado TEXT NOT NULL DEFAULT 'BORRADOR',
+		numero_autorizacion TEXT,
+		fecha_autorizacion DATETIME,
+		xml_original TEXT,
+		xml_autorizado TEXT,
+		observaciones_sri TEXT,
+		ambiente TEXT NOT NULL DEFAULT 'PRUEBAS',
+		tipo_emision TEXT NOT NULL DEFAULT 'NORMAL',
+		fecha_creacion DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		fecha_actualizacion DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`
+	tablaFacturasTenant = `CREATE TABLE %s (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		numero_factura TEXT NOT NULL,
+		clave_acceso TEXT NOT NULL UNIQUE,
+		fecha_emision DATETIME NOT NULL,
+		cliente_nombre TEXT NOT NULL,
+		cliente_cedula TEXT NOT NULL,
+		cliente_direccion TEXT,
+		cliente_telefono TEXT,
+		cliente_email TEXT,
+		subtotal REAL NOT NULL,
+		iva REAL NOT NULL,
+		total REAL NOT NULL,
+		estado TEXT NOT NULL DEFAULT 'BORRADOR',
+		numero_autorizacion TEXT,
+		fecha_autorizacion DATETIME,
+		xml_original TEXT,
+		xml_autorizado TEXT,
+		observaciones_sri TEXT,
+		ambiente TEXT NOT NULL DEFAULT 'PRUEBAS',
+		tipo_emision TEXT NOT NULL DEFAULT 'NORMAL',
+		fecha_creacion DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		fecha_actualizacion DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		tenant_id INTEGER NOT NULL DEFAULT 0
+	)`
+	tablaClientesGlobal = `CREATE TABLE %s (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		cedula TEXT NOT NULL UNIQUE,
+		nombre TEXT NOT NULL,
+		direccion TEXT,
+		telefono TEXT,
+		email TEXT,
+		tipo_cliente TEXT NOT NULL DEFAULT 'PERSONA_NATURAL',
+		fecha_creacion DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		activo BOOLEAN NOT NULL DEFAULT 1
+	)`
+	tablaClientesTenant = `CREATE TABLE %s (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		cedula TEXT NOT NULL,
+		nombre TEXT NOT NULL,
+		direccion TEXT,
+		telefono TEXT,
+		email TEXT,
+		tipo_cliente TEXT NOT NULL DEFAULT 'PERSONA_NATURAL',
+		fecha_creacion DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		activo BOOLEAN NOT NULL DEFAULT 1,
+		tenant_id INTEGER NOT NULL DEFAULT 0
+	)`
+)
+
+// Columnas del catálogo y del stock previas a tenant_id
+const (
+	columnasCatalogo = `id, codigo_principal, codigo_auxiliar, descripcion, unidad_medida, precio_unitario,
+		codigo_iva, codigo_ice, tarifa_ice, activo, fecha_creacion, fecha_actualizacion, controla_inventario`
+	columnasStock = "producto_id, establecimiento, cantidad, stock_minimo, fecha_actualizacion"
+)
+
+// Definiciones del catálogo y del stock para reconstruirTabla. Las versiones "Global"
+// corresponden a las migraciones 6 y 7.
+const (
+	tablaCatalogoGlobal = `CREATE TABLE %s (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		codigo_principal TEXT NOT NULL UNIQUE,
+		codigo_auxiliar TEXT,
+		descripcion TEXT NOT NULL,
+		unidad_medida TEXT NOT NULL DEFAULT 'UNI',
+		precio_unitario REAL NOT NULL,
+		codigo_iva TEXT NOT NULL DEFAULT '4',
+		codigo_ice TEXT,
+		tarifa_ice REAL NOT NULL DEFAULT 0,
+		activo BOOLEAN NOT NULL DEFAULT 1,
+		fecha_creacion DATETIME NOT NULL,
+		fecha_actualizacion DATETIME NOT NULL,
+		controla_inventario BOOLEAN NOT NULL DEFAULT 0
+	)`
+	tablaCatalogoTenant = `CREATE TABLE %s (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		codigo_principal TEXT NOT NULL,
+		codigo_auxiliar TEXT,
+		descripcion TEXT NOT NULL,
+		unidad_medida TEXT NOT NULL DEFAULT 'UNI',
+		precio_unitario REAL NOT NULL,
+		codigo_iva TEXT NOT NULL DEFAULT '4',
+		codigo_ice TEXT,
+		tarifa_ice REAL NOT NULL DEFAULT 0,
+		activo BOOLEAN NOT NULL DEFAULT 1,
+		fecha_creacion DATETIME NOT NULL,
+		fecha_actualizacion DATETIME NOT NULL,
+		controla_inventario BOOLEAN NOT NULL DEFAULT 0,
+		tenant_id INTEGER NOT NULL DEFAULT 0
+	)`
+	tablaStockGlobal = `CREATE TABLE %s (
+		producto_id INTEGER NOT NULL,
+		establecimiento TEXT NOT NULL,
+		cantidad REAL NOT NULL DEFAULT 0,
+		stock_minimo REAL NOT NULL DEFAULT 0,
+		fecha_actualizacion DATETIME NOT NULL,
+		PRIMARY KEY (producto_id, establecimiento),
+		FOREIGN KEY (producto_id) REFERENCES catalogo_productos (id)
+	)`
+	tablaStockTenant = `CREATE TABLE %s (
+		producto_id INTEGER NOT NULL,
+		establecimiento TEXT NOT NULL,
+		cantidad REAL NOT NULL DEFAULT 0,
+		stock_minimo REAL NOT NULL DEFAULT 0,
+		fecha_actualizacion DATETIME NOT NULL,
+		tenant_id INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (tenant_id, producto_id, establecimiento),
+		FOREIGN KEY (producto_id) REFERENCES catalogo_productos (id)
+	)`
+)
+
+// indicesFacturasClientes índices de la migración 1 que se pierden al reconstruir las tablas
+var indicesFacturasClientes = []string{
+	"CREATE INDEX IF NOT EXISTS idx_facturas_numero ON facturas(numero_factura)",
+	"CREATE INDEX IF NOT EXISTS idx_facturas_clave ON facturas(clave_acceso)",
+	"CREATE INDEX IF NOT EXISTS idx_facturas_cliente ON facturas(cliente_cedula)",
+	"CREATE INDEX IF NOT EXISTS idx_facturas_fecha ON facturas(fecha_emision)",
+	"CREATE INDEX IF NOT EXISTS idx_facturas_estado ON facturas(estado)",
+	"CREATE INDEX IF NOT EXISTS idx_clientes_cedula ON clientes(cedula)",
+}
+
+// reconstruirTabla reemplaza una tabla por la definición indicada copiando sus filas (los IDs se
+// conservan). Sus índices y triggers se eliminan con ella y deben crearse de nuevo; las tablas
+// que la referencian por clave foránea la siguen referenciando por nombre.
+func reconstruirTabla(tx *transaccion, tabla, definicion, columnasDestino, columnasOrigen string) error {
+	// Con claves foráneas activas DROP TABLE borraría en cascada las filas de las tablas hijas
+	var clavesForaneas int
+	if err := tx.QueryRow("PRAGMA foreign_keys").Scan(&clavesForaneas); err != nil {
+		return fmt.Errorf("error verificando claves foráneas: %v", err)
+	}
+	if clavesForaneas != 0 {
+		return fmt.Errorf("no se puede reconstruir %s con PRAGMA foreign_keys activo", tabla)
+	}
+
+	temporal := tabla + "_reconstruida"
+	err := sentencias(
+		fmt.Sprintf(definicion, temporal),
+		fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s", temporal, columnasDestino, columnasOrigen, tabla),
+		"DROP TABLE "+tabla,
+		fmt.Sprintf("ALTER TABLE %s RENAME TO %s", temporal, tabla),
+	)(tx)
+	if err != nil {
+		return fmt.Errorf("error reconstruyendo tabla %s: %v", tabla, err)
+	}
+	return nil
+}
+
+// asegurarColumna agrega la columna a una tabla existente si aún no la tiene
+func asegurarColumna(tx *transaccion, tabla, columna, definicion string) error {
+	var existe int
+	err := tx.QueryRow("SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?", tabla, columna).Scan(&existe)
+	if err != nil {
+		return fmt.Errorf("error leyendo columnas de %s: %v", tabla, err)
+	}
+	if existe > 0 {
+		return nil
+	}
+
+	if _, err := tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", tabla, columna, definicion)); err != nil {
+		return fmt.Errorf("error agregando columna %s.%s: %v", tabla, columna, err)
+	}
+	return nil
+}
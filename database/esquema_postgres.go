@@ -0,0 +1,432 @@
+// Package database define las migraciones versionadas del esquema PostgreSQL
+package database
+
+// migracionesPostgres equivalente PostgreSQL de migraciones: mismas versiones, tablas y
+// columnas, con los tipos nativos del motor. Toda migración nueva se agrega en ambos historiales.
+var migracionesPostgres = []Migracion{
+	{
+		Version:     1,
+		Descripcion: "esquema inicial: facturas, productos, clientes, configuración y auditoría",
+		Subir: sentencias(
+			`CREATE TABLE IF NOT EXISTS facturas (
+				id SERIAL PRIMARY KEY,
+				numero_factura TEXT NOT NULL UNIQUE,
+				clave_acceso TEXT NOT NULL UNIQUE,
+				fecha_emision TIMESTAMPTZ NOT NULL,
+				cliente_nombre TEXT NOT NULL,
+				cliente_cedula TEXT NOT NULL,
+				cliente_direccion TEXT,
+				cliente_telefono TEXT,
+				cliente_email TEXT,
+				subtotal DOUBLE PRECISION NOT NULL,
+				iva DOUBLE PRECISION NOT NULL,
+				total DOUBLE PRECISION NOT NULL,
+				estado TEXT NOT NULL DEFAULT 'BORRADOR',
+				numero_autorizacion TEXT,
+				fecha_autorizacion TIMESTAMPTZ,
+				xml_original TEXT,
+				xml_autorizado TEXT,
+				observaciones_sri TEXT,
+				ambiente TEXT NOT NULL DEFAULT 'PRUEBAS',
+				tipo_emision TEXT NOT NULL DEFAULT 'NORMAL',
+				fecha_creacion TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+				fecha_actualizacion TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+			)`,
+			`CREATE TABLE IF NOT EXISTS productos (
+				id SERIAL PRIMARY KEY,
+				factura_id INTEGER NOT NULL REFERENCES facturas (id) ON DELETE CASCADE,
+				codigo TEXT NOT NULL,
+				codigo_principal TEXT,
+				codigo_auxiliar TEXT,
+				descripcion TEXT NOT NULL,
+				unidad_medida TEXT DEFAULT 'UNI',
+				cantidad DOUBLE PRECISION NOT NULL,
+				precio_unitario DOUBLE PRECISION NOT NULL,
+				descuento DOUBLE PRECISION DEFAULT 0,
+				precio_total_sin_iva DOUBLE PRECISION NOT NULL,
+				precio_total DOUBLE PRECISION NOT NULL,
+				iva DOUBLE PRECISION NOT NULL
+			)`,
+			`CREATE TABLE IF NOT EXISTS clientes (
+				id SERIAL PRIMARY KEY,
+				cedula TEXT NOT NULL UNIQUE,
+				nombre TEXT NOT NULL,
+				direccion TEXT,
+				telefono TEXT,
+				email TEXT,
+				tipo_cliente TEXT NOT NULL DEFAULT 'PERSONA_NATURAL',
+				fecha_creacion TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+				activo BOOLEAN NOT NULL DEFAULT TRUE
+			)`,
+			`CREATE TABLE IF NOT EXISTS configuracion (
+				id SERIAL PRIMARY KEY,
+				clave TEXT NOT NULL UNIQUE,
+				valor TEXT NOT NULL,
+				tipo TEXT NOT NULL DEFAULT 'STRING',
+				activo BOOLEAN NOT NULL DEFAULT TRUE
+			)`,
+			`CREATE TABLE IF NOT EXISTS audit_log (
+				id SERIAL PRIMARY KEY,
+				tabla TEXT NOT NULL,
+				registro_id INTEGER NOT NULL,
+				operacion TEXT NOT NULL,
+				usuario TEXT NOT NULL DEFAULT 'sistema',
+				datos_antes TEXT,
+				datos_despues TEXT,
+				ip_address TEXT,
+				user_agent TEXT,
+				timestamp TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+			)`,
+			"CREATE INDEX IF NOT EXISTS idx_facturas_numero ON facturas(numero_factura)",
+			"CREATE INDEX IF NOT EXISTS idx_facturas_clave ON facturas(clave_acceso)",
+			"CREATE INDEX IF NOT EXISTS idx_facturas_cliente ON facturas(cliente_cedula)",
+			"CREATE INDEX IF NOT EXISTS idx_facturas_fecha ON facturas(fecha_emision)",
+			"CREATE INDEX IF NOT EXISTS idx_facturas_estado ON facturas(estado)",
+			"CREATE INDEX IF NOT EXISTS idx_productos_factura ON productos(factura_id)",
+			"CREATE INDEX IF NOT EXISTS idx_clientes_cedula ON clientes(cedula)",
+			"CREATE INDEX IF NOT EXISTS idx_audit_tabla ON audit_log(tabla)",
+			"CREATE INDEX IF NOT EXISTS idx_audit_registro ON audit_log(registro_id)",
+			"CREATE INDEX IF NOT EXISTS idx_audit_timestamp ON audit_log(timestamp)",
+			"CREATE INDEX IF NOT EXISTS idx_audit_usuario ON audit_log(usuario)",
+		),
+		Bajar: sentencias(
+			"DROP TABLE IF EXISTS audit_log",
+			"DROP TABLE IF EXISTS configuracion",
+			"DROP TABLE IF EXISTS clientes",
+			"DROP TABLE IF EXISTS productos",
+			"DROP TABLE IF EXISTS facturas",
+		),
+	},
+	{
+		Version:     2,
+		Descripcion: "cola persistente de trabajos de autorización SRI",
+		Subir: sentencias(
+			`CREATE TABLE IF NOT EXISTS trabajos_autorizacion (
+				id SERIAL PRIMARY KEY,
+				factura_id INTEGER NOT NULL REFERENCES facturas (id) ON DELETE CASCADE,
+				clave_acceso TEXT,
+				etapa TEXT NOT NULL DEFAULT 'FIRMAR',
+				estado TEXT NOT NULL DEFAULT 'PENDIENTE',
+				intentos INTEGER NOT NULL DEFAULT 0,
+				proximo_intento TIMESTAMPTZ NOT NULL,
+				xml_firmado TEXT,
+				ultimo_error TEXT,
+				worker TEXT,
+				fecha_creacion TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+				fecha_actualizacion TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+			)`,
+			"CREATE INDEX IF NOT EXISTS idx_trabajos_estado ON trabajos_autorizacion(estado, proximo_intento)",
+			"CREATE INDEX IF NOT EXISTS idx_trabajos_factura ON trabajos_autorizacion(factura_id)",
+		),
+		Bajar: sentencias("DROP TABLE IF EXISTS trabajos_autorizacion"),
+	},
+	{
+		Version:     3,
+		Descripcion: "historial de transiciones de estado de facturas",
+		Subir: sentencias(
+			`CREATE TABLE IF NOT EXISTS historial_estados_factura (
+				id SERIAL PRIMARY KEY,
+				factura_id INTEGER NOT NULL REFERENCES facturas (id) ON DELETE CASCADE,
+				estado_anterior TEXT NOT NULL,
+				estado_nuevo TEXT NOT NULL,
+				actor TEXT NOT NULL,
+				mensajes_sri TEXT,
+				observaciones TEXT,
+				fecha TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+			)`,
+			"CREATE INDEX IF NOT EXISTS idx_historial_estados_factura ON historial_estados_factura(factura_id)",
+		),
+		Bajar: sentencias("DROP TABLE IF EXISTS historial_estados_factura"),
+	},
+	{
+		Version:     4,
+		Descripcion: "archivo de envelopes SOAP intercambiados con el SRI",
+		Subir: sentencias(
+			`CREATE TABLE IF NOT EXISTS intercambios_soap (
+				id BIGSERIAL PRIMARY KEY,
+				operacion TEXT NOT NULL,
+				clave_acceso TEXT,
+				ambiente TEXT,
+				endpoint TEXT,
+				codigo_http INTEGER,
+				duracion_ms BIGINT,
+				error TEXT,
+				comprimido BOOLEAN DEFAULT FALSE,
+				solicitud BYTEA,
+				respuesta BYTEA,
+				fecha TIMESTAMPTZ NOT NULL
+			)`,
+			"CREATE INDEX IF NOT EXISTS idx_intercambios_soap_clave ON intercambios_soap(clave_acceso)",
+			"CREATE INDEX IF NOT EXISTS idx_intercambios_soap_fecha ON intercambios_soap(fecha)",
+		),
+		Bajar: sentencias("DROP TABLE IF EXISTS intercambios_soap"),
+	},
+	{
+		Version:     5,
+		Descripcion: "cola de fallidos y errores clasificados por intento",
+		Subir: sentencias(
+			"ALTER TABLE trabajos_autorizacion ADD COLUMN IF NOT EXISTS errores_intentos TEXT",
+			`CREATE TABLE IF NOT EXISTS cola_fallidos (
+				id SERIAL PRIMARY KEY,
+				factura_id INTEGER,
+				trabajo_id INTEGER,
+				clave_acceso TEXT,
+				origen TEXT NOT NULL,
+				etapa TEXT NOT NULL,
+				xml_comprobante TEXT,
+				intentos TEXT,
+				ultimo_error TEXT,
+				recuperable BOOLEAN NOT NULL DEFAULT FALSE,
+				estado TEXT NOT NULL DEFAULT 'PENDIENTE',
+				reencolados INTEGER NOT NULL DEFAULT 0,
+				motivo_descarte TEXT,
+				fecha_creacion TIMESTAMPTZ NOT NULL,
+				fecha_actualizacion TIMESTAMPTZ NOT NULL
+			)`,
+			"CREATE INDEX IF NOT EXISTS idx_cola_fallidos_estado ON cola_fallidos(estado)",
+			"CREATE INDEX IF NOT EXISTS idx_cola_fallidos_factura ON cola_fallidos(factura_id)",
+		),
+		Bajar: sentencias(
+			"DROP TABLE IF EXISTS cola_fallidos",
+			"ALTER TABLE trabajos_autorizacion DROP COLUMN IF EXISTS errores_intentos",
+		),
+	},
+	{
+		Version:     6,
+		Descripcion: "catálogo maestro de productos y servicios",
+		Subir: sentencias(
+			`CREATE TABLE IF NOT EXISTS catalogo_productos (
+				id SERIAL PRIMARY KEY,
+				codigo_principal TEXT NOT NULL UNIQUE,
+				codigo_auxiliar TEXT,
+				descripcion TEXT NOT NULL,
+				unidad_medida TEXT NOT NULL DEFAULT 'UNI',
+				precio_unitario DOUBLE PRECISION NOT NULL,
+				codigo_iva TEXT NOT NULL DEFAULT '4',
+				codigo_ice TEXT,
+				tarifa_ice DOUBLE PRECISION NOT NULL DEFAULT 0,
+				activo BOOLEAN NOT NULL DEFAULT TRUE,
+				fecha_creacion TIMESTAMPTZ NOT NULL,
+				fecha_actualizacion TIMESTAMPTZ NOT NULL
+			)`,
+			"CREATE INDEX IF NOT EXISTS idx_catalogo_productos_auxiliar ON catalogo_productos(codigo_auxiliar)",
+		),
+		Bajar: sentencias("DROP TABLE IF EXISTS catalogo_productos"),
+	},
+	{
+		Version:     7,
+		Descripcion: "inventario por establecimiento y kardex de movimientos",
+		Subir: sentencias(
+			"ALTER TABLE catalogo_productos ADD COLUMN IF NOT EXISTS controla_inventario BOOLEAN NOT NULL DEFAULT FALSE",
+			`CREATE TABLE IF NOT EXISTS stock_productos (
+				producto_id INTEGER NOT NULL REFERENCES catalogo_productos (id),
+				establecimiento TEXT NOT NULL,
+				cantidad DOUBLE PRECISION NOT NULL DEFAULT 0,
+				stock_minimo DOUBLE PRECISION NOT NULL DEFAULT 0,
+				fecha_actualizacion TIMESTAMPTZ NOT NULL,
+				PRIMARY KEY (producto_id, establecimiento)
+			)`,
+			`CREATE TABLE IF NOT EXISTS movimientos_inventario (
+				id SERIAL PRIMARY KEY,
+				producto_id INTEGER NOT NULL REFERENCES catalogo_productos (id),
+				establecimiento TEXT NOT NULL,
+				tipo TEXT NOT NULL,
+				cantidad DOUBLE PRECISION NOT NULL,
+				saldo DOUBLE PRECISION NOT NULL,
+				factura_id INTEGER,
+				documento TEXT,
+				motivo TEXT,
+				usuario TEXT NOT NULL,
+				fecha TIMESTAMPTZ NOT NULL
+			)`,
+			"CREATE INDEX IF NOT EXISTS idx_movimientos_inventario_producto ON movimientos_inventario(producto_id, establecimiento)",
+			"CREATE INDEX IF NOT EXISTS idx_movimientos_inventario_factura ON movimientos_inventario(factura_id)",
+			"CREATE INDEX IF NOT EXISTS idx_movimientos_inventario_documento ON movimientos_inventario(documento)",
+		),
+		Bajar: sentencias(
+			"DROP TABLE IF EXISTS movimientos_inventario",
+			"DROP TABLE IF EXISTS stock_productos",
+			"ALTER TABLE catalogo_productos DROP COLUMN IF EXISTS controla_inventario",
+		),
+	},
+	{
+		Version:     8,
+		Descripcion: "índices de texto completo de clientes y productos",
+		// Las expresiones deben coincidir con las de Database.coincidencia para que se usen
+		Subir: sentencias(
+			`CREATE INDEX IF NOT EXISTS idx_clientes_texto ON clientes USING GIN (to_tsvector('simple',
+				COALESCE(nombre, '') || ' ' || COALESCE(cedula, '') || ' ' || COALESCE(email, '') || ' ' || COALESCE(direccion, '')))`,
+			`CREATE INDEX IF NOT EXISTS idx_productos_texto ON productos USING GIN (to_tsvector('simple',
+				COALESCE(descripcion, '') || ' ' || COALESCE(codigo, '')))`,
+		),
+		Bajar: sentencias(
+			"DROP INDEX IF EXISTS idx_clientes_texto",
+			"DROP INDEX IF EXISTS idx_productos_texto",
+		),
+	},
+	{
+		Version:     9,
+		Descripcion: "solicitudes de anulación de facturas autorizadas",
+		Subir: sentencias(
+			`CREATE TABLE IF NOT EXISTS anulaciones_factura (
+				id SERIAL PRIMARY KEY,
+				factura_id INTEGER NOT NULL REFERENCES facturas (id),
+				motivo TEXT NOT NULL,
+				estado TEXT NOT NULL,
+				referencia_sri TEXT,
+				observaciones TEXT,
+				solicitado_por TEXT NOT NULL,
+				fecha_solicitud TIMESTAMPTZ NOT NULL,
+				resuelto_por TEXT,
+				fecha_resolucion TIMESTAMPTZ
+			)`,
+			"CREATE INDEX IF NOT EXISTS idx_anulaciones_factura ON anulaciones_factura(factura_id)",
+			// Una sola solicitud pendiente por factura
+			"CREATE UNIQUE INDEX IF NOT EXISTS idx_anulaciones_pendiente ON anulaciones_factura(factura_id) WHERE estado = 'SOLICITADA'",
+		),
+		Bajar: sentencias("DROP TABLE IF EXISTS anulaciones_factura"),
+	},
+	{
+		Version:     10,
+		Descripcion: "cadena de hashes del log de auditoría",
+		Subir: func(tx *transaccion) error {
+			err := sentencias(
+				"ALTER TABLE audit_log ADD COLUMN hash TEXT",
+				"ALTER TABLE audit_log ADD COLUMN hash_anterior TEXT",
+			)(tx)
+			if err != nil {
+				return err
+			}
+			return sellarAuditoriaExistente(tx)
+		},
+		Bajar: sentencias(
+			"ALTER TABLE audit_log DROP COLUMN hash_anterior",
+			"ALTER TABLE audit_log DROP COLUMN hash",
+		),
+	},
+	{
+		Version:     11,
+		Descripcion: "request ID de la petición que originó cada entrada de auditoría",
+		Subir: sentencias(
+			"ALTER TABLE audit_log ADD COLUMN request_id TEXT",
+			"CREATE INDEX IF NOT EXISTS idx_audit_request ON audit_log(request_id)",
+		),
+		Bajar: sentencias(
+			"DROP INDEX IF EXISTS idx_audit_request",
+			"ALTER TABLE audit_log DROP COLUMN request_id",
+		),
+	},
+	{
+		Version:     12,
+		Descripcion: "tenants: emisores con sus establecimientos y credenciales; datos aislados por tenant_id",
+		Subir: sentencias(
+			`CREATE TABLE IF NOT EXISTS tenants (
+				id SERIAL PRIMARY KEY,
+				ruc TEXT NOT NULL UNIQUE,
+				razon_social TEXT NOT NULL,
+				nombre_comercial TEXT,
+				direccion_matriz TEXT NOT NULL,
+				obligado_contabilidad BOOLEAN NOT NULL DEFAULT FALSE,
+				contribuyente_especial TEXT,
+				regimen TEXT NOT NULL DEFAULT 'GENERAL',
+				ambiente TEXT NOT NULL DEFAULT '1',
+				tipo_emision TEXT NOT NULL DEFAULT '1',
+				certificado_ruta TEXT,
+				certificado_password TEXT,
+				activo BOOLEAN NOT NULL DEFAULT TRUE,
+				fecha_creacion TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+			)`,
+			`CREATE TABLE IF NOT EXISTS establecimientos_tenant (
+				id SERIAL PRIMARY KEY,
+				tenant_id INTEGER NOT NULL REFERENCES tenants (id),
+				codigo TEXT NOT NULL,
+				punto_emision TEXT NOT NULL,
+				direccion TEXT NOT NULL,
+				secuencial INTEGER NOT NULL DEFAULT 0,
+				activo BOOLEAN NOT NULL DEFAULT TRUE,
+				UNIQUE (tenant_id, codigo, punto_emision)
+			)`,
+			`CREATE TABLE IF NOT EXISTS credenciales_tenant (
+				id SERIAL PRIMARY KEY,
+				tenant_id INTEGER NOT NULL REFERENCES tenants (id),
+				nombre TEXT NOT NULL,
+				prefijo TEXT NOT NULL,
+				hash_clave TEXT NOT NULL UNIQUE,
+				activa BOOLEAN NOT NULL DEFAULT TRUE,
+				fecha_creacion TIMESTAMPTZ NOT NULL,
+				fecha_revocacion TIMESTAMPTZ
+			)`,
+			"CREATE INDEX IF NOT EXISTS idx_credenciales_tenant ON credenciales_tenant(tenant_id)",
+			// tenant_id 0 es el emisor de config.Config, sin fila en tenants
+			"ALTER TABLE facturas ADD COLUMN tenant_id INTEGER NOT NULL DEFAULT 0",
+			"ALTER TABLE clientes ADD COLUMN tenant_id INTEGER NOT NULL DEFAULT 0",
+			"ALTER TABLE audit_log ADD COLUMN tenant_id INTEGER NOT NULL DEFAULT 0",
+			// Número de factura y cédula pasan a ser únicos por tenant
+			"ALTER TABLE facturas DROP CONSTRAINT IF EXISTS facturas_numero_factura_key",
+			"ALTER TABLE clientes DROP CONSTRAINT IF EXISTS clientes_cedula_key",
+			"CREATE UNIQUE INDEX IF NOT EXISTS idx_facturas_tenant_numero ON facturas(tenant_id, numero_factura)",
+			"CREATE UNIQUE INDEX IF NOT EXISTS idx_clientes_tenant_cedula ON clientes(tenant_id, cedula)",
+			"CREATE INDEX IF NOT EXISTS idx_audit_tenant ON audit_log(tenant_id)",
+		),
+		Bajar: sentencias(
+			// Falla si dos tenants comparten un número de factura o una cédula
+			"DROP INDEX IF EXISTS idx_facturas_tenant_numero",
+			"DROP INDEX IF EXISTS idx_clientes_tenant_cedula",
+			"DROP INDEX IF EXISTS idx_audit_tenant",
+			"ALTER TABLE facturas ADD CONSTRAINT facturas_numero_factura_key UNIQUE (numero_factura)",
+			"ALTER TABLE clientes ADD CONSTRAINT clientes_cedula_key UNIQUE (cedula)",
+			"ALTER TABLE audit_log DROP COLUMN tenant_id",
+			"ALTER TABLE clientes DROP COLUMN tenant_id",
+			"ALTER TABLE facturas DROP COLUMN tenant_id",
+			"DROP TABLE IF EXISTS credenciales_tenant",
+			"DROP TABLE IF EXISTS establecimientos_tenant",
+			"DROP TABLE IF EXISTS tenants",
+		),
+	},
+	{
+		Version:     13,
+		Descripcion: "catálogo e inventario aislados por tenant_id",
+		Subir: sentencias(
+			"ALTER TABLE catalogo_productos ADD COLUMN tenant_id INTEGER NOT NULL DEFAULT 0",
+			"ALTER TABLE stock_productos ADD COLUMN tenant_id INTEGER NOT NULL DEFAULT 0",
+			"ALTER TABLE movimientos_inventario ADD COLUMN tenant_id INTEGER NOT NULL DEFAULT 0",
+			// Código principal y existencias pasan a ser únicos por tenant
+			"ALTER TABLE catalogo_productos DROP CONSTRAINT IF EXISTS catalogo_productos_codigo_principal_key",
+			"CREATE UNIQUE INDEX IF NOT EXISTS idx_catalogo_tenant_codigo ON catalogo_productos(tenant_id, codigo_principal)",
+			"ALTER TABLE stock_productos DROP CONSTRAINT IF EXISTS stock_productos_pkey",
+			"ALTER TABLE stock_productos ADD PRIMARY KEY (tenant_id, producto_id, establecimiento)",
+			"CREATE INDEX IF NOT EXISTS idx_movimientos_inventario_tenant ON movimientos_inventario(tenant_id, producto_id)",
+		),
+		Bajar: sentencias(
+			// Falla si dos tenants comparten un código principal
+			"DROP INDEX IF EXISTS idx_catalogo_tenant_codigo",
+			"DROP INDEX IF EXISTS idx_movimientos_inventario_tenant",
+			"ALTER TABLE catalogo_productos ADD CONSTRAINT catalogo_productos_codigo_principal_key UNIQUE (codigo_principal)",
+			"ALTER TABLE stock_productos DROP CONSTRAINT IF EXISTS stock_productos_pkey",
+			"ALTER TABLE stock_productos ADD PRIMARY KEY (producto_id, establecimiento)",
+			"ALTER TABLE movimientos_inventario DROP COLUMN tenant_id",
+			"ALTER TABLE stock_productos DROP COLUMN tenant_id",
+			"ALTER TABLE catalogo_productos DROP COLUMN tenant_id",
+		),
+	},
+	{
+		Version:     14,
+		Descripcion: "secuenciales del emisor de la configuración por establecimiento y punto de emisión",
+		Subir: sentencias(
+			`CREATE TABLE IF NOT EXISTS secuenciales_emisor (
+				ruc TEXT NOT NULL,
+				establecimiento TEXT NOT NULL,
+				punto_emision TEXT NOT NULL,
+				secuencial INTEGER NOT NULL,
+				PRIMARY KEY (ruc, establecimiento, punto_emision)
+			)`,
+		),
+		Bajar: sentencias("DROP TABLE IF EXISTS secuenciales_emisor"),
+	},
+	{
+		Version:     15,
+		Descripcion: "recepción devuelta por clave registrada o en procesamiento (43, 70)",
+		Subir:       sentencias("ALTER TABLE trabajos_autorizacion ADD COLUMN recepcion_duplicada BOOLEAN NOT NULL DEFAULT FALSE"),
+		Bajar:       sentencias("ALTER TABLE trabajos_autorizacion DROP COLUMN recepcion_duplicada"),
+	},
+}
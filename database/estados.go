@@ -0,0 +1,246 @@
+// Package database implementa la máquina de estados de facturas y su historial
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Estados del ciclo de vida de una factura electrónica
+const (
+	EstadoBorrador     = "BORRADOR"
+	EstadoFirmada      = "FIRMADA"
+	EstadoEnviada      = "ENVIADA"
+	EstadoRecibida     = "RECIBIDA"
+	EstadoEnProceso    = "EN_PROCESO"
+	EstadoAutorizada   = "AUTORIZADA"
+	EstadoNoAutorizada = "NO_AUTORIZADA"
+	EstadoDevuelta     = "DEVUELTA"
+	EstadoAnulada      = "ANULADA"
+
+	// EstadoRechazada estado de versiones anteriores, equivalente a DEVUELTA
+	EstadoRechazada = "RECHAZADA"
+)
+
+// ActorSistema actor registrado cuando el cambio no indica quién lo realizó
+const ActorSistema = "sistema"
+
+// ErrTransicionInvalida se retorna cuando el cambio de estado no está permitido
+var ErrTransicionInvalida = errors.New("transición de estado no permitida")
+
+// transicionesFactura estados destino permitidos desde cada estado
+var transicionesFactura = map[string][]string{
+	EstadoBorrador:     {EstadoFirmada, EstadoAnulada},
+	EstadoFirmada:      {EstadoEnviada, EstadoBorrador, EstadoAnulada},
+	EstadoEnviada:      {EstadoRecibida, EstadoDevuelta},
+	EstadoRecibida:     {EstadoEnProceso, EstadoAutorizada, EstadoNoAutorizada},
+	EstadoEnProceso:    {EstadoAutorizada, EstadoNoAutorizada},
+	EstadoDevuelta:     {EstadoBorrador, EstadoFirmada, EstadoAnulada},
+	EstadoNoAutorizada: {EstadoBorrador, EstadoAnulada},
+	EstadoAutorizada:   {EstadoAnulada},
+	EstadoAnulada:      {},
+	EstadoRechazada:    {EstadoBorrador, EstadoFirmada, EstadoAnulada},
+}
+
+// estadosManuales estados que un usuario puede fijar desde la API. Los demás (firma, envío y
+// respuestas del SRI) los asigna el pipeline de autorización.
+var estadosManuales = map[string]bool{
+	EstadoBorrador: true,
+	EstadoAnulada:  true,
+}
+
+// MensajeEstadoDB mensaje del SRI asociado a un cambio de estado
+type MensajeEstadoDB struct {
+	Identificador        string `json:"identificador"`
+	Mensaje              string `json:"mensaje"`
+	InformacionAdicional string `json:"informacionAdicional,omitempty"`
+	Tipo                 string `json:"tipo,omitempty"`
+}
+
+// CambioEstadoFactura datos de una transición de estado
+type CambioEstadoFactura struct {
+	Estado             string
+	NumeroAutorizacion string
+	XMLAutorizado      string
+	FechaAutorizacion  *time.Time // fechaAutorizacion del SRI; sin ella se usa la hora local
+	Observaciones      string
+	Actor              string            // Usuario o proceso que realiza el cambio
+	MensajesSRI        []MensajeEstadoDB // Mensajes del SRI que motivaron el cambio
+}
+
+// HistorialEstadoDB registro de una transición de estado de factura
+type HistorialEstadoDB struct {
+	ID             int               `json:"id"`
+	FacturaID      int               `json:"facturaId"`
+	EstadoAnterior string            `json:"estadoAnterior"`
+	EstadoNuevo    string            `json:"estadoNuevo"`
+	Actor          string            `json:"actor"`
+	MensajesSRI    []MensajeEstadoDB `json:"mensajesSRI"`
+	Observaciones  string            `json:"observaciones"`
+	Fecha          time.Time         `json:"fecha"`
+}
+
+// EsEstadoFactura indica si el estado pertenece a la máquina de estados
+func EsEstadoFactura(estado string) bool {
+	_, ok := transicionesFactura[estado]
+	return ok
+}
+
+// TransicionPermitida indica si una factura puede pasar de un estado a otro
+func TransicionPermitida(desde, hacia string) bool {
+	for _, destino := range transicionesFactura[desde] {
+		if destino == hacia {
+			return true
+		}
+	}
+	return false
+}
+
+// EsEstadoManual indica si un usuario puede fijar el estado sin pasar por el pipeline
+func EsEstadoManual(estado string) bool {
+	return estadosManuales[estado]
+}
+
+// TransicionesDesde retorna los estados alcanzables desde el estado indicado
+func TransicionesDesde(estado string) []string {
+	return append([]string(nil), transicionesFactura[estado]...)
+}
+
+// TransicionarEstadoFactura valida y aplica un cambio de estado registrándolo en el historial.
+// Repetir el estado actual no es un error y no genera registro. Una factura autorizada solo
+// se anula confirmando una solicitud de anulación (ConfirmarAnulacion).
+func (d *Database) TransicionarEstadoFactura(ctx context.Context, id int, cambio CambioEstadoFactura) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error iniciando transacción: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := transicionarEstadoFactura(ctx, tx, id, cambio, false); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error confirmando transacción: %v", err)
+	}
+	return nil
+}
+
+// transicionarEstadoFactura aplica el cambio de estado dentro de la transacción indicada;
+// anulacionConfirmada habilita el paso de AUTORIZADA a ANULADA. Las facturas de otro tenant
+// no se encuentran.
+func transicionarEstadoFactura(ctx context.Context, tx *transaccion, id int, cambio CambioEstadoFactura, anulacionConfirmada bool) error {
+	if !EsEstadoFactura(cambio.Estado) || cambio.Estado == EstadoRechazada {
+		return fmt.Errorf("estado de factura desconocido: %s", cambio.Estado)
+	}
+	if cambio.Actor == "" {
+		cambio.Actor = ActorSistema
+	}
+
+	var estadoActual string
+	filtro, args := filtroTenant(ctx, "tenant_id")
+	err := tx.QueryRow("SELECT estado FROM facturas WHERE id = ?"+filtro+tx.paraActualizar(),
+		append([]interface{}{id}, args...)...).Scan(&estadoActual)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("factura con ID %d no encontrada", id)
+	}
+	if err != nil {
+		return fmt.Errorf("error obteniendo estado de factura: %v", err)
+	}
+
+	if estadoActual == cambio.Estado {
+		return nil
+	}
+	if !TransicionPermitida(estadoActual, cambio.Estado) {
+		return fmt.Errorf("%w: %s → %s", ErrTransicionInvalida, estadoActual, cambio.Estado)
+	}
+	if estadoActual == EstadoAutorizada && cambio.Estado == EstadoAnulada && !anulacionConfirmada {
+		return fmt.Errorf("%w: una factura autorizada requiere una solicitud de anulación confirmada", ErrTransicionInvalida)
+	}
+
+	// Los datos de autorización se conservan si el cambio no los reemplaza (p.ej. al anular)
+	var fechaAutorizacion *time.Time
+	if cambio.Estado == EstadoAutorizada {
+		fechaAutorizacion = cambio.FechaAutorizacion
+		if fechaAutorizacion == nil {
+			now := time.Now()
+			fechaAutorizacion = &now
+		}
+	}
+
+	_, err = tx.Exec(`
+		UPDATE facturas
+		SET estado = ?,
+		    numero_autorizacion = COALESCE(NULLIF(?, ''), numero_autorizacion),
+		    fecha_autorizacion = COALESCE(?, fecha_autorizacion),
+		    xml_autorizado = COALESCE(NULLIF(?, ''), xml_autorizado),
+		    observaciones_sri = ?, fecha_actualizacion = CURRENT_TIMESTAMP
+		WHERE id = ?`,
+		cambio.Estado, cambio.NumeroAutorizacion, fechaAutorizacion, cambio.XMLAutorizado, cambio.Observaciones, id)
+	if err != nil {
+		return fmt.Errorf("error actualizando estado de factura: %v", err)
+	}
+
+	// La salida de inventario se registra en la misma transacción que la autorización
+	if cambio.Estado == EstadoAutorizada {
+		if err := descontarInventarioFactura(tx, id, cambio.Actor); err != nil {
+			return err
+		}
+	}
+
+	mensajes, err := json.Marshal(cambio.MensajesSRI)
+	if err != nil {
+		return fmt.Errorf("error serializando mensajes SRI: %v", err)
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO historial_estados_factura (
+			factura_id, estado_anterior, estado_nuevo, actor, mensajes_sri, observaciones, fecha
+		) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		id, estadoActual, cambio.Estado, cambio.Actor, string(mensajes), cambio.Observaciones, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("error registrando historial de estado: %v", err)
+	}
+	return nil
+}
+
+// ObtenerHistorialEstados retorna las transiciones de una factura en orden cronológico
+func (d *Database) ObtenerHistorialEstados(ctx context.Context, facturaID int) ([]*HistorialEstadoDB, error) {
+	filtro, args := filtroFacturaTenant(ctx, "factura_id")
+	rows, err := d.db.Query(`
+		SELECT id, factura_id, estado_anterior, estado_nuevo, actor, mensajes_sri, observaciones, fecha
+		FROM historial_estados_factura
+		WHERE factura_id = ?`+filtro+`
+		ORDER BY id`, append([]interface{}{facturaID}, args...)...)
+	if err != nil {
+		return nil, fmt.Errorf("error consultando historial de estados: %v", err)
+	}
+	defer rows.Close()
+
+	var historial []*HistorialEstadoDB
+	for rows.Next() {
+		registro := &HistorialEstadoDB{}
+		var mensajes, observaciones sql.NullString
+
+		err := rows.Scan(&registro.ID, &registro.FacturaID, &registro.EstadoAnterior, &registro.EstadoNuevo,
+			&registro.Actor, &mensajes, &observaciones, &registro.Fecha)
+		if err != nil {
+			return nil, fmt.Errorf("error escaneando historial de estados: %v", err)
+		}
+
+		if mensajes.String != "" {
+			if err := json.Unmarshal([]byte(mensajes.String), &registro.MensajesSRI); err != nil {
+				return nil, fmt.Errorf("error leyendo mensajes SRI del historial: %v", err)
+			}
+		}
+		registro.Observaciones = observaciones.String
+
+		historial = append(historial, registro)
+	}
+
+	return historial, rows.Err()
+}
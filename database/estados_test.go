@@ -0,0 +1,165 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go-facturacion-sri/factory"
+	"go-facturacion-sri/models"
+)
+
+// facturaEstadosPrueba guarda una factura en BORRADOR para probar transiciones
+func facturaEstadosPrueba(t *testing.T, db *Database, clave string) int {
+	t.Helper()
+	setupTestConfig()
+
+	productos := []models.ProductoInput{
+		{Codigo: "EST001", Descripcion: "Producto estados", Cantidad: 1, PrecioUnitario: 10},
+	}
+	factura, err := factory.CrearFactura(models.FacturaInput{
+		ClienteNombre: "CLIENTE ESTADOS",
+		ClienteCedula: "1713175071",
+		Productos:     productos,
+	})
+	if err != nil {
+		t.Fatalf("CrearFactura() error: %v", err)
+	}
+
+	facturaDB, err := db.GuardarFactura(context.Background(), factura, clave, productos)
+	if err != nil {
+		t.Fatalf("GuardarFactura() error: %v", err)
+	}
+	return facturaDB.ID
+}
+
+// recorrerHastaRecibida lleva una factura en BORRADOR hasta RECIBIDA
+func recorrerHastaRecibida(t *testing.T, db *Database, id int) {
+	t.Helper()
+
+	for _, estado := range []string{EstadoFirmada, EstadoEnviada, EstadoRecibida} {
+		if err := db.ActualizarEstadoFactura(context.Background(), id, estado, "", "", ""); err != nil {
+			t.Fatalf("Error pasando factura %d a %s: %v", id, estado, err)
+		}
+	}
+}
+
+func TestTransicionPermitida(t *testing.T) {
+	tests := []struct {
+		desde, hacia string
+		permitida    bool
+	}{
+		{EstadoBorrador, EstadoFirmada, true},
+		{EstadoBorrador, EstadoAutorizada, false},
+		{EstadoFirmada, EstadoEnviada, true},
+		{EstadoEnviada, EstadoDevuelta, true},
+		{EstadoRecibida, EstadoEnProceso, true},
+		{EstadoEnProceso, EstadoNoAutorizada, true},
+		{EstadoEnviada, EstadoAnulada, false},
+		{EstadoAutorizada, EstadoAnulada, true},
+		{EstadoAutorizada, EstadoBorrador, false},
+		{EstadoAnulada, EstadoBorrador, false},
+		{EstadoRechazada, EstadoBorrador, true},
+	}
+
+	for _, tt := range tests {
+		if got := TransicionPermitida(tt.desde, tt.hacia); got != tt.permitida {
+			t.Errorf("TransicionPermitida(%s, %s) = %v, esperado %v", tt.desde, tt.hacia, got, tt.permitida)
+		}
+	}
+}
+
+func TestTransicionarEstadoFacturaRegistraHistorial(t *testing.T) {
+	db := nuevaDBTrabajosPrueba(t)
+	id := facturaEstadosPrueba(t, db, "1111111111111111111111111111111111111111111111111")
+
+	recorrerHastaRecibida(t, db, id)
+
+	fechaSRI := time.Date(2026, 3, 2, 10, 15, 0, 0, time.UTC)
+	err := db.TransicionarEstadoFactura(context.Background(), id, CambioEstadoFactura{
+		Estado:             EstadoAutorizada,
+		NumeroAutorizacion: "AUT-1",
+		XMLAutorizado:      "<autorizacion/>",
+		FechaAutorizacion:  &fechaSRI,
+		Actor:              "pipeline",
+		MensajesSRI:        []MensajeEstadoDB{{Identificador: "60", Mensaje: "AMBIENTE PRUEBAS", Tipo: "INFORMATIVO"}},
+	})
+	if err != nil {
+		t.Fatalf("TransicionarEstadoFactura() error: %v", err)
+	}
+
+	// Repetir el estado actual es idempotente y no genera historial
+	if err := db.ActualizarEstadoFactura(context.Background(), id, EstadoAutorizada, "AUT-1", "", ""); err != nil {
+		t.Errorf("Repetir AUTORIZADA no debería fallar: %v", err)
+	}
+
+	historial, err := db.ObtenerHistorialEstados(context.Background(), id)
+	if err != nil {
+		t.Fatalf("ObtenerHistorialEstados() error: %v", err)
+	}
+	if len(historial) != 4 {
+		t.Fatalf("Historial con %d registros, esperados 4", len(historial))
+	}
+	if historial[0].EstadoAnterior != EstadoBorrador || historial[0].Actor != ActorSistema {
+		t.Errorf("Primer registro inesperado: %+v", historial[0])
+	}
+
+	ultimo := historial[3]
+	if ultimo.EstadoAnterior != EstadoRecibida || ultimo.EstadoNuevo != EstadoAutorizada || ultimo.Actor != "pipeline" {
+		t.Errorf("Último registro inesperado: %+v", ultimo)
+	}
+	if len(ultimo.MensajesSRI) != 1 || ultimo.MensajesSRI[0].Identificador != "60" {
+		t.Errorf("Mensajes SRI no registrados: %+v", ultimo.MensajesSRI)
+	}
+	if ultimo.Fecha.IsZero() {
+		t.Error("La transición debería registrar la fecha")
+	}
+
+	// Una factura autorizada no se anula directamente, solo confirmando una solicitud
+	if err := db.ActualizarEstadoFactura(context.Background(), id, EstadoAnulada, "", "", "Anulada"); !errors.Is(err, ErrTransicionInvalida) {
+		t.Fatalf("Anular sin solicitud = %v, esperado ErrTransicionInvalida", err)
+	}
+
+	// Anular conserva los datos de autorización
+	if _, err := db.SolicitarAnulacion(context.Background(), id, "Error en datos del comprador", "contador"); err != nil {
+		t.Fatalf("SolicitarAnulacion() error: %v", err)
+	}
+	if _, err := db.ConfirmarAnulacion(context.Background(), id, "ANU-2026-0001", "contador"); err != nil {
+		t.Fatalf("Error anulando factura: %v", err)
+	}
+	factura, err := db.ObtenerFacturaPorID(context.Background(), id)
+	if err != nil {
+		t.Fatalf("ObtenerFacturaPorID() error: %v", err)
+	}
+	if factura.NumeroAutorizacion != "AUT-1" || factura.FechaAutorizacion == nil {
+		t.Fatalf("Datos de autorización perdidos al anular: %+v", factura)
+	}
+	if !factura.FechaAutorizacion.Equal(fechaSRI) {
+		t.Errorf("FechaAutorizacion = %v, esperada la del SRI %v", factura.FechaAutorizacion, fechaSRI)
+	}
+}
+
+func TestTransicionarEstadoFacturaInvalida(t *testing.T) {
+	db := nuevaDBTrabajosPrueba(t)
+	id := facturaEstadosPrueba(t, db, "2222222222222222222222222222222222222222222222222")
+
+	err := db.ActualizarEstadoFactura(context.Background(), id, EstadoAutorizada, "AUT-2", "", "")
+	if !errors.Is(err, ErrTransicionInvalida) {
+		t.Fatalf("Se esperaba ErrTransicionInvalida, obtenido: %v", err)
+	}
+
+	if err := db.ActualizarEstadoFactura(context.Background(), id, "PERDIDA", "", "", ""); err == nil || errors.Is(err, ErrTransicionInvalida) {
+		t.Errorf("Un estado desconocido debería rechazarse como tal: %v", err)
+	}
+
+	factura, _ := db.ObtenerFacturaPorID(context.Background(), id)
+	if factura.Estado != EstadoBorrador || factura.NumeroAutorizacion != "" {
+		t.Errorf("La factura no debería cambiar: estado=%s numero=%s", factura.Estado, factura.NumeroAutorizacion)
+	}
+
+	historial, _ := db.ObtenerHistorialEstados(context.Background(), id)
+	if len(historial) != 0 {
+		t.Errorf("Una transición rechazada no debería registrarse: %+v", historial)
+	}
+}
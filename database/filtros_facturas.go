@@ -0,0 +1,193 @@
+// Package database implementa la búsqueda de facturas con filtros, orden y conteo total
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrFiltroFacturasInvalido se retorna cuando un criterio de búsqueda no es válido
+var ErrFiltroFacturasInvalido = errors.New("filtro de facturas inválido")
+
+// columnasOrdenFacturas campos por los que se puede ordenar (nombre JSON → columna)
+var columnasOrdenFacturas = map[string]string{
+	"id":                "id",
+	"numeroFactura":     "numero_factura",
+	"claveAcceso":       "clave_acceso",
+	"fechaEmision":      "fecha_emision",
+	"clienteNombre":     "cliente_nombre",
+	"clienteCedula":     "cliente_cedula",
+	"subtotal":          "subtotal",
+	"iva":               "iva",
+	"total":             "total",
+	"estado":            "estado",
+	"ambiente":          "ambiente",
+	"fechaAutorizacion": "fecha_autorizacion",
+	"fechaCreacion":     "fecha_creacion",
+}
+
+// FiltroFacturas criterios de búsqueda de facturas; los campos vacíos no filtran
+type FiltroFacturas struct {
+	Desde           time.Time // Fecha de emisión desde (inclusive)
+	Hasta           time.Time // Fecha de emisión hasta (exclusive)
+	Estados         []string
+	Ambiente        string
+	TotalMinimo     *float64
+	TotalMaximo     *float64
+	Establecimiento string // Tomado de la clave de acceso (posiciones 25-27)
+	PrefijoNumero   string
+	PrefijoClave    string
+	ClienteCedula   string
+	OrdenarPor      string // Nombre JSON del campo; por defecto fechaCreacion
+	Ascendente      bool
+	Limite          int
+	Offset          int
+}
+
+// prefijoLike escapa los comodines de LIKE y agrega % al final
+func prefijoLike(prefijo string) string {
+	reemplazo := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+	return reemplazo.Replace(prefijo) + "%"
+}
+
+// condiciones traduce el filtro a condiciones SQL y sus argumentos
+func (f FiltroFacturas) condiciones() ([]string, []interface{}, error) {
+	var condiciones []string
+	var args []interface{}
+
+	if !f.Desde.IsZero() {
+		condiciones = append(condiciones, "fecha_emision >= ?")
+		args = append(args, f.Desde.UTC())
+	}
+	if !f.Hasta.IsZero() {
+		condiciones = append(condiciones, "fecha_emision < ?")
+		args = append(args, f.Hasta.UTC())
+	}
+	if len(f.Estados) > 0 {
+		marcadores := make([]string, len(f.Estados))
+		for i, estado := range f.Estados {
+			if !EsEstadoFactura(estado) {
+				return nil, nil, fmt.Errorf("%w: estado desconocido %s", ErrFiltroFacturasInvalido, estado)
+			}
+			marcadores[i] = "?"
+			args = append(args, estado)
+		}
+		condiciones = append(condiciones, "estado IN ("+strings.Join(marcadores, ", ")+")")
+	}
+	if f.Ambiente != "" {
+		condiciones = append(condiciones, "ambiente = ?")
+		args = append(args, strings.ToUpper(f.Ambiente))
+	}
+	if f.TotalMinimo != nil && f.TotalMaximo != nil && *f.TotalMinimo > *f.TotalMaximo {
+		return nil, nil, fmt.Errorf("%w: el total mínimo es mayor que el máximo", ErrFiltroFacturasInvalido)
+	}
+	if f.TotalMinimo != nil {
+		condiciones = append(condiciones, "total >= ?")
+		args = append(args, *f.TotalMinimo)
+	}
+	if f.TotalMaximo != nil {
+		condiciones = append(condiciones, "total <= ?")
+		args = append(args, *f.TotalMaximo)
+	}
+	if f.Establecimiento != "" {
+		if !patronEstablecimiento.MatchString(f.Establecimiento) {
+			return nil, nil, fmt.Errorf("%w: establecimiento %q (debe tener 3 dígitos)", ErrFiltroFacturasInvalido, f.Establecimiento)
+		}
+		condiciones = append(condiciones, "SUBSTR(clave_acceso, 25, 3) = ?")
+		args = append(args, f.Establecimiento)
+	}
+	if f.PrefijoNumero != "" {
+		condiciones = append(condiciones, `numero_factura LIKE ? ESCAPE '\'`)
+		args = append(args, prefijoLike(f.PrefijoNumero))
+	}
+	if f.PrefijoClave != "" {
+		condiciones = append(condiciones, `clave_acceso LIKE ? ESCAPE '\'`)
+		args = append(args, prefijoLike(f.PrefijoClave))
+	}
+	if f.ClienteCedula != "" {
+		condiciones = append(condiciones, "cliente_cedula = ?")
+		args = append(args, f.ClienteCedula)
+	}
+	return condiciones, args, nil
+}
+
+// BuscarFacturas lista las facturas del tenant del contexto que cumplen el filtro junto con el
+// total de coincidencias (sin paginación), para que el cliente pueda paginar
+func (d *Database) BuscarFacturas(ctx context.Context, filtro FiltroFacturas) ([]*FacturaDB, int, error) {
+	condiciones, args, err := filtro.condiciones()
+	if err != nil {
+		return nil, 0, err
+	}
+	if tenantID, ok := TenantDe(ctx); ok {
+		condiciones = append(condiciones, "tenant_id = ?")
+		args = append(args, tenantID)
+	}
+
+	orden := "fecha_creacion"
+	if filtro.OrdenarPor != "" {
+		columna, ok := columnasOrdenFacturas[filtro.OrdenarPor]
+		if !ok {
+			return nil, 0, fmt.Errorf("%w: no se puede ordenar por %s", ErrFiltroFacturasInvalido, filtro.OrdenarPor)
+		}
+		orden = columna
+	}
+	direccion := " DESC"
+	if filtro.Ascendente {
+		direccion = " ASC"
+	}
+
+	limite := filtro.Limite
+	if limite <= 0 {
+		limite = 10
+	}
+
+	where := ""
+	if len(condiciones) > 0 {
+		where = " WHERE " + strings.Join(condiciones, " AND ")
+	}
+
+	var total int
+	if err := d.db.QueryRow("SELECT COUNT(*) FROM facturas"+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("error contando facturas: %v", err)
+	}
+
+	query := `
+		SELECT id, numero_factura, clave_acceso, fecha_emision, cliente_nombre, cliente_cedula,
+		       subtotal, iva, total, estado, numero_autorizacion, ambiente, tenant_id
+		FROM facturas` + where + `
+		ORDER BY ` + orden + direccion + `, id` + direccion + `
+		LIMIT ? OFFSET ?`
+
+	rows, err := d.db.Query(query, append(args, limite, filtro.Offset)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error listando facturas: %v", err)
+	}
+	defer rows.Close()
+
+	var facturas []*FacturaDB
+	for rows.Next() {
+		factura := &FacturaDB{}
+		var numeroAutorizacion sql.NullString
+
+		err := rows.Scan(
+			&factura.ID, &factura.NumeroFactura, &factura.ClaveAcceso, &factura.FechaEmision,
+			&factura.ClienteNombre, &factura.ClienteCedula, &factura.Subtotal, &factura.IVA,
+			&factura.Total, &factura.Estado, &numeroAutorizacion, &factura.Ambiente, &factura.TenantID,
+		)
+		if err != nil {
+			return nil, 0, fmt.Errorf("error escaneando factura: %v", err)
+		}
+		factura.NumeroAutorizacion = numeroAutorizacion.String
+
+		facturas = append(facturas, factura)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error listando facturas: %v", err)
+	}
+
+	return facturas, total, nil
+}
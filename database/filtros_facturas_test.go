@@ -0,0 +1,93 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go-facturacion-sri/factory"
+	"go-facturacion-sri/models"
+)
+
+// guardarFacturaFiltro guarda una factura en BORRADOR con el precio y clave indicados
+func guardarFacturaFiltro(t *testing.T, db *Database, clave string, precio float64) *FacturaDB {
+	t.Helper()
+	setupTestConfig()
+
+	productos := []models.ProductoInput{{Codigo: "FIL001", Descripcion: "Producto filtros", Cantidad: 1, PrecioUnitario: precio}}
+	factura, err := factory.CrearFactura(models.FacturaInput{
+		ClienteNombre: "CLIENTE FILTROS",
+		ClienteCedula: "1713175071",
+		Productos:     productos,
+	})
+	if err != nil {
+		t.Fatalf("CrearFactura() error: %v", err)
+	}
+	facturaDB, err := db.GuardarFactura(context.Background(), factura, clave, productos)
+	if err != nil {
+		t.Fatalf("GuardarFactura() error: %v", err)
+	}
+	return facturaDB
+}
+
+func TestBuscarFacturas(t *testing.T) {
+	db := nuevaDBTrabajosPrueba(t)
+
+	barata := guardarFacturaFiltro(t, db, clavePrueba("01", "001", "000000001"), 10)
+	media := guardarFacturaFiltro(t, db, clavePrueba("01", "002", "000000002"), 50)
+	cara := guardarFacturaFiltro(t, db, clavePrueba("01", "002", "000000003"), 100)
+	recorrerHastaRecibida(t, db, media.ID)
+
+	minimo, maximo := 20.0, 200.0
+	casos := []struct {
+		nombre   string
+		filtro   FiltroFacturas
+		esperado []int
+	}{
+		{"sin filtro, más recientes primero", FiltroFacturas{}, []int{cara.ID, media.ID, barata.ID}},
+		{"estado", FiltroFacturas{Estados: []string{EstadoRecibida}}, []int{media.ID}},
+		{"varios estados", FiltroFacturas{Estados: []string{EstadoRecibida, EstadoBorrador}, OrdenarPor: "id", Ascendente: true}, []int{barata.ID, media.ID, cara.ID}},
+		{"rango de total", FiltroFacturas{TotalMinimo: &minimo, TotalMaximo: &maximo, OrdenarPor: "total", Ascendente: true}, []int{media.ID, cara.ID}},
+		{"establecimiento", FiltroFacturas{Establecimiento: "001"}, []int{barata.ID}},
+		{"prefijo de número", FiltroFacturas{PrefijoNumero: media.NumeroFactura}, []int{media.ID}},
+		{"prefijo de clave", FiltroFacturas{PrefijoClave: cara.ClaveAcceso[:30], OrdenarPor: "total"}, []int{cara.ID, media.ID}},
+		{"ambiente", FiltroFacturas{Ambiente: "produccion"}, nil},
+		{"rango de fechas", FiltroFacturas{Desde: time.Now().Add(-time.Hour), Hasta: time.Now().Add(time.Hour), OrdenarPor: "id", Ascendente: true}, []int{barata.ID, media.ID, cara.ID}},
+		{"fechas futuras", FiltroFacturas{Desde: time.Now().Add(time.Hour)}, nil},
+	}
+
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			facturas, total, err := db.BuscarFacturas(context.Background(), caso.filtro)
+			if err != nil {
+				t.Fatalf("BuscarFacturas() error: %v", err)
+			}
+			if total != len(caso.esperado) || len(facturas) != len(caso.esperado) {
+				t.Fatalf("BuscarFacturas() = %d facturas (total %d), esperadas %d", len(facturas), total, len(caso.esperado))
+			}
+			for i, factura := range facturas {
+				if factura.ID != caso.esperado[i] {
+					t.Errorf("Posición %d: factura %d, esperada %d", i, factura.ID, caso.esperado[i])
+				}
+			}
+		})
+	}
+
+	// El total cuenta todas las coincidencias aunque se pagine
+	facturas, total, err := db.BuscarFacturas(context.Background(), FiltroFacturas{Limite: 1, Offset: 1})
+	if err != nil || total != 3 || len(facturas) != 1 || facturas[0].ID != media.ID {
+		t.Errorf("Paginación: %d facturas, total %d, %v", len(facturas), total, err)
+	}
+
+	for _, filtro := range []FiltroFacturas{
+		{OrdenarPor: "xml_original; DROP TABLE facturas"},
+		{Estados: []string{"PAGADA"}},
+		{Establecimiento: "1"},
+		{TotalMinimo: &maximo, TotalMaximo: &minimo},
+	} {
+		if _, _, err := db.BuscarFacturas(context.Background(), filtro); !errors.Is(err, ErrFiltroFacturasInvalido) {
+			t.Errorf("BuscarFacturas(%+v) = %v, esperado ErrFiltroFacturasInvalido", filtro, err)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+// Package database implementa el archivo de envelopes SOAP intercambiados con el SRI
+package database
+
+import (
+	"bytes"
+	"compress/gzip"
+	"database/sql"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"go-facturacion-sri/sri"
+)
+
+// IntercambioSOAPDB petición y respuesta SOAP archivadas
+type IntercambioSOAPDB struct {
+	ID          int       `json:"id"`
+	Operacion   string    `json:"operacion"`
+	ClaveAcceso string    `json:"claveAcceso"`
+	Ambiente    string    `json:"ambiente"`
+	Endpoint    string    `json:"endpoint"`
+	CodigoHTTP  int       `json:"codigoHttp"`
+	DuracionMs  int64     `json:"duracionMs"`
+	Error       string    `json:"error,omitempty"`
+	Comprimido  bool      `json:"comprimido"`
+	Solicitud   string    `json:"solicitud,omitempty"` // Solo al obtener un intercambio por ID
+	Respuesta   string    `json:"respuesta,omitempty"`
+	Fecha       time.Time `json:"fecha"`
+}
+
+// FiltroIntercambiosSOAP criterios de búsqueda en el archivo SOAP; los campos vacíos no filtran
+type FiltroIntercambiosSOAP struct {
+	ClaveAcceso string
+	Operacion   string
+	Desde       time.Time
+	Hasta       time.Time
+	Limite      int
+	Offset      int
+}
+
+// GuardarIntercambioSOAP archiva un intercambio SOAP, comprimiendo los envelopes con gzip si se indica
+func (d *Database) GuardarIntercambioSOAP(intercambio sri.IntercambioSOAP, comprimir bool) (int, error) {
+	solicitud, respuesta := intercambio.Solicitud, intercambio.Respuesta
+	if comprimir {
+		var err error
+		if solicitud, err = comprimirGzip(solicitud); err != nil {
+			return 0, fmt.Errorf("error comprimiendo solicitud SOAP: %v", err)
+		}
+		if respuesta, err = comprimirGzip(respuesta); err != nil {
+			return 0, fmt.Errorf("error comprimiendo respuesta SOAP: %v", err)
+		}
+	}
+
+	fecha := intercambio.Fecha
+	if fecha.IsZero() {
+		fecha = time.Now()
+	}
+
+	var id int
+	err := d.db.QueryRow(`
+		INSERT INTO intercambios_soap (
+			operacion, clave_acceso, ambiente, endpoint, codigo_http, duracion_ms,
+			error, comprimido, solicitud, respuesta, fecha
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id`,
+		intercambio.Operacion, intercambio.ClaveAcceso, intercambio.Ambiente.String(), intercambio.Endpoint,
+		intercambio.CodigoHTTP, intercambio.Duracion.Milliseconds(), intercambio.Error, comprimir,
+		solicitud, respuesta, fecha.UTC()).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("error archivando intercambio SOAP: %v", err)
+	}
+	return id, nil
+}
+
+// BuscarIntercambiosSOAP lista los intercambios archivados, del más reciente al más antiguo.
+// Los envelopes no se incluyen; se obtienen con ObtenerIntercambioSOAP.
+func (d *Database) BuscarIntercambiosSOAP(filtro FiltroIntercambiosSOAP) ([]*IntercambioSOAPDB, error) {
+	var condiciones []string
+	var args []interface{}
+
+	if filtro.ClaveAcceso != "" {
+		condiciones = append(condiciones, "clave_acceso = ?")
+		args = append(args, filtro.ClaveAcceso)
+	}
+	if filtro.Operacion != "" {
+		condiciones = append(condiciones, "operacion = ?")
+		args = append(args, filtro.Operacion)
+	}
+	if !filtro.Desde.IsZero() {
+		condiciones = append(condiciones, "fecha >= ?")
+		args = append(args, filtro.Desde.UTC())
+	}
+	if !filtro.Hasta.IsZero() {
+		condiciones = append(condiciones, "fecha < ?")
+		args = append(args, filtro.Hasta.UTC())
+	}
+
+	limite := filtro.Limite
+	if limite <= 0 {
+		limite = 50
+	}
+
+	query := `
+		SELECT id, operacion, clave_acceso, ambiente, endpoint, codigo_http, duracion_ms, error, comprimido, fecha
+		FROM intercambios_soap`
+	if len(condiciones) > 0 {
+		query += " WHERE " + strings.Join(condiciones, " AND ")
+	}
+	query += " ORDER BY fecha DESC, id DESC LIMIT ? OFFSET ?"
+	args = append(args, limite, filtro.Offset)
+
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error consultando intercambios SOAP: %v", err)
+	}
+	defer rows.Close()
+
+	var intercambios []*IntercambioSOAPDB
+	for rows.Next() {
+		intercambio := &IntercambioSOAPDB{}
+		var clave, ambiente, endpoint, errorTexto sql.NullString
+		var codigo, duracion sql.NullInt64
+
+		err := rows.Scan(&intercambio.ID, &intercambio.Operacion, &clave, &ambiente, &endpoint,
+			&codigo, &duracion, &errorTexto, &intercambio.Comprimido, &intercambio.Fecha)
+		if err != nil {
+			return nil, fmt.Errorf("error escaneando intercambio SOAP: %v", err)
+		}
+
+		intercambio.ClaveAcceso = clave.String
+		intercambio.Ambiente = ambiente.String
+		intercambio.Endpoint = endpoint.String
+		intercambio.CodigoHTTP = int(codigo.Int64)
+		intercambio.DuracionMs = duracion.Int64
+		intercambio.Error = errorTexto.String
+
+		intercambios = append(intercambios, intercambio)
+	}
+
+	return intercambios, rows.Err()
+}
+
+// ObtenerIntercambioSOAP retorna un intercambio con sus envelopes ya descomprimidos
+func (d *Database) ObtenerIntercambioSOAP(id int) (*IntercambioSOAPDB, error) {
+	intercambio := &IntercambioSOAPDB{}
+	var clave, ambiente, endpoint, errorTexto sql.NullString
+	var codigo, duracion sql.NullInt64
+	var solicitud, respuesta []byte
+
+	err := d.db.QueryRow(`
+		SELECT id, operacion, clave_acceso, ambiente, endpoint, codigo_http, duracion_ms,
+		       error, comprimido, solicitud, respuesta, fecha
+		FROM intercambios_soap WHERE id = ?`, id).Scan(
+		&intercambio.ID, &intercambio.Operacion, &clave, &ambiente, &endpoint, &codigo, &duracion,
+		&errorTexto, &intercambio.Comprimido, &solicitud, &respuesta, &intercambio.Fecha)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("intercambio SOAP con ID %d no encontrado", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error obteniendo intercambio SOAP: %v", err)
+	}
+
+	if intercambio.Comprimido {
+		if solicitud, err = descomprimirGzip(solicitud); err != nil {
+			return nil, fmt.Errorf("error descomprimiendo solicitud SOAP: %v", err)
+		}
+		if respuesta, err = descomprimirGzip(respuesta); err != nil {
+			return nil, fmt.Errorf("error descomprimiendo respuesta SOAP: %v", err)
+		}
+	}
+
+	intercambio.ClaveAcceso = clave.String
+	intercambio.Ambiente = ambiente.String
+	intercambio.Endpoint = endpoint.String
+	intercambio.CodigoHTTP = int(codigo.Int64)
+	intercambio.DuracionMs = duracion.Int64
+	intercambio.Error = errorTexto.String
+	intercambio.Solicitud = string(solicitud)
+	intercambio.Respuesta = string(respuesta)
+
+	return intercambio, nil
+}
+
+// PurgarIntercambiosSOAP elimina los intercambios anteriores a la fecha indicada (política de retención)
+func (d *Database) PurgarIntercambiosSOAP(antesDe time.Time) (int64, error) {
+	result, err := d.db.Exec("DELETE FROM intercambios_soap WHERE fecha < ?", antesDe.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("error purgando intercambios SOAP: %v", err)
+	}
+	return result.RowsAffected()
+}
+
+// comprimirGzip comprime el contenido; un contenido vacío se mantiene vacío
+func comprimirGzip(contenido []byte) ([]byte, error) {
+	if len(contenido) == 0 {
+		return contenido, nil
+	}
+
+	var buffer bytes.Buffer
+	escritor := gzip.NewWriter(&buffer)
+	if _, err := escritor.Write(contenido); err != nil {
+		return nil, err
+	}
+	if err := escritor.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// descomprimirGzip revierte comprimirGzip
+func descomprimirGzip(contenido []byte) ([]byte, error) {
+	if len(contenido) == 0 {
+		return contenido, nil
+	}
+
+	lector, err := gzip.NewReader(bytes.NewReader(contenido))
+	if err != nil {
+		return nil, err
+	}
+	defer lector.Close()
+	return io.ReadAll(lector)
+}
@@ -0,0 +1,79 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"go-facturacion-sri/sri"
+)
+
+func TestIntercambiosSOAPComprimidosYBusqueda(t *testing.T) {
+	db := nuevaDBTrabajosPrueba(t)
+
+	clave := "2406202501179214673900110010010000000011234567813"
+	ahora := time.Now()
+	intercambios := []struct {
+		intercambio sri.IntercambioSOAP
+		comprimir   bool
+	}{
+		{sri.IntercambioSOAP{Operacion: sri.OperacionRecepcion, ClaveAcceso: clave, Solicitud: []byte("<soap:Envelope>recepcion</soap:Envelope>"),
+			Respuesta: []byte("<respuestaSolicitud>RECIBIDA</respuestaSolicitud>"), CodigoHTTP: 200, Duracion: 120 * time.Millisecond, Fecha: ahora.Add(-time.Minute)}, true},
+		{sri.IntercambioSOAP{Operacion: sri.OperacionAutorizacion, ClaveAcceso: clave, Solicitud: []byte("<soap:Envelope>autorizacion</soap:Envelope>"),
+			CodigoHTTP: 0, Error: "timeout", Fecha: ahora}, false},
+		{sri.IntercambioSOAP{Operacion: sri.OperacionRecepcion, ClaveAcceso: "otra", Solicitud: []byte("<antigua/>"),
+			CodigoHTTP: 200, Fecha: ahora.AddDate(0, 0, -40)}, true},
+	}
+
+	var idRecepcion int
+	for i, tt := range intercambios {
+		id, err := db.GuardarIntercambioSOAP(tt.intercambio, tt.comprimir)
+		if err != nil {
+			t.Fatalf("GuardarIntercambioSOAP() error: %v", err)
+		}
+		if i == 0 {
+			idRecepcion = id
+		}
+	}
+
+	encontrados, err := db.BuscarIntercambiosSOAP(FiltroIntercambiosSOAP{ClaveAcceso: clave})
+	if err != nil {
+		t.Fatalf("BuscarIntercambiosSOAP() error: %v", err)
+	}
+	if len(encontrados) != 2 {
+		t.Fatalf("Encontrados %d intercambios, esperados 2", len(encontrados))
+	}
+	if encontrados[0].Operacion != sri.OperacionAutorizacion || encontrados[0].Error != "timeout" {
+		t.Errorf("El más reciente debería ser la autorización fallida: %+v", encontrados[0])
+	}
+	if encontrados[0].Solicitud != "" {
+		t.Error("La búsqueda no debería incluir los envelopes")
+	}
+
+	recientes, err := db.BuscarIntercambiosSOAP(FiltroIntercambiosSOAP{Desde: ahora.AddDate(0, 0, -1)})
+	if err != nil {
+		t.Fatalf("BuscarIntercambiosSOAP() por fecha error: %v", err)
+	}
+	if len(recientes) != 2 {
+		t.Errorf("Intercambios del último día = %d, esperados 2", len(recientes))
+	}
+
+	completo, err := db.ObtenerIntercambioSOAP(idRecepcion)
+	if err != nil {
+		t.Fatalf("ObtenerIntercambioSOAP() error: %v", err)
+	}
+	if !completo.Comprimido || completo.Solicitud != "<soap:Envelope>recepcion</soap:Envelope>" ||
+		completo.Respuesta != "<respuestaSolicitud>RECIBIDA</respuestaSolicitud>" {
+		t.Errorf("Envelopes no recuperados correctamente: %+v", completo)
+	}
+	if completo.DuracionMs != 120 || completo.Ambiente != sri.Pruebas.String() {
+		t.Errorf("Metadatos inesperados: %+v", completo)
+	}
+
+	eliminados, err := db.PurgarIntercambiosSOAP(ahora.AddDate(0, 0, -30))
+	if err != nil {
+		t.Fatalf("PurgarIntercambiosSOAP() error: %v", err)
+	}
+	if eliminados != 1 {
+		t.Errorf("Eliminados = %d, esperado 1", eliminados)
+	}
+}
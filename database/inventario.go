@@ -0,0 +1,520 @@
+// Package database implementa el inventario por establecimiento y su kardex de movimientos
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"go-facturacion-sri/config"
+)
+
+// Tipos de movimiento del kardex de inventario
+const (
+	MovimientoVenta      = "VENTA"      // Factura autorizada por el SRI
+	MovimientoDevolucion = "DEVOLUCION" // Nota de crédito autorizada por devolución
+	MovimientoAjuste     = "AJUSTE"     // Ajuste manual (conteo físico, merma, ingreso)
+	MovimientoAnulacion  = "ANULACION"  // Reingreso por anulación de una factura autorizada
+)
+
+// ErrNotaCreditoRegistrada se retorna cuando la nota de crédito ya afectó el inventario
+var ErrNotaCreditoRegistrada = errors.New("la nota de crédito ya fue registrada en el inventario")
+
+var patronEstablecimiento = regexp.MustCompile(`^\d{3}$`)
+
+// StockDB existencias de un producto en un establecimiento
+type StockDB struct {
+	ProductoID         int       `json:"productoId"`
+	CodigoPrincipal    string    `json:"codigoPrincipal"`
+	Descripcion        string    `json:"descripcion"`
+	Establecimiento    string    `json:"establecimiento"`
+	Cantidad           float64   `json:"cantidad"`
+	StockMinimo        float64   `json:"stockMinimo"`
+	FechaActualizacion time.Time `json:"fechaActualizacion"`
+}
+
+// MovimientoInventarioDB registro del kardex; Cantidad es negativa en las salidas
+type MovimientoInventarioDB struct {
+	ID              int       `json:"id"`
+	ProductoID      int       `json:"productoId"`
+	Establecimiento string    `json:"establecimiento"`
+	Tipo            string    `json:"tipo"`
+	Cantidad        float64   `json:"cantidad"`
+	Saldo           float64   `json:"saldo"` // Stock resultante en el establecimiento
+	FacturaID       *int      `json:"facturaId,omitempty"`
+	Documento       string    `json:"documento,omitempty"` // Número de factura o clave de la nota de crédito
+	Motivo          string    `json:"motivo,omitempty"`
+	Usuario         string    `json:"usuario"`
+	Fecha           time.Time `json:"fecha"`
+	TenantID        int       `json:"tenantId"` // Emisor dueño del producto
+}
+
+// AjusteInventario ajuste manual de existencias
+type AjusteInventario struct {
+	ProductoID      int     `json:"productoId"`
+	Establecimiento string  `json:"establecimiento"`
+	Cantidad        float64 `json:"cantidad"` // Positiva para ingresos, negativa para salidas
+	Motivo          string  `json:"motivo"`
+	Usuario         string  `json:"usuario"`
+}
+
+// LineaDevolucion producto devuelto en una nota de crédito
+type LineaDevolucion struct {
+	Codigo   string  `json:"codigo"`
+	Cantidad float64 `json:"cantidad"`
+}
+
+// NotaCreditoDevolucion nota de crédito autorizada por el SRI que reingresa mercadería
+type NotaCreditoDevolucion struct {
+	ClaveAcceso string            `json:"claveAcceso"`
+	FacturaID   int               `json:"facturaId"` // Factura modificada por la nota de crédito
+	Lineas      []LineaDevolucion `json:"lineas"`
+	Usuario     string            `json:"usuario"`
+}
+
+// establecimientoDeClave extrae el establecimiento (posiciones 25-27) de una clave de acceso;
+// si la clave no tiene el formato del SRI se usa el establecimiento configurado
+func establecimientoDeClave(clave string) string {
+	if len(clave) == 49 {
+		return clave[24:27]
+	}
+	return config.Config.Empresa.Establecimiento
+}
+
+// moverStock aplica un movimiento al stock del establecimiento y lo registra en el kardex,
+// completando el saldo, el ID y la fecha del movimiento
+func moverStock(tx *transaccion, movimiento *MovimientoInventarioDB) error {
+	movimiento.Fecha = time.Now().UTC()
+
+	err := tx.QueryRow(`
+		INSERT INTO stock_productos (tenant_id, producto_id, establecimiento, cantidad, stock_minimo, fecha_actualizacion)
+		VALUES (?, ?, ?, ?, 0, ?)
+		ON CONFLICT (tenant_id, producto_id, establecimiento) DO UPDATE
+		SET cantidad = stock_productos.cantidad + excluded.cantidad,
+		    fecha_actualizacion = excluded.fecha_actualizacion
+		RETURNING cantidad`,
+		movimiento.TenantID, movimiento.ProductoID, movimiento.Establecimiento, movimiento.Cantidad,
+		movimiento.Fecha).Scan(&movimiento.Saldo)
+	if err != nil {
+		return fmt.Errorf("error actualizando stock: %v", err)
+	}
+
+	err = tx.QueryRow(`
+		INSERT INTO movimientos_inventario (
+			producto_id, establecimiento, tipo, cantidad, saldo, factura_id, documento, motivo, usuario, fecha, tenant_id
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id`,
+		movimiento.ProductoID, movimiento.Establecimiento, movimiento.Tipo, movimiento.Cantidad, movimiento.Saldo,
+		movimiento.FacturaID, movimiento.Documento, movimiento.Motivo, movimiento.Usuario, movimiento.Fecha,
+		movimiento.TenantID).Scan(&movimiento.ID)
+	if err != nil {
+		return fmt.Errorf("error registrando movimiento de inventario: %v", err)
+	}
+	return nil
+}
+
+// descontarInventarioFactura registra la salida de los productos con inventario de una factura
+// recién autorizada. Se ejecuta en la misma transacción que el cambio de estado; el stock puede
+// quedar negativo porque la venta ya fue autorizada por el SRI. Los productos se buscan en el
+// catálogo del tenant de la factura.
+func descontarInventarioFactura(tx *transaccion, facturaID int, actor string) error {
+	var numero, clave string
+	var tenantID int
+	err := tx.QueryRow("SELECT numero_factura, clave_acceso, tenant_id FROM facturas WHERE id = ?",
+		facturaID).Scan(&numero, &clave, &tenantID)
+	if err != nil {
+		return fmt.Errorf("error obteniendo factura para inventario: %v", err)
+	}
+
+	rows, err := tx.Query(`
+		SELECT c.id, SUM(p.cantidad)
+		FROM productos p
+		JOIN catalogo_productos c ON c.codigo_principal = p.codigo AND c.tenant_id = ?
+		WHERE p.factura_id = ? AND c.controla_inventario = TRUE
+		GROUP BY c.id
+		ORDER BY c.id`, tenantID, facturaID)
+	if err != nil {
+		return fmt.Errorf("error consultando productos con inventario: %v", err)
+	}
+
+	var movimientos []*MovimientoInventarioDB
+	for rows.Next() {
+		movimiento := &MovimientoInventarioDB{
+			Establecimiento: establecimientoDeClave(clave),
+			Tipo:            MovimientoVenta,
+			FacturaID:       &facturaID,
+			Documento:       numero,
+			Usuario:         actor,
+			TenantID:        tenantID,
+		}
+		if err := rows.Scan(&movimiento.ProductoID, &movimiento.Cantidad); err != nil {
+			rows.Close()
+			return fmt.Errorf("error escaneando productos con inventario: %v", err)
+		}
+		movimiento.Cantidad = -movimiento.Cantidad
+		movimientos = append(movimientos, movimiento)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error consultando productos con inventario: %v", err)
+	}
+
+	for _, movimiento := range movimientos {
+		if err := moverStock(tx, movimiento); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reingresarInventarioAnulacion devuelve al establecimiento de la venta lo que una factura anulada
+// había descontado, menos lo ya reingresado por notas de crédito
+func reingresarInventarioAnulacion(tx *transaccion, facturaID int, actor string) error {
+	rows, err := tx.Query(`
+		SELECT v.producto_id, v.establecimiento, v.documento, v.tenant_id, -SUM(v.cantidad),
+		       COALESCE((SELECT SUM(d.cantidad) FROM movimientos_inventario d
+		                 WHERE d.factura_id = v.factura_id AND d.producto_id = v.producto_id AND d.tipo = ?), 0)
+		FROM movimientos_inventario v
+		WHERE v.factura_id = ? AND v.tipo = ?
+		GROUP BY v.factura_id, v.producto_id, v.establecimiento, v.documento, v.tenant_id
+		ORDER BY v.producto_id`, MovimientoDevolucion, facturaID, MovimientoVenta)
+	if err != nil {
+		return fmt.Errorf("error consultando inventario de la factura: %v", err)
+	}
+
+	var movimientos []*MovimientoInventarioDB
+	for rows.Next() {
+		movimiento := &MovimientoInventarioDB{Tipo: MovimientoAnulacion, FacturaID: &facturaID, Usuario: actor}
+		var vendido, devuelto float64
+		if err := rows.Scan(&movimiento.ProductoID, &movimiento.Establecimiento, &movimiento.Documento,
+			&movimiento.TenantID, &vendido, &devuelto); err != nil {
+			rows.Close()
+			return fmt.Errorf("error escaneando inventario de la factura: %v", err)
+		}
+		if movimiento.Cantidad = vendido - devuelto; movimiento.Cantidad > 0 {
+			movimientos = append(movimientos, movimiento)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error consultando inventario de la factura: %v", err)
+	}
+
+	for _, movimiento := range movimientos {
+		if err := moverStock(tx, movimiento); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AjustarStock aplica un ajuste manual de existencias. Un ajuste no puede dejar el stock negativo.
+func (d *Database) AjustarStock(ctx context.Context, ajuste AjusteInventario) (*MovimientoInventarioDB, error) {
+	ajuste.Motivo = strings.TrimSpace(ajuste.Motivo)
+	if ajuste.Motivo == "" {
+		return nil, fmt.Errorf("el motivo del ajuste es requerido")
+	}
+	if ajuste.Cantidad == 0 {
+		return nil, fmt.Errorf("la cantidad del ajuste no puede ser cero")
+	}
+	if !patronEstablecimiento.MatchString(ajuste.Establecimiento) {
+		return nil, fmt.Errorf("establecimiento inválido: %q (debe tener 3 dígitos)", ajuste.Establecimiento)
+	}
+	ajuste.Usuario = usuarioAuditoria(ctx, ajuste.Usuario)
+
+	producto, err := d.ObtenerProductoCatalogo(ctx, ajuste.ProductoID)
+	if err != nil {
+		return nil, err
+	}
+	if !producto.ControlaInventario {
+		return nil, fmt.Errorf("el producto %s no controla inventario", producto.CodigoPrincipal)
+	}
+
+	antes, err := d.ObtenerStock(ctx, ajuste.ProductoID, ajuste.Establecimiento)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error iniciando transacción: %v", err)
+	}
+	defer tx.Rollback()
+
+	movimiento := &MovimientoInventarioDB{
+		ProductoID:      ajuste.ProductoID,
+		Establecimiento: ajuste.Establecimiento,
+		Tipo:            MovimientoAjuste,
+		Cantidad:        ajuste.Cantidad,
+		Motivo:          ajuste.Motivo,
+		Usuario:         ajuste.Usuario,
+		TenantID:        producto.TenantID,
+	}
+	if err := moverStock(tx, movimiento); err != nil {
+		return nil, err
+	}
+	if movimiento.Saldo < 0 {
+		return nil, fmt.Errorf("el ajuste deja el stock de %s en negativo (%.2f)", producto.CodigoPrincipal, movimiento.Saldo)
+	}
+
+	if err := auditarEnTransaccion(ctx, tx, "stock_productos", ajuste.ProductoID, "UPDATE", ajuste.Usuario, antes, movimiento); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error confirmando transacción: %v", err)
+	}
+	return movimiento, nil
+}
+
+// RegistrarNotaCreditoDevolucion reingresa al inventario los productos devueltos en una nota de
+// crédito autorizada. Las líneas de productos sin control de inventario se ignoran.
+func (d *Database) RegistrarNotaCreditoDevolucion(ctx context.Context, nota NotaCreditoDevolucion) ([]*MovimientoInventarioDB, error) {
+	if len(nota.ClaveAcceso) != 49 || nota.ClaveAcceso[8:10] != "04" {
+		return nil, fmt.Errorf("la clave de acceso no corresponde a una nota de crédito (codDoc 04)")
+	}
+	if len(nota.Lineas) == 0 {
+		return nil, fmt.Errorf("la nota de crédito no tiene productos devueltos")
+	}
+	nota.Usuario = usuarioAuditoria(ctx, nota.Usuario)
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error iniciando transacción: %v", err)
+	}
+	defer tx.Rollback()
+
+	var estado string
+	var tenantID int
+	filtro, args := filtroTenant(ctx, "tenant_id")
+	err = tx.QueryRow("SELECT estado, tenant_id FROM facturas WHERE id = ?"+filtro+tx.paraActualizar(),
+		append([]interface{}{nota.FacturaID}, args...)...).Scan(&estado, &tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("factura con ID %d no encontrada", nota.FacturaID)
+	}
+	if estado != EstadoAutorizada {
+		return nil, fmt.Errorf("solo se registran devoluciones de facturas autorizadas (estado actual: %s)", estado)
+	}
+
+	var registradas int
+	err = tx.QueryRow("SELECT COUNT(*) FROM movimientos_inventario WHERE documento = ? AND tipo = ? AND tenant_id = ?",
+		nota.ClaveAcceso, MovimientoDevolucion, tenantID).Scan(&registradas)
+	if err != nil {
+		return nil, fmt.Errorf("error verificando nota de crédito: %v", err)
+	}
+	if registradas > 0 {
+		return nil, ErrNotaCreditoRegistrada
+	}
+
+	var movimientos []*MovimientoInventarioDB
+	for i, linea := range nota.Lineas {
+		if linea.Cantidad <= 0 {
+			return nil, fmt.Errorf("línea %d: la cantidad devuelta debe ser mayor a cero", i+1)
+		}
+
+		var productoID int
+		var codigo string
+		var controlaInventario bool
+		err := tx.QueryRow(`
+			SELECT id, codigo_principal, controla_inventario FROM catalogo_productos
+			WHERE (codigo_principal = ? OR codigo_auxiliar = ?) AND tenant_id = ?
+			ORDER BY CASE WHEN codigo_principal = ? THEN 0 ELSE 1 END
+			LIMIT 1`, linea.Codigo, linea.Codigo, tenantID, linea.Codigo).Scan(&productoID, &codigo, &controlaInventario)
+		if err != nil {
+			return nil, fmt.Errorf("línea %d: producto con código %s no encontrado en el catálogo", i+1, linea.Codigo)
+		}
+		if !controlaInventario {
+			continue
+		}
+
+		var vendido, devuelto float64
+		err = tx.QueryRow("SELECT COALESCE(SUM(cantidad), 0) FROM productos WHERE factura_id = ? AND codigo = ?",
+			nota.FacturaID, codigo).Scan(&vendido)
+		if err != nil {
+			return nil, fmt.Errorf("error consultando cantidad facturada: %v", err)
+		}
+		err = tx.QueryRow(`
+			SELECT COALESCE(SUM(cantidad), 0) FROM movimientos_inventario
+			WHERE factura_id = ? AND producto_id = ? AND tipo = ?`,
+			nota.FacturaID, productoID, MovimientoDevolucion).Scan(&devuelto)
+		if err != nil {
+			return nil, fmt.Errorf("error consultando cantidad devuelta: %v", err)
+		}
+		if devuelto+linea.Cantidad > vendido+1e-9 {
+			return nil, fmt.Errorf("línea %d: se devuelven %.2f de %s pero solo quedan %.2f facturados sin devolver",
+				i+1, linea.Cantidad, codigo, vendido-devuelto)
+		}
+
+		movimiento := &MovimientoInventarioDB{
+			ProductoID:      productoID,
+			Establecimiento: establecimientoDeClave(nota.ClaveAcceso),
+			Tipo:            MovimientoDevolucion,
+			Cantidad:        linea.Cantidad,
+			FacturaID:       &nota.FacturaID,
+			Documento:       nota.ClaveAcceso,
+			Usuario:         nota.Usuario,
+			TenantID:        tenantID,
+		}
+		if err := moverStock(tx, movimiento); err != nil {
+			return nil, err
+		}
+		movimientos = append(movimientos, movimiento)
+	}
+
+	if len(movimientos) > 0 {
+		err := auditarEnTransaccion(ctx, tx, "movimientos_inventario", nota.FacturaID, "INSERT", nota.Usuario, nil, movimientos)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error confirmando transacción: %v", err)
+	}
+	return movimientos, nil
+}
+
+// ConfigurarStockMinimo fija el stock mínimo de un producto en un establecimiento
+func (d *Database) ConfigurarStockMinimo(ctx context.Context, productoID int, establecimiento string, minimo float64) (*StockDB, error) {
+	if !patronEstablecimiento.MatchString(establecimiento) {
+		return nil, fmt.Errorf("establecimiento inválido: %q (debe tener 3 dígitos)", establecimiento)
+	}
+	if minimo < 0 {
+		return nil, fmt.Errorf("el stock mínimo no puede ser negativo")
+	}
+	producto, err := d.ObtenerProductoCatalogo(ctx, productoID)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = d.db.Exec(`
+		INSERT INTO stock_productos (tenant_id, producto_id, establecimiento, cantidad, stock_minimo, fecha_actualizacion)
+		VALUES (?, ?, ?, 0, ?, ?)
+		ON CONFLICT (tenant_id, producto_id, establecimiento) DO UPDATE
+		SET stock_minimo = excluded.stock_minimo, fecha_actualizacion = excluded.fecha_actualizacion`,
+		producto.TenantID, productoID, establecimiento, minimo, time.Now().UTC())
+	if err != nil {
+		return nil, fmt.Errorf("error configurando stock mínimo: %v", err)
+	}
+	return d.ObtenerStock(ctx, productoID, establecimiento)
+}
+
+// ObtenerStock retorna las existencias de un producto en un establecimiento;
+// un producto sin movimientos tiene stock cero
+func (d *Database) ObtenerStock(ctx context.Context, productoID int, establecimiento string) (*StockDB, error) {
+	stock, err := d.consultarStock(ctx, " AND c.id = ? AND s.establecimiento = ?", "", productoID, establecimiento)
+	if err != nil {
+		return nil, err
+	}
+	if len(stock) > 0 {
+		return stock[0], nil
+	}
+
+	producto, err := d.ObtenerProductoCatalogo(ctx, productoID)
+	if err != nil {
+		return nil, err
+	}
+	return &StockDB{
+		ProductoID:      producto.ID,
+		CodigoPrincipal: producto.CodigoPrincipal,
+		Descripcion:     producto.Descripcion,
+		Establecimiento: establecimiento,
+	}, nil
+}
+
+// ListarStock lista las existencias, opcionalmente de un solo establecimiento
+func (d *Database) ListarStock(ctx context.Context, establecimiento string, limite, offset int) ([]*StockDB, error) {
+	filtro := ""
+	var args []interface{}
+	if establecimiento != "" {
+		filtro = " AND s.establecimiento = ?"
+		args = append(args, establecimiento)
+	}
+	args = append(args, limite, offset)
+	return d.consultarStock(ctx, filtro, " LIMIT ? OFFSET ?", args...)
+}
+
+// ListarStockBajo lista los productos activos cuyo stock está en o por debajo del mínimo
+func (d *Database) ListarStockBajo(ctx context.Context, establecimiento string) ([]*StockDB, error) {
+	filtro := " AND c.activo = TRUE AND s.cantidad <= s.stock_minimo"
+	var args []interface{}
+	if establecimiento != "" {
+		filtro += " AND s.establecimiento = ?"
+		args = append(args, establecimiento)
+	}
+	return d.consultarStock(ctx, filtro, "", args...)
+}
+
+// consultarStock ejecuta la consulta de existencias del tenant del contexto con un filtro
+// adicional; sufijo va después del ORDER BY (paginación)
+func (d *Database) consultarStock(ctx context.Context, filtro, sufijo string, args ...interface{}) ([]*StockDB, error) {
+	condicionTenant, argsTenant := filtroTenant(ctx, "s.tenant_id")
+	args = append(argsTenant, args...)
+	query := `
+		SELECT s.producto_id, c.codigo_principal, c.descripcion, s.establecimiento,
+		       s.cantidad, s.stock_minimo, s.fecha_actualizacion
+		FROM stock_productos s
+		JOIN catalogo_productos c ON c.id = s.producto_id
+		WHERE c.controla_inventario = TRUE` + condicionTenant + filtro + `
+		ORDER BY c.codigo_principal, s.establecimiento` + sufijo
+
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error consultando stock: %v", err)
+	}
+	defer rows.Close()
+
+	var stock []*StockDB
+	for rows.Next() {
+		registro := &StockDB{}
+		err := rows.Scan(&registro.ProductoID, &registro.CodigoPrincipal, &registro.Descripcion,
+			&registro.Establecimiento, &registro.Cantidad, &registro.StockMinimo, &registro.FechaActualizacion)
+		if err != nil {
+			return nil, fmt.Errorf("error escaneando stock: %v", err)
+		}
+		stock = append(stock, registro)
+	}
+	return stock, rows.Err()
+}
+
+// ListarMovimientosInventario lista el kardex del tenant del contexto, del más reciente al más antiguo;
+// productoID 0 y establecimiento vacío no filtran
+func (d *Database) ListarMovimientosInventario(ctx context.Context, productoID int, establecimiento string, limite, offset int) ([]*MovimientoInventarioDB, error) {
+	filtro, args := filtroTenant(ctx, "tenant_id")
+	query := `
+		SELECT id, producto_id, establecimiento, tipo, cantidad, saldo, factura_id,
+		       COALESCE(documento, ''), COALESCE(motivo, ''), usuario, fecha, tenant_id
+		FROM movimientos_inventario WHERE 1 = 1` + filtro
+	if productoID > 0 {
+		query += " AND producto_id = ?"
+		args = append(args, productoID)
+	}
+	if establecimiento != "" {
+		query += " AND establecimiento = ?"
+		args = append(args, establecimiento)
+	}
+	query += " ORDER BY id DESC LIMIT ? OFFSET ?"
+	args = append(args, limite, offset)
+
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error consultando movimientos de inventario: %v", err)
+	}
+	defer rows.Close()
+
+	var movimientos []*MovimientoInventarioDB
+	for rows.Next() {
+		movimiento := &MovimientoInventarioDB{}
+		err := rows.Scan(&movimiento.ID, &movimiento.ProductoID, &movimiento.Establecimiento, &movimiento.Tipo,
+			&movimiento.Cantidad, &movimiento.Saldo, &movimiento.FacturaID, &movimiento.Documento,
+			&movimiento.Motivo, &movimiento.Usuario, &movimiento.Fecha, &movimiento.TenantID)
+		if err != nil {
+			return nil, fmt.Errorf("error escaneando movimiento de inventario: %v", err)
+		}
+		movimientos = append(movimientos, movimiento)
+	}
+	return movimientos, rows.Err()
+}
@@ -0,0 +1,155 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"go-facturacion-sri/factory"
+	"go-facturacion-sri/models"
+)
+
+// clavePrueba arma una clave de acceso de 49 dígitos con el tipo de documento y establecimiento dados
+func clavePrueba(codDoc, establecimiento, secuencial string) string {
+	return "19102026" + codDoc + "1713175071001" + "1" + establecimiento + "001" + secuencial + "12345678" + "1" + "7"
+}
+
+// productoInventarioPrueba agrega al catálogo un producto que controla inventario
+func productoInventarioPrueba(t *testing.T, db *Database, codigo string) *ProductoCatalogoDB {
+	t.Helper()
+
+	producto, err := db.GuardarProductoCatalogo(context.Background(), &ProductoCatalogoDB{
+		CodigoPrincipal:    codigo,
+		Descripcion:        "Producto " + codigo,
+		PrecioUnitario:     10,
+		ControlaInventario: true,
+	})
+	if err != nil {
+		t.Fatalf("GuardarProductoCatalogo() error: %v", err)
+	}
+	return producto
+}
+
+// autorizarFacturaInventario guarda y autoriza una factura con las líneas indicadas
+func autorizarFacturaInventario(t *testing.T, db *Database, clave string, productos []models.ProductoInput) int {
+	t.Helper()
+	setupTestConfig()
+
+	productos, err := db.CompletarDesdeCatalogo(context.Background(), productos)
+	if err != nil {
+		t.Fatalf("CompletarDesdeCatalogo() error: %v", err)
+	}
+	factura, err := factory.CrearFactura(models.FacturaInput{
+		ClienteNombre: "CLIENTE INVENTARIO",
+		ClienteCedula: "1713175071",
+		Productos:     productos,
+	})
+	if err != nil {
+		t.Fatalf("CrearFactura() error: %v", err)
+	}
+	facturaDB, err := db.GuardarFactura(context.Background(), factura, clave, productos)
+	if err != nil {
+		t.Fatalf("GuardarFactura() error: %v", err)
+	}
+
+	recorrerHastaRecibida(t, db, facturaDB.ID)
+	if err := db.TransicionarEstadoFactura(context.Background(), facturaDB.ID, CambioEstadoFactura{Estado: EstadoAutorizada, Actor: "pipeline"}); err != nil {
+		t.Fatalf("TransicionarEstadoFactura(AUTORIZADA) error: %v", err)
+	}
+	return facturaDB.ID
+}
+
+func TestInventarioFacturaYNotaCredito(t *testing.T) {
+	db := nuevaDBTrabajosPrueba(t)
+	producto := productoInventarioPrueba(t, db, "INV001")
+	if _, err := db.GuardarProductoCatalogo(context.Background(), &ProductoCatalogoDB{CodigoPrincipal: "SERV001", Descripcion: "Instalación", PrecioUnitario: 5}); err != nil {
+		t.Fatalf("GuardarProductoCatalogo() error: %v", err)
+	}
+
+	if _, err := db.AjustarStock(context.Background(), AjusteInventario{ProductoID: producto.ID, Establecimiento: "002", Cantidad: 10, Motivo: "Inventario inicial"}); err != nil {
+		t.Fatalf("AjustarStock() error: %v", err)
+	}
+
+	facturaID := autorizarFacturaInventario(t, db, clavePrueba("01", "002", "000000001"), []models.ProductoInput{
+		{Codigo: "INV001", Cantidad: 3},
+		{Codigo: "SERV001", Cantidad: 1},
+		{Codigo: "INV001", Cantidad: 1},
+	})
+
+	stock, err := db.ObtenerStock(context.Background(), producto.ID, "002")
+	if err != nil || stock.Cantidad != 6 {
+		t.Fatalf("Stock tras autorizar = %+v, %v; esperado 6", stock, err)
+	}
+
+	claveNC := clavePrueba("04", "002", "000000002")
+	if _, err := db.RegistrarNotaCreditoDevolucion(context.Background(), NotaCreditoDevolucion{
+		ClaveAcceso: claveNC, FacturaID: facturaID, Lineas: []LineaDevolucion{{Codigo: "INV001", Cantidad: 5}},
+	}); err == nil {
+		t.Error("Devolver más de lo facturado debió fallar")
+	}
+
+	movimientos, err := db.RegistrarNotaCreditoDevolucion(context.Background(), NotaCreditoDevolucion{
+		ClaveAcceso: claveNC, FacturaID: facturaID,
+		Lineas: []LineaDevolucion{{Codigo: "INV001", Cantidad: 2}, {Codigo: "SERV001", Cantidad: 1}},
+	})
+	if err != nil {
+		t.Fatalf("RegistrarNotaCreditoDevolucion() error: %v", err)
+	}
+	if len(movimientos) != 1 || movimientos[0].Saldo != 8 || movimientos[0].Establecimiento != "002" {
+		t.Errorf("Movimientos de devolución inesperados: %+v", movimientos)
+	}
+
+	// La devolución se audita en la misma transacción; el intento rechazado no deja entrada
+	auditoria, err := db.ObtenerAuditoriaPorRegistro(context.Background(), "movimientos_inventario", facturaID)
+	if err != nil || len(auditoria) != 1 || auditoria[0].Operacion != "INSERT" || !strings.Contains(auditoria[0].DatosDespues, claveNC) {
+		t.Errorf("Auditoría de la nota de crédito = %+v, %v", auditoria, err)
+	}
+
+	_, err = db.RegistrarNotaCreditoDevolucion(context.Background(), NotaCreditoDevolucion{
+		ClaveAcceso: claveNC, FacturaID: facturaID, Lineas: []LineaDevolucion{{Codigo: "INV001", Cantidad: 1}},
+	})
+	if !errors.Is(err, ErrNotaCreditoRegistrada) {
+		t.Errorf("Registrar dos veces la misma nota de crédito = %v, esperado ErrNotaCreditoRegistrada", err)
+	}
+
+	kardex, err := db.ListarMovimientosInventario(context.Background(), producto.ID, "002", 10, 0)
+	if err != nil || len(kardex) != 3 {
+		t.Fatalf("ListarMovimientosInventario() = %d movimientos, %v; esperados 3", len(kardex), err)
+	}
+	venta := kardex[1]
+	if venta.Tipo != MovimientoVenta || venta.Cantidad != -4 || venta.FacturaID == nil || *venta.FacturaID != facturaID {
+		t.Errorf("Movimiento de venta inesperado: %+v", venta)
+	}
+}
+
+func TestAjusteYStockBajo(t *testing.T) {
+	db := nuevaDBTrabajosPrueba(t)
+	producto := productoInventarioPrueba(t, db, "INV002")
+
+	if _, err := db.AjustarStock(context.Background(), AjusteInventario{ProductoID: producto.ID, Establecimiento: "001", Cantidad: 5}); err == nil {
+		t.Error("Un ajuste sin motivo debió fallar")
+	}
+	if _, err := db.AjustarStock(context.Background(), AjusteInventario{ProductoID: producto.ID, Establecimiento: "001", Cantidad: -1, Motivo: "Merma"}); err == nil {
+		t.Error("Un ajuste que deja stock negativo debió fallar")
+	}
+	if _, err := db.AjustarStock(context.Background(), AjusteInventario{ProductoID: producto.ID, Establecimiento: "001", Cantidad: 5, Motivo: "Compra", Usuario: "bodega"}); err != nil {
+		t.Fatalf("AjustarStock() error: %v", err)
+	}
+
+	if _, err := db.ConfigurarStockMinimo(context.Background(), producto.ID, "001", 10); err != nil {
+		t.Fatalf("ConfigurarStockMinimo() error: %v", err)
+	}
+	bajo, err := db.ListarStockBajo(context.Background(), "001")
+	if err != nil || len(bajo) != 1 || bajo[0].Cantidad != 5 || bajo[0].StockMinimo != 10 {
+		t.Fatalf("ListarStockBajo() = %+v, %v", bajo, err)
+	}
+	if bajo, _ := db.ListarStockBajo(context.Background(), "002"); len(bajo) != 0 {
+		t.Errorf("ListarStockBajo(002) = %+v, esperado vacío", bajo)
+	}
+
+	auditoria, err := db.ObtenerAuditoriaPorRegistro(context.Background(), "stock_productos", producto.ID)
+	if err != nil || len(auditoria) != 1 || auditoria[0].Usuario != "bodega" {
+		t.Errorf("Auditoría del ajuste = %+v, %v", auditoria, err)
+	}
+}
@@ -0,0 +1,295 @@
+// Package database aplica y revierte las migraciones versionadas del esquema
+package database
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"go-facturacion-sri/config"
+)
+
+// Migracion cambio versionado del esquema con su reversión
+type Migracion struct {
+	Version     int
+	Descripcion string
+	Subir       func(tx *transaccion) error
+	Bajar       func(tx *transaccion) error
+}
+
+// EstadoMigracion indica si una migración conocida está aplicada en la base de datos
+type EstadoMigracion struct {
+	Version         int        `json:"version"`
+	Descripcion     string     `json:"descripcion"`
+	Aplicada        bool       `json:"aplicada"`
+	FechaAplicacion *time.Time `json:"fechaAplicacion,omitempty"`
+}
+
+// sentencias crea el paso de una migración a partir de sentencias SQL ejecutadas en orden
+func sentencias(sqls ...string) func(tx *transaccion) error {
+	return func(tx *transaccion) error {
+		for _, sentencia := range sqls {
+			if _, err := tx.Exec(sentencia); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// UltimaVersionEsquema versión del esquema que espera este binario. Ambos dialectos
+// mantienen la misma numeración de migraciones.
+func UltimaVersionEsquema() int {
+	return migraciones[len(migraciones)-1].Version
+}
+
+// migracionesDialecto historial de migraciones correspondiente al motor de la base de datos
+func (d *Database) migracionesDialecto() []Migracion {
+	if d.dialecto == DialectoPostgres {
+		return migracionesPostgres
+	}
+	return migraciones
+}
+
+// asegurarTablaMigraciones crea la tabla que registra las migraciones aplicadas
+func (d *Database) asegurarTablaMigraciones() error {
+	tipoFecha := "DATETIME"
+	if d.dialecto == DialectoPostgres {
+		tipoFecha = "TIMESTAMPTZ"
+	}
+	_, err := d.db.Exec(`
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		descripcion TEXT NOT NULL,
+		fecha_aplicacion ` + tipoFecha + ` NOT NULL
+	);`)
+	if err != nil {
+		return fmt.Errorf("error creando tabla schema_migrations: %v", err)
+	}
+	return nil
+}
+
+// VersionEsquema retorna la mayor versión aplicada (0 si la base de datos está vacía)
+func (d *Database) VersionEsquema() (int, error) {
+	var version int
+	if err := d.db.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&version); err != nil {
+		return 0, fmt.Errorf("error leyendo versión del esquema: %v", err)
+	}
+	return version, nil
+}
+
+// verificarEsquema impide abrir una base de datos migrada por una versión más nueva del sistema
+func (d *Database) verificarEsquema() error {
+	version, err := d.VersionEsquema()
+	if err != nil {
+		return err
+	}
+	if version > UltimaVersionEsquema() {
+		return fmt.Errorf("el esquema de la base de datos (versión %d) es más nuevo que el soportado (versión %d)", version, UltimaVersionEsquema())
+	}
+	return nil
+}
+
+// Migrar aplica las migraciones pendientes en orden, cada una en su propia transacción.
+// Retorna la cantidad de migraciones aplicadas.
+func (d *Database) Migrar() (int, error) {
+	aplicadas := 0
+	for _, migracion := range d.migracionesDialecto() {
+		aplicada, err := d.aplicarMigracion(migracion)
+		if err != nil {
+			return aplicadas, err
+		}
+		if aplicada {
+			log.Printf("📦 Migración %03d aplicada: %s", migracion.Version, migracion.Descripcion)
+			aplicadas++
+		}
+	}
+	return aplicadas, nil
+}
+
+// aplicarMigracion ejecuta la migración si aún no está registrada. La verificación se hace
+// dentro de la transacción para que dos procesos que abren la base a la vez no la apliquen dos veces;
+// en PostgreSQL además se toma un bloqueo de aplicación porque la lectura no bloquea a otros procesos.
+func (d *Database) aplicarMigracion(migracion Migracion) (bool, error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("error iniciando migración %d: %v", migracion.Version, err)
+	}
+	defer tx.Rollback()
+
+	if err := tx.bloquear(bloqueoMigraciones); err != nil {
+		return false, err
+	}
+
+	var existe int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM schema_migrations WHERE version = ?", migracion.Version).Scan(&existe); err != nil {
+		return false, fmt.Errorf("error verificando migración %d: %v", migracion.Version, err)
+	}
+	if existe > 0 {
+		return false, nil
+	}
+
+	if err := migracion.Subir(tx); err != nil {
+		return false, fmt.Errorf("error aplicando migración %d (%s): %v", migracion.Version, migracion.Descripcion, err)
+	}
+	_, err = tx.Exec("INSERT INTO schema_migrations (version, descripcion, fecha_aplicacion) VALUES (?, ?, ?)",
+		migracion.Version, migracion.Descripcion, time.Now().UTC())
+	if err != nil {
+		return false, fmt.Errorf("error registrando migración %d: %v", migracion.Version, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("error confirmando migración %d: %v", migracion.Version, err)
+	}
+	return true, nil
+}
+
+// Revertir deshace las últimas migraciones aplicadas, de la más reciente a la más antigua.
+// Retorna la cantidad de migraciones revertidas.
+func (d *Database) Revertir(pasos int) (int, error) {
+	revertidas := 0
+	historial := d.migracionesDialecto()
+	for i := len(historial) - 1; i >= 0 && revertidas < pasos; i-- {
+		migracion := historial[i]
+
+		tx, err := d.db.Begin()
+		if err != nil {
+			return revertidas, fmt.Errorf("error iniciando reversión %d: %v", migracion.Version, err)
+		}
+		if err := tx.bloquear(bloqueoMigraciones); err != nil {
+			tx.Rollback()
+			return revertidas, err
+		}
+
+		result, err := tx.Exec("DELETE FROM schema_migrations WHERE version = ?", migracion.Version)
+		if err != nil {
+			tx.Rollback()
+			return revertidas, fmt.Errorf("error revirtiendo migración %d: %v", migracion.Version, err)
+		}
+		if filas, _ := result.RowsAffected(); filas == 0 {
+			tx.Rollback()
+			continue
+		}
+
+		if err := migracion.Bajar(tx); err != nil {
+			tx.Rollback()
+			return revertidas, fmt.Errorf("error revirtiendo migración %d (%s): %v", migracion.Version, migracion.Descripcion, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return revertidas, fmt.Errorf("error confirmando reversión %d: %v", migracion.Version, err)
+		}
+
+		log.Printf("↩️  Migración %03d revertida: %s", migracion.Version, migracion.Descripcion)
+		revertidas++
+	}
+	return revertidas, nil
+}
+
+// EstadoMigraciones lista las migraciones conocidas indicando cuáles están aplicadas
+func (d *Database) EstadoMigraciones() ([]EstadoMigracion, error) {
+	rows, err := d.db.Query("SELECT version, fecha_aplicacion FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("error consultando migraciones: %v", err)
+	}
+	defer rows.Close()
+
+	aplicadas := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var fecha time.Time
+		if err := rows.Scan(&version, &fecha); err != nil {
+			return nil, fmt.Errorf("error escaneando migración: %v", err)
+		}
+		aplicadas[version] = fecha
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error consultando migraciones: %v", err)
+	}
+
+	historial := d.migracionesDialecto()
+	estados := make([]EstadoMigracion, 0, len(historial))
+	for _, migracion := range historial {
+		estado := EstadoMigracion{Version: migracion.Version, Descripcion: migracion.Descripcion}
+		if fecha, ok := aplicadas[migracion.Version]; ok {
+			estado.Aplicada = true
+			estado.FechaAplicacion = &fecha
+		}
+		estados = append(estados, estado)
+	}
+	return estados, nil
+}
+
+// EjecutarCLIMigraciones administra el esquema de la base de datos configurada
+//
+// Uso:
+//
+//	migraciones estado
+//	migraciones subir
+//	migraciones bajar [pasos]   (por defecto revierte una migración)
+func EjecutarCLIMigraciones(args []string, cfg config.DatabaseConfig) error {
+	if len(args) == 0 {
+		return fmt.Errorf("uso: migraciones [estado | subir | bajar [pasos]]")
+	}
+
+	dialecto, fuente, err := fuenteDesdeConfig(cfg)
+	if err != nil {
+		return err
+	}
+
+	// Abrir sin migrar: estado y bajar no deben aplicar migraciones pendientes
+	d, err := abrir(dialecto, fuente)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	switch args[0] {
+	case "estado":
+		estados, err := d.EstadoMigraciones()
+		if err != nil {
+			return err
+		}
+		version, err := d.VersionEsquema()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("🗄️  Esquema de %s: versión %d de %d\n", d.descripcion(), version, UltimaVersionEsquema())
+		for _, estado := range estados {
+			marca := "⏳ pendiente"
+			if estado.Aplicada {
+				marca = "✅ " + estado.FechaAplicacion.Local().Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("   %03d  %-22s %s\n", estado.Version, marca, estado.Descripcion)
+		}
+
+	case "subir":
+		if err := d.verificarEsquema(); err != nil {
+			return err
+		}
+		aplicadas, err := d.Migrar()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("✅ %d migraciones aplicadas\n", aplicadas)
+
+	case "bajar":
+		pasos := 1
+		if len(args) > 1 {
+			pasos, err = strconv.Atoi(args[1])
+			if err != nil || pasos <= 0 {
+				return fmt.Errorf("pasos inválidos: %s", args[1])
+			}
+		}
+		revertidas, err := d.Revertir(pasos)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("↩️  %d migraciones revertidas\n", revertidas)
+
+	default:
+		return fmt.Errorf("comando desconocido: %s", args[0])
+	}
+
+	return nil
+}
@@ -0,0 +1,164 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// columnasTabla retorna los nombres de columna de una tabla
+func columnasTabla(t *testing.T, db *Database, tabla string) []string {
+	t.Helper()
+
+	// Consulta portable entre dialectos; una tabla inexistente no tiene columnas
+	rows, err := db.db.Query("SELECT * FROM " + tabla + " LIMIT 0")
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+
+	columnas, err := rows.Columns()
+	if err != nil {
+		t.Fatalf("Error leyendo columnas de %s: %v", tabla, err)
+	}
+	return columnas
+}
+
+func TestNewAplicaMigraciones(t *testing.T) {
+	db := nuevaDBTrabajosPrueba(t)
+
+	version, err := db.VersionEsquema()
+	if err != nil {
+		t.Fatalf("VersionEsquema() error: %v", err)
+	}
+	if version != UltimaVersionEsquema() {
+		t.Errorf("Versión = %d, esperada %d", version, UltimaVersionEsquema())
+	}
+
+	estados, err := db.EstadoMigraciones()
+	if err != nil {
+		t.Fatalf("EstadoMigraciones() error: %v", err)
+	}
+	for _, estado := range estados {
+		if !estado.Aplicada || estado.FechaAplicacion == nil {
+			t.Errorf("Migración %d no aplicada", estado.Version)
+		}
+	}
+
+	// Volver a migrar no aplica nada
+	if aplicadas, err := db.Migrar(); err != nil || aplicadas != 0 {
+		t.Errorf("Migrar() = %d, %v; se esperaba 0 sin error", aplicadas, err)
+	}
+}
+
+func TestRevertirYVolverAMigrar(t *testing.T) {
+	db := nuevaDBTrabajosPrueba(t)
+
+	// Revertir hasta antes de la migración 5, que agrega la columna errores_intentos
+	pasos := UltimaVersionEsquema() - 4
+	revertidas, err := db.Revertir(pasos)
+	if err != nil || revertidas != pasos {
+		t.Fatalf("Revertir(%d) = %d, %v", pasos, revertidas, err)
+	}
+	if strings.Contains(strings.Join(columnasTabla(t, db, "trabajos_autorizacion"), ","), "errores_intentos") {
+		t.Error("La columna errores_intentos debió eliminarse al revertir")
+	}
+	if version, _ := db.VersionEsquema(); version != 4 {
+		t.Errorf("Versión tras revertir = %d, esperada 4", version)
+	}
+
+	// Revertir todo deja la base sin tablas del sistema
+	if _, err := db.Revertir(len(migraciones)); err != nil {
+		t.Fatalf("Revertir() todo error: %v", err)
+	}
+	if columnas := columnasTabla(t, db, "facturas"); len(columnas) != 0 {
+		t.Errorf("La tabla facturas no se eliminó: %v", columnas)
+	}
+
+	aplicadas, err := db.Migrar()
+	if err != nil || aplicadas != len(migraciones) {
+		t.Fatalf("Migrar() = %d, %v; se esperaban %d", aplicadas, err, len(migraciones))
+	}
+}
+
+func TestMigrarInstalacionExistente(t *testing.T) {
+	ruta := filepath.Join(t.TempDir(), "legado.db")
+
+	// Base creada antes del sistema de migraciones: tablas presentes, sin schema_migrations
+	legado, err := sql.Open("sqlite3", ruta)
+	if err != nil {
+		t.Fatalf("Error abriendo base legada: %v", err)
+	}
+	sqlTx, err := legado.Begin()
+	if err != nil {
+		t.Fatalf("Error iniciando transacción: %v", err)
+	}
+	tx := &transaccion{Tx: sqlTx, dialecto: DialectoSQLite}
+	for _, migracion := range migraciones[:2] {
+		if err := migracion.Subir(tx); err != nil {
+			t.Fatalf("Error creando esquema legado: %v", err)
+		}
+	}
+	if _, err := tx.Exec("ALTER TABLE trabajos_autorizacion ADD COLUMN errores_intentos TEXT"); err != nil {
+		t.Fatalf("Error agregando columna legada: %v", err)
+	}
+	tx.Commit()
+	legado.Close()
+
+	db, err := New(ruta)
+	if err != nil {
+		t.Fatalf("New() sobre base legada error: %v", err)
+	}
+	defer db.Close()
+
+	if version, _ := db.VersionEsquema(); version != UltimaVersionEsquema() {
+		t.Errorf("Versión = %d, esperada %d", version, UltimaVersionEsquema())
+	}
+	if _, err := db.ListarFallidos("", 10, 0); err != nil {
+		t.Errorf("cola_fallidos no disponible tras migrar: %v", err)
+	}
+}
+
+func TestNewRechazaEsquemaMasNuevo(t *testing.T) {
+	ruta := filepath.Join(t.TempDir(), "futuro.db")
+	db, err := New(ruta)
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	_, err = db.db.Exec("INSERT INTO schema_migrations (version, descripcion, fecha_aplicacion) VALUES (?, 'futura', CURRENT_TIMESTAMP)",
+		UltimaVersionEsquema()+1)
+	db.Close()
+	if err != nil {
+		t.Fatalf("Error registrando migración futura: %v", err)
+	}
+
+	if _, err := New(ruta); err == nil || !strings.Contains(err.Error(), "más nuevo") {
+		t.Errorf("Se esperaba error de esquema más nuevo, obtenido %v", err)
+	}
+}
+
+func TestActualizarFacturaReemplazaProductos(t *testing.T) {
+	db := nuevaDBTrabajosPrueba(t)
+	id := facturaEstadosPrueba(t, db, "CLAVE-ACTUALIZAR")
+
+	productos := []ProductoDB{
+		{Codigo: "ACT001", Descripcion: "Producto actualizado", Cantidad: 2, PrecioUnitario: 15},
+	}
+	factura, err := db.ActualizarFactura(context.Background(), id, "1713175071", "CLIENTE ACTUALIZADO", productos, "")
+	if err != nil {
+		t.Fatalf("ActualizarFactura() error: %v", err)
+	}
+	if factura.Subtotal != 30 || factura.ClienteNombre != "CLIENTE ACTUALIZADO" {
+		t.Errorf("Factura actualizada inesperada: subtotal=%.2f cliente=%s", factura.Subtotal, factura.ClienteNombre)
+	}
+
+	guardados, err := db.ObtenerProductosPorFactura(context.Background(), id)
+	if err != nil {
+		t.Fatalf("ObtenerProductosPorFactura() error: %v", err)
+	}
+	if len(guardados) != 1 || guardados[0].PrecioTotalSinIva != 30 {
+		t.Errorf("Productos guardados inesperados: %+v", guardados)
+	}
+}
@@ -0,0 +1,549 @@
+// Package database administra los emisores (tenants) que comparten la instalación y aísla sus datos
+package database
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-facturacion-sri/config"
+)
+
+// TenantPredeterminado emisor definido en config.Config; dueño de los datos previos a los tenants
+const TenantPredeterminado = 0
+
+// Regímenes tributarios del emisor
+const (
+	RegimenGeneral             = "GENERAL"
+	RegimenRimpeEmprendedor    = "RIMPE_EMPRENDEDOR"
+	RegimenRimpeNegocioPopular = "RIMPE_NEGOCIO_POPULAR"
+)
+
+// prefijoCredencialTenant inicio de las claves de API emitidas para los tenants
+const prefijoCredencialTenant = "fsri_"
+
+var (
+	// ErrTenantNoEncontrado se retorna cuando el tenant no existe o está inactivo
+	ErrTenantNoEncontrado = errors.New("tenant no encontrado")
+	// ErrTenantInvalido se retorna cuando los datos del emisor no son válidos
+	ErrTenantInvalido = errors.New("datos de tenant inválidos")
+	// ErrCredencialInvalida se retorna cuando la clave de API no existe, fue revocada o su tenant está inactivo
+	ErrCredencialInvalida = errors.New("credencial de API inválida")
+	// ErrEstablecimientoNoEncontrado se retorna cuando el tenant no tiene el establecimiento y punto de emisión
+	ErrEstablecimientoNoEncontrado = errors.New("establecimiento no encontrado")
+)
+
+var (
+	patronRUC          = regexp.MustCompile(`^\d{10}001$`)
+	patronPuntoEmision = regexp.MustCompile(`^\d{3}$`)
+)
+
+// TenantDB emisor con su propio RUC, perfil tributario, certificado, ambiente y establecimientos
+type TenantDB struct {
+	ID                    int                        `json:"id"`
+	RUC                   string                     `json:"ruc"`
+	RazonSocial           string                     `json:"razonSocial"`
+	NombreComercial       string                     `json:"nombreComercial"`
+	DireccionMatriz       string                     `json:"direccionMatriz"`
+	ObligadoContabilidad  bool                       `json:"obligadoContabilidad"`
+	ContribuyenteEspecial string                     `json:"contribuyenteEspecial"` // Número de resolución; vacío si no aplica
+	Regimen               string                     `json:"regimen"`               // GENERAL, RIMPE_EMPRENDEDOR, RIMPE_NEGOCIO_POPULAR
+	Ambiente              string                     `json:"ambiente"`              // "1" = pruebas, "2" = producción
+	TipoEmision           string                     `json:"tipoEmision"`           // "1" = normal
+	CertificadoRuta       string                     `json:"certificadoRuta"`
+	CertificadoPassword   string                     `json:"certificadoPassword,omitempty"` // Acepta referencias a secretos
+	Activo                bool                       `json:"activo"`
+	FechaCreacion         time.Time                  `json:"fechaCreacion"`
+	Establecimientos      []*EstablecimientoTenantDB `json:"establecimientos"`
+}
+
+// EstablecimientoTenantDB punto de emisión de un tenant con su último secuencial emitido
+type EstablecimientoTenantDB struct {
+	ID           int    `json:"id"`
+	TenantID     int    `json:"tenantId"`
+	Codigo       string `json:"codigo"`       // Establecimiento, 3 dígitos
+	PuntoEmision string `json:"puntoEmision"` // 3 dígitos
+	Direccion    string `json:"direccion"`
+	Secuencial   int    `json:"secuencial"`
+	Activo       bool   `json:"activo"`
+}
+
+// CredencialTenantDB clave de API de un tenant; solo se guarda el SHA-256 de la clave
+type CredencialTenantDB struct {
+	ID              int        `json:"id"`
+	TenantID        int        `json:"tenantId"`
+	Nombre          string     `json:"nombre"`
+	Prefijo         string     `json:"prefijo"` // Inicio de la clave, para identificarla sin revelarla
+	Activa          bool       `json:"activa"`
+	FechaCreacion   time.Time  `json:"fechaCreacion"`
+	FechaRevocacion *time.Time `json:"fechaRevocacion,omitempty"`
+}
+
+// claveTenant clave privada del tenant en context.Context
+type claveTenant struct{}
+
+// ConTenant retorna un contexto cuyas consultas y escrituras quedan limitadas al tenant
+func ConTenant(ctx context.Context, tenantID int) context.Context {
+	return context.WithValue(ctx, claveTenant{}, tenantID)
+}
+
+// TenantDe obtiene el tenant del contexto. Sin tenant (procesos internos como el pipeline o la
+// CLI) las consultas no se filtran.
+func TenantDe(ctx context.Context) (int, bool) {
+	if ctx == nil {
+		return 0, false
+	}
+	tenantID, ok := ctx.Value(claveTenant{}).(int)
+	return tenantID, ok
+}
+
+// tenantEscritura tenant dueño de las filas creadas con el contexto
+func tenantEscritura(ctx context.Context) int {
+	if tenantID, ok := TenantDe(ctx); ok {
+		return tenantID
+	}
+	return TenantPredeterminado
+}
+
+// filtroTenant condición que limita una consulta al tenant del contexto; vacía sin tenant
+func filtroTenant(ctx context.Context, columna string) (string, []interface{}) {
+	tenantID, ok := TenantDe(ctx)
+	if !ok {
+		return "", nil
+	}
+	return " AND " + columna + " = ?", []interface{}{tenantID}
+}
+
+// filtroFacturaTenant limita una consulta sobre una tabla hija de facturas a las facturas del
+// tenant del contexto
+func filtroFacturaTenant(ctx context.Context, columna string) (string, []interface{}) {
+	tenantID, ok := TenantDe(ctx)
+	if !ok {
+		return "", nil
+	}
+	return " AND " + columna + " IN (SELECT id FROM facturas WHERE tenant_id = ?)", []interface{}{tenantID}
+}
+
+// hashCredencial SHA-256 de una clave de API; las claves son aleatorias, no necesitan sal
+func hashCredencial(clave string) string {
+	suma := sha256.Sum256([]byte(clave))
+	return hex.EncodeToString(suma[:])
+}
+
+// validar completa los valores por defecto del tenant y verifica sus datos de emisor
+func (t *TenantDB) validar() error {
+	t.RUC = strings.TrimSpace(t.RUC)
+	t.RazonSocial = strings.TrimSpace(t.RazonSocial)
+	if t.Regimen == "" {
+		t.Regimen = RegimenGeneral
+	}
+	if t.Ambiente == "" {
+		t.Ambiente = "1"
+	}
+	if t.TipoEmision == "" {
+		t.TipoEmision = "1"
+	}
+
+	switch {
+	case !patronRUC.MatchString(t.RUC):
+		return fmt.Errorf("%w: RUC %q (debe tener 13 dígitos y terminar en 001)", ErrTenantInvalido, t.RUC)
+	case t.RazonSocial == "":
+		return fmt.Errorf("%w: la razón social es requerida", ErrTenantInvalido)
+	case strings.TrimSpace(t.DireccionMatriz) == "":
+		return fmt.Errorf("%w: la dirección matriz es requerida", ErrTenantInvalido)
+	case t.Ambiente != "1" && t.Ambiente != "2":
+		return fmt.Errorf("%w: ambiente %q (1 = pruebas, 2 = producción)", ErrTenantInvalido, t.Ambiente)
+	case t.TipoEmision != "1":
+		return fmt.Errorf("%w: tipo de emisión %q (solo 1 = normal)", ErrTenantInvalido, t.TipoEmision)
+	case t.Regimen != RegimenGeneral && t.Regimen != RegimenRimpeEmprendedor && t.Regimen != RegimenRimpeNegocioPopular:
+		return fmt.Errorf("%w: régimen desconocido %s", ErrTenantInvalido, t.Regimen)
+	case len(t.Establecimientos) == 0:
+		return fmt.Errorf("%w: se requiere al menos un establecimiento", ErrTenantInvalido)
+	}
+
+	for _, establecimiento := range t.Establecimientos {
+		if !patronEstablecimiento.MatchString(establecimiento.Codigo) || !patronPuntoEmision.MatchString(establecimiento.PuntoEmision) {
+			return fmt.Errorf("%w: establecimiento %q y punto de emisión %q deben tener 3 dígitos",
+				ErrTenantInvalido, establecimiento.Codigo, establecimiento.PuntoEmision)
+		}
+		if strings.TrimSpace(establecimiento.Direccion) == "" {
+			establecimiento.Direccion = t.DireccionMatriz
+		}
+	}
+	return nil
+}
+
+// CrearTenant registra un emisor con sus establecimientos. El secuencial de cada
+// establecimiento es el último emitido: el siguiente comprobante usa secuencial + 1.
+func (d *Database) CrearTenant(ctx context.Context, tenant *TenantDB) (*TenantDB, error) {
+	if err := tenant.validar(); err != nil {
+		return nil, err
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error iniciando transacción: %v", err)
+	}
+	defer tx.Rollback()
+
+	var id int
+	err = tx.QueryRow(`
+		INSERT INTO tenants (
+			ruc, razon_social, nombre_comercial, direccion_matriz, obligado_contabilidad,
+			contribuyente_especial, regimen, ambiente, tipo_emision, certificado_ruta,
+			certificado_password, activo, fecha_creacion
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, TRUE, ?)
+		RETURNING id`,
+		tenant.RUC, tenant.RazonSocial, tenant.NombreComercial, tenant.DireccionMatriz, tenant.ObligadoContabilidad,
+		tenant.ContribuyenteEspecial, tenant.Regimen, tenant.Ambiente, tenant.TipoEmision, tenant.CertificadoRuta,
+		tenant.CertificadoPassword, time.Now().UTC()).Scan(&id)
+	if err != nil {
+		return nil, fmt.Errorf("error registrando tenant %s: %v", tenant.RUC, err)
+	}
+
+	for _, establecimiento := range tenant.Establecimientos {
+		_, err := tx.Exec(`
+			INSERT INTO establecimientos_tenant (tenant_id, codigo, punto_emision, direccion, secuencial, activo)
+			VALUES (?, ?, ?, ?, ?, TRUE)`,
+			id, establecimiento.Codigo, establecimiento.PuntoEmision, establecimiento.Direccion, establecimiento.Secuencial)
+		if err != nil {
+			return nil, fmt.Errorf("error registrando establecimiento %s-%s: %v", establecimiento.Codigo, establecimiento.PuntoEmision, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error confirmando transacción: %v", err)
+	}
+
+	creado, err := d.ObtenerTenant(id)
+	if err != nil {
+		return nil, err
+	}
+	d.auditar(ctx, "tenants", id, "CREATE", "", nil, creado.sinSecretos())
+	return creado, nil
+}
+
+// sinSecretos copia del tenant sin la referencia a la contraseña del certificado
+func (t *TenantDB) sinSecretos() *TenantDB {
+	copia := *t
+	copia.CertificadoPassword = ""
+	return &copia
+}
+
+// ObtenerTenant obtiene un tenant activo con sus establecimientos
+func (d *Database) ObtenerTenant(id int) (*TenantDB, error) {
+	tenant := &TenantDB{}
+	var nombreComercial, contribuyenteEspecial, certificadoRuta, certificadoPassword sql.NullString
+
+	err := d.db.QueryRow(`
+		SELECT id, ruc, razon_social, nombre_comercial, direccion_matriz, obligado_contabilidad,
+		       contribuyente_especial, regimen, ambiente, tipo_emision, certificado_ruta,
+		       certificado_password, activo, fecha_creacion
+		FROM tenants WHERE id = ? AND activo = TRUE`, id).Scan(
+		&tenant.ID, &tenant.RUC, &tenant.RazonSocial, &nombreComercial, &tenant.DireccionMatriz,
+		&tenant.ObligadoContabilidad, &contribuyenteEspecial, &tenant.Regimen, &tenant.Ambiente,
+		&tenant.TipoEmision, &certificadoRuta, &certificadoPassword, &tenant.Activo, &tenant.FechaCreacion)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: ID %d", ErrTenantNoEncontrado, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error obteniendo tenant: %v", err)
+	}
+	tenant.NombreComercial = nombreComercial.String
+	tenant.ContribuyenteEspecial = contribuyenteEspecial.String
+	tenant.CertificadoRuta = certificadoRuta.String
+	tenant.CertificadoPassword = certificadoPassword.String
+
+	rows, err := d.db.Query(`
+		SELECT id, tenant_id, codigo, punto_emision, direccion, secuencial, activo
+		FROM establecimientos_tenant
+		WHERE tenant_id = ?
+		ORDER BY id`, id)
+	if err != nil {
+		return nil, fmt.Errorf("error obteniendo establecimientos del tenant: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		establecimiento := &EstablecimientoTenantDB{}
+		err := rows.Scan(&establecimiento.ID, &establecimiento.TenantID, &establecimiento.Codigo,
+			&establecimiento.PuntoEmision, &establecimiento.Direccion, &establecimiento.Secuencial, &establecimiento.Activo)
+		if err != nil {
+			return nil, fmt.Errorf("error escaneando establecimiento: %v", err)
+		}
+		tenant.Establecimientos = append(tenant.Establecimientos, establecimiento)
+	}
+	return tenant, rows.Err()
+}
+
+// ListarTenants retorna los tenants activos ordenados por ID
+func (d *Database) ListarTenants() ([]*TenantDB, error) {
+	rows, err := d.db.Query("SELECT id FROM tenants WHERE activo = TRUE ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("error listando tenants: %v", err)
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("error escaneando tenant: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error listando tenants: %v", err)
+	}
+
+	tenants := make([]*TenantDB, 0, len(ids))
+	for _, id := range ids {
+		tenant, err := d.ObtenerTenant(id)
+		if err != nil {
+			return nil, err
+		}
+		tenants = append(tenants, tenant)
+	}
+	return tenants, nil
+}
+
+// ExistenTenants indica si el despliegue tiene tenants registrados, activos o no
+func (d *Database) ExistenTenants() (bool, error) {
+	var existen bool
+	if err := d.db.QueryRow("SELECT EXISTS (SELECT 1 FROM tenants)").Scan(&existen); err != nil {
+		return false, fmt.Errorf("error consultando tenants: %v", err)
+	}
+	return existen, nil
+}
+
+// EmitirCredencialTenant genera una clave de API para el tenant. La clave solo se retorna aquí:
+// la base de datos guarda su hash.
+func (d *Database) EmitirCredencialTenant(ctx context.Context, tenantID int, nombre string) (string, *CredencialTenantDB, error) {
+	nombre = strings.TrimSpace(nombre)
+	if nombre == "" {
+		return "", nil, fmt.Errorf("el nombre de la credencial es requerido")
+	}
+	if _, err := d.ObtenerTenant(tenantID); err != nil {
+		return "", nil, err
+	}
+
+	aleatorio := make([]byte, 24)
+	if _, err := rand.Read(aleatorio); err != nil {
+		return "", nil, fmt.Errorf("error generando clave de API: %v", err)
+	}
+	clave := prefijoCredencialTenant + hex.EncodeToString(aleatorio)
+
+	credencial := &CredencialTenantDB{
+		TenantID:      tenantID,
+		Nombre:        nombre,
+		Prefijo:       clave[:len(prefijoCredencialTenant)+8],
+		Activa:        true,
+		FechaCreacion: time.Now().UTC(),
+	}
+	err := d.db.QueryRow(`
+		INSERT INTO credenciales_tenant (tenant_id, nombre, prefijo, hash_clave, activa, fecha_creacion)
+		VALUES (?, ?, ?, ?, TRUE, ?)
+		RETURNING id`,
+		tenantID, credencial.Nombre, credencial.Prefijo, hashCredencial(clave), credencial.FechaCreacion).Scan(&credencial.ID)
+	if err != nil {
+		return "", nil, fmt.Errorf("error registrando credencial: %v", err)
+	}
+
+	d.auditar(ctx, "credenciales_tenant", credencial.ID, "CREATE", "", nil, credencial)
+	return clave, credencial, nil
+}
+
+// RevocarCredencialTenant desactiva una clave de API; las peticiones con ella se rechazan
+func (d *Database) RevocarCredencialTenant(ctx context.Context, id int) error {
+	resultado, err := d.db.Exec("UPDATE credenciales_tenant SET activa = FALSE, fecha_revocacion = ? WHERE id = ? AND activa = TRUE",
+		time.Now().UTC(), id)
+	if err != nil {
+		return fmt.Errorf("error revocando credencial: %v", err)
+	}
+	if revocadas, err := resultado.RowsAffected(); err == nil && revocadas == 0 {
+		return fmt.Errorf("credencial %d no encontrada o ya revocada", id)
+	}
+
+	d.auditar(ctx, "credenciales_tenant", id, "REVOKE", "", nil, map[string]interface{}{"id": id, "activa": false})
+	return nil
+}
+
+// ResolverCredencialTenant identifica el tenant de una clave de API activa
+func (d *Database) ResolverCredencialTenant(clave string) (*CredencialTenantDB, *TenantDB, error) {
+	if !strings.HasPrefix(clave, prefijoCredencialTenant) {
+		return nil, nil, ErrCredencialInvalida
+	}
+
+	credencial := &CredencialTenantDB{}
+	err := d.db.QueryRow(`
+		SELECT c.id, c.tenant_id, c.nombre, c.prefijo, c.activa, c.fecha_creacion
+		FROM credenciales_tenant c
+		JOIN tenants t ON t.id = c.tenant_id
+		WHERE c.hash_clave = ? AND c.activa = TRUE AND t.activo = TRUE`, hashCredencial(clave)).Scan(
+		&credencial.ID, &credencial.TenantID, &credencial.Nombre, &credencial.Prefijo, &credencial.Activa, &credencial.FechaCreacion)
+	if err == sql.ErrNoRows {
+		return nil, nil, ErrCredencialInvalida
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("error verificando credencial: %v", err)
+	}
+
+	tenant, err := d.ObtenerTenant(credencial.TenantID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return credencial, tenant, nil
+}
+
+// ReservarSecuencialTenant incrementa y retorna el secuencial del establecimiento y punto de
+// emisión del tenant del contexto. Un comprobante que falla después de reservar deja un salto
+// en la numeración.
+func (d *Database) ReservarSecuencialTenant(ctx context.Context, codigo, puntoEmision string) (*EstablecimientoTenantDB, error) {
+	tenantID, ok := TenantDe(ctx)
+	if !ok || tenantID == TenantPredeterminado {
+		return nil, fmt.Errorf("%w: el contexto no tiene un tenant registrado", ErrTenantNoEncontrado)
+	}
+
+	establecimiento := &EstablecimientoTenantDB{}
+	err := d.db.QueryRow(`
+		UPDATE establecimientos_tenant
+		SET secuencial = secuencial + 1
+		WHERE tenant_id = ? AND codigo = ? AND punto_emision = ? AND activo = TRUE
+		RETURNING id, tenant_id, codigo, punto_emision, direccion, secuencial, activo`,
+		tenantID, codigo, puntoEmision).Scan(&establecimiento.ID, &establecimiento.TenantID, &establecimiento.Codigo,
+		&establecimiento.PuntoEmision, &establecimiento.Direccion, &establecimiento.Secuencial, &establecimiento.Activo)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: %s-%s", ErrEstablecimientoNoEncontrado, codigo, puntoEmision)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reservando secuencial: %v", err)
+	}
+	if establecimiento.Secuencial > 999999999 {
+		return nil, fmt.Errorf("el establecimiento %s-%s agotó los secuenciales", codigo, puntoEmision)
+	}
+	return establecimiento, nil
+}
+
+// ReservarSecuencialEmisor incrementa y retorna el secuencial del emisor de la configuración para
+// su RUC, establecimiento y punto de emisión. La primera reserva continúa después del mayor
+// secuencial de las claves de acceso ya guardadas con ese RUC y serie. Igual que con los tenants,
+// un comprobante que falla después de reservar deja un salto en la numeración.
+func (d *Database) ReservarSecuencialEmisor(ctx context.Context, ruc, establecimiento, puntoEmision string) (int, error) {
+	if tenantID, ok := TenantDe(ctx); ok && tenantID != TenantPredeterminado {
+		return 0, fmt.Errorf("el tenant %d numera con los secuenciales de sus establecimientos", tenantID)
+	}
+
+	// Clave de acceso: fecha (1-8), tipo (9-10), RUC (11-23), ambiente (24), serie (25-30), secuencial (31-39)
+	var secuencial int
+	err := d.db.QueryRow(`
+		INSERT INTO secuenciales_emisor (ruc, establecimiento, punto_emision, secuencial)
+		VALUES (?, ?, ?, 1 + (
+			SELECT COALESCE(MAX(CAST(SUBSTR(clave_acceso, 31, 9) AS INTEGER)), 0)
+			FROM facturas
+			WHERE tenant_id = ? AND LENGTH(clave_acceso) = 49
+			  AND SUBSTR(clave_acceso, 11, 13) = ? AND SUBSTR(clave_acceso, 25, 6) = ?))
+		ON CONFLICT (ruc, establecimiento, punto_emision)
+		DO UPDATE SET secuencial = secuenciales_emisor.secuencial + 1
+		RETURNING secuencial`,
+		ruc, establecimiento, puntoEmision,
+		TenantPredeterminado, ruc, establecimiento+puntoEmision).Scan(&secuencial)
+	if err != nil {
+		return 0, fmt.Errorf("error reservando secuencial: %v", err)
+	}
+	if secuencial > 999999999 {
+		return 0, fmt.Errorf("el establecimiento %s-%s agotó los secuenciales", establecimiento, puntoEmision)
+	}
+	return secuencial, nil
+}
+
+// EjecutarCLITenants administra tenants y sus credenciales de API desde la línea de comandos
+func EjecutarCLITenants(args []string, cfg config.DatabaseConfig) error {
+	if len(args) == 0 {
+		return fmt.Errorf("uso: tenants [listar | crear <archivo.json> | credencial <tenantId> <nombre> | revocar <credencialId>]")
+	}
+
+	d, err := NuevaDesdeConfig(cfg)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	ctx := ConActorAuditoria(context.Background(), ActorAuditoria{Usuario: "cli"})
+
+	switch args[0] {
+	case "listar":
+		tenants, err := d.ListarTenants()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("🏢 Tenants registrados: %d\n", len(tenants))
+		for _, tenant := range tenants {
+			fmt.Printf("   %3d  %s  %-40s ambiente %s\n", tenant.ID, tenant.RUC, tenant.RazonSocial, tenant.Ambiente)
+			for _, establecimiento := range tenant.Establecimientos {
+				fmt.Printf("        %s-%s  secuencial %09d\n", establecimiento.Codigo, establecimiento.PuntoEmision, establecimiento.Secuencial)
+			}
+		}
+
+	case "crear":
+		if len(args) < 2 {
+			return fmt.Errorf("uso: tenants crear <archivo.json>")
+		}
+		contenido, err := os.ReadFile(args[1])
+		if err != nil {
+			return fmt.Errorf("error leyendo %s: %v", args[1], err)
+		}
+		var tenant TenantDB
+		if err := json.Unmarshal(contenido, &tenant); err != nil {
+			return fmt.Errorf("error parseando %s: %v", args[1], err)
+		}
+		creado, err := d.CrearTenant(ctx, &tenant)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("✅ Tenant %d creado: %s (%s)\n", creado.ID, creado.RazonSocial, creado.RUC)
+		fmt.Printf("💡 Emita una credencial de API con: tenants credencial %d <nombre>\n", creado.ID)
+
+	case "credencial":
+		if len(args) < 3 {
+			return fmt.Errorf("uso: tenants credencial <tenantId> <nombre>")
+		}
+		tenantID, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("ID de tenant inválido: %s", args[1])
+		}
+		clave, credencial, err := d.EmitirCredencialTenant(ctx, tenantID, args[2])
+		if err != nil {
+			return err
+		}
+		fmt.Printf("🔑 Credencial %d (%s) emitida para el tenant %d\n", credencial.ID, credencial.Nombre, tenantID)
+		fmt.Printf("   %s\n", clave)
+		fmt.Println("⚠️  Guárdela ahora: no se puede volver a mostrar")
+
+	case "revocar":
+		if len(args) < 2 {
+			return fmt.Errorf("uso: tenants revocar <credencialId>")
+		}
+		id, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("ID de credencial inválido: %s", args[1])
+		}
+		if err := d.RevocarCredencialTenant(ctx, id); err != nil {
+			return err
+		}
+		fmt.Printf("🚫 Credencial %d revocada\n", id)
+
+	default:
+		return fmt.Errorf("subcomando desconocido: %s", args[0])
+	}
+	return nil
+}
@@ -0,0 +1,450 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"go-facturacion-sri/factory"
+	"go-facturacion-sri/models"
+)
+
+// tenantPrueba registra un tenant con un establecimiento 001-001
+func tenantPrueba(t *testing.T, db *Database, ruc, razonSocial string) *TenantDB {
+	t.Helper()
+
+	tenant, err := db.CrearTenant(context.Background(), &TenantDB{
+		RUC:             ruc,
+		RazonSocial:     razonSocial,
+		DireccionMatriz: "Av. Amazonas N24-03, Quito",
+		Establecimientos: []*EstablecimientoTenantDB{
+			{Codigo: "001", PuntoEmision: "001"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("CrearTenant(%s) error: %v", ruc, err)
+	}
+	return tenant
+}
+
+// facturaTenantPrueba guarda una factura en BORRADOR con el contexto del tenant
+func facturaTenantPrueba(t *testing.T, db *Database, ctx context.Context, clave, cedula, descripcion string) *FacturaDB {
+	t.Helper()
+	setupTestConfig()
+
+	productos := []models.ProductoInput{
+		{Codigo: "TEN001", Descripcion: descripcion, Cantidad: 1, PrecioUnitario: 20},
+	}
+	factura, err := factory.CrearFactura(models.FacturaInput{
+		ClienteNombre: "CLIENTE " + descripcion,
+		ClienteCedula: cedula,
+		Productos:     productos,
+	})
+	if err != nil {
+		t.Fatalf("CrearFactura() error: %v", err)
+	}
+
+	facturaDB, err := db.GuardarFactura(ctx, factura, clave, productos)
+	if err != nil {
+		t.Fatalf("GuardarFactura() error: %v", err)
+	}
+	return facturaDB
+}
+
+func TestCrearTenantValidaEmisor(t *testing.T) {
+	db := nuevaDBTrabajosPrueba(t)
+
+	invalidos := []*TenantDB{
+		{RUC: "179214673900", RazonSocial: "RUC CORTO", DireccionMatriz: "Quito", Establecimientos: []*EstablecimientoTenantDB{{Codigo: "001", PuntoEmision: "001"}}},
+		{RUC: "1792146739001", DireccionMatriz: "Quito", Establecimientos: []*EstablecimientoTenantDB{{Codigo: "001", PuntoEmision: "001"}}},
+		{RUC: "1792146739001", RazonSocial: "SIN ESTABLECIMIENTOS", DireccionMatriz: "Quito"},
+		{RUC: "1792146739001", RazonSocial: "AMBIENTE", DireccionMatriz: "Quito", Ambiente: "3", Establecimientos: []*EstablecimientoTenantDB{{Codigo: "001", PuntoEmision: "001"}}},
+		{RUC: "1792146739001", RazonSocial: "REGIMEN", DireccionMatriz: "Quito", Regimen: "OTRO", Establecimientos: []*EstablecimientoTenantDB{{Codigo: "001", PuntoEmision: "001"}}},
+		{RUC: "1792146739001", RazonSocial: "SERIE", DireccionMatriz: "Quito", Establecimientos: []*EstablecimientoTenantDB{{Codigo: "1", PuntoEmision: "001"}}},
+	}
+	for _, tenant := range invalidos {
+		if _, err := db.CrearTenant(context.Background(), tenant); !errors.Is(err, ErrTenantInvalido) {
+			t.Errorf("CrearTenant(%s) = %v, esperado ErrTenantInvalido", tenant.RazonSocial, err)
+		}
+	}
+
+	tenant := tenantPrueba(t, db, "1792146739001", "EMPRESA UNO S.A.")
+	if tenant.Ambiente != "1" || tenant.TipoEmision != "1" || tenant.Regimen != RegimenGeneral || !tenant.Activo {
+		t.Errorf("Valores por defecto inesperados: %+v", tenant)
+	}
+	if len(tenant.Establecimientos) != 1 || tenant.Establecimientos[0].Direccion != tenant.DireccionMatriz {
+		t.Errorf("Establecimientos inesperados: %+v", tenant.Establecimientos)
+	}
+
+	// El RUC es único entre tenants
+	if _, err := db.CrearTenant(context.Background(), &TenantDB{
+		RUC: "1792146739001", RazonSocial: "DUPLICADO", DireccionMatriz: "Quito",
+		Establecimientos: []*EstablecimientoTenantDB{{Codigo: "001", PuntoEmision: "001"}},
+	}); err == nil {
+		t.Error("Se esperaba error al registrar un RUC duplicado")
+	}
+}
+
+func TestCredencialesTenant(t *testing.T) {
+	db := nuevaDBTrabajosPrueba(t)
+	tenant := tenantPrueba(t, db, "1792146739001", "EMPRESA UNO S.A.")
+
+	if _, _, err := db.EmitirCredencialTenant(context.Background(), 999, "integracion"); !errors.Is(err, ErrTenantNoEncontrado) {
+		t.Errorf("EmitirCredencialTenant() de tenant inexistente = %v, esperado ErrTenantNoEncontrado", err)
+	}
+
+	clave, credencial, err := db.EmitirCredencialTenant(context.Background(), tenant.ID, "integracion")
+	if err != nil {
+		t.Fatalf("EmitirCredencialTenant() error: %v", err)
+	}
+	if !strings.HasPrefix(clave, prefijoCredencialTenant) || !strings.HasPrefix(clave, credencial.Prefijo) || !credencial.Activa {
+		t.Errorf("Credencial inesperada: clave=%s %+v", clave, credencial)
+	}
+
+	resuelta, resuelto, err := db.ResolverCredencialTenant(clave)
+	if err != nil || resuelta.ID != credencial.ID || resuelto.ID != tenant.ID || resuelto.RUC != tenant.RUC {
+		t.Fatalf("ResolverCredencialTenant() = %+v, %+v, %v", resuelta, resuelto, err)
+	}
+
+	for _, incorrecta := range []string{"", "otra-clave", clave + "0", prefijoCredencialTenant + strings.Repeat("0", 48)} {
+		if _, _, err := db.ResolverCredencialTenant(incorrecta); !errors.Is(err, ErrCredencialInvalida) {
+			t.Errorf("ResolverCredencialTenant(%q) = %v, esperado ErrCredencialInvalida", incorrecta, err)
+		}
+	}
+
+	if err := db.RevocarCredencialTenant(context.Background(), credencial.ID); err != nil {
+		t.Fatalf("RevocarCredencialTenant() error: %v", err)
+	}
+	if _, _, err := db.ResolverCredencialTenant(clave); !errors.Is(err, ErrCredencialInvalida) {
+		t.Errorf("Credencial revocada resuelta: %v", err)
+	}
+	if err := db.RevocarCredencialTenant(context.Background(), credencial.ID); err == nil {
+		t.Error("Se esperaba error al revocar dos veces la misma credencial")
+	}
+}
+
+func TestReservarSecuencialTenant(t *testing.T) {
+	db := nuevaDBTrabajosPrueba(t)
+	uno := tenantPrueba(t, db, "1792146739001", "EMPRESA UNO S.A.")
+	dos := tenantPrueba(t, db, "0990000000001", "EMPRESA DOS S.A.")
+	ctxUno := ConTenant(context.Background(), uno.ID)
+
+	if _, err := db.ReservarSecuencialTenant(context.Background(), "001", "001"); !errors.Is(err, ErrTenantNoEncontrado) {
+		t.Errorf("Reserva sin tenant = %v, esperado ErrTenantNoEncontrado", err)
+	}
+	if _, err := db.ReservarSecuencialTenant(ctxUno, "002", "001"); !errors.Is(err, ErrEstablecimientoNoEncontrado) {
+		t.Errorf("Reserva de establecimiento inexistente = %v, esperado ErrEstablecimientoNoEncontrado", err)
+	}
+
+	for esperado := 1; esperado <= 3; esperado++ {
+		establecimiento, err := db.ReservarSecuencialTenant(ctxUno, "001", "001")
+		if err != nil || establecimiento.Secuencial != esperado || establecimiento.TenantID != uno.ID {
+			t.Fatalf("Reserva %d = %+v, %v", esperado, establecimiento, err)
+		}
+	}
+
+	// La numeración de cada tenant es independiente
+	establecimiento, err := db.ReservarSecuencialTenant(ConTenant(context.Background(), dos.ID), "001", "001")
+	if err != nil || establecimiento.Secuencial != 1 {
+		t.Errorf("Primera reserva del segundo tenant = %+v, %v", establecimiento, err)
+	}
+}
+
+func TestReservarSecuencialEmisor(t *testing.T) {
+	db := nuevaDBTrabajosPrueba(t)
+	ctx := context.Background()
+
+	// Facturas ya emitidas con el RUC y la serie 001-001: la numeración continúa después de ellas
+	clave := "19102026" + "01" + "1792146739001" + "1" + "001001" + "000000041" + "12345678" + "1" + "7"
+	facturaTenantPrueba(t, db, ctx, clave, "1713175071", "PREVIA")
+
+	for _, esperado := range []int{42, 43} {
+		secuencial, err := db.ReservarSecuencialEmisor(ctx, "1792146739001", "001", "001")
+		if err != nil || secuencial != esperado {
+			t.Fatalf("ReservarSecuencialEmisor() = %d, %v; esperado %d", secuencial, err, esperado)
+		}
+	}
+
+	// Cada punto de emisión y cada RUC tiene su propia numeración
+	if secuencial, err := db.ReservarSecuencialEmisor(ctx, "1792146739001", "001", "002"); err != nil || secuencial != 1 {
+		t.Errorf("Primera reserva del punto 001-002 = %d, %v", secuencial, err)
+	}
+	if secuencial, err := db.ReservarSecuencialEmisor(ctx, "0990000000001", "001", "001"); err != nil || secuencial != 1 {
+		t.Errorf("Primera reserva de otro RUC = %d, %v", secuencial, err)
+	}
+
+	tenant := tenantPrueba(t, db, "0991234567001", "EMPRESA TENANT S.A.")
+	if _, err := db.ReservarSecuencialEmisor(ConTenant(ctx, tenant.ID), "1792146739001", "001", "001"); err == nil {
+		t.Error("Un tenant registrado no debe usar los secuenciales del emisor de la configuración")
+	}
+}
+
+func TestAislamientoFacturasEntreTenants(t *testing.T) {
+	db := nuevaDBTrabajosPrueba(t)
+	uno := tenantPrueba(t, db, "1792146739001", "EMPRESA UNO S.A.")
+	dos := tenantPrueba(t, db, "0990000000001", "EMPRESA DOS S.A.")
+	ctxUno := ConTenant(context.Background(), uno.ID)
+	ctxDos := ConTenant(context.Background(), dos.ID)
+
+	facturaUno := facturaTenantPrueba(t, db, ctxUno, "CLAVE-TENANT-UNO", "1713175071", "MANZANAS")
+	facturaDos := facturaTenantPrueba(t, db, ctxDos, "CLAVE-TENANT-DOS", "1713175071", "PERAS")
+
+	// Cada tenant numera sus facturas desde el inicio
+	if facturaUno.NumeroFactura != "FAC-000001" || facturaDos.NumeroFactura != "FAC-000001" {
+		t.Errorf("Numeración por tenant inesperada: %s y %s", facturaUno.NumeroFactura, facturaDos.NumeroFactura)
+	}
+	if facturaUno.TenantID != uno.ID || facturaDos.TenantID != dos.ID {
+		t.Errorf("Tenant de las facturas inesperado: %d y %d", facturaUno.TenantID, facturaDos.TenantID)
+	}
+
+	// Lecturas de la factura de otro tenant
+	if _, err := db.ObtenerFacturaPorID(ctxDos, facturaUno.ID); err == nil {
+		t.Error("ObtenerFacturaPorID() retornó la factura de otro tenant")
+	}
+	if _, err := db.ObtenerFacturaPorNumero(ctxDos, facturaDos.NumeroFactura); err != nil {
+		t.Errorf("ObtenerFacturaPorNumero() de la factura propia error: %v", err)
+	}
+	if productos, err := db.ObtenerProductosPorFactura(ctxDos, facturaUno.ID); err != nil || len(productos) != 0 {
+		t.Errorf("ObtenerProductosPorFactura() de otro tenant = %d productos, %v", len(productos), err)
+	}
+	facturas, total, err := db.BuscarFacturas(ctxUno, FiltroFacturas{Limite: 10})
+	if err != nil || total != 1 || len(facturas) != 1 || facturas[0].ID != facturaUno.ID {
+		t.Errorf("BuscarFacturas() = %d facturas (total %d), %v", len(facturas), total, err)
+	}
+	if facturas, err := db.ListarFacturasPorCliente(ctxDos, "1713175071", 10, 0); err != nil || len(facturas) != 1 || facturas[0].ID != facturaDos.ID {
+		t.Errorf("ListarFacturasPorCliente() = %d facturas, %v", len(facturas), err)
+	}
+	if stats, err := db.EstadisticasFacturas(ctxUno); err != nil || stats["total_facturas"] != 1 {
+		t.Errorf("EstadisticasFacturas() = %v, %v", stats, err)
+	}
+
+	// Escrituras sobre la factura de otro tenant
+	if err := db.TransicionarEstadoFactura(ctxDos, facturaUno.ID, CambioEstadoFactura{Estado: EstadoFirmada}); err == nil {
+		t.Error("TransicionarEstadoFactura() cambió la factura de otro tenant")
+	}
+	productos := []ProductoDB{{Codigo: "X", Descripcion: "Intruso", Cantidad: 1, PrecioUnitario: 1}}
+	if _, err := db.ActualizarFactura(ctxDos, facturaUno.ID, "0912345675", "INTRUSO", productos, ""); err == nil {
+		t.Error("ActualizarFactura() modificó la factura de otro tenant")
+	}
+	if err := db.EliminarFactura(ctxDos, facturaUno.ID); err == nil {
+		t.Error("EliminarFactura() eliminó la factura de otro tenant")
+	}
+	if historial, err := db.ObtenerHistorialEstados(ctxDos, facturaUno.ID); err != nil || len(historial) != 0 {
+		t.Errorf("ObtenerHistorialEstados() de otro tenant = %d registros, %v", len(historial), err)
+	}
+
+	// La factura sigue intacta para su dueño y los procesos internos la ven sin contexto de tenant
+	intacta, err := db.ObtenerFacturaPorID(ctxUno, facturaUno.ID)
+	if err != nil || intacta.Estado != EstadoBorrador || intacta.ClienteNombre != "CLIENTE MANZANAS" {
+		t.Errorf("Factura tras intentos de otro tenant = %+v, %v", intacta, err)
+	}
+	if todas, err := db.ListarFacturas(context.Background(), 10, 0); err != nil || len(todas) != 2 {
+		t.Errorf("ListarFacturas() sin tenant = %d facturas, %v", len(todas), err)
+	}
+}
+
+func TestAislamientoAnulacionesEntreTenants(t *testing.T) {
+	db := nuevaDBTrabajosPrueba(t)
+	uno := tenantPrueba(t, db, "1792146739001", "EMPRESA UNO S.A.")
+	dos := tenantPrueba(t, db, "0990000000001", "EMPRESA DOS S.A.")
+	ctxUno := ConTenant(context.Background(), uno.ID)
+	ctxDos := ConTenant(context.Background(), dos.ID)
+
+	factura := facturaTenantPrueba(t, db, ctxUno, "CLAVE-ANULACION-UNO", "1713175071", "MANZANAS")
+	for _, estado := range []string{EstadoFirmada, EstadoEnviada, EstadoRecibida, EstadoAutorizada} {
+		if err := db.ActualizarEstadoFactura(ctxUno, factura.ID, estado, "AUT-1", "", ""); err != nil {
+			t.Fatalf("Error pasando factura a %s: %v", estado, err)
+		}
+	}
+
+	if _, err := db.SolicitarAnulacion(ctxDos, factura.ID, "Intento ajeno", "intruso"); err == nil {
+		t.Error("SolicitarAnulacion() aceptó la factura de otro tenant")
+	}
+	if _, err := db.SolicitarAnulacion(ctxUno, factura.ID, "Error en el valor", "contador"); err != nil {
+		t.Fatalf("SolicitarAnulacion() error: %v", err)
+	}
+	if _, err := db.ConfirmarAnulacion(ctxDos, factura.ID, "REF-1", "intruso"); err == nil {
+		t.Error("ConfirmarAnulacion() confirmó la anulación de otro tenant")
+	}
+	if _, err := db.RechazarAnulacion(ctxDos, factura.ID, "Intento ajeno", "intruso"); !errors.Is(err, ErrSinAnulacionPendiente) {
+		t.Errorf("RechazarAnulacion() de otro tenant = %v, esperado ErrSinAnulacionPendiente", err)
+	}
+	if anulaciones, err := db.ListarAnulaciones(ctxDos, factura.ID); err != nil || len(anulaciones) != 0 {
+		t.Errorf("ListarAnulaciones() de otro tenant = %d, %v", len(anulaciones), err)
+	}
+	if anulaciones, err := db.ListarAnulaciones(ctxUno, factura.ID); err != nil || len(anulaciones) != 1 || anulaciones[0].Estado != AnulacionSolicitada {
+		t.Errorf("ListarAnulaciones() propias = %+v, %v", anulaciones, err)
+	}
+}
+
+func TestAislamientoCatalogoEInventarioEntreTenants(t *testing.T) {
+	db := nuevaDBTrabajosPrueba(t)
+	uno := tenantPrueba(t, db, "1792146739001", "EMPRESA UNO S.A.")
+	dos := tenantPrueba(t, db, "0990000000001", "EMPRESA DOS S.A.")
+	ctxUno := ConTenant(context.Background(), uno.ID)
+	ctxDos := ConTenant(context.Background(), dos.ID)
+
+	// El mismo código principal puede existir en el catálogo de ambos tenants, no dos veces en uno
+	productos := map[context.Context]*ProductoCatalogoDB{}
+	for _, ctx := range []context.Context{ctxUno, ctxDos} {
+		producto, err := db.GuardarProductoCatalogo(ctx, &ProductoCatalogoDB{
+			CodigoPrincipal: "TEN001", Descripcion: "Producto con inventario", PrecioUnitario: 20, ControlaInventario: true,
+		})
+		if err != nil {
+			t.Fatalf("GuardarProductoCatalogo() error: %v", err)
+		}
+		productos[ctx] = producto
+	}
+	productoUno, productoDos := productos[ctxUno], productos[ctxDos]
+	if productoUno.TenantID != uno.ID || productoDos.TenantID != dos.ID {
+		t.Errorf("Tenant de los productos inesperado: %d y %d", productoUno.TenantID, productoDos.TenantID)
+	}
+	if _, err := db.GuardarProductoCatalogo(ctxUno, &ProductoCatalogoDB{CodigoPrincipal: "TEN001", Descripcion: "Duplicado", PrecioUnitario: 1}); err == nil {
+		t.Error("GuardarProductoCatalogo() aceptó un código duplicado en el mismo tenant")
+	}
+
+	// Lecturas y escrituras del catálogo de otro tenant
+	if _, err := db.ObtenerProductoCatalogo(ctxDos, productoUno.ID); err == nil {
+		t.Error("ObtenerProductoCatalogo() retornó el producto de otro tenant")
+	}
+	if encontrado, err := db.ObtenerProductoCatalogoPorCodigo(ctxDos, "TEN001"); err != nil || encontrado.ID != productoDos.ID {
+		t.Errorf("ObtenerProductoCatalogoPorCodigo() = %+v, %v", encontrado, err)
+	}
+	if lista, err := db.ListarProductosCatalogo(ctxDos, "", true, 10, 0); err != nil || len(lista) != 1 || lista[0].ID != productoDos.ID {
+		t.Errorf("ListarProductosCatalogo() = %d productos, %v", len(lista), err)
+	}
+	intruso := *productoUno
+	intruso.Descripcion = "Intruso"
+	if _, err := db.ActualizarProductoCatalogo(ctxDos, &intruso); err == nil {
+		t.Error("ActualizarProductoCatalogo() modificó el producto de otro tenant")
+	}
+	if err := db.DesactivarProductoCatalogo(ctxDos, productoUno.ID); err == nil {
+		t.Error("DesactivarProductoCatalogo() desactivó el producto de otro tenant")
+	}
+
+	// Stock del producto de otro tenant
+	if _, err := db.AjustarStock(ctxDos, AjusteInventario{ProductoID: productoUno.ID, Establecimiento: "001", Cantidad: 5, Motivo: "Intruso"}); err == nil {
+		t.Error("AjustarStock() ajustó el producto de otro tenant")
+	}
+	if _, err := db.ConfigurarStockMinimo(ctxDos, productoUno.ID, "001", 3); err == nil {
+		t.Error("ConfigurarStockMinimo() configuró el producto de otro tenant")
+	}
+	if _, err := db.AjustarStock(ctxUno, AjusteInventario{ProductoID: productoUno.ID, Establecimiento: "001", Cantidad: 5, Motivo: "Inventario inicial"}); err != nil {
+		t.Fatalf("AjustarStock() error: %v", err)
+	}
+
+	// La venta autorizada descuenta el producto del catálogo del tenant de la factura
+	factura := facturaTenantPrueba(t, db, ctxUno, "CLAVE-INVENTARIO-UNO", "1713175071", "MANZANAS")
+	for _, estado := range []string{EstadoFirmada, EstadoEnviada, EstadoRecibida, EstadoAutorizada} {
+		if err := db.ActualizarEstadoFactura(ctxUno, factura.ID, estado, "AUT-1", "", ""); err != nil {
+			t.Fatalf("Error pasando factura a %s: %v", estado, err)
+		}
+	}
+	if stock, err := db.ObtenerStock(ctxUno, productoUno.ID, "001"); err != nil || stock.Cantidad != 4 {
+		t.Errorf("Stock propio tras la venta = %+v, %v; esperado 4", stock, err)
+	}
+	if stock, err := db.ListarStock(ctxDos, "", 10, 0); err != nil || len(stock) != 0 {
+		t.Errorf("ListarStock() de otro tenant = %+v, %v", stock, err)
+	}
+	if _, err := db.ObtenerStock(ctxDos, productoUno.ID, "001"); err == nil {
+		t.Error("ObtenerStock() retornó el stock de otro tenant")
+	}
+	if movimientos, err := db.ListarMovimientosInventario(ctxDos, 0, "", 10, 0); err != nil || len(movimientos) != 0 {
+		t.Errorf("ListarMovimientosInventario() de otro tenant = %d, %v", len(movimientos), err)
+	}
+	movimientos, err := db.ListarMovimientosInventario(ctxUno, 0, "", 10, 0)
+	if err != nil || len(movimientos) != 2 || movimientos[0].Tipo != MovimientoVenta || movimientos[0].TenantID != uno.ID {
+		t.Errorf("ListarMovimientosInventario() propios = %+v, %v", movimientos, err)
+	}
+
+	if _, err := db.RegistrarNotaCreditoDevolucion(ctxDos, NotaCreditoDevolucion{
+		ClaveAcceso: clavePrueba("04", "001", "000000001"),
+		FacturaID:   factura.ID,
+		Lineas:      []LineaDevolucion{{Codigo: "TEN001", Cantidad: 1}},
+	}); err == nil {
+		t.Error("RegistrarNotaCreditoDevolucion() aceptó la factura de otro tenant")
+	}
+}
+
+func TestAislamientoClientesBusquedaYAuditoria(t *testing.T) {
+	db := nuevaDBTrabajosPrueba(t)
+	uno := tenantPrueba(t, db, "1792146739001", "EMPRESA UNO S.A.")
+	dos := tenantPrueba(t, db, "0990000000001", "EMPRESA DOS S.A.")
+	ctxUno := ConTenant(context.Background(), uno.ID)
+	ctxDos := ConTenant(context.Background(), dos.ID)
+
+	// La misma cédula puede ser cliente de ambos tenants
+	clienteUno, err := db.GuardarCliente(ctxUno, &ClienteDB{Cedula: "1713175071", Nombre: "Juan Pérez", TipoCliente: "PERSONA_NATURAL"})
+	if err != nil {
+		t.Fatalf("GuardarCliente() tenant uno error: %v", err)
+	}
+	clienteDos, err := db.GuardarCliente(ctxDos, &ClienteDB{Cedula: "1713175071", Nombre: "Juan P. Torres", TipoCliente: "PERSONA_NATURAL"})
+	if err != nil {
+		t.Fatalf("GuardarCliente() tenant dos error: %v", err)
+	}
+	if clienteUno.ID == clienteDos.ID || clienteUno.TenantID != uno.ID || clienteDos.TenantID != dos.ID {
+		t.Fatalf("Clientes por tenant inesperados: %+v y %+v", clienteUno, clienteDos)
+	}
+
+	// Guardar de nuevo la cédula actualiza solo el cliente del propio tenant
+	if _, err := db.GuardarCliente(ctxUno, &ClienteDB{Cedula: "1713175071", Nombre: "Juan Pérez Andrade", TipoCliente: "PERSONA_NATURAL"}); err != nil {
+		t.Fatalf("GuardarCliente() actualización error: %v", err)
+	}
+	if cliente, err := db.ObtenerClientePorCedula(ctxDos, "1713175071"); err != nil || cliente.Nombre != "Juan P. Torres" {
+		t.Errorf("Cliente del tenant dos modificado: %+v, %v", cliente, err)
+	}
+
+	if _, err := db.ObtenerClientePorID(ctxDos, clienteUno.ID); err == nil {
+		t.Error("ObtenerClientePorID() retornó el cliente de otro tenant")
+	}
+	if err := db.DesactivarCliente(ctxDos, clienteUno.ID); err == nil {
+		t.Error("DesactivarCliente() desactivó el cliente de otro tenant")
+	}
+	if err := db.EliminarCliente(ctxDos, clienteUno.ID); err == nil {
+		t.Error("EliminarCliente() eliminó el cliente de otro tenant")
+	}
+	if clientes, err := db.ListarClientes(ctxDos, "", "", 10, 0); err != nil || len(clientes) != 1 || clientes[0].ID != clienteDos.ID {
+		t.Errorf("ListarClientes() = %d clientes, %v", len(clientes), err)
+	}
+
+	// La búsqueda de texto solo encuentra clientes y líneas de factura propios
+	facturaTenantPrueba(t, db, ctxUno, "CLAVE-BUSQUEDA-UNO", "1713175071", "MANZANAS")
+	for _, texto := range []string{"Andrade", "manzanas"} {
+		if resultados, err := db.Buscar(ctxDos, texto, 10); err != nil || len(resultados) != 0 {
+			t.Errorf("Buscar(%q) desde otro tenant = %d resultados, %v", texto, len(resultados), err)
+		}
+		if resultados, err := db.Buscar(ctxUno, texto, 10); err != nil || len(resultados) == 0 {
+			t.Errorf("Buscar(%q) propio = %d resultados, %v", texto, len(resultados), err)
+		}
+	}
+
+	// Las entradas de auditoría pertenecen al tenant que originó el cambio
+	propias, err := db.ObtenerAuditoriaPorRegistro(ctxUno, "clientes", clienteUno.ID)
+	if err != nil || len(propias) != 2 {
+		t.Fatalf("ObtenerAuditoriaPorRegistro() propio = %d entradas, %v", len(propias), err)
+	}
+	for _, entrada := range propias {
+		if entrada.TenantID != uno.ID {
+			t.Errorf("Entrada de auditoría con tenant %d, esperado %d", entrada.TenantID, uno.ID)
+		}
+	}
+	if ajenas, err := db.ObtenerAuditoriaPorRegistro(ctxDos, "clientes", clienteUno.ID); err != nil || len(ajenas) != 0 {
+		t.Errorf("ObtenerAuditoriaPorRegistro() de otro tenant = %d entradas, %v", len(ajenas), err)
+	}
+	if ajenas, err := db.ObtenerAuditoriaPorTabla(ctxDos, "facturas", 10, 0); err != nil || len(ajenas) != 0 {
+		t.Errorf("ObtenerAuditoriaPorTabla() de otro tenant = %d entradas, %v", len(ajenas), err)
+	}
+
+	// El tenant forma parte del hash: la cadena sigue siendo verificable
+	verificacion, err := db.VerificarCadenaAuditoria()
+	if err != nil || !verificacion.Valida {
+		t.Errorf("VerificarCadenaAuditoria() = %+v, %v", verificacion, err)
+	}
+	if _, err := db.db.Exec("UPDATE audit_log SET tenant_id = ? WHERE id = ?", dos.ID, propias[0].ID); err != nil {
+		t.Fatalf("Error alterando auditoría: %v", err)
+	}
+	if verificacion, err := db.VerificarCadenaAuditoria(); err != nil || verificacion.Valida {
+		t.Errorf("Mover una entrada a otro tenant no rompió la cadena: %+v, %v", verificacion, err)
+	}
+}
@@ -0,0 +1,232 @@
+// Package database implementa la cola persistente de trabajos de autorización SRI
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"go-facturacion-sri/sri"
+)
+
+// Etapas de un trabajo de autorización
+const (
+	EtapaFirmar    = "FIRMAR"
+	EtapaEnviar    = "ENVIAR"
+	EtapaAutorizar = "AUTORIZAR"
+)
+
+// Estados de un trabajo de autorización
+const (
+	TrabajoPendiente  = "PENDIENTE"
+	TrabajoEnCurso    = "EN_CURSO"
+	TrabajoCompletado = "COMPLETADO"
+	TrabajoFallido    = "FALLIDO"
+)
+
+// TrabajoAutorizacionDB trabajo de la cola de autorización (firmar, enviar y consultar)
+type TrabajoAutorizacionDB struct {
+	ID                 int                  `json:"id"`
+	FacturaID          int                  `json:"facturaId"`
+	ClaveAcceso        string               `json:"claveAcceso"`
+	Etapa              string               `json:"etapa"`  // FIRMAR, ENVIAR, AUTORIZAR
+	Estado             string               `json:"estado"` // PENDIENTE, EN_CURSO, COMPLETADO, FALLIDO
+	Intentos           int                  `json:"intentos"`
+	ProximoIntento     time.Time            `json:"proximoIntento"`
+	XMLFirmado         string               `json:"-"`
+	UltimoError        string               `json:"ultimoError"`
+	ErroresIntentos    []sri.IntentoFallido `json:"erroresIntentos"` // Errores de la etapa actual
+	Worker             string               `json:"worker"`
+	RecepcionDuplicada bool                 `json:"recepcionDuplicada"` // Recepción devuelta con 43 o 70: el autorizado debe ser el XMLFirmado
+	FechaCreacion      time.Time            `json:"fechaCreacion"`
+	FechaActualizacion time.Time            `json:"fechaActualizacion"`
+}
+
+// EncolarTrabajoAutorizacion agrega una factura a la cola de autorización.
+// Si la factura ya tiene un trabajo activo se retorna ese mismo trabajo.
+func (d *Database) EncolarTrabajoAutorizacion(facturaID int) (*TrabajoAutorizacionDB, error) {
+	return d.EncolarTrabajoDesdeEtapa(facturaID, EtapaFirmar, "", "")
+}
+
+// EncolarTrabajoDesdeEtapa agrega una factura a la cola retomando desde una etapa posterior
+// a la firma (por ejemplo al reencolar un fallido con el XML ya firmado).
+// Si la factura ya tiene un trabajo activo se retorna ese mismo trabajo.
+func (d *Database) EncolarTrabajoDesdeEtapa(facturaID int, etapa, claveAcceso, xmlFirmado string) (*TrabajoAutorizacionDB, error) {
+	var existente int
+	err := d.db.QueryRow(`
+		SELECT id FROM trabajos_autorizacion
+		WHERE factura_id = ? AND estado IN (?, ?)
+		ORDER BY id LIMIT 1`,
+		facturaID, TrabajoPendiente, TrabajoEnCurso).Scan(&existente)
+	if err == nil {
+		return d.ObtenerTrabajoAutorizacion(existente)
+	}
+	if err != sql.ErrNoRows {
+		return nil, fmt.Errorf("error verificando trabajos existentes: %v", err)
+	}
+
+	ahora := time.Now().UTC()
+	var id int
+	err = d.db.QueryRow(`
+		INSERT INTO trabajos_autorizacion (
+			factura_id, clave_acceso, etapa, estado, intentos, proximo_intento, xml_firmado,
+			fecha_creacion, fecha_actualizacion
+		) VALUES (?, ?, ?, ?, 0, ?, ?, ?, ?)
+		RETURNING id`,
+		facturaID, claveAcceso, etapa, TrabajoPendiente, ahora, xmlFirmado, ahora, ahora).Scan(&id)
+	if err != nil {
+		return nil, fmt.Errorf("error encolando trabajo de autorización: %v", err)
+	}
+
+	return d.ObtenerTrabajoAutorizacion(id)
+}
+
+// TomarTrabajoAutorizacion reserva el siguiente trabajo pendiente cuyo próximo intento ya venció.
+// Retorna nil si no hay trabajos disponibles. La reserva es atómica entre workers.
+func (d *Database) TomarTrabajoAutorizacion(worker string) (*TrabajoAutorizacionDB, error) {
+	ahora := time.Now().UTC()
+
+	var id int
+	err := d.db.QueryRow(`
+		UPDATE trabajos_autorizacion
+		SET estado = ?, worker = ?, fecha_actualizacion = ?
+		WHERE id = (
+			SELECT id FROM trabajos_autorizacion
+			WHERE estado = ? AND proximo_intento <= ?
+			ORDER BY proximo_intento, id LIMIT 1
+		) AND estado = ?
+		RETURNING id`,
+		TrabajoEnCurso, worker, ahora, TrabajoPendiente, ahora, TrabajoPendiente).Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error tomando trabajo de autorización: %v", err)
+	}
+
+	return d.ObtenerTrabajoAutorizacion(id)
+}
+
+// GuardarTrabajoAutorizacion persiste etapa, estado, intentos y resultado de un trabajo
+func (d *Database) GuardarTrabajoAutorizacion(trabajo *TrabajoAutorizacionDB) error {
+	errores, err := json.Marshal(trabajo.ErroresIntentos)
+	if err != nil {
+		return fmt.Errorf("error serializando errores del trabajo: %v", err)
+	}
+
+	_, err = d.db.Exec(`
+		UPDATE trabajos_autorizacion
+		SET clave_acceso = ?, etapa = ?, estado = ?, intentos = ?, proximo_intento = ?,
+		    xml_firmado = ?, ultimo_error = ?, errores_intentos = ?, worker = ?, recepcion_duplicada = ?,
+		    fecha_actualizacion = ?
+		WHERE id = ?`,
+		trabajo.ClaveAcceso, trabajo.Etapa, trabajo.Estado, trabajo.Intentos,
+		trabajo.ProximoIntento.UTC(), trabajo.XMLFirmado, trabajo.UltimoError, string(errores), trabajo.Worker,
+		trabajo.RecepcionDuplicada, time.Now().UTC(), trabajo.ID)
+	if err != nil {
+		return fmt.Errorf("error guardando trabajo de autorización: %v", err)
+	}
+	return nil
+}
+
+// RecuperarTrabajosEnCurso devuelve a PENDIENTE los trabajos que quedaron EN_CURSO
+// (por ejemplo tras un reinicio del proceso). Retorna la cantidad recuperada.
+func (d *Database) RecuperarTrabajosEnCurso() (int, error) {
+	result, err := d.db.Exec(`
+		UPDATE trabajos_autorizacion
+		SET estado = ?, worker = '', fecha_actualizacion = ?
+		WHERE estado = ?`,
+		TrabajoPendiente, time.Now().UTC(), TrabajoEnCurso)
+	if err != nil {
+		return 0, fmt.Errorf("error recuperando trabajos en curso: %v", err)
+	}
+
+	filas, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("error obteniendo trabajos recuperados: %v", err)
+	}
+	return int(filas), nil
+}
+
+// ObtenerTrabajoAutorizacion obtiene un trabajo por su ID
+func (d *Database) ObtenerTrabajoAutorizacion(id int) (*TrabajoAutorizacionDB, error) {
+	row := d.db.QueryRow(selectTrabajoAutorizacion+" WHERE id = ?", id)
+
+	trabajo, err := escanearTrabajoAutorizacion(row)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("trabajo de autorización con ID %d no encontrado", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error obteniendo trabajo de autorización: %v", err)
+	}
+	return trabajo, nil
+}
+
+// ListarTrabajosAutorizacion lista trabajos, opcionalmente filtrados por estado
+func (d *Database) ListarTrabajosAutorizacion(estado string, limite, offset int) ([]*TrabajoAutorizacionDB, error) {
+	query := selectTrabajoAutorizacion
+	args := []interface{}{}
+	if estado != "" {
+		query += " WHERE estado = ?"
+		args = append(args, estado)
+	}
+	query += " ORDER BY id DESC LIMIT ? OFFSET ?"
+	args = append(args, limite, offset)
+
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error listando trabajos de autorización: %v", err)
+	}
+	defer rows.Close()
+
+	var trabajos []*TrabajoAutorizacionDB
+	for rows.Next() {
+		trabajo, err := escanearTrabajoAutorizacion(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error escaneando trabajo de autorización: %v", err)
+		}
+		trabajos = append(trabajos, trabajo)
+	}
+
+	return trabajos, rows.Err()
+}
+
+const selectTrabajoAutorizacion = `
+	SELECT id, factura_id, clave_acceso, etapa, estado, intentos, proximo_intento,
+	       xml_firmado, ultimo_error, errores_intentos, worker, recepcion_duplicada, fecha_creacion,
+	       fecha_actualizacion
+	FROM trabajos_autorizacion`
+
+// filaEscaneable abstrae *sql.Row y *sql.Rows
+type filaEscaneable interface {
+	Scan(dest ...interface{}) error
+}
+
+// escanearTrabajoAutorizacion convierte una fila en TrabajoAutorizacionDB
+func escanearTrabajoAutorizacion(fila filaEscaneable) (*TrabajoAutorizacionDB, error) {
+	trabajo := &TrabajoAutorizacionDB{}
+	var claveAcceso, xmlFirmado, ultimoError, errores, worker sql.NullString
+
+	err := fila.Scan(
+		&trabajo.ID, &trabajo.FacturaID, &claveAcceso, &trabajo.Etapa, &trabajo.Estado,
+		&trabajo.Intentos, &trabajo.ProximoIntento, &xmlFirmado, &ultimoError, &errores, &worker,
+		&trabajo.RecepcionDuplicada, &trabajo.FechaCreacion, &trabajo.FechaActualizacion,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if errores.String != "" {
+		if err := json.Unmarshal([]byte(errores.String), &trabajo.ErroresIntentos); err != nil {
+			return nil, fmt.Errorf("error leyendo errores del trabajo: %v", err)
+		}
+	}
+
+	trabajo.ClaveAcceso = claveAcceso.String
+	trabajo.XMLFirmado = xmlFirmado.String
+	trabajo.UltimoError = ultimoError.String
+	trabajo.Worker = worker.String
+
+	return trabajo, nil
+}
@@ -0,0 +1,114 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func nuevaDBTrabajosPrueba(t *testing.T) *Database {
+	t.Helper()
+
+	db, err := abrirDBPrueba(t, filepath.Join(t.TempDir(), "trabajos.db"))
+	if err != nil {
+		t.Fatalf("Error creando base de datos: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestEncolarTrabajoAutorizacion(t *testing.T) {
+	db := nuevaDBTrabajosPrueba(t)
+
+	trabajo, err := db.EncolarTrabajoAutorizacion(7)
+	if err != nil {
+		t.Fatalf("EncolarTrabajoAutorizacion() error: %v", err)
+	}
+	if trabajo.Etapa != EtapaFirmar || trabajo.Estado != TrabajoPendiente || trabajo.Intentos != 0 {
+		t.Errorf("Trabajo inicial inesperado: %+v", trabajo)
+	}
+
+	// Mientras el trabajo esté activo no se duplica
+	repetido, err := db.EncolarTrabajoAutorizacion(7)
+	if err != nil {
+		t.Fatalf("EncolarTrabajoAutorizacion() repetido error: %v", err)
+	}
+	if repetido.ID != trabajo.ID {
+		t.Errorf("Se esperaba el mismo trabajo %d, obtenido %d", trabajo.ID, repetido.ID)
+	}
+
+	// Un trabajo terminado permite volver a encolar la factura
+	trabajo.Estado = TrabajoCompletado
+	if err := db.GuardarTrabajoAutorizacion(trabajo); err != nil {
+		t.Fatalf("GuardarTrabajoAutorizacion() error: %v", err)
+	}
+	nuevo, err := db.EncolarTrabajoAutorizacion(7)
+	if err != nil {
+		t.Fatalf("EncolarTrabajoAutorizacion() tras completar error: %v", err)
+	}
+	if nuevo.ID == trabajo.ID {
+		t.Error("Se esperaba un trabajo nuevo tras completar el anterior")
+	}
+}
+
+func TestTomarTrabajoAutorizacion(t *testing.T) {
+	db := nuevaDBTrabajosPrueba(t)
+
+	vacio, err := db.TomarTrabajoAutorizacion("w1")
+	if err != nil || vacio != nil {
+		t.Fatalf("Cola vacía debería retornar nil, nil: %v, %v", vacio, err)
+	}
+
+	primero, _ := db.EncolarTrabajoAutorizacion(1)
+	segundo, _ := db.EncolarTrabajoAutorizacion(2)
+
+	// El segundo trabajo se programa en el futuro y no debe tomarse aún
+	segundo.ProximoIntento = time.Now().Add(time.Hour)
+	if err := db.GuardarTrabajoAutorizacion(segundo); err != nil {
+		t.Fatalf("GuardarTrabajoAutorizacion() error: %v", err)
+	}
+
+	tomado, err := db.TomarTrabajoAutorizacion("w1")
+	if err != nil {
+		t.Fatalf("TomarTrabajoAutorizacion() error: %v", err)
+	}
+	if tomado == nil || tomado.ID != primero.ID || tomado.Estado != TrabajoEnCurso || tomado.Worker != "w1" {
+		t.Fatalf("Trabajo tomado inesperado: %+v", tomado)
+	}
+
+	otro, err := db.TomarTrabajoAutorizacion("w2")
+	if err != nil || otro != nil {
+		t.Errorf("No debería haber trabajos disponibles: %+v, %v", otro, err)
+	}
+}
+
+func TestRecuperarTrabajosEnCurso(t *testing.T) {
+	db := nuevaDBTrabajosPrueba(t)
+
+	db.EncolarTrabajoAutorizacion(1)
+	db.EncolarTrabajoAutorizacion(2)
+	if _, err := db.TomarTrabajoAutorizacion("w1"); err != nil {
+		t.Fatalf("TomarTrabajoAutorizacion() error: %v", err)
+	}
+
+	recuperados, err := db.RecuperarTrabajosEnCurso()
+	if err != nil {
+		t.Fatalf("RecuperarTrabajosEnCurso() error: %v", err)
+	}
+	if recuperados != 1 {
+		t.Errorf("Recuperados = %d, esperado 1", recuperados)
+	}
+
+	pendientes, err := db.ListarTrabajosAutorizacion(TrabajoPendiente, 10, 0)
+	if err != nil {
+		t.Fatalf("ListarTrabajosAutorizacion() error: %v", err)
+	}
+	if len(pendientes) != 2 {
+		t.Errorf("Pendientes = %d, esperado 2", len(pendientes))
+	}
+	for _, trabajo := range pendientes {
+		if trabajo.Worker != "" {
+			t.Errorf("El trabajo recuperado %d conserva worker %q", trabajo.ID, trabajo.Worker)
+		}
+	}
+}
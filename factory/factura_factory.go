@@ -11,10 +11,47 @@ import (
 	"go-facturacion-sri/validators"
 )
 
+// Emisor - Datos del contribuyente que emite la factura. CrearFactura usa los de config.Config;
+// en un despliegue multi-tenant cada tenant aporta los suyos.
+type Emisor struct {
+	RUC                   string
+	RazonSocial           string
+	NombreComercial       string
+	DirMatriz             string
+	DirEstablecimiento    string
+	Establecimiento       string
+	PuntoEmision          string
+	Ambiente              string // "1" pruebas, "2" producción
+	TipoEmision           string
+	ContribuyenteEspecial string // Número de resolución; vacío si no aplica
+	ObligadoContabilidad  string // "SI" o "NO"; vacío omite el campo
+	ContribuyenteRimpe    string // Leyenda RIMPE; vacío si no aplica
+	Secuencial            string // Vacío: siguiente del contador de config
+	ClaveAcceso           string // Vacío: se genera con config.GenerarClaveAcceso
+}
+
+// EmisorDesdeConfig - Emisor único definido en config.Config
+func EmisorDesdeConfig() Emisor {
+	return Emisor{
+		RUC:                config.Config.Empresa.RUC,
+		RazonSocial:        config.Config.Empresa.RazonSocial,
+		DirEstablecimiento: config.Config.Empresa.Direccion,
+		Establecimiento:    config.Config.Empresa.Establecimiento,
+		PuntoEmision:       config.Config.Empresa.PuntoEmision,
+		Ambiente:           config.Config.Ambiente.Codigo,
+		TipoEmision:        config.Config.Ambiente.TipoEmision,
+	}
+}
+
 // CrearFactura - Función factory que crea una factura completa con protección contra panics
 // Recibe datos simples y devuelve una estructura completa lista para XML
 // Ahora devuelve (Factura, error) - dos valores!
-func CrearFactura(input models.FacturaInput) (factura models.Factura, err error) {
+func CrearFactura(input models.FacturaInput) (models.Factura, error) {
+	return CrearFacturaParaEmisor(input, EmisorDesdeConfig())
+}
+
+// CrearFacturaParaEmisor - Igual que CrearFactura pero con los datos de emisor indicados
+func CrearFacturaParaEmisor(input models.FacturaInput, emisor Emisor) (factura models.Factura, err error) {
 	// Protección contra panics
 	defer func() {
 		if r := recover(); r != nil {
@@ -35,7 +72,10 @@ func CrearFactura(input models.FacturaInput) (factura models.Factura, err error)
 
 	// Calcular totales de TODOS los productos
 	var subtotal float64 = 0
+	var totalICE float64 = 0
 	var detalles []models.Detalle // Slice vacío para ir agregando productos
+	baseIVA := make(map[float64]float64) // Base imponible agrupada por tarifa de IVA
+	var tarifas []float64                 // Tarifas en orden de aparición, para sumar de forma determinista
 
 	// Procesar cada producto
 	for i, producto := range input.Productos {
@@ -54,6 +94,21 @@ func CrearFactura(input models.FacturaInput) (factura models.Factura, err error)
 		
 		subtotal += subtotalProducto // Sumar al total general
 		
+		// Impuestos del producto: el ICE forma parte de la base imponible del IVA
+		tarifaIVA, err := models.TarifaIVA(producto.CodigoIVA)
+		if err != nil {
+			return models.Factura{}, fmt.Errorf("producto %d: %v", i+1, err)
+		}
+		if producto.TarifaICE < 0 {
+			return models.Factura{}, fmt.Errorf("producto %d: tarifa de ICE inválida: %.2f", i+1, producto.TarifaICE)
+		}
+		iceProducto := subtotalProducto * producto.TarifaICE / 100
+		totalICE += iceProducto
+		if _, ok := baseIVA[tarifaIVA]; !ok {
+			tarifas = append(tarifas, tarifaIVA)
+		}
+		baseIVA[tarifaIVA] += subtotalProducto + iceProducto
+		
 		// Verificar overflow del subtotal total
 		if subtotal > 99999999.99 {
 			return models.Factura{}, fmt.Errorf("subtotal total excede límite máximo permitido")
@@ -78,39 +133,55 @@ func CrearFactura(input models.FacturaInput) (factura models.Factura, err error)
 		return models.Factura{}, fmt.Errorf("subtotal inválido: %.2f", subtotal)
 	}
 
-	// Calcular IVA sobre el subtotal total
-	iva := subtotal * 0.15 // 15% IVA Ecuador
-	total := subtotal + iva
+	// Calcular IVA sobre la base de cada tarifa (15% general en Ecuador)
+	var iva float64 = 0
+	for _, tarifa := range tarifas {
+		iva += baseIVA[tarifa] * tarifa
+	}
+	total := subtotal + totalICE + iva
 	
 	// Validar que el total no exceda límites
 	if total > 99999999.99 {
 		return models.Factura{}, fmt.Errorf("total de factura excede límite máximo permitido: %.2f", total)
 	}
 
-	// Validar configuración antes de crear factura
-	if config.Config.Empresa.RUC == "" {
+	// Validar el emisor antes de crear factura
+	if emisor.RUC == "" {
 		return models.Factura{}, fmt.Errorf("configuración incompleta: RUC de empresa no configurado")
 	}
-	if config.Config.Empresa.RazonSocial == "" {
+	if emisor.RazonSocial == "" {
 		return models.Factura{}, fmt.Errorf("configuración incompleta: razón social no configurada")
 	}
 
-	// Crear la factura completa usando configuración externa
+	// Sin clave ni secuencial del emisor se usan los contadores del config, en ese orden
+	if emisor.ClaveAcceso == "" {
+		emisor.ClaveAcceso = config.GenerarClaveAcceso()
+	}
+	if emisor.Secuencial == "" {
+		emisor.Secuencial = config.ObtenerSecuencialSiguiente()
+	}
+
+	// Crear la factura completa con los datos del emisor
 	facturaResult := models.Factura{
 		InfoTributaria: models.InfoTributaria{
-			Ambiente:        config.Config.Ambiente.Codigo,        // Desde configuración
-			TipoEmision:     config.Config.Ambiente.TipoEmision,   // Desde configuración
-			RazonSocial:     config.Config.Empresa.RazonSocial,    // Desde configuración
-			RUC:             config.Config.Empresa.RUC,            // Desde configuración
-			ClaveAcceso:     config.GenerarClaveAcceso(),          // Función del config
-			CodDoc:          "01",                                 // 01=factura
-			Establecimiento: config.Config.Empresa.Establecimiento, // Desde configuración
-			PuntoEmision:    config.Config.Empresa.PuntoEmision,   // Desde configuración
-			Secuencial:      config.ObtenerSecuencialSiguiente(),  // Función del config
+			Ambiente:           emisor.Ambiente,
+			TipoEmision:        emisor.TipoEmision,
+			RazonSocial:        emisor.RazonSocial,
+			NombreComercial:    emisor.NombreComercial,
+			RUC:                emisor.RUC,
+			ClaveAcceso:        emisor.ClaveAcceso,
+			CodDoc:             "01", // 01=factura
+			Establecimiento:    emisor.Establecimiento,
+			PuntoEmision:       emisor.PuntoEmision,
+			Secuencial:         emisor.Secuencial,
+			DirMatriz:          emisor.DirMatriz,
+			ContribuyenteRimpe: emisor.ContribuyenteRimpe,
 		},
 		InfoFactura: models.InfoFactura{
 			FechaEmision:                time.Now().Format("02/01/2006"), // DD/MM/YYYY
-			DirEstablecimiento:          config.Config.Empresa.Direccion,  // Desde configuración
+			DirEstablecimiento:          emisor.DirEstablecimiento,
+			ContribuyenteEspecial:       emisor.ContribuyenteEspecial,
+			ObligadoContabilidad:        emisor.ObligadoContabilidad,
 			TipoIdentificacionComprador: "05", // 05=cédula
 			IdentificacionComprador:     input.ClienteCedula,
 			RazonSocialComprador:        input.ClienteNombre,
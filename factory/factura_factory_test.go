@@ -367,6 +367,38 @@ func TestCrearFactura_CalculosIVA(t *testing.T) {
 	}
 }
 
+func TestCrearFactura_CodigosImpuesto(t *testing.T) {
+	setUp()
+
+	input := models.FacturaInput{
+		ClienteNombre: "Test Cliente",
+		ClienteCedula: "1713175071",
+		Productos: []models.ProductoInput{
+			{Codigo: "GRAV001", Descripcion: "Producto gravado", Cantidad: 1, PrecioUnitario: 100},
+			{Codigo: "EXEN001", Descripcion: "Servicio exento", Cantidad: 2, PrecioUnitario: 50, CodigoIVA: "7"},
+			{Codigo: "ICE001", Descripcion: "Producto con ICE", Cantidad: 1, PrecioUnitario: 100, CodigoICE: "3011", TarifaICE: 10},
+		},
+	}
+
+	factura, err := CrearFactura(input)
+	if err != nil {
+		t.Fatalf("CrearFactura() error = %v", err)
+	}
+
+	// Subtotal 300; ICE 10; IVA 15% sobre 100 + (100 + 10) = 31.50; exento no grava IVA
+	if !almostEqual(factura.InfoFactura.TotalSinImpuestos, 300) {
+		t.Errorf("TotalSinImpuestos = %v, quería 300", factura.InfoFactura.TotalSinImpuestos)
+	}
+	if !almostEqual(factura.InfoFactura.ImporteTotal, 341.50) {
+		t.Errorf("ImporteTotal = %v, quería 341.50", factura.InfoFactura.ImporteTotal)
+	}
+
+	input.Productos[0].CodigoIVA = "99"
+	if _, err := CrearFactura(input); err == nil {
+		t.Error("CrearFactura() con código de IVA desconocido debió fallar")
+	}
+}
+
 // Benchmark para CrearFactura con un producto
 func BenchmarkCrearFactura_UnProducto(b *testing.B) {
 	setUp()
@@ -4,6 +4,7 @@ go 1.24
 
 require (
 	github.com/jung-kurt/gofpdf v1.16.2
+	github.com/lib/pq v1.10.9
 	github.com/mattn/go-sqlite3 v1.14.28
 	software.sslmate.com/src/go-pkcs12 v0.5.0
 )
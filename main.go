@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"strings"
@@ -10,6 +12,8 @@ import (
 	"go-facturacion-sri/database"
 	"go-facturacion-sri/factory"
 	"go-facturacion-sri/models"
+	"go-facturacion-sri/pipeline"
+	"go-facturacion-sri/secrets"
 	"go-facturacion-sri/sri"
 )
 
@@ -38,7 +42,32 @@ func main() {
 			port = os.Args[2]
 		}
 
-		server := api.NewServer(port)
+		// Una conexión compartida por la API y el pipeline de autorización
+		db, err := database.NuevaDesdeConfig(config.Config.Database)
+		if err != nil {
+			fmt.Printf("❌ Error abriendo base de datos: %v\n", err)
+			os.Exit(1)
+		}
+		defer db.Close()
+		fmt.Printf("🔎 Búsqueda de texto completo: %s\n", db.ModoBusqueda())
+
+		// Un solo resolvedor de secretos: el keystore se descifra una vez al arrancar
+		resolvedor := abrirSecretos()
+		server := api.NewServer(port, db, resolvedor)
+
+		// Pipeline de autorización asíncrona (firma, envío y consulta al SRI)
+		if config.Config.Pipeline.Habilitado {
+			clientes := func(ambiente sri.Ambiente) pipeline.ClienteSRI {
+				return server.ClienteSRI(ambiente)
+			}
+			if p, err := iniciarPipeline(db, clientes, resolvedor); err != nil {
+				fmt.Printf("⚠️  Pipeline de autorización deshabilitado: %v\n", err)
+			} else {
+				defer p.Detener()
+				server.ConfigurarPipeline(p)
+			}
+		}
+
 		if err := server.Start(); err != nil {
 			fmt.Printf("❌ Error iniciando servidor: %v\n", err)
 			os.Exit(1)
@@ -76,6 +105,52 @@ func main() {
 		return
 	}
 
+	// Modo Simulador SRI: Servicios SOAP simulados para desarrollo sin conexión
+	if len(os.Args) > 1 && os.Args[1] == "simulador-sri" {
+		port := "8089"
+		if len(os.Args) > 2 {
+			port = os.Args[2]
+		}
+
+		simulador := sri.NuevoSimuladorSRI(sri.Pruebas)
+		fmt.Println("🧪 Simulador SRI escuchando en puerto " + port)
+		fmt.Println("   Recepción:    http://localhost:" + port + sri.RutaRecepcionSimulador)
+		fmt.Println("   Autorización: http://localhost:" + port + sri.RutaAutorizacionSimulador)
+		fmt.Println("💡 Configure sri.endpointRecepcion y sri.endpointAutorizacion con estas URLs")
+		if err := simulador.Escuchar(":" + port); err != nil {
+			fmt.Printf("❌ Error en simulador SRI: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Modo Secretos: Administrar keystore cifrado de credenciales
+	if len(os.Args) > 1 && os.Args[1] == "secretos" {
+		if err := secrets.EjecutarCLI(os.Args[2:], config.Config.Secretos, os.Stdin); err != nil {
+			fmt.Printf("❌ Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Modo Migraciones: Administrar versiones del esquema de la base de datos
+	if len(os.Args) > 1 && os.Args[1] == "migraciones" {
+		if err := database.EjecutarCLIMigraciones(os.Args[2:], config.Config.Database); err != nil {
+			fmt.Printf("❌ Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Modo Tenants: Administrar emisores y sus credenciales de API
+	if len(os.Args) > 1 && os.Args[1] == "tenants" {
+		if err := database.EjecutarCLITenants(os.Args[2:], config.Config.Database); err != nil {
+			fmt.Printf("❌ Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Modo demo: Ejecutar ejemplos y pruebas
 	fmt.Println("\n" + strings.Repeat("=", 50))
 	fmt.Println("🧪 MODO DEMO - Ejecutando ejemplos")
@@ -85,6 +160,10 @@ func main() {
 	fmt.Println("🗄️  Para demo DB: go run main.go test_validaciones.go database")
 	fmt.Println("🧪 Para test SRI: go run main.go test_validaciones.go test-sri")
 	fmt.Println("📋 Para certificación: go run main.go test_validaciones.go certificacion")
+	fmt.Println("🧪 Para simulador SRI: go run main.go test_validaciones.go simulador-sri [puerto]")
+	fmt.Println("🔐 Para secretos: go run main.go test_validaciones.go secretos [guardar|listar|eliminar]")
+	fmt.Println("🗄️  Para migraciones: go run main.go test_validaciones.go migraciones [estado|subir|bajar [pasos]]")
+	fmt.Println("🏢 Para tenants: go run main.go test_validaciones.go tenants [listar|crear|credencial|revocar]")
 	fmt.Println(strings.Repeat("=", 50))
 
 	// Primero, ejecutar pruebas de validación
@@ -140,3 +219,34 @@ func main() {
 	fmt.Println("=== XML GENERADO ===")
 	fmt.Printf("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n%s\n", xmlData)
 }
+
+// abrirSecretos crea el resolvedor de secretos del proceso. Un keystore configurado que aún no
+// existe detiene el arranque indicando cómo crearlo.
+func abrirSecretos() *secrets.Resolvedor {
+	resolvedor, err := secrets.NuevoResolvedorDesdeConfig(config.Config.Secretos)
+	if errors.Is(err, secrets.ErrKeystoreNoExiste) {
+		fmt.Printf("❌ %v\n", err)
+		fmt.Println("🔐 Cree el keystore con cada secreto referenciado como keystore:<nombre> en la configuración:")
+		fmt.Println("   echo -n '<valor>' | go run main.go test_validaciones.go secretos guardar <nombre>")
+		os.Exit(1)
+	}
+	if err != nil {
+		fmt.Printf("❌ Error configurando secretos: %v\n", err)
+		os.Exit(1)
+	}
+	return resolvedor
+}
+
+// iniciarPipeline lanza los workers de autorización sobre la base de datos y los clientes SRI del servidor
+func iniciarPipeline(db *database.Database, clientes pipeline.FabricaClienteSRI, resolvedor *secrets.Resolvedor) (*pipeline.Pipeline, error) {
+	p, err := pipeline.NuevoDesdeConfig(db, clientes, resolvedor)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.Iniciar(context.Background()); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
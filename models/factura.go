@@ -8,11 +8,15 @@ import (
 )
 
 // ProductoInput - Datos de un producto individual
+// Si Descripcion y PrecioUnitario se omiten, el producto se toma del catálogo por su Codigo
 type ProductoInput struct {
 	Codigo         string
 	Descripcion    string
 	Cantidad       float64
 	PrecioUnitario float64
+	CodigoIVA      string  // Código de porcentaje de IVA del SRI (vacío = 15%)
+	CodigoICE      string  // Código de ICE del SRI (vacío = no grava ICE)
+	TarifaICE      float64 // Porcentaje ad valorem de ICE, ej: 10 para 10%
 }
 
 // FacturaInput - Datos simples para crear una factura
@@ -24,22 +28,28 @@ type FacturaInput struct {
 }
 
 // InfoTributaria - Datos básicos del emisor (obligatorios SRI)
+// Los campos opcionales se omiten del XML cuando están vacíos
 type InfoTributaria struct {
-	Ambiente        string `xml:"ambiente"`
-	TipoEmision     string `xml:"tipoEmision"`
-	RazonSocial     string `xml:"razonSocial"`
-	RUC             string `xml:"ruc"`
-	ClaveAcceso     string `xml:"claveAcceso"`
-	CodDoc          string `xml:"codDoc"`
-	Establecimiento string `xml:"estab"`
-	PuntoEmision    string `xml:"ptoEmi"`
-	Secuencial      string `xml:"secuencial"`
+	Ambiente           string `xml:"ambiente"`
+	TipoEmision        string `xml:"tipoEmision"`
+	RazonSocial        string `xml:"razonSocial"`
+	NombreComercial    string `xml:"nombreComercial,omitempty"`
+	RUC                string `xml:"ruc"`
+	ClaveAcceso        string `xml:"claveAcceso"`
+	CodDoc             string `xml:"codDoc"`
+	Establecimiento    string `xml:"estab"`
+	PuntoEmision       string `xml:"ptoEmi"`
+	Secuencial         string `xml:"secuencial"`
+	DirMatriz          string `xml:"dirMatriz,omitempty"`
+	ContribuyenteRimpe string `xml:"contribuyenteRimpe,omitempty"` // Leyenda del régimen RIMPE
 }
 
 // InfoFactura - Datos específicos de la factura
 type InfoFactura struct {
 	FechaEmision                string  `xml:"fechaEmision"`
 	DirEstablecimiento          string  `xml:"dirEstablecimiento"`
+	ContribuyenteEspecial       string  `xml:"contribuyenteEspecial,omitempty"` // Número de resolución
+	ObligadoContabilidad        string  `xml:"obligadoContabilidad,omitempty"`  // SI o NO
 	TipoIdentificacionComprador string  `xml:"tipoIdentificacionComprador"`
 	IdentificacionComprador     string  `xml:"identificacionComprador"`
 	RazonSocialComprador        string  `xml:"razonSocialComprador"`
@@ -0,0 +1,47 @@
+// Package models define los códigos de impuesto del SRI usados en los detalles de factura
+package models
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// CodigoIVAGeneral código de porcentaje de IVA aplicado cuando el producto no indica otro (15%)
+const CodigoIVAGeneral = "4"
+
+// tarifasIVA códigos de porcentaje de IVA del SRI (tabla 17 de la ficha técnica) y su tarifa.
+// El IVA diferenciado (código 8) no se incluye porque su tarifa depende de cada caso.
+var tarifasIVA = map[string]float64{
+	"0":  0,    // 0%
+	"2":  0.12, // 12%
+	"3":  0.14, // 14%
+	"4":  0.15, // 15%
+	"5":  0.05, // 5%
+	"6":  0,    // No objeto de impuesto
+	"7":  0,    // Exento de IVA
+	"10": 0.13, // 13%
+}
+
+// codigoICEValido los códigos de ICE del SRI son numéricos de 4 dígitos
+var codigoICEValido = regexp.MustCompile(`^[0-9]{4}$`)
+
+// TarifaIVA retorna la tarifa (fracción, ej: 0.15) de un código de porcentaje de IVA.
+// Un código vacío equivale a CodigoIVAGeneral.
+func TarifaIVA(codigo string) (float64, error) {
+	if codigo == "" {
+		codigo = CodigoIVAGeneral
+	}
+	tarifa, ok := tarifasIVA[codigo]
+	if !ok {
+		return 0, fmt.Errorf("código de IVA no soportado: %s", codigo)
+	}
+	return tarifa, nil
+}
+
+// ValidarCodigoICE verifica el formato de un código de ICE; vacío significa que el producto no grava ICE
+func ValidarCodigoICE(codigo string) error {
+	if codigo != "" && !codigoICEValido.MatchString(codigo) {
+		return fmt.Errorf("código de ICE inválido: %s (debe tener 4 dígitos)", codigo)
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package pdf
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"go-facturacion-sri/database"
 	"time"
@@ -21,15 +22,15 @@ func NewFacturaPDFGenerator(db *database.Database) *FacturaPDFGenerator {
 }
 
 // GenerarFacturaPDF genera un PDF para una factura específica
-func (g *FacturaPDFGenerator) GenerarFacturaPDF(facturaID int) ([]byte, error) {
+func (g *FacturaPDFGenerator) GenerarFacturaPDF(ctx context.Context, facturaID int) ([]byte, error) {
 	// Obtener factura de la base de datos
-	factura, err := g.db.ObtenerFacturaPorID(facturaID)
+	factura, err := g.db.ObtenerFacturaPorID(ctx, facturaID)
 	if err != nil {
 		return nil, fmt.Errorf("error obteniendo factura: %v", err)
 	}
 
 	// Obtener productos de la factura
-	productos, err := g.db.ObtenerProductosPorFactura(facturaID)
+	productos, err := g.db.ObtenerProductosPorFactura(ctx, facturaID)
 	if err != nil {
 		return nil, fmt.Errorf("error obteniendo productos: %v", err)
 	}
@@ -179,9 +180,9 @@ func (g *FacturaPDFGenerator) GenerarFacturaPDF(facturaID int) ([]byte, error) {
 }
 
 // GenerarFacturaSimplePDF genera un PDF básico más simple
-func (g *FacturaPDFGenerator) GenerarFacturaSimplePDF(facturaID int) ([]byte, error) {
+func (g *FacturaPDFGenerator) GenerarFacturaSimplePDF(ctx context.Context, facturaID int) ([]byte, error) {
 	// Obtener factura
-	factura, err := g.db.ObtenerFacturaPorID(facturaID)
+	factura, err := g.db.ObtenerFacturaPorID(ctx, facturaID)
 	if err != nil {
 		return nil, fmt.Errorf("error obteniendo factura: %v", err)
 	}
@@ -211,15 +212,15 @@ func (g *FacturaPDFGenerator) GenerarFacturaSimplePDF(facturaID int) ([]byte, er
 }
 
 // ValidarFacturaParaPDF valida que una factura puede generar PDF
-func (g *FacturaPDFGenerator) ValidarFacturaParaPDF(facturaID int) error {
+func (g *FacturaPDFGenerator) ValidarFacturaParaPDF(ctx context.Context, facturaID int) error {
 	// Verificar que la factura existe
-	_, err := g.db.ObtenerFacturaPorID(facturaID)
+	_, err := g.db.ObtenerFacturaPorID(ctx, facturaID)
 	if err != nil {
 		return fmt.Errorf("factura no encontrada: %v", err)
 	}
 
 	// Verificar que tiene productos
-	productos, err := g.db.ObtenerProductosPorFactura(facturaID)
+	productos, err := g.db.ObtenerProductosPorFactura(ctx, facturaID)
 	if err != nil {
 		return fmt.Errorf("error obteniendo productos: %v", err)
 	}
@@ -0,0 +1,141 @@
+// Package pipeline implementa el reencolado de la cola de fallidos
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"go-facturacion-sri/database"
+	"go-facturacion-sri/sri"
+)
+
+// ErrFallidoNoReencolable se retorna cuando un fallido no puede volver a la cola de autorización
+var ErrFallidoNoReencolable = errors.New("fallido no reencolable")
+
+// intervaloReencoladoMaximo frecuencia máxima con la que se revisa la cola de fallidos
+const intervaloReencoladoMaximo = time.Minute
+
+// enviarAFallidos registra en la cola de fallidos un trabajo que agotó sus intentos
+func (p *Pipeline) enviarAFallidos(trabajo *database.TrabajoAutorizacionDB, causa error) {
+	recuperable := false
+	if clasificado := sri.ClasificarError(causa); clasificado != nil {
+		recuperable = clasificado.IsRecuperable()
+	}
+
+	fallido, err := p.db.GuardarFallido(&database.FallidoDB{
+		FacturaID:      trabajo.FacturaID,
+		TrabajoID:      trabajo.ID,
+		ClaveAcceso:    trabajo.ClaveAcceso,
+		Origen:         database.OrigenPipeline,
+		Etapa:          trabajo.Etapa,
+		XMLComprobante: trabajo.XMLFirmado,
+		Intentos:       trabajo.ErroresIntentos,
+		UltimoError:    causa.Error(),
+		Recuperable:    recuperable,
+	})
+	if err != nil {
+		log.Printf("❌ Pipeline: no se pudo registrar el trabajo %d en la cola de fallidos: %v", trabajo.ID, err)
+		return
+	}
+	log.Printf("📥 Pipeline: trabajo %d en cola de fallidos (fallido %d, recuperable: %t)", trabajo.ID, fallido.ID, recuperable)
+}
+
+// ReencolarFallido devuelve un fallido PENDIENTE a la cola de autorización, retomando
+// desde la etapa que falló cuando se conserva el XML firmado
+func (p *Pipeline) ReencolarFallido(id int) (*database.TrabajoAutorizacionDB, error) {
+	fallido, err := p.db.ObtenerFallido(id)
+	if err != nil {
+		return nil, err
+	}
+	if fallido.Estado != database.FallidoPendiente {
+		return nil, fmt.Errorf("%w: el fallido %d está %s", ErrFallidoNoReencolable, id, fallido.Estado)
+	}
+	if fallido.FacturaID == 0 {
+		return nil, fmt.Errorf("%w: la clave %s no corresponde a una factura", ErrFallidoNoReencolable, fallido.ClaveAcceso)
+	}
+
+	trabajo, err := p.db.EncolarTrabajoDesdeEtapa(fallido.FacturaID, etapaReencolado(fallido), fallido.ClaveAcceso, fallido.XMLComprobante)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.db.MarcarFallidoReencolado(id, trabajo.ID); err != nil {
+		return nil, err
+	}
+
+	select {
+	case p.despertar <- struct{}{}:
+	default:
+	}
+
+	return trabajo, nil
+}
+
+// ReencolarFallidosRecuperables reencola los fallidos recuperables cuya espera ya venció.
+// La espera se duplica con cada fallo previo de la misma factura. Retorna la cantidad reencolada.
+func (p *Pipeline) ReencolarFallidosRecuperables() (int, error) {
+	fallidos, err := p.db.FallidosReencolables(p.config.MaxReencolados)
+	if err != nil {
+		return 0, err
+	}
+
+	reencolados := 0
+	for _, fallido := range fallidos {
+		espera := p.config.EsperaReencolado << uint(fallido.Reencolados)
+		if time.Since(fallido.FechaCreacion) < espera {
+			continue
+		}
+
+		if _, err := p.ReencolarFallido(fallido.ID); err != nil {
+			log.Printf("❌ Pipeline: error reencolando fallido %d: %v", fallido.ID, err)
+			continue
+		}
+		reencolados++
+	}
+
+	return reencolados, nil
+}
+
+// ejecutarReencolado revisa periódicamente la cola de fallidos hasta que se cancela el contexto
+func (p *Pipeline) ejecutarReencolado(ctx context.Context) {
+	defer p.wg.Done()
+
+	intervalo := p.config.EsperaReencolado
+	if intervalo > intervaloReencoladoMaximo {
+		intervalo = intervaloReencoladoMaximo
+	}
+	ticker := time.NewTicker(intervalo)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if reencolados, err := p.ReencolarFallidosRecuperables(); err != nil {
+			log.Printf("❌ Pipeline: error revisando cola de fallidos: %v", err)
+		} else if reencolados > 0 {
+			log.Printf("🔁 Pipeline: %d fallidos recuperables reencolados", reencolados)
+		}
+	}
+}
+
+// etapaReencolado etapa desde la que se retoma un fallido: sin XML firmado se vuelve a firmar
+func etapaReencolado(fallido *database.FallidoDB) string {
+	if fallido.XMLComprobante == "" {
+		return database.EtapaFirmar
+	}
+
+	switch fallido.Etapa {
+	case database.EtapaAutorizar, sri.OperacionAutorizacion:
+		return database.EtapaAutorizar
+	case database.EtapaEnviar, sri.OperacionRecepcion:
+		return database.EtapaEnviar
+	default:
+		return database.EtapaFirmar
+	}
+}
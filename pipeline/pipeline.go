@@ -0,0 +1,654 @@
+// Package pipeline implementa el procesamiento asíncrono de autorización de comprobantes SRI
+package pipeline
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"go-facturacion-sri/config"
+	"go-facturacion-sri/database"
+	"go-facturacion-sri/secrets"
+	"go-facturacion-sri/sri"
+)
+
+// ClienteSRI operaciones SOAP que necesita el pipeline (implementado por sri.SOAPClient)
+type ClienteSRI interface {
+	EnviarComprobanteContexto(ctx context.Context, xmlComprobante []byte) (*sri.RespuestaSolicitud, error)
+	ConsultarAutorizacionContexto(ctx context.Context, claveAcceso string) (*sri.RespuestaComprobante, error)
+}
+
+// FabricaClienteSRI retorna el cliente SOAP de un ambiente. El servidor API comparte los suyos
+// para que el pipeline use el mismo circuit breaker, limitador, archivo SOAP y cola de fallidos.
+type FabricaClienteSRI func(ambiente sri.Ambiente) ClienteSRI
+
+// ActorPipeline actor registrado en el historial de estados para los cambios del pipeline
+const ActorPipeline = "pipeline"
+
+// Config parámetros de ejecución del pipeline
+type Config struct {
+	Workers            int                 // Cantidad de workers concurrentes
+	IntervaloSondeo    time.Duration       // Frecuencia con la que un worker inactivo revisa la cola
+	MaxIntentos        int                 // Intentos por etapa antes de marcar el trabajo como FALLIDO
+	EsperaAutorizacion time.Duration       // Espera tras RECIBIDA antes de la primera consulta
+	MaxReencolados     int                 // Reencolados automáticos de un fallido recuperable
+	EsperaReencolado   time.Duration       // Espera base antes de reencolar; se duplica en cada reencolado
+	Reintento          sri.ConfigReintento // Backoff entre intentos de una misma etapa
+	PolicyID           string              // Política de firma XAdES-BES
+	PolicyHash         string              // Hash de la política de firma
+}
+
+// ConfigDesdeGlobal construye la configuración del pipeline a partir de config.Config
+func ConfigDesdeGlobal() Config {
+	cfg := config.Config.Pipeline
+	maxReencolados := 0
+	if cfg.MaxReencolados != nil {
+		maxReencolados = *cfg.MaxReencolados
+	}
+	return Config{
+		Workers:            cfg.Workers,
+		IntervaloSondeo:    time.Duration(cfg.IntervaloSondeoSegundos) * time.Second,
+		MaxIntentos:        cfg.MaxIntentos,
+		EsperaAutorizacion: time.Duration(cfg.EsperaAutorizacionSegundos) * time.Second,
+		MaxReencolados:     maxReencolados,
+		EsperaReencolado:   time.Duration(cfg.EsperaReencoladoMinutos) * time.Minute,
+		Reintento:          sri.ConfigReintentoDefault,
+		PolicyID:           config.Config.SRI.PolicyID,
+		PolicyHash:         config.Config.SRI.PolicyHash,
+	}
+}
+
+// Pipeline procesa la cola persistente de autorización: firma, envía y consulta cada factura
+type Pipeline struct {
+	db      *database.Database
+	cliente ClienteSRI
+	signer  sri.Signer
+	config  Config
+
+	mu        sync.Mutex
+	cancelar  context.CancelFunc
+	wg        sync.WaitGroup
+	despertar chan struct{}
+
+	// Firmador y cliente SOAP por emisor. Sin firmadorTenant (pipeline creado con Nuevo) todas
+	// las facturas se firman con signer y se envían con cliente.
+	firmadorTenant  func(tenant *database.TenantDB) (sri.Signer, error)
+	clienteAmbiente FabricaClienteSRI
+	muEmisores      sync.Mutex
+	signers         map[int]sri.Signer
+	clientes        map[sri.Ambiente]ClienteSRI
+}
+
+// Nuevo crea un pipeline sobre la base de datos, el cliente SOAP y el firmador indicados
+func Nuevo(db *database.Database, cliente ClienteSRI, signer sri.Signer, cfg Config) (*Pipeline, error) {
+	if db == nil {
+		return nil, fmt.Errorf("base de datos requerida para el pipeline")
+	}
+	if cliente == nil {
+		return nil, fmt.Errorf("cliente SRI requerido para el pipeline")
+	}
+	if signer == nil {
+		return nil, fmt.Errorf("firmador requerido para el pipeline")
+	}
+
+	if cfg.Workers <= 0 {
+		cfg.Workers = 1
+	}
+	if cfg.IntervaloSondeo <= 0 {
+		cfg.IntervaloSondeo = 2 * time.Second
+	}
+	if cfg.MaxIntentos <= 0 {
+		cfg.MaxIntentos = sri.ConfigReintentoDefault.MaxIntentos
+	}
+	if cfg.Reintento.TiempoBase == 0 {
+		cfg.Reintento = sri.ConfigReintentoDefault
+	}
+	if cfg.EsperaReencolado <= 0 {
+		cfg.EsperaReencolado = 15 * time.Minute
+	}
+
+	return &Pipeline{
+		db:        db,
+		cliente:   cliente,
+		signer:    signer,
+		config:    cfg,
+		despertar: make(chan struct{}, 1),
+	}, nil
+}
+
+// NuevoDesdeConfig crea el pipeline con el firmador definido en config.Config. Los clientes SOAP
+// de cada ambiente se obtienen de fabrica; si es nil se crean clientes propios sin archivo SOAP
+// ni cola de fallidos. resolvedor es el resolvedor de secretos del proceso (nil: solo env: y file:).
+func NuevoDesdeConfig(db *database.Database, fabrica FabricaClienteSRI, resolvedor *secrets.Resolvedor) (*Pipeline, error) {
+	if resolvedor == nil {
+		resolvedor = secrets.NuevoResolvedor()
+	}
+
+	signer, err := sri.NuevoSignerDesdeConfig(config.Config.Certificado, resolvedor)
+	if err != nil {
+		return nil, fmt.Errorf("error configurando firmador: %v", err)
+	}
+
+	if fabrica == nil {
+		fabrica = func(ambiente sri.Ambiente) ClienteSRI {
+			return sri.NewSOAPClient(ambiente)
+		}
+	}
+	cliente := fabrica(sri.AmbienteConfigurado())
+
+	p, err := Nuevo(db, cliente, signer, ConfigDesdeGlobal())
+	if err != nil {
+		return nil, err
+	}
+
+	// Cada tenant firma con su certificado y envía al ambiente indicado en su clave de acceso
+	p.firmadorTenant = func(tenant *database.TenantDB) (sri.Signer, error) {
+		if tenant.CertificadoRuta == "" {
+			return nil, fmt.Errorf("el tenant %d no tiene certificado configurado", tenant.ID)
+		}
+		return sri.NuevoSignerDesdeConfig(config.CertificadoConfig{
+			RutaArchivo: tenant.CertificadoRuta,
+			Password:    tenant.CertificadoPassword,
+		}, resolvedor)
+	}
+	p.clienteAmbiente = fabrica
+	p.signers = make(map[int]sri.Signer)
+	p.clientes = map[sri.Ambiente]ClienteSRI{sri.AmbienteConfigurado(): cliente}
+	return p, nil
+}
+
+// signerPara retorna el firmador del emisor de la factura; el del tenant se crea una sola vez
+func (p *Pipeline) signerPara(tenantID int) (sri.Signer, error) {
+	if tenantID == database.TenantPredeterminado || p.firmadorTenant == nil {
+		return p.signer, nil
+	}
+
+	p.muEmisores.Lock()
+	defer p.muEmisores.Unlock()
+	if signer, ok := p.signers[tenantID]; ok {
+		return signer, nil
+	}
+
+	tenant, err := p.db.ObtenerTenant(tenantID)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := p.firmadorTenant(tenant)
+	if err != nil {
+		return nil, fmt.Errorf("error configurando firmador del tenant %d: %v", tenantID, err)
+	}
+	p.signers[tenantID] = signer
+	return signer, nil
+}
+
+// clientePara retorna el cliente SOAP del ambiente codificado en la clave de acceso
+func (p *Pipeline) clientePara(claveAcceso string) ClienteSRI {
+	if p.clienteAmbiente == nil {
+		return p.cliente
+	}
+	clave, err := sri.ParsearClaveAcceso(claveAcceso)
+	if err != nil {
+		return p.cliente
+	}
+
+	p.muEmisores.Lock()
+	defer p.muEmisores.Unlock()
+	cliente, ok := p.clientes[clave.Ambiente]
+	if !ok {
+		cliente = p.clienteAmbiente(clave.Ambiente)
+		p.clientes[clave.Ambiente] = cliente
+	}
+	return cliente
+}
+
+// Encolar agrega una factura a la cola y despierta a un worker inactivo
+func (p *Pipeline) Encolar(facturaID int) (*database.TrabajoAutorizacionDB, error) {
+	trabajo, err := p.db.EncolarTrabajoAutorizacion(facturaID)
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case p.despertar <- struct{}{}:
+	default:
+	}
+
+	return trabajo, nil
+}
+
+// Iniciar recupera los trabajos interrumpidos y lanza los workers en segundo plano
+func (p *Pipeline) Iniciar(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.cancelar != nil {
+		return fmt.Errorf("el pipeline ya está en ejecución")
+	}
+
+	recuperados, err := p.db.RecuperarTrabajosEnCurso()
+	if err != nil {
+		return err
+	}
+	if recuperados > 0 {
+		log.Printf("🔁 Pipeline: %d trabajos interrumpidos devueltos a la cola", recuperados)
+	}
+
+	ctx, cancelar := context.WithCancel(ctx)
+	p.cancelar = cancelar
+
+	for i := 1; i <= p.config.Workers; i++ {
+		p.wg.Add(1)
+		go p.ejecutarWorker(ctx, fmt.Sprintf("worker-%d", i))
+	}
+
+	if p.config.MaxReencolados > 0 {
+		p.wg.Add(1)
+		go p.ejecutarReencolado(ctx)
+	}
+
+	log.Printf("⚙️  Pipeline de autorización iniciado con %d workers", p.config.Workers)
+	return nil
+}
+
+// Detener cancela los workers y espera a que terminen su trabajo actual
+func (p *Pipeline) Detener() {
+	p.mu.Lock()
+	cancelar := p.cancelar
+	p.cancelar = nil
+	p.mu.Unlock()
+
+	if cancelar == nil {
+		return
+	}
+	cancelar()
+	p.wg.Wait()
+}
+
+// ejecutarWorker toma trabajos de la cola hasta que se cancela el contexto
+func (p *Pipeline) ejecutarWorker(ctx context.Context, nombre string) {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(p.config.IntervaloSondeo)
+	defer ticker.Stop()
+
+	for {
+		procesado, err := p.procesarSiguiente(ctx, nombre)
+		if err != nil {
+			log.Printf("❌ Pipeline %s: %v", nombre, err)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if procesado {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-p.despertar:
+		}
+	}
+}
+
+// ProcesarSiguiente procesa un único trabajo disponible de forma síncrona.
+// Retorna false si la cola no tenía trabajos listos.
+func (p *Pipeline) ProcesarSiguiente(ctx context.Context) (bool, error) {
+	return p.procesarSiguiente(ctx, "manual")
+}
+
+// procesarSiguiente reserva un trabajo y ejecuta su etapa actual
+func (p *Pipeline) procesarSiguiente(ctx context.Context, worker string) (bool, error) {
+	trabajo, err := p.db.TomarTrabajoAutorizacion(worker)
+	if err != nil || trabajo == nil {
+		return false, err
+	}
+
+	if err := p.procesar(ctx, trabajo); err != nil {
+		return true, fmt.Errorf("trabajo %d (factura %d): %v", trabajo.ID, trabajo.FacturaID, err)
+	}
+	return true, nil
+}
+
+// procesar ejecuta la etapa del trabajo y persiste el resultado
+func (p *Pipeline) procesar(ctx context.Context, trabajo *database.TrabajoAutorizacionDB) error {
+	etapa, intentos := trabajo.Etapa, trabajo.Intentos
+
+	var err error
+	switch trabajo.Etapa {
+	case database.EtapaFirmar:
+		err = p.firmar(trabajo)
+	case database.EtapaEnviar:
+		err = p.enviar(ctx, trabajo)
+	case database.EtapaAutorizar:
+		err = p.autorizar(ctx, trabajo)
+	default:
+		err = fmt.Errorf("etapa desconocida: %s", trabajo.Etapa)
+		trabajo.Estado = database.TrabajoFallido
+		trabajo.UltimoError = err.Error()
+	}
+
+	// Un apagado a mitad de la etapa no cuenta como intento: el trabajo vuelve a la cola
+	if ctx.Err() != nil && trabajo.Etapa == etapa && trabajo.Estado != database.TrabajoCompletado {
+		trabajo.Estado = database.TrabajoPendiente
+		trabajo.Intentos = intentos
+		trabajo.Worker = ""
+		trabajo.ProximoIntento = time.Now()
+		err = nil
+	}
+
+	if errGuardar := p.db.GuardarTrabajoAutorizacion(trabajo); errGuardar != nil {
+		return errGuardar
+	}
+	return err
+}
+
+// firmar firma el XML original de la factura y deja el trabajo listo para enviar
+func (p *Pipeline) firmar(trabajo *database.TrabajoAutorizacionDB) error {
+	factura, err := p.db.ObtenerFacturaPorID(context.Background(), trabajo.FacturaID)
+	if err != nil {
+		trabajo.Estado = database.TrabajoFallido
+		trabajo.UltimoError = err.Error()
+		return err
+	}
+
+	if factura.Estado == database.EstadoAutorizada || factura.Estado == database.EstadoAnulada {
+		p.completar(trabajo)
+		return nil
+	}
+
+	signer, err := p.signerPara(factura.TenantID)
+	if err != nil {
+		return p.reprogramar(trabajo, err)
+	}
+
+	xmlFirmado, err := sri.FirmarXMLXAdESBES([]byte(factura.XMLOriginal), sri.XAdESBESConfig{
+		Signer:     signer,
+		PolicyID:   p.config.PolicyID,
+		PolicyHash: p.config.PolicyHash,
+	})
+	if err != nil {
+		return p.reprogramar(trabajo, fmt.Errorf("error firmando factura: %v", err))
+	}
+
+	trabajo.XMLFirmado = string(xmlFirmado)
+	trabajo.ClaveAcceso = extraerClaveAcceso([]byte(factura.XMLOriginal))
+	if trabajo.ClaveAcceso == "" {
+		trabajo.ClaveAcceso = factura.ClaveAcceso
+	}
+
+	// Un reintento tras una caída puede encontrar la factura ya enviada
+	if database.TransicionPermitida(factura.Estado, database.EstadoFirmada) {
+		if err := p.transicionar(trabajo, database.EstadoFirmada, nil); err != nil {
+			return p.errorTransicion(trabajo, err)
+		}
+	}
+
+	p.avanzar(trabajo, database.EtapaEnviar, 0)
+	return nil
+}
+
+// enviar remite el comprobante firmado al servicio de recepción
+func (p *Pipeline) enviar(ctx context.Context, trabajo *database.TrabajoAutorizacionDB) error {
+	if err := p.transicionar(trabajo, database.EstadoEnviada, nil); err != nil {
+		return p.errorTransicion(trabajo, err)
+	}
+
+	respuesta, err := p.clientePara(trabajo.ClaveAcceso).EnviarComprobanteContexto(ctx, []byte(trabajo.XMLFirmado))
+	if err != nil {
+		return p.reprogramar(trabajo, fmt.Errorf("error enviando comprobante: %v", err))
+	}
+
+	switch respuesta.Estado {
+	case sri.EstadoSRIRecibida:
+		if err := p.transicionar(trabajo, database.EstadoRecibida, nil); err != nil {
+			return p.errorTransicion(trabajo, err)
+		}
+		p.avanzar(trabajo, database.EtapaAutorizar, p.config.EsperaAutorizacion)
+		return nil
+
+	case sri.EstadoSRIDevuelta:
+		var mensajes []sri.MensajeSRI
+		for _, comprobante := range respuesta.Comprobantes {
+			mensajes = append(mensajes, comprobante.Mensajes...)
+		}
+
+		errores := respuesta.ErroresSRI()
+
+		// Clave ya registrada o en procesamiento (43, 70): el SRI ya tiene un comprobante con esta
+		// clave, solo falta consultar; al autorizar se verifica que sea el que se firmó
+		if sri.TieneAccion(errores, sri.AccionConsultarAutorizacion) {
+			trabajo.RecepcionDuplicada = true
+			if err := p.transicionar(trabajo, database.EstadoRecibida, mensajes); err != nil {
+				return p.errorTransicion(trabajo, err)
+			}
+			p.avanzar(trabajo, database.EtapaAutorizar, p.config.EsperaAutorizacion)
+			return nil
+		}
+
+		// Falla interna del SRI: el comprobante es correcto y se reenvía más tarde
+		if sri.TieneAccion(errores, sri.AccionReintentarEnvio) {
+			return p.reprogramar(trabajo, fmt.Errorf("recepción devuelta por falla del SRI: %s", formatearMensajes(mensajes)))
+		}
+
+		if err := p.transicionar(trabajo, database.EstadoDevuelta, mensajes); err != nil {
+			return p.errorTransicion(trabajo, err)
+		}
+		trabajo.UltimoError = formatearMensajes(mensajes)
+		p.completar(trabajo)
+		return nil
+
+	default:
+		return p.reprogramar(trabajo, fmt.Errorf("estado de recepción inesperado: %s", respuesta.Estado))
+	}
+}
+
+// autorizar consulta la autorización y registra el resultado final en la factura
+func (p *Pipeline) autorizar(ctx context.Context, trabajo *database.TrabajoAutorizacionDB) error {
+	respuesta, err := p.clientePara(trabajo.ClaveAcceso).ConsultarAutorizacionContexto(ctx, trabajo.ClaveAcceso)
+	if err != nil {
+		return p.reprogramar(trabajo, fmt.Errorf("error consultando autorización: %v", err))
+	}
+	if len(respuesta.Autorizaciones) == 0 {
+		if err := p.transicionar(trabajo, database.EstadoEnProceso, nil); err != nil {
+			return p.errorTransicion(trabajo, err)
+		}
+		return p.reprogramar(trabajo, fmt.Errorf("comprobante %s aún sin autorizaciones", trabajo.ClaveAcceso))
+	}
+
+	autorizacion := respuesta.Autorizaciones[0]
+	switch autorizacion.Estado {
+	case sri.EstadoSRIAutorizado:
+		// Tras una recepción 43 o 70 el comprobante autorizado puede ser otro con la misma clave
+		// (por ejemplo un secuencial repetido): solo se acepta si es el que se firmó
+		if trabajo.RecepcionDuplicada {
+			if strings.TrimSpace(autorizacion.Comprobante) == "" {
+				return p.reprogramar(trabajo, fmt.Errorf("la autorización de %s no incluye el comprobante para compararlo con el firmado", trabajo.ClaveAcceso))
+			}
+			if !mismoComprobante(autorizacion.Comprobante, trabajo.XMLFirmado) {
+				mensajes := []sri.MensajeSRI{{
+					Identificador:        "43",
+					Mensaje:              "CLAVE ACCESO REGISTRADA",
+					InformacionAdicional: "el SRI autorizó con esta clave de acceso un comprobante distinto del firmado",
+					Tipo:                 "ERROR",
+				}}
+				if err := p.transicionar(trabajo, database.EstadoNoAutorizada, mensajes); err != nil {
+					return p.errorTransicion(trabajo, err)
+				}
+				trabajo.UltimoError = formatearMensajes(mensajes)
+				p.completar(trabajo)
+				return nil
+			}
+		}
+
+		// Si la respuesta no trae el comprobante, el autorizado es el mismo que se envió firmado
+		if strings.TrimSpace(autorizacion.Comprobante) == "" {
+			autorizacion.Comprobante = trabajo.XMLFirmado
+		}
+		xmlAutorizado, err := sri.GenerarXMLAutorizado(autorizacion)
+		if err != nil {
+			return p.reprogramar(trabajo, err)
+		}
+
+		// La fecha legal de la autorización es la que reporta el SRI, no la hora de este proceso
+		var fechaAutorizacion *time.Time
+		if fecha, err := sri.ParsearFechaAutorizacion(autorizacion.FechaAutorizacion); err == nil {
+			fechaAutorizacion = &fecha
+		} else {
+			log.Printf("⚠️  Pipeline: %v en la autorización de %s", err, trabajo.ClaveAcceso)
+		}
+
+		err = p.db.TransicionarEstadoFactura(context.Background(), trabajo.FacturaID, database.CambioEstadoFactura{
+			Estado:             database.EstadoAutorizada,
+			NumeroAutorizacion: autorizacion.NumeroAutorizacion,
+			XMLAutorizado:      string(xmlAutorizado),
+			FechaAutorizacion:  fechaAutorizacion,
+			Observaciones:      formatearMensajes(autorizacion.Mensajes),
+			Actor:              ActorPipeline,
+			MensajesSRI:        mensajesEstado(autorizacion.Mensajes),
+		})
+		if err != nil {
+			return p.errorTransicion(trabajo, err)
+		}
+		p.completar(trabajo)
+		return nil
+
+	case sri.EstadoSRINoAutorizado, "NO_AUTORIZADO":
+		if err := p.transicionar(trabajo, database.EstadoNoAutorizada, autorizacion.Mensajes); err != nil {
+			return p.errorTransicion(trabajo, err)
+		}
+		trabajo.UltimoError = formatearMensajes(autorizacion.Mensajes)
+		p.completar(trabajo)
+		return nil
+
+	default:
+		if err := p.transicionar(trabajo, database.EstadoEnProceso, autorizacion.Mensajes); err != nil {
+			return p.errorTransicion(trabajo, err)
+		}
+		return p.reprogramar(trabajo, fmt.Errorf("autorización en estado %s", autorizacion.Estado))
+	}
+}
+
+// transicionar registra el cambio de estado de la factura del trabajo con los mensajes del SRI
+func (p *Pipeline) transicionar(trabajo *database.TrabajoAutorizacionDB, estado string, mensajes []sri.MensajeSRI) error {
+	return p.db.TransicionarEstadoFactura(context.Background(), trabajo.FacturaID, database.CambioEstadoFactura{
+		Estado:        estado,
+		Observaciones: formatearMensajes(mensajes),
+		Actor:         ActorPipeline,
+		MensajesSRI:   mensajesEstado(mensajes),
+	})
+}
+
+// errorTransicion decide si un fallo al cambiar el estado amerita reintento; la etapa no debe
+// continuar aunque retorne nil. Una transición inválida indica que la factura fue modificada
+// fuera del pipeline y no se reintenta.
+func (p *Pipeline) errorTransicion(trabajo *database.TrabajoAutorizacionDB, err error) error {
+	if errors.Is(err, database.ErrTransicionInvalida) {
+		trabajo.Estado = database.TrabajoFallido
+		trabajo.UltimoError = err.Error()
+		trabajo.Worker = ""
+		return err
+	}
+	return p.reprogramar(trabajo, err)
+}
+
+// avanzar mueve el trabajo a la siguiente etapa reiniciando el contador de intentos
+func (p *Pipeline) avanzar(trabajo *database.TrabajoAutorizacionDB, etapa string, espera time.Duration) {
+	trabajo.Etapa = etapa
+	trabajo.Estado = database.TrabajoPendiente
+	trabajo.Intentos = 0
+	trabajo.UltimoError = ""
+	trabajo.ErroresIntentos = nil
+	trabajo.Worker = ""
+	trabajo.ProximoIntento = time.Now().Add(espera)
+}
+
+// completar marca el trabajo como terminado
+func (p *Pipeline) completar(trabajo *database.TrabajoAutorizacionDB) {
+	trabajo.Estado = database.TrabajoCompletado
+	trabajo.Worker = ""
+	trabajo.ProximoIntento = time.Now()
+}
+
+// reprogramar registra el error y agenda un nuevo intento con backoff exponencial,
+// o marca el trabajo como FALLIDO y lo envía a la cola de fallidos al agotar los intentos de la etapa
+func (p *Pipeline) reprogramar(trabajo *database.TrabajoAutorizacionDB, causa error) error {
+	trabajo.Intentos++
+	trabajo.UltimoError = causa.Error()
+	trabajo.ErroresIntentos = append(trabajo.ErroresIntentos, sri.NuevoIntentoFallido(trabajo.Intentos, causa))
+	trabajo.Worker = ""
+
+	if trabajo.Intentos >= p.config.MaxIntentos {
+		trabajo.Estado = database.TrabajoFallido
+		p.enviarAFallidos(trabajo, causa)
+		return fmt.Errorf("etapa %s agotó %d intentos: %v", trabajo.Etapa, trabajo.Intentos, causa)
+	}
+
+	trabajo.Estado = database.TrabajoPendiente
+	trabajo.ProximoIntento = time.Now().Add(sri.CalcularTiempoEspera(trabajo.Intentos, p.config.Reintento))
+	return nil
+}
+
+// extraerClaveAcceso obtiene infoTributaria/claveAcceso del comprobante
+func extraerClaveAcceso(xmlComprobante []byte) string {
+	var documento struct {
+		ClaveAcceso string `xml:"infoTributaria>claveAcceso"`
+	}
+	if err := xml.Unmarshal(xmlComprobante, &documento); err != nil {
+		return ""
+	}
+	return strings.TrimSpace(documento.ClaveAcceso)
+}
+
+// mismoComprobante indica si el comprobante que reporta el SRI es el que se firmó, sin
+// considerar la declaración XML ni los espacios alrededor del documento
+func mismoComprobante(autorizado, firmado string) bool {
+	return sinDeclaracionXML(autorizado) == sinDeclaracionXML(firmado)
+}
+
+// sinDeclaracionXML quita la declaración <?xml ...?> inicial y los espacios alrededor
+func sinDeclaracionXML(documento string) string {
+	documento = strings.TrimSpace(documento)
+	if strings.HasPrefix(documento, "<?xml") {
+		if fin := strings.Index(documento, "?>"); fin >= 0 {
+			documento = strings.TrimSpace(documento[fin+2:])
+		}
+	}
+	return documento
+}
+
+// mensajesEstado convierte los mensajes del SRI al formato del historial de estados
+func mensajesEstado(mensajes []sri.MensajeSRI) []database.MensajeEstadoDB {
+	if len(mensajes) == 0 {
+		return nil
+	}
+	resultado := make([]database.MensajeEstadoDB, 0, len(mensajes))
+	for _, mensaje := range mensajes {
+		resultado = append(resultado, database.MensajeEstadoDB{
+			Identificador:        mensaje.Identificador,
+			Mensaje:              mensaje.Mensaje,
+			InformacionAdicional: mensaje.InformacionAdicional,
+			Tipo:                 mensaje.Tipo,
+		})
+	}
+	return resultado
+}
+
+// formatearMensajes convierte los mensajes del SRI en observaciones legibles
+func formatearMensajes(mensajes []sri.MensajeSRI) string {
+	partes := make([]string, 0, len(mensajes))
+	for _, mensaje := range mensajes {
+		texto := fmt.Sprintf("[%s] %s", mensaje.Identificador, mensaje.Mensaje)
+		if mensaje.InformacionAdicional != "" {
+			texto += ": " + mensaje.InformacionAdicional
+		}
+		partes = append(partes, texto)
+	}
+	return strings.Join(partes, "; ")
+}
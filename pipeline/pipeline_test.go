@@ -0,0 +1,504 @@
+package pipeline
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go-facturacion-sri/config"
+	"go-facturacion-sri/database"
+	"go-facturacion-sri/factory"
+	"go-facturacion-sri/models"
+	"go-facturacion-sri/sri"
+)
+
+// entornoPrueba agrupa simulador SRI, base de datos temporal y pipeline
+type entornoPrueba struct {
+	simulador *sri.SimuladorSRI
+	db        *database.Database
+	pipeline  *Pipeline
+
+	secuencial int
+}
+
+// configPrueba usa esperas mínimas para que los tests no dependan del backoff real
+func configPrueba() Config {
+	return Config{
+		Workers:            2,
+		IntervaloSondeo:    10 * time.Millisecond,
+		MaxIntentos:        5,
+		EsperaAutorizacion: 0,
+		Reintento: sri.ConfigReintento{
+			MaxIntentos:   5,
+			TiempoBase:    time.Millisecond,
+			Multiplicador: 1,
+			TiempoMaximo:  5 * time.Millisecond,
+		},
+	}
+}
+
+func nuevoEntornoPrueba(t *testing.T, cfg Config) *entornoPrueba {
+	t.Helper()
+	config.CargarConfiguracionPorDefecto()
+
+	simulador := sri.NuevoSimuladorSRI(sri.Pruebas)
+	if err := simulador.Iniciar(); err != nil {
+		t.Fatalf("Iniciar() simulador error: %v", err)
+	}
+	t.Cleanup(func() { simulador.Detener() })
+
+	db, err := database.New(filepath.Join(t.TempDir(), "pipeline.db"))
+	if err != nil {
+		t.Fatalf("Error creando base de datos: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	p, err := Nuevo(db, simulador.NuevoCliente(), signerPrueba(t), cfg)
+	if err != nil {
+		t.Fatalf("Nuevo() error: %v", err)
+	}
+
+	return &entornoPrueba{simulador: simulador, db: db, pipeline: p}
+}
+
+// signerPrueba crea un firmador local con un certificado autofirmado
+func signerPrueba(t *testing.T) sri.Signer {
+	t.Helper()
+
+	clave, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Error generando clave RSA: %v", err)
+	}
+	plantilla := &x509.Certificate{
+		SerialNumber: big.NewInt(2001),
+		Subject:      pkix.Name{CommonName: "EMPRESA PRUEBA S.A."},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, plantilla, plantilla, &clave.PublicKey, clave)
+	if err != nil {
+		t.Fatalf("Error creando certificado: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Error parseando certificado: %v", err)
+	}
+
+	signer, err := sri.NuevoSignerPKCS12(&sri.CertificadoDigital{PrivateKey: clave, Cert: cert})
+	if err != nil {
+		t.Fatalf("NuevoSignerPKCS12() error: %v", err)
+	}
+	return signer
+}
+
+// crearFacturaPrueba guarda una factura en BORRADOR y retorna su ID y la clave del XML
+func (e *entornoPrueba) crearFacturaPrueba(t *testing.T) (int, string) {
+	t.Helper()
+
+	productos := []models.ProductoInput{
+		{Codigo: "PIPE001", Descripcion: "Servicio de prueba", Cantidad: 1, PrecioUnitario: 100},
+	}
+	factura, err := factory.CrearFactura(models.FacturaInput{
+		ClienteNombre: "CLIENTE PIPELINE",
+		ClienteCedula: "1713175071",
+		Productos:     productos,
+	})
+	if err != nil {
+		t.Fatalf("CrearFactura() error: %v", err)
+	}
+
+	// Clave con dígito verificador según el algoritmo del SRI, que valida el simulador
+	e.secuencial++
+	clave, err := sri.GenerarClaveAcceso(sri.ClaveAccesoConfig{
+		FechaEmision:     time.Now(),
+		TipoComprobante:  sri.Factura,
+		RUCEmisor:        "1792146739001",
+		Ambiente:         sri.Pruebas,
+		Serie:            "001001",
+		NumeroSecuencial: fmt.Sprintf("%09d", e.secuencial),
+		CodigoNumerico:   "12345678",
+		TipoEmision:      sri.EmisionNormal,
+	})
+	if err != nil {
+		t.Fatalf("GenerarClaveAcceso() error: %v", err)
+	}
+	factura.InfoTributaria.ClaveAcceso = clave
+
+	facturaDB, err := e.db.GuardarFactura(context.Background(), factura, clave, productos)
+	if err != nil {
+		t.Fatalf("GuardarFactura() error: %v", err)
+	}
+
+	return facturaDB.ID, factura.InfoTributaria.ClaveAcceso
+}
+
+// procesarHasta ejecuta trabajos hasta que el de la factura alcanza el estado indicado
+func (e *entornoPrueba) procesarHasta(t *testing.T, facturaID int, estadoTrabajo string) *database.TrabajoAutorizacionDB {
+	t.Helper()
+
+	limite := time.Now().Add(5 * time.Second)
+	for time.Now().Before(limite) {
+		if _, err := e.pipeline.ProcesarSiguiente(context.Background()); err != nil {
+			t.Logf("ProcesarSiguiente(): %v", err)
+		}
+
+		trabajos, err := e.db.ListarTrabajosAutorizacion("", 10, 0)
+		if err != nil {
+			t.Fatalf("ListarTrabajosAutorizacion() error: %v", err)
+		}
+		for _, trabajo := range trabajos {
+			if trabajo.FacturaID == facturaID && trabajo.Estado == estadoTrabajo {
+				return trabajo
+			}
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	t.Fatalf("La factura %d no alcanzó el estado de trabajo %s", facturaID, estadoTrabajo)
+	return nil
+}
+
+func TestPipelineAutorizaFactura(t *testing.T) {
+	e := nuevoEntornoPrueba(t, configPrueba())
+	id, clave := e.crearFacturaPrueba(t)
+
+	if _, err := e.pipeline.Encolar(id); err != nil {
+		t.Fatalf("Encolar() error: %v", err)
+	}
+	trabajo := e.procesarHasta(t, id, database.TrabajoCompletado)
+
+	if trabajo.ClaveAcceso != clave {
+		t.Errorf("ClaveAcceso del trabajo = %s, esperada la del XML %s", trabajo.ClaveAcceso, clave)
+	}
+
+	factura, err := e.db.ObtenerFacturaPorID(context.Background(), id)
+	if err != nil {
+		t.Fatalf("ObtenerFacturaPorID() error: %v", err)
+	}
+	if factura.Estado != "AUTORIZADA" || factura.NumeroAutorizacion != clave {
+		t.Errorf("Factura no autorizada: estado=%s numero=%s", factura.Estado, factura.NumeroAutorizacion)
+	}
+	if factura.FechaAutorizacion == nil {
+		t.Error("FechaAutorizacion debería registrarse")
+	}
+	autorizado, err := sri.ParsearXMLAutorizado([]byte(factura.XMLAutorizado))
+	if err != nil {
+		t.Fatalf("XMLAutorizado no es un archivo <autorizacion>: %v", err)
+	}
+	if autorizado.Estado != sri.EstadoSRIAutorizado || autorizado.NumeroAutorizacion != clave || autorizado.Ambiente == "" {
+		t.Errorf("Datos de autorización inesperados: %+v", autorizado)
+	}
+	if fechaSRI, err := sri.ParsearFechaAutorizacion(autorizado.FechaAutorizacion); err != nil || factura.FechaAutorizacion == nil || !factura.FechaAutorizacion.Equal(fechaSRI) {
+		t.Errorf("FechaAutorizacion = %v, esperada la del SRI %s", factura.FechaAutorizacion, autorizado.FechaAutorizacion)
+	}
+	if !strings.Contains(autorizado.Comprobante, "ds:Signature") {
+		t.Error("El comprobante autorizado debería contener la firma")
+	}
+}
+
+func TestPipelineDevueltaMarcaFactura(t *testing.T) {
+	e := nuevoEntornoPrueba(t, configPrueba())
+	id, clave := e.crearFacturaPrueba(t)
+	e.simulador.ProgramarEscenario(clave, sri.EscenarioDevuelta("45", "SECUENCIAL REGISTRADO"))
+
+	e.pipeline.Encolar(id)
+	e.procesarHasta(t, id, database.TrabajoCompletado)
+
+	factura, _ := e.db.ObtenerFacturaPorID(context.Background(), id)
+	if factura.Estado != database.EstadoDevuelta {
+		t.Errorf("Estado = %s, esperado DEVUELTA", factura.Estado)
+	}
+	if !strings.Contains(factura.ObservacionesSRI, "[45]") {
+		t.Errorf("Observaciones sin mensaje del SRI: %q", factura.ObservacionesSRI)
+	}
+
+	historial, err := e.db.ObtenerHistorialEstados(context.Background(), id)
+	if err != nil {
+		t.Fatalf("ObtenerHistorialEstados() error: %v", err)
+	}
+	ultimo := historial[len(historial)-1]
+	if ultimo.EstadoNuevo != database.EstadoDevuelta || ultimo.Actor != ActorPipeline ||
+		len(ultimo.MensajesSRI) != 1 || ultimo.MensajesSRI[0].Identificador != "45" {
+		t.Errorf("Último registro de historial inesperado: %+v", ultimo)
+	}
+}
+
+func TestPipelineReenviaTrasErrorInternoSRI(t *testing.T) {
+	e := nuevoEntornoPrueba(t, configPrueba())
+	id, clave := e.crearFacturaPrueba(t)
+	e.simulador.ProgramarEscenario(clave, sri.EscenarioSRI{
+		Recepcion: []sri.RespuestaSimulada{
+			{
+				Estado:   sri.EstadoSRIDevuelta,
+				Mensajes: []sri.MensajeSRI{{Identificador: "50", Mensaje: "ERROR INTERNO GENERAL", Tipo: "ERROR"}},
+			},
+			{Estado: sri.EstadoSRIRecibida},
+		},
+	})
+
+	e.pipeline.Encolar(id)
+	e.procesarHasta(t, id, database.TrabajoCompletado)
+
+	factura, _ := e.db.ObtenerFacturaPorID(context.Background(), id)
+	if factura.Estado != database.EstadoAutorizada {
+		t.Errorf("Estado = %s, esperado AUTORIZADA tras reenviar", factura.Estado)
+	}
+	if estado, _ := e.simulador.Comprobante(clave); estado.Recepciones != 2 {
+		t.Errorf("Recepciones = %d, esperadas 2", estado.Recepciones)
+	}
+}
+
+// Un envío que llegó al SRI pero cuya respuesta se perdió vuelve como 70: se autoriza porque el
+// comprobante del SRI es el mismo que se firmó
+func TestPipelineRecepcionDuplicadaMismoComprobante(t *testing.T) {
+	e := nuevoEntornoPrueba(t, configPrueba())
+	id, clave := e.crearFacturaPrueba(t)
+	e.simulador.ProgramarEscenario(clave, sri.EscenarioSRI{
+		Recepcion: []sri.RespuestaSimulada{{Estado: sri.EstadoSRIRecibida, CodigoHTTP: 500}},
+	})
+
+	e.pipeline.Encolar(id)
+	trabajo := e.procesarHasta(t, id, database.TrabajoCompletado)
+
+	if !trabajo.RecepcionDuplicada {
+		t.Error("El trabajo debería registrar la recepción devuelta con 70")
+	}
+	factura, _ := e.db.ObtenerFacturaPorID(context.Background(), id)
+	if factura.Estado != database.EstadoAutorizada {
+		t.Errorf("Estado = %s, esperado AUTORIZADA", factura.Estado)
+	}
+}
+
+// Si la clave ya estaba autorizada para otro comprobante (43) la factura no se marca AUTORIZADA
+func TestPipelineRecepcionDuplicadaOtroComprobante(t *testing.T) {
+	e := nuevoEntornoPrueba(t, configPrueba())
+	id, clave := e.crearFacturaPrueba(t)
+
+	factura, err := e.db.ObtenerFacturaPorID(context.Background(), id)
+	if err != nil {
+		t.Fatalf("ObtenerFacturaPorID() error: %v", err)
+	}
+	otro := strings.Replace(factura.XMLOriginal, "CLIENTE PIPELINE", "OTRO CLIENTE", 1)
+	cliente := e.simulador.NuevoCliente()
+	if _, err := cliente.EnviarComprobante([]byte(otro)); err != nil {
+		t.Fatalf("EnviarComprobante() error: %v", err)
+	}
+	if _, err := cliente.ConsultarAutorizacion(clave); err != nil {
+		t.Fatalf("ConsultarAutorizacion() error: %v", err)
+	}
+
+	e.pipeline.Encolar(id)
+	e.procesarHasta(t, id, database.TrabajoCompletado)
+
+	factura, _ = e.db.ObtenerFacturaPorID(context.Background(), id)
+	if factura.Estado != database.EstadoNoAutorizada || factura.NumeroAutorizacion != "" {
+		t.Errorf("Estado = %s, número = %q; esperado NO_AUTORIZADA sin número", factura.Estado, factura.NumeroAutorizacion)
+	}
+	if !strings.Contains(factura.ObservacionesSRI, "distinto del firmado") {
+		t.Errorf("Observaciones sin la causa: %q", factura.ObservacionesSRI)
+	}
+}
+
+func TestMismoComprobante(t *testing.T) {
+	firmado := `<?xml version="1.0" encoding="UTF-8"?>` + "\n<factura id=\"comprobante\"><a>1</a></factura>"
+	if !mismoComprobante("<factura id=\"comprobante\"><a>1</a></factura>\n", firmado) {
+		t.Error("La declaración XML y los espacios alrededor no deberían importar")
+	}
+	if mismoComprobante(`<factura id="comprobante"><a>2</a></factura>`, firmado) {
+		t.Error("Comprobantes con contenido distinto no deberían coincidir")
+	}
+}
+
+func TestPipelineEsperaAutorizacionEnProceso(t *testing.T) {
+	e := nuevoEntornoPrueba(t, configPrueba())
+	id, clave := e.crearFacturaPrueba(t)
+	e.simulador.ProgramarEscenario(clave, sri.EscenarioEnProceso(2))
+
+	e.pipeline.Encolar(id)
+	e.procesarHasta(t, id, database.TrabajoCompletado)
+
+	factura, _ := e.db.ObtenerFacturaPorID(context.Background(), id)
+	if factura.Estado != "AUTORIZADA" {
+		t.Errorf("Estado = %s, esperado AUTORIZADA", factura.Estado)
+	}
+	if estado, _ := e.simulador.Comprobante(clave); estado.Consultas != 3 {
+		t.Errorf("Consultas = %d, esperadas 3", estado.Consultas)
+	}
+
+	// El historial recorre el ciclo completo una sola vez por estado
+	historial, _ := e.db.ObtenerHistorialEstados(context.Background(), id)
+	esperados := []string{database.EstadoFirmada, database.EstadoEnviada, database.EstadoRecibida,
+		database.EstadoEnProceso, database.EstadoAutorizada}
+	if len(historial) != len(esperados) {
+		t.Fatalf("Historial con %d registros, esperados %d: %+v", len(historial), len(esperados), historial)
+	}
+	for i, registro := range historial {
+		if registro.EstadoNuevo != esperados[i] {
+			t.Errorf("Transición %d = %s, esperada %s", i, registro.EstadoNuevo, esperados[i])
+		}
+	}
+}
+
+func TestPipelineFallaTrasAgotarIntentos(t *testing.T) {
+	cfg := configPrueba()
+	cfg.MaxIntentos = 2
+	e := nuevoEntornoPrueba(t, cfg)
+	id, clave := e.crearFacturaPrueba(t)
+	e.simulador.ProgramarEscenario(clave, sri.EscenarioEnProceso(10))
+
+	e.pipeline.Encolar(id)
+	trabajo := e.procesarHasta(t, id, database.TrabajoFallido)
+
+	if trabajo.Etapa != database.EtapaAutorizar || trabajo.Intentos != 2 {
+		t.Errorf("Trabajo fallido inesperado: etapa=%s intentos=%d", trabajo.Etapa, trabajo.Intentos)
+	}
+	factura, _ := e.db.ObtenerFacturaPorID(context.Background(), id)
+	if factura.Estado != database.EstadoEnProceso {
+		t.Errorf("Estado = %s, esperado EN_PROCESO", factura.Estado)
+	}
+}
+
+func TestPipelineWorkersYReinicio(t *testing.T) {
+	e := nuevoEntornoPrueba(t, configPrueba())
+
+	// Simular un trabajo que quedó EN_CURSO por una caída del proceso
+	idInterrumpida, _ := e.crearFacturaPrueba(t)
+	e.pipeline.Encolar(idInterrumpida)
+	if _, err := e.db.TomarTrabajoAutorizacion("proceso-anterior"); err != nil {
+		t.Fatalf("TomarTrabajoAutorizacion() error: %v", err)
+	}
+
+	ids := []int{idInterrumpida}
+	for i := 0; i < 3; i++ {
+		id, _ := e.crearFacturaPrueba(t)
+		ids = append(ids, id)
+	}
+
+	if err := e.pipeline.Iniciar(context.Background()); err != nil {
+		t.Fatalf("Iniciar() error: %v", err)
+	}
+	defer e.pipeline.Detener()
+
+	for _, id := range ids[1:] {
+		if _, err := e.pipeline.Encolar(id); err != nil {
+			t.Fatalf("Encolar() error: %v", err)
+		}
+	}
+
+	limite := time.Now().Add(5 * time.Second)
+	for _, id := range ids {
+		for {
+			factura, err := e.db.ObtenerFacturaPorID(context.Background(), id)
+			if err != nil {
+				t.Fatalf("ObtenerFacturaPorID() error: %v", err)
+			}
+			if factura.Estado == "AUTORIZADA" {
+				break
+			}
+			if time.Now().After(limite) {
+				t.Fatalf("Factura %d no autorizada, estado %s", id, factura.Estado)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+}
+
+func TestNuevoRequiereFirmador(t *testing.T) {
+	db, err := database.New(filepath.Join(t.TempDir(), "pipeline.db"))
+	if err != nil {
+		t.Fatalf("Error creando base de datos: %v", err)
+	}
+	defer db.Close()
+
+	if _, err := Nuevo(db, sri.NewSOAPClient(sri.Pruebas), nil, Config{}); err == nil {
+		t.Error("Nuevo() debería requerir un firmador")
+	}
+}
+
+func TestPipelineFallidoYReencolado(t *testing.T) {
+	cfg := configPrueba()
+	cfg.MaxIntentos = 2
+	e := nuevoEntornoPrueba(t, cfg)
+	id, clave := e.crearFacturaPrueba(t)
+	e.simulador.ProgramarEscenario(clave, sri.EscenarioEnProceso(10))
+
+	e.pipeline.Encolar(id)
+	trabajo := e.procesarHasta(t, id, database.TrabajoFallido)
+
+	fallidos, err := e.db.ListarFallidos(database.FallidoPendiente, 10, 0)
+	if err != nil {
+		t.Fatalf("ListarFallidos() error: %v", err)
+	}
+	if len(fallidos) != 1 {
+		t.Fatalf("Fallidos = %d, esperado 1", len(fallidos))
+	}
+	fallido := fallidos[0]
+	if fallido.FacturaID != id || fallido.TrabajoID != trabajo.ID || fallido.Etapa != database.EtapaAutorizar {
+		t.Errorf("Fallido inesperado: %+v", fallido)
+	}
+	if len(fallido.Intentos) != 2 {
+		t.Errorf("Intentos = %d, esperados 2", len(fallido.Intentos))
+	}
+
+	// El reencolado retoma la autorización con el XML firmado
+	nuevo, err := e.pipeline.ReencolarFallido(fallido.ID)
+	if err != nil {
+		t.Fatalf("ReencolarFallido() error: %v", err)
+	}
+	if nuevo.Etapa != database.EtapaAutorizar || nuevo.XMLFirmado == "" || nuevo.ID == trabajo.ID {
+		t.Errorf("Trabajo reencolado inesperado: etapa=%s id=%d", nuevo.Etapa, nuevo.ID)
+	}
+	if _, err := e.pipeline.ReencolarFallido(fallido.ID); !errors.Is(err, ErrFallidoNoReencolable) {
+		t.Errorf("Se esperaba ErrFallidoNoReencolable al reencolar dos veces, obtenido %v", err)
+	}
+}
+
+func TestClienteParaUsaFabricaPorAmbiente(t *testing.T) {
+	e := nuevoEntornoPrueba(t, configPrueba())
+
+	// Como en NuevoDesdeConfig: el cliente del ambiente configurado y la fábrica para los demás
+	creados := map[sri.Ambiente]int{}
+	e.pipeline.clienteAmbiente = func(ambiente sri.Ambiente) ClienteSRI {
+		creados[ambiente]++
+		return e.simulador.NuevoCliente()
+	}
+	e.pipeline.clientes = map[sri.Ambiente]ClienteSRI{sri.Pruebas: e.pipeline.cliente}
+
+	claveDe := func(ambiente sri.Ambiente) string {
+		clave, err := sri.GenerarClaveAcceso(sri.ClaveAccesoConfig{
+			FechaEmision:     time.Now(),
+			TipoComprobante:  sri.Factura,
+			RUCEmisor:        "1792146739001",
+			Ambiente:         ambiente,
+			Serie:            "001001",
+			NumeroSecuencial: "000000001",
+			CodigoNumerico:   "12345678",
+			TipoEmision:      sri.EmisionNormal,
+		})
+		if err != nil {
+			t.Fatalf("GenerarClaveAcceso() error: %v", err)
+		}
+		return clave
+	}
+
+	if cliente := e.pipeline.clientePara(claveDe(sri.Pruebas)); cliente != e.pipeline.cliente {
+		t.Error("El ambiente configurado debería usar el cliente del pipeline")
+	}
+	produccion := e.pipeline.clientePara(claveDe(sri.Produccion))
+	if produccion == e.pipeline.cliente {
+		t.Error("Producción debería usar el cliente de la fábrica")
+	}
+	if e.pipeline.clientePara(claveDe(sri.Produccion)) != produccion || creados[sri.Produccion] != 1 || creados[sri.Pruebas] != 0 {
+		t.Errorf("La fábrica debería llamarse una vez por ambiente: %v", creados)
+	}
+}
@@ -0,0 +1,102 @@
+// Package secrets comandos de línea para administrar el keystore local
+package secrets
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+
+	"go-facturacion-sri/config"
+)
+
+// EjecutarCLI administra el keystore configurado en config.SecretosConfig
+//
+// Uso:
+//
+//	secretos guardar <nombre>   (lee el valor desde stdin)
+//	secretos listar
+//	secretos eliminar <nombre>
+func EjecutarCLI(args []string, cfg config.SecretosConfig, entrada io.Reader) error {
+	if len(args) == 0 {
+		return fmt.Errorf("uso: secretos [guardar <nombre> | listar | eliminar <nombre>]")
+	}
+
+	if cfg.RutaKeystore == "" {
+		return fmt.Errorf("secretos.rutaKeystore no está configurado")
+	}
+
+	variable := cfg.VariableClaveMaestra
+	if variable == "" {
+		variable = VariableClaveMaestraDefault
+	}
+	claveMaestra := os.Getenv(variable)
+	if claveMaestra == "" {
+		return fmt.Errorf("defina la clave maestra en la variable de entorno %s", variable)
+	}
+
+	// Solo guardar crea el keystore; los demás comandos fallan si la ruta está mal escrita
+	abrir := AbrirKeystore
+	if args[0] == "guardar" {
+		abrir = AbrirOCrearKeystore
+	}
+	ks, err := abrir(cfg.RutaKeystore, []byte(claveMaestra))
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "guardar":
+		if len(args) < 2 {
+			return fmt.Errorf("uso: secretos guardar <nombre>")
+		}
+		valor, err := leerValor(entrada)
+		if err != nil {
+			return err
+		}
+		if err := ks.Guardar(args[1], valor); err != nil {
+			return err
+		}
+		fmt.Printf("✅ Secreto '%s' guardado en %s\n", args[1], cfg.RutaKeystore)
+		fmt.Printf("💡 Referéncielo en la configuración como \"keystore:%s\"\n", args[1])
+
+	case "listar":
+		nombres := ks.Listar()
+		fmt.Printf("🔐 Secretos en %s: %d\n", cfg.RutaKeystore, len(nombres))
+		for _, nombre := range nombres {
+			fmt.Printf("   • %s\n", nombre)
+		}
+
+	case "eliminar":
+		if len(args) < 2 {
+			return fmt.Errorf("uso: secretos eliminar <nombre>")
+		}
+		if err := ks.Eliminar(args[1]); err != nil {
+			return err
+		}
+		fmt.Printf("🗑️  Secreto '%s' eliminado\n", args[1])
+
+	default:
+		return fmt.Errorf("comando desconocido: %s", args[0])
+	}
+
+	return nil
+}
+
+// leerValor lee una línea desde la entrada sin dejar el valor en argumentos del proceso
+func leerValor(entrada io.Reader) ([]byte, error) {
+	lector := bufio.NewReader(entrada)
+	linea, err := lector.ReadBytes('\n')
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("error leyendo valor: %v", err)
+	}
+
+	for len(linea) > 0 && (linea[len(linea)-1] == '\n' || linea[len(linea)-1] == '\r') {
+		linea = linea[:len(linea)-1]
+	}
+	if len(linea) == 0 {
+		return nil, fmt.Errorf("valor vacío")
+	}
+
+	return linea, nil
+}
@@ -0,0 +1,290 @@
+// Package secrets implementa un keystore local cifrado con clave maestra
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// Parámetros de derivación de la clave de cifrado
+const (
+	versionKeystore      = 1
+	iteracionesPBKDF2    = 210000
+	longitudSalt         = 16
+	longitudClaveCifrado = 32 // AES-256
+)
+
+// entradaKeystore secreto cifrado con AES-256-GCM
+type entradaKeystore struct {
+	Nonce string `json:"nonce"`
+	Datos string `json:"datos"`
+}
+
+// archivoKeystore formato en disco del keystore
+type archivoKeystore struct {
+	Version     int                        `json:"version"`
+	Salt        string                     `json:"salt"`
+	Iteraciones int                        `json:"iteraciones"`
+	Verificador entradaKeystore            `json:"verificador"`
+	Entradas    map[string]entradaKeystore `json:"entradas"`
+}
+
+// Keystore almacén local de secretos cifrados con una clave maestra
+type Keystore struct {
+	mu      sync.Mutex
+	ruta    string
+	aead    cipher.AEAD
+	archivo archivoKeystore
+}
+
+// ErrKeystoreNoExiste la ruta configurada no tiene un keystore; solo "secretos guardar" lo crea
+var ErrKeystoreNoExiste = errors.New("keystore no existe")
+
+// textoVerificador valor conocido cifrado para detectar una clave maestra incorrecta
+var textoVerificador = []byte("go-facturacion-sri/keystore")
+
+// AbrirKeystore abre un keystore existente; si la ruta no existe devuelve ErrKeystoreNoExiste.
+// La clave maestra se borra de memoria después de derivar la clave de cifrado.
+func AbrirKeystore(ruta string, claveMaestra []byte) (*Keystore, error) {
+	return abrirKeystore(ruta, claveMaestra, false)
+}
+
+// AbrirOCrearKeystore abre un keystore existente o crea uno nuevo si no existe
+func AbrirOCrearKeystore(ruta string, claveMaestra []byte) (*Keystore, error) {
+	return abrirKeystore(ruta, claveMaestra, true)
+}
+
+func abrirKeystore(ruta string, claveMaestra []byte, crear bool) (*Keystore, error) {
+	defer Borrar(claveMaestra)
+
+	if len(claveMaestra) == 0 {
+		return nil, fmt.Errorf("clave maestra requerida")
+	}
+
+	ks := &Keystore{ruta: ruta}
+
+	data, err := os.ReadFile(ruta)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("error leyendo keystore: %v", err)
+	}
+
+	if os.IsNotExist(err) {
+		if !crear {
+			return nil, fmt.Errorf("%w: %s", ErrKeystoreNoExiste, ruta)
+		}
+
+		// Keystore nuevo
+		salt := make([]byte, longitudSalt)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, fmt.Errorf("error generando salt: %v", err)
+		}
+
+		ks.archivo = archivoKeystore{
+			Version:     versionKeystore,
+			Salt:        base64.StdEncoding.EncodeToString(salt),
+			Iteraciones: iteracionesPBKDF2,
+			Entradas:    make(map[string]entradaKeystore),
+		}
+
+		if err := ks.derivarClave(claveMaestra, salt); err != nil {
+			return nil, err
+		}
+
+		verificador, err := ks.cifrar("", textoVerificador)
+		if err != nil {
+			return nil, err
+		}
+		ks.archivo.Verificador = verificador
+
+		if err := ks.guardar(); err != nil {
+			return nil, err
+		}
+		return ks, nil
+	}
+
+	// Keystore existente
+	if err := json.Unmarshal(data, &ks.archivo); err != nil {
+		return nil, fmt.Errorf("keystore corrupto: %v", err)
+	}
+	if ks.archivo.Version != versionKeystore {
+		return nil, fmt.Errorf("versión de keystore no soportada: %d", ks.archivo.Version)
+	}
+	if ks.archivo.Entradas == nil {
+		ks.archivo.Entradas = make(map[string]entradaKeystore)
+	}
+
+	salt, err := base64.StdEncoding.DecodeString(ks.archivo.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("salt inválido en keystore: %v", err)
+	}
+
+	if err := ks.derivarClave(claveMaestra, salt); err != nil {
+		return nil, err
+	}
+
+	verificador, err := ks.descifrar("", ks.archivo.Verificador)
+	if err != nil {
+		return nil, fmt.Errorf("clave maestra incorrecta")
+	}
+	Borrar(verificador)
+
+	return ks, nil
+}
+
+// derivarClave deriva la clave AES a partir de la clave maestra con PBKDF2-SHA256
+func (ks *Keystore) derivarClave(claveMaestra, salt []byte) error {
+	iteraciones := ks.archivo.Iteraciones
+	if iteraciones <= 0 {
+		iteraciones = iteracionesPBKDF2
+	}
+
+	clave, err := pbkdf2.Key(sha256.New, string(claveMaestra), salt, iteraciones, longitudClaveCifrado)
+	if err != nil {
+		return fmt.Errorf("error derivando clave: %v", err)
+	}
+	defer Borrar(clave)
+
+	bloque, err := aes.NewCipher(clave)
+	if err != nil {
+		return fmt.Errorf("error creando cifrador: %v", err)
+	}
+
+	aead, err := cipher.NewGCM(bloque)
+	if err != nil {
+		return fmt.Errorf("error creando GCM: %v", err)
+	}
+
+	ks.aead = aead
+	return nil
+}
+
+// cifrar cifra un valor usando el nombre como dato adicional autenticado
+func (ks *Keystore) cifrar(nombre string, valor []byte) (entradaKeystore, error) {
+	nonce := make([]byte, ks.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return entradaKeystore{}, fmt.Errorf("error generando nonce: %v", err)
+	}
+
+	cifrado := ks.aead.Seal(nil, nonce, valor, []byte(nombre))
+
+	return entradaKeystore{
+		Nonce: base64.StdEncoding.EncodeToString(nonce),
+		Datos: base64.StdEncoding.EncodeToString(cifrado),
+	}, nil
+}
+
+// descifrar descifra una entrada verificando su autenticidad
+func (ks *Keystore) descifrar(nombre string, entrada entradaKeystore) ([]byte, error) {
+	nonce, err := base64.StdEncoding.DecodeString(entrada.Nonce)
+	if err != nil {
+		return nil, fmt.Errorf("nonce inválido: %v", err)
+	}
+
+	cifrado, err := base64.StdEncoding.DecodeString(entrada.Datos)
+	if err != nil {
+		return nil, fmt.Errorf("datos cifrados inválidos: %v", err)
+	}
+
+	valor, err := ks.aead.Open(nil, nonce, cifrado, []byte(nombre))
+	if err != nil {
+		return nil, fmt.Errorf("error descifrando secreto '%s': %v", nombre, err)
+	}
+
+	return valor, nil
+}
+
+// guardar escribe el keystore a disco de forma atómica con permisos restringidos
+func (ks *Keystore) guardar() error {
+	data, err := json.MarshalIndent(ks.archivo, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error serializando keystore: %v", err)
+	}
+
+	if dir := filepath.Dir(ks.ruta); dir != "" {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return fmt.Errorf("error creando directorio del keystore: %v", err)
+		}
+	}
+
+	temporal := ks.ruta + ".tmp"
+	if err := os.WriteFile(temporal, data, 0600); err != nil {
+		return fmt.Errorf("error escribiendo keystore: %v", err)
+	}
+
+	if err := os.Rename(temporal, ks.ruta); err != nil {
+		os.Remove(temporal)
+		return fmt.Errorf("error reemplazando keystore: %v", err)
+	}
+
+	return nil
+}
+
+// Obtener implementa Proveedor descifrando el secreto solicitado
+func (ks *Keystore) Obtener(nombre string) ([]byte, error) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	entrada, ok := ks.archivo.Entradas[nombre]
+	if !ok {
+		return nil, &ErrSecretoNoEncontrado{Proveedor: "keystore", Nombre: nombre}
+	}
+
+	return ks.descifrar(nombre, entrada)
+}
+
+// Guardar cifra y almacena un secreto. El valor se borra de memoria al terminar.
+func (ks *Keystore) Guardar(nombre string, valor []byte) error {
+	defer Borrar(valor)
+
+	if nombre == "" {
+		return fmt.Errorf("nombre de secreto requerido")
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	entrada, err := ks.cifrar(nombre, valor)
+	if err != nil {
+		return err
+	}
+
+	ks.archivo.Entradas[nombre] = entrada
+	return ks.guardar()
+}
+
+// Eliminar borra un secreto del keystore
+func (ks *Keystore) Eliminar(nombre string) error {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	if _, ok := ks.archivo.Entradas[nombre]; !ok {
+		return &ErrSecretoNoEncontrado{Proveedor: "keystore", Nombre: nombre}
+	}
+
+	delete(ks.archivo.Entradas, nombre)
+	return ks.guardar()
+}
+
+// Listar retorna los nombres de los secretos almacenados (nunca sus valores)
+func (ks *Keystore) Listar() []string {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	nombres := make([]string, 0, len(ks.archivo.Entradas))
+	for nombre := range ks.archivo.Entradas {
+		nombres = append(nombres, nombre)
+	}
+	sort.Strings(nombres)
+	return nombres
+}
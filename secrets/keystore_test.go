@@ -0,0 +1,153 @@
+package secrets
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go-facturacion-sri/config"
+)
+
+func TestKeystoreGuardarYObtener(t *testing.T) {
+	ruta := filepath.Join(t.TempDir(), "secretos", "keystore.json")
+
+	ks, err := AbrirOCrearKeystore(ruta, []byte("clave-maestra"))
+	if err != nil {
+		t.Fatalf("AbrirOCrearKeystore() error: %v", err)
+	}
+
+	valor := []byte("password-p12")
+	if err := ks.Guardar("cert_password", valor); err != nil {
+		t.Fatalf("Guardar() error: %v", err)
+	}
+
+	// Guardar debe borrar el valor recibido
+	if !bytes.Equal(valor, make([]byte, len(valor))) {
+		t.Error("Guardar() no borró el valor de memoria")
+	}
+
+	// El archivo no debe contener el secreto en claro
+	data, err := os.ReadFile(ruta)
+	if err != nil {
+		t.Fatalf("Error leyendo keystore: %v", err)
+	}
+	if bytes.Contains(data, []byte("password-p12")) {
+		t.Error("El keystore contiene el secreto en texto plano")
+	}
+
+	info, err := os.Stat(ruta)
+	if err != nil {
+		t.Fatalf("Error obteniendo permisos: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Permisos del keystore = %v, esperado 0600", info.Mode().Perm())
+	}
+
+	// Reabrir con la misma clave maestra
+	ks2, err := AbrirKeystore(ruta, []byte("clave-maestra"))
+	if err != nil {
+		t.Fatalf("AbrirKeystore() reabriendo: %v", err)
+	}
+
+	obtenido, err := ks2.Obtener("cert_password")
+	if err != nil {
+		t.Fatalf("Obtener() error: %v", err)
+	}
+	if string(obtenido) != "password-p12" {
+		t.Errorf("Obtener() = %q, esperado %q", obtenido, "password-p12")
+	}
+
+	if nombres := ks2.Listar(); len(nombres) != 1 || nombres[0] != "cert_password" {
+		t.Errorf("Listar() = %v", nombres)
+	}
+}
+
+func TestKeystoreClaveMaestraIncorrecta(t *testing.T) {
+	ruta := filepath.Join(t.TempDir(), "keystore.json")
+
+	if _, err := AbrirOCrearKeystore(ruta, []byte("correcta")); err != nil {
+		t.Fatalf("AbrirOCrearKeystore() error: %v", err)
+	}
+
+	if _, err := AbrirKeystore(ruta, []byte("incorrecta")); err == nil {
+		t.Error("AbrirKeystore() debería fallar con clave maestra incorrecta")
+	}
+
+	if _, err := AbrirKeystore(ruta, nil); err == nil {
+		t.Error("AbrirKeystore() debería fallar sin clave maestra")
+	}
+}
+
+func TestKeystoreEliminar(t *testing.T) {
+	ruta := filepath.Join(t.TempDir(), "keystore.json")
+
+	ks, err := AbrirOCrearKeystore(ruta, []byte("maestra"))
+	if err != nil {
+		t.Fatalf("AbrirOCrearKeystore() error: %v", err)
+	}
+
+	if err := ks.Guardar("smtp_password", []byte("x")); err != nil {
+		t.Fatalf("Guardar() error: %v", err)
+	}
+	if err := ks.Eliminar("smtp_password"); err != nil {
+		t.Fatalf("Eliminar() error: %v", err)
+	}
+
+	if _, err := ks.Obtener("smtp_password"); err == nil {
+		t.Error("Obtener() debería fallar tras eliminar")
+	}
+	if err := ks.Eliminar("smtp_password"); err == nil {
+		t.Error("Eliminar() debería fallar para secreto inexistente")
+	}
+}
+
+func TestKeystoreEntradaManipulada(t *testing.T) {
+	ruta := filepath.Join(t.TempDir(), "keystore.json")
+
+	ks, err := AbrirOCrearKeystore(ruta, []byte("maestra"))
+	if err != nil {
+		t.Fatalf("AbrirOCrearKeystore() error: %v", err)
+	}
+	if err := ks.Guardar("a", []byte("valor-a")); err != nil {
+		t.Fatalf("Guardar() error: %v", err)
+	}
+	if err := ks.Guardar("b", []byte("valor-b")); err != nil {
+		t.Fatalf("Guardar() error: %v", err)
+	}
+
+	// Copiar la entrada cifrada de "a" bajo el nombre "b": el AAD debe impedirlo
+	ks.archivo.Entradas["b"] = ks.archivo.Entradas["a"]
+	if _, err := ks.Obtener("b"); err == nil {
+		t.Error("Obtener() debería detectar una entrada intercambiada")
+	}
+}
+
+func TestAbrirKeystoreInexistente(t *testing.T) {
+	ruta := filepath.Join(t.TempDir(), "keystore.json")
+
+	if _, err := AbrirKeystore(ruta, []byte("maestra")); !errors.Is(err, ErrKeystoreNoExiste) {
+		t.Fatalf("AbrirKeystore() = %v, esperado ErrKeystoreNoExiste", err)
+	}
+	if _, err := os.Stat(ruta); !os.IsNotExist(err) {
+		t.Error("AbrirKeystore() no debería crear el archivo")
+	}
+
+	cfg := config.SecretosConfig{RutaKeystore: ruta, VariableClaveMaestra: "TEST_CLAVE_MAESTRA"}
+	t.Setenv("TEST_CLAVE_MAESTRA", "maestra")
+	if _, err := NuevoResolvedorDesdeConfig(cfg); !errors.Is(err, ErrKeystoreNoExiste) {
+		t.Errorf("NuevoResolvedorDesdeConfig() = %v, esperado ErrKeystoreNoExiste", err)
+	}
+	if err := EjecutarCLI([]string{"listar"}, cfg, strings.NewReader("")); !errors.Is(err, ErrKeystoreNoExiste) {
+		t.Errorf("secretos listar = %v, esperado ErrKeystoreNoExiste", err)
+	}
+
+	if err := EjecutarCLI([]string{"guardar", "cert_password"}, cfg, strings.NewReader("valor\n")); err != nil {
+		t.Fatalf("secretos guardar error: %v", err)
+	}
+	if _, err := AbrirKeystore(ruta, []byte("maestra")); err != nil {
+		t.Errorf("AbrirKeystore() tras guardar: %v", err)
+	}
+}
@@ -0,0 +1,187 @@
+// Package secrets resuelve credenciales (contraseñas de certificados, claves de API y de firma)
+// sin mantenerlas en texto plano dentro de los archivos de configuración
+package secrets
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"go-facturacion-sri/config"
+)
+
+// VariableClaveMaestraDefault variable de entorno que contiene la clave maestra del keystore
+const VariableClaveMaestraDefault = "FACTURACION_MASTER_KEY"
+
+// Proveedor define el contrato para cualquier fuente de secretos
+type Proveedor interface {
+	// Obtener retorna una copia del secreto. El llamador debe borrarlo con Borrar
+	// cuando ya no lo necesite.
+	Obtener(nombre string) ([]byte, error)
+}
+
+// ErrSecretoNoEncontrado indica que el proveedor no tiene el secreto solicitado
+type ErrSecretoNoEncontrado struct {
+	Proveedor string
+	Nombre    string
+}
+
+// Error implementa la interfaz error
+func (e *ErrSecretoNoEncontrado) Error() string {
+	return fmt.Sprintf("secreto '%s' no encontrado en proveedor %s", e.Nombre, e.Proveedor)
+}
+
+// Borrar sobrescribe con ceros el contenido de un secreto en memoria
+func Borrar(secreto []byte) {
+	for i := range secreto {
+		secreto[i] = 0
+	}
+}
+
+// EnvProvider obtiene secretos desde variables de entorno
+type EnvProvider struct {
+	Prefijo string // Prefijo opcional, ej: "FACTURACION_"
+}
+
+// Obtener implementa Proveedor leyendo la variable de entorno Prefijo+nombre
+func (p *EnvProvider) Obtener(nombre string) ([]byte, error) {
+	valor, ok := os.LookupEnv(p.Prefijo + nombre)
+	if !ok {
+		return nil, &ErrSecretoNoEncontrado{Proveedor: "env", Nombre: p.Prefijo + nombre}
+	}
+	return []byte(valor), nil
+}
+
+// FileProvider obtiene secretos desde archivos (un secreto por archivo),
+// compatible con Docker/Kubernetes secrets montados como archivos
+type FileProvider struct {
+	Directorio string // Directorio base para rutas relativas
+}
+
+// Obtener implementa Proveedor leyendo el archivo indicado
+func (p *FileProvider) Obtener(nombre string) ([]byte, error) {
+	ruta := nombre
+	if p.Directorio != "" && !filepath.IsAbs(nombre) {
+		ruta = filepath.Join(p.Directorio, nombre)
+	}
+
+	data, err := os.ReadFile(ruta)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, &ErrSecretoNoEncontrado{Proveedor: "file", Nombre: ruta}
+		}
+		return nil, fmt.Errorf("error leyendo secreto %s: %v", ruta, err)
+	}
+
+	// Eliminar salto de línea final típico de archivos de texto
+	for len(data) > 0 && (data[len(data)-1] == '\n' || data[len(data)-1] == '\r') {
+		data[len(data)-1] = 0
+		data = data[:len(data)-1]
+	}
+
+	return data, nil
+}
+
+// Resolvedor resuelve referencias de secretos del tipo "esquema:nombre"
+//
+// Formatos soportados:
+//   - "env:CERT_PASSWORD"      variable de entorno
+//   - "${CERT_PASSWORD}"       variable de entorno (compatibilidad con produccion.json)
+//   - "file:/run/secrets/cert" archivo
+//   - "keystore:cert_password" keystore local cifrado
+//   - cualquier otro valor se interpreta como literal (compatibilidad hacia atrás)
+type Resolvedor struct {
+	mu          sync.RWMutex
+	proveedores map[string]Proveedor
+}
+
+// NuevoResolvedor crea un resolvedor con los proveedores env y file registrados
+func NuevoResolvedor() *Resolvedor {
+	r := &Resolvedor{
+		proveedores: make(map[string]Proveedor),
+	}
+	r.Registrar("env", &EnvProvider{})
+	r.Registrar("file", &FileProvider{})
+	return r
+}
+
+// NuevoResolvedorDesdeConfig crea un resolvedor según config.SecretosConfig.
+// Si hay keystore configurado se abre con la clave maestra de la variable de entorno.
+func NuevoResolvedorDesdeConfig(cfg config.SecretosConfig) (*Resolvedor, error) {
+	r := NuevoResolvedor()
+
+	if cfg.DirectorioArchivos != "" {
+		r.Registrar("file", &FileProvider{Directorio: cfg.DirectorioArchivos})
+	}
+
+	if cfg.RutaKeystore != "" {
+		variable := cfg.VariableClaveMaestra
+		if variable == "" {
+			variable = VariableClaveMaestraDefault
+		}
+
+		claveMaestra, ok := os.LookupEnv(variable)
+		if !ok || claveMaestra == "" {
+			return nil, fmt.Errorf("keystore configurado pero la variable %s no está definida", variable)
+		}
+
+		ks, err := AbrirKeystore(cfg.RutaKeystore, []byte(claveMaestra))
+		if err != nil {
+			return nil, fmt.Errorf("error abriendo keystore: %w", err)
+		}
+		r.Registrar("keystore", ks)
+	}
+
+	return r, nil
+}
+
+// Registrar agrega o reemplaza el proveedor asociado a un esquema
+func (r *Resolvedor) Registrar(esquema string, proveedor Proveedor) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.proveedores[esquema] = proveedor
+}
+
+// Resolver obtiene el valor de una referencia de secreto
+func (r *Resolvedor) Resolver(referencia string) ([]byte, error) {
+	if referencia == "" {
+		return nil, nil
+	}
+
+	esquema, nombre, esReferencia := parsearReferencia(referencia)
+	if !esReferencia {
+		return []byte(referencia), nil
+	}
+
+	r.mu.RLock()
+	proveedor, ok := r.proveedores[esquema]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("proveedor de secretos '%s' no registrado", esquema)
+	}
+
+	return proveedor.Obtener(nombre)
+}
+
+// EsReferencia indica si un valor de configuración es una referencia a un secreto
+func EsReferencia(valor string) bool {
+	_, _, ok := parsearReferencia(valor)
+	return ok
+}
+
+// parsearReferencia separa esquema y nombre de una referencia
+func parsearReferencia(valor string) (esquema, nombre string, ok bool) {
+	if strings.HasPrefix(valor, "${") && strings.HasSuffix(valor, "}") {
+		return "env", valor[2 : len(valor)-1], true
+	}
+
+	for _, prefijo := range []string{"env", "file", "keystore"} {
+		if strings.HasPrefix(valor, prefijo+":") {
+			return prefijo, valor[len(prefijo)+1:], true
+		}
+	}
+
+	return "", "", false
+}
@@ -0,0 +1,120 @@
+package secrets
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go-facturacion-sri/config"
+)
+
+func TestResolvedorReferencias(t *testing.T) {
+	t.Setenv("TEST_SECRETO_CERT", "clave-env")
+
+	dir := t.TempDir()
+	rutaArchivo := filepath.Join(dir, "cert_password")
+	if err := os.WriteFile(rutaArchivo, []byte("clave-archivo\n"), 0600); err != nil {
+		t.Fatalf("Error creando archivo de secreto: %v", err)
+	}
+
+	r := NuevoResolvedor()
+
+	tests := []struct {
+		name       string
+		referencia string
+		esperado   string
+		expectErr  bool
+	}{
+		{"Literal", "clave-literal", "clave-literal", false},
+		{"Vacío", "", "", false},
+		{"Variable de entorno", "env:TEST_SECRETO_CERT", "clave-env", false},
+		{"Variable estilo ${}", "${TEST_SECRETO_CERT}", "clave-env", false},
+		{"Archivo", "file:" + rutaArchivo, "clave-archivo", false},
+		{"Variable inexistente", "env:TEST_SECRETO_INEXISTENTE", "", true},
+		{"Archivo inexistente", "file:" + filepath.Join(dir, "nada"), "", true},
+		{"Keystore no registrado", "keystore:cert", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			valor, err := r.Resolver(tt.referencia)
+			if tt.expectErr {
+				if err == nil {
+					t.Errorf("Resolver(%q) esperaba error", tt.referencia)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Resolver(%q) error inesperado: %v", tt.referencia, err)
+			}
+			if string(valor) != tt.esperado {
+				t.Errorf("Resolver(%q) = %q, esperado %q", tt.referencia, valor, tt.esperado)
+			}
+		})
+	}
+}
+
+func TestEsReferencia(t *testing.T) {
+	casos := map[string]bool{
+		"env:X":          true,
+		"${X}":           true,
+		"file:/tmp/x":    true,
+		"keystore:x":     true,
+		"password123":    false,
+		"":               false,
+		"https://sri.ec": false,
+	}
+
+	for valor, esperado := range casos {
+		if EsReferencia(valor) != esperado {
+			t.Errorf("EsReferencia(%q) = %v, esperado %v", valor, !esperado, esperado)
+		}
+	}
+}
+
+func TestBorrar(t *testing.T) {
+	secreto := []byte("super-secreto")
+	Borrar(secreto)
+
+	for i, b := range secreto {
+		if b != 0 {
+			t.Fatalf("byte %d no fue borrado: %v", i, b)
+		}
+	}
+}
+
+func TestNuevoResolvedorDesdeConfigKeystore(t *testing.T) {
+	ruta := filepath.Join(t.TempDir(), "keystore.json")
+
+	ks, err := AbrirOCrearKeystore(ruta, []byte("maestra"))
+	if err != nil {
+		t.Fatalf("AbrirOCrearKeystore() error: %v", err)
+	}
+	if err := ks.Guardar("cert_password", []byte("desde-keystore")); err != nil {
+		t.Fatalf("Guardar() error: %v", err)
+	}
+
+	cfg := config.SecretosConfig{
+		RutaKeystore:         ruta,
+		VariableClaveMaestra: "TEST_CLAVE_MAESTRA",
+	}
+
+	// Sin clave maestra definida debe fallar
+	if _, err := NuevoResolvedorDesdeConfig(cfg); err == nil {
+		t.Error("NuevoResolvedorDesdeConfig() esperaba error sin clave maestra")
+	}
+
+	t.Setenv("TEST_CLAVE_MAESTRA", "maestra")
+	r, err := NuevoResolvedorDesdeConfig(cfg)
+	if err != nil {
+		t.Fatalf("NuevoResolvedorDesdeConfig() error: %v", err)
+	}
+
+	valor, err := r.Resolver("keystore:cert_password")
+	if err != nil {
+		t.Fatalf("Resolver() error: %v", err)
+	}
+	if string(valor) != "desde-keystore" {
+		t.Errorf("Resolver() = %q, esperado %q", valor, "desde-keystore")
+	}
+}
@@ -20,7 +20,7 @@ func TestErroresSOAPAvanzados(t *testing.T) {
 	}{
 		{
 			name:          "Error de clave de acceso registrada",
-			mensajeError:  "CLAVE-01: Clave de acceso ya registrada",
+			mensajeError:  "43: CLAVE ACCESO REGISTRADA",
 			codigoHTTP:    400,
 			esRecuperable: false,
 			tipoEsperado:  ErrorClaveAcceso,
@@ -34,14 +34,14 @@ func TestErroresSOAPAvanzados(t *testing.T) {
 		},
 		{
 			name:          "Error de certificado expirado",
-			mensajeError:  "CERT-01: Certificado expirado",
+			mensajeError:  "40: ERROR EN EL CERTIFICADO",
 			codigoHTTP:    403,
 			esRecuperable: false,
 			tipoEsperado:  ErrorCertificado,
 		},
 		{
 			name:          "Error de sistema SRI",
-			mensajeError:  "SRI-01: Sistema en mantenimiento",
+			mensajeError:  "50: ERROR INTERNO GENERAL",
 			codigoHTTP:    503,
 			esRecuperable: true,
 			tipoEsperado:  ErrorSistema,
@@ -0,0 +1,127 @@
+// Package sri implementa el archivo de los mensajes SOAP intercambiados con el SRI
+package sri
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Operaciones SOAP que se archivan
+const (
+	OperacionRecepcion        = "RECEPCION"
+	OperacionAutorizacion     = "AUTORIZACION"
+	OperacionAutorizacionLote = "AUTORIZACION_LOTE"
+)
+
+// IntercambioSOAP petición enviada al SRI y respuesta recibida, con sus tiempos
+type IntercambioSOAP struct {
+	Operacion   string // RECEPCION, AUTORIZACION, AUTORIZACION_LOTE
+	ClaveAcceso string // Clave del comprobante o del lote
+	Ambiente    Ambiente
+	Endpoint    string
+	Solicitud   []byte // Envelope SOAP enviado
+	Respuesta   []byte // Cuerpo recibido (vacío si no hubo respuesta)
+	CodigoHTTP  int    // 0 si la petición no obtuvo respuesta
+	Duracion    time.Duration
+	Error       string    // Error de transporte, si lo hubo
+	Fecha       time.Time // Inicio de la petición
+}
+
+// ArchivoSOAP almacena los intercambios SOAP para cumplimiento y diagnóstico
+type ArchivoSOAP interface {
+	ArchivarIntercambio(intercambio IntercambioSOAP) error
+}
+
+// ConfigurarArchivo define dónde se archivan los mensajes SOAP del cliente (nil lo deshabilita)
+func (c *SOAPClient) ConfigurarArchivo(archivo ArchivoSOAP) {
+	c.archivo = archivo
+}
+
+// ejecutarSOAP espera turno en el limitador, envía el envelope al endpoint y archiva la
+// petición y la respuesta. Un fallo del archivo se registra en el log pero no interrumpe
+// la comunicación con el SRI.
+func (c *SOAPClient) ejecutarSOAP(ctx context.Context, operacion, claveAcceso, endpoint string, soapRequest []byte) ([]byte, int, error) {
+	if c.limitador != nil {
+		liberar, err := c.limitador.Adquirir(ctx)
+		if err != nil {
+			return nil, 0, err
+		}
+		defer liberar()
+	}
+
+	intercambio := IntercambioSOAP{
+		Operacion:   operacion,
+		ClaveAcceso: claveAcceso,
+		Ambiente:    c.Ambiente,
+		Endpoint:    endpoint,
+		Solicitud:   soapRequest,
+		Fecha:       time.Now(),
+	}
+
+	respBody, codigo, err := c.hacerPeticionSOAP(ctx, endpoint, soapRequest)
+
+	if c.archivo != nil {
+		intercambio.Respuesta = respBody
+		intercambio.CodigoHTTP = codigo
+		intercambio.Duracion = time.Since(intercambio.Fecha)
+		if err != nil {
+			intercambio.Error = err.Error()
+		}
+		if errArchivo := c.archivo.ArchivarIntercambio(intercambio); errArchivo != nil {
+			log.Printf("[ARCHIVO_SOAP] No se pudo archivar %s %s: %v", operacion, claveAcceso, errArchivo)
+		}
+	}
+
+	return respBody, codigo, err
+}
+
+// hacerPeticionSOAP realiza el POST del envelope y lee la respuesta completa
+func (c *SOAPClient) hacerPeticionSOAP(ctx context.Context, endpoint string, soapRequest []byte) ([]byte, int, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewBuffer(soapRequest))
+	if err != nil {
+		return nil, 0, fmt.Errorf("error creando petición HTTP: %v", err)
+	}
+
+	// Headers requeridos por SRI
+	req.Header.Set("Content-Type", "text/xml; charset=utf-8")
+	req.Header.Set("SOAPAction", "")
+	req.Header.Set("Content-Length", fmt.Sprintf("%d", len(soapRequest)))
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, 0, ctx.Err()
+		}
+		return nil, 0, fmt.Errorf("error enviando petición al SRI: %v", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, resp.StatusCode, fmt.Errorf("error leyendo respuesta del SRI: %v", err)
+	}
+
+	return respBody, resp.StatusCode, nil
+}
+
+// claveAccesoDocumento extrae la clave de acceso de un comprobante o de un lote masivo
+func claveAccesoDocumento(documento []byte) string {
+	var contenido struct {
+		ClaveAcceso     string `xml:"claveAcceso"`
+		ClaveTributaria string `xml:"infoTributaria>claveAcceso"`
+	}
+	if err := xml.Unmarshal(documento, &contenido); err != nil {
+		return ""
+	}
+	if contenido.ClaveTributaria != "" {
+		return strings.TrimSpace(contenido.ClaveTributaria)
+	}
+	return strings.TrimSpace(contenido.ClaveAcceso)
+}
@@ -0,0 +1,90 @@
+package sri
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// archivoSOAPMemoria archivo SOAP en memoria para tests
+type archivoSOAPMemoria struct {
+	mu           sync.Mutex
+	intercambios []IntercambioSOAP
+	err          error
+}
+
+func (a *archivoSOAPMemoria) ArchivarIntercambio(intercambio IntercambioSOAP) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.intercambios = append(a.intercambios, intercambio)
+	return a.err
+}
+
+func TestArchivoSOAPRegistraIntercambios(t *testing.T) {
+	simulador, cliente := iniciarSimuladorPrueba(t)
+	archivo := &archivoSOAPMemoria{}
+	cliente.ConfigurarArchivo(archivo)
+
+	clave, xmlData := comprobanteSimulado(t, 1)
+	if _, err := cliente.EnviarComprobante(xmlData); err != nil {
+		t.Fatalf("EnviarComprobante() error: %v", err)
+	}
+	if _, err := cliente.ConsultarAutorizacion(clave); err != nil {
+		t.Fatalf("ConsultarAutorizacion() error: %v", err)
+	}
+
+	if len(archivo.intercambios) != 2 {
+		t.Fatalf("Intercambios archivados = %d, esperados 2", len(archivo.intercambios))
+	}
+
+	recepcion, autorizacion := archivo.intercambios[0], archivo.intercambios[1]
+	if recepcion.Operacion != OperacionRecepcion || autorizacion.Operacion != OperacionAutorizacion {
+		t.Errorf("Operaciones inesperadas: %s, %s", recepcion.Operacion, autorizacion.Operacion)
+	}
+	if recepcion.Endpoint != simulador.EndpointRecepcion() {
+		t.Errorf("Endpoint = %s, esperado %s", recepcion.Endpoint, simulador.EndpointRecepcion())
+	}
+	for _, intercambio := range archivo.intercambios {
+		if intercambio.ClaveAcceso != clave {
+			t.Errorf("%s: clave = %q, esperada %s", intercambio.Operacion, intercambio.ClaveAcceso, clave)
+		}
+		if intercambio.CodigoHTTP != http.StatusOK || intercambio.Error != "" {
+			t.Errorf("%s: código %d, error %q", intercambio.Operacion, intercambio.CodigoHTTP, intercambio.Error)
+		}
+		if !strings.Contains(string(intercambio.Solicitud), "soap:Envelope") || len(intercambio.Respuesta) == 0 {
+			t.Errorf("%s: envelopes no archivados", intercambio.Operacion)
+		}
+		if intercambio.Fecha.IsZero() || intercambio.Duracion <= 0 {
+			t.Errorf("%s: tiempos no registrados: %+v", intercambio.Operacion, intercambio)
+		}
+	}
+	if !strings.Contains(string(autorizacion.Respuesta), EstadoSRIAutorizado) {
+		t.Error("La respuesta de autorización archivada debería contener el estado")
+	}
+}
+
+func TestArchivoSOAPRegistraErroresHTTP(t *testing.T) {
+	simulador, cliente := iniciarSimuladorPrueba(t)
+	archivo := &archivoSOAPMemoria{err: errors.New("disco lleno")}
+	cliente.ConfigurarArchivo(archivo)
+
+	clave, xmlData := comprobanteSimulado(t, 2)
+	simulador.ProgramarEscenario(clave, EscenarioSRI{
+		Recepcion: []RespuestaSimulada{{CodigoHTTP: http.StatusServiceUnavailable}},
+	})
+
+	// El fallo del archivo no debe ocultar el error real del SRI
+	_, err := cliente.EnviarComprobante(xmlData)
+	if err == nil || !strings.Contains(err.Error(), "503") {
+		t.Fatalf("Se esperaba el error HTTP 503 del SRI, obtenido: %v", err)
+	}
+
+	if len(archivo.intercambios) != 1 {
+		t.Fatalf("Intercambios archivados = %d, esperado 1", len(archivo.intercambios))
+	}
+	if archivo.intercambios[0].CodigoHTTP != http.StatusServiceUnavailable {
+		t.Errorf("CodigoHTTP = %d, esperado 503", archivo.intercambios[0].CodigoHTTP)
+	}
+}
@@ -8,12 +8,14 @@ import (
 	"os"
 	"software.sslmate.com/src/go-pkcs12"
 	"time"
+
+	"go-facturacion-sri/secrets"
 )
 
 // CertificadoDigital representa un certificado digital PKCS#12 para firma electrónica
 type CertificadoDigital struct {
 	Archivo    string              // Ruta al archivo .p12
-	Password   string              // Contraseña del certificado
+	Password   string              // Deprecated: ya no se conserva tras decodificar el .p12
 	PrivateKey interface{}         // Clave privada extraída
 	Cert       *x509.Certificate   // Certificado X.509
 	CACerts    []*x509.Certificate // Certificados de la CA
@@ -28,24 +30,44 @@ type CertificadoConfig struct {
 }
 
 // CargarCertificado carga un certificado PKCS#12 desde archivo
+// La contraseña no se conserva en el CertificadoDigital resultante
 func CargarCertificado(config CertificadoConfig) (*CertificadoDigital, error) {
+	return cargarCertificado(config, []byte(config.Password))
+}
+
+// CargarCertificadoConSecretos carga un certificado resolviendo config.Password
+// como referencia a secreto ("env:", "file:", "keystore:" o literal)
+func CargarCertificadoConSecretos(config CertificadoConfig, resolvedor *secrets.Resolvedor) (*CertificadoDigital, error) {
+	password, err := resolvedor.Resolver(config.Password)
+	if err != nil {
+		return nil, fmt.Errorf("error resolviendo contraseña del certificado: %v", err)
+	}
+
+	config.Password = ""
+	return cargarCertificado(config, password)
+}
+
+// cargarCertificado decodifica el .p12 y borra la contraseña de memoria al terminar
+func cargarCertificado(config CertificadoConfig, password []byte) (*CertificadoDigital, error) {
+	defer secrets.Borrar(password)
+
 	// Leer el archivo .p12
 	data, err := os.ReadFile(config.RutaArchivo)
 	if err != nil {
 		return nil, fmt.Errorf("error leyendo certificado: %v", err)
 	}
+	defer secrets.Borrar(data)
 
 	// Decodificar PKCS#12
 	// IMPORTANTE: En Ecuador, los certificados del Banco Central tienen 2 claves privadas
 	// Necesitamos asegurarnos de tomar la correcta (generalmente la segunda)
-	privateKey, cert, caCerts, err := pkcs12.DecodeChain(data, config.Password)
+	privateKey, cert, caCerts, err := pkcs12.DecodeChain(data, string(password))
 	if err != nil {
 		return nil, fmt.Errorf("error decodificando PKCS#12: %v", err)
 	}
 
 	certificado := &CertificadoDigital{
 		Archivo:    config.RutaArchivo,
-		Password:   config.Password,
 		PrivateKey: privateKey,
 		Cert:       cert,
 		CACerts:    caCerts,
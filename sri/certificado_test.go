@@ -1,10 +1,18 @@
 package sri
 
 import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
 	"os"
 	"path/filepath"
 	"testing"
 	"time"
+
+	"go-facturacion-sri/secrets"
+	"software.sslmate.com/src/go-pkcs12"
 )
 
 // TestCargarCertificado tests certificate loading functionality
@@ -369,4 +377,90 @@ func TestCargarCertificadoEdgeCases(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+
+// generarP12Prueba crea un .p12 autofirmado válido para tests y retorna su ruta
+func generarP12Prueba(t *testing.T, password string) string {
+	t.Helper()
+
+	clave, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Error generando clave RSA: %v", err)
+	}
+
+	plantilla := &x509.Certificate{
+		SerialNumber: big.NewInt(1001),
+		Subject:      pkix.Name{CommonName: "EMPRESA PRUEBA S.A."},
+		Issuer:       pkix.Name{CommonName: "EMPRESA PRUEBA S.A."},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, plantilla, plantilla, &clave.PublicKey, clave)
+	if err != nil {
+		t.Fatalf("Error creando certificado: %v", err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Error parseando certificado: %v", err)
+	}
+
+	p12, err := pkcs12.Modern.Encode(clave, cert, nil, password)
+	if err != nil {
+		t.Fatalf("Error codificando PKCS#12: %v", err)
+	}
+
+	ruta := filepath.Join(t.TempDir(), "prueba.p12")
+	if err := os.WriteFile(ruta, p12, 0600); err != nil {
+		t.Fatalf("Error escribiendo .p12: %v", err)
+	}
+
+	return ruta
+}
+
+// TestCargarCertificadoNoConservaPassword verifica que la contraseña no quede en memoria
+func TestCargarCertificadoNoConservaPassword(t *testing.T) {
+	ruta := generarP12Prueba(t, "clave-p12")
+
+	cert, err := CargarCertificado(CertificadoConfig{RutaArchivo: ruta, Password: "clave-p12"})
+	if err != nil {
+		t.Fatalf("CargarCertificado() error: %v", err)
+	}
+
+	if cert.Password != "" {
+		t.Error("CertificadoDigital.Password no debería conservar la contraseña")
+	}
+	if cert.PrivateKey == nil || cert.Cert == nil {
+		t.Error("CargarCertificado() no extrajo clave y certificado")
+	}
+}
+
+// TestCargarCertificadoConSecretos verifica la resolución de la contraseña vía proveedor
+func TestCargarCertificadoConSecretos(t *testing.T) {
+	ruta := generarP12Prueba(t, "clave-desde-env")
+	t.Setenv("TEST_CERT_PASSWORD", "clave-desde-env")
+
+	resolvedor := secrets.NuevoResolvedor()
+
+	cert, err := CargarCertificadoConSecretos(CertificadoConfig{
+		RutaArchivo:     ruta,
+		Password:        "env:TEST_CERT_PASSWORD",
+		ValidarVigencia: true,
+	}, resolvedor)
+	if err != nil {
+		t.Fatalf("CargarCertificadoConSecretos() error: %v", err)
+	}
+	if cert.ObtenerSubject() != "EMPRESA PRUEBA S.A." {
+		t.Errorf("Subject = %s", cert.ObtenerSubject())
+	}
+
+	_, err = CargarCertificadoConSecretos(CertificadoConfig{
+		RutaArchivo: ruta,
+		Password:    "env:TEST_CERT_PASSWORD_INEXISTENTE",
+	}, resolvedor)
+	if err == nil {
+		t.Error("CargarCertificadoConSecretos() debería fallar si el secreto no existe")
+	}
+}
@@ -2,6 +2,7 @@
 package sri
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"sync"
@@ -63,6 +64,7 @@ type CircuitBreaker struct {
 	ultimoError         time.Time
 	ultimoCambioEstado  time.Time
 	peticionesTest      int
+	pruebasEnCurso      int // Peticiones de prueba ejecutándose en estado medio cerrado
 	mutex               sync.RWMutex
 	estadisticas        EstadisticasCircuitBreaker
 }
@@ -95,23 +97,50 @@ func NuevoCircuitBreakerDefault() *CircuitBreaker {
 
 // Ejecutar ejecuta una función con protección de circuit breaker
 func (cb *CircuitBreaker) Ejecutar(fn func() error) error {
-	cb.mutex.Lock()
-	defer cb.mutex.Unlock()
+	return cb.EjecutarContexto(context.Background(), func(ctx context.Context) error {
+		return fn()
+	})
+}
 
+// EjecutarContexto ejecuta una función cancelable con protección de circuit breaker.
+// El lock no se mantiene durante fn, así las llamadas concurrentes no se serializan; en estado
+// medio cerrado cada llamada reserva un cupo de prueba antes de soltarlo.
+// Las cancelaciones del contexto no cuentan como fallos del SRI.
+func (cb *CircuitBreaker) EjecutarContexto(ctx context.Context, fn func(ctx context.Context) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	cb.mutex.Lock()
 	cb.estadisticas.TotalPeticiones++
 
 	// Verificar si podemos ejecutar la petición
 	if !cb.puedeEjecutar() {
 		cb.estadisticas.PeticionesBloqueadas++
-		return fmt.Errorf("circuit breaker ABIERTO: SRI no disponible, reintente en %v", 
-			cb.tiempoRestanteAbierto())
+		restante := cb.tiempoRestanteAbierto()
+		cb.mutex.Unlock()
+		return fmt.Errorf("circuit breaker ABIERTO: SRI no disponible, reintente en %v", restante)
 	}
+	prueba := cb.estado == EstadoMedioCerrado
+	if prueba {
+		cb.pruebasEnCurso++
+	}
+	cb.mutex.Unlock()
 
 	// Ejecutar la función
-	err := fn()
+	err := fn(ctx)
+
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+	if prueba {
+		cb.pruebasEnCurso--
+	}
 
 	// Actualizar estado según resultado
 	if err != nil {
+		if ctx.Err() != nil {
+			return err
+		}
 		cb.registrarError(err)
 		cb.estadisticas.PeticionesFallidas++
 		return err
@@ -137,7 +166,8 @@ func (cb *CircuitBreaker) puedeEjecutar() bool {
 		}
 		return false
 	case EstadoMedioCerrado:
-		return cb.peticionesTest < cb.config.MaxPeticionesTest
+		// Las pruebas en curso ocupan cupo hasta conocer su resultado
+		return cb.peticionesTest+cb.pruebasEnCurso < cb.config.MaxPeticionesTest
 	default:
 		return false
 	}
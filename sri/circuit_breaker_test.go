@@ -0,0 +1,121 @@
+package sri
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// abrirCircuito lleva el circuit breaker a ABIERTO con errores consecutivos
+func abrirCircuito(t *testing.T, cb *CircuitBreaker) {
+	t.Helper()
+	for i := 0; i < cb.config.MaxErrores; i++ {
+		cb.Ejecutar(func() error { return errors.New("SRI caído") })
+	}
+	if estado := cb.ObtenerEstado(); estado != EstadoAbierto {
+		t.Fatalf("Estado = %s, esperado ABIERTO", estado)
+	}
+}
+
+func TestCircuitBreakerAbreYCierra(t *testing.T) {
+	cb := NuevoCircuitBreaker(ConfigCircuitBreaker{
+		MaxErrores:        2,
+		TiempoAbierto:     20 * time.Millisecond,
+		TiempoEvaluacion:  time.Minute,
+		MaxPeticionesTest: 2,
+	})
+	abrirCircuito(t, cb)
+
+	if err := cb.Ejecutar(func() error { return nil }); err == nil {
+		t.Error("Ejecutar() debería bloquear con el circuito abierto")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	for i := 0; i < 2; i++ {
+		if err := cb.Ejecutar(func() error { return nil }); err != nil {
+			t.Fatalf("Prueba %d rechazada: %v", i+1, err)
+		}
+	}
+	if estado := cb.ObtenerEstado(); estado != EstadoCerrado {
+		t.Errorf("Estado = %s, esperado CERRADO tras las pruebas exitosas", estado)
+	}
+}
+
+func TestCircuitBreakerMedioCerradoLimitaPruebasConcurrentes(t *testing.T) {
+	cb := NuevoCircuitBreaker(ConfigCircuitBreaker{
+		MaxErrores:        1,
+		TiempoAbierto:     10 * time.Millisecond,
+		TiempoEvaluacion:  time.Minute,
+		MaxPeticionesTest: 2,
+	})
+	abrirCircuito(t, cb)
+	time.Sleep(20 * time.Millisecond)
+
+	var ejecutadas, rechazadas int32
+	liberar := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := cb.EjecutarContexto(context.Background(), func(ctx context.Context) error {
+				atomic.AddInt32(&ejecutadas, 1)
+				<-liberar
+				return nil
+			})
+			if err != nil {
+				atomic.AddInt32(&rechazadas, 1)
+			}
+		}()
+	}
+
+	// Esperar a que cada goroutine esté bloqueada en fn o haya sido rechazada
+	limite := time.Now().Add(2 * time.Second)
+	for atomic.LoadInt32(&ejecutadas)+atomic.LoadInt32(&rechazadas) < 10 && time.Now().Before(limite) {
+		time.Sleep(time.Millisecond)
+	}
+	if n := atomic.LoadInt32(&ejecutadas); n != 2 {
+		t.Errorf("Pruebas concurrentes en MEDIO_CERRADO = %d, esperadas 2", n)
+	}
+
+	close(liberar)
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&rechazadas); n != 8 {
+		t.Errorf("Peticiones rechazadas = %d, esperadas 8", n)
+	}
+	if estado := cb.ObtenerEstado(); estado != EstadoCerrado {
+		t.Errorf("Estado = %s, esperado CERRADO tras las pruebas exitosas", estado)
+	}
+}
+
+func TestCircuitBreakerPruebaCanceladaLiberaCupo(t *testing.T) {
+	cb := NuevoCircuitBreaker(ConfigCircuitBreaker{
+		MaxErrores:        1,
+		TiempoAbierto:     10 * time.Millisecond,
+		TiempoEvaluacion:  time.Minute,
+		MaxPeticionesTest: 1,
+	})
+	abrirCircuito(t, cb)
+	time.Sleep(20 * time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	err := cb.EjecutarContexto(ctx, func(ctx context.Context) error {
+		cancel()
+		return ctx.Err()
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("EjecutarContexto() = %v, esperado context.Canceled", err)
+	}
+
+	// La cancelación no cuenta como fallo ni deja el cupo de prueba ocupado
+	if err := cb.Ejecutar(func() error { return nil }); err != nil {
+		t.Fatalf("Prueba tras cancelación rechazada: %v", err)
+	}
+	if estado := cb.ObtenerEstado(); estado != EstadoCerrado {
+		t.Errorf("Estado = %s, esperado CERRADO", estado)
+	}
+}
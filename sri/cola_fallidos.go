@@ -0,0 +1,106 @@
+// Package sri implementa el registro de envíos que agotaron sus reintentos
+package sri
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// IntentoFallido error de un intento con su clasificación SRI
+type IntentoFallido struct {
+	Numero   int       `json:"numero"`
+	Error    string    `json:"error"`
+	ErrorSRI *ErrorSRI `json:"errorSRI,omitempty"`
+	Fecha    time.Time `json:"fecha"`
+}
+
+// NuevoIntentoFallido clasifica el error de un intento
+func NuevoIntentoFallido(numero int, err error) IntentoFallido {
+	return IntentoFallido{
+		Numero:   numero,
+		Error:    err.Error(),
+		ErrorSRI: ClasificarError(err),
+		Fecha:    time.Now(),
+	}
+}
+
+// ClasificarError retorna el ErrorSRI contenido en err o, si no lo hay, el que corresponde a su mensaje
+func ClasificarError(err error) *ErrorSRI {
+	if err == nil {
+		return nil
+	}
+	var errorSRI *ErrorSRI
+	if errors.As(err, &errorSRI) {
+		return errorSRI
+	}
+	return ParsearErrorSRI(err.Error(), 0)
+}
+
+// ErrorReintentosAgotados se retorna cuando una operación con el SRI agotó sus reintentos
+type ErrorReintentosAgotados struct {
+	Operacion   string // RECEPCION o AUTORIZACION
+	ClaveAcceso string
+	Resultado   *ResultadoReintento
+}
+
+// Error implementa la interfaz error
+func (e *ErrorReintentosAgotados) Error() string {
+	accion := "enviando comprobante"
+	if e.Operacion == OperacionAutorizacion {
+		accion = "consultando autorización"
+	}
+	return fmt.Sprintf("error %s después de %d intentos: %v", accion, e.Resultado.IntentosRealizados, e.Resultado.UltimoError)
+}
+
+// Unwrap expone el último error para errors.Is/errors.As
+func (e *ErrorReintentosAgotados) Unwrap() error {
+	return e.Resultado.UltimoError
+}
+
+// Recuperable indica si el último error permite volver a intentar más tarde
+func (e *ErrorReintentosAgotados) Recuperable() bool {
+	if clasificado := ClasificarError(e.Resultado.UltimoError); clasificado != nil {
+		return clasificado.IsRecuperable()
+	}
+	return false
+}
+
+// FallidoSRI comprobante que agotó sus reintentos, listo para la cola de fallidos
+type FallidoSRI struct {
+	Operacion      string
+	ClaveAcceso    string
+	XMLComprobante []byte
+	Intentos       []IntentoFallido
+	Recuperable    bool
+}
+
+// ColaFallidos almacena los comprobantes que agotaron sus reintentos (dead-letter queue)
+type ColaFallidos interface {
+	RegistrarFallido(fallido FallidoSRI) error
+}
+
+// ConfigurarColaFallidos define dónde se registran los envíos que agotan sus reintentos (nil lo deshabilita)
+func (c *SOAPClient) ConfigurarColaFallidos(cola ColaFallidos) {
+	c.colaFallidos = cola
+}
+
+// registrarFallido envía a la cola de fallidos el error de reintentos agotados y lo retorna
+func (c *SOAPClient) registrarFallido(agotado *ErrorReintentosAgotados, xmlComprobante []byte) error {
+	if c.colaFallidos == nil {
+		return agotado
+	}
+
+	fallido := FallidoSRI{
+		Operacion:      agotado.Operacion,
+		ClaveAcceso:    agotado.ClaveAcceso,
+		XMLComprobante: xmlComprobante,
+		Intentos:       agotado.Resultado.Intentos,
+		Recuperable:    agotado.Recuperable(),
+	}
+	if err := c.colaFallidos.RegistrarFallido(fallido); err != nil {
+		log.Printf("[COLA_FALLIDOS] No se pudo registrar %s: %v", agotado.ClaveAcceso, err)
+	}
+	return agotado
+}
@@ -0,0 +1,86 @@
+package sri
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+// colaFallidosMemoria guarda en memoria los fallidos registrados
+type colaFallidosMemoria struct {
+	fallidos []FallidoSRI
+}
+
+func (c *colaFallidosMemoria) RegistrarFallido(fallido FallidoSRI) error {
+	c.fallidos = append(c.fallidos, fallido)
+	return nil
+}
+
+func TestReintentoRegistraErrorDeCadaIntento(t *testing.T) {
+	config := ConfigReintento{MaxIntentos: 3, TiempoBase: time.Millisecond, Multiplicador: 1, TiempoMaximo: time.Millisecond}
+
+	resultado := EjecutarConReintentoContexto(context.Background(), func(ctx context.Context) error {
+		return errors.New("timeout de conexión")
+	}, config)
+
+	if resultado.Exitoso || len(resultado.Intentos) != 3 {
+		t.Fatalf("Intentos registrados = %d, esperados 3", len(resultado.Intentos))
+	}
+	for i, intento := range resultado.Intentos {
+		if intento.Numero != i+1 || intento.ErrorSRI == nil {
+			t.Errorf("Intento %d sin clasificar: %+v", i, intento)
+		}
+	}
+
+	// Los intentos se persisten como JSON y deben poder leerse de vuelta
+	datos, err := json.Marshal(resultado.Intentos)
+	if err != nil {
+		t.Fatalf("json.Marshal() error: %v", err)
+	}
+	var leidos []IntentoFallido
+	if err := json.Unmarshal(datos, &leidos); err != nil {
+		t.Fatalf("json.Unmarshal() error: %v", err)
+	}
+	if leidos[0].ErrorSRI.Tipo != resultado.Intentos[0].ErrorSRI.Tipo {
+		t.Errorf("Tipo = %s, esperado %s", leidos[0].ErrorSRI.Tipo, resultado.Intentos[0].ErrorSRI.Tipo)
+	}
+}
+
+func TestRegistrarFallidoEnCola(t *testing.T) {
+	cliente := &SOAPClient{}
+	cola := &colaFallidosMemoria{}
+
+	ultimo := errors.New("servicio no disponible")
+	agotado := &ErrorReintentosAgotados{
+		Operacion:   OperacionRecepcion,
+		ClaveAcceso: "CLAVE-AGOTADA",
+		Resultado: &ResultadoReintento{
+			IntentosRealizados: 2,
+			UltimoError:        ultimo,
+			Intentos:           []IntentoFallido{NuevoIntentoFallido(1, ultimo), NuevoIntentoFallido(2, ultimo)},
+		},
+	}
+
+	// Sin cola configurada solo se retorna el error
+	if err := cliente.registrarFallido(agotado, []byte("<factura/>")); !errors.Is(err, ultimo) {
+		t.Errorf("registrarFallido() = %v, se esperaba envolver el último error", err)
+	}
+
+	cliente.ConfigurarColaFallidos(cola)
+	err := cliente.registrarFallido(agotado, []byte("<factura/>"))
+	if err.Error() != "error enviando comprobante después de 2 intentos: servicio no disponible" {
+		t.Errorf("Mensaje inesperado: %v", err)
+	}
+	if len(cola.fallidos) != 1 {
+		t.Fatalf("Fallidos registrados = %d, esperado 1", len(cola.fallidos))
+	}
+	fallido := cola.fallidos[0]
+	if fallido.ClaveAcceso != "CLAVE-AGOTADA" || len(fallido.Intentos) != 2 || string(fallido.XMLComprobante) != "<factura/>" {
+		t.Errorf("Fallido inesperado: %+v", fallido)
+	}
+	if fallido.Recuperable != agotado.Recuperable() {
+		t.Errorf("Recuperable = %t, esperado %t", fallido.Recuperable, agotado.Recuperable())
+	}
+}
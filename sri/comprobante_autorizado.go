@@ -0,0 +1,87 @@
+// Package sri implementa el XML de comprobante autorizado que se entrega al comprador
+package sri
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// xmlAutorizacion estructura del archivo de comprobante autorizado, igual a la que
+// publica el SRI en su portal: datos de autorización y el comprobante firmado en CDATA
+type xmlAutorizacion struct {
+	XMLName            xml.Name     `xml:"autorizacion"`
+	Estado             string       `xml:"estado"`
+	NumeroAutorizacion string       `xml:"numeroAutorizacion"`
+	FechaAutorizacion  string       `xml:"fechaAutorizacion"`
+	Ambiente           string       `xml:"ambiente"`
+	Comprobante        cdata        `xml:"comprobante"`
+	Mensajes           []MensajeSRI `xml:"mensajes>mensaje,omitempty"`
+}
+
+// cdata texto que se serializa dentro de una sección CDATA
+type cdata struct {
+	Texto string `xml:",cdata"`
+}
+
+// GenerarXMLAutorizado construye el archivo <autorizacion> a partir de la respuesta del SRI.
+// Solo los comprobantes AUTORIZADO tienen validez tributaria.
+func GenerarXMLAutorizado(autorizacion AutorizacionSRI) ([]byte, error) {
+	if autorizacion.Estado != EstadoSRIAutorizado {
+		return nil, fmt.Errorf("el comprobante no está autorizado: estado %s", autorizacion.Estado)
+	}
+
+	comprobante := strings.TrimSpace(autorizacion.Comprobante)
+	if comprobante == "" {
+		return nil, fmt.Errorf("la autorización %s no incluye el comprobante", autorizacion.NumeroAutorizacion)
+	}
+
+	documento := xmlAutorizacion{
+		Estado:             autorizacion.Estado,
+		NumeroAutorizacion: autorizacion.NumeroAutorizacion,
+		FechaAutorizacion:  autorizacion.FechaAutorizacion,
+		Ambiente:           autorizacion.Ambiente,
+		Comprobante:        cdata{Texto: comprobante},
+		Mensajes:           autorizacion.Mensajes,
+	}
+
+	contenido, err := xml.MarshalIndent(documento, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("error generando XML autorizado: %v", err)
+	}
+
+	return append([]byte(xml.Header), contenido...), nil
+}
+
+// ParsearXMLAutorizado lee un archivo <autorizacion> y retorna sus datos con el comprobante extraído
+func ParsearXMLAutorizado(xmlAutorizado []byte) (*AutorizacionSRI, error) {
+	var documento xmlAutorizacion
+	if err := xml.Unmarshal(xmlAutorizado, &documento); err != nil {
+		return nil, fmt.Errorf("error parseando XML autorizado: %v", err)
+	}
+
+	return &AutorizacionSRI{
+		Estado:             documento.Estado,
+		NumeroAutorizacion: documento.NumeroAutorizacion,
+		FechaAutorizacion:  documento.FechaAutorizacion,
+		Ambiente:           documento.Ambiente,
+		Comprobante:        strings.TrimSpace(documento.Comprobante.Texto),
+		Mensajes:           documento.Mensajes,
+	}, nil
+}
+
+// formatosFechaAutorizacion formatos de fechaAutorizacion que devuelve el SRI: ISO 8601 con zona
+// (con o sin milisegundos) en los servicios en línea y dd/mm/aaaa en los comprobantes offline
+var formatosFechaAutorizacion = []string{time.RFC3339Nano, "02/01/2006 15:04:05"}
+
+// ParsearFechaAutorizacion convierte la fechaAutorizacion de una respuesta del SRI
+func ParsearFechaAutorizacion(valor string) (time.Time, error) {
+	valor = strings.TrimSpace(valor)
+	for _, formato := range formatosFechaAutorizacion {
+		if fecha, err := time.ParseInLocation(formato, valor, time.Local); err == nil {
+			return fecha, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("fecha de autorización inválida: %q", valor)
+}
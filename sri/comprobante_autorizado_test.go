@@ -0,0 +1,80 @@
+package sri
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGenerarXMLAutorizado(t *testing.T) {
+	comprobante := `<?xml version="1.0" encoding="UTF-8"?><factura id="comprobante"><detalle>A &amp; B ]]> C</detalle></factura>`
+	autorizacion := AutorizacionSRI{
+		Estado:             EstadoSRIAutorizado,
+		NumeroAutorizacion: "2306202501179214673900110010010000000019152728411",
+		FechaAutorizacion:  "2025-06-23T10:15:00-05:00",
+		Ambiente:           "PRUEBAS",
+		Comprobante:        comprobante,
+		Mensajes:           []MensajeSRI{{Identificador: "60", Mensaje: "ESTE PROCESO FUE REALIZADO EN EL AMBIENTE DE PRUEBAS", Tipo: "INFORMATIVO"}},
+	}
+
+	xmlAutorizado, err := GenerarXMLAutorizado(autorizacion)
+	if err != nil {
+		t.Fatalf("GenerarXMLAutorizado() error: %v", err)
+	}
+
+	contenido := string(xmlAutorizado)
+	for _, esperado := range []string{"<autorizacion>", "<estado>AUTORIZADO</estado>", "<ambiente>PRUEBAS</ambiente>", "<![CDATA[<?xml"} {
+		if !strings.Contains(contenido, esperado) {
+			t.Errorf("El XML autorizado no contiene %q:\n%s", esperado, contenido)
+		}
+	}
+
+	leida, err := ParsearXMLAutorizado(xmlAutorizado)
+	if err != nil {
+		t.Fatalf("ParsearXMLAutorizado() error: %v", err)
+	}
+	if leida.Comprobante != comprobante {
+		t.Errorf("El comprobante no se conservó intacto:\n%s", leida.Comprobante)
+	}
+	if leida.NumeroAutorizacion != autorizacion.NumeroAutorizacion || leida.FechaAutorizacion != autorizacion.FechaAutorizacion {
+		t.Errorf("Datos de autorización inesperados: %+v", leida)
+	}
+	if len(leida.Mensajes) != 1 || leida.Mensajes[0].Identificador != "60" {
+		t.Errorf("Mensajes inesperados: %+v", leida.Mensajes)
+	}
+}
+
+func TestGenerarXMLAutorizadoRequiereAutorizacion(t *testing.T) {
+	if _, err := GenerarXMLAutorizado(AutorizacionSRI{Estado: EstadoSRINoAutorizado, Comprobante: "<factura/>"}); err == nil {
+		t.Error("Un comprobante NO AUTORIZADO no debería generar XML autorizado")
+	}
+	if _, err := GenerarXMLAutorizado(AutorizacionSRI{Estado: EstadoSRIAutorizado}); err == nil {
+		t.Error("Una autorización sin comprobante debería fallar")
+	}
+}
+
+func TestParsearFechaAutorizacion(t *testing.T) {
+	tests := []struct {
+		valor    string
+		esperada time.Time
+	}{
+		{"2025-06-23T14:30:00.000-05:00", time.Date(2025, 6, 23, 19, 30, 0, 0, time.UTC)},
+		{"2025-06-23T14:30:00-05:00", time.Date(2025, 6, 23, 19, 30, 0, 0, time.UTC)},
+		{" 23/06/2025 14:30:00 ", time.Date(2025, 6, 23, 14, 30, 0, 0, time.Local)},
+	}
+
+	for _, tt := range tests {
+		fecha, err := ParsearFechaAutorizacion(tt.valor)
+		if err != nil {
+			t.Errorf("ParsearFechaAutorizacion(%q) error: %v", tt.valor, err)
+			continue
+		}
+		if !fecha.Equal(tt.esperada) {
+			t.Errorf("ParsearFechaAutorizacion(%q) = %v, esperada %v", tt.valor, fecha, tt.esperada)
+		}
+	}
+
+	if _, err := ParsearFechaAutorizacion(""); err == nil {
+		t.Error("Una fecha vacía debería fallar")
+	}
+}
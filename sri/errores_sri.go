@@ -2,7 +2,11 @@
 package sri
 
 import (
+	"errors"
 	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -20,6 +24,8 @@ const (
 	ErrorCertificado
 	ErrorFirma
 	ErrorClaveAcceso
+	ErrorEmisor
+	ErrorInformativo
 )
 
 // String implementa Stringer para TipoErrorSRI
@@ -45,19 +51,55 @@ func (te TipoErrorSRI) String() string {
 		return "ERROR_FIRMA"
 	case ErrorClaveAcceso:
 		return "ERROR_CLAVE_ACCESO"
+	case ErrorEmisor:
+		return "ERROR_EMISOR"
+	case ErrorInformativo:
+		return "INFORMATIVO"
 	default:
 		return "ERROR_DESCONOCIDO"
 	}
 }
 
+// MarshalText serializa el tipo con su nombre para que la UI no dependa del valor numérico
+func (te TipoErrorSRI) MarshalText() ([]byte, error) {
+	return []byte(te.String()), nil
+}
+
+// UnmarshalText recupera el tipo desde su nombre, por ejemplo al leer intentos persistidos
+func (te *TipoErrorSRI) UnmarshalText(texto []byte) error {
+	for tipo := ErrorConexion; tipo <= ErrorInformativo; tipo++ {
+		if tipo.String() == string(texto) {
+			*te = tipo
+			return nil
+		}
+	}
+	*te = 0
+	return nil
+}
+
+// AccionErrorSRI acción que la aplicación o el usuario deben tomar ante un error
+type AccionErrorSRI string
+
+const (
+	AccionReintentarEnvio       AccionErrorSRI = "REINTENTAR_ENVIO"       // Reenviar el mismo comprobante más tarde
+	AccionConsultarAutorizacion AccionErrorSRI = "CONSULTAR_AUTORIZACION" // El SRI ya tiene el comprobante
+	AccionCorregirComprobante   AccionErrorSRI = "CORREGIR_COMPROBANTE"   // Corregir datos y volver a firmar
+	AccionNuevoSecuencial       AccionErrorSRI = "NUEVO_SECUENCIAL"       // Emitir con otro secuencial
+	AccionRevisarCertificado    AccionErrorSRI = "REVISAR_CERTIFICADO"    // Revisar firma o certificado digital
+	AccionRegularizarEmisor     AccionErrorSRI = "REGULARIZAR_EMISOR"     // Situación tributaria del emisor
+	AccionNinguna               AccionErrorSRI = "NINGUNA"                // Mensaje informativo
+)
+
 // ErrorSRI estructura para errores específicos del SRI
 type ErrorSRI struct {
-	Tipo         TipoErrorSRI `json:"tipo"`
-	Codigo       string       `json:"codigo"`
-	Mensaje      string       `json:"mensaje"`
-	Detalle      string       `json:"detalle"`
-	Recuperable  bool         `json:"recuperable"`
-	SugerenciaFix string      `json:"sugerencia_fix"`
+	Tipo          TipoErrorSRI   `json:"tipo"`
+	Codigo        string         `json:"codigo"`
+	Mensaje       string         `json:"mensaje"`
+	Detalle       string         `json:"detalle"`
+	Recuperable   bool           `json:"recuperable"`
+	SugerenciaFix string         `json:"sugerencia_fix"`
+	Accion        AccionErrorSRI `json:"accion,omitempty"`
+	Severidad     string         `json:"severidad,omitempty"` // ERROR, ADVERTENCIA o INFORMATIVO según el SRI
 }
 
 // Error implementa la interfaz error
@@ -93,167 +135,341 @@ func (e *ErrorSRI) GetSugerencia() string {
 	return e.SugerenciaFix
 }
 
-// Códigos de error específicos del SRI Ecuador
+// codigosErrorSRI catálogo de identificadores que retornan los servicios
+// RecepcionComprobantesOffline y AutorizacionComprobantesOffline, según la
+// tabla de errores de la ficha técnica de comprobantes electrónicos del SRI
 var codigosErrorSRI = map[string]*ErrorSRI{
-	// Errores de conexión
-	"CONEXION_TIMEOUT": {
-		Tipo:          ErrorTimeout,
-		Codigo:        "CONEXION_TIMEOUT",
-		Mensaje:       "Timeout conectando con SRI",
-		Detalle:       "El servidor del SRI no respondió en el tiempo esperado",
-		Recuperable:   true,
-		SugerenciaFix: "Reintentar en unos minutos. Verificar conexión a internet.",
+	// Situación del emisor
+	"2": {
+		Tipo:          ErrorEmisor,
+		Mensaje:       "RUC DEL EMISOR SE ENCUENTRA NO ACTIVO",
+		Detalle:       "El RUC del emisor está suspendido o cancelado",
+		Accion:        AccionRegularizarEmisor,
+		SugerenciaFix: "Reactivar el RUC en el SRI antes de emitir comprobantes.",
 	},
-	"SERVIDOR_NO_DISPONIBLE": {
-		Tipo:          ErrorConexion,
-		Codigo:        "SERVIDOR_NO_DISPONIBLE",
-		Mensaje:       "Servidor SRI no disponible",
-		Detalle:       "El servicio web del SRI está temporalmente inaccesible",
-		Recuperable:   true,
-		SugerenciaFix: "Reintentar más tarde. Verificar estado de servicios SRI.",
+	"10": {
+		Tipo:          ErrorEmisor,
+		Mensaje:       "ESTABLECIMIENTO DEL EMISOR SE ENCUENTRA CLAUSURADO",
+		Detalle:       "El establecimiento del comprobante está clausurado",
+		Accion:        AccionRegularizarEmisor,
+		SugerenciaFix: "Emitir desde un establecimiento abierto o levantar la clausura.",
 	},
-
-	// Errores de validación XML
-	"CLAVE-01": {
-		Tipo:          ErrorClaveAcceso,
-		Codigo:        "CLAVE-01",
-		Mensaje:       "Clave de acceso registrada",
-		Detalle:       "La clave de acceso ya está registrada en el SRI",
-		Recuperable:   false,
-		SugerenciaFix: "Generar nueva clave de acceso con secuencial diferente.",
+	"27": {
+		Tipo:          ErrorEmisor,
+		Mensaje:       "CLASE NO PERMITIDO",
+		Detalle:       "La clase de contribuyente no puede emitir comprobantes electrónicos",
+		Accion:        AccionRegularizarEmisor,
+		SugerenciaFix: "Verificar la clase de contribuyente registrada en el SRI.",
 	},
-	"CLAVE-02": {
-		Tipo:          ErrorClaveAcceso,
-		Codigo:        "CLAVE-02",
-		Mensaje:       "Clave de acceso mal formada",
-		Detalle:       "La clave de acceso no cumple con el formato de 49 dígitos",
-		Recuperable:   false,
-		SugerenciaFix: "Verificar algoritmo de generación de clave de acceso.",
+	"28": {
+		Tipo:          ErrorEmisor,
+		Mensaje:       "ACUERDO DE MEDIOS ELECTRÓNICOS NO ACEPTADO",
+		Detalle:       "El emisor no ha aceptado el acuerdo de medios electrónicos",
+		Accion:        AccionRegularizarEmisor,
+		SugerenciaFix: "Aceptar el acuerdo de medios electrónicos en SRI en línea.",
 	},
-	"CLAVE-03": {
-		Tipo:          ErrorClaveAcceso,
-		Codigo:        "CLAVE-03",
-		Mensaje:       "Fecha de emisión incorrecta",
-		Detalle:       "La fecha en la clave de acceso no coincide con el XML",
-		Recuperable:   false,
-		SugerenciaFix: "Sincronizar fechas entre clave de acceso y XML.",
+	"37": {
+		Tipo:          ErrorEmisor,
+		Mensaje:       "RUC SIN AUTORIZACIÓN DE EMISIÓN",
+		Detalle:       "El RUC no está autorizado para emitir comprobantes electrónicos",
+		Accion:        AccionRegularizarEmisor,
+		SugerenciaFix: "Solicitar la autorización de emisión electrónica en el SRI.",
+	},
+	"56": {
+		Tipo:          ErrorEmisor,
+		Mensaje:       "ESTABLECIMIENTO CERRADO",
+		Detalle:       "El establecimiento del comprobante no está abierto en el RUC",
+		Accion:        AccionRegularizarEmisor,
+		SugerenciaFix: "Verificar el código de establecimiento o abrirlo en el RUC.",
+	},
+	"57": {
+		Tipo:          ErrorEmisor,
+		Mensaje:       "AUTORIZACIÓN SUSPENDIDA",
+		Detalle:       "La autorización de emisión electrónica está suspendida",
+		Accion:        AccionRegularizarEmisor,
+		SugerenciaFix: "Contactar al SRI para levantar la suspensión.",
+	},
+	"63": {
+		Tipo:          ErrorEmisor,
+		Mensaje:       "RUC CLAUSURADO",
+		Detalle:       "El RUC del emisor se encuentra clausurado",
+		Accion:        AccionRegularizarEmisor,
+		SugerenciaFix: "Regularizar la situación del RUC en el SRI.",
 	},
 
-	// Errores de estructura XML
-	"ESTRUCTURA-01": {
+	// Estructura del comprobante
+	"26": {
 		Tipo:          ErrorFormato,
-		Codigo:        "ESTRUCTURA-01",
-		Mensaje:       "Estructura XML incorrecta",
-		Detalle:       "El XML no cumple con el esquema XSD del SRI",
-		Recuperable:   false,
-		SugerenciaFix: "Validar XML contra esquema oficial del SRI.",
+		Mensaje:       "TAMAÑO MÁXIMO SUPERADO",
+		Detalle:       "El archivo supera el tamaño máximo permitido",
+		Accion:        AccionCorregirComprobante,
+		SugerenciaFix: "Reducir el tamaño del comprobante o dividir el lote.",
 	},
-	"ESTRUCTURA-02": {
+	"35": {
 		Tipo:          ErrorFormato,
-		Codigo:        "ESTRUCTURA-02",
-		Mensaje:       "Codificación incorrecta",
-		Detalle:       "El XML debe estar codificado en UTF-8",
-		Recuperable:   false,
-		SugerenciaFix: "Verificar codificación UTF-8 del XML.",
+		Mensaje:       "ARCHIVO NO CUMPLE ESTRUCTURA XML",
+		Detalle:       "El comprobante no cumple el esquema XSD o no es XML válido",
+		Accion:        AccionCorregirComprobante,
+		SugerenciaFix: "Validar el XML contra el esquema XSD oficial de la versión declarada.",
 	},
-
-	// Errores de datos
-	"RUC-01": {
-		Tipo:          ErrorDatos,
-		Codigo:        "RUC-01",
-		Mensaje:       "RUC no válido",
-		Detalle:       "El RUC del emisor no está registrado en el SRI",
-		Recuperable:   false,
-		SugerenciaFix: "Verificar RUC en portal SRI. Debe estar activo.",
+	"36": {
+		Tipo:          ErrorFormato,
+		Mensaje:       "VERSIÓN ESQUEMA DESCONTINUADA",
+		Detalle:       "La versión del comprobante ya no es aceptada",
+		Accion:        AccionCorregirComprobante,
+		SugerenciaFix: "Generar el comprobante con una versión de esquema vigente.",
 	},
-	"CEDULA-01": {
-		Tipo:          ErrorDatos,
-		Codigo:        "CEDULA-01",
-		Mensaje:       "Cédula no válida",
-		Detalle:       "La cédula del comprador no cumple algoritmo de validación",
-		Recuperable:   false,
-		SugerenciaFix: "Verificar dígito verificador de la cédula ecuatoriana.",
+	"48": {
+		Tipo:          ErrorFormato,
+		Mensaje:       "ESQUEMA XSD NO EXISTE",
+		Detalle:       "No existe un esquema para la versión y tipo de comprobante",
+		Accion:        AccionCorregirComprobante,
+		SugerenciaFix: "Verificar los atributos id y version del elemento raíz.",
+	},
+	"49": {
+		Tipo:          ErrorValidacion,
+		Mensaje:       "ARGUMENTOS QUE ENVIAN AL WS NULOS",
+		Detalle:       "La petición SOAP no incluye el comprobante",
+		Accion:        AccionCorregirComprobante,
+		SugerenciaFix: "Verificar que la petición SOAP incluya el comprobante en base64.",
 	},
 
-	// Errores de certificado
-	"CERT-01": {
-		Tipo:          ErrorCertificado,
-		Codigo:        "CERT-01",
-		Mensaje:       "Certificado expirado",
-		Detalle:       "El certificado digital usado para firmar ha expirado",
-		Recuperable:   false,
-		SugerenciaFix: "Renovar certificado digital con entidad certificadora autorizada.",
+	// Firma y certificado
+	"39": {
+		Tipo:          ErrorFirma,
+		Mensaje:       "FIRMA INVALIDA",
+		Detalle:       "La firma XAdES-BES no es válida o el XML fue modificado tras firmar",
+		Accion:        AccionRevisarCertificado,
+		SugerenciaFix: "Verificar la firma XAdES-BES y no modificar el XML después de firmarlo.",
 	},
-	"CERT-02": {
+	"40": {
 		Tipo:          ErrorCertificado,
-		Codigo:        "CERT-02",
-		Mensaje:       "Certificado revocado",
-		Detalle:       "El certificado digital ha sido revocado",
-		Recuperable:   false,
-		SugerenciaFix: "Obtener nuevo certificado digital.",
+		Mensaje:       "ERROR EN EL CERTIFICADO",
+		Detalle:       "El certificado de firma no es válido o está caducado",
+		Accion:        AccionRevisarCertificado,
+		SugerenciaFix: "Renovar el certificado digital con una entidad certificadora autorizada.",
 	},
-	"CERT-03": {
+	"42": {
 		Tipo:          ErrorCertificado,
-		Codigo:        "CERT-03",
-		Mensaje:       "Cadena de certificación inválida",
-		Detalle:       "La cadena de certificación no es válida",
-		Recuperable:   false,
-		SugerenciaFix: "Verificar cadena completa de certificación.",
+		Mensaje:       "CERTIFICADO REVOCADO",
+		Detalle:       "El certificado de firma fue revocado por la entidad emisora",
+		Accion:        AccionRevisarCertificado,
+		SugerenciaFix: "Obtener un nuevo certificado digital.",
 	},
 
-	// Errores de firma
-	"FIRMA-01": {
-		Tipo:          ErrorFirma,
-		Codigo:        "FIRMA-01",
-		Mensaje:       "Firma digital inválida",
-		Detalle:       "La firma XAdES-BES no es válida",
-		Recuperable:   false,
-		SugerenciaFix: "Verificar proceso de firma digital XAdES-BES.",
+	// Clave de acceso y secuencial
+	"43": {
+		Tipo:          ErrorClaveAcceso,
+		Mensaje:       "CLAVE ACCESO REGISTRADA",
+		Detalle:       "El SRI ya recibió un comprobante con esta clave de acceso",
+		Accion:        AccionConsultarAutorizacion,
+		SugerenciaFix: "No reenviar: consultar la autorización del comprobante ya recibido.",
 	},
-	"FIRMA-02": {
-		Tipo:          ErrorFirma,
-		Codigo:        "FIRMA-02",
-		Mensaje:       "Algoritmo de firma no soportado",
-		Detalle:       "El algoritmo de firma no está soportado por SRI",
-		Recuperable:   false,
-		SugerenciaFix: "Usar algoritmo RSA-SHA256 para firma.",
+	"45": {
+		Tipo:          ErrorDatos,
+		Mensaje:       "SECUENCIAL REGISTRADO",
+		Detalle:       "El secuencial ya fue utilizado en otro comprobante autorizado",
+		Accion:        AccionNuevoSecuencial,
+		SugerenciaFix: "Emitir el comprobante con el siguiente secuencial disponible.",
+	},
+	"58": {
+		Tipo:          ErrorClaveAcceso,
+		Mensaje:       "ERROR EN LA ESTRUCTURA DE CLAVE ACCESO",
+		Detalle:       "La clave de acceso no tiene 49 dígitos o su dígito verificador es incorrecto",
+		Accion:        AccionCorregirComprobante,
+		SugerenciaFix: "Regenerar la clave de acceso con el dígito verificador módulo 11.",
+	},
+	"70": {
+		Tipo:          ErrorClaveAcceso,
+		Mensaje:       "CLAVE DE ACCESO EN PROCESAMIENTO",
+		Detalle:       "El comprobante fue recibido y aún se está procesando",
+		Accion:        AccionConsultarAutorizacion,
+		SugerenciaFix: "No reenviar: consultar la autorización en unos segundos.",
 	},
 
-	// Errores del sistema SRI
-	"SRI-01": {
-		Tipo:          ErrorSistema,
-		Codigo:        "SRI-01",
-		Mensaje:       "Sistema en mantenimiento",
-		Detalle:       "El sistema del SRI está en mantenimiento programado",
-		Recuperable:   true,
-		SugerenciaFix: "Reintentar después del horario de mantenimiento.",
+	// Datos del comprobante
+	"46": {
+		Tipo:          ErrorDatos,
+		Mensaje:       "RUC NO EXISTE",
+		Detalle:       "El RUC del emisor no está registrado en el SRI",
+		Accion:        AccionCorregirComprobante,
+		SugerenciaFix: "Verificar el RUC del emisor en infoTributaria.",
 	},
-	"SRI-02": {
+	"47": {
+		Tipo:          ErrorDatos,
+		Mensaje:       "TIPO DE COMPROBANTE NO EXISTE",
+		Detalle:       "El código de documento no corresponde a un comprobante electrónico",
+		Accion:        AccionCorregirComprobante,
+		SugerenciaFix: "Verificar codDoc en infoTributaria (01 para facturas).",
+	},
+	"52": {
+		Tipo:          ErrorDatos,
+		Mensaje:       "ERROR EN DIFERENCIAS",
+		Detalle:       "Los totales del comprobante no cuadran con el detalle",
+		Accion:        AccionCorregirComprobante,
+		SugerenciaFix: "Recalcular subtotales, impuestos y total del comprobante.",
+	},
+	"65": {
+		Tipo:          ErrorDatos,
+		Mensaje:       "FECHA DE EMISIÓN EXTEMPORÁNEA",
+		Detalle:       "El comprobante se envió fuera del plazo permitido",
+		Accion:        AccionCorregirComprobante,
+		SugerenciaFix: "Emitir un nuevo comprobante con la fecha actual.",
+	},
+	"67": {
+		Tipo:          ErrorDatos,
+		Mensaje:       "FECHA INVÁLIDA",
+		Detalle:       "La fecha de emisión no es válida o no coincide con la clave de acceso",
+		Accion:        AccionCorregirComprobante,
+		SugerenciaFix: "Sincronizar la fecha del XML con la de la clave de acceso.",
+	},
+
+	// Sistema SRI
+	"50": {
 		Tipo:          ErrorSistema,
-		Codigo:        "SRI-02",
-		Mensaje:       "Sobrecarga del sistema",
-		Detalle:       "El sistema del SRI está experimentando alta carga",
+		Mensaje:       "ERROR INTERNO GENERAL",
+		Detalle:       "Falla interna de los servicios del SRI",
 		Recuperable:   true,
-		SugerenciaFix: "Reintentar con intervalos exponenciales.",
+		Accion:        AccionReintentarEnvio,
+		SugerenciaFix: "Reintentar el envío con intervalos exponenciales.",
+	},
+	"60": {
+		Tipo:          ErrorInformativo,
+		Mensaje:       "ESTE PROCESO FUE REALIZADO EN EL AMBIENTE DE PRUEBAS",
+		Detalle:       "El comprobante no tiene validez tributaria",
+		Accion:        AccionNinguna,
+		Severidad:     "INFORMATIVO",
+		SugerenciaFix: "Ninguna: usar el ambiente de producción para comprobantes válidos.",
 	},
 }
 
+func init() {
+	for codigo, errorInfo := range codigosErrorSRI {
+		errorInfo.Codigo = codigo
+		if errorInfo.Severidad == "" {
+			errorInfo.Severidad = "ERROR"
+		}
+	}
+}
+
+// identificadorSRI detecta mensajes del tipo "43: ..." o "[43] ..."
+var identificadorSRI = regexp.MustCompile(`^\s*\[?(\d{1,3})\]?\s*[:\-\]]?\s`)
+
+// BuscarErrorSRI retorna una copia de la entrada del catálogo para el identificador
+func BuscarErrorSRI(identificador string) (*ErrorSRI, bool) {
+	errorInfo, ok := codigosErrorSRI[strings.TrimSpace(identificador)]
+	if !ok {
+		return nil, false
+	}
+	copia := *errorInfo
+	return &copia, true
+}
+
+// CatalogoErroresSRI retorna el catálogo completo ordenado por identificador
+func CatalogoErroresSRI() []*ErrorSRI {
+	catalogo := make([]*ErrorSRI, 0, len(codigosErrorSRI))
+	for codigo := range codigosErrorSRI {
+		errorInfo, _ := BuscarErrorSRI(codigo)
+		catalogo = append(catalogo, errorInfo)
+	}
+	sort.Slice(catalogo, func(i, j int) bool {
+		a, _ := strconv.Atoi(catalogo[i].Codigo)
+		b, _ := strconv.Atoi(catalogo[j].Codigo)
+		return a < b
+	})
+	return catalogo
+}
+
+// ErrorDesdeMensajeSRI clasifica un mensaje de recepción o autorización.
+// Los identificadores fuera del catálogo se tratan como errores de datos no recuperables.
+func ErrorDesdeMensajeSRI(mensaje MensajeSRI) *ErrorSRI {
+	errorSRI, ok := BuscarErrorSRI(mensaje.Identificador)
+	if !ok {
+		errorSRI = &ErrorSRI{
+			Tipo:          ErrorDatos,
+			Codigo:        strings.TrimSpace(mensaje.Identificador),
+			Mensaje:       mensaje.Mensaje,
+			Accion:        AccionCorregirComprobante,
+			SugerenciaFix: "Revisar el mensaje del SRI y corregir el comprobante antes de reenviarlo.",
+		}
+		if esMensajeInformativo(mensaje.Tipo) {
+			errorSRI.Tipo = ErrorInformativo
+			errorSRI.Accion = AccionNinguna
+			errorSRI.SugerenciaFix = "Ninguna: mensaje informativo del SRI."
+		}
+	}
+
+	if mensaje.InformacionAdicional != "" {
+		errorSRI.Detalle = mensaje.InformacionAdicional
+	}
+	if mensaje.Tipo != "" {
+		errorSRI.Severidad = strings.ToUpper(mensaje.Tipo)
+	}
+	return errorSRI
+}
+
+// ErroresDesdeMensajes clasifica todos los mensajes de una respuesta del SRI
+func ErroresDesdeMensajes(mensajes []MensajeSRI) []*ErrorSRI {
+	errores := make([]*ErrorSRI, 0, len(mensajes))
+	for _, mensaje := range mensajes {
+		errores = append(errores, ErrorDesdeMensajeSRI(mensaje))
+	}
+	return errores
+}
+
+// TieneAccion indica si alguno de los errores sugiere la acción dada
+func TieneAccion(errores []*ErrorSRI, accion AccionErrorSRI) bool {
+	for _, errorSRI := range errores {
+		if errorSRI.Accion == accion {
+			return true
+		}
+	}
+	return false
+}
+
+// ErroresSRI clasifica los mensajes de todos los comprobantes de la respuesta de recepción
+func (r *RespuestaSolicitud) ErroresSRI() []*ErrorSRI {
+	var mensajes []MensajeSRI
+	for _, comprobante := range r.Comprobantes {
+		mensajes = append(mensajes, comprobante.Mensajes...)
+	}
+	return ErroresDesdeMensajes(mensajes)
+}
+
+// ErroresSRI clasifica los mensajes de la autorización
+func (a AutorizacionSRI) ErroresSRI() []*ErrorSRI {
+	return ErroresDesdeMensajes(a.Mensajes)
+}
+
+// esMensajeInformativo indica si el tipo de mensaje del SRI no es un error
+func esMensajeInformativo(tipo string) bool {
+	tipo = strings.ToUpper(tipo)
+	return tipo == "INFORMATIVO" || tipo == "ADVERTENCIA"
+}
+
+// normalizarMensajeSRI pasa a mayúsculas y quita tildes para comparar textos del SRI
+func normalizarMensajeSRI(texto string) string {
+	return strings.NewReplacer("Á", "A", "É", "E", "Í", "I", "Ó", "O", "Ú", "U").Replace(strings.ToUpper(texto))
+}
+
 // ParsearErrorSRI parsea mensajes de error del SRI y los clasifica
 func ParsearErrorSRI(mensajeError string, codigoHTTP int) *ErrorSRI {
 	mensajeLower := strings.ToLower(mensajeError)
 
-	// Buscar por código específico en el mensaje
-	for codigo, errorInfo := range codigosErrorSRI {
-		if strings.Contains(mensajeLower, strings.ToLower(codigo)) ||
-		   strings.Contains(mensajeLower, strings.ToLower(errorInfo.Mensaje)) {
-			return &ErrorSRI{
-				Tipo:          errorInfo.Tipo,
-				Codigo:        errorInfo.Codigo,
-				Mensaje:       errorInfo.Mensaje,
-				Detalle:       errorInfo.Detalle,
-				Recuperable:   errorInfo.Recuperable,
-				SugerenciaFix: errorInfo.SugerenciaFix,
-			}
+	// Buscar por identificador al inicio del mensaje
+	if coincidencia := identificadorSRI.FindStringSubmatch(mensajeError); coincidencia != nil {
+		if errorSRI, ok := BuscarErrorSRI(coincidencia[1]); ok {
+			errorSRI.Detalle = mensajeError
+			return errorSRI
+		}
+	}
+
+	// Buscar por texto oficial del mensaje
+	mensajeNormalizado := normalizarMensajeSRI(mensajeError)
+	for _, errorInfo := range CatalogoErroresSRI() {
+		if strings.Contains(mensajeNormalizado, normalizarMensajeSRI(errorInfo.Mensaje)) {
+			errorInfo.Detalle = mensajeError
+			return errorInfo
 		}
 	}
 
@@ -384,7 +600,8 @@ func CrearErrorCertificado(detalle string) *ErrorSRI {
 
 // EsErrorRecuperable determina si un error permite reintentos
 func EsErrorRecuperable(err error) bool {
-	if errorSRI, ok := err.(*ErrorSRI); ok {
+	var errorSRI *ErrorSRI
+	if errors.As(err, &errorSRI) {
 		return errorSRI.IsRecuperable()
 	}
 	return false
@@ -392,7 +609,8 @@ func EsErrorRecuperable(err error) bool {
 
 // ObtenerSugerencia obtiene sugerencia para resolver un error
 func ObtenerSugerencia(err error) string {
-	if errorSRI, ok := err.(*ErrorSRI); ok {
+	var errorSRI *ErrorSRI
+	if errors.As(err, &errorSRI) {
 		return errorSRI.GetSugerencia()
 	}
 	return "Error no clasificado. Revisar logs y documentación SRI."
@@ -139,7 +139,7 @@ func TestParsearErrorSRI(t *testing.T) {
 	}{
 		{
 			name:         "Clave de acceso duplicada",
-			mensaje:      "CLAVE-01: Clave de acceso ya registrada",
+			mensaje:      "43: CLAVE ACCESO REGISTRADA",
 			codigoHTTP:   400,
 			expectedTipo: ErrorClaveAcceso,
 			expectRec:    false,
@@ -153,7 +153,7 @@ func TestParsearErrorSRI(t *testing.T) {
 		},
 		{
 			name:         "Certificado expirado",
-			mensaje:      "CERT-01: Certificado expirado",
+			mensaje:      "40: ERROR EN EL CERTIFICADO",
 			codigoHTTP:   403,
 			expectedTipo: ErrorCertificado,
 			expectRec:    false,
@@ -411,7 +411,7 @@ func BenchmarkCrearErrorSRI(b *testing.B) {
 
 // BenchmarkParsearErrorSRI benchmarks SRI error parsing
 func BenchmarkParsearErrorSRI(b *testing.B) {
-	mensaje := "CLAVE-01: Clave de acceso ya registrada"
+	mensaje := "43: CLAVE ACCESO REGISTRADA"
 	
 	for i := 0; i < b.N; i++ {
 		ParsearErrorSRI(mensaje, 400)
@@ -465,4 +465,111 @@ func TestErrorHandlingEdgeCases(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+
+// TestErrorDesdeMensajeSRI verifica la clasificación de identificadores oficiales
+func TestErrorDesdeMensajeSRI(t *testing.T) {
+	tests := []struct {
+		identificador string
+		tipo          TipoErrorSRI
+		recuperable   bool
+		accion        AccionErrorSRI
+	}{
+		{"35", ErrorFormato, false, AccionCorregirComprobante},
+		{"39", ErrorFirma, false, AccionRevisarCertificado},
+		{"43", ErrorClaveAcceso, false, AccionConsultarAutorizacion},
+		{"45", ErrorDatos, false, AccionNuevoSecuencial},
+		{"50", ErrorSistema, true, AccionReintentarEnvio},
+		{"56", ErrorEmisor, false, AccionRegularizarEmisor},
+		{"65", ErrorDatos, false, AccionCorregirComprobante},
+		{"70", ErrorClaveAcceso, false, AccionConsultarAutorizacion},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.identificador, func(t *testing.T) {
+			errorSRI := ErrorDesdeMensajeSRI(MensajeSRI{
+				Identificador:        tt.identificador,
+				Mensaje:              "TEXTO DEL SRI",
+				InformacionAdicional: "detalle " + tt.identificador,
+				Tipo:                 "ERROR",
+			})
+
+			if errorSRI.Codigo != tt.identificador || errorSRI.Tipo != tt.tipo {
+				t.Errorf("Clasificación inesperada: %s %v", errorSRI.Codigo, errorSRI.Tipo)
+			}
+			if errorSRI.Recuperable != tt.recuperable || errorSRI.Accion != tt.accion {
+				t.Errorf("Recuperable=%v accion=%s, esperado %v %s",
+					errorSRI.Recuperable, errorSRI.Accion, tt.recuperable, tt.accion)
+			}
+			if errorSRI.Detalle != "detalle "+tt.identificador || errorSRI.SugerenciaFix == "" {
+				t.Errorf("Detalle o sugerencia incorrectos: %+v", errorSRI)
+			}
+		})
+	}
+
+	// Consultar no debe modificar el catálogo compartido
+	if original, _ := BuscarErrorSRI("35"); original.Detalle == "detalle 35" {
+		t.Error("ErrorDesdeMensajeSRI modificó la entrada del catálogo")
+	}
+
+	t.Run("Identificador desconocido", func(t *testing.T) {
+		errorSRI := ErrorDesdeMensajeSRI(MensajeSRI{Identificador: "999", Mensaje: "NUEVO ERROR", Tipo: "ERROR"})
+		if errorSRI.Tipo != ErrorDatos || errorSRI.Recuperable || errorSRI.Mensaje != "NUEVO ERROR" {
+			t.Errorf("Error desconocido mal clasificado: %+v", errorSRI)
+		}
+
+		informativo := ErrorDesdeMensajeSRI(MensajeSRI{Identificador: "998", Mensaje: "AVISO", Tipo: "ADVERTENCIA"})
+		if informativo.Tipo != ErrorInformativo || informativo.Accion != AccionNinguna {
+			t.Errorf("Advertencia mal clasificada: %+v", informativo)
+		}
+	})
+}
+
+// TestParsearErrorSRIIdentificadorOficial verifica la búsqueda por identificador y por texto
+func TestParsearErrorSRIIdentificadorOficial(t *testing.T) {
+	porCodigo := ParsearErrorSRI("[45] SECUENCIAL REGISTRADO", 200)
+	if porCodigo.Codigo != "45" || porCodigo.Accion != AccionNuevoSecuencial {
+		t.Errorf("Se esperaba el error 45: %+v", porCodigo)
+	}
+
+	porTexto := ParsearErrorSRI("Comprobante devuelto: CLAVE DE ACCESO EN PROCESAMIENTO", 200)
+	if porTexto.Codigo != "70" {
+		t.Errorf("Se esperaba el error 70 por texto: %+v", porTexto)
+	}
+
+	sinTilde := ParsearErrorSRI("FECHA DE EMISION EXTEMPORANEA", 200)
+	if sinTilde.Codigo != "65" {
+		t.Errorf("El texto sin tildes debería reconocerse como 65: %+v", sinTilde)
+	}
+}
+
+// TestCatalogoErroresSRI verifica orden y consistencia del catálogo
+func TestCatalogoErroresSRI(t *testing.T) {
+	catalogo := CatalogoErroresSRI()
+	if len(catalogo) == 0 {
+		t.Fatal("El catálogo no debería estar vacío")
+	}
+	if catalogo[0].Codigo != "2" {
+		t.Errorf("El catálogo debería ordenarse numéricamente, primero: %s", catalogo[0].Codigo)
+	}
+	for _, errorSRI := range catalogo {
+		if errorSRI.Accion == "" || errorSRI.SugerenciaFix == "" || errorSRI.Severidad == "" {
+			t.Errorf("Entrada incompleta en el catálogo: %+v", errorSRI)
+		}
+	}
+
+	respuesta := &RespuestaSolicitud{
+		Estado: EstadoSRIDevuelta,
+		Comprobantes: []ComprobanteRecepcion{{
+			Mensajes: []MensajeSRI{{Identificador: "43", Mensaje: "CLAVE ACCESO REGISTRADA"}},
+		}},
+	}
+	if !TieneAccion(respuesta.ErroresSRI(), AccionConsultarAutorizacion) {
+		t.Error("La respuesta con error 43 debería sugerir consultar la autorización")
+	}
+
+	envuelto := fmt.Errorf("envío fallido: %w", ErrorDesdeMensajeSRI(MensajeSRI{Identificador: "50"}))
+	if !EsErrorRecuperable(envuelto) {
+		t.Error("EsErrorRecuperable debería reconocer errores SRI envueltos")
+	}
+}
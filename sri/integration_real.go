@@ -8,10 +8,12 @@ import (
 	"go-facturacion-sri/config"
 	"go-facturacion-sri/factory"
 	"go-facturacion-sri/models"
+	"go-facturacion-sri/secrets"
 )
 
-// TestearIntegracionSRIReal realiza testing completo con SRI real
-func TestearIntegracionSRIReal() error {
+// TestearIntegracionSRIReal realiza testing completo con SRI real; resolvedor resuelve la
+// contraseña del certificado (nil: solo referencias env: y file:)
+func TestearIntegracionSRIReal(resolvedor *secrets.Resolvedor) error {
 	fmt.Println("🚀 INICIANDO TESTING DE INTEGRACIÓN SRI REAL")
 	fmt.Println("=" + string(make([]byte, 50)))
 
@@ -47,12 +49,15 @@ func TestearIntegracionSRIReal() error {
 		fmt.Println("     2. Actualizar config/desarrollo.json")
 		fmt.Println("     3. Reiniciar sistema")
 	} else {
-		// Intentar cargar certificado real
-		cert, err := CargarCertificado(CertificadoConfig{
+		// Intentar cargar certificado real (la contraseña puede ser una referencia a secreto)
+		if resolvedor == nil {
+			resolvedor = secrets.NuevoResolvedor()
+		}
+		cert, err := CargarCertificadoConSecretos(CertificadoConfig{
 			RutaArchivo:     config.Config.Certificado.RutaArchivo,
 			Password:        config.Config.Certificado.Password,
 			ValidarVigencia: true,
-		})
+		}, resolvedor)
 		if err != nil {
 			fmt.Printf("⚠️  Error cargando certificado: %v\n", err)
 			fmt.Println("   Cambiando a modo demo...")
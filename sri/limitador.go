@@ -0,0 +1,168 @@
+// Package sri implementa la limitación de tasa y concurrencia de las llamadas al SRI
+package sri
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// ConfigLimitador límites de salida hacia el SRI; un valor 0 deshabilita ese límite
+type ConfigLimitador struct {
+	PeticionesPorSegundo float64 // Tasa sostenida del token bucket
+	Rafaga               int     // Capacidad del bucket (peticiones seguidas sin esperar)
+	MaxConcurrentes      int     // Peticiones en vuelo simultáneas
+}
+
+// MetricasLimitador tiempos acumulados del limitador; la espera en cola se mide aparte
+// del tiempo de llamada para distinguir throttling propio de lentitud del SRI
+type MetricasLimitador struct {
+	Peticiones       int64   `json:"peticiones"`
+	Canceladas       int64   `json:"canceladas"` // Abandonadas por el contexto mientras esperaban
+	EnVuelo          int     `json:"en_vuelo"`
+	EnCola           int     `json:"en_cola"`
+	TiempoColaMs     int64   `json:"tiempo_cola_ms"`    // Espera total antes de llamar al SRI
+	TiempoLlamadaMs  int64   `json:"tiempo_llamada_ms"` // Duración total de las llamadas
+	MaxTiempoColaMs  int64   `json:"max_tiempo_cola_ms"`
+	MaxConcurrentes  int     `json:"max_concurrentes"`
+	PeticionesPorSeg float64 `json:"peticiones_por_segundo"`
+}
+
+// LimitadorSRI combina un token bucket con un tope de peticiones en vuelo
+type LimitadorSRI struct {
+	config ConfigLimitador
+
+	mu       sync.Mutex
+	tokens   float64
+	ultimo   time.Time
+	metricas MetricasLimitador
+
+	enVuelo chan struct{} // Semáforo de concurrencia (nil sin tope)
+}
+
+// NuevoLimitadorSRI crea un limitador con el bucket lleno
+func NuevoLimitadorSRI(config ConfigLimitador) *LimitadorSRI {
+	if config.PeticionesPorSegundo > 0 && config.Rafaga <= 0 {
+		config.Rafaga = 1
+	}
+
+	limitador := &LimitadorSRI{
+		config: config,
+		tokens: float64(config.Rafaga),
+		ultimo: time.Now(),
+	}
+	if config.MaxConcurrentes > 0 {
+		limitador.enVuelo = make(chan struct{}, config.MaxConcurrentes)
+	}
+	limitador.metricas.MaxConcurrentes = config.MaxConcurrentes
+	limitador.metricas.PeticionesPorSeg = config.PeticionesPorSegundo
+	return limitador
+}
+
+// Adquirir espera turno según la tasa y la concurrencia configuradas. La espera se
+// interrumpe con el contexto. La función retornada debe llamarse al terminar la llamada.
+func (l *LimitadorSRI) Adquirir(ctx context.Context) (func(), error) {
+	inicio := time.Now()
+
+	l.mu.Lock()
+	l.metricas.EnCola++
+	l.mu.Unlock()
+
+	if err := l.esperarToken(ctx); err != nil {
+		l.registrarCancelacion()
+		return nil, err
+	}
+
+	if l.enVuelo != nil {
+		select {
+		case l.enVuelo <- struct{}{}:
+		case <-ctx.Done():
+			l.registrarCancelacion()
+			return nil, ctx.Err()
+		}
+	}
+
+	espera := time.Since(inicio)
+	l.mu.Lock()
+	l.metricas.EnCola--
+	l.metricas.EnVuelo++
+	l.metricas.Peticiones++
+	l.metricas.TiempoColaMs += espera.Milliseconds()
+	if espera.Milliseconds() > l.metricas.MaxTiempoColaMs {
+		l.metricas.MaxTiempoColaMs = espera.Milliseconds()
+	}
+	l.mu.Unlock()
+
+	inicioLlamada := time.Now()
+	var liberado sync.Once
+	return func() {
+		liberado.Do(func() {
+			l.mu.Lock()
+			l.metricas.EnVuelo--
+			l.metricas.TiempoLlamadaMs += time.Since(inicioLlamada).Milliseconds()
+			l.mu.Unlock()
+
+			if l.enVuelo != nil {
+				<-l.enVuelo
+			}
+		})
+	}, nil
+}
+
+// esperarToken reserva un token del bucket y espera hasta que esté disponible.
+// Si el contexto se cancela antes, el token reservado se devuelve al bucket.
+func (l *LimitadorSRI) esperarToken(ctx context.Context) error {
+	if l.config.PeticionesPorSegundo <= 0 {
+		return ctx.Err()
+	}
+
+	l.mu.Lock()
+	ahora := time.Now()
+	l.tokens += ahora.Sub(l.ultimo).Seconds() * l.config.PeticionesPorSegundo
+	if maximo := float64(l.config.Rafaga); l.tokens > maximo {
+		l.tokens = maximo
+	}
+	l.ultimo = ahora
+	l.tokens--
+
+	var espera time.Duration
+	if l.tokens < 0 {
+		espera = time.Duration(-l.tokens / l.config.PeticionesPorSegundo * float64(time.Second))
+	}
+	l.mu.Unlock()
+
+	if espera <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(espera)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		l.tokens++
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// registrarCancelacion contabiliza una petición abandonada mientras esperaba turno
+func (l *LimitadorSRI) registrarCancelacion() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.metricas.EnCola--
+	l.metricas.Canceladas++
+}
+
+// ObtenerMetricas retorna una copia de las métricas acumuladas
+func (l *LimitadorSRI) ObtenerMetricas() MetricasLimitador {
+	if l == nil {
+		return MetricasLimitador{}
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.metricas
+}
@@ -0,0 +1,116 @@
+package sri
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLimitadorTokenBucket(t *testing.T) {
+	limitador := NuevoLimitadorSRI(ConfigLimitador{PeticionesPorSegundo: 20, Rafaga: 2})
+
+	inicio := time.Now()
+	for i := 0; i < 4; i++ {
+		liberar, err := limitador.Adquirir(context.Background())
+		if err != nil {
+			t.Fatalf("Adquirir() error: %v", err)
+		}
+		liberar()
+	}
+
+	// La ráfaga cubre dos peticiones; las otras dos esperan 50ms cada una
+	if transcurrido := time.Since(inicio); transcurrido < 90*time.Millisecond {
+		t.Errorf("Cuatro peticiones tomaron %v, se esperaba al menos 100ms", transcurrido)
+	}
+
+	metricas := limitador.ObtenerMetricas()
+	if metricas.Peticiones != 4 || metricas.EnVuelo != 0 || metricas.EnCola != 0 {
+		t.Errorf("Métricas inesperadas: %+v", metricas)
+	}
+	if metricas.TiempoColaMs < 90 {
+		t.Errorf("TiempoColaMs = %d, esperado al menos 90", metricas.TiempoColaMs)
+	}
+}
+
+func TestLimitadorMaxConcurrentes(t *testing.T) {
+	limitador := NuevoLimitadorSRI(ConfigLimitador{MaxConcurrentes: 1})
+
+	liberar, err := limitador.Adquirir(context.Background())
+	if err != nil {
+		t.Fatalf("Adquirir() error: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	if _, err := limitador.Adquirir(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Se esperaba DeadlineExceeded con el cupo ocupado, obtenido: %v", err)
+	}
+
+	metricas := limitador.ObtenerMetricas()
+	if metricas.EnVuelo != 1 || metricas.Canceladas != 1 || metricas.EnCola != 0 {
+		t.Errorf("Métricas inesperadas: %+v", metricas)
+	}
+
+	liberar()
+	liberar() // Liberar dos veces no debe devolver un cupo extra
+
+	segundo, err := limitador.Adquirir(context.Background())
+	if err != nil {
+		t.Fatalf("Adquirir() tras liberar error: %v", err)
+	}
+	defer segundo()
+
+	ctx2, cancel2 := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel2()
+	if _, err := limitador.Adquirir(ctx2); err == nil {
+		t.Error("El cupo debería seguir limitado a una petición")
+	}
+}
+
+func TestLimitadorCancelacionDevuelveToken(t *testing.T) {
+	limitador := NuevoLimitadorSRI(ConfigLimitador{PeticionesPorSegundo: 1, Rafaga: 1})
+
+	liberar, err := limitador.Adquirir(context.Background())
+	if err != nil {
+		t.Fatalf("Adquirir() error: %v", err)
+	}
+	liberar()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	inicio := time.Now()
+	if _, err := limitador.Adquirir(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Se esperaba DeadlineExceeded, obtenido: %v", err)
+	}
+	if time.Since(inicio) > 500*time.Millisecond {
+		t.Error("La espera debería interrumpirse con el contexto")
+	}
+
+	// El token reservado por la petición cancelada vuelve al bucket
+	limitador.mu.Lock()
+	tokens := limitador.tokens
+	limitador.mu.Unlock()
+	if tokens < -0.1 {
+		t.Errorf("tokens = %.2f, la cancelación debería devolver el token", tokens)
+	}
+}
+
+func TestSOAPClientMetricasLimitador(t *testing.T) {
+	_, cliente := iniciarSimuladorPrueba(t)
+	cliente.ConfigurarLimitador(ConfigLimitador{PeticionesPorSegundo: 100, Rafaga: 1, MaxConcurrentes: 2})
+
+	clave, xmlData := comprobanteSimulado(t, 3)
+	if _, err := cliente.EnviarComprobante(xmlData); err != nil {
+		t.Fatalf("EnviarComprobante() error: %v", err)
+	}
+	if _, err := cliente.ConsultarAutorizacion(clave); err != nil {
+		t.Fatalf("ConsultarAutorizacion() error: %v", err)
+	}
+
+	metricas := cliente.ObtenerMetricasLimitador()
+	if metricas.Peticiones != 2 || metricas.EnVuelo != 0 || metricas.MaxConcurrentes != 2 {
+		t.Errorf("Métricas inesperadas: %+v", metricas)
+	}
+}
@@ -0,0 +1,440 @@
+// Package sri implementa el envío de comprobantes en lote masivo al SRI
+package sri
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+// Límites aplicados al construir un lote masivo
+const (
+	MaxComprobantesLote = 50
+	MaxTamanoLoteBytes  = 500 * 1024
+)
+
+// ComprobanteLote comprobante firmado incluido en un lote
+type ComprobanteLote struct {
+	ClaveAcceso string
+	XML         []byte
+}
+
+// LoteMasivo agrupa comprobantes firmados bajo una clave de acceso de lote
+type LoteMasivo struct {
+	ClaveAcceso  string
+	RUC          string
+	Comprobantes []ComprobanteLote
+}
+
+// loteXML estructura <lote> que recibe el servicio de recepción
+type loteXML struct {
+	XMLName      xml.Name             `xml:"lote"`
+	Version      string               `xml:"version,attr"`
+	ClaveAcceso  string               `xml:"claveAcceso"`
+	RUC          string               `xml:"ruc"`
+	Comprobantes []comprobanteLoteXML `xml:"comprobantes>comprobante"`
+}
+
+// comprobanteLoteXML comprobante embebido como CDATA dentro del lote
+type comprobanteLoteXML struct {
+	Contenido string `xml:",cdata"`
+}
+
+// ResultadoDocumentoLote resultado individual de un comprobante del lote
+type ResultadoDocumentoLote struct {
+	ClaveAcceso  string           `json:"claveAcceso"`
+	Estado       string           `json:"estado"` // RECIBIDA, DEVUELTA, EN PROCESO, AUTORIZADO, NO AUTORIZADO
+	Mensajes     []MensajeSRI     `json:"mensajes,omitempty"`
+	Autorizacion *AutorizacionSRI `json:"autorizacion,omitempty"`
+}
+
+// ResultadoLote resultado del lote separado por comprobante
+type ResultadoLote struct {
+	ClaveAccesoLote string                   `json:"claveAccesoLote"`
+	Estado          string                   `json:"estado"`             // Estado general de recepción del lote
+	Mensajes        []MensajeSRI             `json:"mensajes,omitempty"` // Errores que afectan a todo el lote
+	Documentos      []ResultadoDocumentoLote `json:"documentos"`
+}
+
+// SolicitudAutorizacionLote estructura para consultar la autorización de un lote
+type SolicitudAutorizacionLote struct {
+	XMLName xml.Name             `xml:"soap:Envelope"`
+	SoapNS  string               `xml:"xmlns:soap,attr"`
+	SriNS   string               `xml:"xmlns:sri,attr"`
+	Body    BodyAutorizacionLote `xml:"soap:Body"`
+}
+
+// BodyAutorizacionLote cuerpo del SOAP para autorización de lote
+type BodyAutorizacionLote struct {
+	XMLName                  xml.Name                 `xml:"soap:Body"`
+	AutorizarComprobanteLote AutorizarComprobanteLote `xml:"sri:autorizacionComprobanteLote"`
+}
+
+// AutorizarComprobanteLote operación de autorización de lote
+type AutorizarComprobanteLote struct {
+	XMLName         xml.Name `xml:"sri:autorizacionComprobanteLote"`
+	ClaveAccesoLote string   `xml:"claveAccesoLote"`
+}
+
+// RespuestaLote respuesta del servicio de autorización para un lote
+type RespuestaLote struct {
+	XMLName                   xml.Name          `xml:"respuestaAutorizacionLote"`
+	ClaveAccesoLoteConsultada string            `xml:"claveAccesoLoteConsultada"`
+	NumeroComprobantesLote    string            `xml:"numeroComprobantesLote"`
+	Autorizaciones            []AutorizacionSRI `xml:"autorizaciones>autorizacion"`
+}
+
+// ConstruirLote genera la clave de acceso del lote y agrega los comprobantes firmados
+func ConstruirLote(config ClaveAccesoConfig, comprobantes ...[]byte) (*LoteMasivo, error) {
+	claveLote, err := GenerarClaveAcceso(config)
+	if err != nil {
+		return nil, fmt.Errorf("error generando clave de acceso del lote: %v", err)
+	}
+
+	lote := &LoteMasivo{
+		ClaveAcceso: claveLote,
+		RUC:         config.RUCEmisor,
+	}
+	for i, comprobante := range comprobantes {
+		if err := lote.Agregar(comprobante); err != nil {
+			return nil, fmt.Errorf("comprobante %d: %v", i+1, err)
+		}
+	}
+
+	return lote, nil
+}
+
+// Agregar incorpora un comprobante firmado validando su clave de acceso y los límites del lote
+func (l *LoteMasivo) Agregar(xmlComprobante []byte) error {
+	if len(l.Comprobantes) >= MaxComprobantesLote {
+		return fmt.Errorf("el lote ya tiene el máximo de %d comprobantes", MaxComprobantesLote)
+	}
+
+	claveAcceso, err := validarEstructuraComprobante(xmlComprobante)
+	if err != nil {
+		return err
+	}
+
+	// La clave de acceso incluye el RUC emisor en las posiciones 11 a 23
+	if l.RUC != "" && claveAcceso[10:23] != l.RUC {
+		return fmt.Errorf("el comprobante %s no pertenece al RUC %s", claveAcceso, l.RUC)
+	}
+
+	for _, existente := range l.Comprobantes {
+		if existente.ClaveAcceso == claveAcceso {
+			return fmt.Errorf("comprobante duplicado en el lote: %s", claveAcceso)
+		}
+	}
+
+	if l.tamano()+len(xmlComprobante) > MaxTamanoLoteBytes {
+		return fmt.Errorf("el lote supera el tamaño máximo de %d bytes", MaxTamanoLoteBytes)
+	}
+
+	l.Comprobantes = append(l.Comprobantes, ComprobanteLote{ClaveAcceso: claveAcceso, XML: xmlComprobante})
+	return nil
+}
+
+// Claves retorna las claves de acceso de los comprobantes del lote en orden
+func (l *LoteMasivo) Claves() []string {
+	claves := make([]string, len(l.Comprobantes))
+	for i, comprobante := range l.Comprobantes {
+		claves[i] = comprobante.ClaveAcceso
+	}
+	return claves
+}
+
+// GenerarXML serializa el lote en el formato <lote> del SRI
+func (l *LoteMasivo) GenerarXML() ([]byte, error) {
+	if len(l.Comprobantes) == 0 {
+		return nil, fmt.Errorf("el lote no tiene comprobantes")
+	}
+
+	documento := loteXML{
+		Version:     "1.0.0",
+		ClaveAcceso: l.ClaveAcceso,
+		RUC:         l.RUC,
+	}
+	for _, comprobante := range l.Comprobantes {
+		if bytes.Contains(comprobante.XML, []byte("]]>")) {
+			return nil, fmt.Errorf("el comprobante %s no puede embeberse como CDATA", comprobante.ClaveAcceso)
+		}
+		documento.Comprobantes = append(documento.Comprobantes, comprobanteLoteXML{Contenido: string(comprobante.XML)})
+	}
+
+	contenido, err := xml.MarshalIndent(documento, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("error generando XML del lote: %v", err)
+	}
+
+	return append([]byte(xml.Header), contenido...), nil
+}
+
+// tamano suma el tamaño de los comprobantes incluidos
+func (l *LoteMasivo) tamano() int {
+	total := 0
+	for _, comprobante := range l.Comprobantes {
+		total += len(comprobante.XML)
+	}
+	return total
+}
+
+// Documento retorna el resultado de un comprobante del lote
+func (r *ResultadoLote) Documento(claveAcceso string) (*ResultadoDocumentoLote, bool) {
+	for i := range r.Documentos {
+		if r.Documentos[i].ClaveAcceso == claveAcceso {
+			return &r.Documentos[i], true
+		}
+	}
+	return nil, false
+}
+
+// Resumen cuenta los comprobantes del lote por estado
+func (r *ResultadoLote) Resumen() map[string]int {
+	resumen := make(map[string]int)
+	for _, documento := range r.Documentos {
+		resumen[documento.Estado]++
+	}
+	return resumen
+}
+
+// Pendientes indica si algún comprobante aún espera resultado de autorización
+func (r *ResultadoLote) Pendientes() bool {
+	for _, documento := range r.Documentos {
+		if documento.Estado == EstadoSRIRecibida || documento.Estado == EstadoSRIEnProceso {
+			return true
+		}
+	}
+	return false
+}
+
+// EnviarLote envía un lote masivo al servicio de recepción con circuit breaker
+func (c *SOAPClient) EnviarLote(lote *LoteMasivo) (*ResultadoLote, error) {
+	return c.EnviarLoteContexto(context.Background(), lote)
+}
+
+// EnviarLoteContexto envía el lote y separa la respuesta de recepción por comprobante
+func (c *SOAPClient) EnviarLoteContexto(ctx context.Context, lote *LoteMasivo) (*ResultadoLote, error) {
+	xmlLote, err := lote.GenerarXML()
+	if err != nil {
+		return nil, err
+	}
+
+	// El lote viaja por la misma operación validarComprobante (y el mismo circuit breaker)
+	respuesta, err := c.EnviarComprobanteContexto(ctx, xmlLote)
+	if err != nil {
+		return nil, err
+	}
+
+	return dividirRecepcionLote(lote, respuesta), nil
+}
+
+// ConsultarAutorizacionLote consulta la autorización de todos los comprobantes del lote
+func (c *SOAPClient) ConsultarAutorizacionLote(claveAccesoLote string) (*RespuestaLote, error) {
+	return c.ConsultarAutorizacionLoteContexto(context.Background(), claveAccesoLote)
+}
+
+// ConsultarAutorizacionLoteContexto consulta la autorización del lote con circuit breaker
+func (c *SOAPClient) ConsultarAutorizacionLoteContexto(ctx context.Context, claveAccesoLote string) (*RespuestaLote, error) {
+	var respuesta *RespuestaLote
+	err := c.circuitBreaker.EjecutarContexto(ctx, func(ctx context.Context) error {
+		resp, err := c.consultarAutorizacionLoteInterno(ctx, claveAccesoLote)
+		if err != nil {
+			return err
+		}
+		respuesta = resp
+		return nil
+	})
+
+	return respuesta, err
+}
+
+// consultarAutorizacionLoteInterno implementación interna sin circuit breaker
+func (c *SOAPClient) consultarAutorizacionLoteInterno(ctx context.Context, claveAccesoLote string) (*RespuestaLote, error) {
+	solicitud := SolicitudAutorizacionLote{
+		SoapNS: "http://schemas.xmlsoap.org/soap/envelope/",
+		SriNS:  "http://ec.gob.sri.ws.autorizacion",
+		Body: BodyAutorizacionLote{
+			AutorizarComprobanteLote: AutorizarComprobanteLote{
+				ClaveAccesoLote: claveAccesoLote,
+			},
+		},
+	}
+
+	soapXML, err := xml.MarshalIndent(solicitud, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("error serializando solicitud SOAP: %v", err)
+	}
+	soapRequest := []byte(xml.Header + string(soapXML))
+
+	respBody, codigoHTTP, err := c.ejecutarSOAP(ctx, OperacionAutorizacionLote, claveAccesoLote, c.endpointAutorizacion(), soapRequest)
+	if err != nil {
+		return nil, err
+	}
+	if codigoHTTP != 200 {
+		return nil, fmt.Errorf("SRI respondió con código %d: %s", codigoHTTP, string(respBody))
+	}
+
+	return parsearRespuestaAutorizacionLote(respBody)
+}
+
+// parsearRespuestaAutorizacionLote extrae respuestaAutorizacionLote del envelope SOAP
+func parsearRespuestaAutorizacionLote(respXML []byte) (*RespuestaLote, error) {
+	respStr := strings.ReplaceAll(string(respXML), "ns2:", "")
+	respStr = strings.ReplaceAll(respStr, "ns3:", "")
+
+	inicio := strings.Index(respStr, "<respuestaAutorizacionLote")
+	if inicio == -1 {
+		return nil, fmt.Errorf("no se encontró respuestaAutorizacionLote en la respuesta del SRI")
+	}
+	fin := strings.Index(respStr[inicio:], "</respuestaAutorizacionLote>")
+	if fin == -1 {
+		return nil, fmt.Errorf("respuesta del SRI mal formada")
+	}
+
+	var respuesta RespuestaLote
+	contenido := respStr[inicio : inicio+fin+len("</respuestaAutorizacionLote>")]
+	if err := xml.Unmarshal([]byte(contenido), &respuesta); err != nil {
+		return nil, fmt.Errorf("error parseando respuesta del SRI: %v", err)
+	}
+
+	return &respuesta, nil
+}
+
+// dividirRecepcionLote asigna a cada comprobante su estado de recepción.
+// Los errores sobre la clave del lote afectan a todos; los comprobantes sin
+// mensajes de error se consideran recibidos.
+func dividirRecepcionLote(lote *LoteMasivo, respuesta *RespuestaSolicitud) *ResultadoLote {
+	resultado := &ResultadoLote{
+		ClaveAccesoLote: lote.ClaveAcceso,
+		Estado:          respuesta.Estado,
+	}
+
+	porClave := make(map[string][]MensajeSRI)
+	for _, comprobante := range respuesta.Comprobantes {
+		if comprobante.ClaveAcceso == "" || comprobante.ClaveAcceso == lote.ClaveAcceso {
+			resultado.Mensajes = append(resultado.Mensajes, comprobante.Mensajes...)
+			continue
+		}
+		porClave[comprobante.ClaveAcceso] = append(porClave[comprobante.ClaveAcceso], comprobante.Mensajes...)
+	}
+	loteRechazado := respuesta.Estado == EstadoSRIDevuelta && contieneError(resultado.Mensajes)
+
+	for _, comprobante := range lote.Comprobantes {
+		documento := ResultadoDocumentoLote{
+			ClaveAcceso: comprobante.ClaveAcceso,
+			Estado:      EstadoSRIRecibida,
+			Mensajes:    porClave[comprobante.ClaveAcceso],
+		}
+		switch {
+		case loteRechazado:
+			documento.Estado = EstadoSRIDevuelta
+			documento.Mensajes = append(documento.Mensajes, resultado.Mensajes...)
+		case contieneError(documento.Mensajes):
+			documento.Estado = EstadoSRIDevuelta
+		}
+		resultado.Documentos = append(resultado.Documentos, documento)
+	}
+
+	return resultado
+}
+
+// AplicarAutorizaciones actualiza los comprobantes recibidos con la respuesta de autorización del lote
+func (r *ResultadoLote) AplicarAutorizaciones(respuesta *RespuestaLote) {
+	for i := range respuesta.Autorizaciones {
+		autorizacion := respuesta.Autorizaciones[i]
+		claveAcceso := extraerClaveAutorizacion(autorizacion)
+
+		documento, ok := r.Documento(claveAcceso)
+		if !ok || documento.Estado == EstadoSRIDevuelta {
+			continue
+		}
+		documento.Estado = autorizacion.Estado
+		documento.Mensajes = autorizacion.Mensajes
+		documento.Autorizacion = &autorizacion
+	}
+}
+
+// extraerClaveAutorizacion obtiene la clave de acceso de una autorización del lote
+func extraerClaveAutorizacion(autorizacion AutorizacionSRI) string {
+	if len(autorizacion.NumeroAutorizacion) == 49 {
+		return autorizacion.NumeroAutorizacion
+	}
+
+	var documento struct {
+		ClaveAcceso string `xml:"infoTributaria>claveAcceso"`
+	}
+	if err := xml.Unmarshal([]byte(autorizacion.Comprobante), &documento); err != nil {
+		return ""
+	}
+	return strings.TrimSpace(documento.ClaveAcceso)
+}
+
+// contieneError indica si algún mensaje del SRI es de tipo ERROR
+func contieneError(mensajes []MensajeSRI) bool {
+	for _, mensaje := range mensajes {
+		if mensaje.Tipo == "" || strings.EqualFold(mensaje.Tipo, "ERROR") {
+			return true
+		}
+	}
+	return false
+}
+
+// ReintentarEnvioLote envía un lote con reintentos
+func (c *SOAPClient) ReintentarEnvioLote(lote *LoteMasivo, config ConfigReintento) (*ResultadoLote, *ResultadoReintento) {
+	return c.ReintentarEnvioLoteContexto(context.Background(), lote, config)
+}
+
+// ReintentarEnvioLoteContexto envía un lote con reintentos cancelables
+func (c *SOAPClient) ReintentarEnvioLoteContexto(ctx context.Context, lote *LoteMasivo, config ConfigReintento) (*ResultadoLote, *ResultadoReintento) {
+	var resultado *ResultadoLote
+
+	fn := func(ctx context.Context) error {
+		res, err := c.EnviarLoteContexto(ctx, lote)
+		if err != nil {
+			return err
+		}
+		resultado = res
+		return nil
+	}
+
+	return resultado, EjecutarConReintentoContexto(ctx, fn, config)
+}
+
+// ProcesarLote envía el lote y consulta su autorización hasta resolver cada comprobante
+func (c *SOAPClient) ProcesarLote(lote *LoteMasivo, config ConfigReintento) (*ResultadoLote, error) {
+	return c.ProcesarLoteContexto(context.Background(), lote, config)
+}
+
+// ProcesarLoteContexto envía el lote y consulta su autorización con reintentos cancelables.
+// Si se agotan las consultas se retorna el resultado parcial junto con el error.
+func (c *SOAPClient) ProcesarLoteContexto(ctx context.Context, lote *LoteMasivo, config ConfigReintento) (*ResultadoLote, error) {
+	resultado, envio := c.ReintentarEnvioLoteContexto(ctx, lote, config)
+	if !envio.Exitoso {
+		return nil, fmt.Errorf("error enviando lote tras %d intentos: %v", envio.IntentosRealizados, envio.UltimoError)
+	}
+	if !resultado.Pendientes() {
+		return resultado, nil
+	}
+
+	consulta := EjecutarConReintentoContexto(ctx, func(ctx context.Context) error {
+		respuesta, err := c.ConsultarAutorizacionLoteContexto(ctx, lote.ClaveAcceso)
+		if err != nil {
+			return err
+		}
+		resultado.AplicarAutorizaciones(respuesta)
+
+		// Mientras queden comprobantes en proceso se trata como error temporal
+		if resultado.Pendientes() {
+			return CrearErrorConexion("Lote aún en procesamiento")
+		}
+		return nil
+	}, config)
+
+	if !consulta.Exitoso {
+		return resultado, fmt.Errorf("lote con comprobantes pendientes tras %d consultas: %v",
+			consulta.IntentosRealizados, consulta.UltimoError)
+	}
+	return resultado, nil
+}
@@ -0,0 +1,181 @@
+package sri
+
+import (
+	"encoding/xml"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// configReintentoLotePrueba reintentos inmediatos para no alargar los tests
+var configReintentoLotePrueba = ConfigReintento{
+	MaxIntentos:      4,
+	TiempoBase:       time.Millisecond,
+	Multiplicador:    1,
+	TiempoMaximo:     5 * time.Millisecond,
+	SoloRecuperables: true,
+}
+
+// lotePrueba construye un lote con los secuenciales indicados
+func lotePrueba(t *testing.T, secuenciales ...int) (*LoteMasivo, []string) {
+	t.Helper()
+
+	var claves []string
+	var comprobantes [][]byte
+	for _, secuencial := range secuenciales {
+		clave, xmlData := comprobanteSimulado(t, secuencial)
+		claves = append(claves, clave)
+		comprobantes = append(comprobantes, xmlData)
+	}
+
+	lote, err := ConstruirLote(ClaveAccesoConfig{
+		FechaEmision:     time.Date(2025, 6, 30, 0, 0, 0, 0, time.UTC),
+		TipoComprobante:  Factura,
+		RUCEmisor:        "1792146739001",
+		Ambiente:         Pruebas,
+		Serie:            "001001",
+		NumeroSecuencial: "000000001",
+		CodigoNumerico:   "87654321",
+		TipoEmision:      EmisionNormal,
+	}, comprobantes...)
+	if err != nil {
+		t.Fatalf("ConstruirLote() error: %v", err)
+	}
+	return lote, claves
+}
+
+func TestConstruirLote(t *testing.T) {
+	lote, claves := lotePrueba(t, 100, 101)
+
+	if err := ValidarClaveAcceso(lote.ClaveAcceso); err != nil {
+		t.Errorf("Clave de lote inválida: %v", err)
+	}
+	if got := lote.Claves(); len(got) != 2 || got[0] != claves[0] || got[1] != claves[1] {
+		t.Errorf("Claves() = %v, esperado %v", got, claves)
+	}
+
+	xmlLote, err := lote.GenerarXML()
+	if err != nil {
+		t.Fatalf("GenerarXML() error: %v", err)
+	}
+	if !strings.Contains(string(xmlLote), "<![CDATA[") {
+		t.Error("Los comprobantes deberían embeberse como CDATA")
+	}
+
+	var leido loteEntrante
+	if err := xml.Unmarshal(xmlLote, &leido); err != nil {
+		t.Fatalf("El lote generado no es XML válido: %v", err)
+	}
+	if leido.ClaveAcceso != lote.ClaveAcceso || len(leido.Comprobantes) != 2 {
+		t.Errorf("Lote leído inesperado: %+v", leido)
+	}
+
+	t.Run("Rechaza duplicados", func(t *testing.T) {
+		_, xmlData := comprobanteSimulado(t, 100)
+		if err := lote.Agregar(xmlData); err == nil {
+			t.Error("Agregar() debería rechazar una clave repetida")
+		}
+	})
+
+	t.Run("Rechaza otro RUC", func(t *testing.T) {
+		_, xmlData := comprobanteSimulado(t, 102)
+		otro := &LoteMasivo{RUC: "0999999999001"}
+		if err := otro.Agregar(xmlData); err == nil {
+			t.Error("Agregar() debería rechazar comprobantes de otro emisor")
+		}
+	})
+
+	t.Run("Rechaza lote vacío", func(t *testing.T) {
+		if _, err := (&LoteMasivo{}).GenerarXML(); err == nil {
+			t.Error("GenerarXML() debería fallar sin comprobantes")
+		}
+	})
+}
+
+func TestProcesarLoteResultadosPorDocumento(t *testing.T) {
+	simulador, cliente := iniciarSimuladorPrueba(t)
+	lote, claves := lotePrueba(t, 110, 111, 112, 113)
+
+	simulador.ProgramarEscenario(claves[1], EscenarioDevuelta("45", "SECUENCIAL REGISTRADO"))
+	simulador.ProgramarEscenario(claves[2], EscenarioNoAutorizado("56", "ESTABLECIMIENTO CERRADO"))
+	simulador.ProgramarEscenario(claves[3], EscenarioEnProceso(2))
+
+	resultado, err := cliente.ProcesarLote(lote, configReintentoLotePrueba)
+	if err != nil {
+		t.Fatalf("ProcesarLote() error: %v", err)
+	}
+	if resultado.Estado != EstadoSRIDevuelta {
+		t.Errorf("Estado general = %s, esperado DEVUELTA por el comprobante rechazado", resultado.Estado)
+	}
+
+	esperados := []string{EstadoSRIAutorizado, EstadoSRIDevuelta, EstadoSRINoAutorizado, EstadoSRIAutorizado}
+	for i, clave := range claves {
+		documento, ok := resultado.Documento(clave)
+		if !ok {
+			t.Fatalf("Falta el resultado de %s", clave)
+		}
+		if documento.Estado != esperados[i] {
+			t.Errorf("Documento %d estado = %s, esperado %s", i, documento.Estado, esperados[i])
+		}
+	}
+
+	devuelto, _ := resultado.Documento(claves[1])
+	if len(devuelto.Mensajes) != 1 || devuelto.Mensajes[0].Identificador != "45" {
+		t.Errorf("Mensajes del devuelto inesperados: %+v", devuelto.Mensajes)
+	}
+	autorizado, _ := resultado.Documento(claves[0])
+	if autorizado.Autorizacion == nil || autorizado.Autorizacion.NumeroAutorizacion != claves[0] {
+		t.Errorf("Autorización inesperada: %+v", autorizado.Autorizacion)
+	}
+
+	resumen := resultado.Resumen()
+	if resumen[EstadoSRIAutorizado] != 2 || resumen[EstadoSRIDevuelta] != 1 || resumen[EstadoSRINoAutorizado] != 1 {
+		t.Errorf("Resumen inesperado: %v", resumen)
+	}
+}
+
+func TestEnviarLoteClaveLoteInvalida(t *testing.T) {
+	_, cliente := iniciarSimuladorPrueba(t)
+	lote, _ := lotePrueba(t, 120, 121)
+	lote.ClaveAcceso = lote.ClaveAcceso[:48] + "x"
+
+	resultado, err := cliente.EnviarLote(lote)
+	if err != nil {
+		t.Fatalf("EnviarLote() error: %v", err)
+	}
+	if len(resultado.Mensajes) != 1 || resultado.Mensajes[0].Identificador != "35" {
+		t.Errorf("Se esperaba error 35 a nivel de lote: %+v", resultado.Mensajes)
+	}
+	for _, documento := range resultado.Documentos {
+		if documento.Estado != EstadoSRIDevuelta {
+			t.Errorf("Documento %s estado = %s, esperado DEVUELTA", documento.ClaveAcceso, documento.Estado)
+		}
+	}
+}
+
+func TestReintentarEnvioLoteCircuitBreaker(t *testing.T) {
+	simulador, cliente := iniciarSimuladorPrueba(t)
+	lote, claves := lotePrueba(t, 130)
+	simulador.ProgramarEscenario(claves[0], EscenarioSRI{
+		Recepcion: []RespuestaSimulada{{CodigoHTTP: http.StatusServiceUnavailable}},
+	})
+
+	// Los errores HTTP genéricos no se clasifican como recuperables
+	config := configReintentoLotePrueba
+	config.SoloRecuperables = false
+
+	resultado, reintento := cliente.ReintentarEnvioLote(lote, config)
+	if reintento.Exitoso || resultado != nil {
+		t.Fatalf("Se esperaba fallo tras reintentos: %+v", reintento)
+	}
+	if reintento.IntentosRealizados != config.MaxIntentos {
+		t.Errorf("Intentos = %d, esperados %d", reintento.IntentosRealizados, config.MaxIntentos)
+	}
+
+	estadisticas := cliente.circuitBreaker.ObtenerEstadisticas()
+	if estadisticas.PeticionesFallidas != int64(config.MaxIntentos) {
+		t.Errorf("El circuit breaker registró %d fallos, esperados %d",
+			estadisticas.PeticionesFallidas, config.MaxIntentos)
+	}
+}
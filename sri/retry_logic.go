@@ -2,6 +2,7 @@
 package sri
 
 import (
+	"context"
 	"fmt"
 	"math"
 	"strings"
@@ -55,6 +56,7 @@ type ResultadoReintento struct {
 	TiempoTotal     time.Duration `json:"tiempo_total"`
 	UltimoError     error         `json:"ultimo_error"`
 	Errores         []error       `json:"errores"`
+	Intentos        []IntentoFallido `json:"intentos"` // Error clasificado de cada intento fallido
 }
 
 // String implementa la interfaz Stringer para ResultadoReintento
@@ -75,8 +77,19 @@ func (r *ResultadoReintento) String() string {
 // FuncionReintentable función que puede ser reintentada
 type FuncionReintentable func() error
 
+// FuncionReintentableContexto función cancelable que puede ser reintentada
+type FuncionReintentableContexto func(ctx context.Context) error
+
 // EjecutarConReintento ejecuta una función con lógica de reintentos
 func EjecutarConReintento(fn FuncionReintentable, config ConfigReintento) *ResultadoReintento {
+	return EjecutarConReintentoContexto(context.Background(), func(ctx context.Context) error {
+		return fn()
+	}, config)
+}
+
+// EjecutarConReintentoContexto ejecuta una función con lógica de reintentos.
+// La cancelación del contexto interrumpe la espera entre intentos.
+func EjecutarConReintentoContexto(ctx context.Context, fn FuncionReintentableContexto, config ConfigReintento) *ResultadoReintento {
 	inicio := time.Now()
 	resultado := &ResultadoReintento{
 		Exitoso:            false,
@@ -85,11 +98,18 @@ func EjecutarConReintento(fn FuncionReintentable, config ConfigReintento) *Resul
 	}
 
 	for intento := 1; intento <= config.MaxIntentos; intento++ {
+		if err := ctx.Err(); err != nil {
+			resultado.UltimoError = err
+			resultado.Errores = append(resultado.Errores, err)
+			fmt.Printf("🛑 Operación cancelada: %v\n", err)
+			break
+		}
+
 		resultado.IntentosRealizados = intento
 		
 		fmt.Printf("🔄 Intento %d/%d...\n", intento, config.MaxIntentos)
 		
-		err := fn()
+		err := fn(ctx)
 		if err == nil {
 			// Éxito!
 			resultado.Exitoso = true
@@ -101,9 +121,16 @@ func EjecutarConReintento(fn FuncionReintentable, config ConfigReintento) *Resul
 		// Registrar error
 		resultado.UltimoError = err
 		resultado.Errores = append(resultado.Errores, err)
+		resultado.Intentos = append(resultado.Intentos, NuevoIntentoFallido(intento, err))
 		
 		// Mostrar error
 		fmt.Printf("❌ Error en intento %d: %v\n", intento, err)
+
+		// Un contexto cancelado no se reintenta
+		if ctx.Err() != nil {
+			fmt.Printf("🛑 Operación cancelada: %v\n", ctx.Err())
+			break
+		}
 		
 		// Verificar si el error es recuperable
 		if config.SoloRecuperables && !EsErrorRecuperable(err) {
@@ -115,7 +142,12 @@ func EjecutarConReintento(fn FuncionReintentable, config ConfigReintento) *Resul
 		if intento < config.MaxIntentos {
 			tiempoEspera := calcularTiempoEspera(intento, config)
 			fmt.Printf("⏳ Esperando %v antes del siguiente intento...\n", tiempoEspera)
-			time.Sleep(tiempoEspera)
+			if err := esperarContexto(ctx, tiempoEspera); err != nil {
+				resultado.UltimoError = err
+				resultado.Errores = append(resultado.Errores, err)
+				fmt.Printf("🛑 Espera cancelada: %v\n", err)
+				break
+			}
 		}
 	}
 
@@ -125,6 +157,25 @@ func EjecutarConReintento(fn FuncionReintentable, config ConfigReintento) *Resul
 	return resultado
 }
 
+// esperarContexto duerme la duración indicada o hasta que el contexto se cancele
+func esperarContexto(ctx context.Context, duracion time.Duration) error {
+	timer := time.NewTimer(duracion)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// CalcularTiempoEspera expone el backoff exponencial para procesos que programan
+// sus propios reintentos (por ejemplo la cola persistente de autorización)
+func CalcularTiempoEspera(intento int, config ConfigReintento) time.Duration {
+	return calcularTiempoEspera(intento, config)
+}
+
 // calcularTiempoEspera calcula el tiempo de espera con backoff exponencial
 func calcularTiempoEspera(intento int, config ConfigReintento) time.Duration {
 	// Backoff exponencial: tiempo_base * multiplicador^(intento-1)
@@ -145,11 +196,16 @@ func calcularTiempoEspera(intento int, config ConfigReintento) time.Duration {
 
 // ReintentarEnvioSRI envía comprobante al SRI con reintentos
 func (c *SOAPClient) ReintentarEnvioSRI(xmlComprobante []byte, config ConfigReintento) (*RespuestaSolicitud, *ResultadoReintento) {
+	return c.ReintentarEnvioSRIContexto(context.Background(), xmlComprobante, config)
+}
+
+// ReintentarEnvioSRIContexto envía comprobante al SRI con reintentos cancelables
+func (c *SOAPClient) ReintentarEnvioSRIContexto(ctx context.Context, xmlComprobante []byte, config ConfigReintento) (*RespuestaSolicitud, *ResultadoReintento) {
 	var respuesta *RespuestaSolicitud
 	var resultadoReintento *ResultadoReintento
 
-	fn := func() error {
-		resp, err := c.EnviarComprobante(xmlComprobante)
+	fn := func(ctx context.Context) error {
+		resp, err := c.EnviarComprobanteContexto(ctx, xmlComprobante)
 		if err != nil {
 			return err
 		}
@@ -157,18 +213,23 @@ func (c *SOAPClient) ReintentarEnvioSRI(xmlComprobante []byte, config ConfigRein
 		return nil
 	}
 
-	resultadoReintento = EjecutarConReintento(fn, config)
+	resultadoReintento = EjecutarConReintentoContexto(ctx, fn, config)
 	
 	return respuesta, resultadoReintento
 }
 
 // ReintentarConsultaAutorizacion consulta autorización con reintentos
 func (c *SOAPClient) ReintentarConsultaAutorizacion(claveAcceso string, config ConfigReintento) (*RespuestaComprobante, *ResultadoReintento) {
+	return c.ReintentarConsultaAutorizacionContexto(context.Background(), claveAcceso, config)
+}
+
+// ReintentarConsultaAutorizacionContexto consulta autorización con reintentos cancelables
+func (c *SOAPClient) ReintentarConsultaAutorizacionContexto(ctx context.Context, claveAcceso string, config ConfigReintento) (*RespuestaComprobante, *ResultadoReintento) {
 	var respuesta *RespuestaComprobante
 	var resultadoReintento *ResultadoReintento
 
-	fn := func() error {
-		resp, err := c.ConsultarAutorizacion(claveAcceso)
+	fn := func(ctx context.Context) error {
+		resp, err := c.ConsultarAutorizacionContexto(ctx, claveAcceso)
 		if err != nil {
 			return err
 		}
@@ -188,23 +249,31 @@ func (c *SOAPClient) ReintentarConsultaAutorizacion(claveAcceso string, config C
 		return CrearErrorConexion("Comprobante aún en procesamiento")
 	}
 
-	resultadoReintento = EjecutarConReintento(fn, config)
+	resultadoReintento = EjecutarConReintentoContexto(ctx, fn, config)
 	
 	return respuesta, resultadoReintento
 }
 
 // ProcesarComprobanteCompletoConReintento procesa comprobante completo con reintentos avanzados
 func (c *SOAPClient) ProcesarComprobanteCompletoConReintento(xmlComprobante []byte, claveAcceso string) (*AutorizacionSRI, error) {
+	return c.ProcesarComprobanteCompletoConReintentoContexto(context.Background(), xmlComprobante, claveAcceso)
+}
+
+// ProcesarComprobanteCompletoConReintentoContexto procesa comprobante completo con reintentos cancelables
+func (c *SOAPClient) ProcesarComprobanteCompletoConReintentoContexto(ctx context.Context, xmlComprobante []byte, claveAcceso string) (*AutorizacionSRI, error) {
 	fmt.Println("🚀 Iniciando procesamiento completo con reintentos avanzados...")
 	
 	// Paso 1: Enviar comprobante con reintentos
 	fmt.Println("\n📤 PASO 1: Enviando comprobante al SRI")
 	fmt.Println(strings.Repeat("-", 50))
 	
-	respRecepcion, resultadoEnvio := c.ReintentarEnvioSRI(xmlComprobante, ConfigReintentoDefault)
+	respRecepcion, resultadoEnvio := c.ReintentarEnvioSRIContexto(ctx, xmlComprobante, ConfigReintentoDefault)
 	if !resultadoEnvio.Exitoso {
-		return nil, fmt.Errorf("error enviando comprobante después de %d intentos: %v", 
-			resultadoEnvio.IntentosRealizados, resultadoEnvio.UltimoError)
+		return nil, c.registrarFallido(&ErrorReintentosAgotados{
+			Operacion:   OperacionRecepcion,
+			ClaveAcceso: claveAcceso,
+			Resultado:   resultadoEnvio,
+		}, xmlComprobante)
 	}
 	
 	fmt.Printf("✅ Comprobante enviado exitosamente en %d intentos (tiempo: %v)\n", 
@@ -220,7 +289,9 @@ func (c *SOAPClient) ProcesarComprobanteCompletoConReintento(xmlComprobante []by
 	fmt.Println(strings.Repeat("-", 50))
 	tiempoEsperaInicial := 5 * time.Second
 	fmt.Printf("Esperando %v para permitir procesamiento...\n", tiempoEsperaInicial)
-	time.Sleep(tiempoEsperaInicial)
+	if err := esperarContexto(ctx, tiempoEsperaInicial); err != nil {
+		return nil, err
+	}
 
 	// Paso 3: Consultar autorización con reintentos
 	fmt.Println("\n🔍 PASO 3: Consultando autorización")
@@ -236,10 +307,13 @@ func (c *SOAPClient) ProcesarComprobanteCompletoConReintento(xmlComprobante []by
 		SoloRecuperables: true,
 	}
 	
-	respAutorizacion, resultadoConsulta := c.ReintentarConsultaAutorizacion(claveAcceso, configConsulta)
+	respAutorizacion, resultadoConsulta := c.ReintentarConsultaAutorizacionContexto(ctx, claveAcceso, configConsulta)
 	if !resultadoConsulta.Exitoso {
-		return nil, fmt.Errorf("error consultando autorización después de %d intentos: %v", 
-			resultadoConsulta.IntentosRealizados, resultadoConsulta.UltimoError)
+		return nil, c.registrarFallido(&ErrorReintentosAgotados{
+			Operacion:   OperacionAutorizacion,
+			ClaveAcceso: claveAcceso,
+			Resultado:   resultadoConsulta,
+		}, xmlComprobante)
 	}
 	
 	fmt.Printf("✅ Autorización obtenida exitosamente en %d intentos (tiempo: %v)\n", 
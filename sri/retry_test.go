@@ -1,6 +1,7 @@
 package sri
 
 import (
+	"context"
 	"errors"
 	"testing"
 	"time"
@@ -288,7 +289,7 @@ func TestEsErrorRecuperable(t *testing.T) {
 		},
 		{
 			name:        "Access key error (not recoverable)",
-			err:         ParsearErrorSRI("CLAVE-01: duplicate", 400),
+			err:         ParsearErrorSRI("43: CLAVE ACCESO REGISTRADA", 400),
 			recuperable: false,
 		},
 		{
@@ -385,6 +386,55 @@ func TestReintentoConTimeout(t *testing.T) {
 	}
 }
 
+// TestEjecutarConReintentoContextoCancelaEspera verifica que la cancelación interrumpa el backoff
+func TestEjecutarConReintentoContextoCancelaEspera(t *testing.T) {
+	config := ConfigReintento{
+		MaxIntentos:      5,
+		TiempoBase:       10 * time.Second,
+		Multiplicador:    2.0,
+		TiempoMaximo:     30 * time.Second,
+		SoloRecuperables: false,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	llamadas := 0
+	inicio := time.Now()
+	resultado := EjecutarConReintentoContexto(ctx, func(ctx context.Context) error {
+		llamadas++
+		return errors.New("fallo temporal")
+	}, config)
+
+	if time.Since(inicio) > time.Second {
+		t.Errorf("La espera no se interrumpió: %v", time.Since(inicio))
+	}
+	if resultado.Exitoso || llamadas != 1 {
+		t.Errorf("Resultado inesperado: exitoso=%v llamadas=%d", resultado.Exitoso, llamadas)
+	}
+	if !errors.Is(resultado.UltimoError, context.DeadlineExceeded) {
+		t.Errorf("UltimoError = %v, esperado context.DeadlineExceeded", resultado.UltimoError)
+	}
+}
+
+// TestEjecutarConReintentoContextoCancelado verifica que no se ejecute con un contexto ya cancelado
+func TestEjecutarConReintentoContextoCancelado(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	resultado := EjecutarConReintentoContexto(ctx, func(ctx context.Context) error {
+		t.Error("La función no debería ejecutarse")
+		return nil
+	}, ConfigReintentoDefault)
+
+	if resultado.Exitoso || resultado.IntentosRealizados != 0 {
+		t.Errorf("Resultado inesperado: %v", resultado)
+	}
+	if !errors.Is(resultado.UltimoError, context.Canceled) {
+		t.Errorf("UltimoError = %v, esperado context.Canceled", resultado.UltimoError)
+	}
+}
+
 // BenchmarkEjecutarConReintento benchmarks retry execution
 func BenchmarkEjecutarConReintento(b *testing.B) {
 	config := ConfigReintento{
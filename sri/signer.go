@@ -0,0 +1,265 @@
+// Package sri implementa firmadores intercambiables para XAdES-BES (local o remoto)
+package sri
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"go-facturacion-sri/config"
+	"go-facturacion-sri/secrets"
+)
+
+// Signer abstrae la operación de firma para que la clave privada pueda vivir
+// fuera del servidor (servicio centralizado de firma, HSM, etc.)
+type Signer interface {
+	// FirmarDigest firma un digest ya calculado con el algoritmo hash indicado (RSA PKCS#1 v1.5)
+	FirmarDigest(digest []byte, hash crypto.Hash) ([]byte, error)
+	// CadenaCertificados retorna el certificado firmante primero, seguido de los de la CA
+	CadenaCertificados() ([]*x509.Certificate, error)
+}
+
+// SignerPKCS12 firma localmente con la clave extraída de un archivo .p12
+type SignerPKCS12 struct {
+	clave  *rsa.PrivateKey
+	cadena []*x509.Certificate
+}
+
+// NuevoSignerPKCS12 crea un firmador local a partir de un certificado cargado
+func NuevoSignerPKCS12(certificado *CertificadoDigital) (*SignerPKCS12, error) {
+	if certificado == nil {
+		return nil, fmt.Errorf("certificado requerido para firma XAdES-BES")
+	}
+
+	rsaKey, ok := certificado.PrivateKey.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("se requiere clave privada RSA")
+	}
+
+	if certificado.Cert == nil {
+		return nil, fmt.Errorf("certificado X.509 requerido para firma XAdES-BES")
+	}
+
+	cadena := append([]*x509.Certificate{certificado.Cert}, certificado.CACerts...)
+
+	return &SignerPKCS12{
+		clave:  rsaKey,
+		cadena: cadena,
+	}, nil
+}
+
+// FirmarDigest implementa Signer
+func (s *SignerPKCS12) FirmarDigest(digest []byte, hash crypto.Hash) ([]byte, error) {
+	return rsa.SignPKCS1v15(rand.Reader, s.clave, hash, digest)
+}
+
+// CadenaCertificados implementa Signer
+func (s *SignerPKCS12) CadenaCertificados() ([]*x509.Certificate, error) {
+	return s.cadena, nil
+}
+
+// SolicitudFirmaRemota cuerpo enviado al servicio de firma remoto
+type SolicitudFirmaRemota struct {
+	KeyID     string `json:"keyId"`
+	Algoritmo string `json:"algoritmo"` // SHA1, SHA256
+	Digest    string `json:"digest"`    // base64
+}
+
+// RespuestaFirmaRemota respuesta del servicio de firma remoto
+type RespuestaFirmaRemota struct {
+	Firma string `json:"firma"` // base64
+	Error string `json:"error,omitempty"`
+}
+
+// RespuestaCertificadosRemota cadena de certificados publicada por el servicio remoto
+type RespuestaCertificadosRemota struct {
+	Certificados []string `json:"certificados"` // DER en base64, firmante primero
+	Error        string   `json:"error,omitempty"`
+}
+
+// SignerRemoto delega la firma a un servicio HTTP externo
+//
+// Protocolo:
+//
+//	POST {URL}/firmar                     SolicitudFirmaRemota -> RespuestaFirmaRemota
+//	GET  {URL}/certificados?keyId={KeyID} -> RespuestaCertificadosRemota
+type SignerRemoto struct {
+	URL        string
+	KeyID      string
+	token      string
+	httpClient *http.Client
+
+	mu     sync.Mutex
+	cadena []*x509.Certificate
+}
+
+// NuevoSignerRemoto crea un firmador remoto. El token se envía como Bearer.
+func NuevoSignerRemoto(url, keyID, token string, timeout time.Duration) *SignerRemoto {
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+
+	return &SignerRemoto{
+		URL:        strings.TrimRight(url, "/"),
+		KeyID:      keyID,
+		token:      token,
+		httpClient: &http.Client{Timeout: timeout},
+	}
+}
+
+// FirmarDigest implementa Signer enviando el digest al servicio remoto
+func (s *SignerRemoto) FirmarDigest(digest []byte, hash crypto.Hash) ([]byte, error) {
+	algoritmo, err := nombreAlgoritmoHash(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	solicitud := SolicitudFirmaRemota{
+		KeyID:     s.KeyID,
+		Algoritmo: algoritmo,
+		Digest:    base64.StdEncoding.EncodeToString(digest),
+	}
+
+	cuerpo, err := json.Marshal(solicitud)
+	if err != nil {
+		return nil, fmt.Errorf("error serializando solicitud de firma: %v", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.URL+"/firmar", bytes.NewReader(cuerpo))
+	if err != nil {
+		return nil, fmt.Errorf("error creando petición de firma: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	var respuesta RespuestaFirmaRemota
+	if err := s.ejecutar(req, &respuesta); err != nil {
+		return nil, err
+	}
+	if respuesta.Error != "" {
+		return nil, fmt.Errorf("servicio de firma respondió con error: %s", respuesta.Error)
+	}
+
+	firma, err := base64.StdEncoding.DecodeString(respuesta.Firma)
+	if err != nil {
+		return nil, fmt.Errorf("firma remota inválida: %v", err)
+	}
+
+	return firma, nil
+}
+
+// CadenaCertificados implementa Signer consultando (y cacheando) la cadena remota
+func (s *SignerRemoto) CadenaCertificados() ([]*x509.Certificate, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cadena != nil {
+		return s.cadena, nil
+	}
+
+	req, err := http.NewRequest(http.MethodGet, s.URL+"/certificados?"+url.Values{"keyId": {s.KeyID}}.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creando petición de certificados: %v", err)
+	}
+
+	var respuesta RespuestaCertificadosRemota
+	if err := s.ejecutar(req, &respuesta); err != nil {
+		return nil, err
+	}
+	if respuesta.Error != "" {
+		return nil, fmt.Errorf("servicio de firma respondió con error: %s", respuesta.Error)
+	}
+	if len(respuesta.Certificados) == 0 {
+		return nil, fmt.Errorf("servicio de firma no retornó certificados")
+	}
+
+	cadena := make([]*x509.Certificate, 0, len(respuesta.Certificados))
+	for i, certB64 := range respuesta.Certificados {
+		der, err := base64.StdEncoding.DecodeString(certB64)
+		if err != nil {
+			return nil, fmt.Errorf("certificado %d inválido: %v", i+1, err)
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, fmt.Errorf("error parseando certificado %d: %v", i+1, err)
+		}
+		cadena = append(cadena, cert)
+	}
+
+	s.cadena = cadena
+	return cadena, nil
+}
+
+// ejecutar envía la petición autenticada y decodifica la respuesta JSON
+func (s *SignerRemoto) ejecutar(req *http.Request, destino interface{}) error {
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error contactando servicio de firma: %v", err)
+	}
+	defer resp.Body.Close()
+
+	cuerpo, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("error leyendo respuesta del servicio de firma: %v", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("servicio de firma respondió con código %d: %s", resp.StatusCode, string(cuerpo))
+	}
+
+	if err := json.Unmarshal(cuerpo, destino); err != nil {
+		return fmt.Errorf("respuesta del servicio de firma mal formada: %v", err)
+	}
+
+	return nil
+}
+
+// nombreAlgoritmoHash traduce crypto.Hash al nombre usado por el protocolo remoto
+func nombreAlgoritmoHash(hash crypto.Hash) (string, error) {
+	switch hash {
+	case crypto.SHA1:
+		return "SHA1", nil
+	case crypto.SHA256:
+		return "SHA256", nil
+	default:
+		return "", fmt.Errorf("algoritmo hash no soportado para firma remota: %v", hash)
+	}
+}
+
+// NuevoSignerDesdeConfig crea el firmador configurado: remoto si hay URL de
+// firma remota, o local cargando el .p12 con la contraseña resuelta vía secretos
+func NuevoSignerDesdeConfig(cfg config.CertificadoConfig, resolvedor *secrets.Resolvedor) (Signer, error) {
+	if cfg.FirmaRemota.URL != "" {
+		token, err := resolvedor.Resolver(cfg.FirmaRemota.Token)
+		if err != nil {
+			return nil, fmt.Errorf("error resolviendo token de firma remota: %v", err)
+		}
+		timeout := time.Duration(cfg.FirmaRemota.TimeoutSegundos) * time.Second
+		return NuevoSignerRemoto(cfg.FirmaRemota.URL, cfg.FirmaRemota.KeyID, string(token), timeout), nil
+	}
+
+	certificado, err := CargarCertificadoConSecretos(CertificadoConfig{
+		RutaArchivo:     cfg.RutaArchivo,
+		Password:        cfg.Password,
+		ValidarVigencia: true,
+	}, resolvedor)
+	if err != nil {
+		return nil, err
+	}
+
+	return NuevoSignerPKCS12(certificado)
+}
@@ -0,0 +1,254 @@
+package sri
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"go-facturacion-sri/config"
+	"go-facturacion-sri/secrets"
+)
+
+// servidorFirmaPrueba levanta un servicio de firma remoto de prueba que usa el .p12 generado
+type servidorFirmaPrueba struct {
+	*httptest.Server
+	certificado *CertificadoDigital
+
+	mu            sync.Mutex
+	digests       [][]byte
+	consultasCert int
+	keyIDsCert    []string // keyId recibido en cada consulta de certificados
+}
+
+func nuevoServidorFirmaPrueba(t *testing.T, token string) *servidorFirmaPrueba {
+	t.Helper()
+
+	certificado, err := CargarCertificado(CertificadoConfig{
+		RutaArchivo: generarP12Prueba(t, "clave-remota"),
+		Password:    "clave-remota",
+	})
+	if err != nil {
+		t.Fatalf("Error cargando certificado de prueba: %v", err)
+	}
+
+	s := &servidorFirmaPrueba{certificado: certificado}
+	clave := certificado.PrivateKey.(*rsa.PrivateKey)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/firmar", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			http.Error(w, "no autorizado", http.StatusUnauthorized)
+			return
+		}
+
+		var solicitud SolicitudFirmaRemota
+		if err := json.NewDecoder(r.Body).Decode(&solicitud); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if solicitud.KeyID != "emisor-1" || solicitud.Algoritmo != "SHA256" {
+			json.NewEncoder(w).Encode(RespuestaFirmaRemota{Error: "clave o algoritmo desconocido"})
+			return
+		}
+
+		digest, _ := base64.StdEncoding.DecodeString(solicitud.Digest)
+		s.mu.Lock()
+		s.digests = append(s.digests, digest)
+		s.mu.Unlock()
+
+		firma, err := rsa.SignPKCS1v15(rand.Reader, clave, crypto.SHA256, digest)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(RespuestaFirmaRemota{Firma: base64.StdEncoding.EncodeToString(firma)})
+	})
+	mux.HandleFunc("/certificados", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			http.Error(w, "no autorizado", http.StatusUnauthorized)
+			return
+		}
+
+		s.mu.Lock()
+		s.consultasCert++
+		s.keyIDsCert = append(s.keyIDsCert, r.URL.Query().Get("keyId"))
+		s.mu.Unlock()
+
+		json.NewEncoder(w).Encode(RespuestaCertificadosRemota{
+			Certificados: []string{base64.StdEncoding.EncodeToString(certificado.Cert.Raw)},
+		})
+	})
+
+	s.Server = httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+	return s
+}
+
+// TestSignerPKCS12 verifica la firma local con el certificado cargado
+func TestSignerPKCS12(t *testing.T) {
+	certificado, err := CargarCertificado(CertificadoConfig{
+		RutaArchivo: generarP12Prueba(t, "clave-local"),
+		Password:    "clave-local",
+	})
+	if err != nil {
+		t.Fatalf("CargarCertificado() error: %v", err)
+	}
+
+	signer, err := NuevoSignerPKCS12(certificado)
+	if err != nil {
+		t.Fatalf("NuevoSignerPKCS12() error: %v", err)
+	}
+
+	digest := sha256.Sum256([]byte("contenido"))
+	firma, err := signer.FirmarDigest(digest[:], crypto.SHA256)
+	if err != nil {
+		t.Fatalf("FirmarDigest() error: %v", err)
+	}
+
+	publica := certificado.Cert.PublicKey.(*rsa.PublicKey)
+	if err := rsa.VerifyPKCS1v15(publica, crypto.SHA256, digest[:], firma); err != nil {
+		t.Errorf("La firma local no es verificable: %v", err)
+	}
+
+	cadena, err := signer.CadenaCertificados()
+	if err != nil || len(cadena) != 1 || cadena[0] != certificado.Cert {
+		t.Errorf("CadenaCertificados() = %v, %v", cadena, err)
+	}
+
+	if _, err := NuevoSignerPKCS12(&CertificadoDigital{PrivateKey: "no-rsa"}); err == nil {
+		t.Error("NuevoSignerPKCS12() debería rechazar claves no RSA")
+	}
+}
+
+// TestFirmarXMLConSignerRemoto firma un documento delegando en el servicio remoto de prueba
+func TestFirmarXMLConSignerRemoto(t *testing.T) {
+	servidor := nuevoServidorFirmaPrueba(t, "token-prueba")
+	signer := NuevoSignerRemoto(servidor.URL+"/", "emisor-1", "token-prueba", 0)
+
+	xmlData := []byte(`<factura><infoTributaria><ruc>1792146739001</ruc></infoTributaria></factura>`)
+	firmado, err := FirmarXMLXAdESBES(xmlData, XAdESBESConfig{Signer: signer})
+	if err != nil {
+		t.Fatalf("FirmarXMLXAdESBES() error: %v", err)
+	}
+
+	if len(servidor.digests) != 1 {
+		t.Fatalf("El servicio remoto recibió %d digests, esperado 1", len(servidor.digests))
+	}
+
+	// La firma incrustada debe corresponder al digest enviado y a la clave del servicio
+	documento := string(firmado)
+	inicio := strings.Index(documento, "<ds:SignatureValue>") + len("<ds:SignatureValue>")
+	fin := strings.Index(documento, "</ds:SignatureValue>")
+	if inicio < len("<ds:SignatureValue>") || fin < inicio {
+		t.Fatal("El documento firmado no contiene SignatureValue")
+	}
+
+	firma, err := base64.StdEncoding.DecodeString(documento[inicio:fin])
+	if err != nil {
+		t.Fatalf("SignatureValue inválido: %v", err)
+	}
+
+	publica := servidor.certificado.Cert.PublicKey.(*rsa.PublicKey)
+	if err := rsa.VerifyPKCS1v15(publica, crypto.SHA256, servidor.digests[0], firma); err != nil {
+		t.Errorf("La firma remota no es verificable: %v", err)
+	}
+
+	certB64 := base64.StdEncoding.EncodeToString(servidor.certificado.Cert.Raw)
+	if !strings.Contains(documento, certB64) {
+		t.Error("El documento firmado no incluye el certificado del servicio remoto")
+	}
+
+	// La cadena se cachea entre firmas
+	if _, err := FirmarXMLXAdESBES(xmlData, XAdESBESConfig{Signer: signer}); err != nil {
+		t.Fatalf("Segunda firma error: %v", err)
+	}
+	if servidor.consultasCert != 1 {
+		t.Errorf("Consultas de certificados = %d, esperado 1", servidor.consultasCert)
+	}
+}
+
+// TestSignerRemotoErrores verifica el manejo de errores del servicio remoto
+func TestSignerRemotoErrores(t *testing.T) {
+	servidor := nuevoServidorFirmaPrueba(t, "token-prueba")
+	digest := sha256.Sum256([]byte("x"))
+
+	tests := []struct {
+		name   string
+		signer *SignerRemoto
+		hash   crypto.Hash
+	}{
+		{"Token inválido", NuevoSignerRemoto(servidor.URL, "emisor-1", "otro", 0), crypto.SHA256},
+		{"Clave desconocida", NuevoSignerRemoto(servidor.URL, "emisor-2", "token-prueba", 0), crypto.SHA256},
+		{"Algoritmo no soportado", NuevoSignerRemoto(servidor.URL, "emisor-1", "token-prueba", 0), crypto.SHA512},
+		{"Servicio caído", NuevoSignerRemoto("http://127.0.0.1:1", "emisor-1", "token-prueba", 0), crypto.SHA256},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.signer.FirmarDigest(digest[:], tt.hash); err == nil {
+				t.Error("FirmarDigest() esperaba error")
+			}
+		})
+	}
+
+	if _, err := NuevoSignerRemoto(servidor.URL, "emisor-1", "otro", 0).CadenaCertificados(); err == nil {
+		t.Error("CadenaCertificados() esperaba error con token inválido")
+	}
+}
+
+// TestSignerRemotoEscapaKeyID verifica que el keyId viaja completo en la consulta de certificados
+func TestSignerRemotoEscapaKeyID(t *testing.T) {
+	servidor := nuevoServidorFirmaPrueba(t, "token-prueba")
+	keyID := "emisor 1&keyId=otro#a+b"
+
+	if _, err := NuevoSignerRemoto(servidor.URL, keyID, "token-prueba", 0).CadenaCertificados(); err != nil {
+		t.Fatalf("CadenaCertificados() error: %v", err)
+	}
+	if len(servidor.keyIDsCert) != 1 || servidor.keyIDsCert[0] != keyID {
+		t.Errorf("keyId recibido = %q, esperado %q", servidor.keyIDsCert, keyID)
+	}
+}
+
+// TestNuevoSignerDesdeConfig verifica la selección entre firmador local y remoto
+func TestNuevoSignerDesdeConfig(t *testing.T) {
+	t.Setenv("TEST_TOKEN_FIRMA", "token-prueba")
+	servidor := nuevoServidorFirmaPrueba(t, "token-prueba")
+	resolvedor := secrets.NuevoResolvedor()
+
+	remoto, err := NuevoSignerDesdeConfig(config.CertificadoConfig{
+		FirmaRemota: config.FirmaRemotaConfig{
+			URL:   servidor.URL,
+			KeyID: "emisor-1",
+			Token: "env:TEST_TOKEN_FIRMA",
+		},
+	}, resolvedor)
+	if err != nil {
+		t.Fatalf("NuevoSignerDesdeConfig() remoto error: %v", err)
+	}
+	if _, ok := remoto.(*SignerRemoto); !ok {
+		t.Errorf("Se esperaba *SignerRemoto, obtenido %T", remoto)
+	}
+	if _, err := remoto.CadenaCertificados(); err != nil {
+		t.Errorf("CadenaCertificados() con token resuelto error: %v", err)
+	}
+
+	t.Setenv("TEST_PASSWORD_P12", "clave-local")
+	local, err := NuevoSignerDesdeConfig(config.CertificadoConfig{
+		RutaArchivo: generarP12Prueba(t, "clave-local"),
+		Password:    "env:TEST_PASSWORD_P12",
+	}, resolvedor)
+	if err != nil {
+		t.Fatalf("NuevoSignerDesdeConfig() local error: %v", err)
+	}
+	if _, ok := local.(*SignerPKCS12); !ok {
+		t.Errorf("Se esperaba *SignerPKCS12, obtenido %T", local)
+	}
+}
@@ -0,0 +1,633 @@
+// Package sri implementa un simulador de los servicios SOAP del SRI para desarrollo y tests
+package sri
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Rutas expuestas por el simulador (mismas que los servicios oficiales)
+const (
+	RutaRecepcionSimulador    = "/comprobantes-electronicos-ws/RecepcionComprobantesOffline"
+	RutaAutorizacionSimulador = "/comprobantes-electronicos-ws/AutorizacionComprobantesOffline"
+)
+
+// Estados que retorna el SRI en recepción y autorización
+const (
+	EstadoSRIRecibida     = "RECIBIDA"
+	EstadoSRIDevuelta     = "DEVUELTA"
+	EstadoSRIEnProceso    = "EN PROCESO"
+	EstadoSRIAutorizado   = "AUTORIZADO"
+	EstadoSRINoAutorizado = "NO AUTORIZADO"
+)
+
+// RespuestaSimulada resultado programado para una llamada al simulador
+type RespuestaSimulada struct {
+	Estado     string        // RECIBIDA, DEVUELTA, EN PROCESO, AUTORIZADO, NO AUTORIZADO
+	Mensajes   []MensajeSRI  // Mensajes a incluir en la respuesta
+	Demora     time.Duration // Espera antes de responder (simula lentitud o timeouts)
+	CodigoHTTP int           // Si es distinto de 0 y 200 responde solo con ese código
+}
+
+// EscenarioSRI secuencia de respuestas programadas para una clave de acceso.
+// Cada llamada consume un paso; el último se repite indefinidamente.
+type EscenarioSRI struct {
+	Recepcion    []RespuestaSimulada // vacío: validar y responder RECIBIDA
+	Autorizacion []RespuestaSimulada // vacío: AUTORIZADO en la primera consulta
+}
+
+// EscenarioDevuelta recepción DEVUELTA con el código de error indicado
+func EscenarioDevuelta(identificador, mensaje string) EscenarioSRI {
+	return EscenarioSRI{
+		Recepcion: []RespuestaSimulada{{
+			Estado:   EstadoSRIDevuelta,
+			Mensajes: []MensajeSRI{{Identificador: identificador, Mensaje: mensaje, Tipo: "ERROR"}},
+		}},
+	}
+}
+
+// EscenarioEnProceso responde EN PROCESO en las primeras consultas y luego AUTORIZADO
+func EscenarioEnProceso(consultas int) EscenarioSRI {
+	escenario := EscenarioSRI{}
+	for i := 0; i < consultas; i++ {
+		escenario.Autorizacion = append(escenario.Autorizacion, RespuestaSimulada{Estado: EstadoSRIEnProceso})
+	}
+	escenario.Autorizacion = append(escenario.Autorizacion, RespuestaSimulada{Estado: EstadoSRIAutorizado})
+	return escenario
+}
+
+// EscenarioNoAutorizado autorización rechazada con el código de error indicado
+func EscenarioNoAutorizado(identificador, mensaje string) EscenarioSRI {
+	return EscenarioSRI{
+		Autorizacion: []RespuestaSimulada{{
+			Estado:   EstadoSRINoAutorizado,
+			Mensajes: []MensajeSRI{{Identificador: identificador, Mensaje: mensaje, Tipo: "ERROR"}},
+		}},
+	}
+}
+
+// EscenarioTimeout la recepción tarda la demora indicada antes de responder
+func EscenarioTimeout(demora time.Duration) EscenarioSRI {
+	return EscenarioSRI{
+		Recepcion: []RespuestaSimulada{{Estado: EstadoSRIRecibida, Demora: demora}},
+	}
+}
+
+// ComprobanteSimulado estado que el simulador mantiene por clave de acceso
+type ComprobanteSimulado struct {
+	ClaveAcceso       string
+	XML               []byte
+	Estado            string
+	Recepciones       int
+	Consultas         int
+	FechaRecepcion    time.Time
+	FechaAutorizacion time.Time
+	Mensajes          []MensajeSRI
+}
+
+// SimuladorSRI implementa RecepcionComprobantesOffline y AutorizacionComprobantesOffline
+// en memoria. Puede ejecutarse en proceso (Iniciar) o como servidor independiente (Escuchar).
+type SimuladorSRI struct {
+	Ambiente      Ambiente
+	RequerirFirma bool // Devuelve error 39 si el comprobante no incluye ds:Signature
+
+	mu                sync.Mutex
+	comprobantes      map[string]*ComprobanteSimulado
+	escenarios        map[string]EscenarioSRI
+	escenarioDefecto  EscenarioSRI
+	pasosRecepcion    map[string]int
+	pasosAutorizacion map[string]int
+	lotes             map[string][]string // clave de lote -> claves de sus comprobantes
+
+	servidor *http.Server
+	URL      string // URL base cuando está iniciado en proceso
+}
+
+// NuevoSimuladorSRI crea un simulador vacío para el ambiente indicado
+func NuevoSimuladorSRI(ambiente Ambiente) *SimuladorSRI {
+	return &SimuladorSRI{
+		Ambiente:          ambiente,
+		comprobantes:      make(map[string]*ComprobanteSimulado),
+		escenarios:        make(map[string]EscenarioSRI),
+		pasosRecepcion:    make(map[string]int),
+		pasosAutorizacion: make(map[string]int),
+		lotes:             make(map[string][]string),
+	}
+}
+
+// ProgramarEscenario define las respuestas para una clave de acceso específica
+func (s *SimuladorSRI) ProgramarEscenario(claveAcceso string, escenario EscenarioSRI) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.escenarios[claveAcceso] = escenario
+	delete(s.pasosRecepcion, claveAcceso)
+	delete(s.pasosAutorizacion, claveAcceso)
+}
+
+// EscenarioPorDefecto define las respuestas para claves sin escenario propio
+func (s *SimuladorSRI) EscenarioPorDefecto(escenario EscenarioSRI) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.escenarioDefecto = escenario
+}
+
+// Comprobante retorna una copia del estado simulado de una clave de acceso
+func (s *SimuladorSRI) Comprobante(claveAcceso string) (ComprobanteSimulado, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	comprobante, ok := s.comprobantes[claveAcceso]
+	if !ok {
+		return ComprobanteSimulado{}, false
+	}
+	return *comprobante, true
+}
+
+// Reiniciar elimina todo el estado y los escenarios programados
+func (s *SimuladorSRI) Reiniciar() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.comprobantes = make(map[string]*ComprobanteSimulado)
+	s.escenarios = make(map[string]EscenarioSRI)
+	s.escenarioDefecto = EscenarioSRI{}
+	s.pasosRecepcion = make(map[string]int)
+	s.pasosAutorizacion = make(map[string]int)
+	s.lotes = make(map[string][]string)
+}
+
+// Iniciar levanta el simulador en un puerto local libre (uso en proceso)
+func (s *SimuladorSRI) Iniciar() error {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return fmt.Errorf("error iniciando simulador SRI: %v", err)
+	}
+
+	s.servidor = &http.Server{Handler: s}
+	s.URL = "http://" + listener.Addr().String()
+
+	go s.servidor.Serve(listener)
+	return nil
+}
+
+// Detener apaga el simulador iniciado con Iniciar
+func (s *SimuladorSRI) Detener() error {
+	if s.servidor == nil {
+		return nil
+	}
+	return s.servidor.Close()
+}
+
+// Escuchar ejecuta el simulador como servidor independiente (bloqueante)
+func (s *SimuladorSRI) Escuchar(direccion string) error {
+	s.servidor = &http.Server{Addr: direccion, Handler: s}
+	return s.servidor.ListenAndServe()
+}
+
+// EndpointRecepcion URL del servicio de recepción simulado
+func (s *SimuladorSRI) EndpointRecepcion() string {
+	return s.URL + RutaRecepcionSimulador
+}
+
+// EndpointAutorizacion URL del servicio de autorización simulado
+func (s *SimuladorSRI) EndpointAutorizacion() string {
+	return s.URL + RutaAutorizacionSimulador
+}
+
+// NuevoCliente crea un SOAPClient apuntando al simulador iniciado
+func (s *SimuladorSRI) NuevoCliente() *SOAPClient {
+	return NewSOAPClientConEndpoints(s.Ambiente, s.EndpointRecepcion(), s.EndpointAutorizacion())
+}
+
+// ServeHTTP atiende ambas operaciones SOAP
+func (s *SimuladorSRI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cuerpo, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "error leyendo solicitud", http.StatusBadRequest)
+		return
+	}
+
+	switch {
+	case strings.HasSuffix(r.URL.Path, "RecepcionComprobantesOffline") ||
+		bytes.Contains(cuerpo, []byte("validarComprobante")):
+		s.atenderRecepcion(w, r, cuerpo)
+	case strings.HasSuffix(r.URL.Path, "AutorizacionComprobantesOffline") ||
+		bytes.Contains(cuerpo, []byte("autorizacionComprobante")):
+		s.atenderAutorizacion(w, r, cuerpo)
+	default:
+		http.Error(w, "operación SOAP desconocida", http.StatusNotFound)
+	}
+}
+
+// envelopeRecepcionEntrante solicitud validarComprobante recibida
+type envelopeRecepcionEntrante struct {
+	XML string `xml:"Body>validarComprobante>xml"`
+}
+
+// envelopeAutorizacionEntrante solicitud autorizacionComprobante o autorizacionComprobanteLote recibida
+type envelopeAutorizacionEntrante struct {
+	ClaveAcceso     string `xml:"Body>autorizacionComprobante>claveAccesoComprobante"`
+	ClaveAccesoLote string `xml:"Body>autorizacionComprobanteLote>claveAccesoLote"`
+}
+
+// loteEntrante lote masivo recibido en validarComprobante
+type loteEntrante struct {
+	ClaveAcceso  string `xml:"claveAcceso"`
+	Comprobantes []struct {
+		Contenido string `xml:",chardata"`
+	} `xml:"comprobantes>comprobante"`
+}
+
+// atenderRecepcion implementa validarComprobante
+func (s *SimuladorSRI) atenderRecepcion(w http.ResponseWriter, r *http.Request, cuerpo []byte) {
+	var solicitud envelopeRecepcionEntrante
+	if err := xml.Unmarshal(cuerpo, &solicitud); err != nil {
+		http.Error(w, "envelope SOAP inválido", http.StatusBadRequest)
+		return
+	}
+
+	respuesta := s.procesarRecepcion(strings.TrimSpace(solicitud.XML))
+	if !esperarDemora(r, respuesta.demora) {
+		return
+	}
+	if respuesta.codigoHTTP != 0 && respuesta.codigoHTTP != http.StatusOK {
+		http.Error(w, http.StatusText(respuesta.codigoHTTP), respuesta.codigoHTTP)
+		return
+	}
+
+	escribirEnvelope(w, "validarComprobanteResponse", "http://ec.gob.sri.ws.recepcion", respuesta.recepcion)
+}
+
+// resultadoSimulacion respuesta calculada bajo el lock, escrita fuera de él
+type resultadoSimulacion struct {
+	recepcion        *RespuestaSolicitud
+	autorizacion     *RespuestaComprobante
+	autorizacionLote *RespuestaLote
+	demora           time.Duration
+	codigoHTTP       int
+}
+
+// procesarRecepcion valida el comprobante (o lote) y aplica el escenario programado
+func (s *SimuladorSRI) procesarRecepcion(xmlBase64 string) resultadoSimulacion {
+	xmlComprobante, err := base64.StdEncoding.DecodeString(xmlBase64)
+	if err != nil {
+		return resultadoSimulacion{recepcion: &RespuestaSolicitud{
+			Estado: EstadoSRIDevuelta,
+			Comprobantes: []ComprobanteRecepcion{{
+				Mensajes: []MensajeSRI{mensajeSimulado("35", "ARCHIVO NO CUMPLE ESTRUCTURA XML", "el contenido no está codificado en base64")},
+			}},
+		}}
+	}
+
+	if esLoteMasivo(xmlComprobante) {
+		return s.procesarRecepcionLote(xmlComprobante)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	recepcion, paso := s.registrarRecepcion(xmlComprobante)
+
+	return resultadoSimulacion{
+		recepcion: &RespuestaSolicitud{
+			Estado:       paso.Estado,
+			Comprobantes: []ComprobanteRecepcion{recepcion},
+		},
+		demora:     paso.Demora,
+		codigoHTTP: paso.CodigoHTTP,
+	}
+}
+
+// procesarRecepcionLote registra cada comprobante del lote; solo los devueltos se listan en la respuesta
+func (s *SimuladorSRI) procesarRecepcionLote(xmlLote []byte) resultadoSimulacion {
+	devolverLote := func(clave, adicional string) resultadoSimulacion {
+		return resultadoSimulacion{recepcion: &RespuestaSolicitud{
+			Estado: EstadoSRIDevuelta,
+			Comprobantes: []ComprobanteRecepcion{{
+				ClaveAcceso: clave,
+				Mensajes:    []MensajeSRI{mensajeSimulado("35", "ARCHIVO NO CUMPLE ESTRUCTURA XML", adicional)},
+			}},
+		}}
+	}
+
+	var lote loteEntrante
+	if err := xml.Unmarshal(xmlLote, &lote); err != nil {
+		return devolverLote("", fmt.Sprintf("lote mal formado: %v", err))
+	}
+	claveLote := strings.TrimSpace(lote.ClaveAcceso)
+	if err := ValidarClaveAcceso(claveLote); err != nil {
+		return devolverLote(claveLote, fmt.Sprintf("clave de acceso del lote inválida: %v", err))
+	}
+	if len(lote.Comprobantes) == 0 {
+		return devolverLote(claveLote, "el lote no contiene comprobantes")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	resultado := resultadoSimulacion{recepcion: &RespuestaSolicitud{Estado: EstadoSRIRecibida}}
+	var claves []string
+	for _, comprobante := range lote.Comprobantes {
+		recepcion, paso := s.registrarRecepcion([]byte(strings.TrimSpace(comprobante.Contenido)))
+		if recepcion.ClaveAcceso != "" {
+			claves = append(claves, recepcion.ClaveAcceso)
+		}
+		if paso.Estado != EstadoSRIRecibida {
+			resultado.recepcion.Estado = EstadoSRIDevuelta
+			resultado.recepcion.Comprobantes = append(resultado.recepcion.Comprobantes, recepcion)
+		}
+		if paso.Demora > resultado.demora {
+			resultado.demora = paso.Demora
+		}
+		if resultado.codigoHTTP == 0 {
+			resultado.codigoHTTP = paso.CodigoHTTP
+		}
+	}
+	s.lotes[claveLote] = claves
+
+	return resultado
+}
+
+// registrarRecepcion valida un comprobante individual y aplica su escenario (requiere lock)
+func (s *SimuladorSRI) registrarRecepcion(xmlComprobante []byte) (ComprobanteRecepcion, RespuestaSimulada) {
+	devolver := func(clave string, mensajes ...MensajeSRI) (ComprobanteRecepcion, RespuestaSimulada) {
+		return ComprobanteRecepcion{ClaveAcceso: clave, Mensajes: mensajes}, RespuestaSimulada{Estado: EstadoSRIDevuelta}
+	}
+
+	claveAcceso, err := validarEstructuraComprobante(xmlComprobante)
+	if err != nil {
+		return devolver(claveAcceso, mensajeSimulado("35", "ARCHIVO NO CUMPLE ESTRUCTURA XML", err.Error()))
+	}
+
+	if s.RequerirFirma && !bytes.Contains(xmlComprobante, []byte("Signature")) {
+		return devolver(claveAcceso, mensajeSimulado("39", "FIRMA INVALIDA", "el comprobante no contiene firma XAdES-BES"))
+	}
+
+	comprobante, existe := s.comprobantes[claveAcceso]
+	if existe {
+		switch comprobante.Estado {
+		case EstadoSRIAutorizado:
+			return devolver(claveAcceso, mensajeSimulado("43", "CLAVE ACCESO REGISTRADA", ""))
+		case EstadoSRIRecibida, EstadoSRIEnProceso:
+			return devolver(claveAcceso, mensajeSimulado("70", "CLAVE DE ACCESO EN PROCESAMIENTO", ""))
+		}
+	} else {
+		comprobante = &ComprobanteSimulado{ClaveAcceso: claveAcceso}
+		s.comprobantes[claveAcceso] = comprobante
+	}
+
+	comprobante.XML = xmlComprobante
+	comprobante.Recepciones++
+	comprobante.FechaRecepcion = time.Now()
+	comprobante.Consultas = 0
+	delete(s.pasosAutorizacion, claveAcceso)
+
+	paso := RespuestaSimulada{Estado: EstadoSRIRecibida}
+	if pasos := s.escenario(claveAcceso).Recepcion; len(pasos) > 0 {
+		paso = siguientePaso(pasos, s.pasosRecepcion, claveAcceso)
+	}
+
+	comprobante.Estado = paso.Estado
+	comprobante.Mensajes = paso.Mensajes
+
+	return ComprobanteRecepcion{ClaveAcceso: claveAcceso, Mensajes: paso.Mensajes}, paso
+}
+
+// atenderAutorizacion implementa autorizacionComprobante y autorizacionComprobanteLote
+func (s *SimuladorSRI) atenderAutorizacion(w http.ResponseWriter, r *http.Request, cuerpo []byte) {
+	var solicitud envelopeAutorizacionEntrante
+	if err := xml.Unmarshal(cuerpo, &solicitud); err != nil {
+		http.Error(w, "envelope SOAP inválido", http.StatusBadRequest)
+		return
+	}
+
+	operacion := "autorizacionComprobanteResponse"
+	var respuesta resultadoSimulacion
+	var contenido interface{}
+	if claveLote := strings.TrimSpace(solicitud.ClaveAccesoLote); claveLote != "" {
+		operacion = "autorizacionComprobanteLoteResponse"
+		respuesta = s.procesarAutorizacionLote(claveLote)
+		contenido = respuesta.autorizacionLote
+	} else {
+		respuesta = s.procesarAutorizacion(strings.TrimSpace(solicitud.ClaveAcceso))
+		contenido = respuesta.autorizacion
+	}
+
+	if !esperarDemora(r, respuesta.demora) {
+		return
+	}
+	if respuesta.codigoHTTP != 0 && respuesta.codigoHTTP != http.StatusOK {
+		http.Error(w, http.StatusText(respuesta.codigoHTTP), respuesta.codigoHTTP)
+		return
+	}
+
+	escribirEnvelope(w, operacion, "http://ec.gob.sri.ws.autorizacion", contenido)
+}
+
+// procesarAutorizacion avanza el escenario de autorización de la clave consultada
+func (s *SimuladorSRI) procesarAutorizacion(claveAcceso string) resultadoSimulacion {
+	respuesta := &RespuestaComprobante{
+		ClaveAccesoConsultada: claveAcceso,
+		NumeroComprobantes:    "0",
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	autorizacion, paso, ok := s.autorizarComprobante(claveAcceso)
+	if !ok {
+		return resultadoSimulacion{autorizacion: respuesta}
+	}
+
+	respuesta.NumeroComprobantes = "1"
+	respuesta.Autorizaciones = []AutorizacionSRI{autorizacion}
+
+	return resultadoSimulacion{
+		autorizacion: respuesta,
+		demora:       paso.Demora,
+		codigoHTTP:   paso.CodigoHTTP,
+	}
+}
+
+// procesarAutorizacionLote consulta todos los comprobantes recibidos en el lote
+func (s *SimuladorSRI) procesarAutorizacionLote(claveLote string) resultadoSimulacion {
+	respuesta := &RespuestaLote{ClaveAccesoLoteConsultada: claveLote}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	resultado := resultadoSimulacion{autorizacionLote: respuesta}
+	for _, claveAcceso := range s.lotes[claveLote] {
+		autorizacion, paso, ok := s.autorizarComprobante(claveAcceso)
+		if !ok {
+			continue
+		}
+		// En lotes el SRI incluye el comprobante en todos los estados para identificarlo
+		if autorizacion.Comprobante == "" {
+			autorizacion.Comprobante = string(s.comprobantes[claveAcceso].XML)
+		}
+		respuesta.Autorizaciones = append(respuesta.Autorizaciones, autorizacion)
+
+		if paso.Demora > resultado.demora {
+			resultado.demora = paso.Demora
+		}
+		if resultado.codigoHTTP == 0 {
+			resultado.codigoHTTP = paso.CodigoHTTP
+		}
+	}
+	respuesta.NumeroComprobantesLote = fmt.Sprintf("%d", len(respuesta.Autorizaciones))
+
+	return resultado
+}
+
+// autorizarComprobante avanza el escenario de una clave y arma su autorización (requiere lock).
+// Retorna false si el comprobante no fue recibido.
+func (s *SimuladorSRI) autorizarComprobante(claveAcceso string) (AutorizacionSRI, RespuestaSimulada, bool) {
+	comprobante, existe := s.comprobantes[claveAcceso]
+	if !existe || comprobante.Estado == EstadoSRIDevuelta {
+		return AutorizacionSRI{}, RespuestaSimulada{}, false
+	}
+
+	comprobante.Consultas++
+
+	// Un comprobante ya resuelto siempre responde igual
+	paso := RespuestaSimulada{Estado: comprobante.Estado, Mensajes: comprobante.Mensajes}
+	if comprobante.Estado != EstadoSRIAutorizado && comprobante.Estado != EstadoSRINoAutorizado {
+		paso = RespuestaSimulada{Estado: EstadoSRIAutorizado}
+		if pasos := s.escenario(claveAcceso).Autorizacion; len(pasos) > 0 {
+			paso = siguientePaso(pasos, s.pasosAutorizacion, claveAcceso)
+		}
+
+		comprobante.Estado = paso.Estado
+		comprobante.Mensajes = paso.Mensajes
+		if paso.Estado == EstadoSRIAutorizado {
+			comprobante.FechaAutorizacion = time.Now()
+		}
+	}
+
+	autorizacion := AutorizacionSRI{
+		Estado:   comprobante.Estado,
+		Ambiente: nombreAmbienteSRI(s.Ambiente),
+		Mensajes: comprobante.Mensajes,
+	}
+	if comprobante.Estado == EstadoSRIAutorizado {
+		autorizacion.NumeroAutorizacion = GenerarNumeroAutorizacion(claveAcceso)
+		autorizacion.FechaAutorizacion = comprobante.FechaAutorizacion.Format("2006-01-02T15:04:05-07:00")
+		autorizacion.Comprobante = string(comprobante.XML)
+	}
+
+	return autorizacion, paso, true
+}
+
+// escenario retorna el escenario de la clave o el escenario por defecto (requiere lock)
+func (s *SimuladorSRI) escenario(claveAcceso string) EscenarioSRI {
+	if escenario, ok := s.escenarios[claveAcceso]; ok {
+		return escenario
+	}
+	return s.escenarioDefecto
+}
+
+// siguientePaso consume un paso de la secuencia repitiendo el último (requiere lock)
+func siguientePaso(pasos []RespuestaSimulada, contadores map[string]int, claveAcceso string) RespuestaSimulada {
+	indice := contadores[claveAcceso]
+	contadores[claveAcceso] = indice + 1
+	if indice >= len(pasos) {
+		indice = len(pasos) - 1
+	}
+	return pasos[indice]
+}
+
+// esLoteMasivo indica si el documento recibido es un <lote>
+func esLoteMasivo(documento []byte) bool {
+	var raiz struct{ XMLName xml.Name }
+	return xml.Unmarshal(documento, &raiz) == nil && raiz.XMLName.Local == "lote"
+}
+
+// validarEstructuraComprobante verifica que el XML sea válido y contenga una clave de acceso correcta
+func validarEstructuraComprobante(xmlComprobante []byte) (string, error) {
+	var documento struct {
+		XMLName     xml.Name
+		ClaveAcceso string `xml:"infoTributaria>claveAcceso"`
+	}
+	if err := xml.Unmarshal(xmlComprobante, &documento); err != nil {
+		return "", fmt.Errorf("XML mal formado: %v", err)
+	}
+
+	switch documento.XMLName.Local {
+	case "factura", "notaCredito", "notaDebito", "guiaRemision", "comprobanteRetencion", "liquidacionCompra":
+	default:
+		return "", fmt.Errorf("tipo de comprobante no soportado: %s", documento.XMLName.Local)
+	}
+
+	claveAcceso := strings.TrimSpace(documento.ClaveAcceso)
+	if claveAcceso == "" {
+		return "", fmt.Errorf("infoTributaria/claveAcceso es requerido")
+	}
+	if err := ValidarClaveAcceso(claveAcceso); err != nil {
+		return claveAcceso, fmt.Errorf("clave de acceso inválida: %v", err)
+	}
+
+	return claveAcceso, nil
+}
+
+// esperarDemora espera la demora programada; retorna false si el cliente abandonó la petición
+func esperarDemora(r *http.Request, demora time.Duration) bool {
+	if demora <= 0 {
+		return true
+	}
+
+	select {
+	case <-time.After(demora):
+		return true
+	case <-r.Context().Done():
+		return false
+	}
+}
+
+// escribirEnvelope serializa la respuesta dentro de un envelope SOAP como el del SRI
+func escribirEnvelope(w http.ResponseWriter, operacion, namespace string, contenido interface{}) {
+	cuerpo, err := xml.Marshal(contenido)
+	if err != nil {
+		http.Error(w, "error generando respuesta", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>`+
+		`<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Body>`+
+		`<ns2:%s xmlns:ns2="%s">%s</ns2:%s></soap:Body></soap:Envelope>`,
+		operacion, namespace, cuerpo, operacion)
+}
+
+// mensajeSimulado construye un mensaje de error del SRI
+func mensajeSimulado(identificador, mensaje, adicional string) MensajeSRI {
+	return MensajeSRI{
+		Identificador:        identificador,
+		Mensaje:              mensaje,
+		InformacionAdicional: adicional,
+		Tipo:                 "ERROR",
+	}
+}
+
+// nombreAmbienteSRI nombre del ambiente tal como lo retorna el SRI
+func nombreAmbienteSRI(ambiente Ambiente) string {
+	if ambiente == Produccion {
+		return "PRODUCCIÓN"
+	}
+	return "PRUEBAS"
+}
@@ -0,0 +1,260 @@
+package sri
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// iniciarSimuladorPrueba levanta un simulador en proceso y un cliente apuntando a él
+func iniciarSimuladorPrueba(t *testing.T) (*SimuladorSRI, *SOAPClient) {
+	t.Helper()
+
+	simulador := NuevoSimuladorSRI(Pruebas)
+	if err := simulador.Iniciar(); err != nil {
+		t.Fatalf("Iniciar() error: %v", err)
+	}
+	t.Cleanup(func() { simulador.Detener() })
+
+	return simulador, simulador.NuevoCliente()
+}
+
+// comprobanteSimulado genera una factura mínima con clave de acceso válida
+func comprobanteSimulado(t *testing.T, secuencial int) (string, []byte) {
+	t.Helper()
+
+	clave, err := GenerarClaveAcceso(ClaveAccesoConfig{
+		FechaEmision:     time.Date(2025, 6, 24, 0, 0, 0, 0, time.UTC),
+		TipoComprobante:  Factura,
+		RUCEmisor:        "1792146739001",
+		Ambiente:         Pruebas,
+		Serie:            "001001",
+		NumeroSecuencial: fmt.Sprintf("%09d", secuencial),
+		CodigoNumerico:   "12345678",
+		TipoEmision:      EmisionNormal,
+	})
+	if err != nil {
+		t.Fatalf("GenerarClaveAcceso() error: %v", err)
+	}
+
+	xmlData := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<factura id="comprobante" version="1.1.0">
+	<infoTributaria>
+		<ambiente>1</ambiente>
+		<claveAcceso>%s</claveAcceso>
+	</infoTributaria>
+</factura>`, clave)
+
+	return clave, []byte(xmlData)
+}
+
+func TestSimuladorFlujoAutorizado(t *testing.T) {
+	simulador, cliente := iniciarSimuladorPrueba(t)
+	clave, xmlData := comprobanteSimulado(t, 1)
+
+	recepcion, err := cliente.EnviarComprobante(xmlData)
+	if err != nil {
+		t.Fatalf("EnviarComprobante() error: %v", err)
+	}
+	if recepcion.Estado != EstadoSRIRecibida {
+		t.Fatalf("Estado recepción = %s, esperado RECIBIDA", recepcion.Estado)
+	}
+
+	autorizacion, err := cliente.ConsultarAutorizacion(clave)
+	if err != nil {
+		t.Fatalf("ConsultarAutorizacion() error: %v", err)
+	}
+	if len(autorizacion.Autorizaciones) != 1 {
+		t.Fatalf("Se esperaba 1 autorización, obtenidas %d", len(autorizacion.Autorizaciones))
+	}
+
+	auth := autorizacion.Autorizaciones[0]
+	if auth.Estado != EstadoSRIAutorizado || auth.NumeroAutorizacion != clave {
+		t.Errorf("Autorización inesperada: %+v", auth)
+	}
+	if !strings.Contains(auth.Comprobante, clave) {
+		t.Error("La autorización debería incluir el comprobante autorizado")
+	}
+
+	estado, ok := simulador.Comprobante(clave)
+	if !ok || estado.Recepciones != 1 || estado.Consultas != 1 {
+		t.Errorf("Estado simulado inesperado: %+v", estado)
+	}
+
+	// Reenviar un comprobante autorizado: CLAVE ACCESO REGISTRADA
+	recepcion, err = cliente.EnviarComprobante(xmlData)
+	if err != nil {
+		t.Fatalf("EnviarComprobante() reenvío error: %v", err)
+	}
+	if recepcion.Estado != EstadoSRIDevuelta || recepcion.Comprobantes[0].Mensajes[0].Identificador != "43" {
+		t.Errorf("Reenvío debería ser DEVUELTA con código 43: %+v", recepcion)
+	}
+}
+
+func TestSimuladorValidacionEstructura(t *testing.T) {
+	_, cliente := iniciarSimuladorPrueba(t)
+	clave, _ := comprobanteSimulado(t, 2)
+	claveInvalida := clave[:48] + fmt.Sprintf("%d", (int(clave[48]-'0')+1)%10)
+
+	tests := []struct {
+		name    string
+		xmlData string
+	}{
+		{"XML mal formado", "<factura><infoTributaria>"},
+		{"Sin clave de acceso", "<factura><infoTributaria></infoTributaria></factura>"},
+		{"Clave con dígito verificador inválido", "<factura><infoTributaria><claveAcceso>" + claveInvalida + "</claveAcceso></infoTributaria></factura>"},
+		{"Tipo de comprobante desconocido", "<recibo><infoTributaria><claveAcceso>" + clave + "</claveAcceso></infoTributaria></recibo>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recepcion, err := cliente.EnviarComprobante([]byte(tt.xmlData))
+			if err != nil {
+				t.Fatalf("EnviarComprobante() error: %v", err)
+			}
+			if recepcion.Estado != EstadoSRIDevuelta {
+				t.Fatalf("Estado = %s, esperado DEVUELTA", recepcion.Estado)
+			}
+			if recepcion.Comprobantes[0].Mensajes[0].Identificador != "35" {
+				t.Errorf("Identificador = %s, esperado 35", recepcion.Comprobantes[0].Mensajes[0].Identificador)
+			}
+		})
+	}
+}
+
+func TestSimuladorEscenarios(t *testing.T) {
+	simulador, cliente := iniciarSimuladorPrueba(t)
+
+	t.Run("DEVUELTA programada", func(t *testing.T) {
+		clave, xmlData := comprobanteSimulado(t, 10)
+		simulador.ProgramarEscenario(clave, EscenarioDevuelta("45", "SECUENCIAL REGISTRADO"))
+
+		recepcion, err := cliente.EnviarComprobante(xmlData)
+		if err != nil {
+			t.Fatalf("EnviarComprobante() error: %v", err)
+		}
+		if recepcion.Estado != EstadoSRIDevuelta || recepcion.Comprobantes[0].Mensajes[0].Identificador != "45" {
+			t.Errorf("Respuesta inesperada: %+v", recepcion)
+		}
+
+		// Un comprobante devuelto no tiene autorizaciones
+		autorizacion, err := cliente.ConsultarAutorizacion(clave)
+		if err != nil {
+			t.Fatalf("ConsultarAutorizacion() error: %v", err)
+		}
+		if autorizacion.NumeroComprobantes != "0" || len(autorizacion.Autorizaciones) != 0 {
+			t.Errorf("Se esperaba respuesta sin autorizaciones: %+v", autorizacion)
+		}
+	})
+
+	t.Run("EN PROCESO y luego AUTORIZADO", func(t *testing.T) {
+		clave, xmlData := comprobanteSimulado(t, 11)
+		simulador.ProgramarEscenario(clave, EscenarioEnProceso(2))
+
+		if _, err := cliente.EnviarComprobante(xmlData); err != nil {
+			t.Fatalf("EnviarComprobante() error: %v", err)
+		}
+
+		esperados := []string{EstadoSRIEnProceso, EstadoSRIEnProceso, EstadoSRIAutorizado, EstadoSRIAutorizado}
+		for i, esperado := range esperados {
+			autorizacion, err := cliente.ConsultarAutorizacion(clave)
+			if err != nil {
+				t.Fatalf("ConsultarAutorizacion() consulta %d error: %v", i+1, err)
+			}
+			if estado := autorizacion.Autorizaciones[0].Estado; estado != esperado {
+				t.Errorf("Consulta %d estado = %s, esperado %s", i+1, estado, esperado)
+			}
+		}
+	})
+
+	t.Run("NO AUTORIZADO", func(t *testing.T) {
+		clave, xmlData := comprobanteSimulado(t, 12)
+		simulador.ProgramarEscenario(clave, EscenarioNoAutorizado("56", "ESTABLECIMIENTO CERRADO"))
+
+		if _, err := cliente.EnviarComprobante(xmlData); err != nil {
+			t.Fatalf("EnviarComprobante() error: %v", err)
+		}
+		autorizacion, err := cliente.ConsultarAutorizacion(clave)
+		if err != nil {
+			t.Fatalf("ConsultarAutorizacion() error: %v", err)
+		}
+
+		auth := autorizacion.Autorizaciones[0]
+		if auth.Estado != EstadoSRINoAutorizado || auth.NumeroAutorizacion != "" {
+			t.Errorf("Autorización inesperada: %+v", auth)
+		}
+		if len(auth.Mensajes) != 1 || auth.Mensajes[0].Identificador != "56" {
+			t.Errorf("Mensajes inesperados: %+v", auth.Mensajes)
+		}
+	})
+
+	t.Run("Error HTTP", func(t *testing.T) {
+		clave, xmlData := comprobanteSimulado(t, 13)
+		simulador.ProgramarEscenario(clave, EscenarioSRI{
+			Recepcion: []RespuestaSimulada{{CodigoHTTP: http.StatusServiceUnavailable}},
+		})
+
+		if _, err := cliente.EnviarComprobante(xmlData); err == nil {
+			t.Error("EnviarComprobante() esperaba error con HTTP 503")
+		}
+	})
+}
+
+func TestSimuladorTimeout(t *testing.T) {
+	simulador, cliente := iniciarSimuladorPrueba(t)
+	cliente.httpClient.Timeout = 100 * time.Millisecond
+
+	clave, xmlData := comprobanteSimulado(t, 20)
+	simulador.ProgramarEscenario(clave, EscenarioTimeout(2*time.Second))
+
+	inicio := time.Now()
+	if _, err := cliente.EnviarComprobante(xmlData); err == nil {
+		t.Fatal("EnviarComprobante() esperaba timeout")
+	}
+	if time.Since(inicio) > time.Second {
+		t.Errorf("El timeout del cliente no se respetó: %v", time.Since(inicio))
+	}
+
+	// El SRI sí registró el comprobante aunque el cliente no recibió respuesta
+	if estado, ok := simulador.Comprobante(clave); !ok || estado.Estado != EstadoSRIRecibida {
+		t.Errorf("Estado simulado inesperado: %+v", estado)
+	}
+}
+
+func TestSimuladorRequiereFirma(t *testing.T) {
+	simulador, cliente := iniciarSimuladorPrueba(t)
+	simulador.RequerirFirma = true
+
+	_, xmlData := comprobanteSimulado(t, 30)
+	recepcion, err := cliente.EnviarComprobante(xmlData)
+	if err != nil {
+		t.Fatalf("EnviarComprobante() error: %v", err)
+	}
+	if recepcion.Estado != EstadoSRIDevuelta || recepcion.Comprobantes[0].Mensajes[0].Identificador != "39" {
+		t.Errorf("Se esperaba DEVUELTA con código 39: %+v", recepcion)
+	}
+}
+
+func TestSimuladorEnvelopeSOAP(t *testing.T) {
+	simulador := NuevoSimuladorSRI(Pruebas)
+	clave, xmlData := comprobanteSimulado(t, 40)
+
+	resultado := simulador.procesarRecepcion(base64.StdEncoding.EncodeToString(xmlData))
+
+	// La respuesta serializada debe poder leerse con el parser del cliente
+	cuerpo, err := xml.Marshal(resultado.recepcion)
+	if err != nil {
+		t.Fatalf("Error serializando respuesta: %v", err)
+	}
+	respuesta, err := NewSOAPClient(Pruebas).parsearRespuestaRecepcion(cuerpo)
+	if err != nil {
+		t.Fatalf("parsearRespuestaRecepcion() error: %v", err)
+	}
+	if respuesta.Estado != EstadoSRIRecibida || respuesta.Comprobantes[0].ClaveAcceso != clave {
+		t.Errorf("Respuesta inesperada: %+v", respuesta)
+	}
+}
@@ -2,12 +2,10 @@
 package sri
 
 import (
-	"bytes"
-	"crypto/tls"
+	"context"
 	"encoding/base64"
 	"encoding/xml"
 	"fmt"
-	"io"
 	"net/http"
 	"strings"
 	"time"
@@ -30,8 +28,14 @@ const (
 type SOAPClient struct {
 	Ambiente        Ambiente
 	TimeoutSegundos int
+	// Endpoints de los servicios; vacíos usan config.Config.SRI o los oficiales del ambiente
+	EndpointRecepcion    string
+	EndpointAutorizacion string
 	httpClient      *http.Client
 	circuitBreaker  *CircuitBreaker
+	archivo         ArchivoSOAP // Archivo de envelopes SOAP (opcional)
+	limitador       *LimitadorSRI
+	colaFallidos    ColaFallidos // Registro de envíos que agotan sus reintentos (opcional)
 }
 
 // RespuestaSolicitud respuesta del servicio de recepción SRI
@@ -121,15 +125,9 @@ func NewSOAPClient(ambiente Ambiente) *SOAPClient {
 	// Obtener timeout desde configuración
 	timeout := time.Duration(config.Config.SRI.TimeoutSegundos) * time.Second
 	
-	// Configurar cliente HTTP con timeout y TLS
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: ambiente == Pruebas, // Solo en pruebas
-		},
-	}
-
+	// Configurar cliente HTTP con timeout, TLS verificado, proxy y pool de conexiones
 	client := &http.Client{
-		Transport: tr,
+		Transport: transporteConfigurado(ambiente),
 		Timeout:   timeout,
 	}
 
@@ -141,20 +139,117 @@ func NewSOAPClient(ambiente Ambiente) *SOAPClient {
 		circuitConfig = ConfigCircuitBreakerDefault
 	}
 
+	endpointRecepcion, endpointAutorizacion := endpointsConfigurados(ambiente)
+
 	return &SOAPClient{
 		Ambiente:        ambiente,
 		TimeoutSegundos: config.Config.SRI.TimeoutSegundos,
+		EndpointRecepcion:    endpointRecepcion,
+		EndpointAutorizacion: endpointAutorizacion,
 		httpClient:      client,
 		circuitBreaker:  NuevoCircuitBreaker(circuitConfig),
+		limitador:       NuevoLimitadorSRI(limitesConfigurados(ambiente)),
+	}
+}
+
+// NewSOAPClientConEndpoints crea un cliente SOAP apuntando a endpoints específicos
+// (por ejemplo el SimuladorSRI o un proxy interno)
+func NewSOAPClientConEndpoints(ambiente Ambiente, endpointRecepcion, endpointAutorizacion string) *SOAPClient {
+	client := NewSOAPClient(ambiente)
+	client.EndpointRecepcion = endpointRecepcion
+	client.EndpointAutorizacion = endpointAutorizacion
+	return client
+}
+
+// AmbienteDesdeCodigo convierte el código de ambiente de la configuración ("1" pruebas, "2" producción)
+func AmbienteDesdeCodigo(codigo string) Ambiente {
+	if strings.TrimSpace(codigo) == Produccion.String() {
+		return Produccion
 	}
+	return Pruebas
+}
+
+// AmbienteConfigurado retorna el ambiente definido en config.Config.Ambiente
+func AmbienteConfigurado() Ambiente {
+	return AmbienteDesdeCodigo(config.Config.Ambiente.Codigo)
+}
+
+// endpointsConfigurados retorna los endpoints de config.Config.SRI, que corresponden
+// al ambiente configurado; para el otro ambiente se usan los oficiales
+func endpointsConfigurados(ambiente Ambiente) (string, string) {
+	if ambiente != AmbienteConfigurado() {
+		return "", ""
+	}
+	return config.Config.SRI.EndpointRecepcion, config.Config.SRI.EndpointAutorizacion
+}
+
+// limitesConfigurados retorna los límites de config.Config.SRI.Limites para el ambiente
+func limitesConfigurados(ambiente Ambiente) ConfigLimitador {
+	limite := config.Config.SRI.Limites.Pruebas
+	if ambiente == Produccion {
+		limite = config.Config.SRI.Limites.Produccion
+	}
+	return ConfigLimitador{
+		PeticionesPorSegundo: limite.PeticionesPorSegundo,
+		Rafaga:               limite.Rafaga,
+		MaxConcurrentes:      limite.MaxConcurrentes,
+	}
+}
+
+// ConfigurarLimitador reemplaza los límites de tasa y concurrencia del cliente
+func (c *SOAPClient) ConfigurarLimitador(limites ConfigLimitador) {
+	c.limitador = NuevoLimitadorSRI(limites)
+}
+
+// ObtenerMetricasLimitador retorna los tiempos de cola y de llamada acumulados
+func (c *SOAPClient) ObtenerMetricasLimitador() MetricasLimitador {
+	return c.limitador.ObtenerMetricas()
+}
+
+// Endpoints retorna los endpoints de recepción y autorización que usa el cliente
+func (c *SOAPClient) Endpoints() (string, string) {
+	return c.endpointRecepcion(), c.endpointAutorizacion()
+}
+
+// endpointRecepcion determina el endpoint de recepción a utilizar
+func (c *SOAPClient) endpointRecepcion() string {
+	if c.EndpointRecepcion != "" {
+		return c.EndpointRecepcion
+	}
+	if configurado, _ := endpointsConfigurados(c.Ambiente); configurado != "" {
+		return configurado
+	}
+	if c.Ambiente == Produccion {
+		return EndpointRecepcionProduccion
+	}
+	return EndpointRecepcionCertificacion
+}
+
+// endpointAutorizacion determina el endpoint de autorización a utilizar
+func (c *SOAPClient) endpointAutorizacion() string {
+	if c.EndpointAutorizacion != "" {
+		return c.EndpointAutorizacion
+	}
+	if _, configurado := endpointsConfigurados(c.Ambiente); configurado != "" {
+		return configurado
+	}
+	if c.Ambiente == Produccion {
+		return EndpointAutorizacionProduccion
+	}
+	return EndpointAutorizacionCertificacion
 }
 
 // EnviarComprobante envía un comprobante XML al SRI para validación con circuit breaker
 func (c *SOAPClient) EnviarComprobante(xmlComprobante []byte) (*RespuestaSolicitud, error) {
+	return c.EnviarComprobanteContexto(context.Background(), xmlComprobante)
+}
+
+// EnviarComprobanteContexto envía un comprobante XML al SRI; la petición HTTP se cancela con el contexto
+func (c *SOAPClient) EnviarComprobanteContexto(ctx context.Context, xmlComprobante []byte) (*RespuestaSolicitud, error) {
 	// Usar circuit breaker para proteger la comunicación
 	var respuesta *RespuestaSolicitud
-	err := c.circuitBreaker.Ejecutar(func() error {
-		resp, err := c.enviarComprobanteInterno(xmlComprobante)
+	err := c.circuitBreaker.EjecutarContexto(ctx, func(ctx context.Context) error {
+		resp, err := c.enviarComprobanteInterno(ctx, xmlComprobante)
 		if err != nil {
 			return err
 		}
@@ -166,7 +261,7 @@ func (c *SOAPClient) EnviarComprobante(xmlComprobante []byte) (*RespuestaSolicit
 }
 
 // enviarComprobanteInterno implementación interna sin circuit breaker
-func (c *SOAPClient) enviarComprobanteInterno(xmlComprobante []byte) (*RespuestaSolicitud, error) {
+func (c *SOAPClient) enviarComprobanteInterno(ctx context.Context, xmlComprobante []byte) (*RespuestaSolicitud, error) {
 	// Codificar XML en base64
 	xmlBase64 := base64.StdEncoding.EncodeToString(xmlComprobante)
 
@@ -190,44 +285,18 @@ func (c *SOAPClient) enviarComprobanteInterno(xmlComprobante []byte) (*Respuesta
 	// Agregar header XML
 	soapRequest := []byte(`<?xml version="1.0" encoding="UTF-8"?>` + "\n" + string(soapXML))
 
-	// Determinar endpoint desde configuración
-	endpoint := config.Config.SRI.EndpointRecepcion
-	if endpoint == "" {
-		// Fallback a constantes si no está configurado
-		if c.Ambiente == Produccion {
-			endpoint = EndpointRecepcionProduccion
-		} else {
-			endpoint = EndpointRecepcionCertificacion
-		}
-	}
-
-	// Crear petición HTTP
-	req, err := http.NewRequest("POST", endpoint, bytes.NewBuffer(soapRequest))
-	if err != nil {
-		return nil, fmt.Errorf("error creando petición HTTP: %v", err)
-	}
-
-	// Headers requeridos por SRI
-	req.Header.Set("Content-Type", "text/xml; charset=utf-8")
-	req.Header.Set("SOAPAction", "")
-	req.Header.Set("Content-Length", fmt.Sprintf("%d", len(soapRequest)))
-
-	// Enviar petición
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("error enviando petición al SRI: %v", err)
-	}
-	defer resp.Body.Close()
+	// Determinar endpoint (cliente, configuración u oficial)
+	endpoint := c.endpointRecepcion()
 
-	// Leer respuesta
-	respBody, err := io.ReadAll(resp.Body)
+	// Enviar petición (queda registrada en el archivo SOAP si está configurado)
+	respBody, codigoHTTP, err := c.ejecutarSOAP(ctx, OperacionRecepcion, claveAccesoDocumento(xmlComprobante), endpoint, soapRequest)
 	if err != nil {
-		return nil, fmt.Errorf("error leyendo respuesta del SRI: %v", err)
+		return nil, err
 	}
 
 	// Verificar código de respuesta HTTP
-	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("SRI respondió con código %d: %s", resp.StatusCode, string(respBody))
+	if codigoHTTP != 200 {
+		return nil, fmt.Errorf("SRI respondió con código %d: %s", codigoHTTP, string(respBody))
 	}
 
 	// Parsear respuesta SOAP
@@ -236,10 +305,15 @@ func (c *SOAPClient) enviarComprobanteInterno(xmlComprobante []byte) (*Respuesta
 
 // ConsultarAutorizacion consulta el estado de autorización de un comprobante con circuit breaker
 func (c *SOAPClient) ConsultarAutorizacion(claveAcceso string) (*RespuestaComprobante, error) {
+	return c.ConsultarAutorizacionContexto(context.Background(), claveAcceso)
+}
+
+// ConsultarAutorizacionContexto consulta la autorización; la petición HTTP se cancela con el contexto
+func (c *SOAPClient) ConsultarAutorizacionContexto(ctx context.Context, claveAcceso string) (*RespuestaComprobante, error) {
 	// Usar circuit breaker para proteger la comunicación
 	var respuesta *RespuestaComprobante
-	err := c.circuitBreaker.Ejecutar(func() error {
-		resp, err := c.consultarAutorizacionInterno(claveAcceso)
+	err := c.circuitBreaker.EjecutarContexto(ctx, func(ctx context.Context) error {
+		resp, err := c.consultarAutorizacionInterno(ctx, claveAcceso)
 		if err != nil {
 			return err
 		}
@@ -251,7 +325,7 @@ func (c *SOAPClient) ConsultarAutorizacion(claveAcceso string) (*RespuestaCompro
 }
 
 // consultarAutorizacionInterno implementación interna sin circuit breaker
-func (c *SOAPClient) consultarAutorizacionInterno(claveAcceso string) (*RespuestaComprobante, error) {
+func (c *SOAPClient) consultarAutorizacionInterno(ctx context.Context, claveAcceso string) (*RespuestaComprobante, error) {
 	// Crear solicitud SOAP
 	solicitud := SolicitudAutorizacion{
 		SoapNS: "http://schemas.xmlsoap.org/soap/envelope/",
@@ -272,44 +346,18 @@ func (c *SOAPClient) consultarAutorizacionInterno(claveAcceso string) (*Respuest
 	// Agregar header XML
 	soapRequest := []byte(`<?xml version="1.0" encoding="UTF-8"?>` + "\n" + string(soapXML))
 
-	// Determinar endpoint desde configuración
-	endpoint := config.Config.SRI.EndpointAutorizacion
-	if endpoint == "" {
-		// Fallback a constantes si no está configurado
-		if c.Ambiente == Produccion {
-			endpoint = EndpointAutorizacionProduccion
-		} else {
-			endpoint = EndpointAutorizacionCertificacion
-		}
-	}
-
-	// Crear petición HTTP
-	req, err := http.NewRequest("POST", endpoint, bytes.NewBuffer(soapRequest))
-	if err != nil {
-		return nil, fmt.Errorf("error creando petición HTTP: %v", err)
-	}
-
-	// Headers requeridos por SRI
-	req.Header.Set("Content-Type", "text/xml; charset=utf-8")
-	req.Header.Set("SOAPAction", "")
-	req.Header.Set("Content-Length", fmt.Sprintf("%d", len(soapRequest)))
-
-	// Enviar petición
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("error enviando petición al SRI: %v", err)
-	}
-	defer resp.Body.Close()
+	// Determinar endpoint (cliente, configuración u oficial)
+	endpoint := c.endpointAutorizacion()
 
-	// Leer respuesta
-	respBody, err := io.ReadAll(resp.Body)
+	// Enviar petición (queda registrada en el archivo SOAP si está configurado)
+	respBody, codigoHTTP, err := c.ejecutarSOAP(ctx, OperacionAutorizacion, claveAcceso, endpoint, soapRequest)
 	if err != nil {
-		return nil, fmt.Errorf("error leyendo respuesta del SRI: %v", err)
+		return nil, err
 	}
 
 	// Verificar código de respuesta HTTP
-	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("SRI respondió con código %d: %s", resp.StatusCode, string(respBody))
+	if codigoHTTP != 200 {
+		return nil, fmt.Errorf("SRI respondió con código %d: %s", codigoHTTP, string(respBody))
 	}
 
 	// Parsear respuesta SOAP
@@ -399,10 +447,15 @@ func (c *SOAPClient) parsearRespuestaAutorizacion(respXML []byte) (*RespuestaCom
 
 // ProcesarComprobanteCompleto procesa un comprobante de forma completa: envío + autorización
 func (c *SOAPClient) ProcesarComprobanteCompleto(xmlComprobante []byte, claveAcceso string) (*AutorizacionSRI, error) {
+	return c.ProcesarComprobanteCompletoContexto(context.Background(), xmlComprobante, claveAcceso)
+}
+
+// ProcesarComprobanteCompletoContexto procesa envío + autorización; las esperas se interrumpen con el contexto
+func (c *SOAPClient) ProcesarComprobanteCompletoContexto(ctx context.Context, xmlComprobante []byte, claveAcceso string) (*AutorizacionSRI, error) {
 	fmt.Println("📤 Enviando comprobante al SRI...")
 
 	// Paso 1: Enviar comprobante para validación
-	respRecepcion, err := c.EnviarComprobante(xmlComprobante)
+	respRecepcion, err := c.EnviarComprobanteContexto(ctx, xmlComprobante)
 	if err != nil {
 		return nil, fmt.Errorf("error en recepción: %v", err)
 	}
@@ -416,7 +469,9 @@ func (c *SOAPClient) ProcesarComprobanteCompleto(xmlComprobante []byte, claveAcc
 
 	// Paso 2: Esperar un momento antes de consultar autorización
 	fmt.Println("⏳ Esperando procesamiento del SRI...")
-	time.Sleep(3 * time.Second)
+	if err := esperarContexto(ctx, 3*time.Second); err != nil {
+		return nil, err
+	}
 
 	// Paso 3: Consultar autorización con reintentos
 	var respAutorizacion *RespuestaComprobante
@@ -425,13 +480,18 @@ func (c *SOAPClient) ProcesarComprobanteCompleto(xmlComprobante []byte, claveAcc
 	for intento := 1; intento <= maxReintentos; intento++ {
 		fmt.Printf("🔍 Consultando autorización (intento %d/%d)...\n", intento, maxReintentos)
 
-		respAutorizacion, err = c.ConsultarAutorizacion(claveAcceso)
+		respAutorizacion, err = c.ConsultarAutorizacionContexto(ctx, claveAcceso)
 		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
 			if intento == maxReintentos {
 				return nil, fmt.Errorf("error consultando autorización después de %d intentos: %v", maxReintentos, err)
 			}
 			fmt.Printf("⚠️  Error en intento %d, reintentando...\n", intento)
-			time.Sleep(2 * time.Second)
+			if err := esperarContexto(ctx, 2*time.Second); err != nil {
+				return nil, err
+			}
 			continue
 		}
 
@@ -450,7 +510,9 @@ func (c *SOAPClient) ProcesarComprobanteCompleto(xmlComprobante []byte, claveAcc
 		// Si aún no está procesado, esperar y reintentar
 		if intento < maxReintentos {
 			fmt.Println("⏳ Comprobante aún en procesamiento, esperando...")
-			time.Sleep(3 * time.Second)
+			if err := esperarContexto(ctx, 3*time.Second); err != nil {
+				return nil, err
+			}
 		}
 	}
 
@@ -514,6 +576,11 @@ func (c *SOAPClient) MostrarEstadoCircuitBreaker() {
 	c.circuitBreaker.MostrarEstado()
 }
 
+// ObtenerEstadisticasCircuitBreaker obtiene las estadísticas acumuladas del circuit breaker
+func (c *SOAPClient) ObtenerEstadisticasCircuitBreaker() EstadisticasCircuitBreaker {
+	return c.circuitBreaker.ObtenerEstadisticas()
+}
+
 // ReiniciarCircuitBreaker reinicia el circuit breaker (útil para testing)
 func (c *SOAPClient) ReiniciarCircuitBreaker() {
 	c.circuitBreaker.Reiniciar()
@@ -1,6 +1,8 @@
 package sri
 
 import (
+	"context"
+	"errors"
 	"testing"
 	"time"
 )
@@ -102,7 +104,7 @@ func TestParsearRespuestaRecepcionMock(t *testing.T) {
 						<claveAcceso>2306202501179214673900110010010000000019152728411</claveAcceso>
 						<mensajes>
 							<mensaje>
-								<identificador>CLAVE-01</identificador>
+								<identificador>43</identificador>
 								<mensaje>CLAVE DE ACCESO REGISTRADA</mensaje>
 								<informacionAdicional></informacionAdicional>
 								<tipo>INFORMATIVO</tipo>
@@ -272,4 +274,57 @@ func containsInner(s, substr string) bool {
 		}
 	}
 	return false
-}
\ No newline at end of file
+}
+
+// TestSOAPClientContextoCancelaPeticion verifica que el contexto cancele la petición HTTP
+// y que la cancelación no cuente como fallo en el circuit breaker
+func TestSOAPClientContextoCancelaPeticion(t *testing.T) {
+	simulador, cliente := iniciarSimuladorPrueba(t)
+	clave, xmlData := comprobanteSimulado(t, 50)
+	simulador.ProgramarEscenario(clave, EscenarioTimeout(5*time.Second))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	inicio := time.Now()
+	_, err := cliente.EnviarComprobanteContexto(ctx, xmlData)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("EnviarComprobanteContexto() error = %v, esperado context.DeadlineExceeded", err)
+	}
+	if time.Since(inicio) > time.Second {
+		t.Errorf("La petición no se canceló a tiempo: %v", time.Since(inicio))
+	}
+
+	estadisticas := cliente.circuitBreaker.ObtenerEstadisticas()
+	if estadisticas.PeticionesFallidas != 0 {
+		t.Errorf("PeticionesFallidas = %d, la cancelación no debe contar como fallo", estadisticas.PeticionesFallidas)
+	}
+
+	// Con un contexto ya cancelado no se contacta al SRI
+	cancelado, cancelar := context.WithCancel(context.Background())
+	cancelar()
+	if _, err := cliente.ConsultarAutorizacionContexto(cancelado, clave); !errors.Is(err, context.Canceled) {
+		t.Errorf("ConsultarAutorizacionContexto() error = %v, esperado context.Canceled", err)
+	}
+	if estado, _ := simulador.Comprobante(clave); estado.Consultas != 0 {
+		t.Errorf("Consultas = %d, no se esperaban consultas al SRI", estado.Consultas)
+	}
+}
+
+// TestProcesarComprobanteCompletoContexto verifica que la cancelación interrumpa las esperas
+func TestProcesarComprobanteCompletoContexto(t *testing.T) {
+	_, cliente := iniciarSimuladorPrueba(t)
+	clave, xmlData := comprobanteSimulado(t, 51)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	inicio := time.Now()
+	_, err := cliente.ProcesarComprobanteCompletoContexto(ctx, xmlData, clave)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("ProcesarComprobanteCompletoContexto() error = %v, esperado context.DeadlineExceeded", err)
+	}
+	if time.Since(inicio) > time.Second {
+		t.Errorf("La espera inicial no se interrumpió: %v", time.Since(inicio))
+	}
+}
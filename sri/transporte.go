@@ -0,0 +1,114 @@
+// Package sri implementa el transporte HTTP hacia los servicios del SRI
+package sri
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"go-facturacion-sri/config"
+)
+
+// NuevoTransporteSRI construye el transporte HTTP hacia el SRI: proxy, CA adicionales,
+// certificado de cliente y pool de conexiones. La verificación TLS solo puede omitirse
+// en pruebas y de forma explícita.
+func NuevoTransporteSRI(ambiente Ambiente, red config.RedSRIConfig) (*http.Transport, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if red.OmitirVerificacionTLS {
+		if ambiente == Produccion {
+			return nil, fmt.Errorf("no se permite omitir la verificación TLS en producción")
+		}
+		tlsConfig.InsecureSkipVerify = true
+	}
+
+	if len(red.CAsAdicionales) > 0 {
+		raices, err := x509.SystemCertPool()
+		if err != nil || raices == nil {
+			raices = x509.NewCertPool()
+		}
+		for _, ruta := range red.CAsAdicionales {
+			pem, err := os.ReadFile(ruta)
+			if err != nil {
+				return nil, fmt.Errorf("error leyendo CA %s: %v", ruta, err)
+			}
+			if !raices.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("el archivo %s no contiene certificados PEM válidos", ruta)
+			}
+		}
+		tlsConfig.RootCAs = raices
+	}
+
+	if red.CertificadoCliente != "" || red.LlaveCliente != "" {
+		if red.CertificadoCliente == "" || red.LlaveCliente == "" {
+			return nil, fmt.Errorf("el certificado de cliente requiere certificado y llave")
+		}
+		certificado, err := tls.LoadX509KeyPair(red.CertificadoCliente, red.LlaveCliente)
+		if err != nil {
+			return nil, fmt.Errorf("error cargando certificado de cliente: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificado}
+	}
+
+	proxy := http.ProxyFromEnvironment
+	if red.ProxyURL != "" {
+		proxyURL, err := url.Parse(red.ProxyURL)
+		if err != nil || proxyURL.Host == "" {
+			return nil, fmt.Errorf("URL de proxy inválida: %s", red.ProxyURL)
+		}
+		proxy = http.ProxyURL(proxyURL)
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: segundosODefecto(red.KeepAliveSegundos, 30),
+	}
+
+	return &http.Transport{
+		Proxy:               proxy,
+		DialContext:         dialer.DialContext,
+		TLSClientConfig:     tlsConfig,
+		TLSHandshakeTimeout: segundosODefecto(red.TimeoutHandshakeSegundos, 10),
+		MaxIdleConns:        enteroODefecto(red.MaxConexionesInactivas, 20),
+		MaxIdleConnsPerHost: enteroODefecto(red.MaxConexionesPorHost, 10),
+		IdleConnTimeout:     segundosODefecto(red.InactividadSegundos, 90),
+		ForceAttemptHTTP2:   true,
+	}, nil
+}
+
+// transporteConfigurado retorna el transporte de config.Config.SRI.Red. La configuración se
+// valida al cargarla; si aun así es inválida (p.ej. se borró la CA) todas las peticiones fallan
+// con el error en lugar de salir sin el proxy, las CA o el certificado de cliente configurados.
+func transporteConfigurado(ambiente Ambiente) *http.Transport {
+	transporte, err := NuevoTransporteSRI(ambiente, config.Config.SRI.Red)
+	if err != nil {
+		log.Printf("[SRI_RED] Configuración de red inválida, las peticiones al SRI fallarán: %v", err)
+		errConfiguracion := fmt.Errorf("configuración de red hacia el SRI inválida: %v", err)
+		return &http.Transport{
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return nil, errConfiguracion
+			},
+		}
+	}
+	return transporte
+}
+
+// segundosODefecto convierte segundos de configuración a duración, con valor por defecto si es 0
+func segundosODefecto(segundos, defecto int) time.Duration {
+	return time.Duration(enteroODefecto(segundos, defecto)) * time.Second
+}
+
+// enteroODefecto retorna el valor o el defecto si no está configurado
+func enteroODefecto(valor, defecto int) int {
+	if valor <= 0 {
+		return defecto
+	}
+	return valor
+}
@@ -0,0 +1,118 @@
+package sri
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go-facturacion-sri/config"
+)
+
+func TestNuevoTransporteSRIVerificaTLSPorDefecto(t *testing.T) {
+	for _, ambiente := range []Ambiente{Pruebas, Produccion} {
+		transporte, err := NuevoTransporteSRI(ambiente, config.RedSRIConfig{})
+		if err != nil {
+			t.Fatalf("NuevoTransporteSRI(%v) error: %v", ambiente, err)
+		}
+		if transporte.TLSClientConfig.InsecureSkipVerify {
+			t.Errorf("Ambiente %v: la verificación TLS debería estar activa", ambiente)
+		}
+		if transporte.MaxIdleConnsPerHost != 10 || transporte.Proxy == nil {
+			t.Errorf("Ambiente %v: transporte sin valores por defecto: %+v", ambiente, transporte)
+		}
+	}
+
+	cliente := NewSOAPClient(Pruebas)
+	transporte, ok := cliente.httpClient.Transport.(*http.Transport)
+	if !ok || transporte.TLSClientConfig.InsecureSkipVerify {
+		t.Error("NewSOAPClient(Pruebas) no debería omitir la verificación TLS")
+	}
+}
+
+func TestNuevoTransporteSRIConfiguracionInvalida(t *testing.T) {
+	tests := []struct {
+		name     string
+		ambiente Ambiente
+		red      config.RedSRIConfig
+	}{
+		{"omitir verificación en producción", Produccion, config.RedSRIConfig{OmitirVerificacionTLS: true}},
+		{"CA inexistente", Pruebas, config.RedSRIConfig{CAsAdicionales: []string{"/no/existe/ca.pem"}}},
+		{"certificado sin llave", Pruebas, config.RedSRIConfig{CertificadoCliente: "cliente.pem"}},
+		{"proxy sin host", Pruebas, config.RedSRIConfig{ProxyURL: "proxy-corporativo"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NuevoTransporteSRI(tt.ambiente, tt.red); err == nil {
+				t.Error("Se esperaba error de configuración")
+			}
+		})
+	}
+}
+
+func TestNuevoTransporteSRIProxy(t *testing.T) {
+	transporte, err := NuevoTransporteSRI(Pruebas, config.RedSRIConfig{ProxyURL: "http://proxy.empresa.local:3128"})
+	if err != nil {
+		t.Fatalf("NuevoTransporteSRI() error: %v", err)
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, EndpointRecepcionCertificacion, nil)
+	proxy, err := transporte.Proxy(req)
+	if err != nil || proxy == nil || proxy.Host != "proxy.empresa.local:3128" {
+		t.Errorf("Proxy = %v (%v), esperado proxy.empresa.local:3128", proxy, err)
+	}
+}
+
+func TestNuevoTransporteSRICAAdicional(t *testing.T) {
+	servidor := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer servidor.Close()
+
+	// Sin la CA del servidor la conexión debe fallar
+	transporte, err := NuevoTransporteSRI(Pruebas, config.RedSRIConfig{})
+	if err != nil {
+		t.Fatalf("NuevoTransporteSRI() error: %v", err)
+	}
+	if _, err := (&http.Client{Transport: transporte}).Get(servidor.URL); err == nil {
+		t.Fatal("Un certificado no confiable debería rechazarse")
+	}
+
+	rutaCA := filepath.Join(t.TempDir(), "ca-corporativa.pem")
+	certificado := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: servidor.Certificate().Raw})
+	if err := os.WriteFile(rutaCA, certificado, 0600); err != nil {
+		t.Fatalf("Error escribiendo CA: %v", err)
+	}
+
+	transporte, err = NuevoTransporteSRI(Pruebas, config.RedSRIConfig{CAsAdicionales: []string{rutaCA}})
+	if err != nil {
+		t.Fatalf("NuevoTransporteSRI() con CA error: %v", err)
+	}
+	resp, err := (&http.Client{Transport: transporte}).Get(servidor.URL)
+	if err != nil {
+		t.Fatalf("La CA adicional debería ser confiable: %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestTransporteConfiguradoInvalidoFallaCerrado(t *testing.T) {
+	original := config.Config.SRI.Red
+	defer func() { config.Config.SRI.Red = original }()
+
+	servidor := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("La petición no debería salir sin la CA configurada")
+	}))
+	defer servidor.Close()
+
+	config.Config.SRI.Red = config.RedSRIConfig{CAsAdicionales: []string{"/no/existe/ca.pem"}}
+	transporte := transporteConfigurado(Pruebas)
+
+	_, err := (&http.Client{Transport: transporte}).Get(servidor.URL)
+	if err == nil || !strings.Contains(err.Error(), "configuración de red hacia el SRI inválida") {
+		t.Errorf("Get() = %v, esperado error de configuración", err)
+	}
+}
@@ -3,8 +3,6 @@ package sri
 
 import (
 	"crypto"
-	"crypto/rand"
-	"crypto/rsa"
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/xml"
@@ -15,7 +13,8 @@ import (
 
 // XAdESBESConfig configuración para firma XAdES-BES
 type XAdESBESConfig struct {
-	Certificado *CertificadoDigital
+	Certificado *CertificadoDigital // Se usa con SignerPKCS12 cuando Signer es nil
+	Signer      Signer              // Firmador local o remoto (opcional)
 	PolicyID    string // Política de firma (requerido por SRI)
 	PolicyHash  string // Hash de la política
 	PolicyURL   string // URL de la política
@@ -182,27 +181,35 @@ type Object struct {
 
 // FirmarXMLXAdESBES firma un documento XML usando XAdES-BES
 func FirmarXMLXAdESBES(xmlData []byte, config XAdESBESConfig) ([]byte, error) {
-	// Validar certificado
-	if config.Certificado == nil {
-		return nil, fmt.Errorf("certificado requerido para firma XAdES-BES")
+	// Resolver firmador (por compatibilidad se acepta solo el certificado local)
+	signer := config.Signer
+	if signer == nil {
+		local, err := NuevoSignerPKCS12(config.Certificado)
+		if err != nil {
+			return nil, err
+		}
+		signer = local
 	}
 
-	// Validar clave privada RSA
-	rsaKey, ok := config.Certificado.PrivateKey.(*rsa.PrivateKey)
-	if !ok {
-		return nil, fmt.Errorf("se requiere clave privada RSA")
+	cadena, err := signer.CadenaCertificados()
+	if err != nil {
+		return nil, fmt.Errorf("error obteniendo cadena de certificados: %v", err)
+	}
+	if len(cadena) == 0 || cadena[0] == nil {
+		return nil, fmt.Errorf("el firmador no proporcionó certificado")
 	}
+	cert := cadena[0]
 
 	// Calcular hash del documento
 	documentHash := sha256.Sum256(xmlData)
 	documentHashB64 := base64.StdEncoding.EncodeToString(documentHash[:])
 
 	// Calcular hash del certificado
-	certHash := sha256.Sum256(config.Certificado.Cert.Raw)
+	certHash := sha256.Sum256(cert.Raw)
 	certHashB64 := base64.StdEncoding.EncodeToString(certHash[:])
 
 	// Obtener certificado en base64
-	certB64 := base64.StdEncoding.EncodeToString(config.Certificado.Cert.Raw)
+	certB64 := base64.StdEncoding.EncodeToString(cert.Raw)
 
 	// Crear SignedInfo
 	signedInfo := SignedInfo{}
@@ -229,8 +236,8 @@ func FirmarXMLXAdESBES(xmlData []byte, config XAdESBESConfig) ([]byte, error) {
 	// Calcular hash de SignedInfo
 	signedInfoHash := sha256.Sum256(signedInfoXML)
 
-	// Firmar con RSA
-	signature, err := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, signedInfoHash[:])
+	// Firmar el digest (localmente o en el servicio remoto)
+	signature, err := signer.FirmarDigest(signedInfoHash[:], crypto.SHA256)
 	if err != nil {
 		return nil, fmt.Errorf("error firmando: %v", err)
 	}
@@ -247,8 +254,8 @@ func FirmarXMLXAdESBES(xmlData []byte, config XAdESBESConfig) ([]byte, error) {
 
 	// KeyInfo
 	xadesSignature.KeyInfo.X509Data.X509Certificate = certB64
-	xadesSignature.KeyInfo.X509Data.X509SubjectName = config.Certificado.Cert.Subject.String()
-	xadesSignature.KeyInfo.X509Data.X509IssuerName = config.Certificado.Cert.Issuer.String()
+	xadesSignature.KeyInfo.X509Data.X509SubjectName = cert.Subject.String()
+	xadesSignature.KeyInfo.X509Data.X509IssuerName = cert.Issuer.String()
 
 	// QualifyingProperties (XAdES-BES)
 	xadesSignature.Object.QualifyingProperties.XAdESNamespace = "http://uri.etsi.org/01903/v1.3.2#"
@@ -261,8 +268,8 @@ func FirmarXMLXAdESBES(xmlData []byte, config XAdESBESConfig) ([]byte, error) {
 	// SigningCertificate
 	xadesSignature.Object.QualifyingProperties.SignedProperties.SignedSignatureProperties.SigningCertificate.Cert.CertDigest.DigestMethod.Algorithm = "http://www.w3.org/2000/09/xmldsig#sha1"
 	xadesSignature.Object.QualifyingProperties.SignedProperties.SignedSignatureProperties.SigningCertificate.Cert.CertDigest.DigestValue = certHashB64
-	xadesSignature.Object.QualifyingProperties.SignedProperties.SignedSignatureProperties.SigningCertificate.Cert.IssuerSerial.X509IssuerName = config.Certificado.Cert.Issuer.String()
-	xadesSignature.Object.QualifyingProperties.SignedProperties.SignedSignatureProperties.SigningCertificate.Cert.IssuerSerial.X509SerialNumber = config.Certificado.Cert.SerialNumber.String()
+	xadesSignature.Object.QualifyingProperties.SignedProperties.SignedSignatureProperties.SigningCertificate.Cert.IssuerSerial.X509IssuerName = cert.Issuer.String()
+	xadesSignature.Object.QualifyingProperties.SignedProperties.SignedSignatureProperties.SigningCertificate.Cert.IssuerSerial.X509SerialNumber = cert.SerialNumber.String()
 
 	// SignaturePolicyIdentifier (obligatorio para SRI)
 	if config.PolicyID != "" {
@@ -36,7 +36,7 @@ export interface FacturaData {
   numeroFactura: string;
   clienteNombre: string;
   total: string;
-  estado: 'BORRADOR' | 'ENVIADA' | 'AUTORIZADA' | 'RECHAZADA';
+  estado: 'BORRADOR' | 'FIRMADA' | 'ENVIADA' | 'RECIBIDA' | 'EN_PROCESO' | 'AUTORIZADA' | 'NO_AUTORIZADA' | 'DEVUELTA' | 'ANULADA' | 'RECHAZADA';
   fechaCreacion?: string;
 }
 
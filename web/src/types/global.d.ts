@@ -75,7 +75,7 @@ export interface Factura {
   subtotal: number;
   iva: number;
   total: number;
-  estado: 'BORRADOR' | 'ENVIADA' | 'AUTORIZADA' | 'RECHAZADA';
+  estado: 'BORRADOR' | 'FIRMADA' | 'ENVIADA' | 'RECIBIDA' | 'EN_PROCESO' | 'AUTORIZADA' | 'NO_AUTORIZADA' | 'DEVUELTA' | 'ANULADA' | 'RECHAZADA';
   numeroAutorizacion?: string;
   ambiente: 'PRUEBAS' | 'PRODUCCION';
 }